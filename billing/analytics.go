@@ -0,0 +1,42 @@
+package billing
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"encore.app/billing/core"
+	"encore.app/billing/repository"
+	"encore.dev/cron"
+	"encore.dev/rlog"
+)
+
+// Rebuilds the revenue rollups of the trailing Analytics.RefreshWindowMonths months every hour
+var _ = cron.NewJob("refresh-revenue-rollups", cron.JobConfig{
+	Title:    "Refresh revenue analytics rollups",
+	Every:    1 * cron.Hour,
+	Endpoint: RefreshRevenueRollups,
+})
+
+// rollupsRepository refreshes the revenue rollups for the cron job, whose endpoint is not a method of the Handler
+var rollupsRepository = sync.OnceValue(func() repository.Repository {
+	return repository.NewSQLRepository(db)
+})
+
+// RefreshRevenueRollups rebuilds the revenue rollups of the trailing months, called by the refresh-revenue-rollups cron job
+//
+//encore:api private method=POST path=/internal/analytics/refresh
+func RefreshRevenueRollups(ctx context.Context) error {
+	log := rlog.With("module", "billing_analytics")
+	log.Info("scheduled revenue rollups refresh started")
+
+	start := time.Now()
+	refresh, err := core.RefreshRevenueRollups(ctx, rollupsRepository(), time.Time{}, cfg.Billing.Analytics.RefreshWindowMonths())
+	if err != nil {
+		log.Error("failed to refresh revenue rollups", "error", err)
+		return err
+	}
+
+	log.Info("scheduled revenue rollups refresh completed", "from", refresh.From, "rows", refresh.Rows, "duration", time.Since(start))
+	return nil
+}
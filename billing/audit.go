@@ -0,0 +1,31 @@
+package billing
+
+import (
+	"encore.app/billing/models"
+	"encore.dev/beta/auth"
+	"encore.dev/middleware"
+)
+
+// AnonymousActor is the audit actor of mutations made by unauthenticated requests
+const AnonymousActor = "anonymous"
+
+// RequestIDHeader is the header identifying the request in the audit log, the trace ID is used when unset
+const RequestIDHeader = "X-Request-Id"
+
+// AuditMiddleware attributes the mutations of each request to its authenticated user and request ID in the audit log
+//
+//encore:middleware target=all
+func AuditMiddleware(req middleware.Request, next middleware.Next) middleware.Response {
+	metadata := models.AuditMetadata{Actor: AnonymousActor}
+	if uid, ok := auth.UserID(); ok {
+		metadata.Actor = string(uid)
+	}
+
+	data := req.Data()
+	metadata.RequestID = data.Headers.Get(RequestIDHeader)
+	if metadata.RequestID == "" && data.Trace != nil {
+		metadata.RequestID = data.Trace.TraceID
+	}
+
+	return next(req.WithContext(models.WithAuditMetadata(req.Context(), metadata)))
+}
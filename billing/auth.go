@@ -0,0 +1,30 @@
+package billing
+
+import (
+	"context"
+	"crypto/subtle"
+
+	"encore.dev/beta/auth"
+	"encore.dev/beta/errs"
+	"encore.dev/rlog"
+)
+
+// AdminUID is the user ID of requests authenticated with the admin API key
+const AdminUID auth.UID = "admin"
+
+// AuthHandler authenticates admin-only endpoints with the admin API key passed as bearer token
+//
+//encore:authhandler
+func AuthHandler(ctx context.Context, token string) (auth.UID, error) {
+	log := rlog.With("module", "billing_auth")
+
+	if secrets.AdminApiKey == "" || subtle.ConstantTimeCompare([]byte(token), []byte(secrets.AdminApiKey)) != 1 {
+		log.Warn("invalid admin API key")
+		return "", &errs.Error{
+			Code:    errs.Unauthenticated,
+			Message: "invalid API key",
+		}
+	}
+
+	return AdminUID, nil
+}
@@ -1,29 +1,44 @@
 package billing
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"time"
 
 	"encore.app/billing/core"
 	exchangerates "encore.app/billing/ext_services"
 	"encore.app/billing/models"
 	"encore.app/billing/repository"
+	"encore.app/billing/statement"
+	"encore.app/billing/validation"
+	"encore.dev"
+	"encore.dev/beta/errs"
 	"encore.dev/config"
 	"encore.dev/rlog"
 	"encore.dev/storage/cache"
+	"encore.dev/storage/objects"
 	"encore.dev/storage/sqldb"
 	"encore.dev/types/uuid"
 	"go.temporal.io/sdk/client"
 	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
 )
 
 //encore:service
 type Handler struct {
 	service        core.Service
+	validator      *validation.Validator
 	temporalClient client.Client
 	worker         worker.Worker
+	currencies     []models.CurrencyInfo
+	exports        exchangerates.ExportStorage
 }
 
 var db = sqldb.NewDatabase("billing", sqldb.DatabaseConfig{
@@ -34,11 +49,16 @@ var cacheCluster = cache.NewCluster("billing", cache.ClusterConfig{
 	EvictionPolicy: cache.AllKeysLRU,
 })
 
+// exportsBucket stores the files of accounting exports, a local bucket in development
+var exportsBucket = objects.NewBucket("billing-exports", objects.BucketConfig{})
+
 // Load loads the application configuration
 var cfg = config.Load[*models.AppConfig]()
 
 var secrets struct {
 	TemporalApiKey string
+	// AdminApiKey authenticates admin-only endpoints
+	AdminApiKey string
 }
 
 // Use configured cache TTL for exchange rates
@@ -51,6 +71,49 @@ func initHandler() (*Handler, error) {
 	log := rlog.With("module", "billing_handler")
 	log.Info("initializing billing handler")
 
+	// Fail fast on currencies unknown to the ISO 4217 currency table
+	currencies, err := models.EnabledCurrencies(cfg)
+	if err != nil {
+		log.Error("invalid currency configuration", "error", err)
+		return nil, fmt.Errorf("invalid currency configuration: %w", err)
+	}
+	log.Info("currencies enabled", "count", len(currencies))
+
+	if err = models.Currency(cfg.Billing.DefaultPresentmentCurrency()).Validate(cfg); err != nil {
+		log.Error("default presentment currency is not enabled", "currency", cfg.Billing.DefaultPresentmentCurrency())
+		return nil, fmt.Errorf("invalid default presentment currency %q: %w", cfg.Billing.DefaultPresentmentCurrency(), err)
+	}
+
+	if err = models.RoundingPolicyFromConfig(cfg).Validate(); err != nil {
+		log.Error("invalid rounding configuration", "error", err)
+		return nil, err
+	}
+
+	if err = models.ValidateActivityRetries(cfg); err != nil {
+		log.Error("invalid activity retry configuration", "error", err)
+		return nil, err
+	}
+
+	if _, err = models.CloseSettingsFromConfig(cfg); err != nil {
+		log.Error("invalid close configuration", "error", err)
+		return nil, err
+	}
+
+	if _, err = models.LedgerSettingsFromConfig(cfg); err != nil {
+		log.Error("invalid ledger configuration", "error", err)
+		return nil, err
+	}
+
+	if _, err = models.InvoiceSettingsFromConfig(cfg); err != nil {
+		log.Error("invalid invoice configuration", "error", err)
+		return nil, err
+	}
+
+	if _, err = statement.CSVLayoutFromConfig(cfg); err != nil {
+		log.Error("invalid bank statement configuration", "error", err)
+		return nil, err
+	}
+
 	// Use configured Temporal host port
 	temporalClient, err := client.Dial(client.Options{
 		HostPort:          cfg.Temporal.Address(),
@@ -58,6 +121,8 @@ func initHandler() (*Handler, error) {
 		Logger:            rlog.With("module", "temporal_worker"),
 		ConnectionOptions: client.ConnectionOptions{TLS: &tls.Config{}},
 		Credentials:       client.NewAPIKeyStaticCredentials(secrets.TemporalApiKey),
+		// Attributes the mutations persisted by activities in the audit log
+		ContextPropagators: []workflow.ContextPropagator{core.NewAuditPropagator()},
 	})
 	if err != nil {
 		log.Error("failed to create temporal client", "error", err)
@@ -74,20 +139,49 @@ func initHandler() (*Handler, error) {
 	billingService := core.NewService(cfg, temporalClient, repo, conversionService)
 	log.Info("billing core service initialized")
 
+	exportStorage := exchangerates.NewExportStorage(objects.BucketRef[exchangerates.ExportBucket](exportsBucket))
+	log.Info("export storage initialized")
+
 	// Use configured task queue
 	w := worker.New(temporalClient, cfg.Temporal.TaskQueue(), worker.Options{})
 	log.Info("temporal worker created", "task_queue", cfg.Temporal.TaskQueue())
 
 	billingWorkflows := core.NewBillWorkflows(cfg)
 	w.RegisterWorkflow(billingWorkflows.CreateBill)
-	log.Info("bill workflow registered")
+	w.RegisterWorkflow(billingWorkflows.RetryFailedOperation)
+	w.RegisterWorkflow(billingWorkflows.ReconcileBills)
+	w.RegisterWorkflow(billingWorkflows.ReconcileDraftBills)
+	w.RegisterWorkflow(billingWorkflows.RunExport)
+	log.Info("bill workflows registered")
 
-	activities := core.NewBillingActivities(repo)
+	activities := core.NewBillingActivities(repo, conversionService, cfg)
 	w.RegisterActivity(activities.SaveBill)
+	w.RegisterActivity(activities.ActivateBill)
 	w.RegisterActivity(activities.AddLineItemToBill)
+	w.RegisterActivity(activities.UpdateLineItem)
+	w.RegisterActivity(activities.RemoveLineItem)
+	w.RegisterActivity(activities.MarkBillClosing)
 	w.RegisterActivity(activities.CloseBill)
+	w.RegisterActivity(activities.VoidBill)
+	w.RegisterActivity(activities.ReopenBill)
+	w.RegisterActivity(activities.RecordFailedOperation)
+	w.RegisterActivity(activities.ResolveFailedOperation)
 	log.Info("temporal activities registered",
-		"activities", []string{"SaveBill", "AddLineItemToBill", "CloseBill"})
+		"activities", []string{"SaveBill", "ActivateBill", "AddLineItemToBill", "UpdateLineItem", "RemoveLineItem", "CloseBill", "VoidBill", "ReopenBill"})
+
+	reconciliationActivities := core.NewReconciliationActivities(repo, temporalClient, activities)
+	w.RegisterActivity(reconciliationActivities.ReconcileBillBatch)
+	w.RegisterActivity(reconciliationActivities.SaveReconciliationReport)
+	w.RegisterActivity(reconciliationActivities.ReconcileDraftBills)
+	log.Info("reconciliation activities registered",
+		"activities", []string{"ReconcileBillBatch", "SaveReconciliationReport", "ReconcileDraftBills"})
+
+	exportActivities := core.NewExportActivities(repo, exportStorage, cfg)
+	w.RegisterActivity(exportActivities.GenerateExport)
+	w.RegisterActivity(exportActivities.CompleteExport)
+	w.RegisterActivity(exportActivities.FailExport)
+	log.Info("export activities registered",
+		"activities", []string{"GenerateExport", "CompleteExport", "FailExport"})
 
 	err = w.Start()
 	if err != nil {
@@ -98,12 +192,27 @@ func initHandler() (*Handler, error) {
 	}
 	log.Info("temporal worker started successfully")
 
-	log.Info("billing handler initialization completed")
-	return &Handler{
+	// Reconciliation only reports discrepancies, the service can run without its schedule
+	if err = billingService.EnsureReconciliationSchedule(context.Background()); err != nil {
+		log.Warn("reconciliation schedule unavailable", "error", err)
+	}
+
+	// Drafts left by failed workflow starts wait for the schedule, the service keeps accepting bills without it
+	if err = billingService.EnsureDraftReconciliationSchedule(context.Background()); err != nil {
+		log.Warn("draft reconciliation schedule unavailable", "error", err)
+	}
+
+	h := &Handler{
 		service:        billingService,
+		validator:      validation.NewValidator(cfg.Billing.Validation, cfg.Billing.Pagination, cfg.Billing.Analytics, time.Now),
 		temporalClient: temporalClient,
 		worker:         w,
-	}, nil
+		currencies:     currencies,
+		exports:        exportStorage,
+	}
+
+	log.Info("billing handler initialization completed")
+	return h, nil
 }
 
 // Shutdown gracefully shuts down the service
@@ -128,7 +237,7 @@ func (h *Handler) CreateBill(ctx context.Context, req *models.CreateBillRequest)
 	log.Info("creating new bill via HTTP API")
 
 	// Validate request
-	if err := ValidateCreateBillRequest(req); err != nil {
+	if err := h.validator.ValidateCreateBillRequest(req); err != nil {
 		log.Error("request validation failed", "error", err)
 		return nil, err
 	}
@@ -157,7 +266,7 @@ func (h *Handler) AddLineItem(
 		"unit_price", req.UnitPrice)
 
 	// Validate request
-	if err := ValidateAddLineItemRequest(req); err != nil {
+	if err := h.validator.ValidateAddLineItemRequest(req); err != nil {
 		log.Error("request validation failed", "error", err)
 		return nil, err
 	}
@@ -172,6 +281,56 @@ func (h *Handler) AddLineItem(
 	return &models.BillResponse{Data: bill}, nil
 }
 
+// UpdateLineItem changes the description, quantity or unit price of a line item on an open bill
+//
+//encore:api public method=PATCH path=/bills/:billId/line-items/:lineItemId
+func (h *Handler) UpdateLineItem(
+	ctx context.Context, billId uuid.UUID, lineItemId uuid.UUID, req *models.UpdateLineItemRequest,
+) (*models.BillResponse, error) {
+	log := rlog.With("module", "billing_handler").With("http_method", "PATCH").With("http_path", fmt.Sprintf("/bills/%s/line-items/%s", billId, lineItemId)).With("bill_id", billId.String()).With("line_item_id", lineItemId.String())
+	log.Info("updating line item via HTTP API")
+
+	if err := h.validator.ValidateUpdateLineItemRequest(req); err != nil {
+		log.Error("request validation failed", "error", err)
+		return nil, err
+	}
+
+	bill, err := h.service.UpdateLineItem(ctx, billId, lineItemId, models.LineItemUpdate{
+		Description: req.Description,
+		Quantity:    req.Quantity,
+		UnitPrice:   req.UnitPrice,
+	})
+	if err != nil {
+		log.Error("failed to update line item", "error", err)
+		return nil, err
+	}
+
+	return &models.BillResponse{Data: bill}, nil
+}
+
+// RemoveLineItem removes a line item from an open bill. The line item is soft-deleted with the given reason.
+//
+//encore:api public method=DELETE path=/bills/:billId/line-items/:lineItemId
+func (h *Handler) RemoveLineItem(
+	ctx context.Context, billId uuid.UUID, lineItemId uuid.UUID, params *models.RemoveLineItemParams,
+) (*models.BillResponse, error) {
+	log := rlog.With("module", "billing_handler").With("http_method", "DELETE").With("http_path", fmt.Sprintf("/bills/%s/line-items/%s", billId, lineItemId)).With("bill_id", billId.String()).With("line_item_id", lineItemId.String())
+	log.Info("removing line item via HTTP API", "reason", params.Reason)
+
+	if err := h.validator.ValidateRemoveLineItemParams(params); err != nil {
+		log.Error("request validation failed", "error", err)
+		return nil, err
+	}
+
+	bill, err := h.service.RemoveLineItem(ctx, billId, lineItemId, params.Reason)
+	if err != nil {
+		log.Error("failed to remove line item", "error", err)
+		return nil, err
+	}
+
+	return &models.BillResponse{Data: bill}, nil
+}
+
 // CloseBill closes an active bill
 //
 //encore:api public method=POST path=/bills/:bill_id/close
@@ -188,14 +347,74 @@ func (h *Handler) CloseBill(ctx context.Context, bill_id uuid.UUID) (*models.Get
 	return &models.GetBillResponse{Data: bill}, nil
 }
 
-// GetBill retrieves a bill by ID with its line items
+// FinalizeBill finalizes a closed bill, it cannot be voided or reopened anymore
+//
+//encore:api public method=POST path=/bills/:bill_id/finalize
+func (h *Handler) FinalizeBill(ctx context.Context, bill_id uuid.UUID) (*models.GetBillResponse, error) {
+	log := rlog.With("module", "billing_handler").With("http_method", "POST").With("http_path", fmt.Sprintf("/bills/%s/finalize", bill_id)).With("bill_id", bill_id.String())
+	log.Info("finalizing bill via HTTP API")
+
+	bill, err := h.service.FinalizeBill(ctx, bill_id)
+	if err != nil {
+		log.Error("failed to finalize bill", "error", err)
+		return nil, err
+	}
+
+	return &models.GetBillResponse{Data: bill}, nil
+}
+
+// VoidBill voids a bill that is not finalized
+//
+//encore:api public method=POST path=/bills/:bill_id/void
+func (h *Handler) VoidBill(ctx context.Context, bill_id uuid.UUID, req *models.VoidBillRequest) (*models.GetBillResponse, error) {
+	log := rlog.With("module", "billing_handler").With("http_method", "POST").With("http_path", fmt.Sprintf("/bills/%s/void", bill_id)).With("bill_id", bill_id.String())
+	log.Info("voiding bill via HTTP API", "reason", req.Reason)
+
+	if err := h.validator.ValidateVoidBillRequest(req); err != nil {
+		log.Error("request validation failed", "error", err)
+		return nil, err
+	}
+
+	bill, err := h.service.VoidBill(ctx, bill_id, req.Reason)
+	if err != nil {
+		log.Error("failed to void bill", "error", err)
+		return nil, err
+	}
+
+	return &models.GetBillResponse{Data: bill}, nil
+}
+
+// ReopenBill reopens a closed bill that is not finalized. Admin only.
+//
+//encore:api auth method=POST path=/bills/:bill_id/reopen
+func (h *Handler) ReopenBill(ctx context.Context, bill_id uuid.UUID) (*models.GetBillResponse, error) {
+	log := rlog.With("module", "billing_handler").With("http_method", "POST").With("http_path", fmt.Sprintf("/bills/%s/reopen", bill_id)).With("bill_id", bill_id.String())
+	log.Info("reopening bill via HTTP API")
+
+	bill, err := h.service.ReopenBill(ctx, bill_id)
+	if err != nil {
+		log.Error("failed to reopen bill", "error", err)
+		return nil, err
+	}
+
+	return &models.GetBillResponse{Data: bill}, nil
+}
+
+// GetBill retrieves a bill by ID with its totals.
+// Line items are only included with ?include=line_items, use ListLineItems to page through large bills.
 //
 //encore:api public method=GET path=/bills/:bill_id
-func (h *Handler) GetBill(ctx context.Context, bill_id uuid.UUID) (*models.GetBillResponse, error) {
+func (h *Handler) GetBill(ctx context.Context, bill_id uuid.UUID, params *models.GetBillParams) (*models.GetBillResponse, error) {
 	log := rlog.With("module", "billing_handler").With("http_method", "GET").With("http_path", fmt.Sprintf("/bills/%s", bill_id)).With("bill_id", bill_id.String())
-	log.Info("retrieving bill via HTTP API")
+	log.Info("retrieving bill via HTTP API", "include", params.Include)
+
+	opts, err := h.validator.ParseGetBillParams(params)
+	if err != nil {
+		log.Error("request validation failed", "error", err)
+		return nil, err
+	}
 
-	bill, err := h.service.GetBillByID(ctx, bill_id)
+	bill, err := h.service.GetBillByID(ctx, bill_id, opts)
 	if err != nil {
 		log.Error("failed to retrieve bill", "error", err)
 		return nil, err
@@ -203,3 +422,818 @@ func (h *Handler) GetBill(ctx context.Context, bill_id uuid.UUID) (*models.GetBi
 
 	return &models.GetBillResponse{Data: bill}, nil
 }
+
+// ListLineItems lists the line items of a bill, ordered by creation time, with cursor pagination
+//
+//encore:api public method=GET path=/bills/:billId/line-items
+func (h *Handler) ListLineItems(
+	ctx context.Context, billId uuid.UUID, params *models.ListLineItemsParams,
+) (*models.ListLineItemsResponse, error) {
+	log := rlog.With("module", "billing_handler").With("http_method", "GET").With("http_path", fmt.Sprintf("/bills/%s/line-items", billId)).With("bill_id", billId.String())
+	log.Info("listing line items via HTTP API", "currency", params.Currency, "limit", params.Limit)
+
+	filter, err := h.validator.ParseListLineItemsParams(params)
+	if err != nil {
+		log.Error("request validation failed", "error", err)
+		return nil, err
+	}
+
+	lineItems, next, err := h.service.ListLineItems(ctx, billId, filter)
+	if err != nil {
+		log.Error("failed to list line items", "error", err)
+		return nil, err
+	}
+
+	resp := &models.ListLineItemsResponse{Data: lineItems}
+	if next != nil {
+		resp.NextCursor = next.Encode()
+	}
+	return resp, nil
+}
+
+// AuditBillTotals recomputes the totals of a closed bill and compares them with the totals persisted at close
+//
+//encore:api public method=GET path=/bills/:bill_id/totals/audit
+func (h *Handler) AuditBillTotals(ctx context.Context, bill_id uuid.UUID) (*models.AuditBillTotalsResponse, error) {
+	log := rlog.With("module", "billing_handler").With("http_method", "GET").With("http_path", fmt.Sprintf("/bills/%s/totals/audit", bill_id)).With("bill_id", bill_id.String())
+	log.Info("auditing bill totals via HTTP API")
+
+	audit, err := h.service.AuditBillTotals(ctx, bill_id)
+	if err != nil {
+		log.Error("failed to audit bill totals", "error", err)
+		return nil, err
+	}
+
+	return &models.AuditBillTotalsResponse{Data: audit}, nil
+}
+
+// GetBillHistory returns the audit trail of a bill: who changed the bill and its line items, and when. Admin only.
+// The trail is hash-chained, verified reports whether it is intact.
+//
+//encore:api auth method=GET path=/bills/:bill_id/history
+func (h *Handler) GetBillHistory(ctx context.Context, bill_id uuid.UUID) (*models.BillHistoryResponse, error) {
+	log := rlog.With("module", "billing_handler").With("http_method", "GET").With("http_path", fmt.Sprintf("/bills/%s/history", bill_id)).With("bill_id", bill_id.String())
+	log.Info("getting bill history via HTTP API")
+
+	history, err := h.service.GetBillHistory(ctx, bill_id)
+	if err != nil {
+		log.Error("failed to get bill history", "error", err)
+		return nil, err
+	}
+
+	return &models.BillHistoryResponse{Data: history}, nil
+}
+
+// RecordPayment records money received against a closed or finalized bill in the ledger. Admin only.
+// A payment received at another rate than the bill was booked also posts the FX difference.
+//
+//encore:api auth method=POST path=/bills/:bill_id/payments
+func (h *Handler) RecordPayment(
+	ctx context.Context, bill_id uuid.UUID, req *models.RecordPaymentRequest,
+) (*models.JournalsResponse, error) {
+	log := rlog.With("module", "billing_handler").With("http_method", "POST").With("http_path", fmt.Sprintf("/bills/%s/payments", bill_id)).With("bill_id", bill_id.String())
+	log.Info("recording payment via HTTP API", "reference", req.Reference)
+
+	if err := h.validator.ValidateRecordPaymentRequest(req); err != nil {
+		log.Error("request validation failed", "error", err)
+		return nil, err
+	}
+
+	journals, err := h.service.RecordPayment(ctx, bill_id, req)
+	if err != nil {
+		log.Error("failed to record payment", "error", err)
+		return nil, err
+	}
+
+	return &models.JournalsResponse{Data: journals}, nil
+}
+
+// IssueCreditNote reduces the amount owed on a closed or finalized bill in the ledger. Admin only.
+//
+//encore:api auth method=POST path=/bills/:bill_id/credit-notes
+func (h *Handler) IssueCreditNote(
+	ctx context.Context, bill_id uuid.UUID, req *models.IssueCreditNoteRequest,
+) (*models.JournalsResponse, error) {
+	log := rlog.With("module", "billing_handler").With("http_method", "POST").With("http_path", fmt.Sprintf("/bills/%s/credit-notes", bill_id)).With("bill_id", bill_id.String())
+	log.Info("issuing credit note via HTTP API", "reference", req.Reference)
+
+	if err := h.validator.ValidateIssueCreditNoteRequest(req); err != nil {
+		log.Error("request validation failed", "error", err)
+		return nil, err
+	}
+
+	journal, err := h.service.IssueCreditNote(ctx, bill_id, req)
+	if err != nil {
+		log.Error("failed to issue credit note", "error", err)
+		return nil, err
+	}
+
+	return &models.JournalsResponse{Data: []*models.Journal{journal}}, nil
+}
+
+// GetBillLedger returns the ledger journals posted for a bill. Admin only.
+//
+//encore:api auth method=GET path=/bills/:bill_id/ledger
+func (h *Handler) GetBillLedger(ctx context.Context, bill_id uuid.UUID) (*models.JournalsResponse, error) {
+	log := rlog.With("module", "billing_handler").With("http_method", "GET").With("http_path", fmt.Sprintf("/bills/%s/ledger", bill_id)).With("bill_id", bill_id.String())
+	log.Info("getting bill ledger via HTTP API")
+
+	journals, err := h.service.GetBillLedger(ctx, bill_id)
+	if err != nil {
+		log.Error("failed to get bill ledger", "error", err)
+		return nil, err
+	}
+
+	return &models.JournalsResponse{Data: journals}, nil
+}
+
+// GetBillRecognitionSchedules returns the recognition schedules of the line items of a bill, with the revenue
+// recognized and still deferred per month. Bills have schedules from when they close. Admin only.
+//
+//encore:api auth method=GET path=/bills/:bill_id/revenue-schedule
+func (h *Handler) GetBillRecognitionSchedules(ctx context.Context, bill_id uuid.UUID) (*models.RecognitionSchedulesResponse, error) {
+	log := rlog.With("module", "billing_handler").With("http_method", "GET").With("http_path", fmt.Sprintf("/bills/%s/revenue-schedule", bill_id)).With("bill_id", bill_id.String())
+	log.Info("getting bill recognition schedules via HTTP API")
+
+	schedules, err := h.service.GetBillRecognitionSchedules(ctx, bill_id)
+	if err != nil {
+		log.Error("failed to get bill recognition schedules", "error", err)
+		return nil, err
+	}
+
+	return &models.RecognitionSchedulesResponse{Data: schedules}, nil
+}
+
+// GetBillInvoice returns the UBL 2.1 invoice of a closed or finalized bill for Peppol delivery.
+// The customer profile must have a party.
+//
+//encore:api public raw method=GET path=/bills/:bill_id/invoice.xml
+func (h *Handler) GetBillInvoice(w http.ResponseWriter, req *http.Request) {
+	billID := encore.CurrentRequest().PathParams.Get("bill_id")
+	log := rlog.With("module", "billing_handler").With("http_method", "GET").With("http_path", fmt.Sprintf("/bills/%s/invoice.xml", billID)).With("bill_id", billID)
+	log.Info("getting bill invoice via HTTP API")
+
+	id, err := uuid.FromString(billID)
+	if err != nil {
+		log.Warn("validation failed: invalid bill ID")
+		errs.HTTPError(w, &errs.Error{Code: errs.InvalidArgument, Message: "bill_id must be a UUID"})
+		return
+	}
+
+	doc, err := h.service.GetBillInvoice(req.Context(), id)
+	if err != nil {
+		log.Error("failed to generate bill invoice", "error", err)
+		errs.HTTPError(w, err)
+		return
+	}
+
+	writeXML(w, doc, log)
+}
+
+// GetCreditNoteDocument returns the UBL 2.1 credit note of a credit note issued on a bill, by its reference,
+// for Peppol delivery. The customer profile must have a party.
+//
+//encore:api public raw method=GET path=/bills/:bill_id/credit-notes/:reference/credit-note.xml
+func (h *Handler) GetCreditNoteDocument(w http.ResponseWriter, req *http.Request) {
+	billID := encore.CurrentRequest().PathParams.Get("bill_id")
+	reference := encore.CurrentRequest().PathParams.Get("reference")
+	log := rlog.With("module", "billing_handler").With("http_method", "GET").With("http_path", fmt.Sprintf("/bills/%s/credit-notes/%s/credit-note.xml", billID, reference)).With("bill_id", billID).With("reference", reference)
+	log.Info("getting credit note document via HTTP API")
+
+	id, err := uuid.FromString(billID)
+	if err != nil {
+		log.Warn("validation failed: invalid bill ID")
+		errs.HTTPError(w, &errs.Error{Code: errs.InvalidArgument, Message: "bill_id must be a UUID"})
+		return
+	}
+
+	doc, err := h.service.GetCreditNoteDocument(req.Context(), id, reference)
+	if err != nil {
+		log.Error("failed to generate credit note document", "error", err)
+		errs.HTTPError(w, err)
+		return
+	}
+
+	writeXML(w, doc, log)
+}
+
+// writeXML writes the XML document as the response
+func writeXML(w http.ResponseWriter, doc []byte, log rlog.Ctx) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	if _, err := w.Write(doc); err != nil {
+		// The status is sent already
+		log.Error("failed to write XML document", "error", err)
+	}
+}
+
+// GetTrialBalance returns the debits and credits of every ledger account up to ?as_of=, now by default,
+// for export to the ERP. Balanced reports whether debits equal credits. Admin only.
+//
+//encore:api auth method=GET path=/ledger/trial-balance
+func (h *Handler) GetTrialBalance(
+	ctx context.Context, params *models.GetTrialBalanceParams,
+) (*models.TrialBalanceResponse, error) {
+	log := rlog.With("module", "billing_handler").With("http_method", "GET").With("http_path", "/ledger/trial-balance")
+	log.Info("getting trial balance via HTTP API", "as_of", params.AsOf)
+
+	asOf, err := h.validator.ParseGetTrialBalanceParams(params)
+	if err != nil {
+		log.Error("request validation failed", "error", err)
+		return nil, err
+	}
+
+	balance, err := h.service.GetTrialBalance(ctx, asOf)
+	if err != nil {
+		log.Error("failed to get trial balance", "error", err)
+		return nil, err
+	}
+
+	return &models.TrialBalanceResponse{Data: balance}, nil
+}
+
+// GetAgingReport buckets the accounts receivable open at ?as_of=, now by default, by the days past due per customer
+// and currency, converted to ?reporting_currency=. Narrow it to one customer with ?customer_id=. Admin only.
+//
+//encore:api auth method=GET path=/reports/ar-aging
+func (h *Handler) GetAgingReport(ctx context.Context, params *models.GetAgingReportParams) (*models.AgingReportResponse, error) {
+	log := rlog.With("module", "billing_handler").With("http_method", "GET").With("http_path", "/reports/ar-aging")
+	log.Info("getting aging report via HTTP API",
+		"as_of", params.AsOf,
+		"customer_id", params.CustomerID,
+		"reporting_currency", params.ReportingCurrency)
+
+	filter, err := h.validator.ParseGetAgingReportParams(params)
+	if err != nil {
+		log.Error("request validation failed", "error", err)
+		return nil, err
+	}
+
+	report, err := h.service.GetAgingReport(ctx, filter)
+	if err != nil {
+		log.Error("failed to get aging report", "error", err)
+		return nil, err
+	}
+
+	return &models.AgingReportResponse{Data: report}, nil
+}
+
+// GetCustomerStatement lists the bills, payments and credit notes of a customer from ?from= until ?to=,
+// now by default, with the running balance per currency. Admin only.
+//
+//encore:api auth method=GET path=/customers/:customer_id/statement
+func (h *Handler) GetCustomerStatement(
+	ctx context.Context, customer_id string, params *models.GetCustomerStatementParams,
+) (*models.CustomerStatementResponse, error) {
+	log := rlog.With("module", "billing_handler").With("http_method", "GET").With("http_path", fmt.Sprintf("/customers/%s/statement", customer_id)).With("customer_id", customer_id)
+	log.Info("getting customer statement via HTTP API",
+		"from", params.From,
+		"to", params.To,
+		"reporting_currency", params.ReportingCurrency)
+
+	filter, err := h.validator.ParseGetCustomerStatementParams(params)
+	if err != nil {
+		log.Error("request validation failed", "error", err)
+		return nil, err
+	}
+
+	statement, err := h.service.GetCustomerStatement(ctx, customer_id, filter)
+	if err != nil {
+		log.Error("failed to get customer statement", "error", err)
+		return nil, err
+	}
+
+	return &models.CustomerStatementResponse{Data: statement}, nil
+}
+
+// RefreshRevenueRollups rebuilds the revenue rollups from the month of `from`, e.g. after backdated changes older than
+// the trailing months refreshed every hour, which are rebuilt when `from` is omitted. Admin only.
+//
+//encore:api auth method=POST path=/analytics/refresh
+func (h *Handler) RefreshRevenueRollups(
+	ctx context.Context, req *models.RefreshRevenueRollupsRequest,
+) (*models.RollupRefreshResponse, error) {
+	log := rlog.With("module", "billing_handler").With("http_method", "POST").With("http_path", "/analytics/refresh")
+	log.Info("refreshing revenue rollups via HTTP API", "from", req.From)
+
+	if err := h.validator.ValidateRefreshRevenueRollupsRequest(req); err != nil {
+		log.Error("request validation failed", "error", err)
+		return nil, err
+	}
+
+	refresh, err := h.service.RefreshRevenueRollups(ctx, req.From)
+	if err != nil {
+		log.Error("failed to refresh revenue rollups", "error", err)
+		return nil, err
+	}
+
+	return &models.RollupRefreshResponse{Data: refresh}, nil
+}
+
+// GetRevenueReport totals the revenue billed per ?granularity= period from ?from= to ?to= months by currency
+// and converted to ?reporting_currency=, as of the last refresh of the revenue rollups. Admin only.
+//
+//encore:api auth method=GET path=/analytics/revenue
+func (h *Handler) GetRevenueReport(ctx context.Context, params *models.GetRevenueReportParams) (*models.RevenueReportResponse, error) {
+	log := rlog.With("module", "billing_handler").With("http_method", "GET").With("http_path", "/analytics/revenue")
+	log.Info("getting revenue report via HTTP API",
+		"from", params.From,
+		"to", params.To,
+		"granularity", params.Granularity,
+		"reporting_currency", params.ReportingCurrency)
+
+	filter, err := h.validator.ParseGetRevenueReportParams(params)
+	if err != nil {
+		log.Error("request validation failed", "error", err)
+		return nil, err
+	}
+
+	report, err := h.service.GetRevenueReport(ctx, filter)
+	if err != nil {
+		log.Error("failed to get revenue report", "error", err)
+		return nil, err
+	}
+
+	return &models.RevenueReportResponse{Data: report}, nil
+}
+
+// GetMRRReport returns the MRR and ARR per month from ?from= to ?to= in ?reporting_currency=, with the new, expansion,
+// contraction and churned MRR and customer churn, as of the last refresh of the revenue rollups. Admin only.
+//
+//encore:api auth method=GET path=/analytics/mrr
+func (h *Handler) GetMRRReport(ctx context.Context, params *models.GetMRRReportParams) (*models.MRRReportResponse, error) {
+	log := rlog.With("module", "billing_handler").With("http_method", "GET").With("http_path", "/analytics/mrr")
+	log.Info("getting MRR report via HTTP API",
+		"from", params.From,
+		"to", params.To,
+		"reporting_currency", params.ReportingCurrency)
+
+	filter, err := h.validator.ParseGetMRRReportParams(params)
+	if err != nil {
+		log.Error("request validation failed", "error", err)
+		return nil, err
+	}
+
+	report, err := h.service.GetMRRReport(ctx, filter)
+	if err != nil {
+		log.Error("failed to get MRR report", "error", err)
+		return nil, err
+	}
+
+	return &models.MRRReportResponse{Data: report}, nil
+}
+
+// GetDeferredRevenueReport returns the deferred revenue waterfall from ?from= to ?to= per month and currency: the
+// revenue deferred at the start of each month, billed by the bills closed in it, recognized and deferred at its end,
+// and when the revenue deferred at the end is to be recognized. Admin only.
+//
+//encore:api auth method=GET path=/reports/deferred-revenue
+func (h *Handler) GetDeferredRevenueReport(
+	ctx context.Context, params *models.GetDeferredRevenueReportParams,
+) (*models.DeferredRevenueReportResponse, error) {
+	log := rlog.With("module", "billing_handler").With("http_method", "GET").With("http_path", "/reports/deferred-revenue")
+	log.Info("getting deferred revenue report via HTTP API", "from", params.From, "to", params.To)
+
+	filter, err := h.validator.ParseGetDeferredRevenueReportParams(params)
+	if err != nil {
+		log.Error("request validation failed", "error", err)
+		return nil, err
+	}
+
+	report, err := h.service.GetDeferredRevenueReport(ctx, filter)
+	if err != nil {
+		log.Error("failed to get deferred revenue report", "error", err)
+		return nil, err
+	}
+
+	return &models.DeferredRevenueReportResponse{Data: report}, nil
+}
+
+// CreateExport starts an export of the bills closed, or ledger journals posted, from `from` until `to`
+// in the requested format. Poll the export until completed, then download its file. Admin only.
+//
+//encore:api auth method=POST path=/exports
+func (h *Handler) CreateExport(ctx context.Context, req *models.CreateExportRequest) (*models.ExportJobResponse, error) {
+	log := rlog.With("module", "billing_handler").With("http_method", "POST").With("http_path", "/exports")
+	log.Info("creating export via HTTP API", "format", req.Format, "from", req.From, "to", req.To)
+
+	if err := h.validator.ValidateCreateExportRequest(req); err != nil {
+		log.Error("request validation failed", "error", err)
+		return nil, err
+	}
+
+	job, err := h.service.CreateExport(ctx, req)
+	if err != nil {
+		log.Error("failed to create export", "error", err)
+		return nil, err
+	}
+
+	log.Info("export created via HTTP API", "export_id", job.ID)
+	return &models.ExportJobResponse{Data: job}, nil
+}
+
+// GetExport returns the status of an export. Admin only.
+//
+//encore:api auth method=GET path=/exports/:export_id
+func (h *Handler) GetExport(ctx context.Context, export_id uuid.UUID) (*models.ExportJobResponse, error) {
+	log := rlog.With("module", "billing_handler").With("http_method", "GET").With("http_path", fmt.Sprintf("/exports/%s", export_id)).With("export_id", export_id.String())
+	log.Info("getting export via HTTP API")
+
+	job, err := h.service.GetExport(ctx, export_id)
+	if err != nil {
+		log.Error("failed to get export", "error", err)
+		return nil, err
+	}
+
+	return &models.ExportJobResponse{Data: job}, nil
+}
+
+// DownloadExport streams the file of a completed export as an attachment. Admin only.
+//
+//encore:api auth raw method=GET path=/exports/:export_id/download
+func (h *Handler) DownloadExport(w http.ResponseWriter, req *http.Request) {
+	exportID := encore.CurrentRequest().PathParams.Get("export_id")
+	log := rlog.With("module", "billing_handler").With("http_method", "GET").With("http_path", fmt.Sprintf("/exports/%s/download", exportID)).With("export_id", exportID)
+	log.Info("downloading export via HTTP API")
+
+	id, err := uuid.FromString(exportID)
+	if err != nil {
+		log.Warn("validation failed: invalid export ID")
+		errs.HTTPError(w, &errs.Error{Code: errs.InvalidArgument, Message: "export_id must be a UUID"})
+		return
+	}
+
+	job, err := h.service.GetExportDownload(req.Context(), id)
+	if err != nil {
+		log.Error("failed to get export", "error", err)
+		errs.HTTPError(w, err)
+		return
+	}
+
+	file, err := h.exports.Download(req.Context(), job.ObjectKey)
+	if err != nil {
+		log.Error("failed to download export file", "error", err)
+		errs.HTTPError(w, err)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", job.Format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", job.FileName()))
+	if _, err = io.Copy(w, file); err != nil {
+		// The status is sent already
+		log.Error("failed to stream export file", "error", err)
+		return
+	}
+
+	log.Info("export downloaded via HTTP API")
+}
+
+// ListBillWorkflows lists open and closing bills from the search attributes of their Temporal workflow. Admin only.
+// The index is eventually consistent, it complements the database for live state.
+//
+//encore:api auth method=GET path=/admin/bills
+func (h *Handler) ListBillWorkflows(
+	ctx context.Context, params *models.ListBillWorkflowsParams,
+) (*models.ListBillWorkflowsResponse, error) {
+	log := rlog.With("module", "billing_handler").With("http_method", "GET").With("http_path", "/admin/bills")
+	log.Info("listing bill workflows via HTTP API", "customer_id", params.CustomerID, "limit", params.Limit)
+
+	filter, err := h.validator.ParseListBillWorkflowsParams(params)
+	if err != nil {
+		log.Error("request validation failed", "error", err)
+		return nil, err
+	}
+
+	bills, nextPageToken, err := h.service.ListBillWorkflows(ctx, filter)
+	if err != nil {
+		log.Error("failed to list bill workflows", "error", err)
+		return nil, err
+	}
+
+	resp := &models.ListBillWorkflowsResponse{Data: bills}
+	if len(nextPageToken) > 0 {
+		resp.NextPageToken = base64.RawURLEncoding.EncodeToString(nextPageToken)
+	}
+	return resp, nil
+}
+
+// StartReconciliation reconciles the open bill workflows with the database on demand. Admin only.
+//
+//encore:api auth method=POST path=/admin/reconciliations
+func (h *Handler) StartReconciliation(
+	ctx context.Context, req *models.StartReconciliationRequest,
+) (*models.StartReconciliationResponse, error) {
+	log := rlog.With("module", "billing_handler").With("http_method", "POST").With("http_path", "/admin/reconciliations")
+	log.Info("starting reconciliation via HTTP API", "repair", req.Repair)
+
+	workflowID, err := h.service.StartReconciliation(ctx, req.Repair)
+	if err != nil {
+		log.Error("failed to start reconciliation", "error", err)
+		return nil, err
+	}
+
+	return &models.StartReconciliationResponse{WorkflowID: workflowID}, nil
+}
+
+// ListReconciliationReports lists the most recent reconciliation reports. Admin only.
+//
+//encore:api auth method=GET path=/admin/reconciliations
+func (h *Handler) ListReconciliationReports(
+	ctx context.Context, params *models.ListReconciliationReportsParams,
+) (*models.ListReconciliationReportsResponse, error) {
+	log := rlog.With("module", "billing_handler").With("http_method", "GET").With("http_path", "/admin/reconciliations")
+	log.Info("listing reconciliation reports via HTTP API", "limit", params.Limit)
+
+	limit, err := h.validator.ParseListReconciliationReportsParams(params)
+	if err != nil {
+		log.Error("request validation failed", "error", err)
+		return nil, err
+	}
+
+	reports, err := h.service.ListReconciliationReports(ctx, limit)
+	if err != nil {
+		log.Error("failed to list reconciliation reports", "error", err)
+		return nil, err
+	}
+
+	return &models.ListReconciliationReportsResponse{Data: reports}, nil
+}
+
+// GetReconciliationReport retrieves a reconciliation report with its discrepancies. Admin only.
+//
+//encore:api auth method=GET path=/admin/reconciliations/:report_id
+func (h *Handler) GetReconciliationReport(ctx context.Context, report_id uuid.UUID) (*models.ReconciliationReportResponse, error) {
+	log := rlog.With("module", "billing_handler").With("http_method", "GET").With("http_path", fmt.Sprintf("/admin/reconciliations/%s", report_id)).With("report_id", report_id.String())
+	log.Info("retrieving reconciliation report via HTTP API")
+
+	report, err := h.service.GetReconciliationReport(ctx, report_id)
+	if err != nil {
+		log.Error("failed to retrieve reconciliation report", "error", err)
+		return nil, err
+	}
+
+	return &models.ReconciliationReportResponse{Data: report}, nil
+}
+
+// ListFailedOperations lists the latest bill operations that exhausted their retries. Admin only.
+//
+//encore:api auth method=GET path=/admin/failed-operations
+func (h *Handler) ListFailedOperations(
+	ctx context.Context, params *models.ListFailedOperationsParams,
+) (*models.ListFailedOperationsResponse, error) {
+	log := rlog.With("module", "billing_handler").With("http_method", "GET").With("http_path", "/admin/failed-operations")
+	log.Info("listing failed operations via HTTP API", "status", params.Status, "bill_id", params.BillID, "limit", params.Limit)
+
+	filter, err := h.validator.ParseListFailedOperationsParams(params)
+	if err != nil {
+		log.Error("request validation failed", "error", err)
+		return nil, err
+	}
+
+	ops, err := h.service.ListFailedOperations(ctx, filter)
+	if err != nil {
+		log.Error("failed to list failed operations", "error", err)
+		return nil, err
+	}
+
+	return &models.ListFailedOperationsResponse{Data: ops}, nil
+}
+
+// GetFailedOperation retrieves a failed operation with its payload and attempts. Admin only.
+//
+//encore:api auth method=GET path=/admin/failed-operations/:operation_id
+func (h *Handler) GetFailedOperation(ctx context.Context, operation_id uuid.UUID) (*models.FailedOperationResponse, error) {
+	log := rlog.With("module", "billing_handler").With("http_method", "GET").With("http_path", fmt.Sprintf("/admin/failed-operations/%s", operation_id)).With("failed_operation_id", operation_id.String())
+	log.Info("retrieving failed operation via HTTP API")
+
+	op, err := h.service.GetFailedOperation(ctx, operation_id)
+	if err != nil {
+		log.Error("failed to retrieve failed operation", "error", err)
+		return nil, err
+	}
+
+	return &models.FailedOperationResponse{Data: op}, nil
+}
+
+// RetryFailedOperation asks the bill workflow to execute a pending failed operation again,
+// or retries it from its database record when the bill workflow is no longer running. Admin only.
+//
+//encore:api auth method=POST path=/admin/failed-operations/:operation_id/retry
+func (h *Handler) RetryFailedOperation(ctx context.Context, operation_id uuid.UUID) (*models.FailedOperationResponse, error) {
+	log := rlog.With("module", "billing_handler").With("http_method", "POST").With("http_path", fmt.Sprintf("/admin/failed-operations/%s/retry", operation_id)).With("failed_operation_id", operation_id.String())
+	log.Info("retrying failed operation via HTTP API")
+
+	op, err := h.service.RetryFailedOperation(ctx, operation_id)
+	if err != nil {
+		log.Error("failed to retry failed operation", "error", err)
+		return nil, err
+	}
+
+	return &models.FailedOperationResponse{Data: op}, nil
+}
+
+// AcknowledgeFailedOperation accepts that a pending failed operation is not persisted,
+// letting its bill workflow complete. Admin only.
+//
+//encore:api auth method=POST path=/admin/failed-operations/:operation_id/acknowledge
+func (h *Handler) AcknowledgeFailedOperation(
+	ctx context.Context, operation_id uuid.UUID, req *models.AcknowledgeFailedOperationRequest,
+) (*models.FailedOperationResponse, error) {
+	log := rlog.With("module", "billing_handler").With("http_method", "POST").With("http_path", fmt.Sprintf("/admin/failed-operations/%s/acknowledge", operation_id)).With("failed_operation_id", operation_id.String())
+	log.Info("acknowledging failed operation via HTTP API", "note", req.Note)
+
+	if err := h.validator.ValidateAcknowledgeFailedOperationRequest(req); err != nil {
+		log.Error("request validation failed", "error", err)
+		return nil, err
+	}
+
+	op, err := h.service.AcknowledgeFailedOperation(ctx, operation_id, req.Note)
+	if err != nil {
+		log.Error("failed to acknowledge failed operation", "error", err)
+		return nil, err
+	}
+
+	return &models.FailedOperationResponse{Data: op}, nil
+}
+
+// ImportBankStatement imports the credits of a bank statement uploaded as the request body, camt.053 XML
+// or CSV with the configured layout as selected by ?format=. Credits paying the outstanding balance of the bill
+// their reference names are recorded as payments, the others are queued for review. Admin only.
+//
+//encore:api auth raw method=POST path=/admin/bank-statements
+func (h *Handler) ImportBankStatement(w http.ResponseWriter, req *http.Request) {
+	log := rlog.With("module", "billing_handler").With("http_method", "POST").With("http_path", "/admin/bank-statements")
+	log.Info("importing bank statement via HTTP API", "format", req.URL.Query().Get("format"))
+
+	format, err := h.validator.ParseStatementFormat(req.URL.Query().Get("format"))
+	if err != nil {
+		log.Error("request validation failed", "error", err)
+		errs.HTTPError(w, err)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, int64(cfg.Billing.BankStatements.MaxStatementBytes())))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			log.Warn("validation failed: bank statement too large", "limit", tooLarge.Limit)
+			errs.HTTPError(w, models.ErrStatementTooLarge)
+			return
+		}
+		log.Error("failed to read bank statement", "error", err)
+		errs.HTTPError(w, err)
+		return
+	}
+
+	result, err := h.service.ImportBankStatement(req.Context(), format, bytes.NewReader(body))
+	if err != nil {
+		log.Error("failed to import bank statement", "error", err)
+		errs.HTTPError(w, err)
+		return
+	}
+
+	log.Info("bank statement imported via HTTP API", "matched", result.Matched, "review", result.Review)
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(&models.StatementImportResponse{Data: result}); err != nil {
+		// The status is sent already
+		log.Error("failed to write import result", "error", err)
+	}
+}
+
+// ListBankTransactions lists the imported bank credits latest booked first, ?status=review for the review queue.
+// Admin only.
+//
+//encore:api auth method=GET path=/admin/bank-transactions
+func (h *Handler) ListBankTransactions(
+	ctx context.Context, params *models.ListBankTransactionsParams,
+) (*models.ListBankTransactionsResponse, error) {
+	log := rlog.With("module", "billing_handler").With("http_method", "GET").With("http_path", "/admin/bank-transactions")
+	log.Info("listing bank transactions via HTTP API", "status", params.Status, "limit", params.Limit)
+
+	filter, err := h.validator.ParseListBankTransactionsParams(params)
+	if err != nil {
+		log.Error("request validation failed", "error", err)
+		return nil, err
+	}
+
+	txns, err := h.service.ListBankTransactions(ctx, filter)
+	if err != nil {
+		log.Error("failed to list bank transactions", "error", err)
+		return nil, err
+	}
+
+	return &models.ListBankTransactionsResponse{Data: txns}, nil
+}
+
+// GetBankTransaction retrieves an imported bank credit with its candidate bills. Admin only.
+//
+//encore:api auth method=GET path=/admin/bank-transactions/:transaction_id
+func (h *Handler) GetBankTransaction(ctx context.Context, transaction_id uuid.UUID) (*models.BankTransactionResponse, error) {
+	log := rlog.With("module", "billing_handler").With("http_method", "GET").With("http_path", fmt.Sprintf("/admin/bank-transactions/%s", transaction_id)).With("bank_transaction_id", transaction_id.String())
+	log.Info("retrieving bank transaction via HTTP API")
+
+	txn, err := h.service.GetBankTransaction(ctx, transaction_id)
+	if err != nil {
+		log.Error("failed to retrieve bank transaction", "error", err)
+		return nil, err
+	}
+
+	return &models.BankTransactionResponse{Data: txn}, nil
+}
+
+// MatchBankTransaction records a bank credit in review as a payment of the given bill. Admin only.
+//
+//encore:api auth method=POST path=/admin/bank-transactions/:transaction_id/match
+func (h *Handler) MatchBankTransaction(
+	ctx context.Context, transaction_id uuid.UUID, req *models.MatchBankTransactionRequest,
+) (*models.BankTransactionResponse, error) {
+	log := rlog.With("module", "billing_handler").With("http_method", "POST").With("http_path", fmt.Sprintf("/admin/bank-transactions/%s/match", transaction_id)).With("bank_transaction_id", transaction_id.String())
+	log.Info("matching bank transaction via HTTP API", "bill_id", req.BillID)
+
+	if err := h.validator.ValidateMatchBankTransactionRequest(req); err != nil {
+		log.Error("request validation failed", "error", err)
+		return nil, err
+	}
+
+	txn, err := h.service.MatchBankTransaction(ctx, transaction_id, req.BillID)
+	if err != nil {
+		log.Error("failed to match bank transaction", "error", err)
+		return nil, err
+	}
+
+	return &models.BankTransactionResponse{Data: txn}, nil
+}
+
+// DismissBankTransaction removes a bank credit that is not the payment of a bill from the review queue. Admin only.
+//
+//encore:api auth method=POST path=/admin/bank-transactions/:transaction_id/dismiss
+func (h *Handler) DismissBankTransaction(
+	ctx context.Context, transaction_id uuid.UUID, req *models.DismissBankTransactionRequest,
+) (*models.BankTransactionResponse, error) {
+	log := rlog.With("module", "billing_handler").With("http_method", "POST").With("http_path", fmt.Sprintf("/admin/bank-transactions/%s/dismiss", transaction_id)).With("bank_transaction_id", transaction_id.String())
+	log.Info("dismissing bank transaction via HTTP API", "note", req.Note)
+
+	if err := h.validator.ValidateDismissBankTransactionRequest(req); err != nil {
+		log.Error("request validation failed", "error", err)
+		return nil, err
+	}
+
+	txn, err := h.service.DismissBankTransaction(ctx, transaction_id, req.Note)
+	if err != nil {
+		log.Error("failed to dismiss bank transaction", "error", err)
+		return nil, err
+	}
+
+	return &models.BankTransactionResponse{Data: txn}, nil
+}
+
+// GetCustomerProfile retrieves the billing profile of a customer
+//
+//encore:api public method=GET path=/customers/:customer_id/profile
+func (h *Handler) GetCustomerProfile(ctx context.Context, customer_id string) (*models.CustomerProfileResponse, error) {
+	log := rlog.With("module", "billing_handler").With("http_method", "GET").With("http_path", fmt.Sprintf("/customers/%s/profile", customer_id)).With("customer_id", customer_id)
+	log.Info("retrieving customer profile via HTTP API")
+
+	profile, err := h.service.GetCustomerProfile(ctx, customer_id)
+	if err != nil {
+		log.Error("failed to retrieve customer profile", "error", err)
+		return nil, err
+	}
+
+	return &models.CustomerProfileResponse{Data: profile}, nil
+}
+
+// UpsertCustomerProfile creates or updates the billing profile of a customer
+//
+//encore:api public method=PUT path=/customers/:customer_id/profile
+func (h *Handler) UpsertCustomerProfile(
+	ctx context.Context, customer_id string, req *models.UpsertCustomerProfileRequest,
+) (*models.CustomerProfileResponse, error) {
+	log := rlog.With("module", "billing_handler").With("http_method", "PUT").With("http_path", fmt.Sprintf("/customers/%s/profile", customer_id)).With("customer_id", customer_id)
+	log.Info("upserting customer profile via HTTP API", "presentment_currency", req.PresentmentCurrency)
+
+	// Validate request
+	if err := h.validator.ValidateUpsertCustomerProfileRequest(customer_id, req); err != nil {
+		log.Error("request validation failed", "error", err)
+		return nil, err
+	}
+	log.Info("request validation passed")
+
+	profile, err := h.service.UpsertCustomerProfile(ctx, customer_id, req)
+	if err != nil {
+		log.Error("failed to upsert customer profile", "error", err)
+		return nil, err
+	}
+
+	return &models.CustomerProfileResponse{Data: profile}, nil
+}
+
+// ListCurrencies lists the enabled currencies and their ISO 4217 metadata
+//
+//encore:api public method=GET path=/currencies
+func (h *Handler) ListCurrencies(ctx context.Context) (*models.ListCurrenciesResponse, error) {
+	log := rlog.With("module", "billing_handler").With("http_method", "GET").With("http_path", "/currencies")
+	log.Info("listing currencies via HTTP API")
+
+	return &models.ListCurrenciesResponse{Data: h.currencies}, nil
+}
@@ -2,12 +2,15 @@ package billing
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
 	"testing"
 	"time"
 
+	"encore.app/billing/core"
 	"encore.app/billing/core/mocks"
 	"encore.app/billing/models"
+	"encore.app/billing/validation"
 	"encore.dev/beta/errs"
 	"encore.dev/types/uuid"
 	"github.com/golang/mock/gomock"
@@ -15,6 +18,11 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+// newTestHandler creates a handler validating requests with the loaded configuration
+func newTestHandler(service core.Service) *Handler {
+	return &Handler{service: service, validator: validation.NewValidator(cfg.Billing.Validation, cfg.Billing.Pagination, cfg.Billing.Analytics, time.Now)}
+}
+
 func TestCreateBill(t *testing.T) {
 	t.Run("when_request_is_invalid_should_return_error", func(t *testing.T) {
 		req := &models.CreateBillRequest{
@@ -22,7 +30,7 @@ func TestCreateBill(t *testing.T) {
 			PeriodStart: time.Now(),
 			PeriodEnd:   time.Now().AddDate(0, 1, 0),
 		}
-		handler := &Handler{}
+		handler := newTestHandler(nil)
 		response, err := handler.CreateBill(context.TODO(), req)
 
 		assert.Error(t, err)
@@ -42,7 +50,7 @@ func TestCreateBill(t *testing.T) {
 
 		t.Run("should_create_bill", func(t *testing.T) {
 			mockSvc := mocks.NewMockService(gomock.NewController(t))
-			handler := &Handler{service: mockSvc}
+			handler := newTestHandler(mockSvc)
 
 			defer handler.CreateBill(context.TODO(), req)
 
@@ -51,7 +59,7 @@ func TestCreateBill(t *testing.T) {
 		t.Run("when_service_returns_success", func(t *testing.T) {
 			t.Run("should_return_bill", func(t *testing.T) {
 				mockSvc := mocks.NewMockService(gomock.NewController(t))
-				handler := &Handler{service: mockSvc}
+				handler := newTestHandler(mockSvc)
 				returnedBill := &models.Bill{
 					ID:          uuid.Must(uuid.NewV4()),
 					CustomerID:  req.CustomerID,
@@ -73,7 +81,7 @@ func TestCreateBill(t *testing.T) {
 		})
 		t.Run("when_service_returns_error", func(t *testing.T) {
 			mockSvc := mocks.NewMockService(gomock.NewController(t))
-			handler := &Handler{service: mockSvc}
+			handler := newTestHandler(mockSvc)
 			mockSvc.EXPECT().CreateBill(gomock.Any(), req).Return(nil, errors.New("some error"))
 
 			res, err := handler.CreateBill(context.TODO(), req)
@@ -93,7 +101,7 @@ func TestAddLineItem(t *testing.T) {
 			Quantity:    decimal.NewFromFloat(2.0),
 			UnitPrice:   decimal.NewFromFloat(10.50),
 		}
-		handler := &Handler{}
+		handler := newTestHandler(nil)
 		response, err := handler.AddLineItem(context.TODO(), billID, req)
 
 		assert.Error(t, err)
@@ -116,7 +124,7 @@ func TestAddLineItem(t *testing.T) {
 
 		t.Run("should_add_line_item", func(t *testing.T) {
 			mockSvc := mocks.NewMockService(gomock.NewController(t))
-			handler := &Handler{service: mockSvc}
+			handler := newTestHandler(mockSvc)
 
 			defer handler.AddLineItem(context.TODO(), billID, req)
 
@@ -126,7 +134,7 @@ func TestAddLineItem(t *testing.T) {
 		t.Run("when_service_returns_success", func(t *testing.T) {
 			t.Run("should_return_updated_bill", func(t *testing.T) {
 				mockSvc := mocks.NewMockService(gomock.NewController(t))
-				handler := &Handler{service: mockSvc}
+				handler := newTestHandler(mockSvc)
 				returnedBill := &models.Bill{
 					ID:        billID,
 					Status:    models.BillStatusOpen,
@@ -156,7 +164,7 @@ func TestAddLineItem(t *testing.T) {
 
 		t.Run("when_service_returns_error", func(t *testing.T) {
 			mockSvc := mocks.NewMockService(gomock.NewController(t))
-			handler := &Handler{service: mockSvc}
+			handler := newTestHandler(mockSvc)
 			mockSvc.EXPECT().AddLineItemToBill(gomock.Any(), billID, req).Return(nil, errors.New("some error"))
 
 			res, err := handler.AddLineItem(context.TODO(), billID, req)
@@ -167,13 +175,87 @@ func TestAddLineItem(t *testing.T) {
 	})
 }
 
+func TestUpdateLineItem(t *testing.T) {
+	billID := uuid.Must(uuid.NewV4())
+	lineItemID := uuid.Must(uuid.NewV4())
+
+	t.Run("when_request_is_empty_should_return_error", func(t *testing.T) {
+		handler := newTestHandler(nil)
+		response, err := handler.UpdateLineItem(context.TODO(), billID, lineItemID, &models.UpdateLineItemRequest{})
+
+		assert.Nil(t, response)
+		var validationErr *errs.Error
+		assert.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, errs.InvalidArgument, validationErr.Code)
+	})
+
+	t.Run("when_request_is_valid", func(t *testing.T) {
+		quantity := decimal.NewFromInt(3)
+		req := &models.UpdateLineItemRequest{Quantity: &quantity}
+
+		t.Run("should_return_updated_bill", func(t *testing.T) {
+			mockSvc := mocks.NewMockService(gomock.NewController(t))
+			handler := newTestHandler(mockSvc)
+			returnedBill := &models.Bill{ID: billID, Status: models.BillStatusOpen}
+			mockSvc.EXPECT().
+				UpdateLineItem(gomock.Any(), billID, lineItemID, models.LineItemUpdate{Quantity: &quantity}).
+				Return(returnedBill, nil)
+
+			res, err := handler.UpdateLineItem(context.TODO(), billID, lineItemID, req)
+
+			assert.NoError(t, err)
+			assert.Equal(t, &models.BillResponse{Data: returnedBill}, res)
+		})
+
+		t.Run("when_service_returns_error", func(t *testing.T) {
+			mockSvc := mocks.NewMockService(gomock.NewController(t))
+			handler := newTestHandler(mockSvc)
+			mockSvc.EXPECT().UpdateLineItem(gomock.Any(), billID, lineItemID, gomock.Any()).Return(nil, models.ErrBillClosed)
+
+			res, err := handler.UpdateLineItem(context.TODO(), billID, lineItemID, req)
+
+			assert.Equal(t, models.ErrBillClosed, err)
+			assert.Nil(t, res)
+		})
+	})
+}
+
+func TestRemoveLineItem(t *testing.T) {
+	billID := uuid.Must(uuid.NewV4())
+	lineItemID := uuid.Must(uuid.NewV4())
+
+	t.Run("when_reason_is_missing_should_return_error", func(t *testing.T) {
+		handler := newTestHandler(nil)
+		response, err := handler.RemoveLineItem(context.TODO(), billID, lineItemID, &models.RemoveLineItemParams{})
+
+		assert.Nil(t, response)
+		var validationErr *errs.Error
+		assert.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, errs.InvalidArgument, validationErr.Code)
+	})
+
+	t.Run("when_request_is_valid", func(t *testing.T) {
+		t.Run("should_return_updated_bill", func(t *testing.T) {
+			mockSvc := mocks.NewMockService(gomock.NewController(t))
+			handler := newTestHandler(mockSvc)
+			returnedBill := &models.Bill{ID: billID, Status: models.BillStatusOpen}
+			mockSvc.EXPECT().RemoveLineItem(gomock.Any(), billID, lineItemID, "duplicate charge").Return(returnedBill, nil)
+
+			res, err := handler.RemoveLineItem(context.TODO(), billID, lineItemID, &models.RemoveLineItemParams{Reason: "duplicate charge"})
+
+			assert.NoError(t, err)
+			assert.Equal(t, &models.BillResponse{Data: returnedBill}, res)
+		})
+	})
+}
+
 func TestCloseBill(t *testing.T) {
 	t.Run("when_bill_id_is_valid", func(t *testing.T) {
 		billID := uuid.Must(uuid.NewV4())
 
 		t.Run("should_close_bill", func(t *testing.T) {
 			mockSvc := mocks.NewMockService(gomock.NewController(t))
-			handler := &Handler{service: mockSvc}
+			handler := newTestHandler(mockSvc)
 
 			defer handler.CloseBill(context.TODO(), billID)
 
@@ -183,7 +265,7 @@ func TestCloseBill(t *testing.T) {
 		t.Run("when_service_returns_success", func(t *testing.T) {
 			t.Run("should_return_closed_bill", func(t *testing.T) {
 				mockSvc := mocks.NewMockService(gomock.NewController(t))
-				handler := &Handler{service: mockSvc}
+				handler := newTestHandler(mockSvc)
 				closedAt := time.Now()
 				returnedBill := &models.Bill{
 					ID:        billID,
@@ -205,7 +287,7 @@ func TestCloseBill(t *testing.T) {
 
 		t.Run("when_service_returns_error", func(t *testing.T) {
 			mockSvc := mocks.NewMockService(gomock.NewController(t))
-			handler := &Handler{service: mockSvc}
+			handler := newTestHandler(mockSvc)
 			mockSvc.EXPECT().CloseBill(gomock.Any(), billID).Return(nil, errors.New("some error"))
 
 			res, err := handler.CloseBill(context.TODO(), billID)
@@ -216,23 +298,234 @@ func TestCloseBill(t *testing.T) {
 	})
 }
 
+func TestVoidBill(t *testing.T) {
+	billID := uuid.Must(uuid.NewV4())
+
+	t.Run("when_reason_is_missing_should_return_error", func(t *testing.T) {
+		handler := newTestHandler(nil)
+		response, err := handler.VoidBill(context.TODO(), billID, &models.VoidBillRequest{Reason: "  "})
+
+		assert.Nil(t, response)
+		var validationErr *errs.Error
+		assert.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, errs.InvalidArgument, validationErr.Code)
+	})
+
+	t.Run("when_bill_is_finalized_should_return_service_error", func(t *testing.T) {
+		mockSvc := mocks.NewMockService(gomock.NewController(t))
+		handler := newTestHandler(mockSvc)
+		mockSvc.EXPECT().VoidBill(gomock.Any(), billID, "duplicate").Return(nil, models.ErrInvalidBillTransition)
+
+		res, err := handler.VoidBill(context.TODO(), billID, &models.VoidBillRequest{Reason: "duplicate"})
+
+		assert.Equal(t, models.ErrInvalidBillTransition, err)
+		assert.Nil(t, res)
+	})
+
+	t.Run("should_return_voided_bill", func(t *testing.T) {
+		mockSvc := mocks.NewMockService(gomock.NewController(t))
+		handler := newTestHandler(mockSvc)
+		returnedBill := &models.Bill{ID: billID, Status: models.BillStatusVoided, VoidReason: "duplicate"}
+		mockSvc.EXPECT().VoidBill(gomock.Any(), billID, "duplicate").Return(returnedBill, nil)
+
+		res, err := handler.VoidBill(context.TODO(), billID, &models.VoidBillRequest{Reason: "duplicate"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, &models.GetBillResponse{Data: returnedBill}, res)
+	})
+}
+
+func TestReopenBill(t *testing.T) {
+	billID := uuid.Must(uuid.NewV4())
+
+	t.Run("should_return_reopened_bill", func(t *testing.T) {
+		mockSvc := mocks.NewMockService(gomock.NewController(t))
+		handler := newTestHandler(mockSvc)
+		returnedBill := &models.Bill{ID: billID, Status: models.BillStatusOpen}
+		mockSvc.EXPECT().ReopenBill(gomock.Any(), billID).Return(returnedBill, nil)
+
+		res, err := handler.ReopenBill(context.TODO(), billID)
+
+		assert.NoError(t, err)
+		assert.Equal(t, &models.GetBillResponse{Data: returnedBill}, res)
+	})
+}
+
+func TestListBillWorkflows(t *testing.T) {
+	t.Run("when_page_token_is_invalid_should_return_error", func(t *testing.T) {
+		handler := newTestHandler(nil)
+
+		res, err := handler.ListBillWorkflows(context.TODO(), &models.ListBillWorkflowsParams{PageToken: "!!"})
+
+		assert.Nil(t, res)
+		var validationErr *errs.Error
+		assert.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, errs.InvalidArgument, validationErr.Code)
+	})
+
+	t.Run("should_return_bills_with_next_page_token", func(t *testing.T) {
+		mockSvc := mocks.NewMockService(gomock.NewController(t))
+		handler := newTestHandler(mockSvc)
+		bills := []*models.BillWorkflowSummary{{BillID: uuid.Must(uuid.NewV4()), CustomerID: "cust-1"}}
+		mockSvc.EXPECT().
+			ListBillWorkflows(gomock.Any(), models.BillWorkflowFilter{CustomerID: "cust-1", Limit: 5, PageToken: []byte("page-2")}).
+			Return(bills, []byte("page-3"), nil)
+
+		res, err := handler.ListBillWorkflows(context.TODO(), &models.ListBillWorkflowsParams{
+			CustomerID: "cust-1",
+			Limit:      5,
+			PageToken:  base64.RawURLEncoding.EncodeToString([]byte("page-2")),
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, bills, res.Data)
+		assert.Equal(t, base64.RawURLEncoding.EncodeToString([]byte("page-3")), res.NextPageToken)
+	})
+}
+
+func TestReconciliationReports(t *testing.T) {
+	t.Run("should_start_reconciliation", func(t *testing.T) {
+		mockSvc := mocks.NewMockService(gomock.NewController(t))
+		handler := newTestHandler(mockSvc)
+		mockSvc.EXPECT().StartReconciliation(gomock.Any(), true).Return("bill-reconciliation-1", nil)
+
+		res, err := handler.StartReconciliation(context.TODO(), &models.StartReconciliationRequest{Repair: true})
+
+		assert.NoError(t, err)
+		assert.Equal(t, &models.StartReconciliationResponse{WorkflowID: "bill-reconciliation-1"}, res)
+	})
+
+	t.Run("when_limit_is_out_of_range_should_return_error", func(t *testing.T) {
+		handler := newTestHandler(nil)
+
+		res, err := handler.ListReconciliationReports(context.TODO(), &models.ListReconciliationReportsParams{Limit: -1})
+
+		assert.Nil(t, res)
+		var validationErr *errs.Error
+		assert.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, errs.InvalidArgument, validationErr.Code)
+	})
+
+	t.Run("should_list_reports", func(t *testing.T) {
+		mockSvc := mocks.NewMockService(gomock.NewController(t))
+		handler := newTestHandler(mockSvc)
+		reports := []*models.ReconciliationReport{{ID: uuid.Must(uuid.NewV4())}}
+		mockSvc.EXPECT().ListReconciliationReports(gomock.Any(), 5).Return(reports, nil)
+
+		res, err := handler.ListReconciliationReports(context.TODO(), &models.ListReconciliationReportsParams{Limit: 5})
+
+		assert.NoError(t, err)
+		assert.Equal(t, &models.ListReconciliationReportsResponse{Data: reports}, res)
+	})
+
+	t.Run("when_report_does_not_exist_should_return_not_found", func(t *testing.T) {
+		mockSvc := mocks.NewMockService(gomock.NewController(t))
+		handler := newTestHandler(mockSvc)
+		reportID := uuid.Must(uuid.NewV4())
+		mockSvc.EXPECT().GetReconciliationReport(gomock.Any(), reportID).Return(nil, models.ErrReconciliationReportNotFound)
+
+		res, err := handler.GetReconciliationReport(context.TODO(), reportID)
+
+		assert.Nil(t, res)
+		assert.Equal(t, models.ErrReconciliationReportNotFound, err)
+	})
+}
+
+func TestFailedOperations(t *testing.T) {
+	t.Run("when_status_is_invalid_should_return_error", func(t *testing.T) {
+		handler := newTestHandler(nil)
+
+		res, err := handler.ListFailedOperations(context.TODO(), &models.ListFailedOperationsParams{Status: "failed"})
+
+		assert.Nil(t, res)
+		var validationErr *errs.Error
+		assert.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, errs.InvalidArgument, validationErr.Code)
+	})
+
+	t.Run("should_list_failed_operations", func(t *testing.T) {
+		mockSvc := mocks.NewMockService(gomock.NewController(t))
+		handler := newTestHandler(mockSvc)
+		billID := uuid.Must(uuid.NewV4())
+		ops := []*models.FailedOperation{{ID: uuid.Must(uuid.NewV4()), BillID: billID}}
+		mockSvc.EXPECT().ListFailedOperations(gomock.Any(), models.FailedOperationFilter{
+			Status: models.FailedOperationStatusPending,
+			BillID: &billID,
+			Limit:  5,
+		}).Return(ops, nil)
+
+		res, err := handler.ListFailedOperations(context.TODO(), &models.ListFailedOperationsParams{
+			Status: "pending",
+			BillID: billID.String(),
+			Limit:  5,
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, &models.ListFailedOperationsResponse{Data: ops}, res)
+	})
+
+	t.Run("when_failed_operation_is_not_pending_should_return_retry_error", func(t *testing.T) {
+		mockSvc := mocks.NewMockService(gomock.NewController(t))
+		handler := newTestHandler(mockSvc)
+		opID := uuid.Must(uuid.NewV4())
+		mockSvc.EXPECT().RetryFailedOperation(gomock.Any(), opID).Return(nil, models.ErrFailedOperationNotPending)
+
+		res, err := handler.RetryFailedOperation(context.TODO(), opID)
+
+		assert.Nil(t, res)
+		assert.Equal(t, models.ErrFailedOperationNotPending, err)
+	})
+
+	t.Run("when_note_is_missing_should_not_acknowledge", func(t *testing.T) {
+		handler := newTestHandler(nil)
+
+		res, err := handler.AcknowledgeFailedOperation(context.TODO(), uuid.Must(uuid.NewV4()), &models.AcknowledgeFailedOperationRequest{})
+
+		assert.Nil(t, res)
+		var validationErr *errs.Error
+		assert.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, errs.InvalidArgument, validationErr.Code)
+	})
+
+	t.Run("should_acknowledge_failed_operation", func(t *testing.T) {
+		mockSvc := mocks.NewMockService(gomock.NewController(t))
+		handler := newTestHandler(mockSvc)
+		op := &models.FailedOperation{ID: uuid.Must(uuid.NewV4()), Status: models.FailedOperationStatusAcknowledged}
+		mockSvc.EXPECT().AcknowledgeFailedOperation(gomock.Any(), op.ID, "closed manually").Return(op, nil)
+
+		res, err := handler.AcknowledgeFailedOperation(context.TODO(), op.ID, &models.AcknowledgeFailedOperationRequest{Note: "closed manually"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, &models.FailedOperationResponse{Data: op}, res)
+	})
+}
+
 func TestGetBill(t *testing.T) {
 	t.Run("when_bill_id_is_valid", func(t *testing.T) {
 		billID := uuid.Must(uuid.NewV4())
 
 		t.Run("should_get_bill", func(t *testing.T) {
 			mockSvc := mocks.NewMockService(gomock.NewController(t))
-			handler := &Handler{service: mockSvc}
+			handler := newTestHandler(mockSvc)
 
-			defer handler.GetBill(context.TODO(), billID)
+			defer handler.GetBill(context.TODO(), billID, &models.GetBillParams{})
 
-			mockSvc.EXPECT().GetBillByID(gomock.Any(), billID)
+			mockSvc.EXPECT().GetBillByID(gomock.Any(), billID, models.GetBillOptions{})
+		})
+
+		t.Run("should_get_bill_with_line_items_when_included", func(t *testing.T) {
+			mockSvc := mocks.NewMockService(gomock.NewController(t))
+			handler := newTestHandler(mockSvc)
+
+			defer handler.GetBill(context.TODO(), billID, &models.GetBillParams{Include: "line_items"})
+
+			mockSvc.EXPECT().GetBillByID(gomock.Any(), billID, models.GetBillOptions{IncludeLineItems: true})
 		})
 
 		t.Run("when_service_returns_success", func(t *testing.T) {
 			t.Run("should_return_bill", func(t *testing.T) {
 				mockSvc := mocks.NewMockService(gomock.NewController(t))
-				handler := &Handler{service: mockSvc}
+				handler := newTestHandler(mockSvc)
 				returnedBill := &models.Bill{
 					ID:        billID,
 					Status:    models.BillStatusOpen,
@@ -254,9 +547,9 @@ func TestGetBill(t *testing.T) {
 						},
 					},
 				}
-				mockSvc.EXPECT().GetBillByID(gomock.Any(), billID).Return(returnedBill, nil)
+				mockSvc.EXPECT().GetBillByID(gomock.Any(), billID, models.GetBillOptions{IncludeLineItems: true}).Return(returnedBill, nil)
 
-				res, err := handler.GetBill(context.TODO(), billID)
+				res, err := handler.GetBill(context.TODO(), billID, &models.GetBillParams{Include: "line_items"})
 
 				assert.Nil(t, err)
 				assert.Equal(t, &models.GetBillResponse{
@@ -267,15 +560,394 @@ func TestGetBill(t *testing.T) {
 
 		t.Run("when_service_returns_error", func(t *testing.T) {
 			mockSvc := mocks.NewMockService(gomock.NewController(t))
-			handler := &Handler{service: mockSvc}
-			mockSvc.EXPECT().GetBillByID(gomock.Any(), billID).Return(nil, errors.New("some error"))
+			handler := newTestHandler(mockSvc)
+			mockSvc.EXPECT().GetBillByID(gomock.Any(), billID, models.GetBillOptions{}).Return(nil, errors.New("some error"))
 
-			res, err := handler.GetBill(context.TODO(), billID)
+			res, err := handler.GetBill(context.TODO(), billID, &models.GetBillParams{})
 
 			assert.Error(t, err)
 			assert.Nil(t, res)
 		})
 	})
+
+	t.Run("when_include_is_unsupported", func(t *testing.T) {
+		t.Run("should_return_error", func(t *testing.T) {
+			handler := newTestHandler(nil)
+
+			res, err := handler.GetBill(context.TODO(), uuid.Must(uuid.NewV4()), &models.GetBillParams{Include: "payments"})
+
+			assert.Error(t, err)
+			assert.Nil(t, res)
+		})
+	})
+}
+
+func TestGetBillHistory(t *testing.T) {
+	t.Run("should_return_bill_history", func(t *testing.T) {
+		mockSvc := mocks.NewMockService(gomock.NewController(t))
+		handler := newTestHandler(mockSvc)
+		billID := uuid.Must(uuid.NewV4())
+		history := &models.BillHistory{BillID: billID, Events: []*models.AuditEvent{}, Verified: true}
+		mockSvc.EXPECT().GetBillHistory(gomock.Any(), billID).Return(history, nil)
+
+		res, err := handler.GetBillHistory(context.TODO(), billID)
+
+		assert.NoError(t, err)
+		assert.Equal(t, &models.BillHistoryResponse{Data: history}, res)
+	})
+
+	t.Run("when_bill_does_not_exist_should_return_not_found", func(t *testing.T) {
+		mockSvc := mocks.NewMockService(gomock.NewController(t))
+		handler := newTestHandler(mockSvc)
+		billID := uuid.Must(uuid.NewV4())
+		mockSvc.EXPECT().GetBillHistory(gomock.Any(), billID).Return(nil, models.ErrBillNotFound)
+
+		res, err := handler.GetBillHistory(context.TODO(), billID)
+
+		assert.Nil(t, res)
+		assert.Equal(t, models.ErrBillNotFound, err)
+	})
+}
+
+func TestRecordPayment(t *testing.T) {
+	t.Run("when_request_is_invalid_should_return_error", func(t *testing.T) {
+		handler := newTestHandler(nil)
+
+		res, err := handler.RecordPayment(context.TODO(), uuid.Must(uuid.NewV4()), &models.RecordPaymentRequest{
+			Amount: decimal.NewFromInt(-1), Currency: models.USD, Reference: "TRF-1",
+		})
+
+		assert.Nil(t, res)
+		var validationErr *errs.Error
+		assert.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, errs.InvalidArgument, validationErr.Code)
+	})
+
+	t.Run("should_return_posted_journals", func(t *testing.T) {
+		mockSvc := mocks.NewMockService(gomock.NewController(t))
+		handler := newTestHandler(mockSvc)
+		billID := uuid.Must(uuid.NewV4())
+		req := &models.RecordPaymentRequest{Amount: decimal.NewFromInt(10), Currency: models.USD, Reference: "TRF-1"}
+		journals := []*models.Journal{{BillID: billID, Type: models.JournalPayment, Reference: "TRF-1"}}
+		mockSvc.EXPECT().RecordPayment(gomock.Any(), billID, req).Return(journals, nil)
+
+		res, err := handler.RecordPayment(context.TODO(), billID, req)
+
+		assert.NoError(t, err)
+		assert.Equal(t, &models.JournalsResponse{Data: journals}, res)
+	})
+}
+
+func TestGetTrialBalance(t *testing.T) {
+	t.Run("when_as_of_is_invalid_should_return_error", func(t *testing.T) {
+		handler := newTestHandler(nil)
+
+		res, err := handler.GetTrialBalance(context.TODO(), &models.GetTrialBalanceParams{AsOf: "yesterday"})
+
+		assert.Nil(t, res)
+		var validationErr *errs.Error
+		assert.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, errs.InvalidArgument, validationErr.Code)
+	})
+
+	t.Run("should_return_trial_balance_as_of_given_time", func(t *testing.T) {
+		mockSvc := mocks.NewMockService(gomock.NewController(t))
+		handler := newTestHandler(mockSvc)
+		asOf := time.Date(2025, 3, 31, 23, 59, 59, 0, time.UTC)
+		balance := models.NewTrialBalance(asOf, models.USD, []models.TrialBalanceLine{}, nil)
+		mockSvc.EXPECT().GetTrialBalance(gomock.Any(), asOf).Return(balance, nil)
+
+		res, err := handler.GetTrialBalance(context.TODO(), &models.GetTrialBalanceParams{AsOf: "2025-03-31T23:59:59Z"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, &models.TrialBalanceResponse{Data: balance}, res)
+	})
+}
+
+func TestGetAgingReport(t *testing.T) {
+	t.Run("when_reporting_currency_is_unsupported_should_return_error", func(t *testing.T) {
+		handler := newTestHandler(nil)
+
+		res, err := handler.GetAgingReport(context.TODO(), &models.GetAgingReportParams{ReportingCurrency: "XYZ"})
+
+		assert.Nil(t, res)
+		var validationErr *errs.Error
+		assert.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, models.ErrInvalidCurrency.Message, validationErr.Message)
+	})
+
+	t.Run("should_return_aging_report_as_of_given_time", func(t *testing.T) {
+		mockSvc := mocks.NewMockService(gomock.NewController(t))
+		handler := newTestHandler(mockSvc)
+		filter := models.AgingReportFilter{
+			AsOf:              time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC),
+			CustomerID:        "customer-123",
+			ReportingCurrency: models.GEL,
+		}
+		report := &models.AgingReport{AsOf: filter.AsOf, ReportingCurrency: models.GEL, Rows: []models.AgingRow{}}
+		mockSvc.EXPECT().GetAgingReport(gomock.Any(), filter).Return(report, nil)
+
+		res, err := handler.GetAgingReport(context.TODO(), &models.GetAgingReportParams{
+			AsOf: "2025-03-31T00:00:00Z", CustomerID: "customer-123", ReportingCurrency: "GEL",
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, &models.AgingReportResponse{Data: report}, res)
+	})
+}
+
+func TestGetCustomerStatement(t *testing.T) {
+	t.Run("when_from_is_missing_should_return_error", func(t *testing.T) {
+		handler := newTestHandler(nil)
+
+		res, err := handler.GetCustomerStatement(context.TODO(), "customer-123", &models.GetCustomerStatementParams{})
+
+		assert.Nil(t, res)
+		var validationErr *errs.Error
+		assert.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, errs.InvalidArgument, validationErr.Code)
+	})
+
+	t.Run("when_to_is_not_after_from_should_return_error", func(t *testing.T) {
+		handler := newTestHandler(nil)
+
+		res, err := handler.GetCustomerStatement(context.TODO(), "customer-123", &models.GetCustomerStatementParams{
+			From: "2025-03-31T00:00:00Z", To: "2025-03-01T00:00:00Z",
+		})
+
+		assert.Nil(t, res)
+		var validationErr *errs.Error
+		assert.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, "to must be after from", validationErr.Message)
+	})
+
+	t.Run("should_return_statement_of_customer_for_period", func(t *testing.T) {
+		mockSvc := mocks.NewMockService(gomock.NewController(t))
+		handler := newTestHandler(mockSvc)
+		filter := models.CustomerStatementFilter{
+			From: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+			To:   time.Date(2025, 3, 31, 23, 59, 59, 0, time.UTC),
+		}
+		statement := &models.CustomerStatement{CustomerID: "customer-123", From: filter.From, To: filter.To}
+		mockSvc.EXPECT().GetCustomerStatement(gomock.Any(), "customer-123", filter).Return(statement, nil)
+
+		res, err := handler.GetCustomerStatement(context.TODO(), "customer-123", &models.GetCustomerStatementParams{
+			From: "2025-03-01T00:00:00Z", To: "2025-03-31T23:59:59Z",
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, &models.CustomerStatementResponse{Data: statement}, res)
+	})
+}
+
+func TestGetRevenueReport(t *testing.T) {
+	t.Run("when_granularity_is_unknown_should_return_error", func(t *testing.T) {
+		handler := newTestHandler(nil)
+
+		res, err := handler.GetRevenueReport(context.TODO(), &models.GetRevenueReportParams{Granularity: "week"})
+
+		assert.Nil(t, res)
+		var validationErr *errs.Error
+		assert.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, errs.InvalidArgument, validationErr.Code)
+	})
+
+	t.Run("when_to_is_before_from_should_return_error", func(t *testing.T) {
+		handler := newTestHandler(nil)
+
+		res, err := handler.GetRevenueReport(context.TODO(), &models.GetRevenueReportParams{From: "2025-06", To: "2025-01"})
+
+		assert.Nil(t, res)
+		var validationErr *errs.Error
+		assert.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, "to cannot be before from", validationErr.Message)
+	})
+
+	t.Run("should_return_revenue_report_of_whole_periods", func(t *testing.T) {
+		mockSvc := mocks.NewMockService(gomock.NewController(t))
+		handler := newTestHandler(mockSvc)
+		filter := models.RevenueReportFilter{
+			From:        time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+			To:          time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC),
+			Granularity: models.GranularityQuarter,
+		}
+		report := &models.RevenueReport{From: filter.From, To: filter.To, Granularity: filter.Granularity}
+		mockSvc.EXPECT().GetRevenueReport(gomock.Any(), filter).Return(report, nil)
+
+		res, err := handler.GetRevenueReport(context.TODO(), &models.GetRevenueReportParams{
+			From: "2025-02", To: "2025-05", Granularity: "quarter",
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, &models.RevenueReportResponse{Data: report}, res)
+	})
+}
+
+func TestGetMRRReport(t *testing.T) {
+	t.Run("when_month_is_invalid_should_return_error", func(t *testing.T) {
+		handler := newTestHandler(nil)
+
+		res, err := handler.GetMRRReport(context.TODO(), &models.GetMRRReportParams{From: "2025-13"})
+
+		assert.Nil(t, res)
+		var validationErr *errs.Error
+		assert.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, "from must be a month formatted YYYY-MM", validationErr.Message)
+	})
+
+	t.Run("should_return_mrr_report_until_month_after_to", func(t *testing.T) {
+		mockSvc := mocks.NewMockService(gomock.NewController(t))
+		handler := newTestHandler(mockSvc)
+		filter := models.MRRReportFilter{
+			From:              time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+			To:                time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC),
+			ReportingCurrency: models.GEL,
+		}
+		report := &models.MRRReport{From: filter.From, To: filter.To, ReportingCurrency: models.GEL}
+		mockSvc.EXPECT().GetMRRReport(gomock.Any(), filter).Return(report, nil)
+
+		res, err := handler.GetMRRReport(context.TODO(), &models.GetMRRReportParams{
+			From: "2025-01", To: "2025-03", ReportingCurrency: "GEL",
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, &models.MRRReportResponse{Data: report}, res)
+	})
+}
+
+func TestGetBillRecognitionSchedules(t *testing.T) {
+	t.Run("should_return_recognition_schedules_of_bill", func(t *testing.T) {
+		mockSvc := mocks.NewMockService(gomock.NewController(t))
+		handler := newTestHandler(mockSvc)
+		billID := uuid.Must(uuid.NewV4())
+		schedules := []*models.RecognitionSchedule{{BillID: billID, Method: models.RecognitionDaily}}
+		mockSvc.EXPECT().GetBillRecognitionSchedules(gomock.Any(), billID).Return(schedules, nil)
+
+		res, err := handler.GetBillRecognitionSchedules(context.TODO(), billID)
+
+		assert.NoError(t, err)
+		assert.Equal(t, &models.RecognitionSchedulesResponse{Data: schedules}, res)
+	})
+
+	t.Run("when_service_fails_should_return_error", func(t *testing.T) {
+		mockSvc := mocks.NewMockService(gomock.NewController(t))
+		handler := newTestHandler(mockSvc)
+		billID := uuid.Must(uuid.NewV4())
+		mockSvc.EXPECT().GetBillRecognitionSchedules(gomock.Any(), billID).Return(nil, models.ErrBillNotFound)
+
+		res, err := handler.GetBillRecognitionSchedules(context.TODO(), billID)
+
+		assert.Nil(t, res)
+		assert.ErrorIs(t, err, models.ErrBillNotFound)
+	})
+}
+
+func TestGetDeferredRevenueReport(t *testing.T) {
+	t.Run("when_month_is_invalid_should_return_error", func(t *testing.T) {
+		handler := newTestHandler(nil)
+
+		res, err := handler.GetDeferredRevenueReport(context.TODO(), &models.GetDeferredRevenueReportParams{To: "March"})
+
+		assert.Nil(t, res)
+		var validationErr *errs.Error
+		assert.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, "to must be a month formatted YYYY-MM", validationErr.Message)
+	})
+
+	t.Run("should_return_waterfall_until_month_after_to", func(t *testing.T) {
+		mockSvc := mocks.NewMockService(gomock.NewController(t))
+		handler := newTestHandler(mockSvc)
+		filter := models.DeferredRevenueFilter{
+			From: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+			To:   time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC),
+		}
+		waterfall := &models.DeferredRevenueWaterfall{From: filter.From, To: filter.To}
+		mockSvc.EXPECT().GetDeferredRevenueReport(gomock.Any(), filter).Return(waterfall, nil)
+
+		res, err := handler.GetDeferredRevenueReport(context.TODO(), &models.GetDeferredRevenueReportParams{
+			From: "2025-01", To: "2025-06",
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, &models.DeferredRevenueReportResponse{Data: waterfall}, res)
+	})
+}
+
+func TestCreateExport(t *testing.T) {
+	t.Run("when_request_is_invalid_should_return_error", func(t *testing.T) {
+		handler := newTestHandler(nil)
+
+		res, err := handler.CreateExport(context.TODO(), &models.CreateExportRequest{Format: "xlsx"})
+
+		assert.Nil(t, res)
+		var validationErr *errs.Error
+		assert.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, errs.InvalidArgument, validationErr.Code)
+	})
+
+	t.Run("should_return_pending_export", func(t *testing.T) {
+		mockSvc := mocks.NewMockService(gomock.NewController(t))
+		handler := newTestHandler(mockSvc)
+		to := time.Now().Truncate(24 * time.Hour)
+		req := &models.CreateExportRequest{Format: models.ExportGeneralLedgerCSV, From: to.AddDate(0, -1, 0), To: to}
+		job, err := models.NewExportJob(req.Format, req.From, req.To, time.Now())
+		assert.NoError(t, err)
+		mockSvc.EXPECT().CreateExport(gomock.Any(), req).Return(job, nil)
+
+		res, err := handler.CreateExport(context.TODO(), req)
+
+		assert.NoError(t, err)
+		assert.Equal(t, &models.ExportJobResponse{Data: job}, res)
+	})
+}
+
+func TestGetExport(t *testing.T) {
+	t.Run("when_export_is_not_found_should_return_error", func(t *testing.T) {
+		mockSvc := mocks.NewMockService(gomock.NewController(t))
+		handler := newTestHandler(mockSvc)
+		exportID := uuid.Must(uuid.NewV4())
+		mockSvc.EXPECT().GetExport(gomock.Any(), exportID).Return(nil, models.ErrExportNotFound)
+
+		res, err := handler.GetExport(context.TODO(), exportID)
+
+		assert.Nil(t, res)
+		assert.Equal(t, models.ErrExportNotFound, err)
+	})
+}
+
+func TestListLineItems(t *testing.T) {
+	billID := uuid.Must(uuid.NewV4())
+
+	t.Run("when_there_is_a_next_page", func(t *testing.T) {
+		t.Run("should_return_next_cursor", func(t *testing.T) {
+			mockSvc := mocks.NewMockService(gomock.NewController(t))
+			handler := newTestHandler(mockSvc)
+			item := &models.LineItem{ID: uuid.Must(uuid.NewV4()), BillID: billID, CreatedAt: time.Now()}
+			next := models.NewLineItemCursor(item)
+			mockSvc.EXPECT().
+				ListLineItems(gomock.Any(), billID, models.LineItemFilter{Currency: models.USD, Limit: 1}).
+				Return([]*models.LineItem{item}, next, nil)
+
+			res, err := handler.ListLineItems(context.TODO(), billID, &models.ListLineItemsParams{Currency: "USD", Limit: 1})
+
+			assert.NoError(t, err)
+			assert.Equal(t, []*models.LineItem{item}, res.Data)
+			assert.Equal(t, next.Encode(), res.NextCursor)
+		})
+	})
+
+	t.Run("when_cursor_is_invalid", func(t *testing.T) {
+		t.Run("should_return_error", func(t *testing.T) {
+			handler := newTestHandler(nil)
+
+			res, err := handler.ListLineItems(context.TODO(), billID, &models.ListLineItemsParams{Cursor: "not-a-cursor"})
+
+			var validationErr *errs.Error
+			assert.ErrorAs(t, err, &validationErr)
+			assert.Equal(t, models.ErrInvalidCursor.Message, validationErr.Message)
+			assert.Nil(t, res)
+		})
+	})
 }
 
 func TestValidation_InvalidPeriod(t *testing.T) {
@@ -284,7 +956,7 @@ func TestValidation_InvalidPeriod(t *testing.T) {
 		PeriodStart: time.Now().AddDate(0, 1, 0), // Start in the future
 		PeriodEnd:   time.Now(),                  // End in the past (invalid)
 	}
-	handler := &Handler{}
+	handler := newTestHandler(nil)
 	response, err := handler.CreateBill(context.TODO(), req)
 
 	assert.Error(t, err)
@@ -304,7 +976,7 @@ func TestValidation_InvalidCurrency(t *testing.T) {
 		Quantity:    decimal.NewFromFloat(1.0),
 		UnitPrice:   decimal.NewFromFloat(10.00),
 	}
-	handler := &Handler{}
+	handler := newTestHandler(nil)
 	response, err := handler.AddLineItem(context.TODO(), billID, req)
 
 	assert.Error(t, err)
@@ -324,7 +996,7 @@ func TestValidation_InvalidQuantity(t *testing.T) {
 		Quantity:    decimal.NewFromFloat(-1.0), // Invalid: negative quantity
 		UnitPrice:   decimal.NewFromFloat(10.00),
 	}
-	handler := &Handler{}
+	handler := newTestHandler(nil)
 	response, err := handler.AddLineItem(context.TODO(), billID, req)
 
 	assert.Error(t, err)
@@ -344,7 +1016,7 @@ func TestValidation_InvalidUnitPrice(t *testing.T) {
 		Quantity:    decimal.NewFromFloat(1.0),
 		UnitPrice:   decimal.NewFromFloat(-10.00), // Invalid: negative price
 	}
-	handler := &Handler{}
+	handler := newTestHandler(nil)
 	response, err := handler.AddLineItem(context.TODO(), billID, req)
 
 	assert.Error(t, err)
@@ -356,3 +1028,112 @@ func TestValidation_InvalidUnitPrice(t *testing.T) {
 	assert.Equal(t, errs.InvalidArgument, validationErr.Code)
 	assert.Contains(t, validationErr.Message, "unit_price cannot be negative")
 }
+
+func TestValidation_OccurredAtInFuture(t *testing.T) {
+	billID := uuid.Must(uuid.NewV4())
+	occurredAt := time.Now().Add(time.Hour)
+	req := &models.AddLineItemRequest{
+		Description: "Test service",
+		Currency:    models.USD,
+		Quantity:    decimal.NewFromFloat(1.0),
+		UnitPrice:   decimal.NewFromFloat(10.00),
+		OccurredAt:  &occurredAt, // Invalid: usage cannot occur in the future
+	}
+	handler := newTestHandler(nil)
+	response, err := handler.AddLineItem(context.TODO(), billID, req)
+
+	assert.Error(t, err)
+	assert.Nil(t, response)
+
+	// Verify validation error
+	var validationErr *errs.Error
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, errs.InvalidArgument, validationErr.Code)
+	assert.Contains(t, validationErr.Message, "occurred_at cannot be in the future")
+}
+
+func TestValidation_UnitPriceExceedsCurrencyPrecision(t *testing.T) {
+	billID := uuid.Must(uuid.NewV4())
+	req := &models.AddLineItemRequest{
+		Description: "Test service",
+		Currency:    models.USD,
+		Quantity:    decimal.NewFromFloat(1.0),
+		UnitPrice:   decimal.RequireFromString("10.005"), // Invalid: USD has 2 minor units
+	}
+	handler := newTestHandler(nil)
+	response, err := handler.AddLineItem(context.TODO(), billID, req)
+
+	assert.Error(t, err)
+	assert.Nil(t, response)
+
+	// Verify validation error
+	var validationErr *errs.Error
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, errs.InvalidArgument, validationErr.Code)
+	assert.Contains(t, validationErr.Message, "unit_price cannot have more than 2 decimal places for USD")
+}
+
+func TestListCurrencies(t *testing.T) {
+	t.Run("should_return_enabled_currencies", func(t *testing.T) {
+		currencies := []models.CurrencyInfo{
+			{Code: models.USD, NumericCode: "840", Fraction: 2, Grapheme: "$"},
+			{Code: models.JPY, NumericCode: "392", Fraction: 0, Grapheme: "¥"},
+		}
+		handler := &Handler{currencies: currencies}
+
+		res, err := handler.ListCurrencies(context.TODO())
+
+		assert.NoError(t, err)
+		assert.Equal(t, &models.ListCurrenciesResponse{Data: currencies}, res)
+	})
+}
+
+func TestUpsertCustomerProfile(t *testing.T) {
+	t.Run("when_currency_is_not_enabled_should_return_error", func(t *testing.T) {
+		handler := newTestHandler(nil)
+		req := &models.UpsertCustomerProfileRequest{PresentmentCurrency: "EUR"}
+
+		res, err := handler.UpsertCustomerProfile(context.TODO(), "customer-123", req)
+
+		assert.Nil(t, res)
+		var validationErr *errs.Error
+		assert.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, errs.InvalidArgument, validationErr.Code)
+		assert.Equal(t, validation.Details{Violations: []validation.FieldViolation{
+			{Field: "presentment_currency", Message: models.ErrInvalidCurrency.Message},
+		}}, validationErr.Details)
+	})
+
+	t.Run("when_timezone_or_close_policy_is_invalid_should_return_error", func(t *testing.T) {
+		handler := newTestHandler(nil)
+		for _, req := range []*models.UpsertCustomerProfileRequest{
+			{PresentmentCurrency: models.GEL, Timezone: "Mars/Olympus"},
+			{PresentmentCurrency: models.GEL, ClosePolicy: "weekly"},
+		} {
+			res, err := handler.UpsertCustomerProfile(context.TODO(), "customer-123", req)
+
+			assert.Nil(t, res)
+			var validationErr *errs.Error
+			assert.ErrorAs(t, err, &validationErr)
+			assert.Equal(t, errs.InvalidArgument, validationErr.Code)
+		}
+	})
+
+	t.Run("when_request_is_valid_should_return_profile", func(t *testing.T) {
+		mockSvc := mocks.NewMockService(gomock.NewController(t))
+		handler := newTestHandler(mockSvc)
+		req := &models.UpsertCustomerProfileRequest{PresentmentCurrency: models.GEL}
+		profile := &models.CustomerProfile{
+			CustomerID:          "customer-123",
+			PresentmentCurrency: models.GEL,
+			CreatedAt:           time.Now(),
+			UpdatedAt:           time.Now(),
+		}
+		mockSvc.EXPECT().UpsertCustomerProfile(gomock.Any(), "customer-123", req).Return(profile, nil)
+
+		res, err := handler.UpsertCustomerProfile(context.TODO(), "customer-123", req)
+
+		assert.NoError(t, err)
+		assert.Equal(t, &models.CustomerProfileResponse{Data: profile}, res)
+	})
+}
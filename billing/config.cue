@@ -1,11 +1,14 @@
 package billing
 
 Temporal: {
-	Address:                           string | *"eu-central-1.aws.api.temporal.io:7233"
-	Namespace:                         "quickstart-pave-billing.l5kfr"
-	TaskQueue:                         "pave-billing"
-	WorkflowExecutionTimeoutBuffer:    3600 // 1 hour
-	ActivityStartToCloseTimeout:       604800 // 1 week
+	Address:                        string | *"eu-central-1.aws.api.temporal.io:7233"
+	Namespace:                      "quickstart-pave-billing.l5kfr"
+	TaskQueue:                      "pave-billing"
+	ActivityStartToCloseTimeout:    30     // seconds per attempt
+	ActivityScheduleToCloseTimeout: 604800 // 1 week across all attempts
+	CloseBillActivityTimeout:       120    // seconds per attempt
+	ReconcileBatchActivityTimeout:  600    // seconds per attempt
+	ExportActivityTimeout:          1800   // seconds per attempt
 	ActivityRetryPolicy: {
 		InitialInterval:    5 // second
 		BackoffCoefficient: 2.0
@@ -31,10 +34,97 @@ Billing: {
 		MaxQuantity:          1000000
 		MaxUnitPrice:         1000000
 		MaxTotalAmount:       10000000
+		// Any ISO 4217 code known to go-money can be enabled, e.g. "JPY"
 		AllowedCurrencies: 		["USD", "GEL"]
+		MaxExportRangeDays:   366
 	}
 	Workflow: {
-		WorkflowIDPrefix: "bill-"
+		WorkflowIDPrefix:              "bill-"
+		ContinueAsNewSignalThreshold:  1000 // signals per workflow run
+		SearchAttributeTotalsInterval: 300  // 5 minutes
+		// Seconds bills stay closing after their close time for late usage, 0 closes them directly
+		LateUsageGraceWindow: 0
+	}
+	DefaultPresentmentCurrency: "USD"
+	Rounding: {
+		Mode:  "half_up" // "half_up", "half_even" or "truncate"
+		Level: "total"   // "line" or "total"
+	}
+	Pagination: {
+		DefaultLimit: 50
+		MaxLimit:     500
+	}
+	DraftReconciler: {
+		Interval:  300 // 5 minutes
+		MinAge:    60  // seconds
+		BatchSize: 100
+	}
+	Reconciliation: {
+		Interval:  3600 // 1 hour
+		Repair:    false
+		BatchSize: 100
+	}
+	Close: {
+		Policy:      "exact" // "exact", "end_of_day" or "grace"
+		Timezone:    "UTC"   // IANA timezone of customers without one
+		GracePeriod: 3600    // seconds, 1 hour
+	}
+	Ledger: {
+		FunctionalCurrency: "USD"
+		Accounts: {
+			AccountsReceivable: "1200"
+			Revenue:            "4000"
+			TaxPayable:         "2200"
+			Cash:               "1000"
+			FXGain:             "7100"
+			FXLoss:             "7200"
+		}
+	}
+	Export: {
+		BatchSize: 500
+	}
+	Invoice: {
+		Seller: {
+			Name:           "Pave Billing LLC"
+			RegistrationID: "405000000"
+			TaxID:          "GE405000000"
+			Street:         "1 Rustaveli Avenue"
+			City:           "Tbilisi"
+			PostalCode:     "0108"
+			CountryCode:    "GE"
+			EndpointID:     "4860000000006"
+			EndpointScheme: "0088"
+		}
+		// Bills carry no tax
+		TaxCategory:        "O"
+		TaxPercent:         0
+		TaxExemptionReason: "Not subject to VAT"
+		PaymentTermsDays:   30
+		PaymentMeansCode:   "30"
+		PayeeIBAN:          ""
+	}
+	BankStatements: {
+		AmountTolerancePercent: 2
+		MinReferencePrefix:     8
+		MaxStatementBytes:      10485760 // 10 MiB
+		CSV: {
+			Delimiter:            ","
+			DateLayout:           "2006-01-02"
+			DecimalSeparator:     "."
+			DateColumn:           "Booking Date"
+			AmountColumn:         "Amount"
+			CurrencyColumn:       "Currency"
+			ReferenceColumn:      "Reference"
+			CounterpartyColumn:   "Counterparty"
+			EntryReferenceColumn: "Transaction ID"
+		}
+	}
+	Analytics: {
+		RefreshWindowMonths: 3
+		MaxRangeMonths:      60 // 5 years
+	}
+	Recognition: {
+		Method: "daily" // "daily" or "monthly"
 	}
 }
 
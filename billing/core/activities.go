@@ -2,25 +2,35 @@ package core
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"time"
 
+	"encore.app/billing/ext_services"
 	"encore.app/billing/models"
 	"encore.app/billing/repository"
 	"encore.dev/rlog"
 	"encore.dev/types/uuid"
 )
 
-func NewBillingActivities(repository repository.Repository) *BillingActivities {
+func NewBillingActivities(
+	repository repository.Repository, conversionService ext_services.ExchangeRatesService, cfg *models.AppConfig,
+) *BillingActivities {
 	return &BillingActivities{
-		repository: repository,
+		repository:        repository,
+		conversionService: conversionService,
+		cfg:               cfg,
 	}
 }
 
 type BillingActivities struct {
-	repository repository.Repository
+	repository        repository.Repository
+	conversionService ext_services.ExchangeRatesService
+	cfg               *models.AppConfig
 }
 
-// SaveBill update bill status to "open" after the workflow has been started
+// SaveBill inserts the bill, succeeding when a previous attempt saved it.
+// Kept for workflows started before bills were created as drafts, see ActivateBill.
 func (a *BillingActivities) SaveBill(ctx context.Context, input *models.Bill) error {
 	logger := rlog.With("module", "billing_activities")
 	logger.Info("Saving bill", "bill_id", input.ID)
@@ -28,39 +38,179 @@ func (a *BillingActivities) SaveBill(ctx context.Context, input *models.Bill) er
 	err := a.repository.CreateBill(ctx, input)
 	if err != nil {
 		logger.Error("Failed to save bill", "error", err)
-		return err
+		return classifyError(err)
 	}
 
 	logger.Info("Save bill successfully", "bill_id", input.ID)
 	return nil
 }
 
+// ActivateBill opens the draft bill after its workflow started and returns the resulting bill status.
+// Bills that are no longer drafts are left untouched: open when activated by a previous attempt,
+// or voided by the reconciler when the workflow started too late.
+func (a *BillingActivities) ActivateBill(ctx context.Context, billID uuid.UUID) (models.BillStatus, error) {
+	logger := rlog.With("module", "billing_activities")
+	logger.Info("Activating bill", "bill_id", billID)
+
+	err := a.repository.ActivateBill(ctx, billID)
+	if err == nil {
+		logger.Info("Bill activated successfully", "bill_id", billID)
+		return models.BillStatusOpen, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		logger.Error("Failed to activate bill", "error", err)
+		return "", classifyError(err)
+	}
+
+	bill, err := a.repository.GetBillByID(ctx, billID, models.GetBillOptions{})
+	if err != nil {
+		logger.Error("Failed to get bill", "error", err)
+		return "", classifyError(err)
+	}
+
+	logger.Warn("Bill is not a draft, leaving it untouched", "bill_id", billID, "status", bill.Status)
+	return bill.Status, nil
+}
+
+type MarkBillClosingInput struct {
+	BillID    uuid.UUID `json:"bill_id"`
+	ClosingAt time.Time `json:"closing_at"`
+}
+
+// MarkBillClosing persists that the bill reached its close time and waits for late line items before closing.
+// It succeeds when a previous attempt marked the bill.
+func (a *BillingActivities) MarkBillClosing(ctx context.Context, input MarkBillClosingInput) error {
+	logger := rlog.With("module", "billing_activities")
+	logger.Info("Marking bill as closing", "bill_id", input.BillID)
+
+	err := a.repository.MarkBillClosing(ctx, input.BillID, input.ClosingAt)
+	if err != nil {
+		logger.Error("Failed to mark bill as closing", "error", err)
+		return classifyError(err)
+	}
+
+	logger.Info("Bill marked as closing successfully", "bill_id", input.BillID)
+	return nil
+}
+
 type CloseBillInput struct {
 	BillID   uuid.UUID `json:"bill_id"`
 	ClosedAt time.Time `json:"closed_at"`
 }
 
-// CloseBill closes a bill and sets its final total
+// CloseBill closes a bill and persists its final totals, their ledger journal and the recognition schedules of its
+// line items in the same transaction. It succeeds when a previous attempt closed the bill at the same time.
 func (a *BillingActivities) CloseBill(ctx context.Context, input CloseBillInput) (*models.Bill, error) {
 	logger := rlog.With("module", "billing_activities")
 	logger.Info("Closing bill", "bill_id", input.BillID)
-	err := a.repository.CloseBill(ctx, input.BillID, input.ClosedAt)
+
+	bill, err := a.repository.GetBillByID(ctx, input.BillID, models.GetBillOptions{IncludeLineItems: true})
+	if err != nil {
+		logger.Error("Failed to get bill", "error", err)
+		return nil, classifyError(err)
+	}
+
+	var journal *models.Journal
+	var schedules []*models.RecognitionSchedule
+	if len(bill.LineItems) > 0 {
+		// Exchange rates may be missing until they are fetched again, errors are retried
+		if err = computeTotals(ctx, a.conversionService, a.cfg, bill, nil); err != nil {
+			logger.Error("Failed to compute bill totals", "error", err)
+			return nil, err
+		}
+		computedAt := time.Now()
+		bill.Total.ComputedAt = &computedAt
+
+		if journal, err = a.billClosedJournal(ctx, bill, input.ClosedAt); err != nil {
+			logger.Error("Failed to build bill closed journal", "error", err)
+			return nil, err
+		}
+		if schedules, err = a.recognitionSchedules(bill, input.ClosedAt); err != nil {
+			logger.Error("Failed to build recognition schedules", "error", err)
+			return nil, err
+		}
+	}
+
+	err = a.repository.CloseBill(ctx, bill, input.ClosedAt, journal, schedules)
 	if err != nil {
 		logger.Error("Failed to close bill", "error", err)
+		return nil, classifyError(err)
+	}
+	bill.Close(input.ClosedAt)
+
+	logger.Info("Bill closed successfully", "bill_id", input.BillID)
+	return bill, nil
+}
+
+// billClosedJournal values the totals of the bill at the current rates in the ledger's functional currency
+func (a *BillingActivities) billClosedJournal(ctx context.Context, bill *models.Bill, closedAt time.Time) (*models.Journal, error) {
+	settings, err := models.LedgerSettingsFromConfig(a.cfg)
+	if err != nil {
+		return nil, err
+	}
+	rates, err := a.conversionService.GetRates(ctx)
+	if err != nil {
 		return nil, err
 	}
+	return models.NewBillClosedJournal(bill, closedAt, rates, settings)
+}
 
-	bill, err := a.repository.GetBillByID(ctx, input.BillID)
+// recognitionSchedules spreads the line totals of the bill over their service periods with the configured method
+func (a *BillingActivities) recognitionSchedules(bill *models.Bill, closedAt time.Time) ([]*models.RecognitionSchedule, error) {
+	method, err := models.RecognitionMethodFromConfig(a.cfg)
 	if err != nil {
-		logger.Error("Failed to get bill", "error", err)
 		return nil, err
 	}
+	return models.NewRecognitionSchedules(bill, closedAt, method, models.RoundingPolicyFromConfig(a.cfg))
+}
 
-	logger.Info("Bill closed successfully", "bill_id", input.BillID)
-	return bill, nil
+type VoidBillInput struct {
+	BillID   uuid.UUID `json:"bill_id"`
+	Reason   string    `json:"reason"`
+	VoidedAt time.Time `json:"voided_at"`
+}
+
+// VoidBill voids an open bill, succeeding when a previous attempt voided it
+func (a *BillingActivities) VoidBill(ctx context.Context, input VoidBillInput) error {
+	logger := rlog.With("module", "billing_activities")
+	logger.Info("Voiding bill", "bill_id", input.BillID, "reason", input.Reason)
+
+	err := a.repository.VoidBill(ctx, input.BillID, input.Reason, input.VoidedAt)
+	if err != nil {
+		logger.Error("Failed to void bill", "error", err)
+		return classifyError(err)
+	}
+
+	logger.Info("Bill voided successfully", "bill_id", input.BillID)
+	return nil
+}
+
+// ReopenBill reopens a closed bill and returns the line totals of its persisted line items, grouped per line amount
+func (a *BillingActivities) ReopenBill(ctx context.Context, billID uuid.UUID) ([]models.LineTotalGroup, error) {
+	logger := rlog.With("module", "billing_activities")
+	logger.Info("Reopening bill", "bill_id", billID)
+
+	err := a.repository.ReopenBill(ctx, billID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		logger.Error("Failed to reopen bill", "error", err)
+		return nil, classifyError(err)
+	}
+	if err != nil {
+		// the bill was reopened by a previous attempt of this activity
+		logger.Warn("Bill is not closed, assuming it was already reopened", "bill_id", billID)
+	}
+
+	lineTotals, err := a.repository.GetLineTotalGroups(ctx, billID, true)
+	if err != nil {
+		logger.Error("Failed to aggregate line totals", "error", err)
+		return nil, classifyError(err)
+	}
+
+	logger.Info("Bill reopened successfully", "bill_id", billID)
+	return lineTotals, nil
 }
 
-// AddLineItemToBill persists a line item and updates bill total in a single transaction
+// AddLineItemToBill persists a line item, succeeding when a previous attempt persisted it
 func (a *BillingActivities) AddLineItemToBill(ctx context.Context, lineItem models.LineItem) error {
 	logger := rlog.With("module", "billing_activities")
 	logger.Info("Persisting line item",
@@ -69,8 +219,8 @@ func (a *BillingActivities) AddLineItemToBill(ctx context.Context, lineItem mode
 
 	err := a.repository.AddLineItemToBill(ctx, &lineItem)
 	if err != nil {
-		logger.Error("Failed to persist line item and update bill total", "error", err)
-		return err
+		logger.Error("Failed to persist line item", "error", err)
+		return classifyError(err)
 	}
 
 	logger.Info("Line item persisted successfully",
@@ -78,3 +228,53 @@ func (a *BillingActivities) AddLineItemToBill(ctx context.Context, lineItem mode
 		"bill_id", lineItem.BillID)
 	return nil
 }
+
+type RemoveLineItemInput struct {
+	BillID     uuid.UUID `json:"bill_id"`
+	LineItemID uuid.UUID `json:"line_item_id"`
+	Reason     string    `json:"reason"`
+	RemovedAt  time.Time `json:"removed_at"`
+}
+
+// UpdateLineItem persists the updated description, quantity and unit price of a line item
+func (a *BillingActivities) UpdateLineItem(ctx context.Context, lineItem models.LineItem) error {
+	logger := rlog.With("module", "billing_activities")
+	logger.Info("Updating line item",
+		"line_item_id", lineItem.ID,
+		"bill_id", lineItem.BillID)
+
+	// Retrying cannot help when the bill is no longer open or the line item was deleted or updated later meanwhile,
+	// the update fails without retries and is recorded as a failed operation
+	err := a.repository.UpdateLineItem(ctx, &lineItem)
+	if err != nil {
+		logger.Error("Failed to update line item", "error", err)
+		return classifyError(err)
+	}
+
+	logger.Info("Line item updated successfully",
+		"line_item_id", lineItem.ID,
+		"bill_id", lineItem.BillID)
+	return nil
+}
+
+// RemoveLineItem soft-deletes a line item with the removal reason
+func (a *BillingActivities) RemoveLineItem(ctx context.Context, input RemoveLineItemInput) error {
+	logger := rlog.With("module", "billing_activities")
+	logger.Info("Removing line item",
+		"line_item_id", input.LineItemID,
+		"bill_id", input.BillID,
+		"reason", input.Reason)
+
+	// A line item deleted by a previous attempt is not an error, the removal fails without retries
+	// when the bill is no longer open or the line item is not found
+	err := a.repository.DeleteLineItem(ctx, input.BillID, input.LineItemID, input.Reason, input.RemovedAt)
+	if err != nil {
+		logger.Error("Failed to remove line item", "error", err)
+		return classifyError(err)
+	}
+
+	logger.Info("Line item removed successfully",
+		"line_item_id", input.LineItemID,
+		"bill_id", input.BillID)
+	return nil
+}
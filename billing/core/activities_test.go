@@ -2,22 +2,85 @@ package core
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"testing"
 	"time"
 
+	"encore.app/billing/ext_services/mocks"
 	"encore.app/billing/models"
 	"encore.app/billing/repository"
+	"encore.app/billing/repository/repositorytest"
 	"encore.dev/types/uuid"
+	"github.com/golang/mock/gomock"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/temporal"
 )
 
+// newTestActivities creates activities backed by the given repository and a conversion service returning fixed rates
+func newTestActivities(t *testing.T, repo repository.Repository) *BillingActivities {
+	ctrl := gomock.NewController(t)
+	conversionService := mocks.NewMockExchangeRatesService(ctrl)
+	conversionService.EXPECT().GetRates(gomock.Any()).Return(&models.RatesData{
+		Rates:     map[string]float64{"USD": 1.0, "GEL": 2.5},
+		UpdatedAt: time.Now(),
+	}, nil).AnyTimes()
+	cfg := &models.AppConfig{
+		Billing: models.BillingConfig{
+			DefaultPresentmentCurrency: func() string {
+				return "USD"
+			},
+			Rounding: models.RoundingConfig{
+				Mode: func() string {
+					return "half_up"
+				},
+				Level: func() string {
+					return "total"
+				},
+			},
+			Validation: models.ValidationConfig{
+				AllowedCurrencies: func() []string {
+					return []string{"USD", "GEL"}
+				},
+			},
+			Ledger:  testLedgerConfig(),
+			Invoice: testInvoiceTaxConfig(),
+			Recognition: models.RecognitionConfig{
+				Method: func() string {
+					return "daily"
+				},
+			},
+		},
+	}
+	return NewBillingActivities(repo, conversionService, cfg)
+}
+
+// testInvoiceTaxConfig invoices bills without VAT, the ledger posts no tax
+func testInvoiceTaxConfig() models.InvoiceConfig {
+	return models.InvoiceConfig{TaxPercent: func() float64 { return 0 }}
+}
+
+// testLedgerConfig posts journals valued in USD
+func testLedgerConfig() models.LedgerConfig {
+	return models.LedgerConfig{
+		FunctionalCurrency: func() string { return "USD" },
+		Accounts: models.LedgerAccountsConfig{
+			AccountsReceivable: func() string { return "1200" },
+			Revenue:            func() string { return "4000" },
+			TaxPayable:         func() string { return "2200" },
+			Cash:               func() string { return "1000" },
+			FXGain:             func() string { return "7100" },
+			FXLoss:             func() string { return "7200" },
+		},
+	}
+}
+
 func TestNewBillingActivities(t *testing.T) {
 	t.Run("should_create_activities_with_repository", func(t *testing.T) {
-		fakeRepo := &repository.FakeRepo{}
-		activities := NewBillingActivities(fakeRepo)
+		fakeRepo := &repositorytest.FakeRepo{}
+		activities := newTestActivities(t, fakeRepo)
 
 		assert.NotNil(t, activities)
 		assert.Equal(t, fakeRepo, activities.repository)
@@ -27,8 +90,8 @@ func TestNewBillingActivities(t *testing.T) {
 func TestBillingActivities_SaveBill(t *testing.T) {
 	t.Run("when_bill_is_valid", func(t *testing.T) {
 		t.Run("should_save_bill_successfully", func(t *testing.T) {
-			fakeRepo := &repository.FakeRepo{}
-			activities := NewBillingActivities(fakeRepo)
+			fakeRepo := &repositorytest.FakeRepo{}
+			activities := newTestActivities(t, fakeRepo)
 
 			bill := &models.Bill{
 				ID:          uuid.Must(uuid.NewV4()),
@@ -45,7 +108,7 @@ func TestBillingActivities_SaveBill(t *testing.T) {
 			assert.NoError(t, err)
 
 			// Verify bill was saved in the fake repo
-			savedBill, err := fakeRepo.GetBillByID(context.TODO(), bill.ID)
+			savedBill, err := fakeRepo.GetBillByID(context.TODO(), bill.ID, models.GetBillOptions{IncludeLineItems: true})
 			assert.NoError(t, err)
 			assert.Equal(t, bill.ID, savedBill.ID)
 			assert.Equal(t, bill.CustomerID, savedBill.CustomerID)
@@ -59,7 +122,7 @@ func TestBillingActivities_SaveBill(t *testing.T) {
 			mockRepo := &MockRepository{
 				createBillError: errors.New("database connection failed"),
 			}
-			activities := NewBillingActivities(mockRepo)
+			activities := newTestActivities(t, mockRepo)
 
 			bill := &models.Bill{
 				ID:          uuid.Must(uuid.NewV4()),
@@ -80,8 +143,8 @@ func TestBillingActivities_SaveBill(t *testing.T) {
 
 	t.Run("when_bill_has_line_items", func(t *testing.T) {
 		t.Run("should_save_bill_with_line_items", func(t *testing.T) {
-			fakeRepo := &repository.FakeRepo{}
-			activities := NewBillingActivities(fakeRepo)
+			fakeRepo := &repositorytest.FakeRepo{}
+			activities := newTestActivities(t, fakeRepo)
 
 			bill := &models.Bill{
 				ID:          uuid.Must(uuid.NewV4()),
@@ -109,7 +172,7 @@ func TestBillingActivities_SaveBill(t *testing.T) {
 			assert.NoError(t, err)
 
 			// Verify bill was saved
-			savedBill, err := fakeRepo.GetBillByID(context.TODO(), bill.ID)
+			savedBill, err := fakeRepo.GetBillByID(context.TODO(), bill.ID, models.GetBillOptions{IncludeLineItems: true})
 			assert.NoError(t, err)
 			assert.Equal(t, bill.ID, savedBill.ID)
 			assert.Len(t, savedBill.LineItems, 1)
@@ -117,11 +180,56 @@ func TestBillingActivities_SaveBill(t *testing.T) {
 	})
 }
 
+func TestBillingActivities_ActivateBill(t *testing.T) {
+	newDraft := func(t *testing.T, fakeRepo *repositorytest.FakeRepo, status models.BillStatus) *models.Bill {
+		bill := &models.Bill{ID: uuid.Must(uuid.NewV4()), CustomerID: "customer-123", Status: status}
+		require.NoError(t, fakeRepo.CreateBill(context.Background(), bill))
+		return bill
+	}
+
+	t.Run("when_bill_is_draft", func(t *testing.T) {
+		t.Run("should_open_bill", func(t *testing.T) {
+			fakeRepo := &repositorytest.FakeRepo{}
+			activities := newTestActivities(t, fakeRepo)
+			bill := newDraft(t, fakeRepo, models.BillStatusDraft)
+
+			status, err := activities.ActivateBill(context.Background(), bill.ID)
+
+			assert.NoError(t, err)
+			assert.Equal(t, models.BillStatusOpen, status)
+			assert.Equal(t, models.BillStatusOpen, bill.Status)
+		})
+	})
+
+	t.Run("when_bill_is_not_draft", func(t *testing.T) {
+		t.Run("should_return_current_status", func(t *testing.T) {
+			fakeRepo := &repositorytest.FakeRepo{}
+			activities := newTestActivities(t, fakeRepo)
+			bill := newDraft(t, fakeRepo, models.BillStatusVoided)
+
+			status, err := activities.ActivateBill(context.Background(), bill.ID)
+
+			assert.NoError(t, err)
+			assert.Equal(t, models.BillStatusVoided, status)
+		})
+	})
+
+	t.Run("when_repository_fails", func(t *testing.T) {
+		t.Run("should_return_error", func(t *testing.T) {
+			activities := newTestActivities(t, &MockRepository{createBillError: errors.New("database error")})
+
+			_, err := activities.ActivateBill(context.Background(), uuid.Must(uuid.NewV4()))
+
+			assert.Error(t, err)
+		})
+	})
+}
+
 func TestBillingActivities_CloseBill(t *testing.T) {
 	t.Run("when_bill_exists", func(t *testing.T) {
 		t.Run("should_close_bill_successfully", func(t *testing.T) {
-			fakeRepo := &repository.FakeRepo{}
-			activities := NewBillingActivities(fakeRepo)
+			fakeRepo := &repositorytest.FakeRepo{}
+			activities := newTestActivities(t, fakeRepo)
 
 			billID := uuid.Must(uuid.NewV4())
 			closedAt := time.Now()
@@ -152,7 +260,7 @@ func TestBillingActivities_CloseBill(t *testing.T) {
 			assert.Equal(t, &closedAt, closedBill.ClosedAt)
 
 			// Verify the bill was actually closed in the repo
-			savedBill, err := fakeRepo.GetBillByID(context.TODO(), billID)
+			savedBill, err := fakeRepo.GetBillByID(context.TODO(), billID, models.GetBillOptions{IncludeLineItems: true})
 			assert.NoError(t, err)
 			assert.Equal(t, models.BillStatusClosed, savedBill.Status)
 			assert.Equal(t, &closedAt, savedBill.ClosedAt)
@@ -161,8 +269,8 @@ func TestBillingActivities_CloseBill(t *testing.T) {
 
 	t.Run("when_bill_does_not_exist", func(t *testing.T) {
 		t.Run("should_return_error", func(t *testing.T) {
-			fakeRepo := &repository.FakeRepo{}
-			activities := NewBillingActivities(fakeRepo)
+			fakeRepo := &repositorytest.FakeRepo{}
+			activities := newTestActivities(t, fakeRepo)
 
 			billID := uuid.Must(uuid.NewV4())
 			closedAt := time.Now()
@@ -176,7 +284,10 @@ func TestBillingActivities_CloseBill(t *testing.T) {
 
 			assert.Error(t, err)
 			assert.Nil(t, closedBill)
-			assert.Equal(t, models.ErrBillNotFound, err)
+			assert.ErrorIs(t, err, models.ErrBillNotFound)
+			var appErr *temporal.ApplicationError
+			require.ErrorAs(t, err, &appErr)
+			assert.True(t, appErr.NonRetryable())
 		})
 	})
 
@@ -186,7 +297,7 @@ func TestBillingActivities_CloseBill(t *testing.T) {
 			mockRepo := &MockRepository{
 				closeBillError: errors.New("failed to close bill"),
 			}
-			activities := NewBillingActivities(mockRepo)
+			activities := newTestActivities(t, mockRepo)
 
 			billID := uuid.Must(uuid.NewV4())
 			closedAt := time.Now()
@@ -210,7 +321,7 @@ func TestBillingActivities_CloseBill(t *testing.T) {
 			mockRepo := &MockRepository{
 				getBillByIDError: errors.New("failed to retrieve bill"),
 			}
-			activities := NewBillingActivities(mockRepo)
+			activities := newTestActivities(t, mockRepo)
 
 			billID := uuid.Must(uuid.NewV4())
 			closedAt := time.Now()
@@ -230,8 +341,8 @@ func TestBillingActivities_CloseBill(t *testing.T) {
 
 	t.Run("when_bill_has_line_items", func(t *testing.T) {
 		t.Run("should_close_bill_with_line_items", func(t *testing.T) {
-			fakeRepo := &repository.FakeRepo{}
-			activities := NewBillingActivities(fakeRepo)
+			fakeRepo := &repositorytest.FakeRepo{}
+			activities := newTestActivities(t, fakeRepo)
 
 			billID := uuid.Must(uuid.NewV4())
 			closedAt := time.Now()
@@ -275,14 +386,133 @@ func TestBillingActivities_CloseBill(t *testing.T) {
 			assert.Equal(t, &closedAt, closedBill.ClosedAt)
 			assert.Len(t, closedBill.LineItems, 1)
 		})
+
+		t.Run("should_persist_totals_with_closed_bill", func(t *testing.T) {
+			fakeRepo := &repositorytest.FakeRepo{}
+			activities := newTestActivities(t, fakeRepo)
+
+			billID := uuid.Must(uuid.NewV4())
+			bill := &models.Bill{
+				ID:                  billID,
+				CustomerID:          "customer-123",
+				Status:              models.BillStatusOpen,
+				PresentmentCurrency: models.USD,
+				PeriodStart:         time.Now(),
+				PeriodEnd:           time.Now().AddDate(0, 1, 0),
+				CreatedAt:           time.Now(),
+				UpdatedAt:           time.Now(),
+			}
+			require.NoError(t, fakeRepo.CreateBill(context.TODO(), bill))
+			require.NoError(t, fakeRepo.AddLineItemToBill(context.TODO(), &models.LineItem{
+				ID:          uuid.Must(uuid.NewV4()),
+				BillID:      billID,
+				Description: "Test service",
+				Currency:    models.GEL,
+				Quantity:    decimal.NewFromInt(2),
+				UnitPrice:   decimal.NewFromInt(25),
+				CreatedAt:   time.Now(),
+			}))
+
+			_, err := activities.CloseBill(context.TODO(), CloseBillInput{BillID: billID, ClosedAt: time.Now()})
+			require.NoError(t, err)
+
+			savedBill, err := fakeRepo.GetBillByID(context.TODO(), billID, models.GetBillOptions{IncludeLineItems: true})
+			require.NoError(t, err)
+			require.NotNil(t, savedBill.Total)
+			assert.NotNil(t, savedBill.Total.ComputedAt)
+			assert.True(t, decimal.NewFromInt(50).Equal(savedBill.Total.ByCurrency[models.GEL]))
+			assert.True(t, decimal.NewFromInt(20).Equal(savedBill.Total.GrandTotal.Amount))
+		})
+
+		t.Run("should_post_bill_closed_journal_once", func(t *testing.T) {
+			fakeRepo := &repositorytest.FakeRepo{}
+			activities := newTestActivities(t, fakeRepo)
+			bill := &models.Bill{
+				ID:                  uuid.Must(uuid.NewV4()),
+				CustomerID:          "customer-123",
+				Status:              models.BillStatusOpen,
+				PresentmentCurrency: models.USD,
+				PeriodStart:         time.Now(),
+				PeriodEnd:           time.Now().AddDate(0, 1, 0),
+			}
+			require.NoError(t, fakeRepo.CreateBill(context.TODO(), bill))
+			require.NoError(t, fakeRepo.AddLineItemToBill(context.TODO(), &models.LineItem{
+				ID:        uuid.Must(uuid.NewV4()),
+				BillID:    bill.ID,
+				Currency:  models.GEL,
+				Quantity:  decimal.NewFromInt(2),
+				UnitPrice: decimal.NewFromInt(25),
+			}))
+			input := CloseBillInput{BillID: bill.ID, ClosedAt: time.Now()}
+
+			_, err := activities.CloseBill(context.TODO(), input)
+			require.NoError(t, err)
+			_, err = activities.CloseBill(context.TODO(), input)
+			require.NoError(t, err)
+
+			journals, err := fakeRepo.ListBillJournals(context.TODO(), bill.ID)
+			require.NoError(t, err)
+			require.Len(t, journals, 1)
+			assert.Equal(t, models.JournalBillClosed, journals[0].Type)
+			receivable, functional := models.LedgerBalance(journals, "1200", models.GEL)
+			assert.True(t, decimal.NewFromInt(50).Equal(receivable))
+			assert.True(t, decimal.NewFromInt(20).Equal(functional))
+		})
+
+		t.Run("should_store_recognition_schedules_until_bill_is_reopened", func(t *testing.T) {
+			fakeRepo := &repositorytest.FakeRepo{}
+			activities := newTestActivities(t, fakeRepo)
+			periodStart := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+			serviceStart, serviceEnd := periodStart, periodStart.AddDate(1, 0, 0)
+			bill := &models.Bill{
+				ID:                  uuid.Must(uuid.NewV4()),
+				CustomerID:          "customer-123",
+				Status:              models.BillStatusOpen,
+				PresentmentCurrency: models.USD,
+				PeriodStart:         periodStart,
+				PeriodEnd:           periodStart.AddDate(0, 1, 0),
+			}
+			require.NoError(t, fakeRepo.CreateBill(context.TODO(), bill))
+			annualFee := &models.LineItem{
+				ID:           uuid.Must(uuid.NewV4()),
+				BillID:       bill.ID,
+				Currency:     models.USD,
+				Quantity:     decimal.NewFromInt(1),
+				UnitPrice:    decimal.NewFromInt(365),
+				ServiceStart: &serviceStart,
+				ServiceEnd:   &serviceEnd,
+			}
+			require.NoError(t, fakeRepo.AddLineItemToBill(context.TODO(), annualFee))
+			input := CloseBillInput{BillID: bill.ID, ClosedAt: bill.PeriodEnd}
+
+			_, err := activities.CloseBill(context.TODO(), input)
+			require.NoError(t, err)
+			_, err = activities.CloseBill(context.TODO(), input)
+			require.NoError(t, err)
+
+			schedules, err := fakeRepo.ListBillRecognitionSchedules(context.TODO(), bill.ID)
+			require.NoError(t, err)
+			require.Len(t, schedules, 1)
+			assert.Equal(t, models.RecognitionDaily, schedules[0].Method)
+			assert.Equal(t, annualFee.ID, schedules[0].LineItemID)
+			require.Len(t, schedules[0].Entries, 12)
+			assert.True(t, decimal.NewFromInt(31).Equal(schedules[0].Entries[0].Recognized))
+			assert.True(t, decimal.NewFromInt(334).Equal(schedules[0].Entries[0].Deferred))
+
+			_, err = activities.ReopenBill(context.TODO(), bill.ID)
+			require.NoError(t, err)
+			schedules, err = fakeRepo.ListBillRecognitionSchedules(context.TODO(), bill.ID)
+			require.NoError(t, err)
+			assert.Empty(t, schedules)
+		})
 	})
 }
 
 func TestBillingActivities_AddLineItemToBill(t *testing.T) {
 	t.Run("when_line_item_is_valid", func(t *testing.T) {
 		t.Run("should_add_line_item_successfully", func(t *testing.T) {
-			fakeRepo := &repository.FakeRepo{}
-			activities := NewBillingActivities(fakeRepo)
+			fakeRepo := &repositorytest.FakeRepo{}
+			activities := newTestActivities(t, fakeRepo)
 
 			billID := uuid.Must(uuid.NewV4())
 			lineItem := models.LineItem{
@@ -316,7 +546,7 @@ func TestBillingActivities_AddLineItemToBill(t *testing.T) {
 			mockRepo := &MockRepository{
 				addLineItemError: errors.New("failed to add line item"),
 			}
-			activities := NewBillingActivities(mockRepo)
+			activities := newTestActivities(t, mockRepo)
 
 			lineItem := models.LineItem{
 				ID:          uuid.Must(uuid.NewV4()),
@@ -336,8 +566,8 @@ func TestBillingActivities_AddLineItemToBill(t *testing.T) {
 
 	t.Run("when_line_item_has_high_precision_values", func(t *testing.T) {
 		t.Run("should_preserve_decimal_precision", func(t *testing.T) {
-			fakeRepo := &repository.FakeRepo{}
-			activities := NewBillingActivities(fakeRepo)
+			fakeRepo := &repositorytest.FakeRepo{}
+			activities := newTestActivities(t, fakeRepo)
 
 			billID := uuid.Must(uuid.NewV4())
 			lineItem := models.LineItem{
@@ -364,8 +594,8 @@ func TestBillingActivities_AddLineItemToBill(t *testing.T) {
 
 	t.Run("when_line_item_has_zero_values", func(t *testing.T) {
 		t.Run("should_handle_zero_values_correctly", func(t *testing.T) {
-			fakeRepo := &repository.FakeRepo{}
-			activities := NewBillingActivities(fakeRepo)
+			fakeRepo := &repositorytest.FakeRepo{}
+			activities := newTestActivities(t, fakeRepo)
 
 			billID := uuid.Must(uuid.NewV4())
 			lineItem := models.LineItem{
@@ -392,8 +622,8 @@ func TestBillingActivities_AddLineItemToBill(t *testing.T) {
 
 	t.Run("when_line_item_has_negative_values", func(t *testing.T) {
 		t.Run("should_handle_negative_values", func(t *testing.T) {
-			fakeRepo := &repository.FakeRepo{}
-			activities := NewBillingActivities(fakeRepo)
+			fakeRepo := &repositorytest.FakeRepo{}
+			activities := newTestActivities(t, fakeRepo)
 
 			billID := uuid.Must(uuid.NewV4())
 			lineItem := models.LineItem{
@@ -419,11 +649,208 @@ func TestBillingActivities_AddLineItemToBill(t *testing.T) {
 	})
 }
 
+// TestBillingActivities_DuplicateExecution runs activities again as Temporal does
+// when an attempt succeeded but its completion was lost
+func TestBillingActivities_DuplicateExecution(t *testing.T) {
+	newOpenBill := func(t *testing.T, fakeRepo *repositorytest.FakeRepo) *models.Bill {
+		bill := &models.Bill{
+			ID:          uuid.Must(uuid.NewV4()),
+			CustomerID:  "customer-123",
+			Status:      models.BillStatusOpen,
+			PeriodStart: time.Now(),
+			PeriodEnd:   time.Now().AddDate(0, 1, 0),
+			CreatedAt:   time.Now(),
+			UpdatedAt:   time.Now(),
+		}
+		require.NoError(t, fakeRepo.CreateBill(context.TODO(), bill))
+		return bill
+	}
+
+	t.Run("when_bill_is_saved_twice_should_succeed", func(t *testing.T) {
+		fakeRepo := &repositorytest.FakeRepo{}
+		activities := newTestActivities(t, fakeRepo)
+		bill := &models.Bill{ID: uuid.Must(uuid.NewV4()), CustomerID: "customer-123", Status: models.BillStatusOpen}
+
+		assert.NoError(t, activities.SaveBill(context.TODO(), bill))
+		assert.NoError(t, activities.SaveBill(context.TODO(), bill))
+	})
+
+	t.Run("when_line_item_is_added_twice_should_persist_it_once", func(t *testing.T) {
+		fakeRepo := &repositorytest.FakeRepo{}
+		activities := newTestActivities(t, fakeRepo)
+		bill := newOpenBill(t, fakeRepo)
+		lineItem := models.LineItem{
+			ID:        uuid.Must(uuid.NewV4()),
+			BillID:    bill.ID,
+			Currency:  models.USD,
+			Quantity:  decimal.NewFromInt(1),
+			UnitPrice: decimal.NewFromInt(10),
+		}
+
+		assert.NoError(t, activities.AddLineItemToBill(context.TODO(), lineItem))
+		assert.NoError(t, activities.AddLineItemToBill(context.TODO(), lineItem))
+
+		lineItems, err := fakeRepo.GetLineItemsByBillID(context.TODO(), bill.ID)
+		require.NoError(t, err)
+		assert.Len(t, lineItems, 1)
+	})
+
+	t.Run("when_line_item_was_added_before_bill_closed_should_succeed_on_retry", func(t *testing.T) {
+		fakeRepo := &repositorytest.FakeRepo{}
+		activities := newTestActivities(t, fakeRepo)
+		bill := newOpenBill(t, fakeRepo)
+		lineItem := models.LineItem{
+			ID:        uuid.Must(uuid.NewV4()),
+			BillID:    bill.ID,
+			Currency:  models.USD,
+			Quantity:  decimal.NewFromInt(1),
+			UnitPrice: decimal.NewFromInt(10),
+		}
+		require.NoError(t, activities.AddLineItemToBill(context.TODO(), lineItem))
+		_, err := activities.CloseBill(context.TODO(), CloseBillInput{BillID: bill.ID, ClosedAt: time.Now()})
+		require.NoError(t, err)
+
+		assert.NoError(t, activities.AddLineItemToBill(context.TODO(), lineItem))
+	})
+
+	t.Run("when_bill_closed_before_line_item_was_added_should_fail_without_retries", func(t *testing.T) {
+		fakeRepo := &repositorytest.FakeRepo{}
+		activities := newTestActivities(t, fakeRepo)
+		bill := newOpenBill(t, fakeRepo)
+		_, err := activities.CloseBill(context.TODO(), CloseBillInput{BillID: bill.ID, ClosedAt: time.Now()})
+		require.NoError(t, err)
+
+		err = activities.AddLineItemToBill(context.TODO(), models.LineItem{
+			ID:        uuid.Must(uuid.NewV4()),
+			BillID:    bill.ID,
+			Currency:  models.USD,
+			Quantity:  decimal.NewFromInt(1),
+			UnitPrice: decimal.NewFromInt(10),
+		})
+
+		var appErr *temporal.ApplicationError
+		require.ErrorAs(t, err, &appErr)
+		assert.True(t, appErr.NonRetryable())
+		assert.Equal(t, InvalidStateErrorType, appErr.Type())
+		lineItems, err := fakeRepo.GetLineItemsByBillID(context.TODO(), bill.ID)
+		require.NoError(t, err)
+		assert.Empty(t, lineItems)
+	})
+
+	t.Run("when_bill_is_marked_closing_twice_should_succeed", func(t *testing.T) {
+		fakeRepo := &repositorytest.FakeRepo{}
+		activities := newTestActivities(t, fakeRepo)
+		bill := newOpenBill(t, fakeRepo)
+		input := MarkBillClosingInput{BillID: bill.ID, ClosingAt: time.Now()}
+
+		assert.NoError(t, activities.MarkBillClosing(context.TODO(), input))
+		assert.NoError(t, activities.MarkBillClosing(context.TODO(), input))
+	})
+
+	t.Run("when_bill_is_closed_twice_at_same_time_should_succeed", func(t *testing.T) {
+		fakeRepo := &repositorytest.FakeRepo{}
+		activities := newTestActivities(t, fakeRepo)
+		bill := newOpenBill(t, fakeRepo)
+		input := CloseBillInput{BillID: bill.ID, ClosedAt: time.Now()}
+
+		_, err := activities.CloseBill(context.TODO(), input)
+		require.NoError(t, err)
+		closedBill, err := activities.CloseBill(context.TODO(), input)
+
+		assert.NoError(t, err)
+		assert.Equal(t, models.BillStatusClosed, closedBill.Status)
+	})
+
+	t.Run("when_bill_is_voided_twice_should_succeed", func(t *testing.T) {
+		fakeRepo := &repositorytest.FakeRepo{}
+		activities := newTestActivities(t, fakeRepo)
+		bill := newOpenBill(t, fakeRepo)
+		input := VoidBillInput{BillID: bill.ID, Reason: "duplicate", VoidedAt: time.Now()}
+
+		assert.NoError(t, activities.VoidBill(context.TODO(), input))
+		assert.NoError(t, activities.VoidBill(context.TODO(), input))
+	})
+
+	t.Run("when_bill_is_voided_should_fail_close_without_retries", func(t *testing.T) {
+		fakeRepo := &repositorytest.FakeRepo{}
+		activities := newTestActivities(t, fakeRepo)
+		bill := newOpenBill(t, fakeRepo)
+		require.NoError(t, activities.VoidBill(context.TODO(), VoidBillInput{BillID: bill.ID, Reason: "duplicate", VoidedAt: time.Now()}))
+
+		_, err := activities.CloseBill(context.TODO(), CloseBillInput{BillID: bill.ID, ClosedAt: time.Now()})
+
+		var appErr *temporal.ApplicationError
+		require.ErrorAs(t, err, &appErr)
+		assert.True(t, appErr.NonRetryable())
+		assert.Equal(t, InvalidStateErrorType, appErr.Type())
+	})
+}
+
+func TestBillingActivities_UpdateAndRemoveLineItem(t *testing.T) {
+	newBillWithLineItem := func(t *testing.T, fakeRepo *repositorytest.FakeRepo) (*models.Bill, models.LineItem) {
+		bill := &models.Bill{ID: uuid.Must(uuid.NewV4()), CustomerID: "customer-123", Status: models.BillStatusOpen}
+		require.NoError(t, fakeRepo.CreateBill(context.TODO(), bill))
+		lineItem := models.LineItem{
+			ID:        uuid.Must(uuid.NewV4()),
+			BillID:    bill.ID,
+			Currency:  models.USD,
+			Quantity:  decimal.NewFromInt(1),
+			UnitPrice: decimal.NewFromInt(10),
+		}
+		require.NoError(t, fakeRepo.AddLineItemToBill(context.TODO(), &lineItem))
+		return bill, lineItem
+	}
+	requireNonRetryable := func(t *testing.T, err error) {
+		var appErr *temporal.ApplicationError
+		require.ErrorAs(t, err, &appErr)
+		assert.True(t, appErr.NonRetryable())
+		assert.Equal(t, InvalidStateErrorType, appErr.Type())
+	}
+
+	t.Run("when_bill_is_closed_should_fail_update_without_retries", func(t *testing.T) {
+		fakeRepo := &repositorytest.FakeRepo{}
+		activities := newTestActivities(t, fakeRepo)
+		bill, lineItem := newBillWithLineItem(t, fakeRepo)
+		_, err := activities.CloseBill(context.TODO(), CloseBillInput{BillID: bill.ID, ClosedAt: time.Now()})
+		require.NoError(t, err)
+
+		lineItem.Quantity = decimal.NewFromInt(2)
+		requireNonRetryable(t, activities.UpdateLineItem(context.TODO(), lineItem))
+	})
+
+	t.Run("when_bill_is_closed_should_fail_removal_without_retries", func(t *testing.T) {
+		fakeRepo := &repositorytest.FakeRepo{}
+		activities := newTestActivities(t, fakeRepo)
+		bill, lineItem := newBillWithLineItem(t, fakeRepo)
+		_, err := activities.CloseBill(context.TODO(), CloseBillInput{BillID: bill.ID, ClosedAt: time.Now()})
+		require.NoError(t, err)
+
+		err = activities.RemoveLineItem(context.TODO(), RemoveLineItemInput{
+			BillID: bill.ID, LineItemID: lineItem.ID, Reason: "duplicate", RemovedAt: time.Now(),
+		})
+
+		requireNonRetryable(t, err)
+		lineItems, err := fakeRepo.GetLineItemsByBillID(context.TODO(), bill.ID)
+		require.NoError(t, err)
+		assert.Len(t, lineItems, 1)
+	})
+
+	t.Run("when_line_item_is_not_found_should_fail_update_without_retries", func(t *testing.T) {
+		fakeRepo := &repositorytest.FakeRepo{}
+		activities := newTestActivities(t, fakeRepo)
+		bill, _ := newBillWithLineItem(t, fakeRepo)
+
+		requireNonRetryable(t, activities.UpdateLineItem(context.TODO(), models.LineItem{ID: uuid.Must(uuid.NewV4()), BillID: bill.ID}))
+	})
+}
+
 // MockRepository is a mock implementation for testing error scenarios
 type MockRepository struct {
 	createBillError   error
 	getBillByIDError  error
 	closeBillError    error
+	voidBillError     error
+	reopenBillError   error
 	addLineItemError  error
 	getLineItemsError error
 }
@@ -435,20 +862,146 @@ func (m *MockRepository) CreateBill(ctx context.Context, bill *models.Bill) erro
 	return nil
 }
 
-func (m *MockRepository) GetBillByID(ctx context.Context, billID uuid.UUID) (*models.Bill, error) {
+func (m *MockRepository) GetBillByID(ctx context.Context, billID uuid.UUID, opts models.GetBillOptions) (*models.Bill, error) {
 	if m.getBillByIDError != nil {
 		return nil, m.getBillByIDError
 	}
 	return &models.Bill{ID: billID}, nil
 }
 
-func (m *MockRepository) CloseBill(ctx context.Context, billID uuid.UUID, closedAt time.Time) error {
+func (m *MockRepository) ListDraftBills(ctx context.Context, createdBefore time.Time, limit int) ([]*models.Bill, error) {
+	return []*models.Bill{}, nil
+}
+
+func (m *MockRepository) ListOpenBills(ctx context.Context, after uuid.UUID, limit int) ([]*models.Bill, error) {
+	return []*models.Bill{}, nil
+}
+
+func (m *MockRepository) ListClosedBills(
+	ctx context.Context, closedFrom, closedTo time.Time, after uuid.UUID, limit int,
+) ([]*models.Bill, error) {
+	return []*models.Bill{}, nil
+}
+
+func (m *MockRepository) SaveReconciliationReport(ctx context.Context, report *models.ReconciliationReport) error {
+	return nil
+}
+
+func (m *MockRepository) ListReconciliationReports(ctx context.Context, limit int) ([]*models.ReconciliationReport, error) {
+	return []*models.ReconciliationReport{}, nil
+}
+
+func (m *MockRepository) GetReconciliationReport(ctx context.Context, id uuid.UUID) (*models.ReconciliationReport, error) {
+	return nil, sql.ErrNoRows
+}
+
+func (m *MockRepository) SaveFailedOperation(ctx context.Context, op *models.FailedOperation) error {
+	return nil
+}
+
+func (m *MockRepository) ResolveFailedOperation(
+	ctx context.Context, id uuid.UUID, status models.FailedOperationStatus, note string, resolvedAt time.Time,
+) error {
+	return nil
+}
+
+func (m *MockRepository) ListFailedOperations(ctx context.Context, filter models.FailedOperationFilter) ([]*models.FailedOperation, error) {
+	return []*models.FailedOperation{}, nil
+}
+
+func (m *MockRepository) GetFailedOperation(ctx context.Context, id uuid.UUID) (*models.FailedOperation, error) {
+	return nil, sql.ErrNoRows
+}
+
+func (m *MockRepository) ListAuditEvents(ctx context.Context, billID uuid.UUID) ([]*models.AuditEvent, error) {
+	return []*models.AuditEvent{}, nil
+}
+
+func (m *MockRepository) PostBillJournals(
+	ctx context.Context,
+	billID uuid.UUID,
+	build func(status models.BillStatus, ledger []*models.Journal) ([]*models.Journal, error),
+) ([]*models.Journal, error) {
+	return build(models.BillStatusClosed, []*models.Journal{})
+}
+
+func (m *MockRepository) ListBillJournals(ctx context.Context, billID uuid.UUID) ([]*models.Journal, error) {
+	return []*models.Journal{}, nil
+}
+
+func (m *MockRepository) GetTrialBalance(ctx context.Context, asOf time.Time, functional models.Currency) (*models.TrialBalance, error) {
+	return models.NewTrialBalance(asOf, functional, []models.TrialBalanceLine{}, nil), nil
+}
+
+func (m *MockRepository) ListJournals(ctx context.Context, postedFrom, postedTo time.Time) ([]*models.Journal, error) {
+	return []*models.Journal{}, nil
+}
+
+func (m *MockRepository) CreateExportJob(ctx context.Context, job *models.ExportJob) error {
+	return nil
+}
+
+func (m *MockRepository) GetExportJob(ctx context.Context, id uuid.UUID) (*models.ExportJob, error) {
+	return nil, sql.ErrNoRows
+}
+
+func (m *MockRepository) MarkExportJobRunning(ctx context.Context, id uuid.UUID, startedAt time.Time) error {
+	return nil
+}
+
+func (m *MockRepository) CompleteExportJob(ctx context.Context, id uuid.UUID, rows int, completedAt time.Time) error {
+	return nil
+}
+
+func (m *MockRepository) FailExportJob(ctx context.Context, id uuid.UUID, message string, failedAt time.Time) error {
+	return nil
+}
+
+func (m *MockRepository) ActivateBill(ctx context.Context, billID uuid.UUID) error {
+	if m.createBillError != nil {
+		return m.createBillError
+	}
+	return nil
+}
+
+func (m *MockRepository) MarkBillClosing(ctx context.Context, billID uuid.UUID, closingAt time.Time) error {
+	if m.closeBillError != nil {
+		return m.closeBillError
+	}
+	return nil
+}
+
+func (m *MockRepository) CloseBill(
+	ctx context.Context, bill *models.Bill, closedAt time.Time, journal *models.Journal,
+	schedules []*models.RecognitionSchedule,
+) error {
+	if m.closeBillError != nil {
+		return m.closeBillError
+	}
+	return nil
+}
+
+func (m *MockRepository) FinalizeBill(ctx context.Context, billID uuid.UUID, finalizedAt time.Time) error {
 	if m.closeBillError != nil {
 		return m.closeBillError
 	}
 	return nil
 }
 
+func (m *MockRepository) VoidBill(ctx context.Context, billID uuid.UUID, reason string, voidedAt time.Time) error {
+	if m.voidBillError != nil {
+		return m.voidBillError
+	}
+	return nil
+}
+
+func (m *MockRepository) ReopenBill(ctx context.Context, billID uuid.UUID) error {
+	if m.reopenBillError != nil {
+		return m.reopenBillError
+	}
+	return nil
+}
+
 func (m *MockRepository) AddLineItemToBill(ctx context.Context, lineItem *models.LineItem) error {
 	if m.addLineItemError != nil {
 		return m.addLineItemError
@@ -456,9 +1009,104 @@ func (m *MockRepository) AddLineItemToBill(ctx context.Context, lineItem *models
 	return nil
 }
 
+func (m *MockRepository) UpdateLineItem(ctx context.Context, lineItem *models.LineItem) error {
+	if m.addLineItemError != nil {
+		return m.addLineItemError
+	}
+	return nil
+}
+
+func (m *MockRepository) DeleteLineItem(ctx context.Context, billID uuid.UUID, lineItemID uuid.UUID, reason string, deletedAt time.Time) error {
+	if m.addLineItemError != nil {
+		return m.addLineItemError
+	}
+	return nil
+}
+
 func (m *MockRepository) GetLineItemsByBillID(ctx context.Context, billID uuid.UUID) ([]*models.LineItem, error) {
 	if m.getLineItemsError != nil {
 		return nil, m.getLineItemsError
 	}
 	return []*models.LineItem{}, nil
 }
+
+func (m *MockRepository) GetLineItem(ctx context.Context, billID, lineItemID uuid.UUID) (*models.LineItem, error) {
+	if m.getLineItemsError != nil {
+		return nil, m.getLineItemsError
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MockRepository) ListLineItems(ctx context.Context, billID uuid.UUID, filter models.LineItemFilter) ([]*models.LineItem, error) {
+	if m.getLineItemsError != nil {
+		return nil, m.getLineItemsError
+	}
+	return []*models.LineItem{}, nil
+}
+
+func (m *MockRepository) GetLineTotalGroups(ctx context.Context, billID uuid.UUID, byLineAmount bool) ([]models.LineTotalGroup, error) {
+	if m.getLineItemsError != nil {
+		return nil, m.getLineItemsError
+	}
+	return []models.LineTotalGroup{}, nil
+}
+
+func (m *MockRepository) CreateBankTransaction(ctx context.Context, txn *models.BankTransaction) (bool, error) {
+	return true, nil
+}
+
+func (m *MockRepository) UpdateBankTransactionMatch(ctx context.Context, txn *models.BankTransaction) error {
+	return nil
+}
+
+func (m *MockRepository) ListBankTransactions(ctx context.Context, filter models.BankTransactionFilter) ([]*models.BankTransaction, error) {
+	return []*models.BankTransaction{}, nil
+}
+
+func (m *MockRepository) GetBankTransaction(ctx context.Context, id uuid.UUID) (*models.BankTransaction, error) {
+	return nil, sql.ErrNoRows
+}
+
+func (m *MockRepository) ListReceivables(ctx context.Context, account string, currency models.Currency) ([]models.Receivable, error) {
+	return []models.Receivable{}, nil
+}
+
+func (m *MockRepository) ListOpenReceivables(ctx context.Context, account string, asOf time.Time, customerID string) ([]models.OpenReceivable, error) {
+	return []models.OpenReceivable{}, nil
+}
+
+func (m *MockRepository) ListReceivableActivity(ctx context.Context, account string, customerID string, to time.Time) ([]models.ReceivableActivity, error) {
+	return []models.ReceivableActivity{}, nil
+}
+
+func (m *MockRepository) RefreshRevenueRollups(ctx context.Context, from, refreshedAt time.Time) (*models.RollupRefresh, error) {
+	return &models.RollupRefresh{From: from, RefreshedAt: refreshedAt}, nil
+}
+
+func (m *MockRepository) GetRevenueRollupsRefresh(ctx context.Context) (*models.RollupRefresh, error) {
+	return nil, sql.ErrNoRows
+}
+
+func (m *MockRepository) ListRevenueByPeriod(ctx context.Context, from, to time.Time, granularity models.Granularity) ([]models.PeriodRevenue, error) {
+	return []models.PeriodRevenue{}, nil
+}
+
+func (m *MockRepository) ListRecurringRevenue(ctx context.Context, from, to time.Time) ([]models.RecurringRevenue, error) {
+	return []models.RecurringRevenue{}, nil
+}
+
+func (m *MockRepository) GetCustomerProfile(ctx context.Context, customerID string) (*models.CustomerProfile, error) {
+	return nil, sql.ErrNoRows
+}
+
+func (m *MockRepository) UpsertCustomerProfile(ctx context.Context, profile *models.CustomerProfile) error {
+	return nil
+}
+
+func (m *MockRepository) ListBillRecognitionSchedules(ctx context.Context, billID uuid.UUID) ([]*models.RecognitionSchedule, error) {
+	return []*models.RecognitionSchedule{}, nil
+}
+
+func (m *MockRepository) ListDeferredRevenueMovements(ctx context.Context, billedBefore time.Time) ([]models.DeferredRevenueMovement, error) {
+	return []models.DeferredRevenueMovement{}, nil
+}
@@ -0,0 +1,73 @@
+package core
+
+import (
+	"database/sql"
+	"errors"
+
+	"encore.dev/beta/errs"
+	"encore.dev/storage/sqldb"
+	"encore.dev/storage/sqldb/sqlerr"
+	"go.temporal.io/sdk/temporal"
+)
+
+// Application error types of activity failures that retrying cannot fix
+const (
+	// InvalidStateErrorType is returned when the bill or line item is not in a state the activity applies to
+	InvalidStateErrorType = "InvalidState"
+	// NotFoundErrorType is returned when the bill does not exist
+	NotFoundErrorType = "NotFound"
+	// InvalidArgumentErrorType is returned when the activity input is rejected
+	InvalidArgumentErrorType = "InvalidArgument"
+	// ConstraintViolationErrorType is returned when the database rejects the write with a constraint violation
+	ConstraintViolationErrorType = "ConstraintViolation"
+)
+
+// classifyError fails the activity without retries when retrying cannot fix the error,
+// e.g. a bill in another state or a constraint violation.
+// Other errors, e.g. connection failures, deadlocks or timeouts, are returned unchanged and retried.
+func classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errType, ok := nonRetryableErrorType(err); ok {
+		return temporal.NewNonRetryableApplicationError(err.Error(), errType, err)
+	}
+	return err
+}
+
+// nonRetryableErrorType returns the application error type of errors that retrying cannot fix
+func nonRetryableErrorType(err error) (string, bool) {
+	var appErr *temporal.ApplicationError
+	if errors.As(err, &appErr) {
+		// Already classified
+		return "", false
+	}
+
+	// Idempotent repository writes report a bill or line item in another state with no rows
+	if errors.Is(err, sql.ErrNoRows) {
+		return InvalidStateErrorType, true
+	}
+
+	var dbErr *sqldb.Error
+	if errors.As(err, &dbErr) {
+		switch dbErr.Code {
+		case sqlerr.NotNullViolation, sqlerr.ForeignKeyViolation, sqlerr.UniqueViolation,
+			sqlerr.CheckViolation, sqlerr.ExcludeViolation:
+			return ConstraintViolationErrorType, true
+		}
+		return "", false
+	}
+
+	var billingErr *errs.Error
+	if errors.As(err, &billingErr) {
+		switch billingErr.Code {
+		case errs.NotFound:
+			return NotFoundErrorType, true
+		case errs.InvalidArgument, errs.OutOfRange:
+			return InvalidArgumentErrorType, true
+		case errs.FailedPrecondition:
+			return InvalidStateErrorType, true
+		}
+	}
+	return "", false
+}
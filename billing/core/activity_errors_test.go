@@ -0,0 +1,60 @@
+package core
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"encore.app/billing/models"
+	"encore.dev/beta/errs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/temporal"
+)
+
+func TestClassifyError(t *testing.T) {
+	t.Run("when_error_is_nil_should_return_nil", func(t *testing.T) {
+		assert.NoError(t, classifyError(nil))
+	})
+
+	t.Run("when_retrying_cannot_fix_error_should_return_non_retryable_error", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			err      error
+			expected string
+		}{
+			{"no rows", fmt.Errorf("close bill: %w", sql.ErrNoRows), InvalidStateErrorType},
+			{"bill not found", models.ErrBillNotFound, NotFoundErrorType},
+			{"invalid transition", models.ErrInvalidBillTransition, InvalidStateErrorType},
+			{"invalid argument", models.ErrInvalidQuantity, InvalidArgumentErrorType},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				err := classifyError(tt.err)
+
+				var appErr *temporal.ApplicationError
+				require.ErrorAs(t, err, &appErr)
+				assert.True(t, appErr.NonRetryable())
+				assert.Equal(t, tt.expected, appErr.Type())
+				assert.ErrorIs(t, err, tt.err)
+			})
+		}
+	})
+
+	t.Run("when_error_is_transient_should_return_error_unchanged", func(t *testing.T) {
+		tests := []struct {
+			name string
+			err  error
+		}{
+			{"connection failure", errors.New("connection reset by peer")},
+			{"unavailable", &errs.Error{Code: errs.Unavailable, Message: "exchange rates unavailable"}},
+			{"already classified", temporal.NewApplicationError("rate limited", "RateLimited")},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assert.Equal(t, tt.err, classifyError(tt.err))
+			})
+		}
+	})
+}
@@ -0,0 +1,78 @@
+package core
+
+import (
+	"context"
+
+	"encore.app/billing/models"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/workflow"
+)
+
+// auditHeader is the activity header carrying the audit metadata
+const auditHeader = "billing-audit"
+
+type auditContextKey struct{}
+
+// requestAudit returns the audit metadata of the request to carry in signals, nil when the context has none
+func requestAudit(ctx context.Context) *models.AuditMetadata {
+	metadata := models.AuditMetadataFrom(ctx)
+	if metadata == (models.AuditMetadata{}) {
+		return nil
+	}
+	return &metadata
+}
+
+// withAudit returns a workflow context whose activities are attributed to the sender of the signal,
+// activities of a context without audit metadata are attributed to the system
+func withAudit(ctx workflow.Context, metadata *models.AuditMetadata) workflow.Context {
+	if metadata == nil {
+		return ctx
+	}
+	return workflow.WithValue(ctx, auditContextKey{}, *metadata)
+}
+
+// auditPropagator carries the audit metadata of workflow contexts to their activities,
+// adding the workflow execution that persists the mutation.
+// Requests reach workflows through signal data, so nothing is propagated from clients.
+type auditPropagator struct {
+	converter converter.DataConverter
+}
+
+// NewAuditPropagator creates the context propagator attributing activity mutations in the audit log
+func NewAuditPropagator() workflow.ContextPropagator {
+	return &auditPropagator{converter: converter.GetDefaultDataConverter()}
+}
+
+func (p *auditPropagator) Inject(ctx context.Context, writer workflow.HeaderWriter) error {
+	return nil
+}
+
+func (p *auditPropagator) ExtractToWorkflow(ctx workflow.Context, reader workflow.HeaderReader) (workflow.Context, error) {
+	return ctx, nil
+}
+
+func (p *auditPropagator) InjectFromWorkflow(ctx workflow.Context, writer workflow.HeaderWriter) error {
+	metadata, _ := ctx.Value(auditContextKey{}).(models.AuditMetadata)
+	execution := workflow.GetInfo(ctx).WorkflowExecution
+	metadata.WorkflowID = execution.ID
+	metadata.RunID = execution.RunID
+
+	payload, err := p.converter.ToPayload(metadata)
+	if err != nil {
+		return err
+	}
+	writer.Set(auditHeader, payload)
+	return nil
+}
+
+func (p *auditPropagator) Extract(ctx context.Context, reader workflow.HeaderReader) (context.Context, error) {
+	payload, ok := reader.Get(auditHeader)
+	if !ok {
+		return ctx, nil
+	}
+	var metadata models.AuditMetadata
+	if err := p.converter.FromPayload(payload, &metadata); err != nil {
+		return ctx, err
+	}
+	return models.WithAuditMetadata(ctx, metadata), nil
+}
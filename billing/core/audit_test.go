@@ -0,0 +1,118 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"encore.app/billing/models"
+	"encore.dev/types/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+func TestBillWorkflow_Audit(t *testing.T) {
+	newEnv := func() *testsuite.TestWorkflowEnvironment {
+		s := testsuite.WorkflowTestSuite{}
+		env := s.NewTestWorkflowEnvironment()
+		env.SetContextPropagators([]workflow.ContextPropagator{NewAuditPropagator()})
+		return env
+	}
+	newOpenBill := func(start time.Time) *models.Bill {
+		return &models.Bill{
+			ID:          uuid.Must(uuid.NewV4()),
+			CustomerID:  "cust-1",
+			Status:      models.BillStatusOpen,
+			PeriodStart: start,
+			PeriodEnd:   start.Add(time.Hour),
+		}
+	}
+
+	t.Run("should_attribute_signaled_mutations_to_sender_and_others_to_system", func(t *testing.T) {
+		env := newEnv()
+		w := NewBillWorkflows(testCfg())
+
+		start := time.Now()
+		env.SetStartTime(start)
+		bill := newOpenBill(start)
+		item := models.LineItem{
+			ID:          uuid.Must(uuid.NewV4()),
+			BillID:      bill.ID,
+			Description: "API calls",
+			Quantity:    decimal.NewFromInt(10),
+			UnitPrice:   decimal.NewFromInt(2),
+			Currency:    "USD",
+			OccurredAt:  start.Add(time.Minute),
+		}
+		sender := &models.AuditMetadata{Actor: "admin", RequestID: "req-1"}
+
+		var added, closed models.AuditMetadata
+		env.OnActivity((&BillingActivities{}).AddLineItemToBill, mock.Anything, mock.Anything).
+			Run(func(args mock.Arguments) { added = models.AuditMetadataFrom(args.Get(0).(context.Context)) }).
+			Return(nil).Once()
+		env.OnActivity((&BillingActivities{}).CloseBill, mock.Anything, mock.Anything).
+			Run(func(args mock.Arguments) { closed = models.AuditMetadataFrom(args.Get(0).(context.Context)) }).
+			Return(&models.Bill{}, nil).Once()
+
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(AddLineItemSignal, LineItemSignalData{LineItem: item, Audit: sender})
+		}, time.Minute)
+
+		env.ExecuteWorkflow(w.CreateBill, BillWorkflowInput{
+			Bill:      bill,
+			Continued: &ContinuedBillState{LineTotals: []models.LineTotalGroup{}},
+		})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+		env.AssertExpectations(t)
+
+		assert.Equal(t, "admin", added.Actor)
+		assert.Equal(t, "req-1", added.RequestID)
+		assert.NotEmpty(t, added.WorkflowID)
+		assert.NotEmpty(t, added.RunID)
+
+		// Closed at period end without a request
+		assert.Empty(t, closed.Actor)
+		assert.Empty(t, closed.RequestID)
+		assert.Equal(t, added.WorkflowID, closed.WorkflowID)
+		assert.Equal(t, added.RunID, closed.RunID)
+	})
+
+	t.Run("should_attribute_closing_to_sender_of_close_signal", func(t *testing.T) {
+		env := newEnv()
+		w := NewBillWorkflows(testCfg())
+
+		start := time.Now()
+		env.SetStartTime(start)
+		bill := newOpenBill(start)
+
+		var closed models.AuditMetadata
+		env.OnActivity((&BillingActivities{}).CloseBill, mock.Anything, mock.Anything).
+			Run(func(args mock.Arguments) { closed = models.AuditMetadataFrom(args.Get(0).(context.Context)) }).
+			Return(&models.Bill{}, nil).Once()
+
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(CloseBillSignal, CloseBillSignalData{
+				RequestedAt: start.Add(time.Minute),
+				Audit:       &models.AuditMetadata{Actor: "admin", RequestID: "req-2"},
+			})
+		}, time.Minute)
+
+		env.ExecuteWorkflow(w.CreateBill, BillWorkflowInput{
+			Bill:      bill,
+			Continued: &ContinuedBillState{LineTotals: []models.LineTotalGroup{}},
+		})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+		env.AssertExpectations(t)
+
+		assert.Equal(t, "admin", closed.Actor)
+		assert.Equal(t, "req-2", closed.RequestID)
+		assert.NotEmpty(t, closed.WorkflowID)
+	})
+}
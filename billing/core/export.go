@@ -0,0 +1,208 @@
+package core
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"encore.app/billing/export"
+	"encore.app/billing/ext_services"
+	"encore.app/billing/models"
+	"encore.app/billing/repository"
+	"encore.dev/rlog"
+	"encore.dev/types/uuid"
+	"go.temporal.io/sdk/workflow"
+)
+
+type ExportInput struct {
+	JobID uuid.UUID `json:"job_id"`
+}
+
+type GenerateExportInput struct {
+	JobID     uuid.UUID `json:"job_id"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+type CompleteExportInput struct {
+	JobID       uuid.UUID `json:"job_id"`
+	Rows        int       `json:"rows"`
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+type FailExportInput struct {
+	JobID    uuid.UUID `json:"job_id"`
+	Error    string    `json:"error"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// RunExport generates the file of an export job in the exports bucket, then completes the job,
+// or fails it when the file cannot be generated
+func (w *BillWorkflows) RunExport(ctx workflow.Context, input ExportInput) error {
+	logger := workflow.GetLogger(ctx)
+	logger.Info("Starting export", "export_id", input.JobID)
+
+	activityCtx := workflow.WithActivityOptions(ctx, getDefaultActivityOptions(w.cfg))
+	exportCtx := workflow.WithActivityOptions(ctx, getActivityOptions(w.cfg,
+		time.Duration(w.cfg.Temporal.ExportActivityTimeout())*time.Second))
+
+	var rows int
+	err := workflow.ExecuteActivity(exportCtx, (&ExportActivities{}).GenerateExport, GenerateExportInput{
+		JobID:     input.JobID,
+		StartedAt: workflow.Now(ctx),
+	}).Get(ctx, &rows)
+	if err != nil {
+		logger.Error("Failed to generate export", "export_id", input.JobID, "error", err)
+		if failErr := workflow.ExecuteActivity(activityCtx, (&ExportActivities{}).FailExport, FailExportInput{
+			JobID:    input.JobID,
+			Error:    err.Error(),
+			FailedAt: workflow.Now(ctx),
+		}).Get(ctx, nil); failErr != nil {
+			logger.Error("Failed to mark export failed", "export_id", input.JobID, "error", failErr)
+		}
+		return err
+	}
+
+	if err = workflow.ExecuteActivity(activityCtx, (&ExportActivities{}).CompleteExport, CompleteExportInput{
+		JobID:       input.JobID,
+		Rows:        rows,
+		CompletedAt: workflow.Now(ctx),
+	}).Get(ctx, nil); err != nil {
+		logger.Error("Failed to complete export", "export_id", input.JobID, "error", err)
+		return err
+	}
+
+	logger.Info("Export completed", "export_id", input.JobID, "rows", rows)
+	return nil
+}
+
+func NewExportActivities(
+	repository repository.Repository, storage ext_services.ExportStorage, cfg *models.AppConfig,
+) *ExportActivities {
+	return &ExportActivities{
+		repository: repository,
+		storage:    storage,
+		cfg:        cfg,
+	}
+}
+
+// ExportActivities write exports to object storage, so unlike BillingActivities they need the export storage
+type ExportActivities struct {
+	repository repository.Repository
+	storage    ext_services.ExportStorage
+	cfg        *models.AppConfig
+}
+
+// GenerateExport starts the export job and uploads its file, returning the number of rows exported.
+// A retried attempt uploads the file again under the same key.
+func (a *ExportActivities) GenerateExport(ctx context.Context, input GenerateExportInput) (int, error) {
+	logger := rlog.With("module", "billing_activities").With("export_id", input.JobID)
+	logger.Info("Generating export")
+
+	if err := a.repository.MarkExportJobRunning(ctx, input.JobID, input.StartedAt); err != nil {
+		logger.Error("Failed to start export job", "error", err)
+		return 0, classifyError(err)
+	}
+	job, err := a.repository.GetExportJob(ctx, input.JobID)
+	if err != nil {
+		logger.Error("Failed to get export job", "error", err)
+		return 0, classifyError(err)
+	}
+
+	var rows int
+	err = a.storage.Upload(ctx, job.ObjectKey, job.Format.ContentType(), func(w io.Writer) error {
+		var err error
+		if job.Format.Journals() {
+			rows, err = a.writeJournals(ctx, job, w)
+		} else {
+			rows, err = a.writeBills(ctx, job, w)
+		}
+		return err
+	})
+	if err != nil {
+		logger.Error("Failed to upload export", "error", err)
+		return 0, classifyError(err)
+	}
+
+	logger.Info("Export generated", "format", job.Format, "rows", rows)
+	return rows, nil
+}
+
+// writeBills writes the bills closed in the range of the job, reading them in batches
+func (a *ExportActivities) writeBills(ctx context.Context, job *models.ExportJob, w io.Writer) (int, error) {
+	bw, err := export.NewBillWriter(job.Format, w)
+	if err != nil {
+		return 0, err
+	}
+
+	limit := a.cfg.Billing.Export.BatchSize()
+	opts := models.GetBillOptions{IncludeLineItems: job.Format != models.ExportBillsCSV}
+	var after uuid.UUID
+	for {
+		bills, err := a.repository.ListClosedBills(ctx, job.From, job.To, after, limit)
+		if err != nil {
+			return 0, err
+		}
+		for _, summary := range bills {
+			// Summaries exclude totals and line items
+			bill, err := a.repository.GetBillByID(ctx, summary.ID, opts)
+			if err != nil {
+				return 0, err
+			}
+			if err = bw.WriteBill(bill); err != nil {
+				return 0, err
+			}
+			after = summary.ID
+		}
+		if len(bills) < limit {
+			break
+		}
+	}
+	return bw.Close()
+}
+
+// writeJournals writes the journals posted in the range of the job
+func (a *ExportActivities) writeJournals(ctx context.Context, job *models.ExportJob, w io.Writer) (int, error) {
+	jw, err := export.NewJournalWriter(job.Format, w)
+	if err != nil {
+		return 0, err
+	}
+
+	journals, err := a.repository.ListJournals(ctx, job.From, job.To)
+	if err != nil {
+		return 0, err
+	}
+	for _, journal := range journals {
+		if err = jw.WriteJournal(journal); err != nil {
+			return 0, err
+		}
+	}
+	return jw.Close()
+}
+
+// CompleteExport completes the export job with the number of rows exported
+func (a *ExportActivities) CompleteExport(ctx context.Context, input CompleteExportInput) error {
+	logger := rlog.With("module", "billing_activities").With("export_id", input.JobID)
+	logger.Info("Completing export", "rows", input.Rows)
+
+	if err := a.repository.CompleteExportJob(ctx, input.JobID, input.Rows, input.CompletedAt); err != nil {
+		logger.Error("Failed to complete export job", "error", err)
+		return classifyError(err)
+	}
+
+	logger.Info("Export job completed")
+	return nil
+}
+
+// FailExport fails the export job with the error of its generation
+func (a *ExportActivities) FailExport(ctx context.Context, input FailExportInput) error {
+	logger := rlog.With("module", "billing_activities").With("export_id", input.JobID)
+	logger.Info("Failing export", "error", input.Error)
+
+	if err := a.repository.FailExportJob(ctx, input.JobID, input.Error, input.FailedAt); err != nil {
+		logger.Error("Failed to fail export job", "error", err)
+		return classifyError(err)
+	}
+
+	logger.Info("Export job failed")
+	return nil
+}
@@ -0,0 +1,186 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"encore.app/billing/ext_services/mocks"
+	"encore.app/billing/models"
+	"encore.app/billing/repository/repositorytest"
+	"encore.dev/types/uuid"
+	"github.com/golang/mock/gomock"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestExportWorkflow(t *testing.T) {
+	jobID := uuid.Must(uuid.NewV4())
+
+	t.Run("should_generate_then_complete_export", func(t *testing.T) {
+		s := testsuite.WorkflowTestSuite{}
+		env := s.NewTestWorkflowEnvironment()
+		w := NewBillWorkflows(testCfg())
+
+		env.OnActivity((&ExportActivities{}).GenerateExport, mock.Anything, mock.Anything).
+			Return(func(_ context.Context, input GenerateExportInput) (int, error) {
+				assert.Equal(t, jobID, input.JobID)
+				return 42, nil
+			}).Once()
+		env.OnActivity((&ExportActivities{}).CompleteExport, mock.Anything, mock.Anything).
+			Return(func(_ context.Context, input CompleteExportInput) error {
+				assert.Equal(t, jobID, input.JobID)
+				assert.Equal(t, 42, input.Rows)
+				return nil
+			}).Once()
+
+		env.ExecuteWorkflow(w.RunExport, ExportInput{JobID: jobID})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+		env.AssertExpectations(t)
+	})
+
+	t.Run("when_generation_fails_should_fail_export_and_return_error", func(t *testing.T) {
+		s := testsuite.WorkflowTestSuite{}
+		env := s.NewTestWorkflowEnvironment()
+		w := NewBillWorkflows(testCfg())
+
+		env.OnActivity((&ExportActivities{}).GenerateExport, mock.Anything, mock.Anything).
+			Return(0, errors.New("bucket unavailable"))
+		var failed FailExportInput
+		env.OnActivity((&ExportActivities{}).FailExport, mock.Anything, mock.Anything).
+			Return(func(_ context.Context, input FailExportInput) error {
+				failed = input
+				return nil
+			}).Once()
+
+		env.ExecuteWorkflow(w.RunExport, ExportInput{JobID: jobID})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.Error(t, env.GetWorkflowError())
+		assert.Equal(t, jobID, failed.JobID)
+		assert.Contains(t, failed.Error, "bucket unavailable")
+		env.AssertNotCalled(t, "CompleteExport", mock.Anything, mock.Anything)
+	})
+}
+
+func TestExportActivities_GenerateExport(t *testing.T) {
+	from := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	newClosedBill := func(t *testing.T, fakeRepo *repositorytest.FakeRepo, closedAt time.Time) *models.Bill {
+		bill := &models.Bill{
+			ID:       uuid.Must(uuid.NewV4()),
+			Status:   models.BillStatusClosed,
+			ClosedAt: &closedAt,
+			Total: &models.Total{
+				ByCurrency: map[models.Currency]decimal.Decimal{models.USD: decimal.NewFromInt(10)},
+			},
+		}
+		require.NoError(t, fakeRepo.CreateBill(context.TODO(), bill))
+		return bill
+	}
+	newJob := func(t *testing.T, fakeRepo *repositorytest.FakeRepo, format models.ExportFormat) *models.ExportJob {
+		job, err := models.NewExportJob(format, from, to, time.Now())
+		require.NoError(t, err)
+		require.NoError(t, fakeRepo.CreateExportJob(context.TODO(), job))
+		return job
+	}
+	// uploadTo writes the uploaded export to buf
+	uploadTo := func(buf *bytes.Buffer) func(context.Context, string, string, func(io.Writer) error) error {
+		return func(_ context.Context, _ string, _ string, write func(io.Writer) error) error {
+			return write(buf)
+		}
+	}
+
+	t.Run("should_upload_bills_closed_in_range_in_batches", func(t *testing.T) {
+		fakeRepo := &repositorytest.FakeRepo{}
+		storage := mocks.NewMockExportStorage(gomock.NewController(t))
+		activities := NewExportActivities(fakeRepo, storage, testCfg())
+		for i := 0; i < 3; i++ {
+			newClosedBill(t, fakeRepo, from.Add(time.Duration(i)*time.Hour))
+		}
+		newClosedBill(t, fakeRepo, to)
+		job := newJob(t, fakeRepo, models.ExportTotalsCSV)
+
+		var buf bytes.Buffer
+		storage.EXPECT().Upload(gomock.Any(), job.ObjectKey, "text/csv", gomock.Any()).DoAndReturn(uploadTo(&buf))
+
+		rows, err := activities.GenerateExport(context.TODO(), GenerateExportInput{JobID: job.ID, StartedAt: time.Now()})
+
+		require.NoError(t, err)
+		assert.Equal(t, 3, rows)
+		assert.Len(t, strings.Split(strings.TrimSpace(buf.String()), "\n"), 4)
+		running, err := fakeRepo.GetExportJob(context.TODO(), job.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.ExportStatusRunning, running.Status)
+	})
+
+	t.Run("should_upload_journals_posted_in_range", func(t *testing.T) {
+		fakeRepo := &repositorytest.FakeRepo{}
+		storage := mocks.NewMockExportStorage(gomock.NewController(t))
+		activities := NewExportActivities(fakeRepo, storage, testCfg())
+		settings, err := models.LedgerSettingsFromConfig(&models.AppConfig{Billing: models.BillingConfig{
+			Rounding: models.RoundingConfig{Mode: func() string { return "half_up" }, Level: func() string { return "total" }},
+			Ledger:   testLedgerConfig(),
+			Invoice:  testInvoiceTaxConfig(),
+		}})
+		require.NoError(t, err)
+		for _, closedAt := range []time.Time{from, to} {
+			bill := &models.Bill{ID: uuid.Must(uuid.NewV4()), Status: models.BillStatusOpen}
+			require.NoError(t, fakeRepo.CreateBill(context.TODO(), bill))
+			closing := &models.Bill{ID: bill.ID, Total: &models.Total{
+				ByCurrency: map[models.Currency]decimal.Decimal{models.USD: decimal.NewFromInt(10)},
+			}}
+			journal, err := models.NewBillClosedJournal(closing, closedAt, &models.RatesData{Rates: map[string]float64{"USD": 1}}, settings)
+			require.NoError(t, err)
+			require.NoError(t, fakeRepo.CloseBill(context.TODO(), closing, closedAt, journal, nil))
+		}
+		job := newJob(t, fakeRepo, models.ExportQuickBooksIIF)
+
+		var buf bytes.Buffer
+		storage.EXPECT().Upload(gomock.Any(), job.ObjectKey, "application/x-iif", gomock.Any()).DoAndReturn(uploadTo(&buf))
+
+		rows, err := activities.GenerateExport(context.TODO(), GenerateExportInput{JobID: job.ID, StartedAt: time.Now()})
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, rows)
+		assert.Equal(t, 1, strings.Count(buf.String(), "\r\nTRNS\t"))
+		assert.Contains(t, buf.String(), "03/01/2025")
+	})
+
+	t.Run("when_upload_fails_should_return_error", func(t *testing.T) {
+		fakeRepo := &repositorytest.FakeRepo{}
+		storage := mocks.NewMockExportStorage(gomock.NewController(t))
+		activities := NewExportActivities(fakeRepo, storage, testCfg())
+		job := newJob(t, fakeRepo, models.ExportBillsCSV)
+
+		storage.EXPECT().Upload(gomock.Any(), job.ObjectKey, gomock.Any(), gomock.Any()).Return(errors.New("bucket unavailable"))
+
+		_, err := activities.GenerateExport(context.TODO(), GenerateExportInput{JobID: job.ID, StartedAt: time.Now()})
+
+		assert.Error(t, err)
+	})
+
+	t.Run("when_export_is_finished_should_fail_without_retries", func(t *testing.T) {
+		fakeRepo := &repositorytest.FakeRepo{}
+		activities := NewExportActivities(fakeRepo, mocks.NewMockExportStorage(gomock.NewController(t)), testCfg())
+		job := newJob(t, fakeRepo, models.ExportBillsCSV)
+		require.NoError(t, fakeRepo.FailExportJob(context.TODO(), job.ID, "failed to start export workflow", time.Now()))
+
+		_, err := activities.GenerateExport(context.TODO(), GenerateExportInput{JobID: job.ID, StartedAt: time.Now()})
+
+		var appErr *temporal.ApplicationError
+		require.ErrorAs(t, err, &appErr)
+		assert.True(t, appErr.NonRetryable())
+		assert.Equal(t, InvalidStateErrorType, appErr.Type())
+	})
+}
@@ -0,0 +1,306 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"encore.app/billing/models"
+	"encore.dev/rlog"
+	"encore.dev/types/uuid"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+const (
+	// RetryFailedOperationSignal executes a pending failed operation of the bill again
+	RetryFailedOperationSignal = "RetryFailedOperationSignal"
+	// AcknowledgeFailedOperationSignal accepts that a pending failed operation of the bill is not persisted
+	AcknowledgeFailedOperationSignal = "AcknowledgeFailedOperationSignal"
+
+	// FailedOperationRetryWorkflowIDPrefix prefixes the ID of the workflows retrying failed operations
+	// whose bill workflow is no longer running
+	FailedOperationRetryWorkflowIDPrefix = "failed-operation-retry-"
+)
+
+type RetryFailedOperationSignalData struct {
+	ID uuid.UUID `json:"id"`
+	// Audit attributes the mutation persisted by the retry to the request that sent the signal
+	Audit *models.AuditMetadata `json:"audit,omitempty"`
+}
+
+type AcknowledgeFailedOperationSignalData struct {
+	ID          uuid.UUID `json:"id"`
+	Note        string    `json:"note"`
+	RequestedAt time.Time `json:"requested_at"`
+}
+
+type ResolveFailedOperationInput struct {
+	ID         uuid.UUID                    `json:"id"`
+	Status     models.FailedOperationStatus `json:"status"`
+	Note       string                       `json:"note"`
+	ResolvedAt time.Time                    `json:"resolved_at"`
+}
+
+// RetryFailedOperationInput is the input of the workflow retrying a failed operation from its database record
+type RetryFailedOperationInput struct {
+	Operation *models.FailedOperation `json:"operation"`
+	Audit     *models.AuditMetadata   `json:"audit,omitempty"`
+}
+
+// RetryFailedOperation executes a pending failed operation whose bill workflow is no longer running.
+// The operation is resolved when it succeeds, otherwise the failed attempt is recorded and it stays pending.
+func (w *BillWorkflows) RetryFailedOperation(ctx workflow.Context, input RetryFailedOperationInput) error {
+	op := input.Operation
+	workflow.GetLogger(ctx).Info("Retrying failed operation of bill workflow that is no longer running",
+		"failed_operation_id", op.ID, "bill_id", op.BillID, "bill_workflow_id", op.WorkflowID)
+
+	persistence := newBillPersistence(w.cfg, op.BillID, true, []*models.FailedOperation{op})
+	persistence.retry(withAudit(ctx, input.Audit), RetryFailedOperationSignalData{ID: op.ID, Audit: input.Audit})
+	return nil
+}
+
+// billPersistence executes the persistence activities of a bill workflow.
+// With dead-lettering enabled, operations exhausting their retries are recorded as failed operations
+// and kept pending until a retry succeeds or an operator acknowledges them.
+type billPersistence struct {
+	cfg        *models.AppConfig
+	billID     uuid.UUID
+	deadLetter bool
+	pending    []*models.FailedOperation
+}
+
+func newBillPersistence(
+	cfg *models.AppConfig, billID uuid.UUID, deadLetter bool, pending []*models.FailedOperation,
+) *billPersistence {
+	return &billPersistence{
+		cfg:        cfg,
+		billID:     billID,
+		deadLetter: deadLetter,
+		pending:    pending,
+	}
+}
+
+// hasPending reports whether failed operations are neither resolved nor acknowledged
+func (p *billPersistence) hasPending() bool {
+	return len(p.pending) > 0
+}
+
+// persist executes the activity of the operation and records it as a failed operation when it fails
+func (p *billPersistence) persist(ctx workflow.Context, operation models.FailedOperationType, input interface{}) error {
+	err := p.execute(ctx, operation, input)
+	if err != nil && p.deadLetter {
+		p.record(ctx, operation, input, err)
+	}
+	return err
+}
+
+// execute executes the activity of the operation
+func (p *billPersistence) execute(ctx workflow.Context, operation models.FailedOperationType, input interface{}) error {
+	options := getDefaultActivityOptions(p.cfg)
+	var activity interface{}
+	switch operation {
+	case models.FailedOperationAddLineItem:
+		activity = (&BillingActivities{}).AddLineItemToBill
+	case models.FailedOperationUpdateLineItem:
+		activity = (&BillingActivities{}).UpdateLineItem
+	case models.FailedOperationRemoveLineItem:
+		activity = (&BillingActivities{}).RemoveLineItem
+	case models.FailedOperationMarkBillClosing:
+		activity = (&BillingActivities{}).MarkBillClosing
+	case models.FailedOperationCloseBill:
+		activity = (&BillingActivities{}).CloseBill
+		options = getActivityOptions(p.cfg, time.Duration(p.cfg.Temporal.CloseBillActivityTimeout())*time.Second)
+	case models.FailedOperationVoidBill:
+		activity = (&BillingActivities{}).VoidBill
+	default:
+		return fmt.Errorf("unsupported failed operation %q", operation)
+	}
+
+	activityCtx := workflow.WithActivityOptions(ctx, options)
+	return workflow.ExecuteActivity(activityCtx, activity, input).Get(ctx, nil)
+}
+
+// record saves the operation that exhausted its retries and keeps it pending
+func (p *billPersistence) record(ctx workflow.Context, operation models.FailedOperationType, input interface{}, cause error) {
+	logger := workflow.GetLogger(ctx)
+
+	payload, err := json.Marshal(input)
+	if err != nil {
+		logger.Error("Failed to encode failed operation payload", "operation", operation, "error", err)
+		return
+	}
+	op := &models.FailedOperation{
+		BillID:     p.billID,
+		WorkflowID: workflow.GetInfo(ctx).WorkflowExecution.ID,
+		Operation:  operation,
+		Payload:    payload,
+		Status:     models.FailedOperationStatusPending,
+		Attempts:   []models.FailedOperationAttempt{},
+		CreatedAt:  workflow.Now(ctx),
+	}
+	if err := workflow.SideEffect(ctx, func(ctx workflow.Context) interface{} {
+		return uuid.Must(uuid.NewV4())
+	}).Get(&op.ID); err != nil {
+		logger.Error("Failed to generate failed operation ID", "error", err)
+		return
+	}
+	op.AddAttempt(cause.Error(), activityErrorType(cause), op.CreatedAt)
+
+	logger.Warn("Operation exhausted its retries, recording failed operation",
+		"operation", operation, "failed_operation_id", op.ID)
+	p.pending = append(p.pending, op)
+	p.save(ctx, op)
+}
+
+// retry executes the pending failed operation again and resolves it when it succeeds
+func (p *billPersistence) retry(ctx workflow.Context, signal RetryFailedOperationSignalData) {
+	logger := workflow.GetLogger(ctx)
+	op := p.find(signal.ID)
+	if op == nil {
+		logger.Warn("Failed operation is not pending, ignoring retry signal", "failed_operation_id", signal.ID)
+		return
+	}
+	logger.Info("Retrying failed operation", "failed_operation_id", op.ID, "operation", op.Operation)
+
+	input, err := decodeFailedOperationInput(op)
+	if err == nil {
+		err = p.execute(ctx, op.Operation, input)
+	}
+	if err != nil {
+		logger.Error("Failed operation retry failed", "failed_operation_id", op.ID, "error", err)
+		op.AddAttempt(err.Error(), activityErrorType(err), workflow.Now(ctx))
+		p.save(ctx, op)
+		return
+	}
+
+	p.resolve(ctx, op, models.FailedOperationStatusResolved, "", workflow.Now(ctx))
+}
+
+// acknowledge resolves the pending failed operation without persisting it
+func (p *billPersistence) acknowledge(ctx workflow.Context, signal AcknowledgeFailedOperationSignalData) {
+	op := p.find(signal.ID)
+	if op == nil {
+		workflow.GetLogger(ctx).Warn("Failed operation is not pending, ignoring acknowledge signal",
+			"failed_operation_id", signal.ID)
+		return
+	}
+	workflow.GetLogger(ctx).Info("Acknowledging failed operation", "failed_operation_id", op.ID, "note", signal.Note)
+
+	p.resolve(ctx, op, models.FailedOperationStatusAcknowledged, signal.Note, signal.RequestedAt)
+}
+
+// resolve persists the resolution and stops waiting for the operation.
+// The operation stays pending when the resolution cannot be saved, so it can be retried or acknowledged again.
+func (p *billPersistence) resolve(
+	ctx workflow.Context, op *models.FailedOperation, status models.FailedOperationStatus, note string, resolvedAt time.Time,
+) {
+	input := ResolveFailedOperationInput{
+		ID:         op.ID,
+		Status:     status,
+		Note:       note,
+		ResolvedAt: resolvedAt,
+	}
+	activityCtx := workflow.WithActivityOptions(ctx, getDefaultActivityOptions(p.cfg))
+	if err := workflow.ExecuteActivity(
+		activityCtx, (&BillingActivities{}).ResolveFailedOperation, input,
+	).Get(ctx, nil); err != nil {
+		workflow.GetLogger(ctx).Error("Failed to resolve failed operation", "failed_operation_id", op.ID, "error", err)
+		return
+	}
+
+	p.pending = slices.DeleteFunc(p.pending, func(pending *models.FailedOperation) bool {
+		return pending.ID == op.ID
+	})
+}
+
+// save persists the failed operation with its attempts
+func (p *billPersistence) save(ctx workflow.Context, op *models.FailedOperation) {
+	activityCtx := workflow.WithActivityOptions(ctx, getDefaultActivityOptions(p.cfg))
+	if err := workflow.ExecuteActivity(
+		activityCtx, (&BillingActivities{}).RecordFailedOperation, op,
+	).Get(ctx, nil); err != nil {
+		workflow.GetLogger(ctx).Error("Failed to record failed operation", "failed_operation_id", op.ID, "error", err)
+	}
+}
+
+func (p *billPersistence) find(id uuid.UUID) *models.FailedOperation {
+	for _, op := range p.pending {
+		if op.ID == id {
+			return op
+		}
+	}
+	return nil
+}
+
+// decodeFailedOperationInput decodes the payload into the input of the operation's activity
+func decodeFailedOperationInput(op *models.FailedOperation) (interface{}, error) {
+	switch op.Operation {
+	case models.FailedOperationAddLineItem, models.FailedOperationUpdateLineItem:
+		return decodePayload[models.LineItem](op.Payload)
+	case models.FailedOperationRemoveLineItem:
+		return decodePayload[RemoveLineItemInput](op.Payload)
+	case models.FailedOperationMarkBillClosing:
+		return decodePayload[MarkBillClosingInput](op.Payload)
+	case models.FailedOperationCloseBill:
+		return decodePayload[CloseBillInput](op.Payload)
+	case models.FailedOperationVoidBill:
+		return decodePayload[VoidBillInput](op.Payload)
+	}
+	return nil, fmt.Errorf("unsupported failed operation %q", op.Operation)
+}
+
+// decodePayload decodes the payload by value, activities are type checked against their parameters
+func decodePayload[T any](payload json.RawMessage) (interface{}, error) {
+	var input T
+	if err := json.Unmarshal(payload, &input); err != nil {
+		return nil, fmt.Errorf("decode failed operation payload: %w", err)
+	}
+	return input, nil
+}
+
+// activityErrorType returns the application error type or the timeout type of the activity failure
+func activityErrorType(err error) string {
+	var appErr *temporal.ApplicationError
+	if errors.As(err, &appErr) {
+		return appErr.Type()
+	}
+	var timeoutErr *temporal.TimeoutError
+	if errors.As(err, &timeoutErr) {
+		return timeoutErr.TimeoutType().String()
+	}
+	return ""
+}
+
+// RecordFailedOperation saves the failed operation, updating its attempts when already saved
+func (a *BillingActivities) RecordFailedOperation(ctx context.Context, op *models.FailedOperation) error {
+	logger := rlog.With("module", "billing_activities")
+	logger.Info("Recording failed operation", "failed_operation_id", op.ID, "operation", op.Operation, "bill_id", op.BillID)
+
+	if err := a.repository.SaveFailedOperation(ctx, op); err != nil {
+		logger.Error("Failed to record failed operation", "error", err)
+		return classifyError(err)
+	}
+
+	logger.Info("Failed operation recorded successfully", "failed_operation_id", op.ID)
+	return nil
+}
+
+// ResolveFailedOperation marks the failed operation as resolved or acknowledged,
+// succeeding when a previous attempt resolved it
+func (a *BillingActivities) ResolveFailedOperation(ctx context.Context, input ResolveFailedOperationInput) error {
+	logger := rlog.With("module", "billing_activities")
+	logger.Info("Resolving failed operation", "failed_operation_id", input.ID, "status", input.Status)
+
+	err := a.repository.ResolveFailedOperation(ctx, input.ID, input.Status, input.Note, input.ResolvedAt)
+	if err != nil {
+		logger.Error("Failed to resolve failed operation", "error", err)
+		return classifyError(err)
+	}
+
+	logger.Info("Failed operation resolved successfully", "failed_operation_id", input.ID)
+	return nil
+}
@@ -0,0 +1,336 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"encore.app/billing/models"
+	"encore.app/billing/repository/repositorytest"
+	"encore.dev/types/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+func TestBillWorkflow_FailedOperations(t *testing.T) {
+	newLineItem := func(billID uuid.UUID, start time.Time) models.LineItem {
+		return models.LineItem{
+			ID:          uuid.Must(uuid.NewV4()),
+			BillID:      billID,
+			Description: "API calls",
+			Quantity:    decimal.NewFromInt(10),
+			UnitPrice:   decimal.NewFromInt(2),
+			Currency:    "USD",
+			OccurredAt:  start.Add(time.Minute),
+		}
+	}
+	newOpenBill := func(start time.Time) *models.Bill {
+		return &models.Bill{
+			ID:          uuid.Must(uuid.NewV4()),
+			CustomerID:  "cust-1",
+			Status:      models.BillStatusOpen,
+			PeriodStart: start,
+			PeriodEnd:   start.Add(time.Hour),
+		}
+	}
+	failed := temporal.NewNonRetryableApplicationError("invalid quantity", InvalidArgumentErrorType, nil)
+
+	t.Run("when_operation_exhausts_retries_should_record_it_and_complete_once_retried", func(t *testing.T) {
+		s := testsuite.WorkflowTestSuite{}
+		env := s.NewTestWorkflowEnvironment()
+		w := NewBillWorkflows(testCfg())
+
+		start := time.Now()
+		env.SetStartTime(start)
+		bill := newOpenBill(start)
+		item := newLineItem(bill.ID, start)
+
+		env.OnActivity((&BillingActivities{}).AddLineItemToBill, mock.Anything, mock.Anything).
+			Return(failed).Once()
+		env.OnActivity((&BillingActivities{}).AddLineItemToBill, mock.Anything, mock.Anything).
+			Return(nil).Once()
+		var failedOp models.FailedOperation
+		env.OnActivity((&BillingActivities{}).RecordFailedOperation, mock.Anything, mock.Anything).
+			Run(func(args mock.Arguments) { failedOp = *args.Get(1).(*models.FailedOperation) }).
+			Return(nil).Once()
+		env.OnActivity((&BillingActivities{}).CloseBill, mock.Anything, mock.Anything).
+			Return(&models.Bill{}, nil).Once()
+		env.OnActivity((&BillingActivities{}).ResolveFailedOperation, mock.Anything,
+			mock.MatchedBy(func(input ResolveFailedOperationInput) bool {
+				return input.ID == failedOp.ID && input.Status == models.FailedOperationStatusResolved
+			})).Return(nil).Once()
+
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(AddLineItemSignal, LineItemSignalData{LineItem: item})
+		}, time.Minute)
+		env.RegisterDelayedCallback(func() {
+			// Closed at period end, waiting for the failed operation
+			assert.False(t, env.IsWorkflowCompleted())
+			env.SignalWorkflow(RetryFailedOperationSignal, RetryFailedOperationSignalData{ID: failedOp.ID})
+		}, 2*time.Hour)
+
+		env.ExecuteWorkflow(w.CreateBill, BillWorkflowInput{
+			Bill:      bill,
+			Continued: &ContinuedBillState{LineTotals: []models.LineTotalGroup{}},
+		})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+		env.AssertExpectations(t)
+
+		assert.Equal(t, bill.ID, failedOp.BillID)
+		assert.Equal(t, models.FailedOperationAddLineItem, failedOp.Operation)
+		assert.Equal(t, InvalidArgumentErrorType, failedOp.Attempts[0].ErrorType)
+		var payload models.LineItem
+		require.NoError(t, json.Unmarshal(failedOp.Payload, &payload))
+		assert.Equal(t, item.ID, payload.ID)
+	})
+
+	t.Run("when_retry_fails_should_record_attempt_and_complete_once_acknowledged", func(t *testing.T) {
+		s := testsuite.WorkflowTestSuite{}
+		env := s.NewTestWorkflowEnvironment()
+		w := NewBillWorkflows(testCfg())
+
+		start := time.Now()
+		env.SetStartTime(start)
+		bill := newOpenBill(start)
+		acknowledgedAt := start.Add(3 * time.Hour)
+
+		env.OnActivity((&BillingActivities{}).VoidBill, mock.Anything, mock.Anything).Return(failed).Twice()
+		var saved []models.FailedOperation
+		env.OnActivity((&BillingActivities{}).RecordFailedOperation, mock.Anything, mock.Anything).
+			Run(func(args mock.Arguments) { saved = append(saved, *args.Get(1).(*models.FailedOperation)) }).
+			Return(nil).Twice()
+		env.OnActivity((&BillingActivities{}).ResolveFailedOperation, mock.Anything,
+			mock.MatchedBy(func(input ResolveFailedOperationInput) bool {
+				return input.Status == models.FailedOperationStatusAcknowledged &&
+					input.Note == "voided in the ledger" && input.ResolvedAt.Equal(acknowledgedAt)
+			})).Return(nil).Once()
+
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(VoidBillSignal, VoidBillSignalData{Reason: "duplicate", RequestedAt: start.Add(time.Minute)})
+		}, time.Minute)
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(RetryFailedOperationSignal, RetryFailedOperationSignalData{ID: saved[0].ID})
+		}, 2*time.Hour)
+		env.RegisterDelayedCallback(func() {
+			assert.False(t, env.IsWorkflowCompleted())
+			env.SignalWorkflow(AcknowledgeFailedOperationSignal, AcknowledgeFailedOperationSignalData{
+				ID:          saved[0].ID,
+				Note:        "voided in the ledger",
+				RequestedAt: acknowledgedAt,
+			})
+		}, 3*time.Hour)
+
+		env.ExecuteWorkflow(w.CreateBill, BillWorkflowInput{
+			Bill:      bill,
+			Continued: &ContinuedBillState{LineTotals: []models.LineTotalGroup{}},
+		})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+		env.AssertExpectations(t)
+
+		require.Len(t, saved, 2)
+		assert.Equal(t, saved[0].ID, saved[1].ID)
+		assert.Len(t, saved[1].Attempts, 2)
+		assert.Equal(t, 2, saved[1].Attempts[1].Attempt)
+	})
+
+	t.Run("when_continued_with_pending_operation_should_retry_it_from_its_payload", func(t *testing.T) {
+		s := testsuite.WorkflowTestSuite{}
+		env := s.NewTestWorkflowEnvironment()
+		w := NewBillWorkflows(testCfg())
+
+		start := time.Now()
+		env.SetStartTime(start)
+		bill := newOpenBill(start)
+		item := newLineItem(bill.ID, start)
+		payload, err := json.Marshal(item)
+		require.NoError(t, err)
+		pending := &models.FailedOperation{
+			ID:        uuid.Must(uuid.NewV4()),
+			BillID:    bill.ID,
+			Operation: models.FailedOperationUpdateLineItem,
+			Payload:   payload,
+			Status:    models.FailedOperationStatusPending,
+		}
+
+		env.OnActivity((&BillingActivities{}).UpdateLineItem, mock.Anything, mock.MatchedBy(func(lineItem models.LineItem) bool {
+			return lineItem.ID == item.ID && lineItem.Quantity.Equal(item.Quantity)
+		})).Return(nil).Once()
+		env.OnActivity((&BillingActivities{}).CloseBill, mock.Anything, mock.Anything).
+			Return(&models.Bill{}, nil).Once()
+		env.OnActivity((&BillingActivities{}).ResolveFailedOperation, mock.Anything, mock.Anything).
+			Return(nil).Once()
+
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(RetryFailedOperationSignal, RetryFailedOperationSignalData{ID: pending.ID})
+		}, 2*time.Hour)
+
+		env.ExecuteWorkflow(w.CreateBill, BillWorkflowInput{
+			Bill: bill,
+			Continued: &ContinuedBillState{
+				LineTotals:       []models.LineTotalGroup{},
+				Runs:             1,
+				FailedOperations: []*models.FailedOperation{pending},
+			},
+		})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+		env.AssertExpectations(t)
+	})
+
+	t.Run("when_started_before_dead_lettering_should_complete_without_recording", func(t *testing.T) {
+		s := testsuite.WorkflowTestSuite{}
+		env := s.NewTestWorkflowEnvironment()
+		w := NewBillWorkflows(testCfg())
+
+		start := time.Now()
+		env.SetStartTime(start)
+		bill := newOpenBill(start)
+
+		env.OnGetVersion(deadLetterChange, workflow.DefaultVersion, 1).Return(workflow.DefaultVersion)
+		env.OnActivity((&BillingActivities{}).CloseBill, mock.Anything, mock.Anything).
+			Return(nil, failed).Once()
+
+		env.ExecuteWorkflow(w.CreateBill, BillWorkflowInput{
+			Bill:      bill,
+			Continued: &ContinuedBillState{LineTotals: []models.LineTotalGroup{}},
+		})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+		env.AssertExpectations(t)
+	})
+}
+
+func TestRetryFailedOperationWorkflow(t *testing.T) {
+	newPending := func(t *testing.T) *models.FailedOperation {
+		payload, err := json.Marshal(CloseBillInput{BillID: uuid.Must(uuid.NewV4()), ClosedAt: time.Now()})
+		require.NoError(t, err)
+		return &models.FailedOperation{
+			ID:         uuid.Must(uuid.NewV4()),
+			BillID:     uuid.Must(uuid.NewV4()),
+			WorkflowID: "bill-completed",
+			Operation:  models.FailedOperationCloseBill,
+			Payload:    payload,
+			Status:     models.FailedOperationStatusPending,
+			Attempts:   []models.FailedOperationAttempt{{Attempt: 1, Error: "timeout"}},
+		}
+	}
+
+	t.Run("when_retry_succeeds_should_resolve_operation", func(t *testing.T) {
+		s := testsuite.WorkflowTestSuite{}
+		env := s.NewTestWorkflowEnvironment()
+		w := NewBillWorkflows(testCfg())
+		op := newPending(t)
+
+		env.OnActivity((&BillingActivities{}).CloseBill, mock.Anything, mock.Anything).
+			Return(&models.Bill{}, nil).Once()
+		env.OnActivity((&BillingActivities{}).ResolveFailedOperation, mock.Anything,
+			mock.MatchedBy(func(input ResolveFailedOperationInput) bool {
+				return input.ID == op.ID && input.Status == models.FailedOperationStatusResolved
+			})).Return(nil).Once()
+
+		env.ExecuteWorkflow(w.RetryFailedOperation, RetryFailedOperationInput{Operation: op})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+		env.AssertExpectations(t)
+	})
+
+	t.Run("when_retry_fails_should_record_attempt_and_keep_operation_pending", func(t *testing.T) {
+		s := testsuite.WorkflowTestSuite{}
+		env := s.NewTestWorkflowEnvironment()
+		w := NewBillWorkflows(testCfg())
+		op := newPending(t)
+
+		env.OnActivity((&BillingActivities{}).CloseBill, mock.Anything, mock.Anything).
+			Return(nil, temporal.NewNonRetryableApplicationError("bill not found", InvalidStateErrorType, nil)).Once()
+		var saved models.FailedOperation
+		env.OnActivity((&BillingActivities{}).RecordFailedOperation, mock.Anything, mock.Anything).
+			Run(func(args mock.Arguments) { saved = *args.Get(1).(*models.FailedOperation) }).
+			Return(nil).Once()
+
+		env.ExecuteWorkflow(w.RetryFailedOperation, RetryFailedOperationInput{Operation: op})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+		env.AssertExpectations(t)
+		assert.Equal(t, models.FailedOperationStatusPending, saved.Status)
+		assert.Equal(t, "bill-completed", saved.WorkflowID)
+		assert.Len(t, saved.Attempts, 2)
+	})
+}
+
+func TestFailedOperationActivities(t *testing.T) {
+	newFailedOperation := func(billID uuid.UUID, failedAt time.Time) *models.FailedOperation {
+		op := &models.FailedOperation{
+			ID:         uuid.Must(uuid.NewV4()),
+			BillID:     billID,
+			WorkflowID: "bill-" + billID.String(),
+			Operation:  models.FailedOperationCloseBill,
+			Payload:    json.RawMessage(`{}`),
+			Status:     models.FailedOperationStatusPending,
+			CreatedAt:  failedAt,
+		}
+		op.AddAttempt("connection refused", "", failedAt)
+		return op
+	}
+
+	t.Run("when_recorded_twice_should_keep_one_operation_with_latest_attempts", func(t *testing.T) {
+		fakeRepo := &repositorytest.FakeRepo{}
+		activities := newTestActivities(t, fakeRepo)
+		now := time.Now()
+		op := newFailedOperation(uuid.Must(uuid.NewV4()), now)
+
+		require.NoError(t, activities.RecordFailedOperation(context.TODO(), op))
+		op.AddAttempt("connection refused", "", now.Add(time.Minute))
+		require.NoError(t, activities.RecordFailedOperation(context.TODO(), op))
+
+		ops, err := fakeRepo.ListFailedOperations(context.TODO(), models.FailedOperationFilter{Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, ops, 1)
+		assert.Len(t, ops[0].Attempts, 2)
+	})
+
+	t.Run("when_resolved_twice_should_succeed", func(t *testing.T) {
+		fakeRepo := &repositorytest.FakeRepo{}
+		activities := newTestActivities(t, fakeRepo)
+		now := time.Now()
+		op := newFailedOperation(uuid.Must(uuid.NewV4()), now)
+		require.NoError(t, activities.RecordFailedOperation(context.TODO(), op))
+		input := ResolveFailedOperationInput{ID: op.ID, Status: models.FailedOperationStatusResolved, ResolvedAt: now}
+
+		assert.NoError(t, activities.ResolveFailedOperation(context.TODO(), input))
+		assert.NoError(t, activities.ResolveFailedOperation(context.TODO(), input))
+	})
+
+	t.Run("when_resolved_otherwise_should_fail_without_retries", func(t *testing.T) {
+		fakeRepo := &repositorytest.FakeRepo{}
+		activities := newTestActivities(t, fakeRepo)
+		now := time.Now()
+		op := newFailedOperation(uuid.Must(uuid.NewV4()), now)
+		require.NoError(t, activities.RecordFailedOperation(context.TODO(), op))
+		require.NoError(t, activities.ResolveFailedOperation(context.TODO(), ResolveFailedOperationInput{
+			ID: op.ID, Status: models.FailedOperationStatusAcknowledged, Note: "closed manually", ResolvedAt: now,
+		}))
+
+		err := activities.ResolveFailedOperation(context.TODO(), ResolveFailedOperationInput{
+			ID: op.ID, Status: models.FailedOperationStatusResolved, ResolvedAt: now.Add(time.Minute),
+		})
+
+		var appErr *temporal.ApplicationError
+		require.ErrorAs(t, err, &appErr)
+		assert.True(t, appErr.NonRetryable())
+		assert.Equal(t, InvalidStateErrorType, appErr.Type())
+	})
+}
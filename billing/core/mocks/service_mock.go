@@ -6,7 +6,9 @@ package mocks
 
 import (
 	context "context"
+	io "io"
 	reflect "reflect"
+	time "time"
 
 	models "encore.app/billing/models"
 	uuid "encore.dev/types/uuid"
@@ -36,6 +38,21 @@ func (m *MockService) EXPECT() *MockServiceMockRecorder {
 	return m.recorder
 }
 
+// AcknowledgeFailedOperation mocks base method.
+func (m *MockService) AcknowledgeFailedOperation(arg0 context.Context, arg1 uuid.UUID, arg2 string) (*models.FailedOperation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AcknowledgeFailedOperation", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*models.FailedOperation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AcknowledgeFailedOperation indicates an expected call of AcknowledgeFailedOperation.
+func (mr *MockServiceMockRecorder) AcknowledgeFailedOperation(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AcknowledgeFailedOperation", reflect.TypeOf((*MockService)(nil).AcknowledgeFailedOperation), arg0, arg1, arg2)
+}
+
 // AddLineItemToBill mocks base method.
 func (m *MockService) AddLineItemToBill(arg0 context.Context, arg1 uuid.UUID, arg2 *models.AddLineItemRequest) (*models.Bill, error) {
 	m.ctrl.T.Helper()
@@ -51,6 +68,21 @@ func (mr *MockServiceMockRecorder) AddLineItemToBill(arg0, arg1, arg2 interface{
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddLineItemToBill", reflect.TypeOf((*MockService)(nil).AddLineItemToBill), arg0, arg1, arg2)
 }
 
+// AuditBillTotals mocks base method.
+func (m *MockService) AuditBillTotals(arg0 context.Context, arg1 uuid.UUID) (*models.TotalsAudit, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AuditBillTotals", arg0, arg1)
+	ret0, _ := ret[0].(*models.TotalsAudit)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AuditBillTotals indicates an expected call of AuditBillTotals.
+func (mr *MockServiceMockRecorder) AuditBillTotals(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuditBillTotals", reflect.TypeOf((*MockService)(nil).AuditBillTotals), arg0, arg1)
+}
+
 // CloseBill mocks base method.
 func (m *MockService) CloseBill(arg0 context.Context, arg1 uuid.UUID) (*models.Bill, error) {
 	m.ctrl.T.Helper()
@@ -81,17 +113,602 @@ func (mr *MockServiceMockRecorder) CreateBill(arg0, arg1 interface{}) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateBill", reflect.TypeOf((*MockService)(nil).CreateBill), arg0, arg1)
 }
 
+// CreateExport mocks base method.
+func (m *MockService) CreateExport(arg0 context.Context, arg1 *models.CreateExportRequest) (*models.ExportJob, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateExport", arg0, arg1)
+	ret0, _ := ret[0].(*models.ExportJob)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateExport indicates an expected call of CreateExport.
+func (mr *MockServiceMockRecorder) CreateExport(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateExport", reflect.TypeOf((*MockService)(nil).CreateExport), arg0, arg1)
+}
+
+// DismissBankTransaction mocks base method.
+func (m *MockService) DismissBankTransaction(arg0 context.Context, arg1 uuid.UUID, arg2 string) (*models.BankTransaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DismissBankTransaction", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*models.BankTransaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DismissBankTransaction indicates an expected call of DismissBankTransaction.
+func (mr *MockServiceMockRecorder) DismissBankTransaction(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DismissBankTransaction", reflect.TypeOf((*MockService)(nil).DismissBankTransaction), arg0, arg1, arg2)
+}
+
+// EnsureDraftReconciliationSchedule mocks base method.
+func (m *MockService) EnsureDraftReconciliationSchedule(arg0 context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnsureDraftReconciliationSchedule", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// EnsureDraftReconciliationSchedule indicates an expected call of EnsureDraftReconciliationSchedule.
+func (mr *MockServiceMockRecorder) EnsureDraftReconciliationSchedule(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnsureDraftReconciliationSchedule", reflect.TypeOf((*MockService)(nil).EnsureDraftReconciliationSchedule), arg0)
+}
+
+// EnsureReconciliationSchedule mocks base method.
+func (m *MockService) EnsureReconciliationSchedule(arg0 context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnsureReconciliationSchedule", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// EnsureReconciliationSchedule indicates an expected call of EnsureReconciliationSchedule.
+func (mr *MockServiceMockRecorder) EnsureReconciliationSchedule(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnsureReconciliationSchedule", reflect.TypeOf((*MockService)(nil).EnsureReconciliationSchedule), arg0)
+}
+
+// FinalizeBill mocks base method.
+func (m *MockService) FinalizeBill(arg0 context.Context, arg1 uuid.UUID) (*models.Bill, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FinalizeBill", arg0, arg1)
+	ret0, _ := ret[0].(*models.Bill)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FinalizeBill indicates an expected call of FinalizeBill.
+func (mr *MockServiceMockRecorder) FinalizeBill(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FinalizeBill", reflect.TypeOf((*MockService)(nil).FinalizeBill), arg0, arg1)
+}
+
+// GetAgingReport mocks base method.
+func (m *MockService) GetAgingReport(arg0 context.Context, arg1 models.AgingReportFilter) (*models.AgingReport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAgingReport", arg0, arg1)
+	ret0, _ := ret[0].(*models.AgingReport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAgingReport indicates an expected call of GetAgingReport.
+func (mr *MockServiceMockRecorder) GetAgingReport(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAgingReport", reflect.TypeOf((*MockService)(nil).GetAgingReport), arg0, arg1)
+}
+
+// GetBankTransaction mocks base method.
+func (m *MockService) GetBankTransaction(arg0 context.Context, arg1 uuid.UUID) (*models.BankTransaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBankTransaction", arg0, arg1)
+	ret0, _ := ret[0].(*models.BankTransaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBankTransaction indicates an expected call of GetBankTransaction.
+func (mr *MockServiceMockRecorder) GetBankTransaction(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBankTransaction", reflect.TypeOf((*MockService)(nil).GetBankTransaction), arg0, arg1)
+}
+
 // GetBillByID mocks base method.
-func (m *MockService) GetBillByID(arg0 context.Context, arg1 uuid.UUID) (*models.Bill, error) {
+func (m *MockService) GetBillByID(arg0 context.Context, arg1 uuid.UUID, arg2 models.GetBillOptions) (*models.Bill, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetBillByID", arg0, arg1)
+	ret := m.ctrl.Call(m, "GetBillByID", arg0, arg1, arg2)
 	ret0, _ := ret[0].(*models.Bill)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetBillByID indicates an expected call of GetBillByID.
-func (mr *MockServiceMockRecorder) GetBillByID(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockServiceMockRecorder) GetBillByID(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBillByID", reflect.TypeOf((*MockService)(nil).GetBillByID), arg0, arg1, arg2)
+}
+
+// GetBillHistory mocks base method.
+func (m *MockService) GetBillHistory(arg0 context.Context, arg1 uuid.UUID) (*models.BillHistory, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBillHistory", arg0, arg1)
+	ret0, _ := ret[0].(*models.BillHistory)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBillHistory indicates an expected call of GetBillHistory.
+func (mr *MockServiceMockRecorder) GetBillHistory(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBillHistory", reflect.TypeOf((*MockService)(nil).GetBillHistory), arg0, arg1)
+}
+
+// GetBillInvoice mocks base method.
+func (m *MockService) GetBillInvoice(arg0 context.Context, arg1 uuid.UUID) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBillInvoice", arg0, arg1)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBillInvoice indicates an expected call of GetBillInvoice.
+func (mr *MockServiceMockRecorder) GetBillInvoice(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBillInvoice", reflect.TypeOf((*MockService)(nil).GetBillInvoice), arg0, arg1)
+}
+
+// GetBillLedger mocks base method.
+func (m *MockService) GetBillLedger(arg0 context.Context, arg1 uuid.UUID) ([]*models.Journal, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBillLedger", arg0, arg1)
+	ret0, _ := ret[0].([]*models.Journal)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBillLedger indicates an expected call of GetBillLedger.
+func (mr *MockServiceMockRecorder) GetBillLedger(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBillLedger", reflect.TypeOf((*MockService)(nil).GetBillLedger), arg0, arg1)
+}
+
+// GetBillRecognitionSchedules mocks base method.
+func (m *MockService) GetBillRecognitionSchedules(arg0 context.Context, arg1 uuid.UUID) ([]*models.RecognitionSchedule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBillRecognitionSchedules", arg0, arg1)
+	ret0, _ := ret[0].([]*models.RecognitionSchedule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBillRecognitionSchedules indicates an expected call of GetBillRecognitionSchedules.
+func (mr *MockServiceMockRecorder) GetBillRecognitionSchedules(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBillRecognitionSchedules", reflect.TypeOf((*MockService)(nil).GetBillRecognitionSchedules), arg0, arg1)
+}
+
+// GetCreditNoteDocument mocks base method.
+func (m *MockService) GetCreditNoteDocument(arg0 context.Context, arg1 uuid.UUID, arg2 string) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCreditNoteDocument", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCreditNoteDocument indicates an expected call of GetCreditNoteDocument.
+func (mr *MockServiceMockRecorder) GetCreditNoteDocument(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCreditNoteDocument", reflect.TypeOf((*MockService)(nil).GetCreditNoteDocument), arg0, arg1, arg2)
+}
+
+// GetCustomerProfile mocks base method.
+func (m *MockService) GetCustomerProfile(arg0 context.Context, arg1 string) (*models.CustomerProfile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCustomerProfile", arg0, arg1)
+	ret0, _ := ret[0].(*models.CustomerProfile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCustomerProfile indicates an expected call of GetCustomerProfile.
+func (mr *MockServiceMockRecorder) GetCustomerProfile(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCustomerProfile", reflect.TypeOf((*MockService)(nil).GetCustomerProfile), arg0, arg1)
+}
+
+// GetCustomerStatement mocks base method.
+func (m *MockService) GetCustomerStatement(arg0 context.Context, arg1 string, arg2 models.CustomerStatementFilter) (*models.CustomerStatement, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCustomerStatement", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*models.CustomerStatement)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCustomerStatement indicates an expected call of GetCustomerStatement.
+func (mr *MockServiceMockRecorder) GetCustomerStatement(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCustomerStatement", reflect.TypeOf((*MockService)(nil).GetCustomerStatement), arg0, arg1, arg2)
+}
+
+// GetDeferredRevenueReport mocks base method.
+func (m *MockService) GetDeferredRevenueReport(arg0 context.Context, arg1 models.DeferredRevenueFilter) (*models.DeferredRevenueWaterfall, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDeferredRevenueReport", arg0, arg1)
+	ret0, _ := ret[0].(*models.DeferredRevenueWaterfall)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDeferredRevenueReport indicates an expected call of GetDeferredRevenueReport.
+func (mr *MockServiceMockRecorder) GetDeferredRevenueReport(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeferredRevenueReport", reflect.TypeOf((*MockService)(nil).GetDeferredRevenueReport), arg0, arg1)
+}
+
+// GetExport mocks base method.
+func (m *MockService) GetExport(arg0 context.Context, arg1 uuid.UUID) (*models.ExportJob, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetExport", arg0, arg1)
+	ret0, _ := ret[0].(*models.ExportJob)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetExport indicates an expected call of GetExport.
+func (mr *MockServiceMockRecorder) GetExport(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetExport", reflect.TypeOf((*MockService)(nil).GetExport), arg0, arg1)
+}
+
+// GetExportDownload mocks base method.
+func (m *MockService) GetExportDownload(arg0 context.Context, arg1 uuid.UUID) (*models.ExportJob, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetExportDownload", arg0, arg1)
+	ret0, _ := ret[0].(*models.ExportJob)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetExportDownload indicates an expected call of GetExportDownload.
+func (mr *MockServiceMockRecorder) GetExportDownload(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetExportDownload", reflect.TypeOf((*MockService)(nil).GetExportDownload), arg0, arg1)
+}
+
+// GetFailedOperation mocks base method.
+func (m *MockService) GetFailedOperation(arg0 context.Context, arg1 uuid.UUID) (*models.FailedOperation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFailedOperation", arg0, arg1)
+	ret0, _ := ret[0].(*models.FailedOperation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFailedOperation indicates an expected call of GetFailedOperation.
+func (mr *MockServiceMockRecorder) GetFailedOperation(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFailedOperation", reflect.TypeOf((*MockService)(nil).GetFailedOperation), arg0, arg1)
+}
+
+// GetMRRReport mocks base method.
+func (m *MockService) GetMRRReport(arg0 context.Context, arg1 models.MRRReportFilter) (*models.MRRReport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMRRReport", arg0, arg1)
+	ret0, _ := ret[0].(*models.MRRReport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMRRReport indicates an expected call of GetMRRReport.
+func (mr *MockServiceMockRecorder) GetMRRReport(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMRRReport", reflect.TypeOf((*MockService)(nil).GetMRRReport), arg0, arg1)
+}
+
+// GetReconciliationReport mocks base method.
+func (m *MockService) GetReconciliationReport(arg0 context.Context, arg1 uuid.UUID) (*models.ReconciliationReport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReconciliationReport", arg0, arg1)
+	ret0, _ := ret[0].(*models.ReconciliationReport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetReconciliationReport indicates an expected call of GetReconciliationReport.
+func (mr *MockServiceMockRecorder) GetReconciliationReport(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReconciliationReport", reflect.TypeOf((*MockService)(nil).GetReconciliationReport), arg0, arg1)
+}
+
+// GetRevenueReport mocks base method.
+func (m *MockService) GetRevenueReport(arg0 context.Context, arg1 models.RevenueReportFilter) (*models.RevenueReport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRevenueReport", arg0, arg1)
+	ret0, _ := ret[0].(*models.RevenueReport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRevenueReport indicates an expected call of GetRevenueReport.
+func (mr *MockServiceMockRecorder) GetRevenueReport(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRevenueReport", reflect.TypeOf((*MockService)(nil).GetRevenueReport), arg0, arg1)
+}
+
+// GetTrialBalance mocks base method.
+func (m *MockService) GetTrialBalance(arg0 context.Context, arg1 time.Time) (*models.TrialBalance, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTrialBalance", arg0, arg1)
+	ret0, _ := ret[0].(*models.TrialBalance)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTrialBalance indicates an expected call of GetTrialBalance.
+func (mr *MockServiceMockRecorder) GetTrialBalance(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTrialBalance", reflect.TypeOf((*MockService)(nil).GetTrialBalance), arg0, arg1)
+}
+
+// ImportBankStatement mocks base method.
+func (m *MockService) ImportBankStatement(arg0 context.Context, arg1 models.StatementFormat, arg2 io.Reader) (*models.StatementImport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ImportBankStatement", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*models.StatementImport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ImportBankStatement indicates an expected call of ImportBankStatement.
+func (mr *MockServiceMockRecorder) ImportBankStatement(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ImportBankStatement", reflect.TypeOf((*MockService)(nil).ImportBankStatement), arg0, arg1, arg2)
+}
+
+// IssueCreditNote mocks base method.
+func (m *MockService) IssueCreditNote(arg0 context.Context, arg1 uuid.UUID, arg2 *models.IssueCreditNoteRequest) (*models.Journal, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IssueCreditNote", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*models.Journal)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IssueCreditNote indicates an expected call of IssueCreditNote.
+func (mr *MockServiceMockRecorder) IssueCreditNote(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IssueCreditNote", reflect.TypeOf((*MockService)(nil).IssueCreditNote), arg0, arg1, arg2)
+}
+
+// ListBankTransactions mocks base method.
+func (m *MockService) ListBankTransactions(arg0 context.Context, arg1 models.BankTransactionFilter) ([]*models.BankTransaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListBankTransactions", arg0, arg1)
+	ret0, _ := ret[0].([]*models.BankTransaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListBankTransactions indicates an expected call of ListBankTransactions.
+func (mr *MockServiceMockRecorder) ListBankTransactions(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListBankTransactions", reflect.TypeOf((*MockService)(nil).ListBankTransactions), arg0, arg1)
+}
+
+// ListBillWorkflows mocks base method.
+func (m *MockService) ListBillWorkflows(arg0 context.Context, arg1 models.BillWorkflowFilter) ([]*models.BillWorkflowSummary, []byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListBillWorkflows", arg0, arg1)
+	ret0, _ := ret[0].([]*models.BillWorkflowSummary)
+	ret1, _ := ret[1].([]byte)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ListBillWorkflows indicates an expected call of ListBillWorkflows.
+func (mr *MockServiceMockRecorder) ListBillWorkflows(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListBillWorkflows", reflect.TypeOf((*MockService)(nil).ListBillWorkflows), arg0, arg1)
+}
+
+// ListFailedOperations mocks base method.
+func (m *MockService) ListFailedOperations(arg0 context.Context, arg1 models.FailedOperationFilter) ([]*models.FailedOperation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListFailedOperations", arg0, arg1)
+	ret0, _ := ret[0].([]*models.FailedOperation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListFailedOperations indicates an expected call of ListFailedOperations.
+func (mr *MockServiceMockRecorder) ListFailedOperations(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListFailedOperations", reflect.TypeOf((*MockService)(nil).ListFailedOperations), arg0, arg1)
+}
+
+// ListLineItems mocks base method.
+func (m *MockService) ListLineItems(arg0 context.Context, arg1 uuid.UUID, arg2 models.LineItemFilter) ([]*models.LineItem, *models.LineItemCursor, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListLineItems", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]*models.LineItem)
+	ret1, _ := ret[1].(*models.LineItemCursor)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ListLineItems indicates an expected call of ListLineItems.
+func (mr *MockServiceMockRecorder) ListLineItems(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListLineItems", reflect.TypeOf((*MockService)(nil).ListLineItems), arg0, arg1, arg2)
+}
+
+// ListReconciliationReports mocks base method.
+func (m *MockService) ListReconciliationReports(arg0 context.Context, arg1 int) ([]*models.ReconciliationReport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListReconciliationReports", arg0, arg1)
+	ret0, _ := ret[0].([]*models.ReconciliationReport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListReconciliationReports indicates an expected call of ListReconciliationReports.
+func (mr *MockServiceMockRecorder) ListReconciliationReports(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListReconciliationReports", reflect.TypeOf((*MockService)(nil).ListReconciliationReports), arg0, arg1)
+}
+
+// MatchBankTransaction mocks base method.
+func (m *MockService) MatchBankTransaction(arg0 context.Context, arg1, arg2 uuid.UUID) (*models.BankTransaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MatchBankTransaction", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*models.BankTransaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MatchBankTransaction indicates an expected call of MatchBankTransaction.
+func (mr *MockServiceMockRecorder) MatchBankTransaction(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MatchBankTransaction", reflect.TypeOf((*MockService)(nil).MatchBankTransaction), arg0, arg1, arg2)
+}
+
+// RecordPayment mocks base method.
+func (m *MockService) RecordPayment(arg0 context.Context, arg1 uuid.UUID, arg2 *models.RecordPaymentRequest) ([]*models.Journal, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordPayment", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]*models.Journal)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RecordPayment indicates an expected call of RecordPayment.
+func (mr *MockServiceMockRecorder) RecordPayment(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordPayment", reflect.TypeOf((*MockService)(nil).RecordPayment), arg0, arg1, arg2)
+}
+
+// RefreshRevenueRollups mocks base method.
+func (m *MockService) RefreshRevenueRollups(arg0 context.Context, arg1 time.Time) (*models.RollupRefresh, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RefreshRevenueRollups", arg0, arg1)
+	ret0, _ := ret[0].(*models.RollupRefresh)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RefreshRevenueRollups indicates an expected call of RefreshRevenueRollups.
+func (mr *MockServiceMockRecorder) RefreshRevenueRollups(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefreshRevenueRollups", reflect.TypeOf((*MockService)(nil).RefreshRevenueRollups), arg0, arg1)
+}
+
+// RemoveLineItem mocks base method.
+func (m *MockService) RemoveLineItem(arg0 context.Context, arg1, arg2 uuid.UUID, arg3 string) (*models.Bill, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveLineItem", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(*models.Bill)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RemoveLineItem indicates an expected call of RemoveLineItem.
+func (mr *MockServiceMockRecorder) RemoveLineItem(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveLineItem", reflect.TypeOf((*MockService)(nil).RemoveLineItem), arg0, arg1, arg2, arg3)
+}
+
+// ReopenBill mocks base method.
+func (m *MockService) ReopenBill(arg0 context.Context, arg1 uuid.UUID) (*models.Bill, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReopenBill", arg0, arg1)
+	ret0, _ := ret[0].(*models.Bill)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReopenBill indicates an expected call of ReopenBill.
+func (mr *MockServiceMockRecorder) ReopenBill(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReopenBill", reflect.TypeOf((*MockService)(nil).ReopenBill), arg0, arg1)
+}
+
+// RetryFailedOperation mocks base method.
+func (m *MockService) RetryFailedOperation(arg0 context.Context, arg1 uuid.UUID) (*models.FailedOperation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RetryFailedOperation", arg0, arg1)
+	ret0, _ := ret[0].(*models.FailedOperation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RetryFailedOperation indicates an expected call of RetryFailedOperation.
+func (mr *MockServiceMockRecorder) RetryFailedOperation(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RetryFailedOperation", reflect.TypeOf((*MockService)(nil).RetryFailedOperation), arg0, arg1)
+}
+
+// StartReconciliation mocks base method.
+func (m *MockService) StartReconciliation(arg0 context.Context, arg1 bool) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StartReconciliation", arg0, arg1)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// StartReconciliation indicates an expected call of StartReconciliation.
+func (mr *MockServiceMockRecorder) StartReconciliation(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StartReconciliation", reflect.TypeOf((*MockService)(nil).StartReconciliation), arg0, arg1)
+}
+
+// UpdateLineItem mocks base method.
+func (m *MockService) UpdateLineItem(arg0 context.Context, arg1, arg2 uuid.UUID, arg3 models.LineItemUpdate) (*models.Bill, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateLineItem", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(*models.Bill)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateLineItem indicates an expected call of UpdateLineItem.
+func (mr *MockServiceMockRecorder) UpdateLineItem(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateLineItem", reflect.TypeOf((*MockService)(nil).UpdateLineItem), arg0, arg1, arg2, arg3)
+}
+
+// UpsertCustomerProfile mocks base method.
+func (m *MockService) UpsertCustomerProfile(arg0 context.Context, arg1 string, arg2 *models.UpsertCustomerProfileRequest) (*models.CustomerProfile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpsertCustomerProfile", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*models.CustomerProfile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpsertCustomerProfile indicates an expected call of UpsertCustomerProfile.
+func (mr *MockServiceMockRecorder) UpsertCustomerProfile(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertCustomerProfile", reflect.TypeOf((*MockService)(nil).UpsertCustomerProfile), arg0, arg1, arg2)
+}
+
+// VoidBill mocks base method.
+func (m *MockService) VoidBill(arg0 context.Context, arg1 uuid.UUID, arg2 string) (*models.Bill, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VoidBill", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*models.Bill)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VoidBill indicates an expected call of VoidBill.
+func (mr *MockServiceMockRecorder) VoidBill(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBillByID", reflect.TypeOf((*MockService)(nil).GetBillByID), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VoidBill", reflect.TypeOf((*MockService)(nil).VoidBill), arg0, arg1, arg2)
 }
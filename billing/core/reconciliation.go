@@ -0,0 +1,283 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"encore.app/billing/models"
+	"encore.app/billing/repository"
+	"encore.dev/rlog"
+	"encore.dev/types/uuid"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/workflow"
+)
+
+// ReconciliationScheduleID identifies the Temporal schedule running ReconcileBills
+const ReconciliationScheduleID = "bill-reconciliation"
+
+// DraftReconciliationScheduleID identifies the Temporal schedule running ReconcileDraftBills
+const DraftReconciliationScheduleID = "draft-bill-reconciliation"
+
+type ReconcileBillsInput struct {
+	// Repair updates the database from the workflow state, otherwise discrepancies are only reported
+	Repair bool `json:"repair"`
+}
+
+type ReconcileBillBatchInput struct {
+	After  uuid.UUID `json:"after"`
+	Limit  int       `json:"limit"`
+	Repair bool      `json:"repair"`
+}
+
+type ReconcileBillBatchResult struct {
+	Checked       int                      `json:"checked"`
+	LastBillID    uuid.UUID                `json:"last_bill_id"`
+	Discrepancies []models.BillDiscrepancy `json:"discrepancies"`
+}
+
+// ReconcileBills compares the state of every open bill workflow with the database in batches,
+// optionally repairs the database, and saves the report
+func (w *BillWorkflows) ReconcileBills(ctx workflow.Context, input ReconcileBillsInput) (*models.ReconciliationReport, error) {
+	logger := workflow.GetLogger(ctx)
+	logger.Info("Starting bill reconciliation", "repair", input.Repair)
+
+	report := &models.ReconciliationReport{
+		WorkflowID:    workflow.GetInfo(ctx).WorkflowExecution.ID,
+		Repair:        input.Repair,
+		StartedAt:     workflow.Now(ctx),
+		Discrepancies: []models.BillDiscrepancy{},
+	}
+	if err := workflow.SideEffect(ctx, func(ctx workflow.Context) interface{} {
+		return uuid.Must(uuid.NewV4())
+	}).Get(&report.ID); err != nil {
+		return nil, err
+	}
+
+	activityCtx := workflow.WithActivityOptions(ctx, getDefaultActivityOptions(w.cfg))
+	batchCtx := workflow.WithActivityOptions(ctx, getActivityOptions(w.cfg,
+		time.Duration(w.cfg.Temporal.ReconcileBatchActivityTimeout())*time.Second))
+	limit := w.cfg.Billing.Reconciliation.BatchSize()
+	var after uuid.UUID
+	for {
+		var batch ReconcileBillBatchResult
+		err := workflow.ExecuteActivity(batchCtx, (&ReconciliationActivities{}).ReconcileBillBatch, ReconcileBillBatchInput{
+			After:  after,
+			Limit:  limit,
+			Repair: input.Repair,
+		}).Get(ctx, &batch)
+		if err != nil {
+			logger.Error("Failed to reconcile bill batch", "error", err)
+			return nil, err
+		}
+
+		report.BillsChecked += batch.Checked
+		report.Discrepancies = append(report.Discrepancies, batch.Discrepancies...)
+		if batch.Checked < limit {
+			break
+		}
+		after = batch.LastBillID
+	}
+	report.CompletedAt = workflow.Now(ctx)
+
+	if err := workflow.ExecuteActivity(
+		activityCtx, (&ReconciliationActivities{}).SaveReconciliationReport, report,
+	).Get(ctx, nil); err != nil {
+		logger.Error("Failed to save reconciliation report", "error", err)
+		return nil, err
+	}
+
+	logger.Info("Bill reconciliation completed",
+		"report_id", report.ID,
+		"bills_checked", report.BillsChecked,
+		"discrepancies", len(report.Discrepancies))
+	return report, nil
+}
+
+// ReconcileDraftBills starts the workflows of draft bills whose workflow could not be started on creation,
+// e.g. while Temporal was unavailable
+func (w *BillWorkflows) ReconcileDraftBills(ctx workflow.Context) (*models.DraftReconciliation, error) {
+	logger := workflow.GetLogger(ctx)
+	logger.Info("Starting draft bill reconciliation")
+
+	activityCtx := workflow.WithActivityOptions(ctx, getDefaultActivityOptions(w.cfg))
+	var result models.DraftReconciliation
+	if err := workflow.ExecuteActivity(
+		activityCtx, (&ReconciliationActivities{}).ReconcileDraftBills,
+	).Get(ctx, &result); err != nil {
+		logger.Error("Failed to reconcile draft bills", "error", err)
+		return nil, err
+	}
+
+	logger.Info("Draft bill reconciliation completed",
+		"checked", result.Checked,
+		"started", result.Started,
+		"voided", result.Voided,
+		"failed", result.Failed)
+	return &result, nil
+}
+
+func NewReconciliationActivities(
+	repository repository.Repository, temporalClient client.Client, billing *BillingActivities,
+) *ReconciliationActivities {
+	return &ReconciliationActivities{
+		repository:     repository,
+		temporalClient: temporalClient,
+		billing:        billing,
+	}
+}
+
+// ReconciliationActivities query bill workflows, so unlike BillingActivities they need the Temporal client
+type ReconciliationActivities struct {
+	repository     repository.Repository
+	temporalClient client.Client
+	billing        *BillingActivities
+}
+
+// ReconcileBillBatch reconciles a batch of open bills with their workflow
+func (a *ReconciliationActivities) ReconcileBillBatch(ctx context.Context, input ReconcileBillBatchInput) (*ReconcileBillBatchResult, error) {
+	logger := rlog.With("module", "billing_activities")
+	logger.Info("Reconciling bill batch", "after", input.After, "limit", input.Limit, "repair", input.Repair)
+
+	bills, err := a.repository.ListOpenBills(ctx, input.After, input.Limit)
+	if err != nil {
+		logger.Error("Failed to list open bills", "error", err)
+		return nil, err
+	}
+
+	result := &ReconcileBillBatchResult{Checked: len(bills), Discrepancies: []models.BillDiscrepancy{}}
+	for _, bill := range bills {
+		discrepancies, err := a.reconcileBill(ctx, bill, input.Repair)
+		if err != nil {
+			logger.Error("Failed to reconcile bill", "bill_id", bill.ID, "error", err)
+			return nil, err
+		}
+		result.LastBillID = bill.ID
+		result.Discrepancies = append(result.Discrepancies, discrepancies...)
+	}
+
+	logger.Info("Bill batch reconciled", "checked", result.Checked, "discrepancies", len(result.Discrepancies))
+	return result, nil
+}
+
+// reconcileBill compares the bill held by its workflow with the database and repairs the differences when asked
+func (a *ReconciliationActivities) reconcileBill(ctx context.Context, bill *models.Bill, repair bool) ([]models.BillDiscrepancy, error) {
+	var workflowBill models.Bill
+	value, err := a.temporalClient.QueryWorkflow(ctx, bill.WorkflowID, "", GetBillQuery)
+	if err == nil {
+		err = value.Get(&workflowBill)
+	}
+	if err != nil {
+		return []models.BillDiscrepancy{{
+			BillID:   bill.ID,
+			Kind:     models.DiscrepancyWorkflowUnavailable,
+			Database: string(bill.Status),
+			Error:    err.Error(),
+		}}, nil
+	}
+
+	stored, err := a.repository.GetBillByID(ctx, bill.ID, models.GetBillOptions{IncludeLineItems: true})
+	if err != nil {
+		return nil, err
+	}
+
+	discrepancies := models.DiffBill(&workflowBill, stored)
+	if repair {
+		for i := range discrepancies {
+			a.repair(ctx, &workflowBill, &discrepancies[i])
+		}
+	}
+	return discrepancies, nil
+}
+
+// repair updates the database from the workflow state. Line item count mismatches and unavailable workflows
+// need investigation and are not repaired.
+func (a *ReconciliationActivities) repair(ctx context.Context, workflowBill *models.Bill, discrepancy *models.BillDiscrepancy) {
+	logger := rlog.With("module", "billing_activities").With("bill_id", discrepancy.BillID.String())
+
+	var err error
+	switch {
+	case discrepancy.Kind == models.DiscrepancyMissingLineItem:
+		err = a.repository.AddLineItemToBill(ctx, discrepancy.LineItem)
+	case discrepancy.Kind == models.DiscrepancyLineItemMismatch:
+		err = a.repository.UpdateLineItem(ctx, discrepancy.LineItem)
+	case discrepancy.Kind == models.DiscrepancyStatusMismatch && workflowBill.IsClosed() && workflowBill.ClosedAt != nil:
+		_, err = a.billing.CloseBill(ctx, CloseBillInput{BillID: workflowBill.ID, ClosedAt: *workflowBill.ClosedAt})
+	case discrepancy.Kind == models.DiscrepancyStatusMismatch && workflowBill.Status == models.BillStatusClosing && workflowBill.ClosingAt != nil:
+		err = a.repository.MarkBillClosing(ctx, workflowBill.ID, *workflowBill.ClosingAt)
+	case discrepancy.Kind == models.DiscrepancyStatusMismatch && workflowBill.Status == models.BillStatusVoided && workflowBill.VoidedAt != nil:
+		err = a.repository.VoidBill(ctx, workflowBill.ID, workflowBill.VoidReason, *workflowBill.VoidedAt)
+	default:
+		return
+	}
+
+	if err != nil {
+		logger.Error("Failed to repair discrepancy", "kind", discrepancy.Kind, "error", err)
+		discrepancy.Error = err.Error()
+		return
+	}
+	logger.Info("Discrepancy repaired", "kind", discrepancy.Kind)
+	discrepancy.Repaired = true
+}
+
+// SaveReconciliationReport persists the report, retries keep the first saved report
+func (a *ReconciliationActivities) SaveReconciliationReport(ctx context.Context, report *models.ReconciliationReport) error {
+	logger := rlog.With("module", "billing_activities")
+	logger.Info("Saving reconciliation report", "report_id", report.ID)
+
+	if err := a.repository.SaveReconciliationReport(ctx, report); err != nil {
+		logger.Error("Failed to save reconciliation report", "error", err)
+		return err
+	}
+
+	logger.Info("Reconciliation report saved successfully", "report_id", report.ID)
+	return nil
+}
+
+// ReconcileDraftBills starts the workflow of draft bills left behind when it could not be started on creation.
+// Drafts whose period already ended are voided instead. Drafts that fail are left to the next run.
+func (a *ReconciliationActivities) ReconcileDraftBills(ctx context.Context) (*models.DraftReconciliation, error) {
+	logger := rlog.With("module", "billing_activities")
+	cfg := a.billing.cfg
+
+	minAge := time.Duration(cfg.Billing.DraftReconciler.MinAge()) * time.Second
+	drafts, err := a.repository.ListDraftBills(ctx, time.Now().Add(-minAge), cfg.Billing.DraftReconciler.BatchSize())
+	if err != nil {
+		logger.Error("Failed to list draft bills", "error", err)
+		return nil, err
+	}
+
+	result := &models.DraftReconciliation{Checked: len(drafts)}
+	for _, draft := range drafts {
+		draftLogger := logger.With("bill_id", draft.ID.String()).With("workflow_id", draft.WorkflowID)
+
+		now := time.Now()
+		if !now.Before(draft.CloseTime()) {
+			err = a.repository.VoidBill(ctx, draft.ID, "billing period ended before the bill was activated", now)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				draftLogger.Error("Failed to void expired draft bill", "error", err)
+				result.Failed++
+				continue
+			}
+			draftLogger.Info("Expired draft bill voided")
+			result.Voided++
+			continue
+		}
+
+		if err = startBillWorkflow(ctx, a.temporalClient, cfg, draft); err != nil {
+			draftLogger.Error("Failed to start workflow for draft bill", "error", err)
+			result.Failed++
+			continue
+		}
+		draftLogger.Info("Workflow started for draft bill")
+		result.Started++
+	}
+
+	logger.Info("Draft bills reconciled",
+		"checked", result.Checked,
+		"started", result.Started,
+		"voided", result.Voided,
+		"failed", result.Failed)
+	return result, nil
+}
@@ -0,0 +1,296 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	mocksCore "encore.app/billing/core/mocks"
+	"encore.app/billing/ext_services/mocks"
+	"encore.app/billing/models"
+	"encore.app/billing/repository/repositorytest"
+	"encore.dev/types/uuid"
+	"github.com/golang/mock/gomock"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestReconcileBillsWorkflow(t *testing.T) {
+	t.Run("should_reconcile_batches_until_last_page_then_save_report", func(t *testing.T) {
+		s := testsuite.WorkflowTestSuite{}
+		env := s.NewTestWorkflowEnvironment()
+		w := NewBillWorkflows(testCfg())
+
+		lastOfFirstBatch := uuid.Must(uuid.NewV4())
+		discrepancy := models.BillDiscrepancy{BillID: lastOfFirstBatch, Kind: models.DiscrepancyStatusMismatch}
+
+		env.OnActivity((&ReconciliationActivities{}).ReconcileBillBatch, mock.Anything,
+			ReconcileBillBatchInput{Limit: 2, Repair: true}).
+			Return(&ReconcileBillBatchResult{
+				Checked:       2,
+				LastBillID:    lastOfFirstBatch,
+				Discrepancies: []models.BillDiscrepancy{discrepancy},
+			}, nil).Once()
+		env.OnActivity((&ReconciliationActivities{}).ReconcileBillBatch, mock.Anything,
+			ReconcileBillBatchInput{After: lastOfFirstBatch, Limit: 2, Repair: true}).
+			Return(&ReconcileBillBatchResult{Checked: 1}, nil).Once()
+
+		var saved *models.ReconciliationReport
+		env.OnActivity((&ReconciliationActivities{}).SaveReconciliationReport, mock.Anything, mock.Anything).
+			Return(func(_ context.Context, report *models.ReconciliationReport) error {
+				saved = report
+				return nil
+			}).Once()
+
+		env.ExecuteWorkflow(w.ReconcileBills, ReconcileBillsInput{Repair: true})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+		env.AssertExpectations(t)
+
+		var report models.ReconciliationReport
+		assert.NoError(t, env.GetWorkflowResult(&report))
+		assert.Equal(t, 3, report.BillsChecked)
+		assert.True(t, report.Repair)
+		assert.NotEqual(t, uuid.UUID{}, report.ID)
+		assert.Len(t, report.Discrepancies, 1)
+		assert.Equal(t, models.DiscrepancyStatusMismatch, report.Discrepancies[0].Kind)
+		if assert.NotNil(t, saved) {
+			assert.Equal(t, report.ID, saved.ID)
+		}
+	})
+
+	t.Run("should_fail_without_saving_report_when_batch_fails", func(t *testing.T) {
+		s := testsuite.WorkflowTestSuite{}
+		env := s.NewTestWorkflowEnvironment()
+		w := NewBillWorkflows(testCfg())
+
+		env.OnActivity((&ReconciliationActivities{}).ReconcileBillBatch, mock.Anything, mock.Anything).
+			Return(nil, errors.New("database unavailable"))
+
+		env.ExecuteWorkflow(w.ReconcileBills, ReconcileBillsInput{})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.Error(t, env.GetWorkflowError())
+		env.AssertNotCalled(t, "SaveReconciliationReport", mock.Anything, mock.Anything)
+	})
+}
+
+func TestReconcileDraftBillsWorkflow(t *testing.T) {
+	t.Run("should_return_the_result_of_the_activity", func(t *testing.T) {
+		s := testsuite.WorkflowTestSuite{}
+		env := s.NewTestWorkflowEnvironment()
+		w := NewBillWorkflows(testCfg())
+
+		env.OnActivity((&ReconciliationActivities{}).ReconcileDraftBills, mock.Anything).
+			Return(&models.DraftReconciliation{Checked: 2, Started: 1, Voided: 1}, nil).Once()
+
+		env.ExecuteWorkflow(w.ReconcileDraftBills)
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+		env.AssertExpectations(t)
+
+		var result models.DraftReconciliation
+		assert.NoError(t, env.GetWorkflowResult(&result))
+		assert.Equal(t, models.DraftReconciliation{Checked: 2, Started: 1, Voided: 1}, result)
+	})
+}
+
+func TestReconciliationActivities_ReconcileBillBatch(t *testing.T) {
+	newOpenBill := func(t *testing.T, fakeRepo *repositorytest.FakeRepo) *models.Bill {
+		billID := uuid.Must(uuid.NewV4())
+		bill := &models.Bill{
+			ID:         billID,
+			Status:     models.BillStatusOpen,
+			WorkflowID: "bill-" + billID.String(),
+		}
+		require.NoError(t, fakeRepo.CreateBill(context.TODO(), bill))
+		return bill
+	}
+	newLineItem := func(billID uuid.UUID) *models.LineItem {
+		return &models.LineItem{
+			ID:          uuid.Must(uuid.NewV4()),
+			BillID:      billID,
+			Description: "Service A",
+			Currency:    models.USD,
+			Quantity:    decimal.NewFromInt(1),
+			UnitPrice:   decimal.NewFromInt(10),
+		}
+	}
+
+	t.Run("should_report_discrepancies_without_repairing", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		mockTemporalClient := mocksCore.NewMockClient(ctrl)
+		fakeRepo := &repositorytest.FakeRepo{}
+		activities := NewReconciliationActivities(fakeRepo, mockTemporalClient, newTestActivities(t, fakeRepo))
+
+		bill := newOpenBill(t, fakeRepo)
+		missing := newLineItem(bill.ID)
+		mockTemporalClient.EXPECT().QueryWorkflow(gomock.Any(), bill.WorkflowID, "", GetBillQuery).
+			Return(fakeEncodedValue{value: models.Bill{
+				ID:            bill.ID,
+				Status:        models.BillStatusOpen,
+				LineItems:     []*models.LineItem{missing},
+				LineItemCount: 1,
+			}}, nil)
+
+		result, err := activities.ReconcileBillBatch(context.TODO(), ReconcileBillBatchInput{Limit: 10})
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, result.Checked)
+		assert.Equal(t, bill.ID, result.LastBillID)
+		require.Len(t, result.Discrepancies, 1)
+		assert.Equal(t, models.DiscrepancyMissingLineItem, result.Discrepancies[0].Kind)
+		assert.False(t, result.Discrepancies[0].Repaired)
+		items, _ := fakeRepo.GetLineItemsByBillID(context.TODO(), bill.ID)
+		assert.Empty(t, items)
+	})
+
+	t.Run("should_repair_database_from_workflow_state", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		mockTemporalClient := mocksCore.NewMockClient(ctrl)
+		fakeRepo := &repositorytest.FakeRepo{}
+		activities := NewReconciliationActivities(fakeRepo, mockTemporalClient, newTestActivities(t, fakeRepo))
+
+		withMissingItem := newOpenBill(t, fakeRepo)
+		missing := newLineItem(withMissingItem.ID)
+		mockTemporalClient.EXPECT().QueryWorkflow(gomock.Any(), withMissingItem.WorkflowID, "", GetBillQuery).
+			Return(fakeEncodedValue{value: models.Bill{
+				ID:            withMissingItem.ID,
+				Status:        models.BillStatusOpen,
+				LineItems:     []*models.LineItem{missing},
+				LineItemCount: 1,
+			}}, nil)
+
+		voided := newOpenBill(t, fakeRepo)
+		voidedAt := time.Now()
+		mockTemporalClient.EXPECT().QueryWorkflow(gomock.Any(), voided.WorkflowID, "", GetBillQuery).
+			Return(fakeEncodedValue{value: models.Bill{
+				ID:         voided.ID,
+				Status:     models.BillStatusVoided,
+				VoidedAt:   &voidedAt,
+				VoidReason: "duplicate",
+			}}, nil)
+
+		result, err := activities.ReconcileBillBatch(context.TODO(), ReconcileBillBatchInput{Limit: 10, Repair: true})
+
+		require.NoError(t, err)
+		assert.Equal(t, 2, result.Checked)
+		require.Len(t, result.Discrepancies, 2)
+		for _, discrepancy := range result.Discrepancies {
+			assert.True(t, discrepancy.Repaired, discrepancy.Kind)
+			assert.Empty(t, discrepancy.Error)
+		}
+		items, _ := fakeRepo.GetLineItemsByBillID(context.TODO(), withMissingItem.ID)
+		assert.Equal(t, []*models.LineItem{missing}, items)
+		assert.Equal(t, models.BillStatusVoided, voided.Status)
+		assert.Equal(t, "duplicate", voided.VoidReason)
+	})
+
+	t.Run("when_workflow_cannot_be_queried_should_report_it_unavailable", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		mockTemporalClient := mocksCore.NewMockClient(ctrl)
+		fakeRepo := &repositorytest.FakeRepo{}
+		activities := NewReconciliationActivities(fakeRepo, mockTemporalClient, newTestActivities(t, fakeRepo))
+
+		bill := newOpenBill(t, fakeRepo)
+		mockTemporalClient.EXPECT().QueryWorkflow(gomock.Any(), bill.WorkflowID, "", GetBillQuery).
+			Return(nil, errors.New("workflow not found"))
+
+		result, err := activities.ReconcileBillBatch(context.TODO(), ReconcileBillBatchInput{Limit: 10, Repair: true})
+
+		require.NoError(t, err)
+		require.Len(t, result.Discrepancies, 1)
+		assert.Equal(t, models.DiscrepancyWorkflowUnavailable, result.Discrepancies[0].Kind)
+		assert.Equal(t, "workflow not found", result.Discrepancies[0].Error)
+		assert.False(t, result.Discrepancies[0].Repaired)
+	})
+}
+
+func TestReconciliationActivities_ReconcileDraftBills(t *testing.T) {
+	testCfg := &models.AppConfig{
+		Temporal: models.TemporalConfig{
+			TaskQueue: func() string { return "test-queue" },
+		},
+		Billing: models.BillingConfig{
+			Workflow: models.WorkflowConfig{
+				ContinueAsNewSignalThreshold:  func() int { return 1000 },
+				SearchAttributeTotalsInterval: func() int { return 0 },
+				LateUsageGraceWindow:          func() int { return 0 },
+			},
+			DraftReconciler: models.DraftReconcilerConfig{
+				MinAge:    func() int { return 60 },
+				BatchSize: func() int { return 10 },
+			},
+		},
+	}
+
+	newDraft := func(t *testing.T, fakeRepo *repositorytest.FakeRepo, createdAt, periodEnd time.Time) *models.Bill {
+		billID := uuid.Must(uuid.NewV4())
+		bill := &models.Bill{
+			ID:          billID,
+			CustomerID:  "customer-123",
+			Status:      models.BillStatusDraft,
+			WorkflowID:  "test-prefix-" + billID.String(),
+			PeriodStart: createdAt,
+			PeriodEnd:   periodEnd,
+			CreatedAt:   createdAt,
+		}
+		require.NoError(t, fakeRepo.CreateBill(context.TODO(), bill))
+		return bill
+	}
+
+	t.Run("should_start_workflows_of_orphaned_drafts_and_void_expired_ones", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		mockTemporalClient := mocksCore.NewMockClient(ctrl)
+		fakeRepo := &repositorytest.FakeRepo{}
+		activities := NewReconciliationActivities(fakeRepo, mockTemporalClient,
+			NewBillingActivities(fakeRepo, mocks.NewMockExchangeRatesService(ctrl), testCfg))
+
+		now := time.Now()
+		orphaned := newDraft(t, fakeRepo, now.Add(-time.Hour), now.Add(24*time.Hour))
+		expired := newDraft(t, fakeRepo, now.Add(-48*time.Hour), now.Add(-time.Hour))
+		// too recent, its workflow may still be starting
+		recent := newDraft(t, fakeRepo, now, now.Add(24*time.Hour))
+
+		mockTemporalClient.EXPECT().
+			ExecuteWorkflow(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, options client.StartWorkflowOptions, _ interface{}, _ ...interface{}) (client.WorkflowRun, error) {
+				assert.Equal(t, orphaned.WorkflowID, options.ID)
+				return nil, nil
+			})
+
+		result, err := activities.ReconcileDraftBills(context.TODO())
+
+		require.NoError(t, err)
+		assert.Equal(t, &models.DraftReconciliation{Checked: 2, Started: 1, Voided: 1}, result)
+		assert.Equal(t, models.BillStatusVoided, expired.Status)
+		assert.Equal(t, models.BillStatusDraft, orphaned.Status, "the workflow activates the draft")
+		assert.Equal(t, models.BillStatusDraft, recent.Status)
+	})
+
+	t.Run("when_temporal_client_fails_should_leave_draft_for_next_run", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		mockTemporalClient := mocksCore.NewMockClient(ctrl)
+		fakeRepo := &repositorytest.FakeRepo{}
+		activities := NewReconciliationActivities(fakeRepo, mockTemporalClient,
+			NewBillingActivities(fakeRepo, mocks.NewMockExchangeRatesService(ctrl), testCfg))
+
+		now := time.Now()
+		newDraft(t, fakeRepo, now.Add(-time.Hour), now.Add(24*time.Hour))
+		mockTemporalClient.EXPECT().
+			ExecuteWorkflow(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(nil, errors.New("temporal unavailable"))
+
+		result, err := activities.ReconcileDraftBills(context.TODO())
+
+		require.NoError(t, err)
+		assert.Equal(t, &models.DraftReconciliation{Checked: 1, Failed: 1}, result)
+	})
+}
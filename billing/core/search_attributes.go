@@ -0,0 +1,177 @@
+package core
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"encore.app/billing/models"
+	"github.com/shopspring/decimal"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// Custom search attributes of the bill workflow, they must be registered in the Temporal namespace
+var (
+	CustomerIDSearchAttribute = temporal.NewSearchAttributeKeyKeyword("CustomerId")
+	BillStatusSearchAttribute = temporal.NewSearchAttributeKeyKeyword("BillStatus")
+	PeriodEndSearchAttribute  = temporal.NewSearchAttributeKeyTime("PeriodEnd")
+	// TotalByCurrencySearchAttribute holds the unrounded line total of each currency as "<currency>:<amount>"
+	TotalByCurrencySearchAttribute = temporal.NewSearchAttributeKeyKeywordList("TotalByCurrency")
+)
+
+// IndexedSearchAttributes is the bill state last upserted as search attributes.
+// It is carried across runs, which inherit the search attributes of the run they continue.
+type IndexedSearchAttributes struct {
+	Status          models.BillStatus `json:"status"`
+	TotalByCurrency []string          `json:"total_by_currency"`
+	// TotalsUpsertedAt is when TotalByCurrency was last upserted
+	TotalsUpsertedAt time.Time `json:"totals_upserted_at"`
+}
+
+// billIndexer upserts the search attributes of the bill. The customer and period end are upserted once,
+// when the bill is first indexed, and the status on transitions. The totals change with every line item:
+// they are upserted with the status, otherwise at most once per interval, and a timer upserts the totals
+// changed meanwhile once the interval ends. Runs started before the throttle upsert every change.
+type billIndexer struct {
+	throttled bool
+	interval  time.Duration
+	indexed   *IndexedSearchAttributes
+	// flushPending is set while the timer upserting the totals at the end of the interval runs
+	flushPending bool
+}
+
+// index upserts the search attributes that changed since the previous upsert and are due
+func (ix *billIndexer) index(ctx workflow.Context, selector workflow.Selector, bill *models.Bill, lineTotals []models.LineTotalGroup) {
+	if !ix.throttled {
+		ix.indexed = upsertBillSearchAttributes(ctx, bill, lineTotals, ix.indexed, true)
+		return
+	}
+
+	now := workflow.Now(ctx)
+	statusChanged := ix.indexed == nil || ix.indexed.Status != bill.Status
+	nextTotals := time.Time{}
+	if ix.indexed != nil {
+		nextTotals = ix.indexed.TotalsUpsertedAt.Add(ix.interval)
+	}
+	totalsDue := statusChanged || !now.Before(nextTotals)
+	ix.indexed = upsertBillSearchAttributes(ctx, bill, lineTotals, ix.indexed, totalsDue)
+
+	if totalsDue || ix.flushPending || ix.indexed == nil ||
+		slices.Equal(ix.indexed.TotalByCurrency, formatTotalByCurrency(models.SumByCurrency(lineTotals))) {
+		return
+	}
+	// The loop of the workflow indexes the bill again once the timer fires
+	ix.flushPending = true
+	selector.AddFuture(workflow.NewTimer(ctx, nextTotals.Sub(now)), func(workflow.Future) {
+		ix.flushPending = false
+	})
+}
+
+// upsertBillSearchAttributes upserts the search attributes that changed since the previous upsert,
+// the totals only when totalsDue is set. Every attribute is upserted when previous is nil.
+// Failures are logged, the bill does not depend on its index.
+func upsertBillSearchAttributes(
+	ctx workflow.Context, bill *models.Bill, lineTotals []models.LineTotalGroup, previous *IndexedSearchAttributes,
+	totalsDue bool,
+) *IndexedSearchAttributes {
+	current := &IndexedSearchAttributes{
+		Status:          bill.Status,
+		TotalByCurrency: formatTotalByCurrency(models.SumByCurrency(lineTotals)),
+	}
+	if previous != nil {
+		current.TotalsUpsertedAt = previous.TotalsUpsertedAt
+		if !totalsDue {
+			current.TotalByCurrency = previous.TotalByCurrency
+		}
+	}
+
+	var updates []temporal.SearchAttributeUpdate
+	if previous == nil {
+		updates = append(updates,
+			CustomerIDSearchAttribute.ValueSet(bill.CustomerID),
+			PeriodEndSearchAttribute.ValueSet(bill.PeriodEnd))
+	}
+	if previous == nil || previous.Status != current.Status {
+		updates = append(updates, BillStatusSearchAttribute.ValueSet(string(current.Status)))
+	}
+	if previous == nil || !slices.Equal(previous.TotalByCurrency, current.TotalByCurrency) {
+		updates = append(updates, TotalByCurrencySearchAttribute.ValueSet(current.TotalByCurrency))
+		current.TotalsUpsertedAt = workflow.Now(ctx)
+	}
+	if len(updates) == 0 {
+		return current
+	}
+
+	if err := workflow.UpsertTypedSearchAttributes(ctx, updates...); err != nil {
+		workflow.GetLogger(ctx).Error("Failed to upsert search attributes", "bill_id", bill.ID, "error", err)
+		return previous
+	}
+	return current
+}
+
+// formatTotalByCurrency formats the totals as TotalByCurrency keywords, sorted by currency
+func formatTotalByCurrency(totals map[models.Currency]decimal.Decimal) []string {
+	keywords := make([]string, 0, len(totals))
+	for currency, amount := range totals {
+		keywords = append(keywords, fmt.Sprintf("%s:%s", currency, amount.String()))
+	}
+	slices.Sort(keywords)
+	return keywords
+}
+
+// parseTotalByCurrency parses TotalByCurrency keywords, malformed keywords are skipped
+func parseTotalByCurrency(keywords []string) map[models.Currency]decimal.Decimal {
+	totals := make(map[models.Currency]decimal.Decimal, len(keywords))
+	for _, keyword := range keywords {
+		currency, value, found := strings.Cut(keyword, ":")
+		if !found {
+			continue
+		}
+		amount, err := decimal.NewFromString(value)
+		if err != nil {
+			continue
+		}
+		totals[models.Currency(currency)] = amount
+	}
+	return totals
+}
+
+// billWorkflowQuery builds the visibility query listing the open and closing bill workflows matching the filter
+func billWorkflowQuery(filter models.BillWorkflowFilter) string {
+	conditions := []string{
+		fmt.Sprintf("WorkflowType = %s", quoteQueryValue("CreateBill")),
+		fmt.Sprintf("%s IN (%s, %s)", BillStatusSearchAttribute.GetName(),
+			quoteQueryValue(string(models.BillStatusOpen)), quoteQueryValue(string(models.BillStatusClosing))),
+	}
+	if filter.CustomerID != "" {
+		conditions = append(conditions,
+			fmt.Sprintf("%s = %s", CustomerIDSearchAttribute.GetName(), quoteQueryValue(filter.CustomerID)))
+	}
+	if filter.PeriodEndAfter != nil {
+		conditions = append(conditions, fmt.Sprintf("%s >= %s",
+			PeriodEndSearchAttribute.GetName(), quoteQueryValue(filter.PeriodEndAfter.UTC().Format(time.RFC3339Nano))))
+	}
+	if filter.PeriodEndBefore != nil {
+		conditions = append(conditions, fmt.Sprintf("%s < %s",
+			PeriodEndSearchAttribute.GetName(), quoteQueryValue(filter.PeriodEndBefore.UTC().Format(time.RFC3339Nano))))
+	}
+	return strings.Join(conditions, " AND ")
+}
+
+// quoteQueryValue quotes a string literal of a visibility query
+func quoteQueryValue(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+// decodeSearchAttribute decodes the named search attribute into valuePtr, it reports false when the attribute is not set
+func decodeSearchAttribute(attributes *commonpb.SearchAttributes, name string, valuePtr interface{}) bool {
+	payload, ok := attributes.GetIndexedFields()[name]
+	if !ok {
+		return false
+	}
+	return converter.GetDefaultDataConverter().FromPayload(payload, valuePtr) == nil
+}
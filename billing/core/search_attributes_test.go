@@ -0,0 +1,247 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"encore.app/billing/models"
+	"encore.dev/types/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestBillWorkflow_SearchAttributes(t *testing.T) {
+	t.Run("should_index_bill_then_upsert_changed_totals_and_status", func(t *testing.T) {
+		s := testsuite.WorkflowTestSuite{}
+		env := s.NewTestWorkflowEnvironment()
+		w := NewBillWorkflows(testCfg())
+
+		env.OnActivity((&BillingActivities{}).ActivateBill, mock.Anything, mock.Anything).
+			Return(models.BillStatusOpen, nil).Once()
+		env.OnActivity((&BillingActivities{}).AddLineItemToBill, mock.Anything, mock.Anything).
+			Return(nil).Once()
+		env.OnActivity((&BillingActivities{}).CloseBill, mock.Anything, mock.Anything).
+			Return(&models.Bill{}, nil).Once()
+
+		var upserts []temporal.SearchAttributes
+		env.OnUpsertTypedSearchAttributes(mock.Anything).Run(func(args mock.Arguments) {
+			upserts = append(upserts, args.Get(0).(temporal.SearchAttributes))
+		}).Return(nil)
+
+		start := time.Now()
+		env.SetStartTime(start)
+		bill := &models.Bill{
+			ID:          uuid.Must(uuid.NewV4()),
+			CustomerID:  "cust-1",
+			Status:      models.BillStatusDraft,
+			PeriodStart: start,
+			PeriodEnd:   start.Add(24 * time.Hour),
+		}
+
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(AddLineItemSignal, LineItemSignalData{LineItem: models.LineItem{
+				ID:        uuid.Must(uuid.NewV4()),
+				BillID:    bill.ID,
+				Currency:  models.USD,
+				Quantity:  decimal.NewFromInt(3),
+				UnitPrice: decimal.RequireFromString("2.5"),
+			}})
+		}, time.Minute)
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(CloseBillSignal, CloseBillSignalData{RequestedAt: start.Add(2 * time.Minute)})
+		}, 2*time.Minute)
+
+		env.ExecuteWorkflow(w.CreateBill, BillWorkflowInput{Bill: bill})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+		require.Len(t, upserts, 3)
+
+		customerID, _ := upserts[0].GetKeyword(CustomerIDSearchAttribute)
+		status, _ := upserts[0].GetKeyword(BillStatusSearchAttribute)
+		periodEnd, _ := upserts[0].GetTime(PeriodEndSearchAttribute)
+		totals, _ := upserts[0].GetKeywordList(TotalByCurrencySearchAttribute)
+		assert.Equal(t, "cust-1", customerID)
+		assert.Equal(t, "open", status)
+		assert.True(t, bill.PeriodEnd.Equal(periodEnd))
+		assert.Empty(t, totals)
+
+		// Only the totals changed with the line item
+		assert.Equal(t, 1, upserts[1].Size())
+		totals, _ = upserts[1].GetKeywordList(TotalByCurrencySearchAttribute)
+		assert.Equal(t, []string{"USD:7.5"}, totals)
+
+		assert.Equal(t, 1, upserts[2].Size())
+		status, _ = upserts[2].GetKeyword(BillStatusSearchAttribute)
+		assert.Equal(t, "closed", status)
+	})
+}
+
+func TestBillWorkflow_ThrottledSearchAttributes(t *testing.T) {
+	newLineItem := func(billID uuid.UUID) models.LineItem {
+		return models.LineItem{
+			ID:        uuid.Must(uuid.NewV4()),
+			BillID:    billID,
+			Currency:  models.USD,
+			Quantity:  decimal.NewFromInt(3),
+			UnitPrice: decimal.RequireFromString("2.5"),
+		}
+	}
+	throttledCfg := func() *models.AppConfig {
+		cfg := testCfg()
+		cfg.Billing.Workflow.SearchAttributeTotalsInterval = func() int { return 300 }
+		return cfg
+	}
+
+	t.Run("should_upsert_totals_changed_within_interval_once_it_ends", func(t *testing.T) {
+		s := testsuite.WorkflowTestSuite{}
+		env := s.NewTestWorkflowEnvironment()
+		w := NewBillWorkflows(throttledCfg())
+
+		env.OnActivity((&BillingActivities{}).ActivateBill, mock.Anything, mock.Anything).
+			Return(models.BillStatusOpen, nil).Once()
+		env.OnActivity((&BillingActivities{}).AddLineItemToBill, mock.Anything, mock.Anything).
+			Return(nil).Twice()
+		env.OnActivity((&BillingActivities{}).CloseBill, mock.Anything, mock.Anything).
+			Return(&models.Bill{}, nil).Once()
+
+		start := time.Now()
+		var upserts []temporal.SearchAttributes
+		var upsertedAt []time.Duration
+		env.OnUpsertTypedSearchAttributes(mock.Anything).Run(func(args mock.Arguments) {
+			upserts = append(upserts, args.Get(0).(temporal.SearchAttributes))
+			upsertedAt = append(upsertedAt, env.Now().Sub(start))
+		}).Return(nil)
+
+		env.SetStartTime(start)
+		bill := &models.Bill{
+			ID:          uuid.Must(uuid.NewV4()),
+			CustomerID:  "cust-1",
+			Status:      models.BillStatusDraft,
+			PeriodStart: start,
+			PeriodEnd:   start.Add(24 * time.Hour),
+		}
+
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(AddLineItemSignal, LineItemSignalData{LineItem: newLineItem(bill.ID)})
+		}, time.Minute)
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(AddLineItemSignal, LineItemSignalData{LineItem: newLineItem(bill.ID)})
+		}, 2*time.Minute)
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(CloseBillSignal, CloseBillSignalData{RequestedAt: start.Add(10 * time.Minute)})
+		}, 10*time.Minute)
+
+		env.ExecuteWorkflow(w.CreateBill, BillWorkflowInput{Bill: bill, Settings: newBillWorkflowSettings(throttledCfg(), bill)})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+		require.Len(t, upserts, 3)
+		assert.Equal(t, 4, upserts[0].Size())
+
+		// Both line items are upserted together when the interval of the first upsert ends
+		assert.Equal(t, 5*time.Minute, upsertedAt[1])
+		assert.Equal(t, 1, upserts[1].Size())
+		totals, _ := upserts[1].GetKeywordList(TotalByCurrencySearchAttribute)
+		assert.Equal(t, []string{"USD:15"}, totals)
+
+		assert.Equal(t, 1, upserts[2].Size())
+		status, _ := upserts[2].GetKeyword(BillStatusSearchAttribute)
+		assert.Equal(t, "closed", status)
+	})
+
+	t.Run("when_continued_should_upsert_status_transition_only", func(t *testing.T) {
+		s := testsuite.WorkflowTestSuite{}
+		env := s.NewTestWorkflowEnvironment()
+		w := NewBillWorkflows(throttledCfg())
+
+		env.OnActivity((&BillingActivities{}).CloseBill, mock.Anything, mock.Anything).
+			Return(&models.Bill{}, nil).Once()
+
+		var upserts []temporal.SearchAttributes
+		env.OnUpsertTypedSearchAttributes(mock.Anything).Run(func(args mock.Arguments) {
+			upserts = append(upserts, args.Get(0).(temporal.SearchAttributes))
+		}).Return(nil)
+
+		start := time.Now()
+		env.SetStartTime(start)
+		bill := &models.Bill{
+			ID:          uuid.Must(uuid.NewV4()),
+			CustomerID:  "cust-1",
+			Status:      models.BillStatusOpen,
+			PeriodStart: start,
+			PeriodEnd:   start.Add(24 * time.Hour),
+		}
+
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(CloseBillSignal, CloseBillSignalData{RequestedAt: start.Add(time.Minute)})
+		}, time.Minute)
+
+		env.ExecuteWorkflow(w.CreateBill, BillWorkflowInput{
+			Bill: bill,
+			Continued: &ContinuedBillState{
+				LineTotals: []models.LineTotalGroup{
+					{Currency: models.USD, LineAmount: decimal.RequireFromString("7.5"), Sum: decimal.RequireFromString("7.5"), Count: 1},
+				},
+				Runs: 1,
+				SearchAttributes: &IndexedSearchAttributes{
+					Status:           models.BillStatusOpen,
+					TotalByCurrency:  []string{"USD:7.5"},
+					TotalsUpsertedAt: start.Add(-time.Hour),
+				},
+			},
+			Settings: newBillWorkflowSettings(throttledCfg(), bill),
+		})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+		require.Len(t, upserts, 1)
+		assert.Equal(t, 1, upserts[0].Size())
+		status, _ := upserts[0].GetKeyword(BillStatusSearchAttribute)
+		assert.Equal(t, "closed", status)
+	})
+}
+
+func TestTotalByCurrency(t *testing.T) {
+	t.Run("should_round_trip_sorted_by_currency", func(t *testing.T) {
+		totals := map[models.Currency]decimal.Decimal{
+			models.USD: decimal.RequireFromString("10.125"),
+			models.GEL: decimal.NewFromInt(3),
+		}
+
+		keywords := formatTotalByCurrency(totals)
+
+		assert.Equal(t, []string{"GEL:3", "USD:10.125"}, keywords)
+		parsed := parseTotalByCurrency(keywords)
+		assert.True(t, totals[models.USD].Equal(parsed[models.USD]))
+		assert.True(t, totals[models.GEL].Equal(parsed[models.GEL]))
+	})
+
+	t.Run("should_skip_malformed_keywords", func(t *testing.T) {
+		assert.Empty(t, parseTotalByCurrency([]string{"USD", "USD:ten"}))
+	})
+}
+
+func TestBillWorkflowQuery(t *testing.T) {
+	t.Run("should_list_open_and_closing_bills_by_default", func(t *testing.T) {
+		assert.Equal(t, "WorkflowType = 'CreateBill' AND BillStatus IN ('open', 'closing')", billWorkflowQuery(models.BillWorkflowFilter{}))
+	})
+
+	t.Run("should_filter_by_customer_and_period_end", func(t *testing.T) {
+		after := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		before := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+
+		query := billWorkflowQuery(models.BillWorkflowFilter{
+			CustomerID:      `o'brien\`,
+			PeriodEndAfter:  &after,
+			PeriodEndBefore: &before,
+		})
+
+		assert.Equal(t, "WorkflowType = 'CreateBill' AND BillStatus IN ('open', 'closing') AND CustomerId = 'o\\'brien\\\\'"+
+			" AND PeriodEnd >= '2025-01-01T00:00:00Z' AND PeriodEnd < '2025-02-01T00:00:00Z'", query)
+	})
+}
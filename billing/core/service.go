@@ -5,28 +5,80 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"io"
+	"strings"
 	"time"
 
 	"encore.app/billing/ext_services"
 	"encore.app/billing/models"
 	"encore.app/billing/repository"
+	"encore.app/billing/statement"
+	"encore.app/billing/ubl"
+	"encore.app/billing/validation"
+	"encore.dev/beta/errs"
 	"encore.dev/rlog"
 	"encore.dev/types/uuid"
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/api/workflowservice/v1"
 	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/temporal"
 )
 
 //go:generate mockgen -package=mocks -destination=mocks/service_mock.go . Service
 type Service interface {
 	CreateBill(ctx context.Context, req *models.CreateBillRequest) (*models.Bill, error)
-	GetBillByID(ctx context.Context, id uuid.UUID) (*models.Bill, error)
+	GetBillByID(ctx context.Context, id uuid.UUID, opts models.GetBillOptions) (*models.Bill, error)
+	ListLineItems(ctx context.Context, billID uuid.UUID, filter models.LineItemFilter) ([]*models.LineItem, *models.LineItemCursor, error)
 	AddLineItemToBill(ctx context.Context, billId uuid.UUID, req *models.AddLineItemRequest) (*models.Bill, error)
+	UpdateLineItem(ctx context.Context, billID, lineItemID uuid.UUID, update models.LineItemUpdate) (*models.Bill, error)
+	RemoveLineItem(ctx context.Context, billID, lineItemID uuid.UUID, reason string) (*models.Bill, error)
 	CloseBill(ctx context.Context, id uuid.UUID) (*models.Bill, error)
+	FinalizeBill(ctx context.Context, id uuid.UUID) (*models.Bill, error)
+	VoidBill(ctx context.Context, id uuid.UUID, reason string) (*models.Bill, error)
+	ReopenBill(ctx context.Context, id uuid.UUID) (*models.Bill, error)
+	ListBillWorkflows(ctx context.Context, filter models.BillWorkflowFilter) ([]*models.BillWorkflowSummary, []byte, error)
+	EnsureReconciliationSchedule(ctx context.Context) error
+	EnsureDraftReconciliationSchedule(ctx context.Context) error
+	StartReconciliation(ctx context.Context, repair bool) (string, error)
+	ListReconciliationReports(ctx context.Context, limit int) ([]*models.ReconciliationReport, error)
+	GetReconciliationReport(ctx context.Context, id uuid.UUID) (*models.ReconciliationReport, error)
+	ListFailedOperations(ctx context.Context, filter models.FailedOperationFilter) ([]*models.FailedOperation, error)
+	GetFailedOperation(ctx context.Context, id uuid.UUID) (*models.FailedOperation, error)
+	RetryFailedOperation(ctx context.Context, id uuid.UUID) (*models.FailedOperation, error)
+	AcknowledgeFailedOperation(ctx context.Context, id uuid.UUID, note string) (*models.FailedOperation, error)
+	AuditBillTotals(ctx context.Context, id uuid.UUID) (*models.TotalsAudit, error)
+	GetBillHistory(ctx context.Context, id uuid.UUID) (*models.BillHistory, error)
+	RecordPayment(ctx context.Context, id uuid.UUID, req *models.RecordPaymentRequest) ([]*models.Journal, error)
+	IssueCreditNote(ctx context.Context, id uuid.UUID, req *models.IssueCreditNoteRequest) (*models.Journal, error)
+	GetBillLedger(ctx context.Context, id uuid.UUID) ([]*models.Journal, error)
+	GetTrialBalance(ctx context.Context, asOf time.Time) (*models.TrialBalance, error)
+	GetAgingReport(ctx context.Context, filter models.AgingReportFilter) (*models.AgingReport, error)
+	GetCustomerStatement(ctx context.Context, customerID string, filter models.CustomerStatementFilter) (*models.CustomerStatement, error)
+	RefreshRevenueRollups(ctx context.Context, from time.Time) (*models.RollupRefresh, error)
+	GetRevenueReport(ctx context.Context, filter models.RevenueReportFilter) (*models.RevenueReport, error)
+	GetMRRReport(ctx context.Context, filter models.MRRReportFilter) (*models.MRRReport, error)
+	GetBillRecognitionSchedules(ctx context.Context, id uuid.UUID) ([]*models.RecognitionSchedule, error)
+	GetDeferredRevenueReport(ctx context.Context, filter models.DeferredRevenueFilter) (*models.DeferredRevenueWaterfall, error)
+	CreateExport(ctx context.Context, req *models.CreateExportRequest) (*models.ExportJob, error)
+	GetExport(ctx context.Context, id uuid.UUID) (*models.ExportJob, error)
+	GetExportDownload(ctx context.Context, id uuid.UUID) (*models.ExportJob, error)
+	GetBillInvoice(ctx context.Context, id uuid.UUID) ([]byte, error)
+	GetCreditNoteDocument(ctx context.Context, id uuid.UUID, reference string) ([]byte, error)
+	ImportBankStatement(ctx context.Context, format models.StatementFormat, r io.Reader) (*models.StatementImport, error)
+	ListBankTransactions(ctx context.Context, filter models.BankTransactionFilter) ([]*models.BankTransaction, error)
+	GetBankTransaction(ctx context.Context, id uuid.UUID) (*models.BankTransaction, error)
+	MatchBankTransaction(ctx context.Context, id, billID uuid.UUID) (*models.BankTransaction, error)
+	DismissBankTransaction(ctx context.Context, id uuid.UUID, note string) (*models.BankTransaction, error)
+	GetCustomerProfile(ctx context.Context, customerID string) (*models.CustomerProfile, error)
+	UpsertCustomerProfile(ctx context.Context, customerID string, req *models.UpsertCustomerProfileRequest) (*models.CustomerProfile, error)
 }
 
 type service struct {
 	repository        repository.Repository
 	temporalClient    client.Client
 	conversionService ext_services.ExchangeRatesService
+	validator         *validation.Validator
 	cfg               *models.AppConfig
 }
 
@@ -43,6 +95,7 @@ func NewService(
 		temporalClient:    temporalClient,
 		repository:        repository,
 		conversionService: conversionService,
+		validator:         validation.NewValidator(cfg.Billing.Validation, cfg.Billing.Pagination, cfg.Billing.Analytics, time.Now),
 		cfg:               cfg,
 	}
 }
@@ -53,79 +106,139 @@ func (s *service) CreateBill(ctx context.Context, req *models.CreateBillRequest)
 		"period_start", req.PeriodStart,
 		"period_end", req.PeriodEnd)
 
+	profile, err := s.findCustomerProfile(ctx, req.CustomerID)
+	if err != nil {
+		log.Error("failed to retrieve customer profile", "error", err)
+		return nil, err
+	}
+	presentmentCurrency := s.resolvePresentmentCurrency(req, profile)
+	closeSettings, err := s.resolveCloseSettings(profile)
+	if err != nil {
+		log.Error("failed to resolve close settings", "error", err)
+		return nil, err
+	}
+	closeAt := closeSettings.CloseTime(req.PeriodEnd)
+
 	billID := uuid.Must(uuid.NewV4())
 	workflowID := fmt.Sprintf("%s%s", s.cfg.Billing.Workflow.WorkflowIDPrefix(), billID.String())
 
 	bill := &models.Bill{
-		ID:          billID,
-		CustomerID:  req.CustomerID,
-		Status:      models.BillStatusOpen,
-		PeriodStart: req.PeriodStart,
-		PeriodEnd:   req.PeriodEnd,
-		WorkflowID:  workflowID,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
+		ID:                  billID,
+		CustomerID:          req.CustomerID,
+		Status:              models.BillStatusDraft,
+		PeriodStart:         req.PeriodStart,
+		PeriodEnd:           req.PeriodEnd,
+		PresentmentCurrency: presentmentCurrency,
+		WorkflowID:          workflowID,
+		ClosePolicy:         closeSettings.Policy,
+		ScheduledCloseAt:    &closeAt,
+		CreatedAt:           time.Now(),
+		UpdatedAt:           time.Now(),
 	}
 
 	log = log.With("bill_id", billID.String()).With("workflow_id", workflowID)
-	log.Info("bill created, starting workflow")
-
-	// Calculate workflow timeout with configured buffer
-	workflowTimeout := req.PeriodEnd.Sub(req.PeriodStart) + time.Duration(s.cfg.Temporal.WorkflowExecutionTimeoutBuffer())*time.Second
 
-	workflowOptions := client.StartWorkflowOptions{
-		ID:                       workflowID,
-		TaskQueue:                s.cfg.Temporal.TaskQueue(),
-		WorkflowExecutionTimeout: workflowTimeout,
+	// The draft is saved first so the bill is not lost when the workflow cannot be started,
+	// the workflow opens it once started
+	if err = s.repository.CreateBill(ctx, bill); err != nil {
+		log.Error("failed to save draft bill", "error", err)
+		return nil, err
 	}
+	log.Info("draft bill saved, starting workflow",
+		"presentment_currency", presentmentCurrency,
+		"close_policy", closeSettings.Policy,
+		"scheduled_close_at", closeAt)
 
-	if _, err := s.temporalClient.ExecuteWorkflow(ctx, workflowOptions, (&BillWorkflows{}).CreateBill, BillWorkflowInput{Bill: bill}); err != nil {
-		log.Error("failed to start workflow", "error", err)
-		return nil, fmt.Errorf("failed to start workflow: %w", err)
+	if err = startBillWorkflow(ctx, s.temporalClient, s.cfg, bill); err != nil {
+		log.Warn("failed to start workflow, draft bill is left to the reconciler", "error", err)
+		return bill, nil
 	}
 
 	log.Info("workflow started successfully")
 	return bill, nil
 }
 
-func (s *service) GetBillByID(ctx context.Context, id uuid.UUID) (*models.Bill, error) {
-	log := rlog.With("module", "billing_core").With("bill_id", id.String())
+// startBillWorkflow starts the workflow of a draft bill. The running workflow is kept if it was already started.
+// Bill workflows have no execution timeout: they complete once the bill is closed or voided, and only after
+// the operations that exhausted their retries are resolved, which may take longer than any fixed bound.
+func startBillWorkflow(ctx context.Context, temporalClient client.Client, cfg *models.AppConfig, bill *models.Bill) error {
+	workflowOptions := client.StartWorkflowOptions{
+		ID:        bill.WorkflowID,
+		TaskQueue: cfg.Temporal.TaskQueue(),
+	}
+
+	input := BillWorkflowInput{Bill: bill, Settings: newBillWorkflowSettings(cfg, bill)}
+	if _, err := temporalClient.ExecuteWorkflow(ctx, workflowOptions, (&BillWorkflows{}).CreateBill, input); err != nil {
+		return fmt.Errorf("failed to start workflow: %w", err)
+	}
+	return nil
+}
+
+// GetBillByID retrieves a bill with its totals. Line items are only loaded when requested,
+// otherwise totals are calculated from line totals aggregated by the workflow or the database.
+func (s *service) GetBillByID(ctx context.Context, id uuid.UUID, opts models.GetBillOptions) (*models.Bill, error) {
+	log := rlog.With("module", "billing_core").With("bill_id", id.String()).With("include_line_items", opts.IncludeLineItems)
 	log.Info("retrieving bill by ID")
 
-	// Try to get bill from workflow first
-	workflowID := fmt.Sprintf("%s%s", s.cfg.Billing.Workflow.WorkflowIDPrefix(), id.String())
-	resp, err := s.temporalClient.QueryWorkflow(
-		ctx, workflowID, "", GetBillQuery,
-	)
-	if err == nil {
-		log.Info("bill found in workflow, querying workflow state")
-		bill := &models.Bill{}
-		if err = resp.Get(bill); err == nil {
+	if !opts.IncludeLineItems {
+		policy := models.RoundingPolicyFromConfig(s.cfg)
+		bill, lineTotals, err := s.getBillSummary(ctx, id, policy.Level == models.RoundingLevelLine)
+		if err != nil {
+			return nil, err
+		}
+		if bill.Total != nil && bill.Total.ComputedAt != nil {
+			log.Info("bill retrieved successfully with persisted totals")
+			return bill, nil
+		}
+		log.Info("calculating bill totals from line totals")
+		if err = computeTotals(ctx, s.conversionService, s.cfg, bill, lineTotals); err != nil {
+			log.Error("failed to calculate bill totals", "error", err)
+			return nil, err
+		}
+		log.Info("bill retrieved successfully")
+		return bill, nil
+	}
+
+	// Only open and closing bills are held by their workflow, bills in any other status are served from the database,
+	// closed bills with the totals persisted at close
+	bill, err := s.getStoredBill(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if bill.IsActive() {
+		workflowBill, lineTotals, err := s.queryWorkflowBill(ctx, id, opts)
+		switch {
+		case err != nil:
+			log.Info("bill not found in workflow, using database", "error", err)
+		case !workflowBill.IsActive():
+			// The workflow closed or voided the bill since it was read, read it again with the totals persisted at close
+			log.Info("bill in workflow is no longer active, using database", "status", workflowBill.Status)
+			if bill, err = s.getStoredBill(ctx, id); err != nil {
+				return nil, err
+			}
+		default:
 			log.Info("bill retrieved from workflow, calculating totals")
-			if err = s.calculateSum(ctx, bill); err != nil {
+			if err = computeTotals(ctx, s.conversionService, s.cfg, workflowBill, lineTotals); err != nil {
 				log.Error("failed to calculate bill totals", "error", err)
 				return nil, err
 			}
 			log.Info("bill retrieved successfully from workflow")
-			return bill, nil
+			return workflowBill, nil
 		}
-		log.Warn("failed to get bill from workflow response", "error", err)
 	}
 
-	// error when querying workflow or bill is closed
-	log.Info("bill not found in workflow, querying database")
-	bill, err := s.repository.GetBillByID(ctx, id)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			log.Warn("bill not found in database", "bill_id", id.String())
-			return nil, models.ErrBillNotFound
-		}
-		log.Error("database error when retrieving bill", "error", err)
+	if bill.LineItems, err = s.repository.GetLineItemsByBillID(ctx, id); err != nil {
+		log.Error("failed to load line items", "error", err)
 		return nil, err
 	}
 
+	if bill.Total != nil && bill.Total.ComputedAt != nil {
+		log.Info("bill retrieved successfully from database with persisted totals")
+		return bill, nil
+	}
+
 	log.Info("bill found in database, calculating totals")
-	if err = s.calculateSum(ctx, bill); err != nil {
+	if err = computeTotals(ctx, s.conversionService, s.cfg, bill, nil); err != nil {
 		log.Error("failed to calculate bill totals", "error", err)
 		return nil, err
 	}
@@ -134,6 +247,207 @@ func (s *service) GetBillByID(ctx context.Context, id uuid.UUID) (*models.Bill,
 	return bill, nil
 }
 
+// getBillSummary returns the bill without its line items, along with its line totals aggregated by the workflow
+// while the bill is active, otherwise by the database. Bills with totals persisted at close have no line totals.
+// Workflows always group line totals per line amount, the database only when byLineAmount is set, which callers
+// adding or removing single line items to the groups need.
+func (s *service) getBillSummary(
+	ctx context.Context, id uuid.UUID, byLineAmount bool,
+) (*models.Bill, []models.LineTotalGroup, error) {
+	log := rlog.With("module", "billing_core").With("bill_id", id.String())
+
+	bill, err := s.getStoredBill(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if bill.IsActive() {
+		workflowBill, lineTotals, err := s.queryWorkflowBill(ctx, id, models.GetBillOptions{})
+		switch {
+		case err != nil:
+			log.Info("bill not found in workflow, using database", "error", err)
+		case !workflowBill.IsActive():
+			// The workflow closed or voided the bill since it was read, read it again with the totals persisted at close
+			log.Info("bill in workflow is no longer active, using database", "status", workflowBill.Status)
+			if bill, err = s.getStoredBill(ctx, id); err != nil {
+				return nil, nil, err
+			}
+		default:
+			log.Info("bill summary retrieved from workflow")
+			return workflowBill, lineTotals, nil
+		}
+	}
+
+	if bill.Total != nil && bill.Total.ComputedAt != nil {
+		return bill, nil, nil
+	}
+	lineTotals, err := s.repository.GetLineTotalGroups(ctx, id, byLineAmount)
+	if err != nil {
+		log.Error("failed to aggregate line totals", "error", err)
+		return nil, nil, err
+	}
+	log.Info("bill summary retrieved from database")
+	return bill, lineTotals, nil
+}
+
+// getLineItem looks a line item of the bill up by ID. The workflow of an active bill is asked first,
+// as it holds the line items of its current run before they are persisted.
+func (s *service) getLineItem(ctx context.Context, bill *models.Bill, lineItemID uuid.UUID) (*models.LineItem, error) {
+	log := rlog.With("module", "billing_core").With("bill_id", bill.ID.String()).With("line_item_id", lineItemID.String())
+
+	if bill.IsActive() {
+		resp, err := s.temporalClient.QueryWorkflow(ctx, bill.WorkflowID, "", GetLineItemQuery, lineItemID)
+		if err != nil {
+			log.Info("line item query failed, using database", "error", err)
+		} else {
+			var item *models.LineItem
+			if err = resp.Get(&item); err != nil {
+				log.Warn("failed to get line item from workflow response", "error", err)
+			} else if item != nil {
+				return item, nil
+			}
+		}
+	}
+
+	item, err := s.repository.GetLineItem(ctx, bill.ID, lineItemID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warn("line item not found on bill")
+			return nil, models.ErrLineItemNotFound
+		}
+		log.Error("failed to get line item", "error", err)
+		return nil, err
+	}
+	return item, nil
+}
+
+// getStoredBill reads the bill from the database without its line items
+func (s *service) getStoredBill(ctx context.Context, id uuid.UUID) (*models.Bill, error) {
+	log := rlog.With("module", "billing_core").With("bill_id", id.String())
+	bill, err := s.repository.GetBillByID(ctx, id, models.GetBillOptions{})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warn("bill not found in database")
+			return nil, models.ErrBillNotFound
+		}
+		log.Error("database error when retrieving bill", "error", err)
+		return nil, err
+	}
+	return bill, nil
+}
+
+// queryWorkflowBill queries the bill state from its workflow, either with all line items or as a summary
+// with the aggregated line totals
+func (s *service) queryWorkflowBill(
+	ctx context.Context, id uuid.UUID, opts models.GetBillOptions,
+) (*models.Bill, []models.LineTotalGroup, error) {
+	log := rlog.With("module", "billing_core").With("bill_id", id.String())
+	workflowID := fmt.Sprintf("%s%s", s.cfg.Billing.Workflow.WorkflowIDPrefix(), id.String())
+
+	query := GetBillSummaryQuery
+	if opts.IncludeLineItems {
+		query = GetBillQuery
+	}
+	resp, err := s.temporalClient.QueryWorkflow(ctx, workflowID, "", query)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	log.Info("bill found in workflow, querying workflow state", "query", query)
+	if opts.IncludeLineItems {
+		bill := &models.Bill{}
+		if err = resp.Get(bill); err != nil {
+			log.Warn("failed to get bill from workflow response", "error", err)
+			return nil, nil, err
+		}
+		// Workflows that continued as new only hold the line items added since the last run
+		if int64(len(bill.LineItems)) < bill.LineItemCount {
+			if err = s.mergePersistedLineItems(ctx, bill); err != nil {
+				return nil, nil, err
+			}
+		}
+		return bill, nil, nil
+	}
+
+	summary := &models.BillSummary{}
+	if err = resp.Get(summary); err != nil || summary.Bill == nil {
+		log.Warn("failed to get bill summary from workflow response", "error", err)
+		return nil, nil, models.ErrBillNotFound
+	}
+	return summary.Bill, summary.LineTotals, nil
+}
+
+// mergePersistedLineItems prepends the persisted line items to the line items held by the workflow.
+// Line items held by the workflow that are not persisted yet are kept.
+func (s *service) mergePersistedLineItems(ctx context.Context, bill *models.Bill) error {
+	log := rlog.With("module", "billing_core").With("bill_id", bill.ID.String())
+	log.Info("loading line items of previous workflow runs",
+		"workflow_line_items", len(bill.LineItems),
+		"line_items_count", bill.LineItemCount)
+
+	persisted, err := s.repository.GetLineItemsByBillID(ctx, bill.ID)
+	if err != nil {
+		log.Error("failed to load persisted line items", "error", err)
+		return err
+	}
+
+	persistedIDs := make(map[uuid.UUID]bool, len(persisted))
+	for _, item := range persisted {
+		persistedIDs[item.ID] = true
+	}
+	lineItems := persisted
+	for _, item := range bill.LineItems {
+		if !persistedIDs[item.ID] {
+			lineItems = append(lineItems, item)
+		}
+	}
+	bill.LineItems = lineItems
+	return nil
+}
+
+// ListLineItems returns a page of the line items of a bill, and the cursor of the next page if there is one
+func (s *service) ListLineItems(
+	ctx context.Context, billID uuid.UUID, filter models.LineItemFilter,
+) ([]*models.LineItem, *models.LineItemCursor, error) {
+	log := rlog.With("module", "billing_core").With("bill_id", billID.String())
+	log.Info("listing line items", "currency", filter.Currency, "limit", filter.Limit)
+
+	bill, err := s.repository.GetBillByID(ctx, billID, models.GetBillOptions{})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warn("bill not found in database")
+			return nil, nil, models.ErrBillNotFound
+		}
+		log.Error("database error when retrieving bill", "error", err)
+		return nil, nil, err
+	}
+
+	// Fetch one extra line item to know whether there is a next page
+	limit := filter.Limit
+	filter.Limit = limit + 1
+	lineItems, err := s.repository.ListLineItems(ctx, billID, filter)
+	if err != nil {
+		log.Error("failed to list line items", "error", err)
+		return nil, nil, err
+	}
+
+	var next *models.LineItemCursor
+	if limit > 0 && len(lineItems) > limit {
+		lineItems = lineItems[:limit]
+		next = models.NewLineItemCursor(lineItems[limit-1])
+	}
+
+	// Line totals are persisted at close, open bills get them calculated on read
+	if bill.Total == nil || bill.Total.ComputedAt == nil {
+		policy := models.RoundingPolicyFromConfig(s.cfg)
+		for _, item := range lineItems {
+			item.Total = policy.RoundLine(item.Currency, item.UnitPrice.Mul(item.Quantity))
+		}
+	}
+
+	log.Info("line items listed successfully", "count", len(lineItems), "has_next_page", next != nil)
+	return lineItems, next, nil
+}
+
 func (s *service) AddLineItemToBill(ctx context.Context, billId uuid.UUID, req *models.AddLineItemRequest) (*models.Bill, error) {
 	log := rlog.With("module", "billing_core").With("bill_id", billId.String())
 	log.Info("adding line item to bill",
@@ -143,29 +457,39 @@ func (s *service) AddLineItemToBill(ctx context.Context, billId uuid.UUID, req *
 		"unit_price", req.UnitPrice)
 
 	// Get bill to check if it exists and is open
-	bill, err := s.GetBillByID(ctx, billId)
+	bill, lineTotals, err := s.getBillSummary(ctx, billId, true)
 	if err != nil {
-		log.Error("failed to get bill for closing", "error", err)
+		log.Error("failed to get bill for adding line item", "error", err)
 		return nil, err
 	}
 
-	if bill.IsClosed() {
-		log.Warn("attempted to add line item to closed bill")
-		return nil, models.ErrBillClosed
-	}
-
 	id, _ := uuid.NewV4()
+	now := time.Now()
 	signal := LineItemSignalData{
+		Audit: requestAudit(ctx),
 		LineItem: models.LineItem{
-			ID:          id,
-			BillID:      billId,
-			Description: req.Description,
-			Currency:    req.Currency,
-			Quantity:    req.Quantity,
-			UnitPrice:   req.UnitPrice,
-			CreatedAt:   time.Now(),
+			ID:           id,
+			BillID:       billId,
+			Description:  req.Description,
+			Currency:     req.Currency,
+			Quantity:     req.Quantity,
+			UnitPrice:    req.UnitPrice,
+			OccurredAt:   now,
+			Recurring:    req.Recurring,
+			ServiceStart: req.ServiceStart,
+			ServiceEnd:   req.ServiceEnd,
+			CreatedAt:    now,
 		},
 	}
+	if req.OccurredAt != nil {
+		signal.LineItem.OccurredAt = *req.OccurredAt
+	}
+
+	// Closing bills, and bills in their grace period, only accept line items that occurred within their period
+	if err = bill.AcceptLineItem(&signal.LineItem, now); err != nil {
+		log.Warn("bill does not accept the line item", "status", bill.Status, "occurred_at", signal.LineItem.OccurredAt)
+		return nil, err
+	}
 
 	log = log.With("workflow_id", bill.WorkflowID)
 	log.Info("sending line item signal to workflow")
@@ -176,23 +500,111 @@ func (s *service) AddLineItemToBill(ctx context.Context, billId uuid.UUID, req *
 		return nil, fmt.Errorf("failed to send signal to workflow: %w", err)
 	}
 
-	bill, err = s.GetBillByID(ctx, billId)
-	if err != nil {
-		log.Error("failed to get bill after sending close signal", "error", err)
+	lineTotals = models.MergeLineTotals(lineTotals, models.GroupLineTotals([]*models.LineItem{&signal.LineItem}))
+	if err = computeTotals(ctx, s.conversionService, s.cfg, bill, lineTotals); err != nil {
+		log.Error("failed to calculate bill totals", "error", err)
 		return nil, err
 	}
-	bill.AddLineItem(signal.LineItem)
 
 	log.Info("line item signal sent successfully")
 	return bill, nil
 }
 
+// UpdateLineItem changes the description, quantity or unit price of a line item on an open bill
+func (s *service) UpdateLineItem(
+	ctx context.Context, billID, lineItemID uuid.UUID, update models.LineItemUpdate,
+) (*models.Bill, error) {
+	log := rlog.With("module", "billing_core").With("bill_id", billID.String()).With("line_item_id", lineItemID.String())
+	log.Info("updating line item")
+
+	bill, lineTotals, err := s.getBillSummary(ctx, billID, true)
+	if err != nil {
+		log.Error("failed to get bill for updating line item", "error", err)
+		return nil, err
+	}
+
+	if !bill.IsOpen() {
+		log.Warn("attempted to update line item of bill that is not open", "status", bill.Status)
+		return nil, models.ErrBillClosed
+	}
+
+	previous, err := s.getLineItem(ctx, bill, lineItemID)
+	if err != nil {
+		return nil, err
+	}
+
+	updated := previous.Apply(update, time.Now())
+	if err = s.validator.ValidateLineItemAmounts(&updated); err != nil {
+		log.Warn("updated line item amounts are invalid", "error", err)
+		return nil, err
+	}
+
+	log = log.With("workflow_id", bill.WorkflowID)
+	log.Info("sending update line item signal to workflow")
+
+	signal := UpdateLineItemSignalData{Previous: *previous, LineItem: updated, Audit: requestAudit(ctx)}
+	err = s.temporalClient.SignalWorkflow(ctx, bill.WorkflowID, "", UpdateLineItemSignal, signal)
+	if err != nil {
+		log.Error("failed to send update line item signal to workflow", "error", err)
+		return nil, fmt.Errorf("failed to send update line item signal to workflow: %w", err)
+	}
+
+	lineTotals = models.MergeLineTotals(models.RemoveLineTotal(lineTotals, previous), models.GroupLineTotals([]*models.LineItem{&updated}))
+	if err = computeTotals(ctx, s.conversionService, s.cfg, bill, lineTotals); err != nil {
+		log.Error("failed to calculate bill totals", "error", err)
+		return nil, err
+	}
+
+	log.Info("update line item signal sent successfully")
+	return bill, nil
+}
+
+// RemoveLineItem removes a line item from an open bill. The line item is soft-deleted with the reason.
+func (s *service) RemoveLineItem(ctx context.Context, billID, lineItemID uuid.UUID, reason string) (*models.Bill, error) {
+	log := rlog.With("module", "billing_core").With("bill_id", billID.String()).With("line_item_id", lineItemID.String())
+	log.Info("removing line item", "reason", reason)
+
+	bill, lineTotals, err := s.getBillSummary(ctx, billID, true)
+	if err != nil {
+		log.Error("failed to get bill for removing line item", "error", err)
+		return nil, err
+	}
+
+	if !bill.IsOpen() {
+		log.Warn("attempted to remove line item of bill that is not open", "status", bill.Status)
+		return nil, models.ErrBillClosed
+	}
+
+	removed, err := s.getLineItem(ctx, bill, lineItemID)
+	if err != nil {
+		return nil, err
+	}
+
+	log = log.With("workflow_id", bill.WorkflowID)
+	log.Info("sending remove line item signal to workflow")
+
+	signal := RemoveLineItemSignalData{LineItem: *removed, Reason: reason, RequestedAt: time.Now(), Audit: requestAudit(ctx)}
+	err = s.temporalClient.SignalWorkflow(ctx, bill.WorkflowID, "", RemoveLineItemSignal, signal)
+	if err != nil {
+		log.Error("failed to send remove line item signal to workflow", "error", err)
+		return nil, fmt.Errorf("failed to send remove line item signal to workflow: %w", err)
+	}
+
+	if err = computeTotals(ctx, s.conversionService, s.cfg, bill, models.RemoveLineTotal(lineTotals, removed)); err != nil {
+		log.Error("failed to calculate bill totals", "error", err)
+		return nil, err
+	}
+
+	log.Info("remove line item signal sent successfully")
+	return bill, nil
+}
+
 func (s *service) CloseBill(ctx context.Context, id uuid.UUID) (*models.Bill, error) {
 	log := rlog.With("module", "billing_core").With("bill_id", id.String())
 	log.Info("closing bill")
 
 	// Get bill to check if it exists and is open
-	bill, err := s.GetBillByID(ctx, id)
+	bill, err := s.GetBillByID(ctx, id, models.GetBillOptions{})
 	if err != nil {
 		log.Error("failed to get bill for closing", "error", err)
 		return nil, err
@@ -202,11 +614,16 @@ func (s *service) CloseBill(ctx context.Context, id uuid.UUID) (*models.Bill, er
 		log.Info("bill is already closed")
 		return bill, nil
 	}
+	if !bill.IsActive() {
+		log.Warn("attempted to close bill that is not open or closing", "status", bill.Status)
+		return nil, models.ErrInvalidBillTransition
+	}
 
 	now := time.Now()
 	// Send close signal to workflow
 	signal := CloseBillSignalData{
 		RequestedAt: now,
+		Audit:       requestAudit(ctx),
 	}
 
 	log = log.With("workflow_id", bill.WorkflowID)
@@ -218,12 +635,6 @@ func (s *service) CloseBill(ctx context.Context, id uuid.UUID) (*models.Bill, er
 		return nil, fmt.Errorf("failed to send close signal to workflow: %w", err)
 	}
 
-	// Get bill to check if it exists and is open
-	bill, err = s.GetBillByID(ctx, id)
-	if err != nil {
-		log.Error("failed to get bill after sending close signal", "error", err)
-		return nil, err
-	}
 	bill.Status = models.BillStatusClosed
 	bill.ClosedAt = &now
 
@@ -231,18 +642,1386 @@ func (s *service) CloseBill(ctx context.Context, id uuid.UUID) (*models.Bill, er
 	return bill, nil
 }
 
-func (s *service) calculateSum(ctx context.Context, bill *models.Bill) error {
-	log := rlog.With("module", "billing_core").With("bill_id", bill.ID.String())
-	log.Info("calculating bill totals", "line_items_count", len(bill.LineItems))
+// FinalizeBill finalizes a closed bill, it cannot be voided or reopened anymore
+func (s *service) FinalizeBill(ctx context.Context, id uuid.UUID) (*models.Bill, error) {
+	log := rlog.With("module", "billing_core").With("bill_id", id.String())
+	log.Info("finalizing bill")
 
-	rates, err := s.conversionService.GetRates(ctx)
+	bill, err := s.GetBillByID(ctx, id, models.GetBillOptions{IncludeLineItems: true})
 	if err != nil {
-		log.Error("failed to get exchange rates", "error", err)
-		return err
+		log.Error("failed to get bill for finalizing", "error", err)
+		return nil, err
+	}
+
+	now := time.Now()
+	if err = bill.Transition(models.BillStatusFinalized, now); err != nil {
+		log.Warn("bill cannot be finalized", "status", bill.Status)
+		return nil, err
+	}
+
+	if err = s.repository.FinalizeBill(ctx, id, now); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warn("bill status changed before finalizing")
+			return nil, models.ErrInvalidBillTransition
+		}
+		log.Error("failed to finalize bill", "error", err)
+		return nil, err
+	}
+
+	log.Info("bill finalized successfully", "finalized_at", now)
+	return bill, nil
+}
+
+// VoidBill voids a bill that is not finalized. Open and closing bills are voided by their workflow,
+// other bills have no running workflow and are voided in the database directly.
+func (s *service) VoidBill(ctx context.Context, id uuid.UUID, reason string) (*models.Bill, error) {
+	log := rlog.With("module", "billing_core").With("bill_id", id.String())
+	log.Info("voiding bill", "reason", reason)
+
+	bill, err := s.GetBillByID(ctx, id, models.GetBillOptions{IncludeLineItems: true})
+	if err != nil {
+		log.Error("failed to get bill for voiding", "error", err)
+		return nil, err
+	}
+
+	wasActive := bill.IsActive()
+	now := time.Now()
+	if err = bill.Void(reason, now); err != nil {
+		log.Warn("bill cannot be voided", "status", bill.Status)
+		return nil, err
+	}
+
+	if wasActive {
+		log = log.With("workflow_id", bill.WorkflowID)
+		log.Info("sending void signal to workflow")
+
+		signal := VoidBillSignalData{Reason: reason, RequestedAt: now, Audit: requestAudit(ctx)}
+		err = s.temporalClient.SignalWorkflow(ctx, bill.WorkflowID, "", VoidBillSignal, signal)
+		if err != nil {
+			log.Error("failed to send void signal to workflow", "error", err)
+			return nil, fmt.Errorf("failed to send void signal to workflow: %w", err)
+		}
+	} else if err = s.repository.VoidBill(ctx, id, reason, now); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warn("bill status changed before voiding")
+			return nil, models.ErrInvalidBillTransition
+		}
+		log.Error("failed to void bill", "error", err)
+		return nil, err
+	}
+
+	log.Info("bill voided successfully", "voided_at", now)
+	return bill, nil
+}
+
+// ReopenBill reopens a closed bill that is not finalized by starting its workflow again.
+// The workflow reopens the bill in the database and discards the totals persisted at close.
+func (s *service) ReopenBill(ctx context.Context, id uuid.UUID) (*models.Bill, error) {
+	log := rlog.With("module", "billing_core").With("bill_id", id.String())
+	log.Info("reopening bill")
+
+	bill, err := s.GetBillByID(ctx, id, models.GetBillOptions{})
+	if err != nil {
+		log.Error("failed to get bill for reopening", "error", err)
+		return nil, err
+	}
+
+	if err = bill.Transition(models.BillStatusOpen, time.Now()); err != nil {
+		log.Warn("bill cannot be reopened", "status", bill.Status)
+		return nil, err
+	}
+
+	log = log.With("workflow_id", bill.WorkflowID)
+	log.Info("starting workflow for reopened bill")
+
+	// Like on creation the workflow has no execution timeout, bills reopened after their close time are closed on request only
+	workflowOptions := client.StartWorkflowOptions{
+		ID:        bill.WorkflowID,
+		TaskQueue: s.cfg.Temporal.TaskQueue(),
+	}
+
+	input := BillWorkflowInput{Bill: bill, Reopened: true, Settings: newBillWorkflowSettings(s.cfg, bill)}
+	if _, err = s.temporalClient.ExecuteWorkflow(ctx, workflowOptions, (&BillWorkflows{}).CreateBill, input); err != nil {
+		log.Error("failed to start workflow for reopened bill", "error", err)
+		return nil, fmt.Errorf("failed to start workflow: %w", err)
+	}
+
+	log.Info("bill reopened successfully")
+	return bill, nil
+}
+
+// ListBillWorkflows lists open and closing bills from the search attributes of their workflow, without reading the database.
+// It returns the token of the next page, empty on the last page.
+func (s *service) ListBillWorkflows(
+	ctx context.Context, filter models.BillWorkflowFilter,
+) ([]*models.BillWorkflowSummary, []byte, error) {
+	log := rlog.With("module", "billing_core")
+	query := billWorkflowQuery(filter)
+	log.Info("listing bill workflows", "query", query, "limit", filter.Limit)
+
+	resp, err := s.temporalClient.ListWorkflow(ctx, &workflowservice.ListWorkflowExecutionsRequest{
+		Query:         query,
+		PageSize:      int32(filter.Limit),
+		NextPageToken: filter.PageToken,
+	})
+	if err != nil {
+		log.Error("failed to list bill workflows", "error", err)
+		return nil, nil, fmt.Errorf("failed to list workflows: %w", err)
 	}
-	log.Info("exchange rates retrieved successfully")
 
-	if err = bill.CalculateSum(rates); err != nil {
+	prefix := s.cfg.Billing.Workflow.WorkflowIDPrefix()
+	bills := make([]*models.BillWorkflowSummary, 0, len(resp.GetExecutions()))
+	for _, execution := range resp.GetExecutions() {
+		workflowID := execution.GetExecution().GetWorkflowId()
+		billID, err := uuid.FromString(strings.TrimPrefix(workflowID, prefix))
+		if err != nil {
+			log.Warn("skipping workflow without bill ID", "workflow_id", workflowID)
+			continue
+		}
+
+		bill := &models.BillWorkflowSummary{
+			BillID:     billID,
+			WorkflowID: workflowID,
+			RunID:      execution.GetExecution().GetRunId(),
+			StartedAt:  execution.GetStartTime().AsTime(),
+		}
+		attributes := execution.GetSearchAttributes()
+		var status string
+		var periodEnd time.Time
+		var totalByCurrency []string
+		decodeSearchAttribute(attributes, CustomerIDSearchAttribute.GetName(), &bill.CustomerID)
+		if decodeSearchAttribute(attributes, BillStatusSearchAttribute.GetName(), &status) {
+			bill.Status = models.BillStatus(status)
+		}
+		if decodeSearchAttribute(attributes, PeriodEndSearchAttribute.GetName(), &periodEnd) {
+			bill.PeriodEnd = &periodEnd
+		}
+		decodeSearchAttribute(attributes, TotalByCurrencySearchAttribute.GetName(), &totalByCurrency)
+		bill.TotalByCurrency = parseTotalByCurrency(totalByCurrency)
+		bills = append(bills, bill)
+	}
+
+	log.Info("bill workflows listed successfully", "count", len(bills))
+	return bills, resp.GetNextPageToken(), nil
+}
+
+// EnsureReconciliationSchedule creates the Temporal schedule running ReconcileBills at the configured interval.
+// An existing schedule is left untouched.
+func (s *service) EnsureReconciliationSchedule(ctx context.Context) error {
+	reconciliationCfg := s.cfg.Billing.Reconciliation
+	return s.ensureSchedule(ctx, ReconciliationScheduleID, time.Duration(reconciliationCfg.Interval())*time.Second,
+		(&BillWorkflows{}).ReconcileBills, ReconcileBillsInput{Repair: reconciliationCfg.Repair()})
+}
+
+// EnsureDraftReconciliationSchedule creates the Temporal schedule running ReconcileDraftBills at the configured
+// interval, so a single run reconciles the drafts whichever instances are up. An existing schedule is left untouched.
+func (s *service) EnsureDraftReconciliationSchedule(ctx context.Context) error {
+	return s.ensureSchedule(ctx, DraftReconciliationScheduleID,
+		time.Duration(s.cfg.Billing.DraftReconciler.Interval())*time.Second, (&BillWorkflows{}).ReconcileDraftBills)
+}
+
+// ensureSchedule creates the schedule running the workflow with the arguments every interval, skipping a run
+// while the previous one is still running. An existing schedule is left untouched.
+func (s *service) ensureSchedule(
+	ctx context.Context, id string, interval time.Duration, workflow interface{}, args ...interface{},
+) error {
+	log := rlog.With("module", "billing_core").With("schedule_id", id)
+	log.Info("ensuring schedule", "interval", interval)
+
+	_, err := s.temporalClient.ScheduleClient().Create(ctx, client.ScheduleOptions{
+		ID: id,
+		Spec: client.ScheduleSpec{
+			Intervals: []client.ScheduleIntervalSpec{{Every: interval}},
+		},
+		Action: &client.ScheduleWorkflowAction{
+			ID:        id,
+			Workflow:  workflow,
+			Args:      args,
+			TaskQueue: s.cfg.Temporal.TaskQueue(),
+		},
+		Overlap: enumspb.SCHEDULE_OVERLAP_POLICY_SKIP,
+	})
+	if errors.Is(err, temporal.ErrScheduleAlreadyRunning) {
+		log.Info("schedule already exists")
+		return nil
+	}
+	if err != nil {
+		log.Error("failed to create schedule", "error", err)
+		return fmt.Errorf("failed to create schedule %s: %w", id, err)
+	}
+
+	log.Info("schedule created")
+	return nil
+}
+
+// StartReconciliation runs ReconcileBills on demand and returns its workflow ID, the report is saved when it completes
+func (s *service) StartReconciliation(ctx context.Context, repair bool) (string, error) {
+	log := rlog.With("module", "billing_core")
+	log.Info("starting reconciliation", "repair", repair)
+
+	workflowOptions := client.StartWorkflowOptions{
+		ID:        fmt.Sprintf("%s-%d", ReconciliationScheduleID, time.Now().UnixNano()),
+		TaskQueue: s.cfg.Temporal.TaskQueue(),
+	}
+	_, err := s.temporalClient.ExecuteWorkflow(ctx, workflowOptions, (&BillWorkflows{}).ReconcileBills, ReconcileBillsInput{Repair: repair})
+	if err != nil {
+		log.Error("failed to start reconciliation workflow", "error", err)
+		return "", fmt.Errorf("failed to start workflow: %w", err)
+	}
+
+	log.Info("reconciliation started", "workflow_id", workflowOptions.ID)
+	return workflowOptions.ID, nil
+}
+
+func (s *service) ListReconciliationReports(ctx context.Context, limit int) ([]*models.ReconciliationReport, error) {
+	log := rlog.With("module", "billing_core")
+	log.Info("listing reconciliation reports", "limit", limit)
+
+	reports, err := s.repository.ListReconciliationReports(ctx, limit)
+	if err != nil {
+		log.Error("failed to list reconciliation reports", "error", err)
+		return nil, err
+	}
+
+	log.Info("reconciliation reports listed successfully", "count", len(reports))
+	return reports, nil
+}
+
+func (s *service) GetReconciliationReport(ctx context.Context, id uuid.UUID) (*models.ReconciliationReport, error) {
+	log := rlog.With("module", "billing_core").With("report_id", id.String())
+	log.Info("getting reconciliation report")
+
+	report, err := s.repository.GetReconciliationReport(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warn("reconciliation report not found")
+			return nil, models.ErrReconciliationReportNotFound
+		}
+		log.Error("failed to get reconciliation report", "error", err)
+		return nil, err
+	}
+
+	log.Info("reconciliation report retrieved successfully", "discrepancies", len(report.Discrepancies))
+	return report, nil
+}
+
+func (s *service) ListFailedOperations(ctx context.Context, filter models.FailedOperationFilter) ([]*models.FailedOperation, error) {
+	log := rlog.With("module", "billing_core")
+	log.Info("listing failed operations", "status", filter.Status, "bill_id", filter.BillID, "limit", filter.Limit)
+
+	ops, err := s.repository.ListFailedOperations(ctx, filter)
+	if err != nil {
+		log.Error("failed to list failed operations", "error", err)
+		return nil, err
+	}
+
+	log.Info("failed operations listed successfully", "count", len(ops))
+	return ops, nil
+}
+
+func (s *service) GetFailedOperation(ctx context.Context, id uuid.UUID) (*models.FailedOperation, error) {
+	log := rlog.With("module", "billing_core").With("failed_operation_id", id.String())
+	log.Info("getting failed operation")
+
+	op, err := s.repository.GetFailedOperation(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warn("failed operation not found")
+			return nil, models.ErrFailedOperationNotFound
+		}
+		log.Error("failed to get failed operation", "error", err)
+		return nil, err
+	}
+
+	log.Info("failed operation retrieved successfully", "status", op.Status, "attempts", len(op.Attempts))
+	return op, nil
+}
+
+// RetryFailedOperation asks the bill workflow to execute the pending failed operation again.
+// Operations whose bill workflow is no longer running are retried from their database record by a workflow of their own.
+// The retry is asynchronous: the operation is resolved, or gets another attempt, once the workflow executed it.
+func (s *service) RetryFailedOperation(ctx context.Context, id uuid.UUID) (*models.FailedOperation, error) {
+	log := rlog.With("module", "billing_core").With("failed_operation_id", id.String())
+	log.Info("retrying failed operation")
+
+	op, err := s.GetFailedOperation(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if op.Status != models.FailedOperationStatusPending {
+		log.Warn("failed operation is not pending", "status", op.Status)
+		return nil, models.ErrFailedOperationNotPending
+	}
+
+	log = log.With("workflow_id", op.WorkflowID)
+	log.Info("sending retry signal to workflow")
+
+	signal := RetryFailedOperationSignalData{ID: op.ID, Audit: requestAudit(ctx)}
+	err = s.temporalClient.SignalWorkflow(ctx, op.WorkflowID, "", RetryFailedOperationSignal, signal)
+	if err != nil {
+		var notFound *serviceerror.NotFound
+		if errors.As(err, &notFound) {
+			log.Warn("bill workflow is not running, starting failed operation retry workflow")
+			return s.startFailedOperationRetry(ctx, op)
+		}
+		log.Error("failed to send retry signal to workflow", "error", err)
+		return nil, fmt.Errorf("failed to send retry signal to workflow: %w", err)
+	}
+
+	log.Info("failed operation retry requested successfully")
+	return op, nil
+}
+
+// startFailedOperationRetry starts the workflow retrying the failed operation from its database record.
+// A retry already running for the operation is kept.
+func (s *service) startFailedOperationRetry(ctx context.Context, op *models.FailedOperation) (*models.FailedOperation, error) {
+	log := rlog.With("module", "billing_core").With("failed_operation_id", op.ID.String())
+
+	workflowOptions := client.StartWorkflowOptions{
+		ID:        FailedOperationRetryWorkflowIDPrefix + op.ID.String(),
+		TaskQueue: s.cfg.Temporal.TaskQueue(),
+	}
+	input := RetryFailedOperationInput{Operation: op, Audit: requestAudit(ctx)}
+	if _, err := s.temporalClient.ExecuteWorkflow(ctx, workflowOptions, (&BillWorkflows{}).RetryFailedOperation, input); err != nil {
+		log.Error("failed to start failed operation retry workflow", "error", err)
+		return nil, fmt.Errorf("failed to start workflow: %w", err)
+	}
+
+	log.Info("failed operation retry requested successfully", "workflow_id", workflowOptions.ID)
+	return op, nil
+}
+
+// AcknowledgeFailedOperation accepts that the pending failed operation is not persisted, so its bill workflow
+// can complete. Operations whose workflow is no longer running are acknowledged in the database directly.
+func (s *service) AcknowledgeFailedOperation(ctx context.Context, id uuid.UUID, note string) (*models.FailedOperation, error) {
+	log := rlog.With("module", "billing_core").With("failed_operation_id", id.String())
+	log.Info("acknowledging failed operation", "note", note)
+
+	op, err := s.GetFailedOperation(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if op.Status != models.FailedOperationStatusPending {
+		log.Warn("failed operation is not pending", "status", op.Status)
+		return nil, models.ErrFailedOperationNotPending
+	}
+
+	log = log.With("workflow_id", op.WorkflowID)
+	log.Info("sending acknowledge signal to workflow")
+
+	now := time.Now()
+	signal := AcknowledgeFailedOperationSignalData{ID: op.ID, Note: note, RequestedAt: now}
+	err = s.temporalClient.SignalWorkflow(ctx, op.WorkflowID, "", AcknowledgeFailedOperationSignal, signal)
+	var notFound *serviceerror.NotFound
+	if errors.As(err, &notFound) {
+		log.Warn("bill workflow is not running, acknowledging failed operation in database")
+		err = s.repository.ResolveFailedOperation(ctx, op.ID, models.FailedOperationStatusAcknowledged, note, now)
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warn("failed operation resolved before acknowledging")
+			return nil, models.ErrFailedOperationNotPending
+		}
+	}
+	if err != nil {
+		log.Error("failed to acknowledge failed operation", "error", err)
+		return nil, fmt.Errorf("failed to acknowledge failed operation: %w", err)
+	}
+
+	op.Resolve(models.FailedOperationStatusAcknowledged, note, now)
+	log.Info("failed operation acknowledged successfully")
+	return op, nil
+}
+
+// findCustomerProfile returns the profile of the customer, or nil when the customer has none
+func (s *service) findCustomerProfile(ctx context.Context, customerID string) (*models.CustomerProfile, error) {
+	profile, err := s.repository.GetCustomerProfile(ctx, customerID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return profile, err
+}
+
+// resolvePresentmentCurrency picks the bill's presentment currency from the request,
+// then the customer profile, then the configured default
+func (s *service) resolvePresentmentCurrency(req *models.CreateBillRequest, profile *models.CustomerProfile) models.Currency {
+	log := rlog.With("module", "billing_core").With("customer_id", req.CustomerID)
+
+	if req.PresentmentCurrency != "" {
+		log.Debug("using presentment currency from request", "presentment_currency", req.PresentmentCurrency)
+		return req.PresentmentCurrency
+	}
+
+	if profile != nil {
+		log.Debug("using presentment currency from customer profile", "presentment_currency", profile.PresentmentCurrency)
+		return profile.PresentmentCurrency
+	}
+
+	currency := models.Currency(s.cfg.Billing.DefaultPresentmentCurrency())
+	log.Debug("customer profile not found, using default presentment currency", "presentment_currency", currency)
+	return currency
+}
+
+// resolveCloseSettings picks the close policy and timezone from the customer profile, then the configured defaults
+func (s *service) resolveCloseSettings(profile *models.CustomerProfile) (models.CloseSettings, error) {
+	settings, err := models.CloseSettingsFromConfig(s.cfg)
+	if err != nil {
+		return settings, err
+	}
+	if profile == nil {
+		return settings, nil
+	}
+
+	if profile.ClosePolicy != "" {
+		settings.Policy = profile.ClosePolicy
+	}
+	if profile.Timezone != "" {
+		if settings.Location, err = time.LoadLocation(profile.Timezone); err != nil {
+			return settings, fmt.Errorf("invalid timezone %q in customer profile: %w", profile.Timezone, err)
+		}
+	}
+	return settings, nil
+}
+
+func (s *service) GetCustomerProfile(ctx context.Context, customerID string) (*models.CustomerProfile, error) {
+	log := rlog.With("module", "billing_core").With("customer_id", customerID)
+	log.Info("retrieving customer profile")
+
+	profile, err := s.repository.GetCustomerProfile(ctx, customerID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warn("customer profile not found")
+			return nil, models.ErrCustomerProfileNotFound
+		}
+		log.Error("database error when retrieving customer profile", "error", err)
+		return nil, err
+	}
+
+	log.Info("customer profile retrieved successfully")
+	return profile, nil
+}
+
+func (s *service) UpsertCustomerProfile(
+	ctx context.Context, customerID string, req *models.UpsertCustomerProfileRequest,
+) (*models.CustomerProfile, error) {
+	log := rlog.With("module", "billing_core").With("customer_id", customerID)
+	log.Info("upserting customer profile",
+		"presentment_currency", req.PresentmentCurrency,
+		"timezone", req.Timezone,
+		"close_policy", req.ClosePolicy)
+
+	now := time.Now()
+	profile := &models.CustomerProfile{
+		CustomerID:          customerID,
+		PresentmentCurrency: req.PresentmentCurrency,
+		Timezone:            req.Timezone,
+		ClosePolicy:         req.ClosePolicy,
+		Party:               req.Party,
+		CreatedAt:           now,
+		UpdatedAt:           now,
+	}
+	if err := s.repository.UpsertCustomerProfile(ctx, profile); err != nil {
+		log.Error("failed to upsert customer profile", "error", err)
+		return nil, err
+	}
+
+	log.Info("customer profile upserted successfully")
+	return profile, nil
+}
+
+// AuditBillTotals recomputes the totals of a closed bill with current exchange rates
+// and compares them with the totals persisted at close
+func (s *service) AuditBillTotals(ctx context.Context, id uuid.UUID) (*models.TotalsAudit, error) {
+	log := rlog.With("module", "billing_core").With("bill_id", id.String())
+	log.Info("auditing persisted bill totals")
+
+	bill, err := s.repository.GetBillByID(ctx, id, models.GetBillOptions{IncludeLineItems: true})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warn("bill not found in database")
+			return nil, models.ErrBillNotFound
+		}
+		log.Error("database error when retrieving bill", "error", err)
+		return nil, err
+	}
+
+	if bill.Total == nil || bill.Total.ComputedAt == nil {
+		log.Warn("bill has no persisted totals to audit", "status", bill.Status)
+		return nil, models.ErrTotalsNotPersisted
+	}
+
+	rates, err := s.conversionService.GetRates(ctx)
+	if err != nil {
+		log.Error("failed to get exchange rates", "error", err)
+		return nil, err
+	}
+
+	audit, err := bill.AuditTotals(rates, models.RoundingPolicyFromConfig(s.cfg))
+	if err != nil {
+		log.Error("failed to recompute bill totals", "error", err)
+		return nil, err
+	}
+
+	log.Info("bill totals audit completed", "matches", audit.Matches, "discrepancies", len(audit.Discrepancies))
+	return audit, nil
+}
+
+// GetBillHistory returns the audit events of the bill with their hash chain verified
+func (s *service) GetBillHistory(ctx context.Context, id uuid.UUID) (*models.BillHistory, error) {
+	log := rlog.With("module", "billing_core").With("bill_id", id.String())
+	log.Info("getting bill history")
+
+	if _, err := s.repository.GetBillByID(ctx, id, models.GetBillOptions{}); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warn("bill not found in database")
+			return nil, models.ErrBillNotFound
+		}
+		log.Error("database error when retrieving bill", "error", err)
+		return nil, err
+	}
+
+	events, err := s.repository.ListAuditEvents(ctx, id)
+	if err != nil {
+		log.Error("failed to list audit events", "error", err)
+		return nil, err
+	}
+
+	history := models.NewBillHistory(id, events)
+	if !history.Verified {
+		log.Error("bill audit trail failed verification", "error", history.VerificationError)
+	}
+	log.Info("bill history retrieved successfully", "events", len(events), "verified", history.Verified)
+	return history, nil
+}
+
+// RecordPayment posts the payment of a closed or finalized bill against its receivable,
+// with the FX difference between the rate the receivable was booked and the current rate
+func (s *service) RecordPayment(ctx context.Context, id uuid.UUID, req *models.RecordPaymentRequest) ([]*models.Journal, error) {
+	log := rlog.With("module", "billing_core").With("bill_id", id.String()).With("reference", req.Reference)
+	log.Info("recording payment", "amount", req.Amount, "currency", req.Currency)
+
+	settings, err := models.LedgerSettingsFromConfig(s.cfg)
+	if err != nil {
+		log.Error("invalid ledger configuration", "error", err)
+		return nil, err
+	}
+	rates, err := s.conversionService.GetRates(ctx)
+	if err != nil {
+		log.Error("failed to get exchange rates", "error", err)
+		return nil, err
+	}
+	payment := models.Payment{
+		Reference:  req.Reference,
+		Currency:   req.Currency,
+		Amount:     req.Amount,
+		ReceivedAt: time.Now(),
+	}
+	if req.ReceivedAt != nil {
+		payment.ReceivedAt = *req.ReceivedAt
+	}
+
+	journals, err := s.repository.PostBillJournals(ctx, id,
+		func(status models.BillStatus, ledger []*models.Journal) ([]*models.Journal, error) {
+			if status != models.BillStatusClosed && status != models.BillStatusFinalized {
+				return nil, models.ErrBillNotSettleable
+			}
+			return models.NewPaymentJournals(id, ledger, payment, rates, settings)
+		})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warn("bill not found in database")
+			return nil, models.ErrBillNotFound
+		}
+		log.Error("failed to post payment journals", "error", err)
+		return nil, err
+	}
+
+	log.Info("payment recorded successfully", "journals", len(journals))
+	return journals, nil
+}
+
+// IssueCreditNote posts the credit note of a closed or finalized bill, reducing its revenue and receivable
+func (s *service) IssueCreditNote(ctx context.Context, id uuid.UUID, req *models.IssueCreditNoteRequest) (*models.Journal, error) {
+	log := rlog.With("module", "billing_core").With("bill_id", id.String()).With("reference", req.Reference)
+	log.Info("issuing credit note", "amount", req.Amount, "currency", req.Currency)
+
+	settings, err := models.LedgerSettingsFromConfig(s.cfg)
+	if err != nil {
+		log.Error("invalid ledger configuration", "error", err)
+		return nil, err
+	}
+	note := models.CreditNote{
+		Reference: req.Reference,
+		Currency:  req.Currency,
+		Amount:    req.Amount,
+		Reason:    req.Reason,
+		IssuedAt:  time.Now(),
+	}
+
+	journals, err := s.repository.PostBillJournals(ctx, id,
+		func(status models.BillStatus, ledger []*models.Journal) ([]*models.Journal, error) {
+			if status != models.BillStatusClosed && status != models.BillStatusFinalized {
+				return nil, models.ErrBillNotSettleable
+			}
+			journal, err := models.NewCreditNoteJournal(id, ledger, note, settings)
+			if err != nil {
+				return nil, err
+			}
+			return []*models.Journal{journal}, nil
+		})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warn("bill not found in database")
+			return nil, models.ErrBillNotFound
+		}
+		log.Error("failed to post credit note journal", "error", err)
+		return nil, err
+	}
+
+	log.Info("credit note issued successfully")
+	return journals[0], nil
+}
+
+// GetBillLedger returns the journals posted for the bill
+func (s *service) GetBillLedger(ctx context.Context, id uuid.UUID) ([]*models.Journal, error) {
+	log := rlog.With("module", "billing_core").With("bill_id", id.String())
+	log.Info("getting bill ledger")
+
+	if _, err := s.repository.GetBillByID(ctx, id, models.GetBillOptions{}); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warn("bill not found in database")
+			return nil, models.ErrBillNotFound
+		}
+		log.Error("database error when retrieving bill", "error", err)
+		return nil, err
+	}
+
+	journals, err := s.repository.ListBillJournals(ctx, id)
+	if err != nil {
+		log.Error("failed to list bill journals", "error", err)
+		return nil, err
+	}
+
+	log.Info("bill ledger retrieved successfully", "journals", len(journals))
+	return journals, nil
+}
+
+// GetTrialBalance returns the balances of the ledger accounts up to asOf in the functional currency
+// and checks that debits equal credits
+func (s *service) GetTrialBalance(ctx context.Context, asOf time.Time) (*models.TrialBalance, error) {
+	log := rlog.With("module", "billing_core").With("as_of", asOf)
+	log.Info("getting trial balance")
+
+	settings, err := models.LedgerSettingsFromConfig(s.cfg)
+	if err != nil {
+		log.Error("invalid ledger configuration", "error", err)
+		return nil, err
+	}
+
+	balance, err := s.repository.GetTrialBalance(ctx, asOf, settings.FunctionalCurrency)
+	if err != nil {
+		log.Error("failed to get trial balance", "error", err)
+		return nil, err
+	}
+	if !balance.Balanced {
+		log.Error("trial balance is unbalanced",
+			"total_debit", balance.TotalDebit,
+			"total_credit", balance.TotalCredit,
+			"unbalanced_journals", len(balance.UnbalancedJournals))
+	}
+
+	log.Info("trial balance retrieved successfully", "lines", len(balance.Lines), "balanced", balance.Balanced)
+	return balance, nil
+}
+
+// GetAgingReport buckets the receivables open at the filter time by the days they are past due,
+// due the configured payment terms after their bill closed, in the reporting currency at the current rates
+func (s *service) GetAgingReport(ctx context.Context, filter models.AgingReportFilter) (*models.AgingReport, error) {
+	log := rlog.With("module", "billing_core").With("as_of", filter.AsOf)
+	log.Info("getting aging report", "customer_id", filter.CustomerID, "reporting_currency", filter.ReportingCurrency)
+
+	settings, err := models.LedgerSettingsFromConfig(s.cfg)
+	if err != nil {
+		log.Error("invalid ledger configuration", "error", err)
+		return nil, err
+	}
+	reporting := s.reportingCurrency(filter.ReportingCurrency, settings)
+
+	receivables, err := s.repository.ListOpenReceivables(ctx, settings.Accounts.AccountsReceivable, filter.AsOf, filter.CustomerID)
+	if err != nil {
+		log.Error("failed to list open receivables", "error", err)
+		return nil, err
+	}
+	rates, err := s.conversionService.GetRates(ctx)
+	if err != nil {
+		log.Error("failed to get exchange rates", "error", err)
+		return nil, err
+	}
+
+	report, err := models.NewAgingReport(receivables, filter.AsOf, s.cfg.Billing.Invoice.PaymentTermsDays(),
+		reporting, rates, models.RoundingPolicyFromConfig(s.cfg))
+	if err != nil {
+		log.Error("failed to build aging report", "error", err)
+		return nil, err
+	}
+
+	log.Info("aging report built successfully", "receivables", len(receivables), "rows", len(report.Rows))
+	return report, nil
+}
+
+// GetCustomerStatement lists the bills, payments and credit notes of the customer in the filter period
+// with their running balance, and the closing balance in the reporting currency at the current rates
+func (s *service) GetCustomerStatement(
+	ctx context.Context, customerID string, filter models.CustomerStatementFilter,
+) (*models.CustomerStatement, error) {
+	log := rlog.With("module", "billing_core").With("customer_id", customerID)
+	log.Info("getting customer statement",
+		"from", filter.From,
+		"to", filter.To,
+		"reporting_currency", filter.ReportingCurrency)
+
+	settings, err := models.LedgerSettingsFromConfig(s.cfg)
+	if err != nil {
+		log.Error("invalid ledger configuration", "error", err)
+		return nil, err
+	}
+	reporting := s.reportingCurrency(filter.ReportingCurrency, settings)
+
+	activity, err := s.repository.ListReceivableActivity(ctx, settings.Accounts.AccountsReceivable, customerID, filter.To)
+	if err != nil {
+		log.Error("failed to list receivable activity", "error", err)
+		return nil, err
+	}
+	rates, err := s.conversionService.GetRates(ctx)
+	if err != nil {
+		log.Error("failed to get exchange rates", "error", err)
+		return nil, err
+	}
+
+	statement, err := models.NewCustomerStatement(customerID, activity, filter.From, filter.To,
+		reporting, rates, models.RoundingPolicyFromConfig(s.cfg))
+	if err != nil {
+		log.Error("failed to build customer statement", "error", err)
+		return nil, err
+	}
+
+	log.Info("customer statement built successfully", "activity", len(activity), "sections", len(statement.Sections))
+	return statement, nil
+}
+
+// RefreshRevenueRollups rebuilds the revenue rollups from the month of from on,
+// or of the trailing Analytics.RefreshWindowMonths months when from is zero
+func (s *service) RefreshRevenueRollups(ctx context.Context, from time.Time) (*models.RollupRefresh, error) {
+	return RefreshRevenueRollups(ctx, s.repository, from, s.cfg.Billing.Analytics.RefreshWindowMonths())
+}
+
+// RefreshRevenueRollups rebuilds the revenue rollups from the month of from on, or of the trailing months of the
+// window when from is zero. It only needs the repository, so the refresh-revenue-rollups cron job runs it without
+// the service.
+func RefreshRevenueRollups(
+	ctx context.Context, repo repository.Repository, from time.Time, windowMonths int,
+) (*models.RollupRefresh, error) {
+	now := time.Now()
+	if from.IsZero() {
+		from = models.StartOfMonth(now).AddDate(0, 1-windowMonths, 0)
+	}
+	log := rlog.With("module", "billing_core").With("from", from)
+	log.Info("refreshing revenue rollups")
+
+	refresh, err := repo.RefreshRevenueRollups(ctx, from, now)
+	if err != nil {
+		log.Error("failed to refresh revenue rollups", "error", err)
+		return nil, err
+	}
+
+	log.Info("revenue rollups refreshed successfully", "rows", refresh.Rows)
+	return refresh, nil
+}
+
+// GetRevenueReport totals the revenue billed per period of the filter by currency
+// and in the reporting currency at the current rates, from the revenue rollups
+func (s *service) GetRevenueReport(ctx context.Context, filter models.RevenueReportFilter) (*models.RevenueReport, error) {
+	log := rlog.With("module", "billing_core").With("from", filter.From).With("to", filter.To)
+	log.Info("getting revenue report", "granularity", filter.Granularity, "reporting_currency", filter.ReportingCurrency)
+
+	settings, err := models.LedgerSettingsFromConfig(s.cfg)
+	if err != nil {
+		log.Error("invalid ledger configuration", "error", err)
+		return nil, err
+	}
+	reporting := s.reportingCurrency(filter.ReportingCurrency, settings)
+
+	refresh, err := s.findRevenueRollupsRefresh(ctx)
+	if err != nil {
+		log.Error("failed to retrieve revenue rollups refresh", "error", err)
+		return nil, err
+	}
+	revenue, err := s.repository.ListRevenueByPeriod(ctx, filter.From, filter.To, filter.Granularity)
+	if err != nil {
+		log.Error("failed to list revenue by period", "error", err)
+		return nil, err
+	}
+	rates, err := s.conversionService.GetRates(ctx)
+	if err != nil {
+		log.Error("failed to get exchange rates", "error", err)
+		return nil, err
+	}
+
+	report, err := models.NewRevenueReport(revenue, filter.From, filter.To, filter.Granularity,
+		reporting, rates, models.RoundingPolicyFromConfig(s.cfg))
+	if err != nil {
+		log.Error("failed to build revenue report", "error", err)
+		return nil, err
+	}
+	report.Refresh = refresh
+
+	log.Info("revenue report built successfully", "periods", len(report.Periods))
+	return report, nil
+}
+
+// GetMRRReport computes the recurring revenue per month of the filter with its movement and customer churn,
+// in the reporting currency at the current rates, from the revenue rollups
+func (s *service) GetMRRReport(ctx context.Context, filter models.MRRReportFilter) (*models.MRRReport, error) {
+	log := rlog.With("module", "billing_core").With("from", filter.From).With("to", filter.To)
+	log.Info("getting MRR report", "reporting_currency", filter.ReportingCurrency)
+
+	settings, err := models.LedgerSettingsFromConfig(s.cfg)
+	if err != nil {
+		log.Error("invalid ledger configuration", "error", err)
+		return nil, err
+	}
+	reporting := s.reportingCurrency(filter.ReportingCurrency, settings)
+
+	refresh, err := s.findRevenueRollupsRefresh(ctx)
+	if err != nil {
+		log.Error("failed to retrieve revenue rollups refresh", "error", err)
+		return nil, err
+	}
+	// The month before the range is the baseline of the movement of its first month
+	recurring, err := s.repository.ListRecurringRevenue(ctx, filter.From.AddDate(0, -1, 0), filter.To)
+	if err != nil {
+		log.Error("failed to list recurring revenue", "error", err)
+		return nil, err
+	}
+	rates, err := s.conversionService.GetRates(ctx)
+	if err != nil {
+		log.Error("failed to get exchange rates", "error", err)
+		return nil, err
+	}
+
+	report, err := models.NewMRRReport(recurring, filter.From, filter.To, reporting, rates, models.RoundingPolicyFromConfig(s.cfg))
+	if err != nil {
+		log.Error("failed to build MRR report", "error", err)
+		return nil, err
+	}
+	report.Refresh = refresh
+
+	log.Info("MRR report built successfully", "months", len(report.Months))
+	return report, nil
+}
+
+// GetBillRecognitionSchedules returns the recognition schedules of the line items of the bill, none until it closes
+func (s *service) GetBillRecognitionSchedules(ctx context.Context, id uuid.UUID) ([]*models.RecognitionSchedule, error) {
+	log := rlog.With("module", "billing_core").With("bill_id", id.String())
+	log.Info("getting bill recognition schedules")
+
+	if _, err := s.repository.GetBillByID(ctx, id, models.GetBillOptions{}); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warn("bill not found in database")
+			return nil, models.ErrBillNotFound
+		}
+		log.Error("database error when retrieving bill", "error", err)
+		return nil, err
+	}
+
+	schedules, err := s.repository.ListBillRecognitionSchedules(ctx, id)
+	if err != nil {
+		log.Error("failed to list bill recognition schedules", "error", err)
+		return nil, err
+	}
+
+	log.Info("bill recognition schedules retrieved successfully", "schedules", len(schedules))
+	return schedules, nil
+}
+
+// GetDeferredRevenueReport rolls the revenue deferred by closed bills forward per month of the filter and currency,
+// with the recognition scheduled after the filter of the revenue still deferred
+func (s *service) GetDeferredRevenueReport(
+	ctx context.Context, filter models.DeferredRevenueFilter,
+) (*models.DeferredRevenueWaterfall, error) {
+	log := rlog.With("module", "billing_core").With("from", filter.From).With("to", filter.To)
+	log.Info("getting deferred revenue report")
+
+	movements, err := s.repository.ListDeferredRevenueMovements(ctx, filter.To)
+	if err != nil {
+		log.Error("failed to list deferred revenue movements", "error", err)
+		return nil, err
+	}
+	waterfall := models.NewDeferredRevenueWaterfall(movements, filter.From, filter.To)
+
+	log.Info("deferred revenue report built successfully", "months", len(waterfall.Months))
+	return waterfall, nil
+}
+
+// findRevenueRollupsRefresh returns the last refresh of the revenue rollups, nil when never refreshed
+func (s *service) findRevenueRollupsRefresh(ctx context.Context) (*models.RollupRefresh, error) {
+	refresh, err := s.repository.GetRevenueRollupsRefresh(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return refresh, err
+}
+
+// reportingCurrency returns the requested reporting currency, the functional currency of the ledger when unset
+func (s *service) reportingCurrency(requested models.Currency, settings models.LedgerSettings) models.Currency {
+	if requested != "" {
+		return requested
+	}
+	return settings.FunctionalCurrency
+}
+
+// CreateExport creates an export job and starts the workflow generating its file
+func (s *service) CreateExport(ctx context.Context, req *models.CreateExportRequest) (*models.ExportJob, error) {
+	log := rlog.With("module", "billing_core")
+	log.Info("creating export", "format", req.Format, "from", req.From, "to", req.To)
+
+	job, err := models.NewExportJob(req.Format, req.From, req.To, time.Now())
+	if err != nil {
+		log.Error("failed to create export job", "error", err)
+		return nil, err
+	}
+	job.WorkflowID = "export-" + job.ID.String()
+	log = log.With("export_id", job.ID.String())
+
+	if err = s.repository.CreateExportJob(ctx, job); err != nil {
+		log.Error("failed to save export job", "error", err)
+		return nil, err
+	}
+
+	workflowOptions := client.StartWorkflowOptions{
+		ID:        job.WorkflowID,
+		TaskQueue: s.cfg.Temporal.TaskQueue(),
+	}
+	if _, err = s.temporalClient.ExecuteWorkflow(ctx, workflowOptions, (&BillWorkflows{}).RunExport, ExportInput{JobID: job.ID}); err != nil {
+		log.Error("failed to start export workflow", "error", err)
+		// The job would stay pending without its workflow
+		if failErr := s.repository.FailExportJob(ctx, job.ID, "failed to start export workflow", time.Now()); failErr != nil {
+			log.Error("failed to mark export failed", "error", failErr)
+		}
+		return nil, fmt.Errorf("failed to start workflow: %w", err)
+	}
+
+	log.Info("export started", "workflow_id", job.WorkflowID)
+	return job, nil
+}
+
+// GetExport returns the export job with its status
+func (s *service) GetExport(ctx context.Context, id uuid.UUID) (*models.ExportJob, error) {
+	log := rlog.With("module", "billing_core").With("export_id", id.String())
+	log.Info("getting export")
+
+	job, err := s.repository.GetExportJob(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warn("export not found in database")
+			return nil, models.ErrExportNotFound
+		}
+		log.Error("database error when retrieving export", "error", err)
+		return nil, err
+	}
+
+	log.Info("export retrieved successfully", "status", job.Status)
+	return job, nil
+}
+
+// GetExportDownload returns the export job whose file can be downloaded, failing with ErrExportNotReady
+// until the job is completed
+func (s *service) GetExportDownload(ctx context.Context, id uuid.UUID) (*models.ExportJob, error) {
+	job, err := s.GetExport(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != models.ExportStatusCompleted {
+		rlog.With("module", "billing_core").With("export_id", id.String()).Warn("export is not completed", "status", job.Status)
+		return nil, models.ErrExportNotReady
+	}
+	return job, nil
+}
+
+// invoiceSubject is what the e-invoice documents of a bill are built from
+type invoiceSubject struct {
+	bill     *models.Bill
+	buyer    models.Party
+	settings models.InvoiceSettings
+	location *time.Location
+}
+
+// GetBillInvoice generates the UBL invoice of a closed or finalized bill, validated against
+// the EN 16931 and Peppol business rules
+func (s *service) GetBillInvoice(ctx context.Context, id uuid.UUID) ([]byte, error) {
+	log := rlog.With("module", "billing_core").With("bill_id", id.String())
+	log.Info("generating bill invoice")
+
+	subject, err := s.getInvoiceSubject(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := ubl.NewInvoice(subject.bill, subject.buyer, subject.settings, subject.location)
+	if err != nil {
+		log.Error("failed to build invoice", "error", err)
+		return nil, err
+	}
+	if err = doc.Validate(); err != nil {
+		log.Error("invoice breaks business rules", "error", err)
+		return nil, err
+	}
+
+	log.Info("bill invoice generated successfully", "lines", len(doc.InvoiceLines))
+	return doc.Marshal()
+}
+
+// GetCreditNoteDocument generates the UBL credit note of a credit note issued on the bill, validated against
+// the EN 16931 and Peppol business rules
+func (s *service) GetCreditNoteDocument(ctx context.Context, id uuid.UUID, reference string) ([]byte, error) {
+	log := rlog.With("module", "billing_core").With("bill_id", id.String()).With("reference", reference)
+	log.Info("generating credit note document")
+
+	subject, err := s.getInvoiceSubject(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	journals, err := s.repository.ListBillJournals(ctx, id)
+	if err != nil {
+		log.Error("failed to list bill journals", "error", err)
+		return nil, err
+	}
+	var note *models.Journal
+	for _, journal := range journals {
+		if journal.Type == models.JournalCreditNote && journal.Reference == reference {
+			note = journal
+			break
+		}
+	}
+	if note == nil {
+		log.Warn("credit note not found in bill ledger")
+		return nil, models.ErrCreditNoteNotFound
+	}
+
+	doc, err := ubl.NewCreditNote(subject.bill, note, subject.buyer, subject.settings, subject.location)
+	if err != nil {
+		log.Error("failed to build credit note", "error", err)
+		return nil, err
+	}
+	if err = doc.Validate(); err != nil {
+		log.Error("credit note breaks business rules", "error", err)
+		return nil, err
+	}
+
+	log.Info("credit note document generated successfully")
+	return doc.Marshal()
+}
+
+// getInvoiceSubject returns the closed or finalized bill with its line items and persisted totals,
+// the party of its customer, and the timezone of the customer its documents are dated in
+func (s *service) getInvoiceSubject(ctx context.Context, id uuid.UUID) (*invoiceSubject, error) {
+	log := rlog.With("module", "billing_core").With("bill_id", id.String())
+
+	settings, err := models.InvoiceSettingsFromConfig(s.cfg)
+	if err != nil {
+		log.Error("invalid invoice configuration", "error", err)
+		return nil, err
+	}
+
+	bill, err := s.repository.GetBillByID(ctx, id, models.GetBillOptions{IncludeLineItems: true})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warn("bill not found in database")
+			return nil, models.ErrBillNotFound
+		}
+		log.Error("database error when retrieving bill", "error", err)
+		return nil, err
+	}
+	if bill.Status != models.BillStatusClosed && bill.Status != models.BillStatusFinalized {
+		log.Warn("bill is not closed or finalized", "status", bill.Status)
+		return nil, models.ErrBillNotInvoiceable
+	}
+	// Bills closed before totals were persisted at close are invoiced at current rates
+	if bill.Total == nil || bill.Total.ComputedAt == nil {
+		if err = computeTotals(ctx, s.conversionService, s.cfg, bill, nil); err != nil {
+			log.Error("failed to calculate bill totals", "error", err)
+			return nil, err
+		}
+	}
+
+	profile, err := s.findCustomerProfile(ctx, bill.CustomerID)
+	if err != nil {
+		log.Error("failed to get customer profile", "error", err)
+		return nil, err
+	}
+	if profile == nil || profile.Party == nil {
+		log.Warn("customer has no party", "customer_id", bill.CustomerID)
+		return nil, models.ErrCustomerPartyMissing
+	}
+	closeSettings, err := s.resolveCloseSettings(profile)
+	if err != nil {
+		log.Error("failed to resolve customer timezone", "error", err)
+		return nil, err
+	}
+
+	return &invoiceSubject{
+		bill:     bill,
+		buyer:    *profile.Party,
+		settings: settings,
+		location: closeSettings.Location,
+	}, nil
+}
+
+// ImportBankStatement imports the credits of a bank statement and matches them to the outstanding balances
+// of closed and finalized bills. Exact matches are recorded as payments, the other credits are queued for review.
+// Credits imported by an earlier statement are counted as duplicates and not matched again.
+func (s *service) ImportBankStatement(
+	ctx context.Context, format models.StatementFormat, r io.Reader,
+) (*models.StatementImport, error) {
+	log := rlog.With("module", "billing_core").With("format", format)
+	log.Info("importing bank statement")
+
+	var parsed *models.Statement
+	var err error
+	switch format {
+	case models.StatementFormatCamt053:
+		parsed, err = statement.ParseCamt053(r)
+	case models.StatementFormatCSV:
+		layout, layoutErr := statement.CSVLayoutFromConfig(s.cfg)
+		if layoutErr != nil {
+			log.Error("invalid bank statement CSV configuration", "error", layoutErr)
+			return nil, layoutErr
+		}
+		parsed, err = statement.ParseCSV(r, layout)
+	default:
+		err = format.Validate()
+	}
+	if err != nil {
+		log.Warn("failed to parse bank statement", "error", err)
+		return nil, err
+	}
+
+	settings, err := models.LedgerSettingsFromConfig(s.cfg)
+	if err != nil {
+		log.Error("invalid ledger configuration", "error", err)
+		return nil, err
+	}
+	policy := models.MatchPolicyFromConfig(s.cfg)
+
+	result := &models.StatementImport{
+		Format:       format,
+		Skipped:      parsed.Skipped,
+		Transactions: []*models.BankTransaction{},
+	}
+	// Receivables are listed per currency and listed again after each payment, so that a credit is matched
+	// against the balances left by the payments recorded before it, including those posted by an earlier import
+	receivables := make(map[models.Currency][]models.Receivable)
+	for _, credit := range parsed.Credits {
+		now := time.Now()
+		txn := models.NewBankTransaction(credit, now)
+		created, err := s.repository.CreateBankTransaction(ctx, txn)
+		if err != nil {
+			log.Error("failed to create bank transaction", "entry_reference", credit.EntryReference, "error", err)
+			return nil, err
+		}
+		if !created {
+			result.Duplicates++
+			continue
+		}
+		result.Imported++
+
+		open, listed := receivables[credit.Currency]
+		if !listed {
+			open, err = s.repository.ListReceivables(ctx, settings.Accounts.AccountsReceivable, credit.Currency)
+			if err != nil {
+				log.Error("failed to list receivables", "currency", credit.Currency, "error", err)
+				return nil, err
+			}
+			receivables[credit.Currency] = open
+		}
+
+		match := models.MatchPayment(credit, open, policy)
+		if match.Status == models.BankTransactionStatusMatched {
+			err = s.recordBankPayment(ctx, txn, *match.BillID)
+			delete(receivables, credit.Currency)
+			if err != nil {
+				var rejected *errs.Error
+				if !errors.As(err, &rejected) {
+					return nil, err
+				}
+				log.Warn("payment of exact match rejected, queueing for review",
+					"entry_reference", credit.EntryReference, "bill_id", match.BillID.String(), "error", err)
+				match.Status, match.BillID, match.Reason = models.BankTransactionStatusReview, nil, models.MatchReasonPaymentRejected
+			}
+		}
+
+		txn.ApplyMatch(match, time.Now())
+		if err = s.repository.UpdateBankTransactionMatch(ctx, txn); err != nil {
+			log.Error("failed to save bank transaction match", "bank_transaction_id", txn.ID.String(), "error", err)
+			return nil, err
+		}
+		if txn.Status == models.BankTransactionStatusMatched {
+			result.Matched++
+		} else {
+			result.Review++
+		}
+		result.Transactions = append(result.Transactions, txn)
+	}
+
+	log.Info("bank statement imported successfully",
+		"imported", result.Imported,
+		"duplicates", result.Duplicates,
+		"skipped", result.Skipped,
+		"matched", result.Matched,
+		"review", result.Review)
+	return result, nil
+}
+
+// recordBankPayment records the credit as a payment of the bill. The payment reference is derived from the entry
+// reference, so a payment recorded before a failed update of the transaction is not recorded twice.
+// The receivables listed before already account for such a payment.
+func (s *service) recordBankPayment(ctx context.Context, txn *models.BankTransaction, billID uuid.UUID) error {
+	bookedAt := txn.BookedAt
+	_, err := s.RecordPayment(ctx, billID, &models.RecordPaymentRequest{
+		Amount:     txn.Amount,
+		Currency:   txn.Currency,
+		Reference:  txn.PaymentReference(),
+		ReceivedAt: &bookedAt,
+	})
+	if errors.Is(err, models.ErrJournalAlreadyPosted) {
+		return nil
+	}
+	return err
+}
+
+func (s *service) ListBankTransactions(ctx context.Context, filter models.BankTransactionFilter) ([]*models.BankTransaction, error) {
+	log := rlog.With("module", "billing_core")
+	log.Info("listing bank transactions", "status", filter.Status, "limit", filter.Limit)
+
+	txns, err := s.repository.ListBankTransactions(ctx, filter)
+	if err != nil {
+		log.Error("failed to list bank transactions", "error", err)
+		return nil, err
+	}
+
+	log.Info("bank transactions listed successfully", "count", len(txns))
+	return txns, nil
+}
+
+func (s *service) GetBankTransaction(ctx context.Context, id uuid.UUID) (*models.BankTransaction, error) {
+	log := rlog.With("module", "billing_core").With("bank_transaction_id", id.String())
+	log.Info("getting bank transaction")
+
+	txn, err := s.repository.GetBankTransaction(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warn("bank transaction not found")
+			return nil, models.ErrBankTransactionNotFound
+		}
+		log.Error("failed to get bank transaction", "error", err)
+		return nil, err
+	}
+
+	log.Info("bank transaction retrieved successfully", "status", txn.Status)
+	return txn, nil
+}
+
+// MatchBankTransaction records the credit in review as a payment of the bill an operator matched it to
+func (s *service) MatchBankTransaction(ctx context.Context, id, billID uuid.UUID) (*models.BankTransaction, error) {
+	log := rlog.With("module", "billing_core").With("bank_transaction_id", id.String()).With("bill_id", billID.String())
+	log.Info("matching bank transaction")
+
+	txn, err := s.GetBankTransaction(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if txn.Status != models.BankTransactionStatusReview {
+		log.Warn("bank transaction is not in review", "status", txn.Status)
+		return nil, models.ErrBankTransactionNotPending
+	}
+
+	if err = s.recordBankPayment(ctx, txn, billID); err != nil {
+		log.Warn("failed to record payment of bank transaction", "error", err)
+		return nil, err
+	}
+
+	txn.ApplyMatch(models.PaymentMatch{
+		Status:     models.BankTransactionStatusMatched,
+		BillID:     &billID,
+		Candidates: txn.CandidateBillIDs,
+		Reason:     models.MatchReasonManual,
+	}, time.Now())
+	if err = s.repository.UpdateBankTransactionMatch(ctx, txn); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warn("bank transaction resolved before matching")
+			return nil, models.ErrBankTransactionNotPending
+		}
+		log.Error("failed to save bank transaction match", "error", err)
+		return nil, err
+	}
+
+	log.Info("bank transaction matched successfully")
+	return txn, nil
+}
+
+// DismissBankTransaction resolves the credit in review as not the payment of a bill
+func (s *service) DismissBankTransaction(ctx context.Context, id uuid.UUID, note string) (*models.BankTransaction, error) {
+	log := rlog.With("module", "billing_core").With("bank_transaction_id", id.String())
+	log.Info("dismissing bank transaction", "note", note)
+
+	txn, err := s.GetBankTransaction(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if txn.Status != models.BankTransactionStatusReview {
+		log.Warn("bank transaction is not in review", "status", txn.Status)
+		return nil, models.ErrBankTransactionNotPending
+	}
+
+	txn.Dismiss(note, time.Now())
+	if err = s.repository.UpdateBankTransactionMatch(ctx, txn); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warn("bank transaction resolved before dismissing")
+			return nil, models.ErrBankTransactionNotPending
+		}
+		log.Error("failed to dismiss bank transaction", "error", err)
+		return nil, err
+	}
+
+	log.Info("bank transaction dismissed successfully")
+	return txn, nil
+}
+
+// computeTotals calculates the bill totals with the latest exchange rates and the configured rounding policy.
+// Totals are calculated from the line items, or from the aggregated line totals when given.
+func computeTotals(
+	ctx context.Context, conversionService ext_services.ExchangeRatesService, cfg *models.AppConfig,
+	bill *models.Bill, lineTotals []models.LineTotalGroup,
+) error {
+	log := rlog.With("module", "billing_core").With("bill_id", bill.ID.String())
+	log.Info("calculating bill totals", "line_items_count", len(bill.LineItems), "line_total_groups", len(lineTotals))
+
+	// Bills created before presentment currencies were introduced use the configured default
+	if bill.PresentmentCurrency == "" {
+		bill.PresentmentCurrency = models.Currency(cfg.Billing.DefaultPresentmentCurrency())
+	}
+
+	rates, err := conversionService.GetRates(ctx)
+	if err != nil {
+		log.Error("failed to get exchange rates", "error", err)
+		return err
+	}
+	log.Info("exchange rates retrieved successfully")
+
+	policy := models.RoundingPolicyFromConfig(cfg)
+	if lineTotals != nil {
+		err = bill.CalculateSummary(lineTotals, rates, policy)
+	} else {
+		err = bill.CalculateSum(rates, policy)
+	}
+	if err != nil {
 		log.Error("failed to calculate bill totals", "error", err)
 		return err
 	}
@@ -3,6 +3,7 @@ package core
 import (
 	"context"
 	"errors"
+	"io"
 	"reflect"
 	"strings"
 	"testing"
@@ -11,11 +12,18 @@ import (
 	mocksCore "encore.app/billing/core/mocks"
 	"encore.app/billing/ext_services/mocks"
 	"encore.app/billing/models"
-	"encore.app/billing/repository"
+	"encore.app/billing/repository/repositorytest"
 	"encore.dev/types/uuid"
 	"github.com/golang/mock/gomock"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/api/serviceerror"
+	workflowpb "go.temporal.io/api/workflow/v1"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
 )
 
 //go:generate mockgen -package=mocks -destination=mocks/temporal_client_mock.go go.temporal.io/sdk/client Client
@@ -25,7 +33,7 @@ func TestNewService(t *testing.T) {
 		ctrl := gomock.NewController(t)
 
 		mockTemporalClient := mocksCore.NewMockClient(ctrl)
-		fakeRepo := &repository.FakeRepo{}
+		fakeRepo := &repositorytest.FakeRepo{}
 		mockConversionService := mocks.NewMockExchangeRatesService(ctrl)
 
 		cfg := &models.AppConfig{
@@ -47,12 +55,34 @@ func TestService_CreateBill(t *testing.T) {
 				WorkflowIDPrefix: func() string {
 					return "test-prefix-"
 				},
+				ContinueAsNewSignalThreshold: func() int {
+					return 1000
+				},
+				SearchAttributeTotalsInterval: func() int {
+					return 0
+				},
+				LateUsageGraceWindow: func() int {
+					return 900
+				},
+			},
+			DefaultPresentmentCurrency: func() string {
+				return "USD"
+			},
+			Rounding: models.RoundingConfig{
+				Mode: func() string {
+					return "half_up"
+				},
+				Level: func() string {
+					return "total"
+				},
+			},
+			Close: models.CloseConfig{
+				Policy:      func() string { return "exact" },
+				Timezone:    func() string { return "UTC" },
+				GracePeriod: func() int { return 600 },
 			},
 		},
 		Temporal: models.TemporalConfig{
-			WorkflowExecutionTimeoutBuffer: func() int {
-				return 10
-			},
 			TaskQueue: func() string {
 				return "test-queue"
 			},
@@ -63,7 +93,7 @@ func TestService_CreateBill(t *testing.T) {
 			ctrl := gomock.NewController(t)
 
 			mockTemporalClient := mocksCore.NewMockClient(ctrl)
-			fakeRepo := &repository.FakeRepo{}
+			fakeRepo := &repositorytest.FakeRepo{}
 			mockConversionService := mocks.NewMockExchangeRatesService(ctrl)
 			mockTemporalClient.EXPECT().
 				ExecuteWorkflow(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
@@ -82,18 +112,115 @@ func TestService_CreateBill(t *testing.T) {
 			assert.NoError(t, err)
 			assert.NotNil(t, bill)
 			assert.Equal(t, req.CustomerID, bill.CustomerID)
-			assert.Equal(t, models.BillStatusOpen, bill.Status)
+			assert.Equal(t, models.BillStatusDraft, bill.Status, "the workflow opens the bill once started")
 			assert.Equal(t, req.PeriodStart, bill.PeriodStart)
 			assert.Equal(t, req.PeriodEnd, bill.PeriodEnd)
 			assert.NotEmpty(t, bill.WorkflowID)
 			assert.True(t, strings.HasPrefix(bill.WorkflowID, "test-prefix-"))
 			assert.NotZero(t, bill.CreatedAt)
 			assert.NotZero(t, bill.UpdatedAt)
+			assert.Equal(t, models.USD, bill.PresentmentCurrency)
+			assert.Equal(t, models.ClosePolicyExact, bill.ClosePolicy)
+			require.NotNil(t, bill.ScheduledCloseAt)
+			assert.True(t, req.PeriodEnd.Equal(*bill.ScheduledCloseAt))
+
+			saved, err := fakeRepo.GetBillByID(context.TODO(), bill.ID, models.GetBillOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, models.BillStatusDraft, saved.Status)
+		})
+	})
+
+	t.Run("when_resolving_presentment_currency", func(t *testing.T) {
+		newReq := func(currency models.Currency) *models.CreateBillRequest {
+			return &models.CreateBillRequest{
+				CustomerID:          "customer-123",
+				PeriodStart:         time.Now(),
+				PeriodEnd:           time.Now().AddDate(0, 1, 0),
+				PresentmentCurrency: currency,
+			}
+		}
+
+		t.Run("should_prefer_request_over_customer_profile", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			mockTemporalClient := mocksCore.NewMockClient(ctrl)
+			mockTemporalClient.EXPECT().
+				ExecuteWorkflow(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+				Return(nil, nil)
+			fakeRepo := &repositorytest.FakeRepo{}
+			_ = fakeRepo.UpsertCustomerProfile(context.TODO(), &models.CustomerProfile{
+				CustomerID:          "customer-123",
+				PresentmentCurrency: models.GEL,
+			})
+
+			service := NewService(testCfg, mockTemporalClient, fakeRepo, mocks.NewMockExchangeRatesService(ctrl))
+
+			bill, err := service.CreateBill(context.TODO(), newReq(models.JPY))
+
+			assert.NoError(t, err)
+			assert.Equal(t, models.JPY, bill.PresentmentCurrency)
+		})
+
+		t.Run("should_default_from_customer_profile", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			mockTemporalClient := mocksCore.NewMockClient(ctrl)
+			mockTemporalClient.EXPECT().
+				ExecuteWorkflow(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+				Return(nil, nil)
+			fakeRepo := &repositorytest.FakeRepo{}
+			_ = fakeRepo.UpsertCustomerProfile(context.TODO(), &models.CustomerProfile{
+				CustomerID:          "customer-123",
+				PresentmentCurrency: models.GEL,
+			})
+
+			service := NewService(testCfg, mockTemporalClient, fakeRepo, mocks.NewMockExchangeRatesService(ctrl))
+
+			bill, err := service.CreateBill(context.TODO(), newReq(""))
+
+			assert.NoError(t, err)
+			assert.Equal(t, models.GEL, bill.PresentmentCurrency)
+		})
+	})
+
+	t.Run("when_customer_profile_has_close_policy", func(t *testing.T) {
+		t.Run("should_schedule_close_at_end_of_day_in_customer_timezone", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			mockTemporalClient := mocksCore.NewMockClient(ctrl)
+			mockTemporalClient.EXPECT().
+				ExecuteWorkflow(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, options client.StartWorkflowOptions, _ interface{}, _ ...interface{}) (client.WorkflowRun, error) {
+					// The workflow completes itself once the bill is closed and its failed operations are resolved
+					assert.Zero(t, options.WorkflowExecutionTimeout)
+					return nil, nil
+				})
+			fakeRepo := &repositorytest.FakeRepo{}
+			_ = fakeRepo.UpsertCustomerProfile(context.TODO(), &models.CustomerProfile{
+				CustomerID:          "customer-123",
+				PresentmentCurrency: models.USD,
+				Timezone:            "Asia/Tbilisi",
+				ClosePolicy:         models.ClosePolicyEndOfDay,
+			})
+
+			service := NewService(testCfg, mockTemporalClient, fakeRepo, mocks.NewMockExchangeRatesService(ctrl))
+
+			bill, err := service.CreateBill(context.TODO(), &models.CreateBillRequest{
+				CustomerID:  "customer-123",
+				PeriodStart: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+				PeriodEnd:   time.Date(2025, 1, 31, 12, 0, 0, 0, time.UTC),
+			})
+
+			require.NoError(t, err)
+			assert.Equal(t, models.ClosePolicyEndOfDay, bill.ClosePolicy)
+			require.NotNil(t, bill.ScheduledCloseAt)
+			// Midnight after January 31st in Tbilisi, UTC+4
+			assert.True(t, time.Date(2025, 1, 31, 20, 0, 0, 0, time.UTC).Equal(*bill.ScheduledCloseAt))
 		})
 	})
 
 	t.Run("when_temporal_client_fails", func(t *testing.T) {
-		t.Run("should_return_error", func(t *testing.T) {
+		t.Run("should_keep_draft_bill_for_reconciler", func(t *testing.T) {
 			ctrl := gomock.NewController(t)
 
 			// Create a mock that fails
@@ -102,7 +229,7 @@ func TestService_CreateBill(t *testing.T) {
 				nil,
 				errors.New("failed to start workflow"),
 			)
-			fakeRepo := &repository.FakeRepo{}
+			fakeRepo := &repositorytest.FakeRepo{}
 			mockConversionService := mocks.NewMockExchangeRatesService(ctrl)
 
 			service := NewService(testCfg, mockTemporalClient, fakeRepo, mockConversionService)
@@ -115,10 +242,312 @@ func TestService_CreateBill(t *testing.T) {
 
 			bill, err := service.CreateBill(context.TODO(), req)
 
-			assert.Error(t, err)
-			assert.Nil(t, bill)
-			assert.Contains(t, err.Error(), "failed to start workflow")
+			require.NoError(t, err)
+			assert.Equal(t, models.BillStatusDraft, bill.Status)
+			saved, err := fakeRepo.GetBillByID(context.TODO(), bill.ID, models.GetBillOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, models.BillStatusDraft, saved.Status)
+		})
+	})
+}
+
+func TestService_Reconciliation(t *testing.T) {
+	testCfg := &models.AppConfig{
+		Temporal: models.TemporalConfig{
+			TaskQueue: func() string { return "test-queue" },
+		},
+	}
+
+	t.Run("should_start_reconciliation_workflow", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		mockTemporalClient := mocksCore.NewMockClient(ctrl)
+		service := NewService(testCfg, mockTemporalClient, &repositorytest.FakeRepo{}, mocks.NewMockExchangeRatesService(ctrl))
+
+		var startedID string
+		mockTemporalClient.EXPECT().
+			ExecuteWorkflow(gomock.Any(), gomock.Any(), gomock.Any(), ReconcileBillsInput{Repair: true}).
+			DoAndReturn(func(_ context.Context, options client.StartWorkflowOptions, _ interface{}, _ ...interface{}) (client.WorkflowRun, error) {
+				assert.Equal(t, "test-queue", options.TaskQueue)
+				startedID = options.ID
+				return nil, nil
+			})
+
+		workflowID, err := service.StartReconciliation(context.TODO(), true)
+
+		require.NoError(t, err)
+		assert.Equal(t, startedID, workflowID)
+		assert.True(t, strings.HasPrefix(workflowID, ReconciliationScheduleID+"-"))
+	})
+
+	t.Run("should_list_most_recent_reports_first", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		fakeRepo := &repositorytest.FakeRepo{}
+		service := NewService(testCfg, mocksCore.NewMockClient(ctrl), fakeRepo, mocks.NewMockExchangeRatesService(ctrl))
+
+		first := &models.ReconciliationReport{ID: uuid.Must(uuid.NewV4())}
+		second := &models.ReconciliationReport{ID: uuid.Must(uuid.NewV4())}
+		third := &models.ReconciliationReport{ID: uuid.Must(uuid.NewV4())}
+		for _, report := range []*models.ReconciliationReport{first, second, third} {
+			require.NoError(t, fakeRepo.SaveReconciliationReport(context.TODO(), report))
+		}
+
+		reports, err := service.ListReconciliationReports(context.TODO(), 2)
+
+		require.NoError(t, err)
+		assert.Equal(t, []*models.ReconciliationReport{third, second}, reports)
+	})
+
+	t.Run("should_get_report_by_id", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		fakeRepo := &repositorytest.FakeRepo{}
+		service := NewService(testCfg, mocksCore.NewMockClient(ctrl), fakeRepo, mocks.NewMockExchangeRatesService(ctrl))
+
+		report := &models.ReconciliationReport{ID: uuid.Must(uuid.NewV4()), BillsChecked: 3}
+		require.NoError(t, fakeRepo.SaveReconciliationReport(context.TODO(), report))
+
+		got, err := service.GetReconciliationReport(context.TODO(), report.ID)
+
+		require.NoError(t, err)
+		assert.Equal(t, report, got)
+	})
+
+	t.Run("when_report_does_not_exist_should_return_not_found", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		service := NewService(testCfg, mocksCore.NewMockClient(ctrl), &repositorytest.FakeRepo{}, mocks.NewMockExchangeRatesService(ctrl))
+
+		got, err := service.GetReconciliationReport(context.TODO(), uuid.Must(uuid.NewV4()))
+
+		assert.Nil(t, got)
+		assert.Equal(t, models.ErrReconciliationReportNotFound, err)
+	})
+}
+
+func TestService_FailedOperations(t *testing.T) {
+	newPendingOperation := func(t *testing.T, fakeRepo *repositorytest.FakeRepo) *models.FailedOperation {
+		billID := uuid.Must(uuid.NewV4())
+		op := &models.FailedOperation{
+			ID:         uuid.Must(uuid.NewV4()),
+			BillID:     billID,
+			WorkflowID: "bill-" + billID.String(),
+			Operation:  models.FailedOperationCloseBill,
+			Status:     models.FailedOperationStatusPending,
+			CreatedAt:  time.Now(),
+		}
+		op.AddAttempt("connection refused", "", op.CreatedAt)
+		require.NoError(t, fakeRepo.SaveFailedOperation(context.TODO(), op))
+		return op
+	}
+
+	t.Run("should_list_failed_operations_matching_filter", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		fakeRepo := &repositorytest.FakeRepo{}
+		service := NewService(&models.AppConfig{}, mocksCore.NewMockClient(ctrl), fakeRepo, mocks.NewMockExchangeRatesService(ctrl))
+		pending := newPendingOperation(t, fakeRepo)
+		resolved := newPendingOperation(t, fakeRepo)
+		require.NoError(t, fakeRepo.ResolveFailedOperation(
+			context.TODO(), resolved.ID, models.FailedOperationStatusResolved, "", time.Now(),
+		))
+
+		ops, err := service.ListFailedOperations(context.TODO(), models.FailedOperationFilter{
+			Status: models.FailedOperationStatusPending,
+			Limit:  10,
 		})
+
+		require.NoError(t, err)
+		require.Len(t, ops, 1)
+		assert.Equal(t, pending.ID, ops[0].ID)
+	})
+
+	t.Run("when_failed_operation_does_not_exist_should_return_not_found", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		service := NewService(&models.AppConfig{}, mocksCore.NewMockClient(ctrl), &repositorytest.FakeRepo{}, mocks.NewMockExchangeRatesService(ctrl))
+
+		got, err := service.GetFailedOperation(context.TODO(), uuid.Must(uuid.NewV4()))
+
+		assert.Nil(t, got)
+		assert.Equal(t, models.ErrFailedOperationNotFound, err)
+	})
+
+	t.Run("should_signal_retry_to_bill_workflow", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		mockTemporalClient := mocksCore.NewMockClient(ctrl)
+		fakeRepo := &repositorytest.FakeRepo{}
+		service := NewService(&models.AppConfig{}, mockTemporalClient, fakeRepo, mocks.NewMockExchangeRatesService(ctrl))
+		op := newPendingOperation(t, fakeRepo)
+
+		mockTemporalClient.EXPECT().
+			SignalWorkflow(gomock.Any(), op.WorkflowID, "", RetryFailedOperationSignal, RetryFailedOperationSignalData{ID: op.ID}).
+			Return(nil)
+
+		got, err := service.RetryFailedOperation(context.TODO(), op.ID)
+
+		require.NoError(t, err)
+		assert.Equal(t, models.FailedOperationStatusPending, got.Status)
+	})
+
+	t.Run("when_bill_workflow_is_not_running_should_start_retry_workflow", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		mockTemporalClient := mocksCore.NewMockClient(ctrl)
+		fakeRepo := &repositorytest.FakeRepo{}
+		cfg := &models.AppConfig{Temporal: models.TemporalConfig{TaskQueue: func() string { return "test-queue" }}}
+		service := NewService(cfg, mockTemporalClient, fakeRepo, mocks.NewMockExchangeRatesService(ctrl))
+		op := newPendingOperation(t, fakeRepo)
+
+		mockTemporalClient.EXPECT().
+			SignalWorkflow(gomock.Any(), op.WorkflowID, "", RetryFailedOperationSignal, gomock.Any()).
+			Return(serviceerror.NewNotFound("workflow execution already completed"))
+		mockTemporalClient.EXPECT().
+			ExecuteWorkflow(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, options client.StartWorkflowOptions, _ interface{}, args ...interface{}) (client.WorkflowRun, error) {
+				assert.Equal(t, FailedOperationRetryWorkflowIDPrefix+op.ID.String(), options.ID)
+				assert.Equal(t, op.ID, args[0].(RetryFailedOperationInput).Operation.ID)
+				return nil, nil
+			})
+
+		got, err := service.RetryFailedOperation(context.TODO(), op.ID)
+
+		require.NoError(t, err)
+		assert.Equal(t, models.FailedOperationStatusPending, got.Status)
+	})
+
+	t.Run("when_failed_operation_is_resolved_should_reject_retry", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		fakeRepo := &repositorytest.FakeRepo{}
+		service := NewService(&models.AppConfig{}, mocksCore.NewMockClient(ctrl), fakeRepo, mocks.NewMockExchangeRatesService(ctrl))
+		op := newPendingOperation(t, fakeRepo)
+		require.NoError(t, fakeRepo.ResolveFailedOperation(
+			context.TODO(), op.ID, models.FailedOperationStatusResolved, "", time.Now(),
+		))
+
+		got, err := service.RetryFailedOperation(context.TODO(), op.ID)
+
+		assert.Nil(t, got)
+		assert.Equal(t, models.ErrFailedOperationNotPending, err)
+	})
+
+	t.Run("should_signal_acknowledgement_to_bill_workflow", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		mockTemporalClient := mocksCore.NewMockClient(ctrl)
+		fakeRepo := &repositorytest.FakeRepo{}
+		service := NewService(&models.AppConfig{}, mockTemporalClient, fakeRepo, mocks.NewMockExchangeRatesService(ctrl))
+		op := newPendingOperation(t, fakeRepo)
+
+		mockTemporalClient.EXPECT().
+			SignalWorkflow(gomock.Any(), op.WorkflowID, "", AcknowledgeFailedOperationSignal, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _, _, _ string, arg interface{}) error {
+				signal := arg.(AcknowledgeFailedOperationSignalData)
+				assert.Equal(t, op.ID, signal.ID)
+				assert.Equal(t, "closed manually", signal.Note)
+				return nil
+			})
+
+		got, err := service.AcknowledgeFailedOperation(context.TODO(), op.ID, "closed manually")
+
+		require.NoError(t, err)
+		assert.Equal(t, models.FailedOperationStatusAcknowledged, got.Status)
+		// The workflow resolves the operation once it handles the signal
+		stored, err := fakeRepo.GetFailedOperation(context.TODO(), op.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.FailedOperationStatusPending, stored.Status)
+	})
+
+	t.Run("when_bill_workflow_is_not_running_should_acknowledge_in_database", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		mockTemporalClient := mocksCore.NewMockClient(ctrl)
+		fakeRepo := &repositorytest.FakeRepo{}
+		service := NewService(&models.AppConfig{}, mockTemporalClient, fakeRepo, mocks.NewMockExchangeRatesService(ctrl))
+		op := newPendingOperation(t, fakeRepo)
+
+		mockTemporalClient.EXPECT().
+			SignalWorkflow(gomock.Any(), op.WorkflowID, "", AcknowledgeFailedOperationSignal, gomock.Any()).
+			Return(serviceerror.NewNotFound("workflow execution already completed"))
+
+		got, err := service.AcknowledgeFailedOperation(context.TODO(), op.ID, "closed manually")
+
+		require.NoError(t, err)
+		assert.Equal(t, models.FailedOperationStatusAcknowledged, got.Status)
+		stored, err := fakeRepo.GetFailedOperation(context.TODO(), op.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.FailedOperationStatusAcknowledged, stored.Status)
+		assert.Equal(t, "closed manually", stored.ResolutionNote)
+	})
+}
+
+func TestService_ListBillWorkflows(t *testing.T) {
+	testCfg := &models.AppConfig{
+		Billing: models.BillingConfig{
+			Workflow: models.WorkflowConfig{
+				WorkflowIDPrefix: func() string { return "bill-" },
+			},
+		},
+	}
+
+	searchAttributes := func(t *testing.T, values map[string]interface{}) *commonpb.SearchAttributes {
+		fields := make(map[string]*commonpb.Payload, len(values))
+		for name, value := range values {
+			payload, err := converter.GetDefaultDataConverter().ToPayload(value)
+			require.NoError(t, err)
+			fields[name] = payload
+		}
+		return &commonpb.SearchAttributes{IndexedFields: fields}
+	}
+
+	t.Run("should_list_bills_from_search_attributes", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		mockTemporalClient := mocksCore.NewMockClient(ctrl)
+		service := NewService(testCfg, mockTemporalClient, &repositorytest.FakeRepo{}, mocks.NewMockExchangeRatesService(ctrl))
+
+		billID := uuid.Must(uuid.NewV4())
+		periodEnd := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+		filter := models.BillWorkflowFilter{CustomerID: "cust-1", Limit: 10, PageToken: []byte("page-2")}
+		mockTemporalClient.EXPECT().
+			ListWorkflow(gomock.Any(), &workflowservice.ListWorkflowExecutionsRequest{
+				Query:         "WorkflowType = 'CreateBill' AND BillStatus IN ('open', 'closing') AND CustomerId = 'cust-1'",
+				PageSize:      10,
+				NextPageToken: []byte("page-2"),
+			}).
+			Return(&workflowservice.ListWorkflowExecutionsResponse{
+				Executions: []*workflowpb.WorkflowExecutionInfo{
+					{
+						Execution: &commonpb.WorkflowExecution{WorkflowId: "bill-" + billID.String(), RunId: "run-1"},
+						SearchAttributes: searchAttributes(t, map[string]interface{}{
+							"CustomerId":      "cust-1",
+							"BillStatus":      "open",
+							"PeriodEnd":       periodEnd,
+							"TotalByCurrency": []string{"USD:7.5"},
+						}),
+					},
+					{Execution: &commonpb.WorkflowExecution{WorkflowId: "not-a-bill"}},
+				},
+				NextPageToken: []byte("page-3"),
+			}, nil)
+
+		bills, next, err := service.ListBillWorkflows(context.TODO(), filter)
+
+		require.NoError(t, err)
+		assert.Equal(t, []byte("page-3"), next)
+		require.Len(t, bills, 1)
+		assert.Equal(t, billID, bills[0].BillID)
+		assert.Equal(t, "run-1", bills[0].RunID)
+		assert.Equal(t, "cust-1", bills[0].CustomerID)
+		assert.Equal(t, models.BillStatusOpen, bills[0].Status)
+		require.NotNil(t, bills[0].PeriodEnd)
+		assert.True(t, periodEnd.Equal(*bills[0].PeriodEnd))
+		assert.True(t, decimal.RequireFromString("7.5").Equal(bills[0].TotalByCurrency[models.USD]))
+	})
+
+	t.Run("when_visibility_is_unavailable_should_return_error", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		mockTemporalClient := mocksCore.NewMockClient(ctrl)
+		service := NewService(testCfg, mockTemporalClient, &repositorytest.FakeRepo{}, mocks.NewMockExchangeRatesService(ctrl))
+
+		mockTemporalClient.EXPECT().ListWorkflow(gomock.Any(), gomock.Any()).Return(nil, errors.New("visibility unavailable"))
+
+		bills, next, err := service.ListBillWorkflows(context.TODO(), models.BillWorkflowFilter{Limit: 10})
+
+		assert.Error(t, err)
+		assert.Nil(t, bills)
+		assert.Nil(t, next)
 	})
 }
 
@@ -147,7 +576,7 @@ func TestService_GetBillByID(t *testing.T) {
 			ctrl := gomock.NewController(t)
 
 			mockTemporalClient := mocksCore.NewMockClient(ctrl)
-			fakeRepo := &repository.FakeRepo{}
+			fakeRepo := &repositorytest.FakeRepo{}
 			mockConversionService := mocks.NewMockExchangeRatesService(ctrl)
 
 			cfg := &models.AppConfig{
@@ -157,6 +586,17 @@ func TestService_GetBillByID(t *testing.T) {
 							return "test-prefix-"
 						},
 					},
+					DefaultPresentmentCurrency: func() string {
+						return "USD"
+					},
+					Rounding: models.RoundingConfig{
+						Mode: func() string {
+							return "half_up"
+						},
+						Level: func() string {
+							return "total"
+						},
+					},
 				},
 				Temporal: models.TemporalConfig{},
 			}
@@ -185,6 +625,9 @@ func TestService_GetBillByID(t *testing.T) {
 					},
 				},
 			}
+			require.NoError(t, fakeRepo.CreateBill(context.TODO(), &models.Bill{
+				ID: billID, CustomerID: "customer-123", Status: models.BillStatusOpen, WorkflowID: workflowID,
+			}))
 
 			// Mock the conversion service to return rates
 			mockConversionService.EXPECT().GetRates(gomock.Any()).Return(&models.RatesData{
@@ -197,7 +640,7 @@ func TestService_GetBillByID(t *testing.T) {
 				QueryWorkflow(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
 				Return(fakeEncodedValue{value: bill}, nil).AnyTimes()
 
-			retrievedBill, err := service.GetBillByID(context.TODO(), billID)
+			retrievedBill, err := service.GetBillByID(context.TODO(), billID, models.GetBillOptions{IncludeLineItems: true})
 
 			assert.NoError(t, err)
 			assert.NotNil(t, retrievedBill)
@@ -207,14 +650,13 @@ func TestService_GetBillByID(t *testing.T) {
 		})
 	})
 
-	t.Run("when_bill_not_found_in_workflow", func(t *testing.T) {
-		t.Run("should_fallback_to_database", func(t *testing.T) {
+	t.Run("when_workflow_continued_as_new", func(t *testing.T) {
+		t.Run("should_merge_line_items_persisted_by_previous_runs", func(t *testing.T) {
 			ctrl := gomock.NewController(t)
 
 			mockTemporalClient := mocksCore.NewMockClient(ctrl)
-			fakeRepo := &repository.FakeRepo{}
 			mockConversionService := mocks.NewMockExchangeRatesService(ctrl)
-
+			fakeRepo := &repositorytest.FakeRepo{}
 			cfg := &models.AppConfig{
 				Billing: models.BillingConfig{
 					Workflow: models.WorkflowConfig{
@@ -222,28 +664,241 @@ func TestService_GetBillByID(t *testing.T) {
 							return "test-prefix-"
 						},
 					},
+					Rounding: models.RoundingConfig{
+						Mode: func() string {
+							return "half_up"
+						},
+						Level: func() string {
+							return "total"
+						},
+					},
 				},
-				Temporal: models.TemporalConfig{},
 			}
+			service := NewService(cfg, mockTemporalClient, fakeRepo, mockConversionService)
+
+			billID := uuid.Must(uuid.NewV4())
+			newItem := func() *models.LineItem {
+				return &models.LineItem{
+					ID:        uuid.Must(uuid.NewV4()),
+					BillID:    billID,
+					Currency:  models.USD,
+					Quantity:  decimal.NewFromInt(1),
+					UnitPrice: decimal.NewFromInt(10),
+				}
+			}
+			persisted, current, pending := newItem(), newItem(), newItem()
+			require.NoError(t, fakeRepo.CreateBill(context.TODO(), &models.Bill{ID: billID, Status: models.BillStatusOpen}))
+			require.NoError(t, fakeRepo.AddLineItemToBill(context.TODO(), persisted))
+			require.NoError(t, fakeRepo.AddLineItemToBill(context.TODO(), current))
+
+			// The current run holds one persisted item and one not persisted yet
+			workflowBill := models.Bill{
+				ID:                  billID,
+				Status:              models.BillStatusOpen,
+				PresentmentCurrency: models.USD,
+				LineItems:           []*models.LineItem{current, pending},
+				LineItemCount:       3,
+			}
+			mockConversionService.EXPECT().GetRates(gomock.Any()).Return(&models.RatesData{
+				Rates:     map[string]float64{"USD": 1.0},
+				UpdatedAt: time.Now(),
+			}, nil)
+			mockTemporalClient.EXPECT().
+				QueryWorkflow(gomock.Any(), gomock.Any(), gomock.Any(), GetBillQuery).
+				Return(fakeEncodedValue{value: workflowBill}, nil)
+
+			retrievedBill, err := service.GetBillByID(context.TODO(), billID, models.GetBillOptions{IncludeLineItems: true})
+
+			require.NoError(t, err)
+			assert.Equal(t, []*models.LineItem{persisted, current, pending}, retrievedBill.LineItems)
+			assert.True(t, decimal.NewFromInt(30).Equal(retrievedBill.Total.GrandTotal.Amount))
+		})
+	})
+
+	t.Run("when_line_items_are_not_included", func(t *testing.T) {
+		cfg := &models.AppConfig{
+			Billing: models.BillingConfig{
+				Workflow: models.WorkflowConfig{
+					WorkflowIDPrefix: func() string {
+						return "test-prefix-"
+					},
+				},
+				DefaultPresentmentCurrency: func() string {
+					return "USD"
+				},
+				Rounding: models.RoundingConfig{
+					Mode: func() string {
+						return "half_up"
+					},
+					Level: func() string {
+						return "line"
+					},
+				},
+			},
+		}
+		rates := &models.RatesData{
+			Rates: map[string]float64{
+				"USD": 1.0,
+				"GEL": 2.5,
+			},
+			UpdatedAt: time.Now(),
+		}
+
+		t.Run("should_return_summary_from_workflow", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
 
+			mockTemporalClient := mocksCore.NewMockClient(ctrl)
+			mockConversionService := mocks.NewMockExchangeRatesService(ctrl)
+			fakeRepo := &repositorytest.FakeRepo{}
 			service := NewService(cfg, mockTemporalClient, fakeRepo, mockConversionService)
 
 			billID := uuid.Must(uuid.NewV4())
+			require.NoError(t, fakeRepo.CreateBill(context.TODO(), &models.Bill{ID: billID, Status: models.BillStatusOpen}))
+			summary := models.BillSummary{
+				Bill: &models.Bill{ID: billID, Status: models.BillStatusOpen, PresentmentCurrency: models.USD},
+				LineTotals: []models.LineTotalGroup{
+					{Currency: models.GEL, LineAmount: decimal.NewFromInt(5), Sum: decimal.NewFromInt(15), Count: 3},
+				},
+			}
+			mockConversionService.EXPECT().GetRates(gomock.Any()).Return(rates, nil)
+			mockTemporalClient.EXPECT().
+				QueryWorkflow(gomock.Any(), "test-prefix-"+billID.String(), "", GetBillSummaryQuery).
+				Return(fakeEncodedValue{value: summary}, nil)
 
-			// Mock the conversion service to return rates
-			mockConversionService.EXPECT().GetRates(gomock.Any()).Return(&models.RatesData{
-				Rates: map[string]float64{
-					"USD": 1.0,
+			retrievedBill, err := service.GetBillByID(context.TODO(), billID, models.GetBillOptions{})
+
+			require.NoError(t, err)
+			assert.Empty(t, retrievedBill.LineItems)
+			assert.Equal(t, int64(3), retrievedBill.LineItemCount)
+			assert.True(t, decimal.NewFromInt(15).Equal(retrievedBill.Total.ByCurrency[models.GEL]))
+			assert.True(t, decimal.NewFromInt(6).Equal(retrievedBill.Total.GrandTotal.Amount))
+		})
+
+		t.Run("should_aggregate_totals_in_database", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			mockTemporalClient := mocksCore.NewMockClient(ctrl)
+			mockConversionService := mocks.NewMockExchangeRatesService(ctrl)
+			fakeRepo := &repositorytest.FakeRepo{}
+			service := NewService(cfg, mockTemporalClient, fakeRepo, mockConversionService)
+
+			billID := uuid.Must(uuid.NewV4())
+			require.NoError(t, fakeRepo.CreateBill(context.TODO(), &models.Bill{
+				ID: billID, Status: models.BillStatusOpen, PresentmentCurrency: models.USD,
+			}))
+			for _, price := range []int64{10, 10, 20} {
+				require.NoError(t, fakeRepo.AddLineItemToBill(context.TODO(), &models.LineItem{
+					ID:        uuid.Must(uuid.NewV4()),
+					BillID:    billID,
+					Currency:  models.USD,
+					Quantity:  decimal.NewFromInt(1),
+					UnitPrice: decimal.NewFromInt(price),
+				}))
+			}
+			mockConversionService.EXPECT().GetRates(gomock.Any()).Return(rates, nil)
+			mockTemporalClient.EXPECT().
+				QueryWorkflow(gomock.Any(), gomock.Any(), gomock.Any(), GetBillSummaryQuery).
+				Return(nil, errors.New("workflow not found"))
+
+			retrievedBill, err := service.GetBillByID(context.TODO(), billID, models.GetBillOptions{})
+
+			require.NoError(t, err)
+			assert.Empty(t, retrievedBill.LineItems)
+			assert.Equal(t, int64(3), retrievedBill.LineItemCount)
+			assert.True(t, decimal.NewFromInt(40).Equal(retrievedBill.Total.GrandTotal.Amount))
+		})
+	})
+
+	t.Run("when_bill_is_closed", func(t *testing.T) {
+		cfg := &models.AppConfig{
+			Billing: models.BillingConfig{
+				Workflow: models.WorkflowConfig{
+					WorkflowIDPrefix: func() string {
+						return "test-prefix-"
+					},
 				},
-				UpdatedAt: time.Now(),
-			}, nil).AnyTimes()
+			},
+		}
+		newOpenBill := func(t *testing.T, fakeRepo *repositorytest.FakeRepo) models.Bill {
+			bill := models.Bill{
+				ID:                  uuid.Must(uuid.NewV4()),
+				CustomerID:          "customer-123",
+				Status:              models.BillStatusOpen,
+				PresentmentCurrency: models.USD,
+				CreatedAt:           time.Now(),
+				UpdatedAt:           time.Now(),
+			}
+			require.NoError(t, fakeRepo.CreateBill(context.TODO(), &bill))
+			return bill
+		}
+		closeInDatabase := func(fakeRepo *repositorytest.FakeRepo, billID uuid.UUID, closedAt time.Time) error {
+			return fakeRepo.CloseBill(context.TODO(), &models.Bill{
+				ID: billID,
+				Total: &models.Total{
+					ByCurrency: map[models.Currency]decimal.Decimal{models.USD: decimal.NewFromInt(42)},
+				},
+			}, closedAt, nil, nil)
+		}
+
+		t.Run("should_return_persisted_totals_without_querying_workflow", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			// No workflow query and no rates are expected: persisted totals must not be recalculated
+			mockTemporalClient := mocksCore.NewMockClient(ctrl)
+			mockConversionService := mocks.NewMockExchangeRatesService(ctrl)
+			fakeRepo := &repositorytest.FakeRepo{}
+			service := NewService(cfg, mockTemporalClient, fakeRepo, mockConversionService)
+
+			bill := newOpenBill(t, fakeRepo)
+			require.NoError(t, closeInDatabase(fakeRepo, bill.ID, time.Now()))
+
+			retrievedBill, err := service.GetBillByID(context.TODO(), bill.ID, models.GetBillOptions{IncludeLineItems: true})
+
+			require.NoError(t, err)
+			assert.Equal(t, models.BillStatusClosed, retrievedBill.Status)
+			require.NotNil(t, retrievedBill.Total)
+			assert.NotNil(t, retrievedBill.Total.ComputedAt)
+			assert.True(t, decimal.NewFromInt(42).Equal(retrievedBill.Total.ByCurrency[models.USD]))
+		})
+
+		t.Run("when_closed_by_workflow_after_read_should_return_persisted_totals", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			mockTemporalClient := mocksCore.NewMockClient(ctrl)
+			mockConversionService := mocks.NewMockExchangeRatesService(ctrl)
+			fakeRepo := &repositorytest.FakeRepo{}
+			service := NewService(cfg, mockTemporalClient, fakeRepo, mockConversionService)
+
+			bill := newOpenBill(t, fakeRepo)
+			closedAt := time.Now()
+			workflowBill := bill
+			workflowBill.Close(closedAt)
+			// The workflow closes the bill between the database read and the query
 			mockTemporalClient.EXPECT().
 				QueryWorkflow(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
-				Return(fakeEncodedValue{value: nil}, errors.New("not found"))
+				DoAndReturn(func(context.Context, string, string, string, ...interface{}) (converter.EncodedValue, error) {
+					return fakeEncodedValue{value: workflowBill}, closeInDatabase(fakeRepo, bill.ID, closedAt)
+				})
 
-			retrievedBill, err := service.GetBillByID(context.TODO(), billID)
+			retrievedBill, err := service.GetBillByID(context.TODO(), bill.ID, models.GetBillOptions{IncludeLineItems: true})
+
+			require.NoError(t, err)
+			require.NotNil(t, retrievedBill.Total)
+			assert.NotNil(t, retrievedBill.Total.ComputedAt)
+			assert.True(t, decimal.NewFromInt(42).Equal(retrievedBill.Total.ByCurrency[models.USD]))
+		})
+	})
+
+	t.Run("when_bill_does_not_exist", func(t *testing.T) {
+		t.Run("should_return_not_found_without_querying_workflow", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			mockTemporalClient := mocksCore.NewMockClient(ctrl)
+			mockConversionService := mocks.NewMockExchangeRatesService(ctrl)
+			service := NewService(&models.AppConfig{}, mockTemporalClient, &repositorytest.FakeRepo{}, mockConversionService)
+
+			retrievedBill, err := service.GetBillByID(context.TODO(), uuid.Must(uuid.NewV4()), models.GetBillOptions{})
 
-			assert.Error(t, err)
 			assert.Nil(t, retrievedBill)
 			assert.Equal(t, models.ErrBillNotFound, err)
 		})
@@ -258,6 +913,17 @@ func TestService_AddLineItemToBill(t *testing.T) {
 					return "test-prefix-"
 				},
 			},
+			DefaultPresentmentCurrency: func() string {
+				return "USD"
+			},
+			Rounding: models.RoundingConfig{
+				Mode: func() string {
+					return "half_up"
+				},
+				Level: func() string {
+					return "total"
+				},
+			},
 		},
 	}
 	t.Run("when_bill_is_open", func(t *testing.T) {
@@ -265,7 +931,7 @@ func TestService_AddLineItemToBill(t *testing.T) {
 			ctrl := gomock.NewController(t)
 
 			mockTemporalClient := mocksCore.NewMockClient(ctrl)
-			fakeRepo := &repository.FakeRepo{}
+			fakeRepo := &repositorytest.FakeRepo{}
 			mockConversionService := mocks.NewMockExchangeRatesService(ctrl)
 
 			service := NewService(testCfg, mockTemporalClient, fakeRepo, mockConversionService)
@@ -316,7 +982,7 @@ func TestService_AddLineItemToBill(t *testing.T) {
 			ctrl := gomock.NewController(t)
 
 			mockTemporalClient := mocksCore.NewMockClient(ctrl)
-			fakeRepo := &repository.FakeRepo{}
+			fakeRepo := &repositorytest.FakeRepo{}
 			mockConversionService := mocks.NewMockExchangeRatesService(ctrl)
 			mockConversionService.EXPECT().GetRates(gomock.Any()).Return(&models.RatesData{
 				Rates: map[string]float64{
@@ -340,6 +1006,7 @@ func TestService_AddLineItemToBill(t *testing.T) {
 				UpdatedAt:  time.Now(),
 				ClosedAt:   &[]time.Time{time.Now()}[0],
 			}
+			require.NoError(t, fakeRepo.CreateBill(context.TODO(), &bill))
 			mockTemporalClient.EXPECT().
 				QueryWorkflow(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
 				Return(fakeEncodedValue{value: bill}, nil)
@@ -358,16 +1025,258 @@ func TestService_AddLineItemToBill(t *testing.T) {
 			assert.Equal(t, models.ErrBillClosed, err)
 		})
 	})
-}
 
-func TestService_CloseBill(t *testing.T) {
-	testCfg := &models.AppConfig{
-		Billing: models.BillingConfig{
-			Workflow: models.WorkflowConfig{
-				WorkflowIDPrefix: func() string {
-					return "test-prefix-"
-				},
-			},
+	t.Run("when_bill_is_closing", func(t *testing.T) {
+		periodEnd := time.Now().Add(-5 * time.Minute)
+		closingAt := periodEnd
+		bill := models.Bill{
+			ID:          uuid.Must(uuid.NewV4()),
+			CustomerID:  "customer-123",
+			Status:      models.BillStatusClosing,
+			PeriodStart: periodEnd.AddDate(0, -1, 0),
+			PeriodEnd:   periodEnd,
+			ClosingAt:   &closingAt,
+			WorkflowID:  "test-prefix-closing",
+		}
+		newReq := func(occurredAt *time.Time) *models.AddLineItemRequest {
+			return &models.AddLineItemRequest{
+				Description: "Late usage",
+				Currency:    models.USD,
+				Quantity:    decimal.NewFromInt(1),
+				UnitPrice:   decimal.NewFromInt(3),
+				OccurredAt:  occurredAt,
+			}
+		}
+
+		t.Run("should_signal_line_item_that_occurred_within_period", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			mockTemporalClient := mocksCore.NewMockClient(ctrl)
+			mockConversionService := mocks.NewMockExchangeRatesService(ctrl)
+			mockConversionService.EXPECT().GetRates(gomock.Any()).Return(&models.RatesData{
+				Rates:     map[string]float64{"USD": 1.0},
+				UpdatedAt: time.Now(),
+			}, nil).AnyTimes()
+			mockTemporalClient.EXPECT().
+				QueryWorkflow(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+				Return(fakeEncodedValue{value: bill}, nil).AnyTimes()
+
+			occurredAt := periodEnd.Add(-time.Minute)
+			mockTemporalClient.EXPECT().
+				SignalWorkflow(gomock.Any(), bill.WorkflowID, "", AddLineItemSignal, gomock.Any()).
+				DoAndReturn(func(_ context.Context, _, _, _ string, arg interface{}) error {
+					signal := arg.(LineItemSignalData)
+					assert.True(t, occurredAt.Equal(signal.LineItem.OccurredAt))
+					assert.True(t, signal.LineItem.CreatedAt.After(periodEnd))
+					return nil
+				})
+
+			service := NewService(testCfg, mockTemporalClient, &repositorytest.FakeRepo{}, mockConversionService)
+			updated, err := service.AddLineItemToBill(context.TODO(), bill.ID, newReq(&occurredAt))
+
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), updated.LineItemCount)
+		})
+
+		t.Run("should_reject_line_item_that_occurred_after_period", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			mockTemporalClient := mocksCore.NewMockClient(ctrl)
+			mockConversionService := mocks.NewMockExchangeRatesService(ctrl)
+			mockConversionService.EXPECT().GetRates(gomock.Any()).Return(&models.RatesData{
+				Rates:     map[string]float64{"USD": 1.0},
+				UpdatedAt: time.Now(),
+			}, nil).AnyTimes()
+			mockTemporalClient.EXPECT().
+				QueryWorkflow(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+				Return(fakeEncodedValue{value: bill}, nil)
+
+			service := NewService(testCfg, mockTemporalClient, &repositorytest.FakeRepo{}, mockConversionService)
+			updated, err := service.AddLineItemToBill(context.TODO(), bill.ID, newReq(nil))
+
+			assert.Nil(t, updated)
+			assert.Equal(t, models.ErrLineItemOutsidePeriod, err)
+		})
+	})
+}
+
+func TestService_EditLineItems(t *testing.T) {
+	testCfg := &models.AppConfig{
+		Billing: models.BillingConfig{
+			Rounding: models.RoundingConfig{
+				Mode:  func() string { return "half_up" },
+				Level: func() string { return "total" },
+			},
+			Validation: models.ValidationConfig{
+				MaxTotalAmount: func() float64 { return 1000000 },
+			},
+		},
+	}
+	rates := &models.RatesData{Rates: map[string]float64{"USD": 1.0}, UpdatedAt: time.Now()}
+
+	newOpenBill := func(t *testing.T, fakeRepo *repositorytest.FakeRepo) (models.Bill, *models.LineItem) {
+		billID := uuid.Must(uuid.NewV4())
+		item := &models.LineItem{
+			ID:          uuid.Must(uuid.NewV4()),
+			BillID:      billID,
+			Description: "Test service",
+			Currency:    models.USD,
+			Quantity:    decimal.NewFromInt(2),
+			UnitPrice:   decimal.NewFromInt(10),
+			CreatedAt:   time.Now(),
+		}
+		bill := models.Bill{
+			ID:                  billID,
+			CustomerID:          "customer-123",
+			Status:              models.BillStatusOpen,
+			PresentmentCurrency: models.USD,
+			WorkflowID:          "test-prefix-" + billID.String(),
+		}
+		require.NoError(t, fakeRepo.CreateBill(context.TODO(), &bill))
+		require.NoError(t, fakeRepo.AddLineItemToBill(context.TODO(), item))
+		return bill, item
+	}
+	// summary is the workflow summary of the bill holding the line item
+	summary := func(bill models.Bill, item *models.LineItem) models.BillSummary {
+		return models.BillSummary{Bill: &bill, LineTotals: models.GroupLineTotals([]*models.LineItem{item})}
+	}
+
+	t.Run("when_bill_is_open", func(t *testing.T) {
+		t.Run("should_update_line_item_and_recompute_totals", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			mockTemporalClient := mocksCore.NewMockClient(ctrl)
+			mockConversionService := mocks.NewMockExchangeRatesService(ctrl)
+			fakeRepo := &repositorytest.FakeRepo{}
+			service := NewService(testCfg, mockTemporalClient, fakeRepo, mockConversionService)
+
+			bill, item := newOpenBill(t, fakeRepo)
+			quantity := decimal.NewFromInt(3)
+
+			mockConversionService.EXPECT().GetRates(gomock.Any()).Return(rates, nil).AnyTimes()
+			mockTemporalClient.EXPECT().
+				QueryWorkflow(gomock.Any(), bill.WorkflowID, "", GetBillSummaryQuery).
+				Return(fakeEncodedValue{value: summary(bill, item)}, nil)
+			mockTemporalClient.EXPECT().
+				QueryWorkflow(gomock.Any(), bill.WorkflowID, "", GetLineItemQuery, item.ID).
+				Return(fakeEncodedValue{value: item}, nil)
+			mockTemporalClient.EXPECT().
+				SignalWorkflow(gomock.Any(), bill.WorkflowID, "", UpdateLineItemSignal, gomock.Any()).
+				DoAndReturn(func(_ context.Context, _, _, _ string, arg interface{}) error {
+					signal := arg.(UpdateLineItemSignalData)
+					assert.True(t, quantity.Equal(signal.LineItem.Quantity))
+					assert.NotNil(t, signal.LineItem.UpdatedAt)
+					return nil
+				})
+
+			updatedBill, err := service.UpdateLineItem(context.TODO(), bill.ID, item.ID, models.LineItemUpdate{Quantity: &quantity})
+
+			require.NoError(t, err)
+			assert.Empty(t, updatedBill.LineItems)
+			assert.Equal(t, int64(1), updatedBill.LineItemCount)
+			assert.True(t, decimal.NewFromInt(30).Equal(updatedBill.Total.ByCurrency[models.USD]))
+		})
+
+		t.Run("should_look_up_line_items_of_previous_runs_in_the_database", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			mockTemporalClient := mocksCore.NewMockClient(ctrl)
+			mockConversionService := mocks.NewMockExchangeRatesService(ctrl)
+			fakeRepo := &repositorytest.FakeRepo{}
+			service := NewService(testCfg, mockTemporalClient, fakeRepo, mockConversionService)
+
+			bill, item := newOpenBill(t, fakeRepo)
+
+			mockConversionService.EXPECT().GetRates(gomock.Any()).Return(rates, nil).AnyTimes()
+			mockTemporalClient.EXPECT().
+				QueryWorkflow(gomock.Any(), bill.WorkflowID, "", GetBillSummaryQuery).
+				Return(fakeEncodedValue{value: summary(bill, item)}, nil)
+			mockTemporalClient.EXPECT().
+				QueryWorkflow(gomock.Any(), bill.WorkflowID, "", GetLineItemQuery, item.ID).
+				Return(fakeEncodedValue{value: (*models.LineItem)(nil)}, nil)
+			mockTemporalClient.EXPECT().
+				SignalWorkflow(gomock.Any(), bill.WorkflowID, "", RemoveLineItemSignal, gomock.Any()).
+				DoAndReturn(func(_ context.Context, _, _, _ string, arg interface{}) error {
+					assert.Equal(t, item.ID, arg.(RemoveLineItemSignalData).LineItem.ID)
+					return nil
+				})
+
+			updatedBill, err := service.RemoveLineItem(context.TODO(), bill.ID, item.ID, "duplicate charge")
+
+			require.NoError(t, err)
+			assert.Zero(t, updatedBill.LineItemCount)
+		})
+
+		t.Run("should_return_not_found_for_unknown_line_item", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			mockTemporalClient := mocksCore.NewMockClient(ctrl)
+			mockConversionService := mocks.NewMockExchangeRatesService(ctrl)
+			fakeRepo := &repositorytest.FakeRepo{}
+			service := NewService(testCfg, mockTemporalClient, fakeRepo, mockConversionService)
+
+			bill, item := newOpenBill(t, fakeRepo)
+
+			mockConversionService.EXPECT().GetRates(gomock.Any()).Return(rates, nil).AnyTimes()
+			mockTemporalClient.EXPECT().
+				QueryWorkflow(gomock.Any(), bill.WorkflowID, "", GetBillSummaryQuery).
+				Return(fakeEncodedValue{value: summary(bill, item)}, nil).Times(2)
+			mockTemporalClient.EXPECT().
+				QueryWorkflow(gomock.Any(), bill.WorkflowID, "", GetLineItemQuery, gomock.Any()).
+				Return(fakeEncodedValue{value: (*models.LineItem)(nil)}, nil).Times(2)
+
+			description := "Renamed"
+			_, err := service.UpdateLineItem(context.TODO(), bill.ID, uuid.Must(uuid.NewV4()), models.LineItemUpdate{Description: &description})
+			assert.Equal(t, models.ErrLineItemNotFound, err)
+
+			_, err = service.RemoveLineItem(context.TODO(), bill.ID, uuid.Must(uuid.NewV4()), "duplicate charge")
+			assert.Equal(t, models.ErrLineItemNotFound, err)
+		})
+	})
+
+	t.Run("when_bill_is_closed", func(t *testing.T) {
+		t.Run("should_return_error", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			mockTemporalClient := mocksCore.NewMockClient(ctrl)
+			fakeRepo := &repositorytest.FakeRepo{}
+			mockConversionService := mocks.NewMockExchangeRatesService(ctrl)
+			service := NewService(testCfg, mockTemporalClient, fakeRepo, mockConversionService)
+
+			bill, item := newOpenBill(t, fakeRepo)
+			require.NoError(t, fakeRepo.CloseBill(context.TODO(), &bill, time.Now(), nil, nil))
+
+			mockConversionService.EXPECT().GetRates(gomock.Any()).Return(rates, nil).AnyTimes()
+
+			description := "Renamed"
+			_, err := service.UpdateLineItem(context.TODO(), bill.ID, item.ID, models.LineItemUpdate{Description: &description})
+			assert.Equal(t, models.ErrBillClosed, err)
+
+			_, err = service.RemoveLineItem(context.TODO(), bill.ID, item.ID, "duplicate charge")
+			assert.Equal(t, models.ErrBillClosed, err)
+		})
+	})
+}
+
+func TestService_CloseBill(t *testing.T) {
+	testCfg := &models.AppConfig{
+		Billing: models.BillingConfig{
+			Workflow: models.WorkflowConfig{
+				WorkflowIDPrefix: func() string {
+					return "test-prefix-"
+				},
+			},
+			DefaultPresentmentCurrency: func() string {
+				return "USD"
+			},
+			Rounding: models.RoundingConfig{
+				Mode: func() string {
+					return "half_up"
+				},
+				Level: func() string {
+					return "total"
+				},
+			},
 		},
 	}
 	t.Run("when_bill_is_open", func(t *testing.T) {
@@ -375,7 +1284,7 @@ func TestService_CloseBill(t *testing.T) {
 			ctrl := gomock.NewController(t)
 
 			mockTemporalClient := mocksCore.NewMockClient(ctrl)
-			fakeRepo := &repository.FakeRepo{}
+			fakeRepo := &repositorytest.FakeRepo{}
 			mockConversionService := mocks.NewMockExchangeRatesService(ctrl)
 
 			service := NewService(testCfg, mockTemporalClient, fakeRepo, mockConversionService)
@@ -419,7 +1328,7 @@ func TestService_CloseBill(t *testing.T) {
 			ctrl := gomock.NewController(t)
 
 			mockTemporalClient := mocksCore.NewMockClient(ctrl)
-			fakeRepo := &repository.FakeRepo{}
+			fakeRepo := &repositorytest.FakeRepo{}
 			mockConversionService := mocks.NewMockExchangeRatesService(ctrl)
 
 			service := NewService(testCfg, mockTemporalClient, fakeRepo, mockConversionService)
@@ -438,6 +1347,7 @@ func TestService_CloseBill(t *testing.T) {
 				UpdatedAt:  time.Now(),
 				ClosedAt:   &closedAt,
 			}
+			require.NoError(t, fakeRepo.CreateBill(context.TODO(), &bill))
 
 			// Mock the conversion service to return rates
 			mockConversionService.EXPECT().GetRates(gomock.Any()).Return(&models.RatesData{
@@ -459,3 +1369,1239 @@ func TestService_CloseBill(t *testing.T) {
 		})
 	})
 }
+
+func TestService_BillTransitions(t *testing.T) {
+	testCfg := &models.AppConfig{
+		Temporal: models.TemporalConfig{
+			TaskQueue: func() string { return "test-queue" },
+		},
+		Billing: models.BillingConfig{
+			Workflow: models.WorkflowConfig{
+				ContinueAsNewSignalThreshold:  func() int { return 1000 },
+				SearchAttributeTotalsInterval: func() int { return 0 },
+				LateUsageGraceWindow:          func() int { return 900 },
+			},
+			Rounding: models.RoundingConfig{
+				Mode:  func() string { return "half_up" },
+				Level: func() string { return "total" },
+			},
+		},
+	}
+	rates := &models.RatesData{Rates: map[string]float64{"USD": 1.0}, UpdatedAt: time.Now()}
+
+	newBill := func(status models.BillStatus) models.Bill {
+		billID := uuid.Must(uuid.NewV4())
+		bill := models.Bill{
+			ID:          billID,
+			CustomerID:  "customer-123",
+			Status:      status,
+			WorkflowID:  "test-prefix-" + billID.String(),
+			PeriodStart: time.Now().Add(-48 * time.Hour),
+			PeriodEnd:   time.Now().Add(-24 * time.Hour),
+		}
+		if status != models.BillStatusOpen {
+			bill.ClosedAt = &bill.PeriodEnd
+		}
+		return bill
+	}
+
+	setup := func(t *testing.T, bill models.Bill) (*service, *mocksCore.MockClient, *repositorytest.FakeRepo) {
+		ctrl := gomock.NewController(t)
+		mockTemporalClient := mocksCore.NewMockClient(ctrl)
+		fakeRepo := &repositorytest.FakeRepo{}
+		mockConversionService := mocks.NewMockExchangeRatesService(ctrl)
+		mockConversionService.EXPECT().GetRates(gomock.Any()).Return(rates, nil).AnyTimes()
+		mockTemporalClient.EXPECT().
+			QueryWorkflow(gomock.Any(), bill.WorkflowID, "", GetBillQuery).
+			Return(fakeEncodedValue{value: bill}, nil).AnyTimes()
+		mockTemporalClient.EXPECT().
+			QueryWorkflow(gomock.Any(), bill.WorkflowID, "", GetBillSummaryQuery).
+			Return(fakeEncodedValue{value: models.BillSummary{Bill: &bill, LineTotals: []models.LineTotalGroup{}}}, nil).AnyTimes()
+		stored := bill
+		require.NoError(t, fakeRepo.CreateBill(context.TODO(), &stored))
+		return NewService(testCfg, mockTemporalClient, fakeRepo, mockConversionService), mockTemporalClient, fakeRepo
+	}
+
+	t.Run("when_bill_is_open", func(t *testing.T) {
+		t.Run("should_void_bill_through_workflow", func(t *testing.T) {
+			bill := newBill(models.BillStatusOpen)
+			service, mockTemporalClient, fakeRepo := setup(t, bill)
+			mockTemporalClient.EXPECT().
+				SignalWorkflow(gomock.Any(), bill.WorkflowID, "", VoidBillSignal, gomock.Any()).
+				Return(nil)
+
+			voided, err := service.VoidBill(context.TODO(), bill.ID, "created by mistake")
+
+			require.NoError(t, err)
+			assert.Equal(t, models.BillStatusVoided, voided.Status)
+			assert.Equal(t, "created by mistake", voided.VoidReason)
+			stored, _ := fakeRepo.GetBillByID(context.TODO(), bill.ID, models.GetBillOptions{})
+			assert.Equal(t, models.BillStatusOpen, stored.Status, "the workflow persists the void")
+		})
+
+		t.Run("should_not_finalize_or_reopen_bill", func(t *testing.T) {
+			bill := newBill(models.BillStatusOpen)
+			service, _, _ := setup(t, bill)
+
+			_, err := service.FinalizeBill(context.TODO(), bill.ID)
+			assert.Equal(t, models.ErrInvalidBillTransition, err)
+
+			_, err = service.ReopenBill(context.TODO(), bill.ID)
+			assert.Equal(t, models.ErrInvalidBillTransition, err)
+		})
+	})
+
+	t.Run("when_bill_is_closed", func(t *testing.T) {
+		t.Run("should_void_bill_in_database", func(t *testing.T) {
+			bill := newBill(models.BillStatusClosed)
+			service, _, fakeRepo := setup(t, bill)
+
+			voided, err := service.VoidBill(context.TODO(), bill.ID, "wrong customer")
+
+			require.NoError(t, err)
+			assert.Equal(t, models.BillStatusVoided, voided.Status)
+			stored, _ := fakeRepo.GetBillByID(context.TODO(), bill.ID, models.GetBillOptions{})
+			assert.Equal(t, models.BillStatusVoided, stored.Status)
+		})
+
+		t.Run("should_finalize_bill", func(t *testing.T) {
+			bill := newBill(models.BillStatusClosed)
+			service, _, fakeRepo := setup(t, bill)
+
+			finalized, err := service.FinalizeBill(context.TODO(), bill.ID)
+
+			require.NoError(t, err)
+			assert.Equal(t, models.BillStatusFinalized, finalized.Status)
+			assert.NotNil(t, finalized.FinalizedAt)
+			stored, _ := fakeRepo.GetBillByID(context.TODO(), bill.ID, models.GetBillOptions{})
+			assert.Equal(t, models.BillStatusFinalized, stored.Status)
+		})
+
+		t.Run("should_reopen_bill_by_starting_workflow", func(t *testing.T) {
+			bill := newBill(models.BillStatusClosed)
+			service, mockTemporalClient, _ := setup(t, bill)
+			mockTemporalClient.EXPECT().
+				ExecuteWorkflow(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, options client.StartWorkflowOptions, _ interface{}, args ...interface{}) (client.WorkflowRun, error) {
+					assert.Equal(t, bill.WorkflowID, options.ID)
+					assert.Zero(t, options.WorkflowExecutionTimeout, "bill workflows have no execution timeout")
+					input := args[0].(BillWorkflowInput)
+					assert.True(t, input.Reopened)
+					assert.True(t, input.Bill.IsOpen())
+					return nil, nil
+				})
+
+			reopened, err := service.ReopenBill(context.TODO(), bill.ID)
+
+			require.NoError(t, err)
+			assert.True(t, reopened.IsOpen())
+			assert.Nil(t, reopened.ClosedAt)
+		})
+	})
+
+	t.Run("when_bill_is_finalized", func(t *testing.T) {
+		t.Run("should_reject_void_and_reopen", func(t *testing.T) {
+			bill := newBill(models.BillStatusFinalized)
+			service, _, _ := setup(t, bill)
+
+			_, err := service.VoidBill(context.TODO(), bill.ID, "too late")
+			assert.Equal(t, models.ErrInvalidBillTransition, err)
+
+			_, err = service.ReopenBill(context.TODO(), bill.ID)
+			assert.Equal(t, models.ErrInvalidBillTransition, err)
+		})
+	})
+}
+
+func TestService_ListLineItems(t *testing.T) {
+	cfg := &models.AppConfig{
+		Billing: models.BillingConfig{
+			Rounding: models.RoundingConfig{
+				Mode: func() string {
+					return "half_up"
+				},
+				Level: func() string {
+					return "total"
+				},
+			},
+		},
+	}
+
+	t.Run("when_bill_does_not_exist", func(t *testing.T) {
+		t.Run("should_return_error", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			service := NewService(cfg, mocksCore.NewMockClient(ctrl), &repositorytest.FakeRepo{}, mocks.NewMockExchangeRatesService(ctrl))
+
+			lineItems, next, err := service.ListLineItems(context.TODO(), uuid.Must(uuid.NewV4()), models.LineItemFilter{Limit: 10})
+
+			assert.Equal(t, models.ErrBillNotFound, err)
+			assert.Nil(t, lineItems)
+			assert.Nil(t, next)
+		})
+	})
+
+	t.Run("when_bill_has_more_line_items_than_the_limit", func(t *testing.T) {
+		t.Run("should_page_through_all_line_items", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			fakeRepo := &repositorytest.FakeRepo{}
+			service := NewService(cfg, mocksCore.NewMockClient(ctrl), fakeRepo, mocks.NewMockExchangeRatesService(ctrl))
+
+			billID := uuid.Must(uuid.NewV4())
+			require.NoError(t, fakeRepo.CreateBill(context.TODO(), &models.Bill{ID: billID, Status: models.BillStatusOpen}))
+			start := time.Now()
+			for i := 0; i < 5; i++ {
+				currency := models.USD
+				if i%2 == 1 {
+					currency = models.GEL
+				}
+				require.NoError(t, fakeRepo.AddLineItemToBill(context.TODO(), &models.LineItem{
+					ID:        uuid.Must(uuid.NewV4()),
+					BillID:    billID,
+					Currency:  currency,
+					Quantity:  decimal.NewFromInt(2),
+					UnitPrice: decimal.NewFromInt(int64(i)),
+					CreatedAt: start.Add(time.Duration(i) * time.Second),
+				}))
+			}
+
+			filter := models.LineItemFilter{Limit: 2}
+			var pages [][]*models.LineItem
+			for {
+				lineItems, next, err := service.ListLineItems(context.TODO(), billID, filter)
+				require.NoError(t, err)
+				pages = append(pages, lineItems)
+				if next == nil {
+					break
+				}
+				filter.After = next
+			}
+
+			require.Len(t, pages, 3)
+			assert.Len(t, pages[2], 1)
+			assert.True(t, decimal.NewFromInt(8).Equal(pages[2][0].Total))
+		})
+
+		t.Run("should_filter_by_currency", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			fakeRepo := &repositorytest.FakeRepo{}
+			service := NewService(cfg, mocksCore.NewMockClient(ctrl), fakeRepo, mocks.NewMockExchangeRatesService(ctrl))
+
+			billID := uuid.Must(uuid.NewV4())
+			require.NoError(t, fakeRepo.CreateBill(context.TODO(), &models.Bill{ID: billID, Status: models.BillStatusOpen}))
+			for _, currency := range []models.Currency{models.USD, models.GEL, models.USD} {
+				require.NoError(t, fakeRepo.AddLineItemToBill(context.TODO(), &models.LineItem{
+					ID:        uuid.Must(uuid.NewV4()),
+					BillID:    billID,
+					Currency:  currency,
+					Quantity:  decimal.NewFromInt(1),
+					UnitPrice: decimal.NewFromInt(1),
+					CreatedAt: time.Now(),
+				}))
+			}
+
+			lineItems, next, err := service.ListLineItems(context.TODO(), billID, models.LineItemFilter{Currency: models.GEL, Limit: 10})
+
+			require.NoError(t, err)
+			assert.Nil(t, next)
+			require.Len(t, lineItems, 1)
+			assert.Equal(t, models.GEL, lineItems[0].Currency)
+		})
+	})
+}
+
+func TestService_AuditBillTotals(t *testing.T) {
+	cfg := &models.AppConfig{
+		Billing: models.BillingConfig{
+			Rounding: models.RoundingConfig{
+				Mode: func() string {
+					return "half_up"
+				},
+				Level: func() string {
+					return "total"
+				},
+			},
+		},
+	}
+	t.Run("when_bill_is_open", func(t *testing.T) {
+		t.Run("should_return_failed_precondition", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			fakeRepo := &repositorytest.FakeRepo{}
+			service := NewService(cfg, mocksCore.NewMockClient(ctrl), fakeRepo, mocks.NewMockExchangeRatesService(ctrl))
+
+			bill := &models.Bill{ID: uuid.Must(uuid.NewV4()), Status: models.BillStatusOpen}
+			require.NoError(t, fakeRepo.CreateBill(context.TODO(), bill))
+
+			audit, err := service.AuditBillTotals(context.TODO(), bill.ID)
+
+			assert.Nil(t, audit)
+			assert.Equal(t, models.ErrTotalsNotPersisted, err)
+		})
+	})
+
+	t.Run("when_bill_is_closed", func(t *testing.T) {
+		t.Run("should_report_discrepancies", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			fakeRepo := &repositorytest.FakeRepo{}
+			mockConversionService := mocks.NewMockExchangeRatesService(ctrl)
+			mockConversionService.EXPECT().GetRates(gomock.Any()).Return(&models.RatesData{
+				Rates:     map[string]float64{"USD": 1.0},
+				UpdatedAt: time.Now(),
+			}, nil)
+			service := NewService(cfg, mocksCore.NewMockClient(ctrl), fakeRepo, mockConversionService)
+
+			billID := uuid.Must(uuid.NewV4())
+			bill := &models.Bill{ID: billID, Status: models.BillStatusOpen, PresentmentCurrency: models.USD}
+			require.NoError(t, fakeRepo.CreateBill(context.TODO(), bill))
+			require.NoError(t, fakeRepo.AddLineItemToBill(context.TODO(), &models.LineItem{
+				ID:        uuid.Must(uuid.NewV4()),
+				BillID:    billID,
+				Currency:  models.USD,
+				Quantity:  decimal.NewFromInt(1),
+				UnitPrice: decimal.NewFromInt(10),
+				Total:     decimal.NewFromInt(10),
+			}))
+			require.NoError(t, fakeRepo.CloseBill(context.TODO(), &models.Bill{
+				ID: billID,
+				Total: &models.Total{
+					ByCurrency: map[models.Currency]decimal.Decimal{models.USD: decimal.NewFromInt(11)},
+					GrandTotal: &models.Converted{Currency: models.USD, Amount: decimal.NewFromInt(10)},
+				},
+			}, time.Now(), nil, nil))
+
+			audit, err := service.AuditBillTotals(context.TODO(), billID)
+
+			require.NoError(t, err)
+			assert.False(t, audit.Matches)
+			require.Len(t, audit.Discrepancies, 1)
+			assert.Equal(t, "by_currency.USD", audit.Discrepancies[0].Field)
+		})
+	})
+}
+
+func TestService_GetBillHistory(t *testing.T) {
+	t.Run("when_bill_does_not_exist_should_return_not_found", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		service := NewService(&models.AppConfig{}, mocksCore.NewMockClient(ctrl), &repositorytest.FakeRepo{}, mocks.NewMockExchangeRatesService(ctrl))
+
+		history, err := service.GetBillHistory(context.TODO(), uuid.Must(uuid.NewV4()))
+
+		assert.Nil(t, history)
+		assert.Equal(t, models.ErrBillNotFound, err)
+	})
+
+	t.Run("should_return_verified_history_attributed_to_actors", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		fakeRepo := &repositorytest.FakeRepo{}
+		service := NewService(&models.AppConfig{}, mocksCore.NewMockClient(ctrl), fakeRepo, mocks.NewMockExchangeRatesService(ctrl))
+
+		bill := &models.Bill{ID: uuid.Must(uuid.NewV4()), Status: models.BillStatusDraft}
+		ctx := models.WithAuditMetadata(context.TODO(), models.AuditMetadata{Actor: "admin", RequestID: "req-1"})
+		require.NoError(t, fakeRepo.CreateBill(ctx, bill))
+		require.NoError(t, fakeRepo.ActivateBill(context.TODO(), bill.ID))
+
+		history, err := service.GetBillHistory(context.TODO(), bill.ID)
+
+		require.NoError(t, err)
+		assert.True(t, history.Verified)
+		require.Len(t, history.Events, 2)
+		assert.Equal(t, models.AuditActionBillCreated, history.Events[0].Action)
+		assert.Equal(t, "admin", history.Events[0].Actor)
+		assert.Equal(t, "req-1", history.Events[0].RequestID)
+		assert.Equal(t, models.AuditActionBillActivated, history.Events[1].Action)
+		assert.Equal(t, models.SystemActor, history.Events[1].Actor)
+	})
+
+	t.Run("when_event_was_altered_should_report_unverified_history", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		fakeRepo := &repositorytest.FakeRepo{}
+		service := NewService(&models.AppConfig{}, mocksCore.NewMockClient(ctrl), fakeRepo, mocks.NewMockExchangeRatesService(ctrl))
+
+		bill := &models.Bill{ID: uuid.Must(uuid.NewV4()), Status: models.BillStatusDraft}
+		require.NoError(t, fakeRepo.CreateBill(context.TODO(), bill))
+		require.NoError(t, fakeRepo.ActivateBill(context.TODO(), bill.ID))
+		events, err := fakeRepo.ListAuditEvents(context.TODO(), bill.ID)
+		require.NoError(t, err)
+		events[0].Actor = "someone"
+
+		history, err := service.GetBillHistory(context.TODO(), bill.ID)
+
+		require.NoError(t, err)
+		assert.False(t, history.Verified)
+		assert.Equal(t, "event 1 was altered", history.VerificationError)
+	})
+}
+
+func TestService_Ledger(t *testing.T) {
+	ledgerCfg := &models.AppConfig{
+		Billing: models.BillingConfig{
+			Validation: models.ValidationConfig{AllowedCurrencies: func() []string { return []string{"USD", "GEL"} }},
+			Rounding: models.RoundingConfig{
+				Mode:  func() string { return "half_up" },
+				Level: func() string { return "total" },
+			},
+			Ledger:  testLedgerConfig(),
+			Invoice: testInvoiceTaxConfig(),
+		},
+	}
+	// newClosedBill closes a bill of 100 GEL booked at 0.4 USD per GEL
+	newClosedBill := func(t *testing.T, fakeRepo *repositorytest.FakeRepo) *models.Bill {
+		settings, err := models.LedgerSettingsFromConfig(ledgerCfg)
+		require.NoError(t, err)
+		bill := &models.Bill{ID: uuid.Must(uuid.NewV4()), Status: models.BillStatusOpen}
+		require.NoError(t, fakeRepo.CreateBill(context.TODO(), bill))
+		closing := &models.Bill{ID: bill.ID, Total: &models.Total{
+			ByCurrency: map[models.Currency]decimal.Decimal{models.GEL: decimal.NewFromInt(100)},
+		}}
+		closedAt := time.Now()
+		journal, err := models.NewBillClosedJournal(closing, closedAt,
+			&models.RatesData{Rates: map[string]float64{"USD": 1, "GEL": 2.5}}, settings)
+		require.NoError(t, err)
+		require.NoError(t, fakeRepo.CloseBill(context.TODO(), closing, closedAt, journal, nil))
+		return bill
+	}
+	newService := func(t *testing.T, fakeRepo *repositorytest.FakeRepo, gelRate float64) Service {
+		ctrl := gomock.NewController(t)
+		conversionService := mocks.NewMockExchangeRatesService(ctrl)
+		conversionService.EXPECT().GetRates(gomock.Any()).Return(&models.RatesData{
+			Rates: map[string]float64{"USD": 1, "GEL": gelRate},
+		}, nil).AnyTimes()
+		return NewService(ledgerCfg, mocksCore.NewMockClient(ctrl), fakeRepo, conversionService)
+	}
+
+	t.Run("when_payment_is_received_at_higher_rate_should_post_fx_gain", func(t *testing.T) {
+		fakeRepo := &repositorytest.FakeRepo{}
+		service := newService(t, fakeRepo, 2)
+		bill := newClosedBill(t, fakeRepo)
+
+		journals, err := service.RecordPayment(context.TODO(), bill.ID, &models.RecordPaymentRequest{
+			Amount: decimal.NewFromInt(100), Currency: models.GEL, Reference: "TRF-1",
+		})
+
+		require.NoError(t, err)
+		require.Len(t, journals, 2)
+		assert.Equal(t, models.JournalPayment, journals[0].Type)
+		assert.Equal(t, models.JournalFXDifference, journals[1].Type)
+		ledger, err := service.GetBillLedger(context.TODO(), bill.ID)
+		require.NoError(t, err)
+		receivable, receivableUSD := models.LedgerBalance(ledger, "1200", models.GEL)
+		assert.True(t, receivable.IsZero())
+		assert.True(t, receivableUSD.IsZero())
+		gain, _ := models.LedgerBalance(ledger, "7100", models.USD)
+		assert.True(t, decimal.NewFromInt(-10).Equal(gain), gain.String())
+
+		balance, err := service.GetTrialBalance(context.TODO(), time.Now())
+		require.NoError(t, err)
+		assert.True(t, balance.Balanced)
+		assert.True(t, decimal.NewFromInt(90).Equal(balance.TotalDebit), balance.TotalDebit.String())
+	})
+
+	t.Run("when_payment_reference_is_recorded_twice_should_return_already_posted", func(t *testing.T) {
+		fakeRepo := &repositorytest.FakeRepo{}
+		service := newService(t, fakeRepo, 2.5)
+		bill := newClosedBill(t, fakeRepo)
+		req := &models.RecordPaymentRequest{Amount: decimal.NewFromInt(40), Currency: models.GEL, Reference: "TRF-1"}
+
+		_, err := service.RecordPayment(context.TODO(), bill.ID, req)
+		require.NoError(t, err)
+		journals, err := service.RecordPayment(context.TODO(), bill.ID, req)
+
+		assert.Nil(t, journals)
+		assert.Equal(t, models.ErrJournalAlreadyPosted, err)
+	})
+
+	t.Run("when_credit_note_exceeds_outstanding_balance_should_return_error", func(t *testing.T) {
+		fakeRepo := &repositorytest.FakeRepo{}
+		service := newService(t, fakeRepo, 2.5)
+		bill := newClosedBill(t, fakeRepo)
+
+		journal, err := service.IssueCreditNote(context.TODO(), bill.ID, &models.IssueCreditNoteRequest{
+			Amount: decimal.NewFromInt(101), Currency: models.GEL, Reference: "CN-1", Reason: "outage",
+		})
+
+		assert.Nil(t, journal)
+		assert.Equal(t, models.ErrAmountExceedsBalance, err)
+	})
+
+	t.Run("when_bill_is_open_should_return_not_settleable", func(t *testing.T) {
+		fakeRepo := &repositorytest.FakeRepo{}
+		service := newService(t, fakeRepo, 2.5)
+		bill := &models.Bill{ID: uuid.Must(uuid.NewV4()), Status: models.BillStatusOpen}
+		require.NoError(t, fakeRepo.CreateBill(context.TODO(), bill))
+
+		journal, err := service.IssueCreditNote(context.TODO(), bill.ID, &models.IssueCreditNoteRequest{
+			Amount: decimal.NewFromInt(1), Currency: models.GEL, Reference: "CN-1", Reason: "outage",
+		})
+
+		assert.Nil(t, journal)
+		assert.Equal(t, models.ErrBillNotSettleable, err)
+	})
+
+	t.Run("when_bill_is_reopened_should_reverse_bill_closed_journal", func(t *testing.T) {
+		fakeRepo := &repositorytest.FakeRepo{}
+		service := newService(t, fakeRepo, 2.5)
+		bill := newClosedBill(t, fakeRepo)
+
+		require.NoError(t, fakeRepo.ReopenBill(context.TODO(), bill.ID))
+
+		ledger, err := service.GetBillLedger(context.TODO(), bill.ID)
+		require.NoError(t, err)
+		require.Len(t, ledger, 2)
+		assert.Equal(t, models.JournalBillReversal, ledger[1].Type)
+		assert.Equal(t, &ledger[0].ID, ledger[1].ReversesID)
+		receivable, _ := models.LedgerBalance(ledger, "1200", models.GEL)
+		assert.True(t, receivable.IsZero())
+	})
+}
+
+func TestService_CustomerProfile(t *testing.T) {
+	t.Run("when_profile_does_not_exist", func(t *testing.T) {
+		t.Run("should_return_not_found", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			service := NewService(&models.AppConfig{}, mocksCore.NewMockClient(ctrl), &repositorytest.FakeRepo{}, mocks.NewMockExchangeRatesService(ctrl))
+
+			profile, err := service.GetCustomerProfile(context.TODO(), "customer-123")
+
+			assert.Nil(t, profile)
+			assert.Equal(t, models.ErrCustomerProfileNotFound, err)
+		})
+	})
+
+	t.Run("when_profile_is_upserted", func(t *testing.T) {
+		t.Run("should_keep_creation_time_and_update_currency", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			fakeRepo := &repositorytest.FakeRepo{}
+			service := NewService(&models.AppConfig{}, mocksCore.NewMockClient(ctrl), fakeRepo, mocks.NewMockExchangeRatesService(ctrl))
+
+			created, err := service.UpsertCustomerProfile(context.TODO(), "customer-123", &models.UpsertCustomerProfileRequest{
+				PresentmentCurrency: models.USD,
+			})
+			assert.NoError(t, err)
+
+			updated, err := service.UpsertCustomerProfile(context.TODO(), "customer-123", &models.UpsertCustomerProfileRequest{
+				PresentmentCurrency: models.GEL,
+			})
+			assert.NoError(t, err)
+
+			profile, err := service.GetCustomerProfile(context.TODO(), "customer-123")
+			assert.NoError(t, err)
+			assert.Equal(t, models.GEL, profile.PresentmentCurrency)
+			assert.Equal(t, created.CreatedAt, updated.CreatedAt)
+		})
+	})
+}
+
+func TestService_Exports(t *testing.T) {
+	testCfg := &models.AppConfig{
+		Temporal: models.TemporalConfig{
+			TaskQueue: func() string { return "test-queue" },
+		},
+	}
+	from := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("should_create_pending_export_and_start_its_workflow", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		mockTemporalClient := mocksCore.NewMockClient(ctrl)
+		fakeRepo := &repositorytest.FakeRepo{}
+		service := NewService(testCfg, mockTemporalClient, fakeRepo, mocks.NewMockExchangeRatesService(ctrl))
+
+		var startedID string
+		mockTemporalClient.EXPECT().
+			ExecuteWorkflow(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, options client.StartWorkflowOptions, _ interface{}, args ...interface{}) (client.WorkflowRun, error) {
+				assert.Equal(t, "test-queue", options.TaskQueue)
+				startedID = options.ID
+				return nil, nil
+			})
+
+		job, err := service.CreateExport(context.TODO(), &models.CreateExportRequest{
+			Format: models.ExportGeneralLedgerCSV, From: from, To: to,
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, models.ExportStatusPending, job.Status)
+		assert.Equal(t, "export-"+job.ID.String(), startedID)
+		assert.Equal(t, startedID, job.WorkflowID)
+		assert.True(t, strings.HasSuffix(job.ObjectKey, ".csv"))
+
+		saved, err := service.GetExport(context.TODO(), job.ID)
+		require.NoError(t, err)
+		assert.Equal(t, job.WorkflowID, saved.WorkflowID)
+	})
+
+	t.Run("when_workflow_fails_to_start_should_fail_export", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		mockTemporalClient := mocksCore.NewMockClient(ctrl)
+		fakeRepo := &repositorytest.FakeRepo{}
+		service := NewService(testCfg, mockTemporalClient, fakeRepo, mocks.NewMockExchangeRatesService(ctrl))
+
+		var jobID uuid.UUID
+		mockTemporalClient.EXPECT().
+			ExecuteWorkflow(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ client.StartWorkflowOptions, _ interface{}, args ...interface{}) (client.WorkflowRun, error) {
+				jobID = args[0].(ExportInput).JobID
+				return nil, errors.New("temporal unavailable")
+			})
+
+		job, err := service.CreateExport(context.TODO(), &models.CreateExportRequest{
+			Format: models.ExportBillsCSV, From: from, To: to,
+		})
+
+		assert.Error(t, err)
+		assert.Nil(t, job)
+		failed, err := fakeRepo.GetExportJob(context.TODO(), jobID)
+		require.NoError(t, err)
+		assert.Equal(t, models.ExportStatusFailed, failed.Status)
+	})
+
+	t.Run("when_export_is_not_found_should_return_not_found", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		service := NewService(testCfg, mocksCore.NewMockClient(ctrl), &repositorytest.FakeRepo{}, mocks.NewMockExchangeRatesService(ctrl))
+
+		_, err := service.GetExport(context.TODO(), uuid.Must(uuid.NewV4()))
+
+		assert.Equal(t, models.ErrExportNotFound, err)
+	})
+
+	t.Run("should_only_download_completed_exports", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		fakeRepo := &repositorytest.FakeRepo{}
+		service := NewService(testCfg, mocksCore.NewMockClient(ctrl), fakeRepo, mocks.NewMockExchangeRatesService(ctrl))
+		job, err := models.NewExportJob(models.ExportTotalsCSV, from, to, time.Now())
+		require.NoError(t, err)
+		require.NoError(t, fakeRepo.CreateExportJob(context.TODO(), job))
+		require.NoError(t, fakeRepo.MarkExportJobRunning(context.TODO(), job.ID, time.Now()))
+
+		_, err = service.GetExportDownload(context.TODO(), job.ID)
+		assert.Equal(t, models.ErrExportNotReady, err)
+
+		require.NoError(t, fakeRepo.CompleteExportJob(context.TODO(), job.ID, 3, time.Now()))
+		completed, err := service.GetExportDownload(context.TODO(), job.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 3, completed.Rows)
+	})
+}
+
+func TestService_Invoices(t *testing.T) {
+	testCfg := &models.AppConfig{
+		Billing: models.BillingConfig{
+			Rounding: models.RoundingConfig{Mode: func() string { return "half_up" }, Level: func() string { return "total" }},
+			Close: models.CloseConfig{
+				Policy:      func() string { return "exact" },
+				Timezone:    func() string { return "UTC" },
+				GracePeriod: func() int { return 0 },
+			},
+			Invoice: models.InvoiceConfig{
+				Seller: models.InvoicePartyConfig{
+					Name:           func() string { return "Pave Billing LLC" },
+					RegistrationID: func() string { return "" },
+					TaxID:          func() string { return "" },
+					Street:         func() string { return "" },
+					City:           func() string { return "Tbilisi" },
+					PostalCode:     func() string { return "" },
+					CountryCode:    func() string { return "GE" },
+					EndpointID:     func() string { return "4860000000006" },
+					EndpointScheme: func() string { return "0088" },
+				},
+				TaxCategory:        func() string { return "O" },
+				TaxPercent:         func() float64 { return 0 },
+				TaxExemptionReason: func() string { return "Not subject to VAT" },
+				PaymentTermsDays:   func() int { return 30 },
+				PaymentMeansCode:   func() string { return "30" },
+				PayeeIBAN:          func() string { return "" },
+			},
+		},
+	}
+	party := &models.Party{Name: "Acme GmbH", CountryCode: "DE", EndpointID: "DE123456789", EndpointScheme: "9930"}
+	// closedAt is past midnight in Tbilisi
+	closedAt := time.Date(2025, 3, 31, 21, 0, 0, 0, time.UTC)
+	newBill := func(t *testing.T, fakeRepo *repositorytest.FakeRepo, status models.BillStatus) *models.Bill {
+		bill := &models.Bill{
+			ID:          uuid.Must(uuid.NewV4()),
+			CustomerID:  "customer-1",
+			Status:      status,
+			PeriodStart: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+			PeriodEnd:   closedAt,
+			LineItems: []*models.LineItem{{
+				Description: "API calls", Currency: models.USD,
+				Quantity: decimal.NewFromInt(2), UnitPrice: decimal.NewFromInt(5), Total: decimal.NewFromInt(10),
+			}},
+			Total: &models.Total{
+				ByCurrency: map[models.Currency]decimal.Decimal{models.USD: decimal.NewFromInt(10)},
+				GrandTotal: &models.Converted{Currency: models.USD, Amount: decimal.NewFromInt(10)},
+				ComputedAt: &closedAt,
+			},
+		}
+		if status == models.BillStatusClosed {
+			bill.ClosedAt = &closedAt
+		}
+		require.NoError(t, fakeRepo.CreateBill(context.TODO(), bill))
+		return bill
+	}
+	newService := func(t *testing.T, fakeRepo *repositorytest.FakeRepo) Service {
+		ctrl := gomock.NewController(t)
+		require.NoError(t, fakeRepo.UpsertCustomerProfile(context.TODO(), &models.CustomerProfile{
+			CustomerID: "customer-1", PresentmentCurrency: models.USD, Timezone: "Asia/Tbilisi", Party: party,
+		}))
+		return NewService(testCfg, mocksCore.NewMockClient(ctrl), fakeRepo, mocks.NewMockExchangeRatesService(ctrl))
+	}
+
+	t.Run("should_generate_invoice_dated_in_customer_timezone", func(t *testing.T) {
+		fakeRepo := &repositorytest.FakeRepo{}
+		service := newService(t, fakeRepo)
+		bill := newBill(t, fakeRepo, models.BillStatusClosed)
+
+		doc, err := service.GetBillInvoice(context.TODO(), bill.ID)
+
+		require.NoError(t, err)
+		assert.Contains(t, string(doc), "<cbc:ID>"+bill.ID.String()+"</cbc:ID>")
+		assert.Contains(t, string(doc), "<cbc:IssueDate>2025-04-01</cbc:IssueDate>")
+		assert.Contains(t, string(doc), `<cbc:PayableAmount currencyID="USD">10.00</cbc:PayableAmount>`)
+	})
+
+	t.Run("when_bill_is_open_should_return_error", func(t *testing.T) {
+		fakeRepo := &repositorytest.FakeRepo{}
+		service := newService(t, fakeRepo)
+		bill := newBill(t, fakeRepo, models.BillStatusOpen)
+
+		doc, err := service.GetBillInvoice(context.TODO(), bill.ID)
+
+		assert.Nil(t, doc)
+		assert.Equal(t, models.ErrBillNotInvoiceable, err)
+	})
+
+	t.Run("when_customer_has_no_party_should_return_error", func(t *testing.T) {
+		fakeRepo := &repositorytest.FakeRepo{}
+		service := newService(t, fakeRepo)
+		bill := newBill(t, fakeRepo, models.BillStatusClosed)
+		require.NoError(t, fakeRepo.UpsertCustomerProfile(context.TODO(), &models.CustomerProfile{
+			CustomerID: "customer-1", PresentmentCurrency: models.USD,
+		}))
+
+		doc, err := service.GetBillInvoice(context.TODO(), bill.ID)
+
+		assert.Nil(t, doc)
+		assert.Equal(t, models.ErrCustomerPartyMissing, err)
+	})
+
+	t.Run("should_generate_credit_note_of_issued_credit_note", func(t *testing.T) {
+		fakeRepo := &repositorytest.FakeRepo{}
+		service := newService(t, fakeRepo)
+		bill := newBill(t, fakeRepo, models.BillStatusClosed)
+		_, err := fakeRepo.PostBillJournals(context.TODO(), bill.ID,
+			func(models.BillStatus, []*models.Journal) ([]*models.Journal, error) {
+				return []*models.Journal{{
+					ID: uuid.Must(uuid.NewV4()), Key: "credit_note:" + bill.ID.String() + ":CN-1", Type: models.JournalCreditNote,
+					BillID: bill.ID, Reference: "CN-1", Description: "Credit note CN-1", PostedAt: closedAt.AddDate(0, 0, 5),
+					Entries: []*models.LedgerEntry{
+						{Account: "4000", Side: models.LedgerDebit, Currency: models.USD, Amount: decimal.NewFromInt(4)},
+						{Account: "1200", Side: models.LedgerCredit, Currency: models.USD, Amount: decimal.NewFromInt(4)},
+					},
+				}}, nil
+			})
+		require.NoError(t, err)
+
+		doc, err := service.GetCreditNoteDocument(context.TODO(), bill.ID, "CN-1")
+
+		require.NoError(t, err)
+		assert.Contains(t, string(doc), "<cbc:CreditNoteTypeCode>381</cbc:CreditNoteTypeCode>")
+		assert.Contains(t, string(doc), "<cbc:ID>"+bill.ID.String()+"</cbc:ID>")
+		assert.Contains(t, string(doc), `<cbc:PayableAmount currencyID="USD">4.00</cbc:PayableAmount>`)
+	})
+
+	t.Run("when_credit_note_is_not_found_should_return_error", func(t *testing.T) {
+		fakeRepo := &repositorytest.FakeRepo{}
+		service := newService(t, fakeRepo)
+		bill := newBill(t, fakeRepo, models.BillStatusClosed)
+
+		doc, err := service.GetCreditNoteDocument(context.TODO(), bill.ID, "CN-404")
+
+		assert.Nil(t, doc)
+		assert.Equal(t, models.ErrCreditNoteNotFound, err)
+	})
+}
+
+func TestService_BankStatements(t *testing.T) {
+	bankCfg := &models.AppConfig{
+		Billing: models.BillingConfig{
+			Validation: models.ValidationConfig{AllowedCurrencies: func() []string { return []string{"USD", "GEL"} }},
+			Rounding: models.RoundingConfig{
+				Mode:  func() string { return "half_up" },
+				Level: func() string { return "total" },
+			},
+			Ledger:  testLedgerConfig(),
+			Invoice: testInvoiceTaxConfig(),
+			BankStatements: models.BankStatementConfig{
+				AmountTolerancePercent: func() float64 { return 2 },
+				MinReferencePrefix:     func() int { return 8 },
+				CSV: models.BankStatementCSVConfig{
+					Delimiter:            func() string { return "," },
+					DateLayout:           func() string { return "2006-01-02" },
+					DecimalSeparator:     func() string { return "." },
+					DateColumn:           func() string { return "date" },
+					AmountColumn:         func() string { return "amount" },
+					CurrencyColumn:       func() string { return "currency" },
+					ReferenceColumn:      func() string { return "reference" },
+					CounterpartyColumn:   func() string { return "" },
+					EntryReferenceColumn: func() string { return "id" },
+				},
+			},
+		},
+	}
+	// newClosedBill closes a bill of the USD amount for customer-1
+	newClosedBill := func(t *testing.T, fakeRepo *repositorytest.FakeRepo, amount int64) *models.Bill {
+		settings, err := models.LedgerSettingsFromConfig(bankCfg)
+		require.NoError(t, err)
+		bill := &models.Bill{ID: uuid.Must(uuid.NewV4()), CustomerID: "customer-1", Status: models.BillStatusOpen}
+		require.NoError(t, fakeRepo.CreateBill(context.TODO(), bill))
+		closing := &models.Bill{ID: bill.ID, Total: &models.Total{
+			ByCurrency: map[models.Currency]decimal.Decimal{models.USD: decimal.NewFromInt(amount)},
+		}}
+		closedAt := time.Now()
+		journal, err := models.NewBillClosedJournal(closing, closedAt, &models.RatesData{Rates: map[string]float64{"USD": 1}}, settings)
+		require.NoError(t, err)
+		require.NoError(t, fakeRepo.CloseBill(context.TODO(), closing, closedAt, journal, nil))
+		return bill
+	}
+	newService := func(t *testing.T, fakeRepo *repositorytest.FakeRepo) Service {
+		ctrl := gomock.NewController(t)
+		conversionService := mocks.NewMockExchangeRatesService(ctrl)
+		conversionService.EXPECT().GetRates(gomock.Any()).Return(&models.RatesData{
+			Rates: map[string]float64{"USD": 1, "GEL": 2.5},
+		}, nil).AnyTimes()
+		return NewService(bankCfg, mocksCore.NewMockClient(ctrl), fakeRepo, conversionService)
+	}
+	statementOf := func(rows ...string) io.Reader {
+		return strings.NewReader("date,amount,currency,reference,id\n" + strings.Join(rows, "\n"))
+	}
+
+	t.Run("when_credit_references_bill_with_outstanding_amount_should_record_payment", func(t *testing.T) {
+		fakeRepo := &repositorytest.FakeRepo{}
+		service := newService(t, fakeRepo)
+		bill := newClosedBill(t, fakeRepo, 100)
+
+		result, err := service.ImportBankStatement(context.TODO(), models.StatementFormatCSV, statementOf(
+			"2025-04-01,100.00,USD,Invoice "+bill.ID.String()+",TX-1",
+			"2025-04-01,-5.00,USD,Fees,TX-2",
+		))
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, result.Imported)
+		assert.Equal(t, 1, result.Matched)
+		assert.Equal(t, 1, result.Skipped)
+		require.Len(t, result.Transactions, 1)
+		assert.Equal(t, models.BankTransactionStatusMatched, result.Transactions[0].Status)
+		assert.Equal(t, &bill.ID, result.Transactions[0].BillID)
+		ledger, err := service.GetBillLedger(context.TODO(), bill.ID)
+		require.NoError(t, err)
+		outstanding, _ := models.LedgerBalance(ledger, "1200", models.USD)
+		assert.True(t, outstanding.IsZero(), outstanding.String())
+	})
+
+	t.Run("when_statement_is_imported_again_should_count_duplicates", func(t *testing.T) {
+		fakeRepo := &repositorytest.FakeRepo{}
+		service := newService(t, fakeRepo)
+		bill := newClosedBill(t, fakeRepo, 100)
+		row := "2025-04-01,100.00,USD," + bill.ID.String() + ",TX-1"
+
+		_, err := service.ImportBankStatement(context.TODO(), models.StatementFormatCSV, statementOf(row))
+		require.NoError(t, err)
+		result, err := service.ImportBankStatement(context.TODO(), models.StatementFormatCSV, statementOf(row))
+
+		require.NoError(t, err)
+		assert.Equal(t, 0, result.Imported)
+		assert.Equal(t, 1, result.Duplicates)
+		assert.Empty(t, result.Transactions)
+	})
+
+	t.Run("when_credit_is_ambiguous_should_queue_for_review", func(t *testing.T) {
+		fakeRepo := &repositorytest.FakeRepo{}
+		service := newService(t, fakeRepo)
+		bill := newClosedBill(t, fakeRepo, 100)
+
+		result, err := service.ImportBankStatement(context.TODO(), models.StatementFormatCSV, statementOf(
+			"2025-04-01,60.00,USD,"+bill.ID.String()+",TX-1",
+			"2025-04-01,99.00,USD,monthly payment,TX-2",
+		))
+
+		require.NoError(t, err)
+		assert.Equal(t, 2, result.Review)
+		assert.Equal(t, models.MatchReasonAmountMismatch, result.Transactions[0].MatchReason)
+		assert.Equal(t, models.MatchReasonAmountOnly, result.Transactions[1].MatchReason)
+		assert.Equal(t, []uuid.UUID{bill.ID}, result.Transactions[1].CandidateBillIDs)
+		queue, err := service.ListBankTransactions(context.TODO(), models.BankTransactionFilter{
+			Status: models.BankTransactionStatusReview, Limit: 10,
+		})
+		require.NoError(t, err)
+		assert.Len(t, queue, 2)
+	})
+
+	t.Run("when_second_credit_pays_settled_bill_should_queue_for_review", func(t *testing.T) {
+		fakeRepo := &repositorytest.FakeRepo{}
+		service := newService(t, fakeRepo)
+		bill := newClosedBill(t, fakeRepo, 100)
+
+		result, err := service.ImportBankStatement(context.TODO(), models.StatementFormatCSV, statementOf(
+			"2025-04-01,100.00,USD,"+bill.ID.String()+",TX-1",
+			"2025-04-02,100.00,USD,"+bill.ID.String()+",TX-2",
+		))
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, result.Matched)
+		assert.Equal(t, models.MatchReasonNoCandidates, result.Transactions[1].MatchReason)
+	})
+
+	t.Run("when_payment_was_recorded_by_failed_import_should_match_next_credit_against_balance_left", func(t *testing.T) {
+		fakeRepo := &repositorytest.FakeRepo{}
+		service := newService(t, fakeRepo)
+		bill := newClosedBill(t, fakeRepo, 200)
+		bookedAt := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+		// The earlier import recorded the payment of TX-1 but failed before saving its transaction
+		_, err := service.RecordPayment(context.TODO(), bill.ID, &models.RecordPaymentRequest{
+			Amount:     decimal.NewFromInt(100),
+			Currency:   models.USD,
+			Reference:  (&models.BankTransaction{EntryReference: "TX-1"}).PaymentReference(),
+			ReceivedAt: &bookedAt,
+		})
+		require.NoError(t, err)
+
+		result, err := service.ImportBankStatement(context.TODO(), models.StatementFormatCSV, statementOf(
+			"2025-04-01,100.00,USD,"+bill.ID.String()+",TX-1",
+			"2025-04-02,100.00,USD,"+bill.ID.String()+",TX-2",
+		))
+
+		require.NoError(t, err)
+		// TX-1 is not paid twice, so TX-2 pays the balance left
+		assert.Equal(t, 2, result.Matched)
+		assert.Equal(t, &bill.ID, result.Transactions[1].BillID)
+		ledger, err := service.GetBillLedger(context.TODO(), bill.ID)
+		require.NoError(t, err)
+		outstanding, _ := models.LedgerBalance(ledger, "1200", models.USD)
+		assert.True(t, outstanding.IsZero(), outstanding.String())
+	})
+
+	t.Run("when_transaction_in_review_is_matched_should_record_payment", func(t *testing.T) {
+		fakeRepo := &repositorytest.FakeRepo{}
+		service := newService(t, fakeRepo)
+		bill := newClosedBill(t, fakeRepo, 100)
+		result, err := service.ImportBankStatement(context.TODO(), models.StatementFormatCSV, statementOf(
+			"2025-04-01,60.00,USD,part payment,TX-1",
+		))
+		require.NoError(t, err)
+		id := result.Transactions[0].ID
+
+		txn, err := service.MatchBankTransaction(context.TODO(), id, bill.ID)
+
+		require.NoError(t, err)
+		assert.Equal(t, models.BankTransactionStatusMatched, txn.Status)
+		assert.Equal(t, models.MatchReasonManual, txn.MatchReason)
+		assert.NotNil(t, txn.ResolvedAt)
+		ledger, err := service.GetBillLedger(context.TODO(), bill.ID)
+		require.NoError(t, err)
+		outstanding, _ := models.LedgerBalance(ledger, "1200", models.USD)
+		assert.True(t, decimal.NewFromInt(40).Equal(outstanding), outstanding.String())
+
+		_, err = service.DismissBankTransaction(context.TODO(), id, "duplicate")
+		assert.Equal(t, models.ErrBankTransactionNotPending, err)
+	})
+
+	t.Run("when_transaction_in_review_is_dismissed_should_resolve_it", func(t *testing.T) {
+		fakeRepo := &repositorytest.FakeRepo{}
+		service := newService(t, fakeRepo)
+		result, err := service.ImportBankStatement(context.TODO(), models.StatementFormatCSV, statementOf(
+			"2025-04-01,12.00,USD,refund from supplier,TX-1",
+		))
+		require.NoError(t, err)
+
+		txn, err := service.DismissBankTransaction(context.TODO(), result.Transactions[0].ID, "supplier refund")
+
+		require.NoError(t, err)
+		assert.Equal(t, models.BankTransactionStatusDismissed, txn.Status)
+		assert.Equal(t, "supplier refund", txn.ResolutionNote)
+	})
+
+	t.Run("when_transaction_is_missing_should_return_not_found", func(t *testing.T) {
+		service := newService(t, &repositorytest.FakeRepo{})
+
+		_, err := service.MatchBankTransaction(context.TODO(), uuid.Must(uuid.NewV4()), uuid.Must(uuid.NewV4()))
+
+		assert.Equal(t, models.ErrBankTransactionNotFound, err)
+	})
+}
+
+func TestService_ReceivableReports(t *testing.T) {
+	reportsCfg := &models.AppConfig{
+		Billing: models.BillingConfig{
+			Validation: models.ValidationConfig{AllowedCurrencies: func() []string { return []string{"USD", "GEL"} }},
+			Rounding: models.RoundingConfig{
+				Mode:  func() string { return "half_up" },
+				Level: func() string { return "total" },
+			},
+			Ledger: testLedgerConfig(),
+			Invoice: models.InvoiceConfig{
+				TaxPercent:       func() float64 { return 0 },
+				PaymentTermsDays: func() int { return 30 },
+			},
+		},
+	}
+	// asOf is after credit notes issued now
+	asOf := time.Now().Add(time.Hour)
+	rates := &models.RatesData{Rates: map[string]float64{"USD": 1, "GEL": 2.5}}
+	// newClosedBill closes a bill of the customer for the amount in GEL the given days before asOf
+	newClosedBill := func(t *testing.T, fakeRepo *repositorytest.FakeRepo, customerID string, amount int64, daysAgo int) *models.Bill {
+		settings, err := models.LedgerSettingsFromConfig(reportsCfg)
+		require.NoError(t, err)
+		bill := &models.Bill{ID: uuid.Must(uuid.NewV4()), CustomerID: customerID, Status: models.BillStatusOpen}
+		require.NoError(t, fakeRepo.CreateBill(context.TODO(), bill))
+		closing := &models.Bill{ID: bill.ID, Total: &models.Total{
+			ByCurrency: map[models.Currency]decimal.Decimal{models.GEL: decimal.NewFromInt(amount)},
+		}}
+		closedAt := asOf.AddDate(0, 0, -daysAgo)
+		journal, err := models.NewBillClosedJournal(closing, closedAt, rates, settings)
+		require.NoError(t, err)
+		require.NoError(t, fakeRepo.CloseBill(context.TODO(), closing, closedAt, journal, nil))
+		return bill
+	}
+	newService := func(t *testing.T, fakeRepo *repositorytest.FakeRepo) Service {
+		ctrl := gomock.NewController(t)
+		conversionService := mocks.NewMockExchangeRatesService(ctrl)
+		conversionService.EXPECT().GetRates(gomock.Any()).Return(rates, nil).AnyTimes()
+		return NewService(reportsCfg, mocksCore.NewMockClient(ctrl), fakeRepo, conversionService)
+	}
+
+	t.Run("should_age_unsettled_balances_in_functional_currency", func(t *testing.T) {
+		fakeRepo := &repositorytest.FakeRepo{}
+		service := newService(t, fakeRepo)
+		recent := newClosedBill(t, fakeRepo, "customer-1", 100, 10)
+		newClosedBill(t, fakeRepo, "customer-1", 50, 75)
+		paid := newClosedBill(t, fakeRepo, "customer-2", 20, 40)
+		receivedAt := asOf.AddDate(0, 0, -1)
+		_, err := service.RecordPayment(context.TODO(), recent.ID, &models.RecordPaymentRequest{
+			Amount: decimal.NewFromInt(40), Currency: models.GEL, Reference: "TRF-1", ReceivedAt: &receivedAt,
+		})
+		require.NoError(t, err)
+		_, err = service.RecordPayment(context.TODO(), paid.ID, &models.RecordPaymentRequest{
+			Amount: decimal.NewFromInt(20), Currency: models.GEL, Reference: "TRF-2", ReceivedAt: &receivedAt,
+		})
+		require.NoError(t, err)
+
+		report, err := service.GetAgingReport(context.TODO(), models.AgingReportFilter{AsOf: asOf})
+
+		require.NoError(t, err)
+		assert.Equal(t, models.USD, report.ReportingCurrency)
+		require.Len(t, report.Rows, 1)
+		row := report.Rows[0]
+		assert.Equal(t, "customer-1", row.CustomerID)
+		assert.True(t, decimal.NewFromInt(60).Equal(row.Amounts.Current), row.Amounts.Current.String())
+		assert.True(t, decimal.NewFromInt(50).Equal(row.Amounts.Days31To60), row.Amounts.Days31To60.String())
+		assert.True(t, decimal.NewFromInt(44).Equal(report.Totals.Total), report.Totals.Total.String())
+	})
+
+	t.Run("should_list_statement_activity_with_running_balance", func(t *testing.T) {
+		fakeRepo := &repositorytest.FakeRepo{}
+		service := newService(t, fakeRepo)
+		newClosedBill(t, fakeRepo, "customer-1", 30, 45)
+		current := newClosedBill(t, fakeRepo, "customer-1", 100, 10)
+		newClosedBill(t, fakeRepo, "customer-2", 70, 10)
+		_, err := service.IssueCreditNote(context.TODO(), current.ID, &models.IssueCreditNoteRequest{
+			Amount: decimal.NewFromInt(15), Currency: models.GEL, Reference: "CN-1", Reason: "Service outage",
+		})
+		require.NoError(t, err)
+
+		statement, err := service.GetCustomerStatement(context.TODO(), "customer-1", models.CustomerStatementFilter{
+			From: asOf.AddDate(0, 0, -30), To: asOf, ReportingCurrency: models.GEL,
+		})
+
+		require.NoError(t, err)
+		require.Len(t, statement.Sections, 1)
+		section := statement.Sections[0]
+		assert.True(t, decimal.NewFromInt(30).Equal(section.OpeningBalance), section.OpeningBalance.String())
+		require.Len(t, section.Lines, 2)
+		assert.Equal(t, current.ID, section.Lines[0].BillID)
+		assert.True(t, decimal.NewFromInt(130).Equal(section.Lines[0].Balance), section.Lines[0].Balance.String())
+		assert.Equal(t, models.ReceivableActivityCreditNote, section.Lines[1].Type)
+		assert.True(t, decimal.NewFromInt(115).Equal(section.Lines[1].Balance), section.Lines[1].Balance.String())
+		assert.True(t, decimal.NewFromInt(115).Equal(statement.ClosingBalance), statement.ClosingBalance.String())
+	})
+}
+
+func TestService_RevenueAnalytics(t *testing.T) {
+	analyticsCfg := &models.AppConfig{
+		Billing: models.BillingConfig{
+			Validation: models.ValidationConfig{AllowedCurrencies: func() []string { return []string{"USD", "GEL"} }},
+			Rounding: models.RoundingConfig{
+				Mode:  func() string { return "half_up" },
+				Level: func() string { return "total" },
+			},
+			Ledger:    testLedgerConfig(),
+			Invoice:   testInvoiceTaxConfig(),
+			Analytics: models.AnalyticsConfig{RefreshWindowMonths: func() int { return 3 }},
+		},
+	}
+	jan := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	// newClosedBill closes a bill of the customer for the period with a recurring fee and usage in GEL
+	newClosedBill := func(
+		t *testing.T, fakeRepo *repositorytest.FakeRepo, customerID string, periodStart, periodEnd time.Time, fee int64,
+	) *models.Bill {
+		bill := &models.Bill{
+			ID: uuid.Must(uuid.NewV4()), CustomerID: customerID, Status: models.BillStatusOpen,
+			PeriodStart: periodStart, PeriodEnd: periodEnd,
+		}
+		require.NoError(t, fakeRepo.CreateBill(context.TODO(), bill))
+		for _, item := range []*models.LineItem{
+			{ID: uuid.Must(uuid.NewV4()), BillID: bill.ID, Currency: models.GEL, Quantity: decimal.NewFromInt(1), UnitPrice: decimal.NewFromInt(fee), Recurring: true},
+			{ID: uuid.Must(uuid.NewV4()), BillID: bill.ID, Currency: models.GEL, Quantity: decimal.NewFromInt(5), UnitPrice: decimal.NewFromInt(2)},
+		} {
+			require.NoError(t, fakeRepo.AddLineItemToBill(context.TODO(), item))
+		}
+		closing := &models.Bill{ID: bill.ID, Total: &models.Total{
+			ByCurrency: map[models.Currency]decimal.Decimal{models.GEL: decimal.NewFromInt(fee + 10)},
+		}}
+		require.NoError(t, fakeRepo.CloseBill(context.TODO(), closing, periodEnd, nil, nil))
+		return bill
+	}
+	newService := func(t *testing.T, fakeRepo *repositorytest.FakeRepo) Service {
+		ctrl := gomock.NewController(t)
+		conversionService := mocks.NewMockExchangeRatesService(ctrl)
+		conversionService.EXPECT().GetRates(gomock.Any()).Return(&models.RatesData{
+			Rates: map[string]float64{"USD": 1, "GEL": 2.5},
+		}, nil).AnyTimes()
+		return NewService(analyticsCfg, mocksCore.NewMockClient(ctrl), fakeRepo, conversionService)
+	}
+
+	t.Run("when_rollups_are_not_refreshed_should_report_no_refresh", func(t *testing.T) {
+		service := newService(t, &repositorytest.FakeRepo{})
+
+		report, err := service.GetRevenueReport(context.TODO(), models.RevenueReportFilter{
+			From: jan, To: jan.AddDate(0, 3, 0), Granularity: models.GranularityMonth,
+		})
+
+		require.NoError(t, err)
+		assert.Nil(t, report.Refresh)
+		assert.Len(t, report.Periods, 3)
+		assert.True(t, report.Total.IsZero())
+	})
+
+	t.Run("should_report_billed_revenue_and_spread_recurring_charges_over_billing_period", func(t *testing.T) {
+		fakeRepo := &repositorytest.FakeRepo{}
+		service := newService(t, fakeRepo)
+		// A quarterly bill closing at the end of March spreads its fee over January to March
+		newClosedBill(t, fakeRepo, "customer-1", jan, jan.AddDate(0, 3, 0).Add(-time.Second), 300)
+		newClosedBill(t, fakeRepo, "customer-2", jan, jan.AddDate(0, 1, 0).Add(-time.Second), 50)
+
+		refresh, err := service.RefreshRevenueRollups(context.TODO(), jan)
+		require.NoError(t, err)
+		assert.Equal(t, jan, refresh.From)
+
+		revenue, err := service.GetRevenueReport(context.TODO(), models.RevenueReportFilter{
+			From: jan, To: jan.AddDate(0, 3, 0), Granularity: models.GranularityMonth,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, refresh, revenue.Refresh)
+		require.Len(t, revenue.Periods, 3)
+		assert.True(t, decimal.NewFromInt(60).Equal(revenue.Periods[0].ByCurrency[models.GEL]))
+		assert.True(t, decimal.NewFromInt(310).Equal(revenue.Periods[2].ByCurrency[models.GEL]))
+		assert.True(t, decimal.NewFromInt(148).Equal(revenue.Total), revenue.Total.String())
+
+		mrr, err := service.GetMRRReport(context.TODO(), models.MRRReportFilter{From: jan, To: jan.AddDate(0, 3, 0)})
+		require.NoError(t, err)
+		require.Len(t, mrr.Months, 3)
+		assert.True(t, decimal.NewFromInt(60).Equal(mrr.Months[0].MRR), mrr.Months[0].MRR.String())
+		assert.Equal(t, 2, mrr.Months[0].NewCustomers)
+		assert.True(t, decimal.NewFromInt(40).Equal(mrr.Months[1].MRR), mrr.Months[1].MRR.String())
+		assert.Equal(t, 1, mrr.Months[1].ChurnedCustomers)
+		assert.True(t, decimal.RequireFromString("0.5").Equal(mrr.Months[1].CustomerChurnRate))
+	})
+	t.Run("when_bill_closed_before_refresh_window_is_voided_should_remove_its_revenue", func(t *testing.T) {
+		fakeRepo := &repositorytest.FakeRepo{}
+		service := newService(t, fakeRepo)
+		bill := newClosedBill(t, fakeRepo, "customer-1", jan, jan.AddDate(0, 1, 0).Add(-time.Second), 50)
+		_, err := service.RefreshRevenueRollups(context.TODO(), jan)
+		require.NoError(t, err)
+		require.NoError(t, fakeRepo.VoidBill(context.TODO(), bill.ID, "duplicate", time.Now()))
+
+		// The scheduled refresh covers the trailing months only, January left the window long ago
+		refresh, err := service.RefreshRevenueRollups(context.TODO(), time.Time{})
+		require.NoError(t, err)
+		assert.Equal(t, jan, refresh.From)
+
+		revenue, err := service.GetRevenueReport(context.TODO(), models.RevenueReportFilter{
+			From: jan, To: jan.AddDate(0, 1, 0), Granularity: models.GranularityMonth,
+		})
+		require.NoError(t, err)
+		assert.True(t, revenue.Total.IsZero(), revenue.Total.String())
+	})
+}
+
+func TestService_RevenueRecognition(t *testing.T) {
+	jan := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	newService := func(t *testing.T, fakeRepo *repositorytest.FakeRepo) Service {
+		ctrl := gomock.NewController(t)
+		return NewService(&models.AppConfig{}, mocksCore.NewMockClient(ctrl), fakeRepo, mocks.NewMockExchangeRatesService(ctrl))
+	}
+	// closeBill closes a bill of January before the end of the month with a quarterly fee recognized from January to March
+	closeBill := func(t *testing.T, fakeRepo *repositorytest.FakeRepo) *models.Bill {
+		bill := &models.Bill{
+			ID: uuid.Must(uuid.NewV4()), CustomerID: "customer-1", Status: models.BillStatusOpen,
+			PeriodStart: jan, PeriodEnd: jan.AddDate(0, 1, 0),
+		}
+		require.NoError(t, fakeRepo.CreateBill(context.TODO(), bill))
+		serviceEnd := jan.AddDate(0, 3, 0)
+		bill.LineItems = []*models.LineItem{{
+			ID: uuid.Must(uuid.NewV4()), BillID: bill.ID, Currency: models.USD, Total: decimal.NewFromInt(90),
+			ServiceStart: &jan, ServiceEnd: &serviceEnd,
+		}}
+		policy := models.RoundingPolicy{Mode: models.RoundingModeHalfUp, Level: models.RoundingLevelTotal}
+		closedAt := bill.PeriodEnd.Add(-time.Second)
+		schedules, err := models.NewRecognitionSchedules(bill, closedAt, models.RecognitionMonthly, policy)
+		require.NoError(t, err)
+		closing := &models.Bill{ID: bill.ID, Total: &models.Total{
+			ByCurrency: map[models.Currency]decimal.Decimal{models.USD: decimal.NewFromInt(90)},
+		}}
+		require.NoError(t, fakeRepo.CloseBill(context.TODO(), closing, closedAt, nil, schedules))
+		return bill
+	}
+
+	t.Run("when_bill_does_not_exist_should_return_not_found", func(t *testing.T) {
+		service := newService(t, &repositorytest.FakeRepo{})
+
+		schedules, err := service.GetBillRecognitionSchedules(context.TODO(), uuid.Must(uuid.NewV4()))
+
+		assert.Nil(t, schedules)
+		assert.ErrorIs(t, err, models.ErrBillNotFound)
+	})
+
+	t.Run("should_return_schedules_of_closed_bill", func(t *testing.T) {
+		fakeRepo := &repositorytest.FakeRepo{}
+		service := newService(t, fakeRepo)
+		bill := closeBill(t, fakeRepo)
+
+		schedules, err := service.GetBillRecognitionSchedules(context.TODO(), bill.ID)
+
+		require.NoError(t, err)
+		require.Len(t, schedules, 1)
+		assert.Len(t, schedules[0].Entries, 3)
+	})
+
+	t.Run("should_roll_deferred_revenue_forward_and_schedule_revenue_still_deferred", func(t *testing.T) {
+		fakeRepo := &repositorytest.FakeRepo{}
+		service := newService(t, fakeRepo)
+		closeBill(t, fakeRepo)
+
+		waterfall, err := service.GetDeferredRevenueReport(context.TODO(), models.DeferredRevenueFilter{
+			From: jan, To: jan.AddDate(0, 2, 0),
+		})
+
+		require.NoError(t, err)
+		require.Len(t, waterfall.Months, 2)
+		january, february := waterfall.Months[0], waterfall.Months[1]
+		assert.True(t, decimal.NewFromInt(90).Equal(january.Billed))
+		assert.True(t, decimal.NewFromInt(30).Equal(january.Recognized))
+		assert.True(t, decimal.NewFromInt(60).Equal(january.Closing))
+		assert.True(t, decimal.NewFromInt(60).Equal(february.Opening))
+		assert.True(t, decimal.NewFromInt(30).Equal(february.Closing))
+		require.Len(t, waterfall.Scheduled, 1)
+		assert.Equal(t, jan.AddDate(0, 2, 0), waterfall.Scheduled[0].Month)
+		assert.True(t, decimal.NewFromInt(30).Equal(waterfall.Scheduled[0].Amount))
+	})
+}
@@ -0,0 +1,85 @@
+package core
+
+import (
+	"time"
+
+	"encore.app/billing/models"
+	"go.temporal.io/sdk/workflow"
+)
+
+// Change IDs of the workflow.GetVersion gates of BillWorkflows.CreateBill.
+//
+// Bills stay open for up to a year, so their workflows replay with code deployed long after they started.
+// Every change to the commands issued by the workflow, e.g. a new activity, timer or search attribute upsert,
+// is gated: runs recorded before the change replay with workflow.DefaultVersion and keep the previous behavior,
+// new runs record the latest version. New signal and query handlers issue no command and need no gate.
+// A gate and its DefaultVersion branch are removed once no run started before the change is still open.
+const (
+	// activateDraftBillChange activates the draft bill saved before the workflow started, instead of saving the bill
+	activateDraftBillChange = "activate-draft-bill"
+	// continueAsNewChange continues the workflow as new once enough signals were handled
+	continueAsNewChange = "continue-as-new"
+	// searchAttributesChange indexes the bill with the search attributes of its workflow
+	searchAttributesChange = "bill-search-attributes"
+	// lateUsageGraceWindowChange moves bills to closing for the late usage grace window at their close time
+	lateUsageGraceWindowChange = "late-usage-grace-window"
+	// deadLetterChange records operations exhausting their retries and completes once they are resolved
+	deadLetterChange = "dead-letter-failed-operations"
+	// throttleSearchAttributesChange upserts the customer and period end once and throttles the totals upserts
+	throttleSearchAttributesChange = "throttle-search-attributes"
+	// gracePeriodUsageChange only accepts usage that occurred within the period once the period of the bill ended
+	gracePeriodUsageChange = "grace-period-usage"
+)
+
+// billWorkflowVersions holds the gated behaviors enabled for a workflow run
+type billWorkflowVersions struct {
+	activateDraftBill    bool
+	continueAsNew        bool
+	searchAttributes     bool
+	lateUsageGraceWindow bool
+	deadLetter           bool
+	// throttleSearchAttributes carries the indexed search attributes across runs and throttles the totals upserts
+	throttleSearchAttributes bool
+	// gracePeriodUsage rejects usage of the next period sent to bills in the grace period of their close policy
+	gracePeriodUsage bool
+}
+
+// getBillWorkflowVersions resolves every gate once at the start of the run, so a run never mixes behaviors
+func getBillWorkflowVersions(ctx workflow.Context) billWorkflowVersions {
+	enabled := func(changeID string) bool {
+		return workflow.GetVersion(ctx, changeID, workflow.DefaultVersion, 1) == 1
+	}
+	return billWorkflowVersions{
+		activateDraftBill:        enabled(activateDraftBillChange),
+		continueAsNew:            enabled(continueAsNewChange),
+		searchAttributes:         enabled(searchAttributesChange),
+		lateUsageGraceWindow:     enabled(lateUsageGraceWindowChange),
+		deadLetter:               enabled(deadLetterChange),
+		throttleSearchAttributes: enabled(throttleSearchAttributesChange),
+		gracePeriodUsage:         enabled(gracePeriodUsageChange),
+	}
+}
+
+// BillWorkflowSettings are the configuration values changing the commands issued by the bill workflow.
+// They are resolved when the workflow starts and carried across runs, so changing the configuration
+// only affects new bills and does not break the replay of bills in flight.
+type BillWorkflowSettings struct {
+	ContinueAsNewSignalThreshold int           `json:"continue_as_new_signal_threshold"`
+	LateUsageGraceWindow         time.Duration `json:"late_usage_grace_window"`
+	// SearchAttributeTotalsInterval is the shortest time between two upserts of the totals, 0 upserts every change
+	SearchAttributeTotalsInterval time.Duration `json:"search_attribute_totals_interval"`
+}
+
+// newBillWorkflowSettings resolves the workflow settings of the bill from the configuration.
+// Bills under the grace policy already accept late usage until they close, the late usage window does not apply to them.
+func newBillWorkflowSettings(cfg *models.AppConfig, bill *models.Bill) *BillWorkflowSettings {
+	settings := &BillWorkflowSettings{
+		ContinueAsNewSignalThreshold:  cfg.Billing.Workflow.ContinueAsNewSignalThreshold(),
+		LateUsageGraceWindow:          lateUsageGraceWindow(cfg),
+		SearchAttributeTotalsInterval: time.Duration(cfg.Billing.Workflow.SearchAttributeTotalsInterval()) * time.Second,
+	}
+	if bill.ClosePolicy == models.ClosePolicyGrace {
+		settings.LateUsageGraceWindow = 0
+	}
+	return settings
+}
@@ -0,0 +1,118 @@
+package core
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"encore.app/billing/models"
+	"encore.dev/types/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
+)
+
+// TestBillWorkflow_Replay replays the histories recorded in testdata with the current workflow code.
+// A failure means the change would break bills in flight and needs a workflow.GetVersion gate.
+func TestBillWorkflow_Replay(t *testing.T) {
+	histories, err := filepath.Glob("testdata/bill_workflow/*.json")
+	require.NoError(t, err)
+	require.NotEmpty(t, histories)
+
+	for _, history := range histories {
+		t.Run(strings.TrimSuffix(filepath.Base(history), ".json"), func(t *testing.T) {
+			replayer := worker.NewWorkflowReplayer()
+			replayer.RegisterWorkflow(NewBillWorkflows(testCfg()).CreateBill)
+
+			assert.NoError(t, replayer.ReplayWorkflowHistoryFromJSONFile(nil, history))
+		})
+	}
+}
+
+func TestBillWorkflow_Versions(t *testing.T) {
+	t.Run("when_started_before_gates_should_save_bill_and_close_at_close_time", func(t *testing.T) {
+		s := testsuite.WorkflowTestSuite{}
+		env := s.NewTestWorkflowEnvironment()
+		cfg := testCfg()
+		cfg.Billing.Workflow.LateUsageGraceWindow = func() int { return 900 }
+		w := NewBillWorkflows(cfg)
+
+		// Runs recorded before a change replay with the default version
+		env.OnGetVersion(mock.Anything, workflow.DefaultVersion, 1).Return(workflow.DefaultVersion)
+
+		start := time.Now()
+		env.SetStartTime(start)
+		periodEnd := start.Add(time.Hour)
+		bill := &models.Bill{
+			ID:          uuid.Must(uuid.NewV4()),
+			CustomerID:  "cust-1",
+			Status:      models.BillStatusOpen,
+			PeriodStart: start,
+			PeriodEnd:   periodEnd,
+		}
+
+		env.OnActivity((&BillingActivities{}).SaveBill, mock.Anything, mock.Anything).Return(nil).Once()
+		env.OnActivity((&BillingActivities{}).AddLineItemToBill, mock.Anything, mock.Anything).Return(nil).Once()
+		env.OnActivity((&BillingActivities{}).CloseBill, mock.Anything, mock.MatchedBy(func(input CloseBillInput) bool {
+			return input.ClosedAt.Equal(periodEnd)
+		})).Return(&models.Bill{}, nil).Once()
+		env.OnUpsertTypedSearchAttributes(mock.Anything).Run(func(args mock.Arguments) {
+			t.Error("search attributes must not be upserted before the search attributes gate")
+		}).Return(nil).Maybe()
+
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(AddLineItemSignal, LineItemSignalData{LineItem: models.LineItem{
+				ID:         uuid.Must(uuid.NewV4()),
+				BillID:     bill.ID,
+				Currency:   models.USD,
+				Quantity:   decimal.NewFromInt(1),
+				UnitPrice:  decimal.NewFromInt(5),
+				OccurredAt: start,
+			}})
+		}, time.Minute)
+
+		env.ExecuteWorkflow(w.CreateBill, BillWorkflowInput{Bill: bill})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+		env.AssertExpectations(t)
+	})
+
+	t.Run("should_use_settings_of_input_over_configuration", func(t *testing.T) {
+		s := testsuite.WorkflowTestSuite{}
+		env := s.NewTestWorkflowEnvironment()
+		w := NewBillWorkflows(testCfg())
+
+		start := time.Now()
+		env.SetStartTime(start)
+		bill := &models.Bill{
+			ID:          uuid.Must(uuid.NewV4()),
+			CustomerID:  "cust-1",
+			Status:      models.BillStatusDraft,
+			PeriodStart: start,
+			PeriodEnd:   start.Add(time.Hour),
+		}
+
+		env.OnActivity((&BillingActivities{}).ActivateBill, mock.Anything, mock.Anything).
+			Return(models.BillStatusOpen, nil).Once()
+		// The configuration closes bills directly, the grace window of the input applies
+		env.OnActivity((&BillingActivities{}).MarkBillClosing, mock.Anything, mock.Anything).Return(nil).Once()
+		env.OnActivity((&BillingActivities{}).CloseBill, mock.Anything, mock.MatchedBy(func(input CloseBillInput) bool {
+			return input.ClosedAt.Equal(bill.PeriodEnd.Add(5 * time.Minute))
+		})).Return(&models.Bill{}, nil).Once()
+
+		env.ExecuteWorkflow(w.CreateBill, BillWorkflowInput{Bill: bill, Settings: &BillWorkflowSettings{
+			ContinueAsNewSignalThreshold: 1000,
+			LateUsageGraceWindow:         5 * time.Minute,
+		}})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+		env.AssertExpectations(t)
+	})
+}
@@ -1,31 +1,85 @@
 package core
 
 import (
+	"errors"
 	"time"
 
 	"encore.app/billing/models"
+	"encore.dev/types/uuid"
 	"go.temporal.io/sdk/temporal"
 	"go.temporal.io/sdk/workflow"
 )
 
 const (
-	AddLineItemSignal = "AddLineItemSignal"
+	AddLineItemSignal    = "AddLineItemSignal"
+	UpdateLineItemSignal = "UpdateLineItemSignal"
+	RemoveLineItemSignal = "RemoveLineItemSignal"
 
 	CloseBillSignal = "CloseBillSignal"
+	VoidBillSignal  = "VoidBillSignal"
 	GetBillQuery    = "GetBillQuery"
+	// GetBillSummaryQuery returns the bill without line items, with the line totals aggregated
+	GetBillSummaryQuery = "GetBillSummaryQuery"
+	// GetLineItemQuery returns a line item held by the current run, or nil when it holds none with the ID
+	GetLineItemQuery = "GetLineItemQuery"
 )
 
 // BillWorkflowInput represents the input for starting a bill workflow
 type BillWorkflowInput struct {
 	Bill *models.Bill `json:"bill"`
+	// Continued is set when the workflow continues as new from a previous run
+	Continued *ContinuedBillState `json:"continued,omitempty"`
+	// Reopened is set when the workflow is started again for a closed bill,
+	// the workflow then reopens the bill in the database instead of saving it
+	Reopened bool `json:"reopened,omitempty"`
+	// Settings are resolved from the configuration when unset, for workflows started before they were carried
+	Settings *BillWorkflowSettings `json:"settings,omitempty"`
+}
+
+// ContinuedBillState is the state carried over when the bill workflow continues as new.
+// The bill is carried without its line items, which are already persisted: only their aggregated totals are kept.
+type ContinuedBillState struct {
+	LineTotals []models.LineTotalGroup `json:"line_totals"`
+	Runs       int                     `json:"runs"`
+	// ManualClose is set when the bill was reopened after its close time, it is then only closed on request
+	ManualClose bool `json:"manual_close,omitempty"`
+	// FailedOperations are the failed operations still pending, the workflow completes once they are resolved
+	FailedOperations []*models.FailedOperation `json:"failed_operations,omitempty"`
+	// SearchAttributes were upserted by the previous runs, the bill is indexed from scratch when nil
+	SearchAttributes *IndexedSearchAttributes `json:"search_attributes,omitempty"`
 }
 
 type LineItemSignalData struct {
 	LineItem models.LineItem `json:"line_item"`
+	// Audit attributes the persisted mutation to the request that sent the signal
+	Audit *models.AuditMetadata `json:"audit,omitempty"`
+}
+
+// UpdateLineItemSignalData carries the line item before and after the update.
+// The previous version is needed when the line item was added in a previous run and is only held in the line totals.
+type UpdateLineItemSignalData struct {
+	Previous models.LineItem       `json:"previous"`
+	LineItem models.LineItem       `json:"line_item"`
+	Audit    *models.AuditMetadata `json:"audit,omitempty"`
+}
+
+// RemoveLineItemSignalData carries the removed line item, for the same reason as UpdateLineItemSignalData
+type RemoveLineItemSignalData struct {
+	LineItem    models.LineItem       `json:"line_item"`
+	Reason      string                `json:"reason"`
+	RequestedAt time.Time             `json:"requested_at"`
+	Audit       *models.AuditMetadata `json:"audit,omitempty"`
 }
 
 type CloseBillSignalData struct {
-	RequestedAt time.Time `json:"requested_at"`
+	RequestedAt time.Time             `json:"requested_at"`
+	Audit       *models.AuditMetadata `json:"audit,omitempty"`
+}
+
+type VoidBillSignalData struct {
+	Reason      string                `json:"reason"`
+	RequestedAt time.Time             `json:"requested_at"`
+	Audit       *models.AuditMetadata `json:"audit,omitempty"`
 }
 
 type BillWorkflows struct {
@@ -40,66 +94,234 @@ func (w *BillWorkflows) CreateBill(ctx workflow.Context, input BillWorkflowInput
 	logger := workflow.GetLogger(ctx)
 
 	bill := input.Bill
-	logger.Info("Starting bill workflow", "bill_id", bill.ID)
+	continued := input.Continued
+	versions := getBillWorkflowVersions(ctx)
+	settings := input.Settings
+	if settings == nil {
+		settings = newBillWorkflowSettings(w.cfg, bill)
+	}
+	graceWindow := settings.LateUsageGraceWindow
+	if !versions.lateUsageGraceWindow {
+		graceWindow = 0
+	}
+	// acceptAt is the time line items are accepted at. Runs started before the grace period check
+	// accept them as if the period of the bill had not ended.
+	acceptAt := func() time.Time {
+		if !versions.gracePeriodUsage {
+			return bill.PeriodStart
+		}
+		return workflow.Now(ctx)
+	}
 
-	// Get configuration for activity options
-	activityCtx := workflow.WithActivityOptions(ctx, getDefaultActivityOptions(w.cfg))
-	if err := workflow.ExecuteActivity(
-		activityCtx, (&BillingActivities{}).SaveBill, bill,
-	).Get(ctx, nil); err != nil {
-		return err
+	switch {
+	case input.Reopened:
+		logger.Info("Starting bill workflow for reopened bill", "bill_id", bill.ID)
+
+		// Line items of the closed bill are persisted, only their line totals are kept like for a continued run
+		var lineTotals []models.LineTotalGroup
+		activityCtx := workflow.WithActivityOptions(ctx, getDefaultActivityOptions(w.cfg))
+		if err := workflow.ExecuteActivity(
+			activityCtx, (&BillingActivities{}).ReopenBill, bill.ID,
+		).Get(ctx, &lineTotals); err != nil {
+			return err
+		}
+		continued = &ContinuedBillState{
+			LineTotals:  lineTotals,
+			ManualClose: !workflow.Now(ctx).Before(bill.CloseTime()),
+		}
+	case continued == nil && !versions.activateDraftBill:
+		logger.Info("Starting bill workflow, saving bill", "bill_id", bill.ID)
+
+		activityCtx := workflow.WithActivityOptions(ctx, getDefaultActivityOptions(w.cfg))
+		if err := workflow.ExecuteActivity(
+			activityCtx, (&BillingActivities{}).SaveBill, bill,
+		).Get(ctx, nil); err != nil {
+			return err
+		}
+		continued = &ContinuedBillState{LineTotals: []models.LineTotalGroup{}}
+	case continued == nil:
+		logger.Info("Starting bill workflow", "bill_id", bill.ID)
+
+		// The bill was saved as a draft before the workflow started
+		var status models.BillStatus
+		activityCtx := workflow.WithActivityOptions(ctx, getDefaultActivityOptions(w.cfg))
+		if err := workflow.ExecuteActivity(
+			activityCtx, (&BillingActivities{}).ActivateBill, bill.ID,
+		).Get(ctx, &status); err != nil {
+			return err
+		}
+		if status != models.BillStatusOpen {
+			logger.Warn("Bill cannot be activated, ending workflow", "bill_id", bill.ID, "status", status)
+			return nil
+		}
+		bill.Status = models.BillStatusOpen
+		continued = &ContinuedBillState{LineTotals: []models.LineTotalGroup{}}
+	default:
+		logger.Info("Continuing bill workflow as new run", "bill_id", bill.ID, "runs", continued.Runs)
+	}
+
+	persistence := newBillPersistence(w.cfg, bill.ID, versions.deadLetter, continued.FailedOperations)
+
+	// lineTotals aggregates the line items of previous runs and of the current run
+	lineTotals := func() []models.LineTotalGroup {
+		return models.MergeLineTotals(continued.LineTotals, models.GroupLineTotals(bill.LineItems))
 	}
 
 	// Signal channels
 	addLineItemCh := workflow.GetSignalChannel(ctx, AddLineItemSignal)
+	updateLineItemCh := workflow.GetSignalChannel(ctx, UpdateLineItemSignal)
+	removeLineItemCh := workflow.GetSignalChannel(ctx, RemoveLineItemSignal)
 	closeBillCh := workflow.GetSignalChannel(ctx, CloseBillSignal)
+	voidBillCh := workflow.GetSignalChannel(ctx, VoidBillSignal)
+	retryFailedOperationCh := workflow.GetSignalChannel(ctx, RetryFailedOperationSignal)
+	acknowledgeFailedOperationCh := workflow.GetSignalChannel(ctx, AcknowledgeFailedOperationSignal)
+	// Line items of previous runs are only counted, callers load them from the database
 	if err := workflow.SetQueryHandler(ctx, GetBillQuery, func() (*models.Bill, error) {
-		return bill, nil
+		current := *bill
+		current.LineItemCount = lineItemCount(continued.LineTotals) + int64(len(bill.LineItems))
+		return &current, nil
 	}); err != nil {
 		return err
 	}
-
-	// Timer until period end
-	duration := bill.PeriodEnd.Sub(workflow.Now(ctx))
-	if duration < 0 {
-		duration = 0
+	if err := workflow.SetQueryHandler(ctx, GetBillSummaryQuery, func() (*models.BillSummary, error) {
+		summary := *bill
+		summary.LineItems = nil
+		return &models.BillSummary{
+			Bill:       &summary,
+			LineTotals: lineTotals(),
+		}, nil
+	}); err != nil {
+		return err
+	}
+	if err := workflow.SetQueryHandler(ctx, GetLineItemQuery, func(id uuid.UUID) (*models.LineItem, error) {
+		return bill.FindLineItem(id), nil
+	}); err != nil {
+		return err
 	}
-	periodEndTimer := workflow.NewTimer(ctx, duration)
 
 	selector := workflow.NewSelector(ctx)
+	signals := 0
+
+	// Index the bill for visibility queries, then again whenever its status or totals change
+	indexer := &billIndexer{
+		throttled: versions.throttleSearchAttributes,
+		interval:  settings.SearchAttributeTotalsInterval,
+	}
+	if versions.throttleSearchAttributes {
+		indexer.indexed = continued.SearchAttributes
+	}
+	index := func() {
+		if versions.searchAttributes {
+			indexer.index(ctx, selector, bill, lineTotals())
+		}
+	}
+	index()
 
 	selector.AddReceive(addLineItemCh, func(c workflow.ReceiveChannel, more bool) {
 		var signal LineItemSignalData
 		c.Receive(ctx, &signal)
-		logger.Info("Received add line item signal", "line_item_id", signal.LineItem.ID)
-
-		success := bill.AddLineItem(signal.LineItem)
-
-		if success { // the bill is not closed
-			addItemCtx := workflow.WithActivityOptions(ctx, getDefaultActivityOptions(w.cfg))
-			err := workflow.ExecuteActivity(addItemCtx, (&BillingActivities{}).AddLineItemToBill, signal.LineItem).
-				Get(addItemCtx, nil)
-			if err != nil {
-				logger.Error("Failed to persist line item", "error", err)
-			}
-		} else {
-			logger.Warn("Bill is closed, ignoring line item signal")
-		}
+		signals++
+		w.addLineItem(withAudit(ctx, signal.Audit), bill, persistence, signal, acceptAt())
+	})
+
+	selector.AddReceive(updateLineItemCh, func(c workflow.ReceiveChannel, more bool) {
+		var signal UpdateLineItemSignalData
+		c.Receive(ctx, &signal)
+		signals++
+		w.updateLineItem(withAudit(ctx, signal.Audit), bill, continued, persistence, signal, acceptAt())
+	})
+
+	selector.AddReceive(removeLineItemCh, func(c workflow.ReceiveChannel, more bool) {
+		var signal RemoveLineItemSignalData
+		c.Receive(ctx, &signal)
+		signals++
+		w.removeLineItem(withAudit(ctx, signal.Audit), bill, continued, persistence, signal)
 	})
 
 	selector.AddReceive(closeBillCh, func(c workflow.ReceiveChannel, more bool) {
 		var signal CloseBillSignalData
 		c.Receive(ctx, &signal)
+		signals++
 		logger.Info("Received close bill signal, closing bill")
-		closeBill(ctx, bill, signal.RequestedAt, w.cfg)
+		closeBill(withAudit(ctx, signal.Audit), bill, signal.RequestedAt, persistence)
+	})
+
+	selector.AddReceive(voidBillCh, func(c workflow.ReceiveChannel, more bool) {
+		var signal VoidBillSignalData
+		c.Receive(ctx, &signal)
+		signals++
+		logger.Info("Received void bill signal, voiding bill", "reason", signal.Reason)
+		voidBill(withAudit(ctx, signal.Audit), bill, signal, persistence)
 	})
 
-	selector.AddFuture(periodEndTimer, func(f workflow.Future) {
-		logger.Info("Billing period ended, automatically closing bill")
-		closeBill(ctx, bill, workflow.Now(ctx), w.cfg)
+	selector.AddReceive(retryFailedOperationCh, func(c workflow.ReceiveChannel, more bool) {
+		var signal RetryFailedOperationSignalData
+		c.Receive(ctx, &signal)
+		signals++
+		persistence.retry(withAudit(ctx, signal.Audit), signal)
+	})
+
+	selector.AddReceive(acknowledgeFailedOperationCh, func(c workflow.ReceiveChannel, more bool) {
+		var signal AcknowledgeFailedOperationSignalData
+		c.Receive(ctx, &signal)
+		signals++
+		persistence.acknowledge(ctx, signal)
 	})
 
-	for !bill.IsClosed() {
+	switch {
+	case bill.Status == models.BillStatusClosing:
+		// The previous run reached the close time, only the rest of the grace window is left
+		w.awaitGraceWindow(ctx, selector, bill, persistence, graceWindow)
+	case !continued.ManualClose:
+		// Timer until the close time of the bill's close policy
+		duration := bill.CloseTime().Sub(workflow.Now(ctx))
+		if duration < 0 {
+			duration = 0
+		}
+		periodEndTimer := workflow.NewTimer(ctx, duration)
+
+		selector.AddFuture(periodEndTimer, func(f workflow.Future) {
+			w.reachCloseTime(ctx, selector, bill, persistence, graceWindow)
+		})
+	}
+
+	// The workflow ends once the bill is closed or voided
+	for bill.IsActive() {
+		selector.Select(ctx)
+		index()
+
+		if !bill.IsActive() || !versions.continueAsNew || !shouldContinueAsNew(ctx, settings, signals) {
+			continue
+		}
+
+		// Handle signals received meanwhile, they would be lost otherwise
+		for selector.HasPending() && bill.IsActive() {
+			selector.Select(ctx)
+			index()
+		}
+		if !bill.IsActive() {
+			break
+		}
+
+		next := *bill
+		next.LineItems = nil
+		logger.Info("Continuing bill workflow as new", "bill_id", bill.ID, "signals", signals)
+		return workflow.NewContinueAsNewError(ctx, w.CreateBill, BillWorkflowInput{
+			Bill: &next,
+			Continued: &ContinuedBillState{
+				LineTotals:       lineTotals(),
+				Runs:             continued.Runs + 1,
+				ManualClose:      continued.ManualClose,
+				FailedOperations: persistence.pending,
+				SearchAttributes: indexer.indexed,
+			},
+			Settings: settings,
+		})
+	}
+
+	// Operations that exhausted their retries are retried or acknowledged before the workflow completes
+	for persistence.hasPending() {
+		logger.Info("Waiting for failed operations to be resolved", "bill_id", bill.ID, "pending", len(persistence.pending))
 		selector.Select(ctx)
 	}
 
@@ -107,10 +329,161 @@ func (w *BillWorkflows) CreateBill(ctx workflow.Context, input BillWorkflowInput
 	return nil
 }
 
-// getDefaultActivityOptions returns activity options based on configuration
+// reachCloseTime closes the bill once its close time is reached, or moves it to closing
+// when a grace window for late line items is set
+func (w *BillWorkflows) reachCloseTime(
+	ctx workflow.Context, selector workflow.Selector, bill *models.Bill, persistence *billPersistence,
+	graceWindow time.Duration,
+) {
+	logger := workflow.GetLogger(ctx)
+	now := workflow.Now(ctx)
+	if graceWindow <= 0 {
+		logger.Info("Bill close time reached, automatically closing bill", "close_policy", bill.ClosePolicy)
+		closeBill(ctx, bill, now, persistence)
+		return
+	}
+
+	if err := bill.Transition(models.BillStatusClosing, now); err != nil {
+		logger.Warn("Bill is not open, ignoring close time", "status", bill.Status)
+		return
+	}
+	logger.Info("Bill close time reached, accepting late line items before closing", "close_policy", bill.ClosePolicy)
+
+	err := persistence.persist(ctx, models.FailedOperationMarkBillClosing, MarkBillClosingInput{
+		BillID:    bill.ID,
+		ClosingAt: now,
+	})
+	if err != nil {
+		logger.Error("Failed to mark bill as closing", "error", err)
+	}
+
+	w.awaitGraceWindow(ctx, selector, bill, persistence, graceWindow)
+}
+
+// awaitGraceWindow closes the closing bill when its grace window ends
+func (w *BillWorkflows) awaitGraceWindow(
+	ctx workflow.Context, selector workflow.Selector, bill *models.Bill, persistence *billPersistence,
+	graceWindow time.Duration,
+) {
+	duration := bill.ClosingAt.Add(graceWindow).Sub(workflow.Now(ctx))
+	if duration < 0 {
+		duration = 0
+	}
+	selector.AddFuture(workflow.NewTimer(ctx, duration), func(f workflow.Future) {
+		workflow.GetLogger(ctx).Info("Bill grace window ended, automatically closing bill")
+		closeBill(ctx, bill, workflow.Now(ctx), persistence)
+	})
+}
+
+// addLineItem adds the signaled line item to the bill, if the bill accepts it at now, and persists it
+func (w *BillWorkflows) addLineItem(
+	ctx workflow.Context, bill *models.Bill, persistence *billPersistence, signal LineItemSignalData, now time.Time,
+) {
+	logger := workflow.GetLogger(ctx)
+	logger.Info("Received add line item signal", "line_item_id", signal.LineItem.ID)
+
+	if err := bill.AcceptLineItem(&signal.LineItem, now); err != nil {
+		logger.Warn("Bill does not accept the line item, ignoring line item signal",
+			"status", bill.Status, "occurred_at", signal.LineItem.OccurredAt, "error", err)
+		return
+	}
+	bill.AddLineItem(signal.LineItem, now)
+
+	if err := persistence.persist(ctx, models.FailedOperationAddLineItem, signal.LineItem); err != nil {
+		logger.Error("Failed to persist line item", "error", err)
+	}
+}
+
+// updateLineItem replaces the line item in the bill and persists the update.
+// Line items added in a previous run are moved from the carried line totals into the bill.
+func (w *BillWorkflows) updateLineItem(
+	ctx workflow.Context, bill *models.Bill, continued *ContinuedBillState, persistence *billPersistence,
+	signal UpdateLineItemSignalData, now time.Time,
+) {
+	logger := workflow.GetLogger(ctx)
+	logger.Info("Received update line item signal", "line_item_id", signal.LineItem.ID)
+
+	err := bill.UpdateLineItem(signal.LineItem)
+	if errors.Is(err, models.ErrLineItemNotFound) {
+		continued.LineTotals = models.RemoveLineTotal(continued.LineTotals, &signal.Previous)
+		err = nil
+		bill.AddLineItem(signal.LineItem, now)
+	}
+	if err != nil {
+		logger.Warn("Bill is not open, ignoring update line item signal")
+		return
+	}
+
+	if err := persistence.persist(ctx, models.FailedOperationUpdateLineItem, signal.LineItem); err != nil {
+		logger.Error("Failed to persist line item update", "error", err)
+	}
+}
+
+// removeLineItem removes the line item from the bill, or from the carried line totals
+// when it was added in a previous run, and soft-deletes it
+func (w *BillWorkflows) removeLineItem(
+	ctx workflow.Context, bill *models.Bill, continued *ContinuedBillState, persistence *billPersistence,
+	signal RemoveLineItemSignalData,
+) {
+	logger := workflow.GetLogger(ctx)
+	logger.Info("Received remove line item signal", "line_item_id", signal.LineItem.ID, "reason", signal.Reason)
+
+	_, err := bill.RemoveLineItem(signal.LineItem.ID)
+	if errors.Is(err, models.ErrLineItemNotFound) {
+		continued.LineTotals = models.RemoveLineTotal(continued.LineTotals, &signal.LineItem)
+		err = nil
+	}
+	if err != nil {
+		logger.Warn("Bill is not open, ignoring remove line item signal")
+		return
+	}
+
+	err = persistence.persist(ctx, models.FailedOperationRemoveLineItem, RemoveLineItemInput{
+		BillID:     bill.ID,
+		LineItemID: signal.LineItem.ID,
+		Reason:     signal.Reason,
+		RemovedAt:  signal.RequestedAt,
+	})
+	if err != nil {
+		logger.Error("Failed to persist line item removal", "error", err)
+	}
+}
+
+// shouldContinueAsNew reports whether the run handled enough signals, or its history grew large enough,
+// to continue as new
+func shouldContinueAsNew(ctx workflow.Context, settings *BillWorkflowSettings, signals int) bool {
+	if threshold := settings.ContinueAsNewSignalThreshold; threshold > 0 && signals >= threshold {
+		return true
+	}
+	return workflow.GetInfo(ctx).GetContinueAsNewSuggested()
+}
+
+// lineItemCount returns the number of line items aggregated in the line totals
+func lineItemCount(groups []models.LineTotalGroup) int64 {
+	var count int64
+	for _, g := range groups {
+		count += g.Count
+	}
+	return count
+}
+
+// lateUsageGraceWindow returns how long bills stay closing after the close time of their close policy
+func lateUsageGraceWindow(cfg *models.AppConfig) time.Duration {
+	return time.Duration(cfg.Billing.Workflow.LateUsageGraceWindow()) * time.Second
+}
+
+// getDefaultActivityOptions returns the options of activities writing to the database
 func getDefaultActivityOptions(cfg *models.AppConfig) workflow.ActivityOptions {
+	return getActivityOptions(cfg, time.Duration(cfg.Temporal.ActivityStartToCloseTimeout())*time.Second)
+}
+
+// getActivityOptions returns activity options whose attempts time out after startToClose.
+// Failed attempts are retried with the configured policy until the schedule to close timeout,
+// unless the activity fails with a non-retryable error.
+func getActivityOptions(cfg *models.AppConfig, startToClose time.Duration) workflow.ActivityOptions {
 	return workflow.ActivityOptions{
-		StartToCloseTimeout: time.Duration(cfg.Temporal.ActivityStartToCloseTimeout()) * time.Second,
+		StartToCloseTimeout:    startToClose,
+		ScheduleToCloseTimeout: time.Duration(cfg.Temporal.ActivityScheduleToCloseTimeout()) * time.Second,
 		RetryPolicy: &temporal.RetryPolicy{
 			InitialInterval:    time.Duration(cfg.Temporal.ActivityRetryPolicy.InitialInterval()) * time.Second,
 			BackoffCoefficient: cfg.Temporal.ActivityRetryPolicy.BackoffCoefficient(),
@@ -120,20 +493,36 @@ func getDefaultActivityOptions(cfg *models.AppConfig) workflow.ActivityOptions {
 	}
 }
 
-func closeBill(ctx workflow.Context, bill *models.Bill, requestedAt time.Time, cfg *models.AppConfig) {
+func closeBill(ctx workflow.Context, bill *models.Bill, requestedAt time.Time, persistence *billPersistence) {
 	success := bill.Close(requestedAt)
 	if !success {
-		workflow.GetLogger(ctx).Warn("Bill is already closed, ignoring close bill signal")
+		workflow.GetLogger(ctx).Warn("Bill is not open or closing, ignoring close bill signal", "status", bill.Status)
 		return
 	}
 
-	activityCtx := workflow.WithActivityOptions(ctx, getDefaultActivityOptions(cfg))
-	err := workflow.ExecuteActivity(activityCtx, (&BillingActivities{}).CloseBill, CloseBillInput{
+	err := persistence.persist(ctx, models.FailedOperationCloseBill, CloseBillInput{
 		BillID:   bill.ID,
 		ClosedAt: requestedAt,
-	}).Get(ctx, nil)
+	})
 
 	if err != nil {
 		workflow.GetLogger(ctx).Error("Failed to close bill", "error", err)
 	}
 }
+
+func voidBill(ctx workflow.Context, bill *models.Bill, signal VoidBillSignalData, persistence *billPersistence) {
+	if err := bill.Void(signal.Reason, signal.RequestedAt); err != nil {
+		workflow.GetLogger(ctx).Warn("Bill cannot be voided, ignoring void bill signal", "status", bill.Status)
+		return
+	}
+
+	err := persistence.persist(ctx, models.FailedOperationVoidBill, VoidBillInput{
+		BillID:   bill.ID,
+		Reason:   signal.Reason,
+		VoidedAt: signal.RequestedAt,
+	})
+
+	if err != nil {
+		workflow.GetLogger(ctx).Error("Failed to void bill", "error", err)
+	}
+}
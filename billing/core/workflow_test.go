@@ -1,6 +1,8 @@
 package core
 
 import (
+	"database/sql"
+	"errors"
 	"testing"
 	"time"
 
@@ -9,14 +11,21 @@ import (
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/converter"
 	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
 )
 
 // helper to build a minimal AppConfig for workflow activity options
 func testCfg() *models.AppConfig {
 	return &models.AppConfig{
 		Temporal: models.TemporalConfig{
-			ActivityStartToCloseTimeout: func() int { return 60 },
+			ActivityStartToCloseTimeout:    func() int { return 60 },
+			ActivityScheduleToCloseTimeout: func() int { return 3600 },
+			CloseBillActivityTimeout:       func() int { return 120 },
+			ReconcileBatchActivityTimeout:  func() int { return 600 },
+			ExportActivityTimeout:          func() int { return 1800 },
 			ActivityRetryPolicy: models.ActivityRetryPolicy{
 				InitialInterval:    func() int { return 1 },
 				BackoffCoefficient: func() float64 { return 2.0 },
@@ -24,21 +33,33 @@ func testCfg() *models.AppConfig {
 				MaximumAttempts:    func() int { return 3 },
 			},
 		},
-		Billing: models.BillingConfig{},
+		Billing: models.BillingConfig{
+			Workflow: models.WorkflowConfig{
+				ContinueAsNewSignalThreshold:  func() int { return 1000 },
+				SearchAttributeTotalsInterval: func() int { return 0 },
+				LateUsageGraceWindow:          func() int { return 0 },
+			},
+			Reconciliation: models.ReconciliationConfig{
+				BatchSize: func() int { return 2 },
+			},
+			Export: models.ExportConfig{
+				BatchSize: func() int { return 2 },
+			},
+		},
 	}
 }
 
 func TestBillWorkflow(t *testing.T) {
-	t.Run("when_started_should_save_bill_then_wait_for_signals_or_period_end", func(t *testing.T) {
+	t.Run("when_started_should_activate_bill_then_wait_for_signals_or_period_end", func(t *testing.T) {
 		s := testsuite.WorkflowTestSuite{}
 		env := s.NewTestWorkflowEnvironment()
 
 		cfg := testCfg()
 		w := NewBillWorkflows(cfg)
 
-		// Mock activities: SaveBill, CloseBill, AddLineItemToBill
-		env.OnActivity((&BillingActivities{}).SaveBill, mock.Anything, mock.Anything).
-			Return(nil).Once()
+		// Mock activities: ActivateBill, CloseBill, AddLineItemToBill
+		env.OnActivity((&BillingActivities{}).ActivateBill, mock.Anything, mock.Anything).
+			Return(models.BillStatusOpen, nil).Once()
 
 		billID := uuid.Must(uuid.NewV4())
 		start := time.Now()
@@ -77,9 +98,9 @@ func TestBillWorkflow(t *testing.T) {
 		cfg := testCfg()
 		w := NewBillWorkflows(cfg)
 
-		// SaveBill always succeeds
-		env.OnActivity((&BillingActivities{}).SaveBill, mock.Anything, mock.Anything).
-			Return(nil).Once()
+		// ActivateBill always succeeds
+		env.OnActivity((&BillingActivities{}).ActivateBill, mock.Anything, mock.Anything).
+			Return(models.BillStatusOpen, nil).Once()
 
 		// Expect AddLineItemToBill once for the signal
 		env.OnActivity((&BillingActivities{}).AddLineItemToBill, mock.Anything, mock.Anything).
@@ -132,8 +153,8 @@ func TestBillWorkflow(t *testing.T) {
 		cfg := testCfg()
 		w := NewBillWorkflows(cfg)
 
-		env.OnActivity((&BillingActivities{}).SaveBill, mock.Anything, mock.Anything).
-			Return(nil).Once()
+		env.OnActivity((&BillingActivities{}).ActivateBill, mock.Anything, mock.Anything).
+			Return(models.BillStatusOpen, nil).Once()
 		env.OnActivity((&BillingActivities{}).CloseBill, mock.Anything, mock.Anything).
 			Return(&models.Bill{}, nil).Once()
 
@@ -166,4 +187,703 @@ func TestBillWorkflow(t *testing.T) {
 		assert.Equal(t, bill.ID, queried.ID)
 		assert.Equal(t, bill.CustomerID, queried.CustomerID)
 	})
+	t.Run("when_signal_threshold_is_reached_should_continue_as_new_with_line_totals", func(t *testing.T) {
+		s := testsuite.WorkflowTestSuite{}
+		env := s.NewTestWorkflowEnvironment()
+
+		cfg := testCfg()
+		cfg.Billing.Workflow.ContinueAsNewSignalThreshold = func() int { return 2 }
+		w := NewBillWorkflows(cfg)
+
+		env.OnActivity((&BillingActivities{}).ActivateBill, mock.Anything, mock.Anything).
+			Return(models.BillStatusOpen, nil).Once()
+		env.OnActivity((&BillingActivities{}).AddLineItemToBill, mock.Anything, mock.Anything).
+			Return(nil).Twice()
+
+		start := time.Now()
+		env.SetStartTime(start)
+
+		bill := &models.Bill{
+			ID:          uuid.Must(uuid.NewV4()),
+			CustomerID:  "cust-4",
+			Status:      models.BillStatusOpen,
+			CreatedAt:   start,
+			UpdatedAt:   start,
+			PeriodStart: start,
+			PeriodEnd:   start.Add(24 * time.Hour),
+		}
+		for i := 1; i <= 2; i++ {
+			item := models.LineItem{
+				ID:        uuid.Must(uuid.NewV4()),
+				BillID:    bill.ID,
+				Currency:  models.USD,
+				Quantity:  decimal.NewFromInt(1),
+				UnitPrice: decimal.NewFromInt(10),
+			}
+			env.RegisterDelayedCallback(func() {
+				env.SignalWorkflow(AddLineItemSignal, LineItemSignalData{LineItem: item})
+			}, time.Duration(i)*time.Minute)
+		}
+
+		env.ExecuteWorkflow(w.CreateBill, BillWorkflowInput{Bill: bill})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		var continueAsNew *workflow.ContinueAsNewError
+		assert.ErrorAs(t, env.GetWorkflowError(), &continueAsNew)
+
+		var next BillWorkflowInput
+		assert.NoError(t, converter.GetDefaultDataConverter().FromPayloads(continueAsNew.Input, &next))
+		assert.Empty(t, next.Bill.LineItems)
+		assert.Equal(t, 1, next.Continued.Runs)
+		assert.Len(t, next.Continued.LineTotals, 1)
+		assert.Equal(t, int64(2), next.Continued.LineTotals[0].Count)
+	})
+
+	t.Run("when_continued_should_not_activate_bill_and_count_previous_line_items", func(t *testing.T) {
+		s := testsuite.WorkflowTestSuite{}
+		env := s.NewTestWorkflowEnvironment()
+
+		w := NewBillWorkflows(testCfg())
+
+		// ActivateBill must not run again for a continued workflow
+		env.OnActivity((&BillingActivities{}).CloseBill, mock.Anything, mock.Anything).
+			Return(&models.Bill{}, nil).Once()
+
+		start := time.Now()
+		env.SetStartTime(start)
+
+		bill := &models.Bill{
+			ID:          uuid.Must(uuid.NewV4()),
+			CustomerID:  "cust-5",
+			Status:      models.BillStatusOpen,
+			CreatedAt:   start,
+			UpdatedAt:   start,
+			PeriodStart: start,
+			PeriodEnd:   start.Add(24 * time.Hour),
+		}
+		lineTotals := []models.LineTotalGroup{
+			{Currency: models.USD, LineAmount: decimal.NewFromInt(10), Sum: decimal.NewFromInt(30), Count: 3},
+		}
+
+		var queried models.Bill
+		var summary models.BillSummary
+		env.RegisterDelayedCallback(func() {
+			f, _ := env.QueryWorkflow(GetBillQuery)
+			_ = f.Get(&queried)
+			f, _ = env.QueryWorkflow(GetBillSummaryQuery)
+			_ = f.Get(&summary)
+			env.SignalWorkflow(CloseBillSignal, CloseBillSignalData{RequestedAt: start.Add(time.Hour)})
+		}, time.Minute)
+
+		env.ExecuteWorkflow(w.CreateBill, BillWorkflowInput{
+			Bill:      bill,
+			Continued: &ContinuedBillState{LineTotals: lineTotals, Runs: 1},
+		})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+		assert.Equal(t, int64(3), queried.LineItemCount)
+		assert.Empty(t, queried.LineItems)
+		assert.Equal(t, lineTotals[0].Count, summary.LineTotals[0].Count)
+		assert.True(t, lineTotals[0].Sum.Equal(summary.LineTotals[0].Sum))
+	})
+
+	t.Run("when_line_item_updated_and_removed_should_persist_and_adjust_bill", func(t *testing.T) {
+		s := testsuite.WorkflowTestSuite{}
+		env := s.NewTestWorkflowEnvironment()
+
+		w := NewBillWorkflows(testCfg())
+
+		env.OnActivity((&BillingActivities{}).ActivateBill, mock.Anything, mock.Anything).
+			Return(models.BillStatusOpen, nil).Once()
+		env.OnActivity((&BillingActivities{}).AddLineItemToBill, mock.Anything, mock.Anything).
+			Return(nil).Twice()
+		env.OnActivity((&BillingActivities{}).UpdateLineItem, mock.Anything, mock.Anything).
+			Return(nil).Once()
+		env.OnActivity((&BillingActivities{}).RemoveLineItem, mock.Anything, mock.Anything).
+			Return(nil).Once()
+		env.OnActivity((&BillingActivities{}).CloseBill, mock.Anything, mock.Anything).
+			Return(&models.Bill{}, nil).Once()
+
+		start := time.Now()
+		env.SetStartTime(start)
+
+		bill := &models.Bill{
+			ID:          uuid.Must(uuid.NewV4()),
+			CustomerID:  "cust-6",
+			Status:      models.BillStatusOpen,
+			CreatedAt:   start,
+			UpdatedAt:   start,
+			PeriodStart: start,
+			PeriodEnd:   start.Add(24 * time.Hour),
+		}
+		kept := models.LineItem{
+			ID:        uuid.Must(uuid.NewV4()),
+			BillID:    bill.ID,
+			Currency:  models.USD,
+			Quantity:  decimal.NewFromInt(1),
+			UnitPrice: decimal.NewFromInt(10),
+		}
+		removed := models.LineItem{
+			ID:        uuid.Must(uuid.NewV4()),
+			BillID:    bill.ID,
+			Currency:  models.USD,
+			Quantity:  decimal.NewFromInt(1),
+			UnitPrice: decimal.NewFromInt(5),
+		}
+		quantity := decimal.NewFromInt(3)
+		updated := kept.Apply(models.LineItemUpdate{Quantity: &quantity}, start)
+
+		var queried models.Bill
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(AddLineItemSignal, LineItemSignalData{LineItem: kept})
+		}, time.Minute)
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(AddLineItemSignal, LineItemSignalData{LineItem: removed})
+		}, 2*time.Minute)
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(UpdateLineItemSignal, UpdateLineItemSignalData{Previous: kept, LineItem: updated})
+		}, 3*time.Minute)
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(RemoveLineItemSignal, RemoveLineItemSignalData{LineItem: removed, Reason: "duplicate"})
+		}, 4*time.Minute)
+		env.RegisterDelayedCallback(func() {
+			f, _ := env.QueryWorkflow(GetBillQuery)
+			_ = f.Get(&queried)
+			env.SignalWorkflow(CloseBillSignal, CloseBillSignalData{RequestedAt: start.Add(time.Hour)})
+		}, 5*time.Minute)
+
+		env.ExecuteWorkflow(w.CreateBill, BillWorkflowInput{Bill: bill})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+		assert.Equal(t, int64(1), queried.LineItemCount)
+		if assert.Len(t, queried.LineItems, 1) {
+			assert.True(t, quantity.Equal(queried.LineItems[0].Quantity))
+		}
+	})
+
+	t.Run("when_line_item_of_previous_run_removed_should_adjust_carried_line_totals", func(t *testing.T) {
+		s := testsuite.WorkflowTestSuite{}
+		env := s.NewTestWorkflowEnvironment()
+
+		w := NewBillWorkflows(testCfg())
+
+		env.OnActivity((&BillingActivities{}).RemoveLineItem, mock.Anything, mock.Anything).
+			Return(nil).Once()
+		env.OnActivity((&BillingActivities{}).CloseBill, mock.Anything, mock.Anything).
+			Return(&models.Bill{}, nil).Once()
+
+		start := time.Now()
+		env.SetStartTime(start)
+
+		bill := &models.Bill{
+			ID:          uuid.Must(uuid.NewV4()),
+			CustomerID:  "cust-7",
+			Status:      models.BillStatusOpen,
+			CreatedAt:   start,
+			UpdatedAt:   start,
+			PeriodStart: start,
+			PeriodEnd:   start.Add(24 * time.Hour),
+		}
+		lineTotals := []models.LineTotalGroup{
+			{Currency: models.USD, LineAmount: decimal.NewFromInt(10), Sum: decimal.NewFromInt(30), Count: 3},
+		}
+		previous := models.LineItem{
+			ID:        uuid.Must(uuid.NewV4()),
+			BillID:    bill.ID,
+			Currency:  models.USD,
+			Quantity:  decimal.NewFromInt(1),
+			UnitPrice: decimal.NewFromInt(10),
+		}
+
+		var summary models.BillSummary
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(RemoveLineItemSignal, RemoveLineItemSignalData{LineItem: previous, Reason: "wrong customer"})
+		}, time.Minute)
+		env.RegisterDelayedCallback(func() {
+			f, _ := env.QueryWorkflow(GetBillSummaryQuery)
+			_ = f.Get(&summary)
+			env.SignalWorkflow(CloseBillSignal, CloseBillSignalData{RequestedAt: start.Add(time.Hour)})
+		}, 2*time.Minute)
+
+		env.ExecuteWorkflow(w.CreateBill, BillWorkflowInput{
+			Bill:      bill,
+			Continued: &ContinuedBillState{LineTotals: lineTotals, Runs: 1},
+		})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+		if assert.Len(t, summary.LineTotals, 1) {
+			assert.Equal(t, int64(2), summary.LineTotals[0].Count)
+			assert.True(t, decimal.NewFromInt(20).Equal(summary.LineTotals[0].Sum))
+		}
+	})
+
+	t.Run("when_void_bill_signal_received_should_void_bill_and_complete", func(t *testing.T) {
+		s := testsuite.WorkflowTestSuite{}
+		env := s.NewTestWorkflowEnvironment()
+
+		w := NewBillWorkflows(testCfg())
+
+		env.OnActivity((&BillingActivities{}).ActivateBill, mock.Anything, mock.Anything).
+			Return(models.BillStatusOpen, nil).Once()
+
+		start := time.Now()
+		env.SetStartTime(start)
+
+		bill := &models.Bill{
+			ID:          uuid.Must(uuid.NewV4()),
+			CustomerID:  "cust-8",
+			Status:      models.BillStatusOpen,
+			CreatedAt:   start,
+			UpdatedAt:   start,
+			PeriodStart: start,
+			PeriodEnd:   start.Add(24 * time.Hour),
+		}
+		voidedAt := start.Add(time.Minute)
+		env.OnActivity((&BillingActivities{}).VoidBill, mock.Anything, mock.MatchedBy(func(input VoidBillInput) bool {
+			return input.BillID == bill.ID && input.Reason == "created by mistake" && input.VoidedAt.Equal(voidedAt)
+		})).Return(nil).Once()
+
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(VoidBillSignal, VoidBillSignalData{Reason: "created by mistake", RequestedAt: voidedAt})
+		}, time.Minute)
+
+		env.ExecuteWorkflow(w.CreateBill, BillWorkflowInput{Bill: bill})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+		env.AssertExpectations(t)
+	})
+
+	t.Run("when_reopened_after_period_end_should_reopen_bill_and_wait_for_close_request", func(t *testing.T) {
+		s := testsuite.WorkflowTestSuite{}
+		env := s.NewTestWorkflowEnvironment()
+
+		w := NewBillWorkflows(testCfg())
+
+		start := time.Now()
+		env.SetStartTime(start)
+
+		bill := &models.Bill{
+			ID:          uuid.Must(uuid.NewV4()),
+			CustomerID:  "cust-9",
+			Status:      models.BillStatusOpen,
+			CreatedAt:   start.Add(-48 * time.Hour),
+			UpdatedAt:   start,
+			PeriodStart: start.Add(-48 * time.Hour),
+			PeriodEnd:   start.Add(-24 * time.Hour),
+		}
+		lineTotals := []models.LineTotalGroup{
+			{Currency: models.USD, LineAmount: decimal.NewFromInt(10), Sum: decimal.NewFromInt(20), Count: 2},
+		}
+
+		// ActivateBill must not run for a reopened bill, it is already active
+		env.OnActivity((&BillingActivities{}).ReopenBill, mock.Anything, bill.ID).
+			Return(lineTotals, nil).Once()
+		env.OnActivity((&BillingActivities{}).CloseBill, mock.Anything, mock.Anything).
+			Return(&models.Bill{}, nil).Once()
+
+		var queried models.Bill
+		env.RegisterDelayedCallback(func() {
+			f, _ := env.QueryWorkflow(GetBillQuery)
+			_ = f.Get(&queried)
+			env.SignalWorkflow(CloseBillSignal, CloseBillSignalData{RequestedAt: start.Add(2 * time.Hour)})
+		}, time.Hour)
+
+		env.ExecuteWorkflow(w.CreateBill, BillWorkflowInput{Bill: bill, Reopened: true})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+		assert.True(t, queried.IsOpen(), "bill reopened after its period ended must not close automatically")
+		assert.Equal(t, int64(2), queried.LineItemCount)
+	})
+
+	t.Run("when_draft_was_voided_before_activation_should_end_without_waiting", func(t *testing.T) {
+		s := testsuite.WorkflowTestSuite{}
+		env := s.NewTestWorkflowEnvironment()
+
+		w := NewBillWorkflows(testCfg())
+
+		env.OnActivity((&BillingActivities{}).ActivateBill, mock.Anything, mock.Anything).
+			Return(models.BillStatusVoided, nil).Once()
+
+		start := time.Now()
+		env.SetStartTime(start)
+
+		bill := &models.Bill{
+			ID:          uuid.Must(uuid.NewV4()),
+			CustomerID:  "cust-10",
+			Status:      models.BillStatusDraft,
+			CreatedAt:   start,
+			UpdatedAt:   start,
+			PeriodStart: start,
+			PeriodEnd:   start.Add(24 * time.Hour),
+		}
+
+		env.ExecuteWorkflow(w.CreateBill, BillWorkflowInput{Bill: bill})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+		assert.True(t, start.Equal(env.Now()), "workflow must not wait for the period end")
+	})
+}
+
+func TestBillWorkflow_ClosePolicy(t *testing.T) {
+	t.Run("should_close_bill_at_scheduled_close_time_and_accept_late_usage", func(t *testing.T) {
+		s := testsuite.WorkflowTestSuite{}
+		env := s.NewTestWorkflowEnvironment()
+		w := NewBillWorkflows(testCfg())
+
+		start := time.Now()
+		env.SetStartTime(start)
+		closeAt := start.Add(2 * time.Hour)
+		bill := &models.Bill{
+			ID:               uuid.Must(uuid.NewV4()),
+			CustomerID:       "cust-1",
+			Status:           models.BillStatusDraft,
+			PeriodStart:      start,
+			PeriodEnd:        start.Add(time.Hour),
+			ClosePolicy:      models.ClosePolicyGrace,
+			ScheduledCloseAt: &closeAt,
+		}
+
+		env.OnActivity((&BillingActivities{}).ActivateBill, mock.Anything, mock.Anything).
+			Return(models.BillStatusOpen, nil).Once()
+		// Usage of the period reported after the period end, within the grace period
+		env.OnActivity((&BillingActivities{}).AddLineItemToBill, mock.Anything, mock.Anything).
+			Return(nil).Once()
+		env.OnActivity((&BillingActivities{}).CloseBill, mock.Anything, mock.MatchedBy(func(input CloseBillInput) bool {
+			return input.ClosedAt.Equal(closeAt)
+		})).Return(&models.Bill{}, nil).Once()
+
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(AddLineItemSignal, LineItemSignalData{LineItem: models.LineItem{
+				ID:         uuid.Must(uuid.NewV4()),
+				BillID:     bill.ID,
+				Currency:   models.USD,
+				Quantity:   decimal.NewFromInt(1),
+				UnitPrice:  decimal.NewFromInt(5),
+				OccurredAt: start.Add(50 * time.Minute),
+			}})
+		}, 90*time.Minute)
+
+		env.ExecuteWorkflow(w.CreateBill, BillWorkflowInput{Bill: bill})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+		env.AssertExpectations(t)
+	})
+}
+
+func TestBillWorkflow_LateUsageGraceWindow(t *testing.T) {
+	newLineItem := func(billID uuid.UUID, occurredAt time.Time) models.LineItem {
+		return models.LineItem{
+			ID:         uuid.Must(uuid.NewV4()),
+			BillID:     billID,
+			Currency:   models.USD,
+			Quantity:   decimal.NewFromInt(1),
+			UnitPrice:  decimal.NewFromInt(5),
+			OccurredAt: occurredAt,
+		}
+	}
+
+	t.Run("should_accept_usage_within_period_while_closing_then_close_after_window", func(t *testing.T) {
+		s := testsuite.WorkflowTestSuite{}
+		env := s.NewTestWorkflowEnvironment()
+		cfg := testCfg()
+		cfg.Billing.Workflow.LateUsageGraceWindow = func() int { return 900 }
+		w := NewBillWorkflows(cfg)
+
+		start := time.Now()
+		env.SetStartTime(start)
+		periodEnd := start.Add(time.Hour)
+		bill := &models.Bill{
+			ID:          uuid.Must(uuid.NewV4()),
+			CustomerID:  "cust-1",
+			Status:      models.BillStatusDraft,
+			PeriodStart: start,
+			PeriodEnd:   periodEnd,
+		}
+		inPeriod := newLineItem(bill.ID, periodEnd.Add(-time.Minute))
+		afterPeriod := newLineItem(bill.ID, periodEnd.Add(time.Minute))
+
+		env.OnActivity((&BillingActivities{}).ActivateBill, mock.Anything, mock.Anything).
+			Return(models.BillStatusOpen, nil).Once()
+		env.OnActivity((&BillingActivities{}).MarkBillClosing, mock.Anything, mock.MatchedBy(func(input MarkBillClosingInput) bool {
+			return input.ClosingAt.Equal(periodEnd)
+		})).Return(nil).Once()
+		// Only the line item that occurred within the period is persisted
+		env.OnActivity((&BillingActivities{}).AddLineItemToBill, mock.Anything, mock.MatchedBy(func(item models.LineItem) bool {
+			return item.ID == inPeriod.ID
+		})).Return(nil).Once()
+		env.OnActivity((&BillingActivities{}).CloseBill, mock.Anything, mock.MatchedBy(func(input CloseBillInput) bool {
+			return input.ClosedAt.Equal(periodEnd.Add(15 * time.Minute))
+		})).Return(&models.Bill{}, nil).Once()
+
+		env.RegisterDelayedCallback(func() {
+			var queried models.Bill
+			value, err := env.QueryWorkflow(GetBillQuery)
+			assert.NoError(t, err)
+			assert.NoError(t, value.Get(&queried))
+			assert.Equal(t, models.BillStatusClosing, queried.Status)
+
+			env.SignalWorkflow(AddLineItemSignal, LineItemSignalData{LineItem: inPeriod})
+			env.SignalWorkflow(AddLineItemSignal, LineItemSignalData{LineItem: afterPeriod})
+		}, 65*time.Minute)
+
+		env.ExecuteWorkflow(w.CreateBill, BillWorkflowInput{Bill: bill})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+		env.AssertExpectations(t)
+	})
+
+	t.Run("when_continued_while_closing_should_close_at_end_of_window", func(t *testing.T) {
+		s := testsuite.WorkflowTestSuite{}
+		env := s.NewTestWorkflowEnvironment()
+		cfg := testCfg()
+		cfg.Billing.Workflow.LateUsageGraceWindow = func() int { return 900 }
+		w := NewBillWorkflows(cfg)
+
+		start := time.Now()
+		env.SetStartTime(start)
+		closingAt := start.Add(-10 * time.Minute)
+		bill := &models.Bill{
+			ID:          uuid.Must(uuid.NewV4()),
+			CustomerID:  "cust-1",
+			Status:      models.BillStatusClosing,
+			PeriodStart: closingAt.AddDate(0, -1, 0),
+			PeriodEnd:   closingAt,
+			ClosingAt:   &closingAt,
+		}
+
+		env.OnActivity((&BillingActivities{}).CloseBill, mock.Anything, mock.MatchedBy(func(input CloseBillInput) bool {
+			return input.ClosedAt.Equal(closingAt.Add(15 * time.Minute))
+		})).Return(&models.Bill{}, nil).Once()
+
+		env.ExecuteWorkflow(w.CreateBill, BillWorkflowInput{
+			Bill:      bill,
+			Continued: &ContinuedBillState{LineTotals: []models.LineTotalGroup{}, Runs: 1},
+		})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+		env.AssertExpectations(t)
+	})
+}
+
+func TestBillWorkflow_GracePolicyWithLateUsageGraceWindow(t *testing.T) {
+	t.Run("should_close_at_end_of_grace_period_without_late_usage_window", func(t *testing.T) {
+		s := testsuite.WorkflowTestSuite{}
+		env := s.NewTestWorkflowEnvironment()
+		cfg := testCfg()
+		cfg.Billing.Workflow.LateUsageGraceWindow = func() int { return 900 }
+		w := NewBillWorkflows(cfg)
+
+		start := time.Now()
+		env.SetStartTime(start)
+		periodEnd := start.Add(time.Hour)
+		closeAt := periodEnd.Add(time.Hour)
+		bill := &models.Bill{
+			ID:               uuid.Must(uuid.NewV4()),
+			CustomerID:       "cust-1",
+			Status:           models.BillStatusDraft,
+			PeriodStart:      start,
+			PeriodEnd:        periodEnd,
+			ClosePolicy:      models.ClosePolicyGrace,
+			ScheduledCloseAt: &closeAt,
+		}
+
+		env.OnActivity((&BillingActivities{}).ActivateBill, mock.Anything, mock.Anything).
+			Return(models.BillStatusOpen, nil).Once()
+		// The grace policy wins, the bill is never closing and the delays do not add up
+		env.OnActivity((&BillingActivities{}).CloseBill, mock.Anything, mock.MatchedBy(func(input CloseBillInput) bool {
+			return input.ClosedAt.Equal(closeAt)
+		})).Return(&models.Bill{}, nil).Once()
+
+		env.ExecuteWorkflow(w.CreateBill, BillWorkflowInput{Bill: bill, Settings: newBillWorkflowSettings(cfg, bill)})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+		env.AssertExpectations(t)
+	})
+}
+
+func TestBillWorkflow_GracePolicyLateUsage(t *testing.T) {
+	t.Run("after_period_end_should_only_accept_usage_within_period", func(t *testing.T) {
+		s := testsuite.WorkflowTestSuite{}
+		env := s.NewTestWorkflowEnvironment()
+		cfg := testCfg()
+		w := NewBillWorkflows(cfg)
+
+		start := time.Now()
+		env.SetStartTime(start)
+		periodEnd := start.Add(time.Hour)
+		closeAt := periodEnd.Add(time.Hour)
+		bill := &models.Bill{
+			ID:               uuid.Must(uuid.NewV4()),
+			CustomerID:       "cust-1",
+			Status:           models.BillStatusDraft,
+			PeriodStart:      start,
+			PeriodEnd:        periodEnd,
+			ClosePolicy:      models.ClosePolicyGrace,
+			ScheduledCloseAt: &closeAt,
+		}
+		newLineItem := func(occurredAt time.Time) models.LineItem {
+			return models.LineItem{
+				ID:         uuid.Must(uuid.NewV4()),
+				BillID:     bill.ID,
+				Currency:   models.USD,
+				Quantity:   decimal.NewFromInt(1),
+				UnitPrice:  decimal.NewFromInt(5),
+				OccurredAt: occurredAt,
+			}
+		}
+		inPeriod := newLineItem(periodEnd.Add(-time.Minute))
+		// Usage of the next period, sent while the bill is open for its grace period
+		nextPeriod := newLineItem(periodEnd.Add(5 * time.Minute))
+
+		env.OnActivity((&BillingActivities{}).ActivateBill, mock.Anything, mock.Anything).
+			Return(models.BillStatusOpen, nil).Once()
+		env.OnActivity((&BillingActivities{}).AddLineItemToBill, mock.Anything, mock.MatchedBy(func(item models.LineItem) bool {
+			return item.ID == inPeriod.ID
+		})).Return(nil).Once()
+		env.OnActivity((&BillingActivities{}).CloseBill, mock.Anything, mock.MatchedBy(func(input CloseBillInput) bool {
+			return input.ClosedAt.Equal(closeAt)
+		})).Return(&models.Bill{}, nil).Once()
+
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(AddLineItemSignal, LineItemSignalData{LineItem: inPeriod})
+			env.SignalWorkflow(AddLineItemSignal, LineItemSignalData{LineItem: nextPeriod})
+		}, 70*time.Minute)
+
+		env.RegisterDelayedCallback(func() {
+			var queried models.Bill
+			value, err := env.QueryWorkflow(GetBillQuery)
+			assert.NoError(t, err)
+			assert.NoError(t, value.Get(&queried))
+			assert.Equal(t, models.BillStatusOpen, queried.Status)
+			require.Len(t, queried.LineItems, 1)
+			assert.Equal(t, inPeriod.ID, queried.LineItems[0].ID)
+		}, 80*time.Minute)
+
+		env.ExecuteWorkflow(w.CreateBill, BillWorkflowInput{Bill: bill, Settings: newBillWorkflowSettings(cfg, bill)})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+		env.AssertExpectations(t)
+	})
+}
+
+func TestBillWorkflow_ActivityRetries(t *testing.T) {
+	newBill := func(start time.Time) *models.Bill {
+		return &models.Bill{
+			ID:          uuid.Must(uuid.NewV4()),
+			CustomerID:  "cust-1",
+			Status:      models.BillStatusDraft,
+			PeriodStart: start,
+			PeriodEnd:   start.Add(time.Hour),
+		}
+	}
+
+	t.Run("when_activity_fails_with_retryable_error_should_retry", func(t *testing.T) {
+		s := testsuite.WorkflowTestSuite{}
+		env := s.NewTestWorkflowEnvironment()
+		w := NewBillWorkflows(testCfg())
+
+		start := time.Now()
+		env.SetStartTime(start)
+		bill := newBill(start)
+
+		env.OnActivity((&BillingActivities{}).ActivateBill, mock.Anything, mock.Anything).
+			Return(models.BillStatusOpen, nil).Once()
+		env.OnActivity((&BillingActivities{}).CloseBill, mock.Anything, mock.Anything).
+			Return(nil, errors.New("connection reset by peer")).Once()
+		env.OnActivity((&BillingActivities{}).CloseBill, mock.Anything, mock.Anything).
+			Return(&models.Bill{}, nil).Once()
+
+		env.ExecuteWorkflow(w.CreateBill, BillWorkflowInput{Bill: bill})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+		env.AssertExpectations(t)
+	})
+
+	t.Run("when_close_bill_retries_for_hours_should_close_bill_without_dead_lettering", func(t *testing.T) {
+		s := testsuite.WorkflowTestSuite{}
+		env := s.NewTestWorkflowEnvironment()
+		// Retry policy of config.cue, bill workflows have no execution timeout cutting it short
+		cfg := testCfg()
+		cfg.Temporal.ActivityScheduleToCloseTimeout = func() int { return 604800 }
+		cfg.Temporal.ActivityRetryPolicy = models.ActivityRetryPolicy{
+			InitialInterval:    func() int { return 5 },
+			BackoffCoefficient: func() float64 { return 2.0 },
+			MaximumInterval:    func() int { return 3600 },
+			MaximumAttempts:    func() int { return 100 },
+		}
+		require.NoError(t, models.ValidateActivityRetries(cfg))
+		w := NewBillWorkflows(cfg)
+
+		start := time.Now()
+		env.SetStartTime(start)
+		bill := newBill(start)
+
+		env.OnActivity((&BillingActivities{}).ActivateBill, mock.Anything, mock.Anything).
+			Return(models.BillStatusOpen, nil).Once()
+		// 15 failed attempts back off for more than 6 hours past the close time
+		env.OnActivity((&BillingActivities{}).CloseBill, mock.Anything, mock.Anything).
+			Return(nil, errors.New("exchange rates unavailable")).Times(15)
+		var closedAt time.Time
+		env.OnActivity((&BillingActivities{}).CloseBill, mock.Anything, mock.Anything).
+			Run(func(args mock.Arguments) { closedAt = env.Now() }).
+			Return(&models.Bill{}, nil).Once()
+
+		env.ExecuteWorkflow(w.CreateBill, BillWorkflowInput{Bill: bill})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+		env.AssertExpectations(t)
+		env.AssertNotCalled(t, "RecordFailedOperation", mock.Anything, mock.Anything)
+		assert.Greater(t, closedAt.Sub(bill.PeriodEnd), 6*time.Hour)
+	})
+
+	t.Run("when_activity_fails_with_non_retryable_error_should_not_retry", func(t *testing.T) {
+		s := testsuite.WorkflowTestSuite{}
+		env := s.NewTestWorkflowEnvironment()
+		w := NewBillWorkflows(testCfg())
+
+		start := time.Now()
+		env.SetStartTime(start)
+		bill := newBill(start)
+
+		env.OnActivity((&BillingActivities{}).ActivateBill, mock.Anything, mock.Anything).
+			Return(models.BillStatusOpen, nil).Once()
+		attempts := 0
+		env.OnActivity((&BillingActivities{}).CloseBill, mock.Anything, mock.Anything).
+			Run(func(args mock.Arguments) { attempts++ }).
+			Return(nil, classifyError(sql.ErrNoRows))
+		var failedOp models.FailedOperation
+		env.OnActivity((&BillingActivities{}).RecordFailedOperation, mock.Anything, mock.Anything).
+			Run(func(args mock.Arguments) { failedOp = *args.Get(1).(*models.FailedOperation) }).
+			Return(nil).Once()
+		env.OnActivity((&BillingActivities{}).ResolveFailedOperation, mock.Anything, mock.Anything).
+			Return(nil).Once()
+
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(AcknowledgeFailedOperationSignal, AcknowledgeFailedOperationSignalData{
+				ID:          failedOp.ID,
+				Note:        "closed manually",
+				RequestedAt: start.Add(2 * time.Hour),
+			})
+		}, 2*time.Hour)
+
+		env.ExecuteWorkflow(w.CreateBill, BillWorkflowInput{Bill: bill})
+
+		assert.True(t, env.IsWorkflowCompleted())
+		assert.NoError(t, env.GetWorkflowError())
+		assert.Equal(t, 1, attempts)
+		assert.Equal(t, InvalidStateErrorType, failedOp.Attempts[0].ErrorType)
+	})
 }
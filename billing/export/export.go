@@ -0,0 +1,307 @@
+// Package export writes closed bills and ledger journals in accounting export formats
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"encore.app/billing/models"
+	"github.com/shopspring/decimal"
+)
+
+// iifDateLayout is the date layout of QuickBooks IIF files
+const iifDateLayout = "01/02/2006"
+
+var (
+	billsHeader = []string{
+		"bill_id", "customer_id", "status", "period_start", "period_end", "closed_at", "finalized_at",
+		"line_items_count", "grand_total_currency", "grand_total", "rates_updated_at",
+	}
+	lineItemsHeader = []string{
+		"bill_id", "customer_id", "line_item_id", "description", "occurred_at", "currency", "quantity", "unit_price",
+		"total", "converted_currency", "converted_rate", "converted_amount",
+	}
+	totalsHeader = []string{
+		"bill_id", "customer_id", "closed_at", "currency", "total", "converted_currency", "converted_total", "grand_total",
+	}
+	generalLedgerHeader = []string{
+		"journal_id", "journal_key", "type", "bill_id", "reverses_journal_id", "reference", "description", "posted_at",
+		"line_no", "account", "currency", "debit", "credit", "functional_currency", "functional_debit", "functional_credit",
+	}
+	iifHeader = []string{
+		"!TRNS\tTRNSID\tTRNSTYPE\tDATE\tACCNT\tAMOUNT\tDOCNUM\tMEMO",
+		"!SPL\tSPLID\tTRNSTYPE\tDATE\tACCNT\tAMOUNT\tDOCNUM\tMEMO",
+		"!ENDTRNS",
+	}
+)
+
+// BillWriter writes closed bills, loaded with their line items, in a bill export format
+type BillWriter struct {
+	format models.ExportFormat
+	csv    *csv.Writer
+	rows   int
+}
+
+// NewBillWriter writes the header of the bill export format to w
+func NewBillWriter(format models.ExportFormat, w io.Writer) (*BillWriter, error) {
+	var header []string
+	switch format {
+	case models.ExportBillsCSV:
+		header = billsHeader
+	case models.ExportLineItemsCSV:
+		header = lineItemsHeader
+	case models.ExportTotalsCSV:
+		header = totalsHeader
+	default:
+		return nil, fmt.Errorf("%s is not a bill export format", format)
+	}
+
+	bw := &BillWriter{format: format, csv: csv.NewWriter(w)}
+	if err := bw.csv.Write(header); err != nil {
+		return nil, err
+	}
+	return bw, nil
+}
+
+// WriteBill writes the rows of the bill
+func (bw *BillWriter) WriteBill(bill *models.Bill) error {
+	var records [][]string
+	switch bw.format {
+	case models.ExportBillsCSV:
+		records = [][]string{billRecord(bill)}
+	case models.ExportLineItemsCSV:
+		records = lineItemRecords(bill)
+	case models.ExportTotalsCSV:
+		records = totalRecords(bill)
+	}
+	bw.rows += len(records)
+	return bw.csv.WriteAll(records)
+}
+
+// Close flushes the export, returning the number of rows written excluding the header
+func (bw *BillWriter) Close() (int, error) {
+	bw.csv.Flush()
+	return bw.rows, bw.csv.Error()
+}
+
+func billRecord(bill *models.Bill) []string {
+	var grandTotalCurrency, grandTotal, ratesUpdatedAt string
+	if bill.Total != nil && bill.Total.GrandTotal != nil {
+		grandTotalCurrency = string(bill.Total.GrandTotal.Currency)
+		grandTotal = bill.Total.GrandTotal.Amount.String()
+		ratesUpdatedAt = formatTime(&bill.Total.GrandTotal.RateUpdatedAt)
+	}
+	return []string{
+		bill.ID.String(),
+		bill.CustomerID,
+		string(bill.Status),
+		formatTime(&bill.PeriodStart),
+		formatTime(&bill.PeriodEnd),
+		formatTime(bill.ClosedAt),
+		formatTime(bill.FinalizedAt),
+		fmt.Sprint(bill.LineItemCount),
+		grandTotalCurrency,
+		grandTotal,
+		ratesUpdatedAt,
+	}
+}
+
+func lineItemRecords(bill *models.Bill) [][]string {
+	records := make([][]string, 0, len(bill.LineItems))
+	for _, item := range bill.LineItems {
+		var convertedCurrency, convertedRate, convertedAmount string
+		if item.Converted != nil {
+			convertedCurrency = string(item.Converted.Currency)
+			convertedRate = item.Converted.Rate.String()
+			convertedAmount = item.Converted.Amount.String()
+		}
+		records = append(records, []string{
+			bill.ID.String(),
+			bill.CustomerID,
+			item.ID.String(),
+			item.Description,
+			formatTime(&item.OccurredAt),
+			string(item.Currency),
+			item.Quantity.String(),
+			item.UnitPrice.String(),
+			item.Total.String(),
+			convertedCurrency,
+			convertedRate,
+			convertedAmount,
+		})
+	}
+	return records
+}
+
+// totalRecords writes a row per currency of the bill totals, with the sum of the converted amounts of its line items
+func totalRecords(bill *models.Bill) [][]string {
+	if bill.Total == nil {
+		return nil
+	}
+
+	var convertedCurrency, grandTotal string
+	if bill.Total.GrandTotal != nil {
+		convertedCurrency = string(bill.Total.GrandTotal.Currency)
+		grandTotal = bill.Total.GrandTotal.Amount.String()
+	}
+	converted := make(map[models.Currency]decimal.Decimal)
+	for _, item := range bill.LineItems {
+		if item.Converted != nil {
+			converted[item.Currency] = converted[item.Currency].Add(item.Converted.Amount)
+		}
+	}
+
+	currencies := make([]models.Currency, 0, len(bill.Total.ByCurrency))
+	for currency := range bill.Total.ByCurrency {
+		currencies = append(currencies, currency)
+	}
+	slices.Sort(currencies)
+
+	records := make([][]string, 0, len(currencies))
+	for _, currency := range currencies {
+		var convertedTotal string
+		if amount, ok := converted[currency]; ok {
+			convertedTotal = amount.String()
+		}
+		records = append(records, []string{
+			bill.ID.String(),
+			bill.CustomerID,
+			formatTime(bill.ClosedAt),
+			string(currency),
+			bill.Total.ByCurrency[currency].String(),
+			convertedCurrency,
+			convertedTotal,
+			grandTotal,
+		})
+	}
+	return records
+}
+
+// JournalWriter writes ledger journals in a journal export format
+type JournalWriter struct {
+	format models.ExportFormat
+	w      io.Writer
+	csv    *csv.Writer
+	rows   int
+	err    error
+}
+
+// NewJournalWriter writes the header of the journal export format to w
+func NewJournalWriter(format models.ExportFormat, w io.Writer) (*JournalWriter, error) {
+	jw := &JournalWriter{format: format, w: w}
+	switch format {
+	case models.ExportGeneralLedgerCSV:
+		jw.csv = csv.NewWriter(w)
+		if err := jw.csv.Write(generalLedgerHeader); err != nil {
+			return nil, err
+		}
+	case models.ExportQuickBooksIIF:
+		jw.writeLines(iifHeader...)
+	default:
+		return nil, fmt.Errorf("%s is not a journal export format", format)
+	}
+	return jw, jw.err
+}
+
+// WriteJournal writes the journal, as a row per entry in the general ledger CSV
+// and as a general journal transaction in the QuickBooks IIF
+func (jw *JournalWriter) WriteJournal(journal *models.Journal) error {
+	if jw.format == models.ExportGeneralLedgerCSV {
+		for i, entry := range journal.Entries {
+			if err := jw.csv.Write(generalLedgerRecord(journal, i, entry)); err != nil {
+				return err
+			}
+		}
+		jw.rows += len(journal.Entries)
+		return nil
+	}
+
+	// The first entry is the transaction and the others its splits, debits are positive and credits negative
+	date := journal.PostedAt.UTC().Format(iifDateLayout)
+	docNum := iifField(journal.Reference)
+	memo := iifField(journal.Description)
+	for i, entry := range journal.Entries {
+		kind := "SPL"
+		if i == 0 {
+			kind = "TRNS"
+		}
+		amount := entry.FunctionalAmount
+		if entry.Side == models.LedgerCredit {
+			amount = amount.Neg()
+		}
+		jw.writeLines(strings.Join([]string{
+			kind, "", "GENERAL JOURNAL", date, iifField(entry.Account),
+			amount.StringFixed(entry.FunctionalCurrency.Fraction()), docNum, memo,
+		}, "\t"))
+	}
+	jw.writeLines("ENDTRNS")
+	jw.rows++
+	return jw.err
+}
+
+// Close flushes the export, returning the number of rows written excluding headers:
+// ledger entries in the general ledger CSV and transactions in the QuickBooks IIF
+func (jw *JournalWriter) Close() (int, error) {
+	if jw.csv != nil {
+		jw.csv.Flush()
+		return jw.rows, jw.csv.Error()
+	}
+	return jw.rows, jw.err
+}
+
+// writeLines writes CRLF terminated lines, keeping the first error
+func (jw *JournalWriter) writeLines(lines ...string) {
+	for _, line := range lines {
+		if jw.err != nil {
+			return
+		}
+		_, jw.err = io.WriteString(jw.w, line+"\r\n")
+	}
+}
+
+func generalLedgerRecord(journal *models.Journal, i int, entry *models.LedgerEntry) []string {
+	var reversesID string
+	if journal.ReversesID != nil {
+		reversesID = journal.ReversesID.String()
+	}
+	var debit, credit, functionalDebit, functionalCredit string
+	if entry.Side == models.LedgerDebit {
+		debit, functionalDebit = entry.Amount.String(), entry.FunctionalAmount.String()
+	} else {
+		credit, functionalCredit = entry.Amount.String(), entry.FunctionalAmount.String()
+	}
+	return []string{
+		journal.ID.String(),
+		journal.Key,
+		string(journal.Type),
+		journal.BillID.String(),
+		reversesID,
+		journal.Reference,
+		journal.Description,
+		formatTime(&journal.PostedAt),
+		fmt.Sprint(i + 1),
+		entry.Account,
+		string(entry.Currency),
+		debit,
+		credit,
+		string(entry.FunctionalCurrency),
+		functionalDebit,
+		functionalCredit,
+	}
+}
+
+// iifField replaces the tabs and line breaks separating IIF fields and rows, and the quotes QuickBooks rejects
+func iifField(value string) string {
+	return strings.NewReplacer("\t", " ", "\r", " ", "\n", " ", `"`, "'").Replace(value)
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
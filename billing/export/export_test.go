@@ -0,0 +1,193 @@
+package export
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"encore.app/billing/models"
+	"encore.dev/types/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	billID     = uuid.FromStringOrNil("6f1c1d0e-8a6b-4c39-9d1e-2b7a4f0c5e11")
+	lineItemID = uuid.FromStringOrNil("0b7e4c52-3f0d-4a8e-bf61-7c9d2e5a1f22")
+	journalID  = uuid.FromStringOrNil("c2a9e8f4-5d1b-4e7a-8f3c-9b0d6a2e4c33")
+	closedAt   = time.Date(2025, 3, 31, 23, 59, 59, 0, time.UTC)
+)
+
+func closedBill() *models.Bill {
+	return &models.Bill{
+		ID:            billID,
+		CustomerID:    "customer-1",
+		Status:        models.BillStatusClosed,
+		PeriodStart:   time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+		PeriodEnd:     closedAt,
+		ClosedAt:      &closedAt,
+		LineItemCount: 2,
+		LineItems: []*models.LineItem{
+			{
+				ID:          lineItemID,
+				Description: "API calls, tier 1",
+				Currency:    models.USD,
+				Quantity:    decimal.NewFromInt(10),
+				UnitPrice:   decimal.RequireFromString("1.5"),
+				OccurredAt:  time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC),
+				Total:       decimal.NewFromInt(15),
+				Converted: &models.LineConversion{
+					Currency: models.USD, Rate: decimal.NewFromInt(1), Amount: decimal.NewFromInt(15),
+				},
+			},
+			{
+				ID:          lineItemID,
+				Description: "Storage",
+				Currency:    models.GEL,
+				Quantity:    decimal.NewFromInt(1),
+				UnitPrice:   decimal.NewFromInt(27),
+				OccurredAt:  time.Date(2025, 3, 20, 0, 0, 0, 0, time.UTC),
+				Total:       decimal.NewFromInt(27),
+				Converted: &models.LineConversion{
+					Currency: models.USD, Rate: decimal.RequireFromString("0.37"), Amount: decimal.RequireFromString("9.99"),
+				},
+			},
+		},
+		Total: &models.Total{
+			ByCurrency: map[models.Currency]decimal.Decimal{
+				models.USD: decimal.NewFromInt(15),
+				models.GEL: decimal.NewFromInt(27),
+			},
+			GrandTotal: &models.Converted{
+				Currency: models.USD, Amount: decimal.RequireFromString("24.99"), RateUpdatedAt: closedAt,
+			},
+		},
+	}
+}
+
+func billClosedJournal() *models.Journal {
+	return &models.Journal{
+		ID:          journalID,
+		Key:         "bill_closed:" + billID.String(),
+		Type:        models.JournalBillClosed,
+		BillID:      billID,
+		Description: "Bill\tclosed\nfor customer-1",
+		PostedAt:    closedAt,
+		Entries: []*models.LedgerEntry{
+			{
+				Account: "1200", Side: models.LedgerDebit, Currency: models.GEL, Amount: decimal.NewFromInt(27),
+				FunctionalCurrency: models.USD, FunctionalAmount: decimal.RequireFromString("9.99"),
+			},
+			{
+				Account: "4000", Side: models.LedgerCredit, Currency: models.GEL, Amount: decimal.NewFromInt(27),
+				FunctionalCurrency: models.USD, FunctionalAmount: decimal.RequireFromString("9.99"),
+			},
+		},
+	}
+}
+
+func TestBillWriter(t *testing.T) {
+	t.Run("should_write_a_row_per_bill", func(t *testing.T) {
+		var buf bytes.Buffer
+		w, err := NewBillWriter(models.ExportBillsCSV, &buf)
+		require.NoError(t, err)
+		require.NoError(t, w.WriteBill(closedBill()))
+		rows, err := w.Close()
+		require.NoError(t, err)
+
+		assert.Equal(t, 1, rows)
+		assert.Equal(t, strings.Join([]string{
+			"bill_id,customer_id,status,period_start,period_end,closed_at,finalized_at,line_items_count," +
+				"grand_total_currency,grand_total,rates_updated_at",
+			billID.String() + ",customer-1,closed,2025-03-01T00:00:00Z,2025-03-31T23:59:59Z,2025-03-31T23:59:59Z,,2," +
+				"USD,24.99,2025-03-31T23:59:59Z",
+		}, "\n")+"\n", buf.String())
+	})
+
+	t.Run("should_write_line_items_with_converted_amounts", func(t *testing.T) {
+		var buf bytes.Buffer
+		w, err := NewBillWriter(models.ExportLineItemsCSV, &buf)
+		require.NoError(t, err)
+		require.NoError(t, w.WriteBill(closedBill()))
+		rows, err := w.Close()
+		require.NoError(t, err)
+
+		assert.Equal(t, 2, rows)
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		require.Len(t, lines, 3)
+		assert.Equal(t, billID.String()+",customer-1,"+lineItemID.String()+
+			",\"API calls, tier 1\",2025-03-10T00:00:00Z,USD,10,1.5,15,USD,1,15", lines[1])
+		assert.Equal(t, billID.String()+",customer-1,"+lineItemID.String()+
+			",Storage,2025-03-20T00:00:00Z,GEL,1,27,27,USD,0.37,9.99", lines[2])
+	})
+
+	t.Run("should_write_totals_per_currency_with_converted_totals", func(t *testing.T) {
+		var buf bytes.Buffer
+		w, err := NewBillWriter(models.ExportTotalsCSV, &buf)
+		require.NoError(t, err)
+		require.NoError(t, w.WriteBill(closedBill()))
+		require.NoError(t, w.WriteBill(&models.Bill{ID: billID, Status: models.BillStatusClosed, ClosedAt: &closedAt}))
+		rows, err := w.Close()
+		require.NoError(t, err)
+
+		assert.Equal(t, 2, rows)
+		assert.Equal(t, strings.Join([]string{
+			"bill_id,customer_id,closed_at,currency,total,converted_currency,converted_total,grand_total",
+			billID.String() + ",customer-1,2025-03-31T23:59:59Z,GEL,27,USD,9.99,24.99",
+			billID.String() + ",customer-1,2025-03-31T23:59:59Z,USD,15,USD,15,24.99",
+		}, "\n")+"\n", buf.String())
+	})
+
+	t.Run("should_reject_journal_formats", func(t *testing.T) {
+		_, err := NewBillWriter(models.ExportGeneralLedgerCSV, &bytes.Buffer{})
+		assert.Error(t, err)
+	})
+}
+
+func TestJournalWriter(t *testing.T) {
+	t.Run("should_write_a_general_ledger_row_per_entry", func(t *testing.T) {
+		var buf bytes.Buffer
+		w, err := NewJournalWriter(models.ExportGeneralLedgerCSV, &buf)
+		require.NoError(t, err)
+		require.NoError(t, w.WriteJournal(billClosedJournal()))
+		rows, err := w.Close()
+		require.NoError(t, err)
+
+		assert.Equal(t, 2, rows)
+		assert.True(t, strings.HasPrefix(buf.String(),
+			"journal_id,journal_key,type,bill_id,reverses_journal_id,reference,description,posted_at,"+
+				"line_no,account,currency,debit,credit,functional_currency,functional_debit,functional_credit\n"))
+		prefix := journalID.String() + ",bill_closed:" + billID.String() + ",bill_closed," + billID.String() +
+			",,,\"Bill\tclosed\nfor customer-1\",2025-03-31T23:59:59Z,"
+		assert.Contains(t, buf.String(), prefix+"1,1200,GEL,27,,USD,9.99,\n")
+		assert.Contains(t, buf.String(), prefix+"2,4000,GEL,,27,USD,,9.99\n")
+	})
+
+	t.Run("should_write_quickbooks_general_journal_transactions", func(t *testing.T) {
+		var buf bytes.Buffer
+		w, err := NewJournalWriter(models.ExportQuickBooksIIF, &buf)
+		require.NoError(t, err)
+		journal := billClosedJournal()
+		journal.Reference = "INV-1"
+		require.NoError(t, w.WriteJournal(journal))
+		rows, err := w.Close()
+		require.NoError(t, err)
+
+		assert.Equal(t, 1, rows)
+		assert.Equal(t, strings.Join([]string{
+			"!TRNS\tTRNSID\tTRNSTYPE\tDATE\tACCNT\tAMOUNT\tDOCNUM\tMEMO",
+			"!SPL\tSPLID\tTRNSTYPE\tDATE\tACCNT\tAMOUNT\tDOCNUM\tMEMO",
+			"!ENDTRNS",
+			"TRNS\t\tGENERAL JOURNAL\t03/31/2025\t1200\t9.99\tINV-1\tBill closed for customer-1",
+			"SPL\t\tGENERAL JOURNAL\t03/31/2025\t4000\t-9.99\tINV-1\tBill closed for customer-1",
+			"ENDTRNS",
+		}, "\r\n")+"\r\n", buf.String())
+	})
+
+	t.Run("should_reject_bill_formats", func(t *testing.T) {
+		_, err := NewJournalWriter(models.ExportBillsCSV, &bytes.Buffer{})
+		assert.Error(t, err)
+	})
+}
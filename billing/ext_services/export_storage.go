@@ -0,0 +1,70 @@
+package ext_services
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"encore.dev/rlog"
+	"encore.dev/storage/objects"
+)
+
+// ExportBucket is the permissions of the bucket storing exports, to reference it with objects.BucketRef
+type ExportBucket interface {
+	objects.Uploader
+	objects.Downloader
+}
+
+//go:generate mockgen -package=mocks -destination=mocks/export_storage_mock.go . ExportStorage
+type ExportStorage interface {
+	// Upload stores the content written by write under the key, discarding it when write fails
+	Upload(ctx context.Context, key string, contentType string, write func(w io.Writer) error) error
+	// Download opens the object stored under the key, returning objects.ErrObjectNotFound when not found
+	Download(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+type exportStorage struct {
+	bucket ExportBucket
+}
+
+func NewExportStorage(bucket ExportBucket) ExportStorage {
+	log := rlog.With("module", "export_storage")
+	log.Info("export storage initialized", "bucket_available", bucket != nil)
+
+	return &exportStorage{bucket: bucket}
+}
+
+func (s *exportStorage) Upload(ctx context.Context, key string, contentType string, write func(w io.Writer) error) error {
+	log := rlog.With("module", "export_storage").With("key", key)
+	log.Info("uploading export", "content_type", contentType)
+
+	w := s.bucket.Upload(ctx, key, objects.WithUploadAttrs(objects.UploadAttrs{ContentType: contentType}))
+	if err := write(w); err != nil {
+		log.Error("failed to write export, aborting upload", "error", err)
+		w.Abort(err)
+		return err
+	}
+	if err := w.Close(); err != nil {
+		log.Error("failed to upload export", "error", err)
+		return err
+	}
+
+	log.Info("export uploaded successfully")
+	return nil
+}
+
+func (s *exportStorage) Download(ctx context.Context, key string) (io.ReadCloser, error) {
+	log := rlog.With("module", "export_storage").With("key", key)
+	log.Info("downloading export")
+
+	r := s.bucket.Download(ctx, key)
+	if err := r.Err(); err != nil {
+		if errors.Is(err, objects.ErrObjectNotFound) {
+			log.Warn("export not found in bucket")
+		} else {
+			log.Error("failed to download export", "error", err)
+		}
+		return nil, err
+	}
+	return r, nil
+}
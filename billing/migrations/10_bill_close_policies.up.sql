@@ -0,0 +1,10 @@
+-- Close policies: bills close at a time derived from the end of their period, the customer timezone and a grace period.
+-- Bills created before have no scheduled close time and close at the end of their period.
+ALTER TABLE customer_profiles
+    ADD COLUMN timezone VARCHAR(64) NULL,
+    ADD COLUMN close_policy VARCHAR(16) NULL CHECK (close_policy IN ('exact', 'end_of_day', 'grace'));
+
+ALTER TABLE bills
+    ADD COLUMN close_policy VARCHAR(16) NULL CHECK (close_policy IN ('exact', 'end_of_day', 'grace')),
+    ADD COLUMN scheduled_close_at TIMESTAMPTZ NULL,
+    ADD CONSTRAINT bills_scheduled_close_at_check CHECK (scheduled_close_at IS NULL OR scheduled_close_at >= period_end);
@@ -0,0 +1,18 @@
+-- Closing state: after its close time a bill waits for the grace window in 'closing', accepting only line items
+-- whose usage occurred within the period, before it is closed. Line items record when their usage occurred,
+-- which is the creation time for line items created before.
+ALTER TABLE line_items ADD COLUMN occurred_at TIMESTAMPTZ NULL;
+UPDATE line_items SET occurred_at = created_at;
+ALTER TABLE line_items ALTER COLUMN occurred_at SET NOT NULL;
+
+ALTER TABLE bills
+    DROP CONSTRAINT bills_status_check,
+    DROP CONSTRAINT bills_closed_at_check,
+    ADD COLUMN closing_at TIMESTAMPTZ NULL,
+    ADD CONSTRAINT bills_status_check CHECK (status IN ('draft', 'open', 'closing', 'closed', 'finalized', 'voided')),
+    ADD CONSTRAINT bills_closed_at_check CHECK (
+        (status IN ('draft', 'open', 'closing') AND closed_at IS NULL) OR
+        (status IN ('closed', 'finalized') AND closed_at IS NOT NULL) OR
+        status = 'voided'
+    ),
+    ADD CONSTRAINT bills_closing_at_check CHECK (status <> 'closing' OR closing_at IS NOT NULL);
@@ -0,0 +1,20 @@
+-- Persistence operations of bill workflows whose activity exhausted its retries.
+-- Pending operations keep their bill workflow running until retried successfully or acknowledged.
+CREATE TABLE failed_operations (
+    id UUID PRIMARY KEY,
+    bill_id UUID NOT NULL REFERENCES bills(id) ON DELETE CASCADE,
+    workflow_id VARCHAR(255) NOT NULL,
+    operation VARCHAR(32) NOT NULL
+        CHECK (operation IN ('add_line_item', 'update_line_item', 'remove_line_item', 'mark_bill_closing', 'close_bill', 'void_bill')),
+    payload JSONB NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'resolved', 'acknowledged')),
+    attempts JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    resolved_at TIMESTAMPTZ,
+    resolution_note TEXT,
+    CHECK ((status = 'pending') = (resolved_at IS NULL))
+);
+
+CREATE INDEX idx_failed_operations_status_created_at ON failed_operations(status, created_at DESC);
+CREATE INDEX idx_failed_operations_bill_id ON failed_operations(bill_id);
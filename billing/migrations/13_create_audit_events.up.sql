@@ -0,0 +1,36 @@
+-- Append-only log of bill and line item mutations, written in the transaction of the mutation.
+-- Events of a bill are hash-chained by sequence, before and after are JSON rather than JSONB
+-- so the stored documents are returned as hashed.
+CREATE TABLE audit_events (
+    id UUID PRIMARY KEY,
+    bill_id UUID NOT NULL REFERENCES bills(id),
+    sequence BIGINT NOT NULL CHECK (sequence > 0),
+    entity_type VARCHAR(16) NOT NULL CHECK (entity_type IN ('bill', 'line_item')),
+    entity_id UUID NOT NULL,
+    action VARCHAR(32) NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    request_id VARCHAR(255) NOT NULL DEFAULT '',
+    workflow_id VARCHAR(255) NOT NULL DEFAULT '',
+    run_id VARCHAR(255) NOT NULL DEFAULT '',
+    before JSON,
+    after JSON NOT NULL,
+    occurred_at TIMESTAMPTZ NOT NULL,
+    prev_hash VARCHAR(64) NOT NULL DEFAULT '',
+    hash VARCHAR(64) NOT NULL,
+    UNIQUE (bill_id, sequence)
+);
+
+-- Events are never changed once written
+CREATE FUNCTION reject_audit_event_change() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_append_only
+    BEFORE UPDATE OR DELETE ON audit_events
+    FOR EACH ROW EXECUTE FUNCTION reject_audit_event_change();
+
+CREATE TRIGGER audit_events_no_truncate
+    BEFORE TRUNCATE ON audit_events
+    FOR EACH STATEMENT EXECUTE FUNCTION reject_audit_event_change();
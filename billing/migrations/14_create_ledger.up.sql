@@ -0,0 +1,66 @@
+-- General ledger postings of billing events. A journal groups the entries of one event, identified by its key,
+-- and is posted once. Amounts are positive, in the transaction currency and valued in the functional currency.
+CREATE TABLE ledger_journals (
+    id UUID PRIMARY KEY,
+    key VARCHAR(512) NOT NULL UNIQUE,
+    type VARCHAR(32) NOT NULL
+        CHECK (type IN ('bill_closed', 'bill_reversal', 'payment', 'credit_note', 'fx_difference')),
+    bill_id UUID NOT NULL REFERENCES bills(id),
+    reverses_journal_id UUID UNIQUE REFERENCES ledger_journals(id),
+    reference VARCHAR(255) NOT NULL DEFAULT '',
+    description TEXT NOT NULL,
+    posted_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_ledger_journals_bill_id ON ledger_journals(bill_id);
+CREATE INDEX idx_ledger_journals_posted_at ON ledger_journals(posted_at);
+
+CREATE TABLE ledger_entries (
+    journal_id UUID NOT NULL REFERENCES ledger_journals(id),
+    line_no INT NOT NULL,
+    account VARCHAR(64) NOT NULL,
+    side VARCHAR(6) NOT NULL CHECK (side IN ('debit', 'credit')),
+    currency VARCHAR(3) NOT NULL,
+    amount NUMERIC(20, 8) NOT NULL CHECK (amount >= 0),
+    functional_currency VARCHAR(3) NOT NULL,
+    functional_amount NUMERIC(20, 8) NOT NULL CHECK (functional_amount >= 0),
+    PRIMARY KEY (journal_id, line_no)
+);
+
+CREATE INDEX idx_ledger_entries_account_currency ON ledger_entries(account, currency);
+
+-- Journals and entries are never changed once posted, postings are corrected by reversal
+CREATE FUNCTION reject_ledger_change() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION '% is append-only', TG_TABLE_NAME;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER ledger_journals_append_only
+    BEFORE UPDATE OR DELETE ON ledger_journals
+    FOR EACH ROW EXECUTE FUNCTION reject_ledger_change();
+
+CREATE TRIGGER ledger_entries_append_only
+    BEFORE UPDATE OR DELETE ON ledger_entries
+    FOR EACH ROW EXECUTE FUNCTION reject_ledger_change();
+
+-- Debits equal credits in the functional currency for every journal, checked once its entries are inserted
+CREATE FUNCTION check_ledger_journal_balanced() RETURNS trigger AS $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM ledger_entries
+        WHERE journal_id = NEW.journal_id
+        GROUP BY journal_id
+        HAVING SUM(CASE WHEN side = 'debit' THEN functional_amount ELSE -functional_amount END) <> 0
+    ) THEN
+        RAISE EXCEPTION 'ledger journal % is unbalanced', NEW.journal_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE CONSTRAINT TRIGGER ledger_entries_balanced
+    AFTER INSERT ON ledger_entries
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE FUNCTION check_ledger_journal_balanced();
@@ -0,0 +1,24 @@
+-- Accounting exports of the bills closed, or ledger journals posted, in a date range.
+-- The exported file is written to the exports bucket under object_key by the export workflow.
+CREATE TABLE export_jobs (
+    id UUID PRIMARY KEY,
+    format VARCHAR(32) NOT NULL
+        CHECK (format IN ('bills_csv', 'line_items_csv', 'totals_csv', 'gl_csv', 'quickbooks_iif')),
+    range_from TIMESTAMPTZ NOT NULL,
+    range_to TIMESTAMPTZ NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'completed', 'failed')),
+    workflow_id VARCHAR(255) NOT NULL,
+    object_key VARCHAR(512) NOT NULL,
+    row_count INTEGER NOT NULL DEFAULT 0,
+    error TEXT,
+    created_at TIMESTAMPTZ NOT NULL,
+    started_at TIMESTAMPTZ,
+    completed_at TIMESTAMPTZ,
+    CHECK (range_from < range_to),
+    CHECK ((status IN ('completed', 'failed')) = (completed_at IS NOT NULL))
+);
+
+CREATE INDEX idx_export_jobs_created_at ON export_jobs(created_at DESC);
+
+-- Exports list the bills closed in their range
+CREATE INDEX idx_bills_closed_at ON bills(closed_at) WHERE closed_at IS NOT NULL;
@@ -0,0 +1,2 @@
+-- Legal identity and Peppol electronic address of the customer, the buyer of its UBL e-invoices
+ALTER TABLE customer_profiles ADD COLUMN party JSONB NULL;
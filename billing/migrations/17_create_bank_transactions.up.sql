@@ -0,0 +1,24 @@
+-- Credits imported from bank statements and their matching to bill payments.
+-- A credit is imported once per entry reference. Credits in review wait until matched to a bill or dismissed.
+CREATE TABLE bank_transactions (
+    id UUID PRIMARY KEY,
+    entry_reference VARCHAR(200) NOT NULL UNIQUE,
+    booked_at TIMESTAMPTZ NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    amount NUMERIC(20, 8) NOT NULL CHECK (amount > 0),
+    reference TEXT NOT NULL DEFAULT '',
+    counterparty TEXT NOT NULL DEFAULT '',
+    status VARCHAR(16) NOT NULL DEFAULT 'review' CHECK (status IN ('matched', 'review', 'dismissed')),
+    bill_id UUID REFERENCES bills(id),
+    candidate_bill_ids JSONB NOT NULL DEFAULT '[]',
+    match_reason VARCHAR(32) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    resolved_at TIMESTAMPTZ,
+    resolution_note TEXT,
+    CHECK ((status = 'matched') = (bill_id IS NOT NULL)),
+    CHECK ((status = 'review') = (resolved_at IS NULL))
+);
+
+CREATE INDEX idx_bank_transactions_status_booked_at ON bank_transactions(status, booked_at DESC);
+CREATE INDEX idx_bank_transactions_bill_id ON bank_transactions(bill_id);
@@ -0,0 +1,3 @@
+-- Receivable reports read the closed and finalized bills of a customer up to a time
+CREATE INDEX idx_bills_receivable_customer_closed_at ON bills(customer_id, closed_at)
+    WHERE status IN ('closed', 'finalized');
@@ -0,0 +1,3 @@
+-- Recurring charges, e.g. subscription fees, make up the monthly recurring revenue
+ALTER TABLE line_items
+    ADD COLUMN recurring BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,22 @@
+-- Revenue per UTC month, customer and currency, rebuilt from the closed and finalized bills by the analytics refresh.
+-- billed is the revenue of the bills closed in the month. recurring is the monthly recurring revenue, the recurring
+-- charges of each bill spread evenly over the calendar months its billing period touches.
+CREATE TABLE revenue_rollups (
+    month DATE NOT NULL CHECK (EXTRACT(DAY FROM month) = 1),
+    customer_id VARCHAR(255) NOT NULL,
+    currency CHAR(3) NOT NULL CHECK (currency ~ '^[A-Z]{3}$'),
+    billed NUMERIC NOT NULL DEFAULT 0,
+    recurring NUMERIC NOT NULL DEFAULT 0,
+    PRIMARY KEY (month, customer_id, currency)
+);
+
+-- The last refresh, a single row
+CREATE TABLE revenue_rollup_refreshes (
+    singleton BOOLEAN PRIMARY KEY DEFAULT true CHECK (singleton),
+    from_month DATE NOT NULL,
+    refreshed_at TIMESTAMPTZ NOT NULL,
+    row_count BIGINT NOT NULL
+);
+
+-- Bills closed before the refreshed months still spread recurring charges into them
+CREATE INDEX idx_bills_revenue_period_end ON bills(period_end) WHERE status IN ('closed', 'finalized');
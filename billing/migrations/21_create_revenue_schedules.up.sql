@@ -0,0 +1,38 @@
+-- Service period of line items recognized over other dates than the billing period, e.g. an annual fee billed monthly.
+-- service_end is exclusive, like period_end.
+ALTER TABLE line_items
+    ADD COLUMN service_start TIMESTAMPTZ NULL,
+    ADD COLUMN service_end TIMESTAMPTZ NULL,
+    ADD CONSTRAINT line_items_service_period CHECK (
+        (service_start IS NULL) = (service_end IS NULL) AND (service_end IS NULL OR service_end >= service_start)
+    );
+
+-- Recognition schedules of the line items of closed bills, generated at close and discarded when the bill is
+-- reopened or voided. amount is the line total deferred when the bill closed.
+CREATE TABLE revenue_schedules (
+    line_item_id UUID PRIMARY KEY REFERENCES line_items(id) ON DELETE CASCADE,
+    bill_id UUID NOT NULL REFERENCES bills(id) ON DELETE CASCADE,
+    customer_id VARCHAR(255) NOT NULL,
+    currency CHAR(3) NOT NULL CHECK (currency ~ '^[A-Z]{3}$'),
+    method VARCHAR(16) NOT NULL CHECK (method IN ('daily', 'monthly')),
+    service_start TIMESTAMPTZ NOT NULL,
+    service_end TIMESTAMPTZ NOT NULL,
+    amount NUMERIC NOT NULL,
+    billed_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_revenue_schedules_bill_id ON revenue_schedules(bill_id);
+CREATE INDEX idx_revenue_schedules_billed_at ON revenue_schedules(billed_at);
+
+-- Revenue recognized per service month. Months served before the bill closed are recognized in the month of close.
+-- deferred is the amount of the schedule still deferred once the entry is recognized.
+CREATE TABLE revenue_schedule_entries (
+    line_item_id UUID NOT NULL REFERENCES revenue_schedules(line_item_id) ON DELETE CASCADE,
+    month DATE NOT NULL CHECK (EXTRACT(DAY FROM month) = 1),
+    recognized_in DATE NOT NULL CHECK (EXTRACT(DAY FROM recognized_in) = 1 AND recognized_in >= month),
+    recognized NUMERIC NOT NULL,
+    deferred NUMERIC NOT NULL,
+    PRIMARY KEY (line_item_id, month)
+);
+
+CREATE INDEX idx_revenue_schedule_entries_recognized_in ON revenue_schedule_entries(recognized_in);
@@ -0,0 +1,2 @@
+-- The revenue rollups refresh looks up the bills whose status changed since the last refresh
+CREATE INDEX idx_bills_updated_at ON bills(updated_at);
@@ -0,0 +1,4 @@
+-- Currency codes are ISO 4217 alphabetic codes.
+-- Enablement and minor-unit precision are enforced by the application's currency registry.
+ALTER TABLE line_items ALTER COLUMN currency TYPE CHAR(3);
+ALTER TABLE line_items ADD CONSTRAINT line_items_currency_iso4217 CHECK (currency ~ '^[A-Z]{3}$');
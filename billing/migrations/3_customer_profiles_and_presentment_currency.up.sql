@@ -0,0 +1,10 @@
+-- Customer profiles holding billing preferences such as the invoice currency
+CREATE TABLE customer_profiles (
+    customer_id VARCHAR(255) PRIMARY KEY,
+    presentment_currency CHAR(3) NOT NULL CHECK (presentment_currency ~ '^[A-Z]{3}$'),
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+-- Bill-level presentment currency; NULL for bills created before it was introduced
+ALTER TABLE bills ADD COLUMN presentment_currency CHAR(3) NULL CHECK (presentment_currency ~ '^[A-Z]{3}$');
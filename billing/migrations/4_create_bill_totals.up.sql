@@ -0,0 +1,24 @@
+-- Totals computed when a bill closes, so closed bills are served without recomputation.
+-- Unconstrained NUMERIC keeps unrounded amounts exact when rounding happens at the total.
+
+-- Native totals per currency
+CREATE TABLE bill_totals (
+    bill_id UUID NOT NULL REFERENCES bills(id) ON DELETE CASCADE,
+    currency CHAR(3) NOT NULL CHECK (currency ~ '^[A-Z]{3}$'),
+    amount NUMERIC NOT NULL,
+    PRIMARY KEY (bill_id, currency)
+);
+
+-- Grand total in the presentment currency
+ALTER TABLE bills
+    ADD COLUMN grand_total NUMERIC NULL,
+    ADD COLUMN grand_total_currency CHAR(3) NULL,
+    ADD COLUMN totals_rates_updated_at TIMESTAMPTZ NULL,
+    ADD COLUMN totals_computed_at TIMESTAMPTZ NULL;
+
+-- Line totals and their conversion to the presentment currency
+ALTER TABLE line_items
+    ADD COLUMN total NUMERIC NULL,
+    ADD COLUMN converted_currency CHAR(3) NULL,
+    ADD COLUMN converted_rate NUMERIC NULL,
+    ADD COLUMN converted_amount NUMERIC NULL;
@@ -0,0 +1,5 @@
+-- Keyset pagination of line items per bill, ordered by creation time then ID
+CREATE INDEX idx_line_items_bill_id_created_at_id ON line_items(bill_id, created_at, id);
+
+-- Aggregation of line totals per currency without loading the line items
+CREATE INDEX idx_line_items_bill_id_currency ON line_items(bill_id, currency);
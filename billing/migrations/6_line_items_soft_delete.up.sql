@@ -0,0 +1,10 @@
+-- Line items can be edited and soft-deleted on open bills, deleted rows are kept for the audit trail
+ALTER TABLE line_items
+    ADD COLUMN updated_at TIMESTAMPTZ,
+    ADD COLUMN deleted_at TIMESTAMPTZ,
+    ADD COLUMN deletion_reason TEXT,
+    ADD CONSTRAINT line_items_deletion_reason_check CHECK (deleted_at IS NULL OR deletion_reason IS NOT NULL);
+
+-- Reads only consider line items that are not deleted
+DROP INDEX idx_line_items_bill_id_created_at_id;
+CREATE INDEX idx_line_items_bill_id_created_at_id ON line_items(bill_id, created_at, id) WHERE deleted_at IS NULL;
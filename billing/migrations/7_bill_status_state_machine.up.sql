@@ -0,0 +1,19 @@
+-- Bill lifecycle: draft -> open -> closed -> finalized, with voided reachable from every status but finalized.
+-- Transitions are checked in the models and guarded by the status conditions of the repository updates,
+-- the constraints below keep every row consistent with its status.
+UPDATE bills SET status = 'draft' WHERE status = 'initializing';
+
+ALTER TABLE bills
+    DROP CONSTRAINT bills_status_check,
+    ALTER COLUMN status SET DEFAULT 'draft',
+    ADD COLUMN finalized_at TIMESTAMPTZ NULL,
+    ADD COLUMN voided_at TIMESTAMPTZ NULL,
+    ADD COLUMN void_reason TEXT NULL,
+    ADD CONSTRAINT bills_status_check CHECK (status IN ('draft', 'open', 'closed', 'finalized', 'voided')),
+    ADD CONSTRAINT bills_closed_at_check CHECK (
+        (status IN ('draft', 'open') AND closed_at IS NULL) OR
+        (status IN ('closed', 'finalized') AND closed_at IS NOT NULL) OR
+        status = 'voided'
+    ),
+    ADD CONSTRAINT bills_finalized_at_check CHECK ((status = 'finalized') = (finalized_at IS NOT NULL)),
+    ADD CONSTRAINT bills_voided_check CHECK ((status = 'voided') = (voided_at IS NOT NULL AND void_reason IS NOT NULL));
@@ -0,0 +1,2 @@
+-- Bills are written as drafts before their workflow starts, the reconciler looks up the oldest drafts
+CREATE INDEX idx_bills_drafts_created_at ON bills(created_at) WHERE status = 'draft';
@@ -0,0 +1,12 @@
+-- Results of the reconciliation runs between the bill workflows and the database
+CREATE TABLE reconciliation_reports (
+    id UUID PRIMARY KEY,
+    workflow_id VARCHAR(255) NOT NULL,
+    repair BOOLEAN NOT NULL,
+    started_at TIMESTAMPTZ NOT NULL,
+    completed_at TIMESTAMPTZ NOT NULL,
+    bills_checked INTEGER NOT NULL,
+    discrepancies JSONB NOT NULL DEFAULT '[]'
+);
+
+CREATE INDEX idx_reconciliation_reports_started_at ON reconciliation_reports(started_at DESC);
@@ -0,0 +1,51 @@
+package models
+
+import "fmt"
+
+// ValidateActivityRetries checks the activity timeouts and retry policy against each other.
+// Bill workflows have no execution timeout, so failing activities are retried with the whole policy:
+// until MaximumAttempts or the schedule to close timeout, before their operation is dead-lettered.
+func ValidateActivityRetries(cfg *AppConfig) error {
+	temporalCfg := cfg.Temporal
+	scheduleToClose := temporalCfg.ActivityScheduleToCloseTimeout()
+	if scheduleToClose <= 0 {
+		return fmt.Errorf("activity schedule to close timeout must be positive, got %d", scheduleToClose)
+	}
+
+	attemptTimeouts := map[string]int{
+		"activity start to close timeout":  temporalCfg.ActivityStartToCloseTimeout(),
+		"close bill activity timeout":      temporalCfg.CloseBillActivityTimeout(),
+		"reconcile batch activity timeout": temporalCfg.ReconcileBatchActivityTimeout(),
+		"export activity timeout":          temporalCfg.ExportActivityTimeout(),
+	}
+	for name, timeout := range attemptTimeouts {
+		if timeout <= 0 {
+			return fmt.Errorf("%s must be positive, got %d", name, timeout)
+		}
+		// A single attempt must fit in the time left for all attempts
+		if timeout > scheduleToClose {
+			return fmt.Errorf("%s of %ds exceeds the activity schedule to close timeout of %ds", name, timeout, scheduleToClose)
+		}
+	}
+
+	policy := temporalCfg.ActivityRetryPolicy
+	if policy.InitialInterval() <= 0 {
+		return fmt.Errorf("activity retry initial interval must be positive, got %d", policy.InitialInterval())
+	}
+	if policy.BackoffCoefficient() < 1 {
+		return fmt.Errorf("activity retry backoff coefficient must be at least 1, got %v", policy.BackoffCoefficient())
+	}
+	if policy.MaximumInterval() < policy.InitialInterval() {
+		return fmt.Errorf("activity retry maximum interval of %ds is below the initial interval of %ds",
+			policy.MaximumInterval(), policy.InitialInterval())
+	}
+	// Otherwise the schedule to close timeout expires while waiting for the next attempt
+	if policy.MaximumInterval() >= scheduleToClose {
+		return fmt.Errorf("activity retry maximum interval of %ds must be below the activity schedule to close timeout of %ds",
+			policy.MaximumInterval(), scheduleToClose)
+	}
+	if policy.MaximumAttempts() < 0 {
+		return fmt.Errorf("activity retry maximum attempts must not be negative, got %d", policy.MaximumAttempts())
+	}
+	return nil
+}
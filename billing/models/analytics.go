@@ -0,0 +1,292 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// monthsPerYear annualizes the monthly recurring revenue
+const monthsPerYear = 12
+
+// churnRatePlaces is the precision of customer churn rates
+const churnRatePlaces = 4
+
+// Granularity is the length of the periods revenue is reported in
+type Granularity string
+
+const (
+	GranularityMonth   Granularity = "month"
+	GranularityQuarter Granularity = "quarter"
+	GranularityYear    Granularity = "year"
+)
+
+// Validate validates the granularity
+func (g Granularity) Validate() error {
+	switch g {
+	case GranularityMonth, GranularityQuarter, GranularityYear:
+		return nil
+	}
+	return fmt.Errorf("invalid granularity %q, supported values: month, quarter, year", g)
+}
+
+// months returns the number of months in a period
+func (g Granularity) months() int {
+	switch g {
+	case GranularityQuarter:
+		return 3
+	case GranularityYear:
+		return 12
+	default:
+		return 1
+	}
+}
+
+// PeriodStart returns the start of the period containing the month in UTC
+func (g Granularity) PeriodStart(t time.Time) time.Time {
+	month := StartOfMonth(t)
+	offset := (int(month.Month()) - 1) % g.months()
+	return month.AddDate(0, -offset, 0)
+}
+
+// NextPeriod returns the start of the period after the one starting at start
+func (g Granularity) NextPeriod(start time.Time) time.Time {
+	return start.AddDate(0, g.months(), 0)
+}
+
+// StartOfMonth returns the first instant of the month of t in UTC, rollups are kept per UTC month
+func StartOfMonth(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// RollupRefresh is the last rebuild of the revenue rollups
+type RollupRefresh struct {
+	// From is the first month rebuilt, months before it were rebuilt by earlier refreshes
+	From        time.Time `json:"from"`
+	RefreshedAt time.Time `json:"refreshed_at"`
+	// Rows is the number of rollup rows rebuilt
+	Rows int64 `json:"rows"`
+}
+
+// PeriodRevenue is the revenue billed in a currency by bills closed in a period
+type PeriodRevenue struct {
+	PeriodStart time.Time       `json:"period_start"`
+	Currency    Currency        `json:"currency"`
+	Billed      decimal.Decimal `json:"billed"`
+}
+
+// RevenuePeriod is the revenue billed in a period per currency and in the reporting currency
+type RevenuePeriod struct {
+	Start      time.Time                    `json:"start"`
+	ByCurrency map[Currency]decimal.Decimal `json:"by_currency"`
+	Reporting  decimal.Decimal              `json:"reporting"`
+}
+
+// RevenueReport is the revenue billed per period of a range, from inclusive to to exclusive
+type RevenueReport struct {
+	From              time.Time   `json:"from"`
+	To                time.Time   `json:"to"`
+	Granularity       Granularity `json:"granularity"`
+	ReportingCurrency Currency    `json:"reporting_currency"`
+	// RatesUpdatedAt is when the rates converting to the reporting currency were published
+	RatesUpdatedAt time.Time `json:"rates_updated_at"`
+	// Refresh is the last rebuild of the rollups the report is computed from, nil when never refreshed
+	Refresh *RollupRefresh  `json:"refresh,omitempty"`
+	Periods []RevenuePeriod `json:"periods"`
+	Total   decimal.Decimal `json:"total"`
+}
+
+// NewRevenueReport lists every period from from until to, periods without revenue included,
+// converting the revenue of each currency to the reporting currency rounded in it
+func NewRevenueReport(
+	revenue []PeriodRevenue, from, to time.Time, granularity Granularity,
+	reporting Currency, rates *RatesData, policy RoundingPolicy,
+) (*RevenueReport, error) {
+	report := &RevenueReport{
+		From:              from,
+		To:                to,
+		Granularity:       granularity,
+		ReportingCurrency: reporting,
+		RatesUpdatedAt:    rates.UpdatedAt,
+		Periods:           []RevenuePeriod{},
+	}
+	periods := make(map[time.Time]*RevenuePeriod)
+	for start := from; start.Before(to); start = granularity.NextPeriod(start) {
+		report.Periods = append(report.Periods, RevenuePeriod{Start: start, ByCurrency: map[Currency]decimal.Decimal{}})
+	}
+	for i := range report.Periods {
+		periods[report.Periods[i].Start] = &report.Periods[i]
+	}
+
+	for _, r := range revenue {
+		period, ok := periods[r.PeriodStart.UTC()]
+		if !ok {
+			continue
+		}
+		rate, err := rates.ConversionRate(r.Currency, reporting)
+		if err != nil {
+			return nil, err
+		}
+		converted := policy.Round(reporting, r.Billed.Mul(rate))
+		period.ByCurrency[r.Currency] = period.ByCurrency[r.Currency].Add(r.Billed)
+		period.Reporting = period.Reporting.Add(converted)
+		report.Total = report.Total.Add(converted)
+	}
+	return report, nil
+}
+
+// RecurringRevenue is the monthly recurring revenue of a customer in a currency
+type RecurringRevenue struct {
+	Month      time.Time       `json:"month"`
+	CustomerID string          `json:"customer_id"`
+	Currency   Currency        `json:"currency"`
+	MRR        decimal.Decimal `json:"mrr"`
+}
+
+// MRRMonth is the recurring revenue of a month in the reporting currency and its movement since the month before
+type MRRMonth struct {
+	Month      time.Time                    `json:"month"`
+	ByCurrency map[Currency]decimal.Decimal `json:"by_currency"`
+	MRR        decimal.Decimal              `json:"mrr"`
+	ARR        decimal.Decimal              `json:"arr"`
+	// NewMRR is the revenue of customers without recurring revenue the month before
+	NewMRR decimal.Decimal `json:"new_mrr"`
+	// ExpansionMRR and ContractionMRR are the increases and decreases of customers with recurring revenue in both months
+	ExpansionMRR   decimal.Decimal `json:"expansion_mrr"`
+	ContractionMRR decimal.Decimal `json:"contraction_mrr"`
+	// ChurnedMRR is the revenue the month before of customers without recurring revenue this month
+	ChurnedMRR decimal.Decimal `json:"churned_mrr"`
+	NetNewMRR  decimal.Decimal `json:"net_new_mrr"`
+	Customers  int             `json:"customers"`
+	// NewCustomers and ChurnedCustomers started and stopped having recurring revenue this month
+	NewCustomers     int `json:"new_customers"`
+	ChurnedCustomers int `json:"churned_customers"`
+	// CustomerChurnRate is the churned customers over the customers of the month before, zero without any
+	CustomerChurnRate decimal.Decimal `json:"customer_churn_rate"`
+}
+
+// MRRReport is the recurring revenue per month of a range, from inclusive to to exclusive
+type MRRReport struct {
+	From              time.Time `json:"from"`
+	To                time.Time `json:"to"`
+	ReportingCurrency Currency  `json:"reporting_currency"`
+	// RatesUpdatedAt is when the rates converting to the reporting currency were published
+	RatesUpdatedAt time.Time `json:"rates_updated_at"`
+	// Refresh is the last rebuild of the rollups the report is computed from, nil when never refreshed
+	Refresh *RollupRefresh `json:"refresh,omitempty"`
+	Months  []MRRMonth     `json:"months"`
+}
+
+// NewMRRReport computes the recurring revenue of every month from from until to and its movement per customer.
+// The recurring revenue must include the month before from, the baseline of the first month.
+// Customers are compared in the reporting currency at the same rates, so exchange rates cause no movement.
+func NewMRRReport(
+	recurring []RecurringRevenue, from, to time.Time,
+	reporting Currency, rates *RatesData, policy RoundingPolicy,
+) (*MRRReport, error) {
+	byCurrency := make(map[time.Time]map[Currency]decimal.Decimal)
+	// unrounded MRR of each customer per month in the reporting currency
+	byCustomer := make(map[time.Time]map[string]decimal.Decimal)
+	for _, r := range recurring {
+		month := StartOfMonth(r.Month)
+		rate, err := rates.ConversionRate(r.Currency, reporting)
+		if err != nil {
+			return nil, err
+		}
+		if byCurrency[month] == nil {
+			byCurrency[month] = make(map[Currency]decimal.Decimal)
+			byCustomer[month] = make(map[string]decimal.Decimal)
+		}
+		byCurrency[month][r.Currency] = byCurrency[month][r.Currency].Add(r.MRR)
+		byCustomer[month][r.CustomerID] = byCustomer[month][r.CustomerID].Add(r.MRR.Mul(rate))
+	}
+
+	report := &MRRReport{
+		From:              from,
+		To:                to,
+		ReportingCurrency: reporting,
+		RatesUpdatedAt:    rates.UpdatedAt,
+		Months:            []MRRMonth{},
+	}
+	for month := from; month.Before(to); month = month.AddDate(0, 1, 0) {
+		report.Months = append(report.Months, newMRRMonth(
+			month, byCurrency[month], byCustomer[month.AddDate(0, -1, 0)], byCustomer[month], reporting, policy,
+		))
+	}
+	return report, nil
+}
+
+// newMRRMonth compares the MRR of each customer in the month to the month before
+func newMRRMonth(
+	month time.Time, byCurrency map[Currency]decimal.Decimal, previous, current map[string]decimal.Decimal,
+	reporting Currency, policy RoundingPolicy,
+) MRRMonth {
+	if byCurrency == nil {
+		byCurrency = map[Currency]decimal.Decimal{}
+	}
+	m := MRRMonth{Month: month, ByCurrency: byCurrency}
+	mrr := func(amounts map[string]decimal.Decimal, customerID string) decimal.Decimal {
+		return policy.Round(reporting, amounts[customerID])
+	}
+
+	customers := make([]string, 0, len(current))
+	for customerID := range current {
+		customers = append(customers, customerID)
+	}
+	for customerID := range previous {
+		if _, ok := current[customerID]; !ok {
+			customers = append(customers, customerID)
+		}
+	}
+
+	previousCustomers := 0
+	for _, customerID := range customers {
+		before, after := mrr(previous, customerID), mrr(current, customerID)
+		m.MRR = m.MRR.Add(after)
+		if before.IsPositive() {
+			previousCustomers++
+		}
+		switch {
+		case !before.IsPositive() && after.IsPositive():
+			m.NewMRR = m.NewMRR.Add(after)
+			m.NewCustomers++
+		case before.IsPositive() && !after.IsPositive():
+			m.ChurnedMRR = m.ChurnedMRR.Add(before)
+			m.ChurnedCustomers++
+		case after.GreaterThan(before):
+			m.ExpansionMRR = m.ExpansionMRR.Add(after.Sub(before))
+		case after.LessThan(before):
+			m.ContractionMRR = m.ContractionMRR.Add(before.Sub(after))
+		}
+		if after.IsPositive() {
+			m.Customers++
+		}
+	}
+
+	m.ARR = m.MRR.Mul(decimal.NewFromInt(monthsPerYear))
+	m.NetNewMRR = m.NewMRR.Add(m.ExpansionMRR).Sub(m.ContractionMRR).Sub(m.ChurnedMRR)
+	if previousCustomers > 0 {
+		m.CustomerChurnRate = decimal.NewFromInt(int64(m.ChurnedCustomers)).
+			DivRound(decimal.NewFromInt(int64(previousCustomers)), churnRatePlaces)
+	}
+	return m
+}
+
+// RevenueReportFilter selects the periods and reporting currency of a revenue report
+type RevenueReportFilter struct {
+	// From is the first month and To the month after the last, both at the start of a period
+	From              time.Time
+	To                time.Time
+	Granularity       Granularity
+	ReportingCurrency Currency
+}
+
+// MRRReportFilter selects the months and reporting currency of a recurring revenue report
+type MRRReportFilter struct {
+	// From is the first month and To the month after the last
+	From              time.Time
+	To                time.Time
+	ReportingCurrency Currency
+}
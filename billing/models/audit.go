@@ -0,0 +1,191 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"encore.dev/types/uuid"
+)
+
+// SystemActor is the actor of mutations without a request, e.g. bills closed at their close time
+const SystemActor = "system"
+
+// AuditMetadata identifies who and what caused a mutation
+type AuditMetadata struct {
+	// Actor is the authenticated user, SystemActor when unset
+	Actor     string `json:"actor,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	// WorkflowID and RunID are set when the mutation is persisted by a workflow activity
+	WorkflowID string `json:"workflow_id,omitempty"`
+	RunID      string `json:"run_id,omitempty"`
+}
+
+type auditMetadataKey struct{}
+
+// WithAuditMetadata returns a context carrying the audit metadata of the mutations made with it
+func WithAuditMetadata(ctx context.Context, metadata AuditMetadata) context.Context {
+	return context.WithValue(ctx, auditMetadataKey{}, metadata)
+}
+
+// AuditMetadataFrom returns the audit metadata carried by the context
+func AuditMetadataFrom(ctx context.Context) AuditMetadata {
+	metadata, _ := ctx.Value(auditMetadataKey{}).(AuditMetadata)
+	return metadata
+}
+
+// AuditEntityType is the type of the mutated record
+type AuditEntityType string
+
+const (
+	AuditEntityBill     AuditEntityType = "bill"
+	AuditEntityLineItem AuditEntityType = "line_item"
+)
+
+// AuditAction is the mutation recorded by an audit event
+type AuditAction string
+
+const (
+	AuditActionBillCreated     AuditAction = "bill.created"
+	AuditActionBillActivated   AuditAction = "bill.activated"
+	AuditActionBillClosing     AuditAction = "bill.closing"
+	AuditActionBillClosed      AuditAction = "bill.closed"
+	AuditActionBillFinalized   AuditAction = "bill.finalized"
+	AuditActionBillVoided      AuditAction = "bill.voided"
+	AuditActionBillReopened    AuditAction = "bill.reopened"
+	AuditActionLineItemAdded   AuditAction = "line_item.added"
+	AuditActionLineItemUpdated AuditAction = "line_item.updated"
+	AuditActionLineItemRemoved AuditAction = "line_item.removed"
+)
+
+// AuditEvent is an append-only record of a bill mutation.
+// Events of a bill are hash-chained: each hash covers the event and the hash of the previous event,
+// so altering or removing an event breaks the chain from that event on.
+type AuditEvent struct {
+	ID         uuid.UUID       `json:"id"`
+	BillID     uuid.UUID       `json:"bill_id"`
+	Sequence   int64           `json:"sequence"`
+	EntityType AuditEntityType `json:"entity_type"`
+	EntityID   uuid.UUID       `json:"entity_id"`
+	Action     AuditAction     `json:"action"`
+	Actor      string          `json:"actor"`
+	RequestID  string          `json:"request_id,omitempty"`
+	WorkflowID string          `json:"workflow_id,omitempty"`
+	RunID      string          `json:"run_id,omitempty"`
+	// Before and After are the record as stored before and after the mutation, Before is null on creation
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	PrevHash   string          `json:"prev_hash"`
+	Hash       string          `json:"hash"`
+}
+
+// NewAuditEvent returns the event of a mutation made with the audit metadata of ctx, chained after prev,
+// which is nil for the first event of the bill
+func NewAuditEvent(
+	ctx context.Context, prev *AuditEvent, billID uuid.UUID, entityType AuditEntityType, entityID uuid.UUID,
+	action AuditAction, before, after json.RawMessage, occurredAt time.Time,
+) (*AuditEvent, error) {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return nil, err
+	}
+	metadata := AuditMetadataFrom(ctx)
+	if metadata.Actor == "" {
+		metadata.Actor = SystemActor
+	}
+	if len(before) == 0 {
+		before = json.RawMessage("null")
+	}
+
+	event := &AuditEvent{
+		ID:         id,
+		BillID:     billID,
+		Sequence:   1,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Action:     action,
+		Actor:      metadata.Actor,
+		RequestID:  metadata.RequestID,
+		WorkflowID: metadata.WorkflowID,
+		RunID:      metadata.RunID,
+		Before:     before,
+		After:      after,
+		// Stored with microsecond precision, the hash must cover the stored time
+		OccurredAt: occurredAt.UTC().Truncate(time.Microsecond),
+	}
+	if prev != nil {
+		event.Sequence = prev.Sequence + 1
+		event.PrevHash = prev.Hash
+	}
+	if event.Hash, err = event.ComputeHash(); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// ComputeHash returns the SHA-256 of the event fields and the previous hash
+func (e *AuditEvent) ComputeHash() (string, error) {
+	// Before and After are hashed compacted, as compared when read back
+	var before, after bytes.Buffer
+	if err := json.Compact(&before, e.Before); err != nil {
+		return "", fmt.Errorf("compact before: %w", err)
+	}
+	if err := json.Compact(&after, e.After); err != nil {
+		return "", fmt.Errorf("compact after: %w", err)
+	}
+	content, err := json.Marshal([]interface{}{
+		e.ID, e.BillID, e.Sequence, e.EntityType, e.EntityID, e.Action, e.Actor, e.RequestID, e.WorkflowID, e.RunID,
+		json.RawMessage(before.Bytes()), json.RawMessage(after.Bytes()), e.OccurredAt.UTC().Format(time.RFC3339Nano), e.PrevHash,
+	})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// VerifyAuditChain checks that the events of a bill, ordered by sequence, form an unbroken hash chain
+func VerifyAuditChain(events []*AuditEvent) error {
+	prevHash := ""
+	for i, event := range events {
+		if event.Sequence != int64(i+1) {
+			return fmt.Errorf("event %d is missing", i+1)
+		}
+		if event.PrevHash != prevHash {
+			return fmt.Errorf("event %d does not follow event %d", event.Sequence, event.Sequence-1)
+		}
+		hash, err := event.ComputeHash()
+		if err != nil {
+			return fmt.Errorf("event %d cannot be hashed: %w", event.Sequence, err)
+		}
+		if hash != event.Hash {
+			return fmt.Errorf("event %d was altered", event.Sequence)
+		}
+		prevHash = event.Hash
+	}
+	return nil
+}
+
+// BillHistory is the audit trail of a bill
+type BillHistory struct {
+	BillID uuid.UUID     `json:"bill_id"`
+	Events []*AuditEvent `json:"events"`
+	// Verified is set when the events form an unbroken hash chain, VerificationError tells where it breaks otherwise
+	Verified          bool   `json:"verified"`
+	VerificationError string `json:"verification_error,omitempty"`
+}
+
+// NewBillHistory returns the history of the bill's events ordered by sequence, with their hash chain verified
+func NewBillHistory(billID uuid.UUID, events []*AuditEvent) *BillHistory {
+	history := &BillHistory{BillID: billID, Events: events, Verified: true}
+	if err := VerifyAuditChain(events); err != nil {
+		history.Verified = false
+		history.VerificationError = err.Error()
+	}
+	return history
+}
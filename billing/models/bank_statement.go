@@ -0,0 +1,340 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"encore.dev/types/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// StatementFormat is the file format of an imported bank statement
+type StatementFormat string
+
+const (
+	// StatementFormatCamt053 is the ISO 20022 camt.053 bank to customer statement
+	StatementFormatCamt053 StatementFormat = "camt053"
+	// StatementFormatCSV is a CSV export with the columns configured in Billing.BankStatements.CSV
+	StatementFormatCSV StatementFormat = "csv"
+)
+
+// Validate validates the statement format
+func (f StatementFormat) Validate() error {
+	switch f {
+	case StatementFormatCamt053, StatementFormatCSV:
+		return nil
+	}
+	return fmt.Errorf("invalid statement format %q, supported values: camt053, csv", f)
+}
+
+// StatementCredit is money received on the bank account, as booked on a statement
+type StatementCredit struct {
+	// EntryReference identifies the entry at the bank, a credit is imported once per entry reference
+	EntryReference string          `json:"entry_reference"`
+	BookedAt       time.Time       `json:"booked_at"`
+	Currency       Currency        `json:"currency"`
+	Amount         decimal.Decimal `json:"amount"`
+	// Reference is the remittance information the payer sent with the transfer
+	Reference    string `json:"reference"`
+	Counterparty string `json:"counterparty,omitempty"`
+}
+
+// Statement holds the credits of a parsed bank statement
+type Statement struct {
+	Credits []StatementCredit
+	// Skipped counts the debits, reversals and pending entries that are not imported
+	Skipped int
+}
+
+// BankTransactionStatus is the matching status of an imported bank credit
+type BankTransactionStatus string
+
+const (
+	// BankTransactionStatusMatched is set once the credit is recorded as a payment of a bill
+	BankTransactionStatusMatched BankTransactionStatus = "matched"
+	// BankTransactionStatusReview queues a credit that could not be matched to exactly one bill for manual review
+	BankTransactionStatusReview BankTransactionStatus = "review"
+	// BankTransactionStatusDismissed is set when an operator decided the credit is not the payment of a bill
+	BankTransactionStatusDismissed BankTransactionStatus = "dismissed"
+)
+
+// Validate validates the bank transaction status
+func (s BankTransactionStatus) Validate() error {
+	switch s {
+	case BankTransactionStatusMatched, BankTransactionStatusReview, BankTransactionStatusDismissed:
+		return nil
+	}
+	return fmt.Errorf("invalid bank transaction status %q, supported values: matched, review, dismissed", s)
+}
+
+// MatchReason explains how a bank credit was matched, or why it needs review
+type MatchReason string
+
+const (
+	// MatchReasonExact is a credit referencing one bill by its ID with the outstanding amount in its currency
+	MatchReasonExact MatchReason = "reference_and_amount"
+	// MatchReasonAmountMismatch is a credit referencing one bill by its ID with another amount than the outstanding one
+	MatchReasonAmountMismatch MatchReason = "reference_amount_mismatch"
+	// MatchReasonAmbiguousReference is a credit referencing several bills by their ID
+	MatchReasonAmbiguousReference MatchReason = "reference_ambiguous"
+	// MatchReasonPartialReference is a credit referencing bills by an ID prefix or their customer ID
+	MatchReasonPartialReference MatchReason = "reference_partial"
+	// MatchReasonAmountOnly is a credit without a usable reference whose amount is close to outstanding balances
+	MatchReasonAmountOnly MatchReason = "amount_within_tolerance"
+	// MatchReasonNoCandidates is a credit that matches no bill with an outstanding balance
+	MatchReasonNoCandidates MatchReason = "no_candidates"
+	// MatchReasonPaymentRejected is an exact match whose payment could not be recorded
+	MatchReasonPaymentRejected MatchReason = "payment_rejected"
+	// MatchReasonManual is a credit an operator matched to a bill
+	MatchReasonManual MatchReason = "manual"
+)
+
+// BankTransaction is a credit imported from a bank statement and its matching to a bill payment
+type BankTransaction struct {
+	ID             uuid.UUID             `json:"id"`
+	EntryReference string                `json:"entry_reference"`
+	BookedAt       time.Time             `json:"booked_at"`
+	Currency       Currency              `json:"currency"`
+	Amount         decimal.Decimal       `json:"amount"`
+	Reference      string                `json:"reference"`
+	Counterparty   string                `json:"counterparty,omitempty"`
+	Status         BankTransactionStatus `json:"status"`
+	// BillID is the bill the credit is recorded as a payment of once matched
+	BillID *uuid.UUID `json:"bill_id,omitempty"`
+	// CandidateBillIDs are the bills the credit may pay, closest amount first
+	CandidateBillIDs []uuid.UUID `json:"candidate_bill_ids"`
+	MatchReason      MatchReason `json:"match_reason"`
+	CreatedAt        time.Time   `json:"created_at"`
+	UpdatedAt        time.Time   `json:"updated_at"`
+	ResolvedAt       *time.Time  `json:"resolved_at,omitempty"`
+	// ResolutionNote explains why a credit was dismissed
+	ResolutionNote string `json:"resolution_note,omitempty"`
+}
+
+// NewBankTransaction creates the bank transaction of a credit, to be matched
+func NewBankTransaction(credit StatementCredit, createdAt time.Time) *BankTransaction {
+	return &BankTransaction{
+		ID:               uuid.Must(uuid.NewV4()),
+		EntryReference:   credit.EntryReference,
+		BookedAt:         credit.BookedAt,
+		Currency:         credit.Currency,
+		Amount:           credit.Amount,
+		Reference:        credit.Reference,
+		Counterparty:     credit.Counterparty,
+		Status:           BankTransactionStatusReview,
+		CandidateBillIDs: []uuid.UUID{},
+		MatchReason:      MatchReasonNoCandidates,
+		CreatedAt:        createdAt,
+		UpdatedAt:        createdAt,
+	}
+}
+
+// Credit returns the statement credit of the transaction
+func (t *BankTransaction) Credit() StatementCredit {
+	return StatementCredit{
+		EntryReference: t.EntryReference,
+		BookedAt:       t.BookedAt,
+		Currency:       t.Currency,
+		Amount:         t.Amount,
+		Reference:      t.Reference,
+		Counterparty:   t.Counterparty,
+	}
+}
+
+// PaymentReference is the reference of the bill payment recorded for the transaction,
+// so the credit is recorded once however often it is matched
+func (t *BankTransaction) PaymentReference() string {
+	return "bank:" + t.EntryReference
+}
+
+// ApplyMatch sets the outcome of matching the credit, matched transactions are resolved at updatedAt
+func (t *BankTransaction) ApplyMatch(match PaymentMatch, updatedAt time.Time) {
+	t.Status = match.Status
+	t.BillID = match.BillID
+	t.CandidateBillIDs = match.Candidates
+	t.MatchReason = match.Reason
+	t.UpdatedAt = updatedAt
+	t.ResolvedAt = nil
+	if match.Status != BankTransactionStatusReview {
+		t.ResolvedAt = &updatedAt
+	}
+}
+
+// Dismiss resolves the transaction in review as a credit that is not the payment of a bill
+func (t *BankTransaction) Dismiss(note string, resolvedAt time.Time) {
+	t.Status = BankTransactionStatusDismissed
+	t.ResolutionNote = note
+	t.ResolvedAt = &resolvedAt
+	t.UpdatedAt = resolvedAt
+}
+
+// Receivable is the outstanding balance of a closed or finalized bill in one currency
+type Receivable struct {
+	BillID      uuid.UUID       `json:"bill_id"`
+	CustomerID  string          `json:"customer_id"`
+	Currency    Currency        `json:"currency"`
+	Outstanding decimal.Decimal `json:"outstanding"`
+}
+
+// MatchPolicy is the fuzzy tolerance of matching credits to receivables
+type MatchPolicy struct {
+	// AmountTolerancePercent is how far off the outstanding balance a credit may be to be a review candidate
+	AmountTolerancePercent decimal.Decimal
+	// MinReferencePrefix is the shortest prefix of a bill ID in a reference that matches the bill
+	MinReferencePrefix int
+}
+
+// MatchPolicyFromConfig builds the match policy of bank statement imports
+func MatchPolicyFromConfig(cfg *AppConfig) MatchPolicy {
+	return MatchPolicy{
+		AmountTolerancePercent: decimal.NewFromFloat(cfg.Billing.BankStatements.AmountTolerancePercent()),
+		MinReferencePrefix:     cfg.Billing.BankStatements.MinReferencePrefix(),
+	}
+}
+
+// PaymentMatch is the outcome of matching a credit to the receivables
+type PaymentMatch struct {
+	Status BankTransactionStatus
+	// BillID is the bill to record the payment of when matched
+	BillID     *uuid.UUID
+	Candidates []uuid.UUID
+	Reason     MatchReason
+}
+
+// MatchPayment matches a credit to the receivables in its currency. A credit is matched only when its reference
+// contains the full ID of one bill and its amount equals the outstanding balance of that bill. Otherwise it is
+// queued for review with the bills its reference partially names, or failing that the bills whose outstanding
+// balance is within the amount tolerance.
+func MatchPayment(credit StatementCredit, receivables []Receivable, policy MatchPolicy) PaymentMatch {
+	inCurrency := make([]Receivable, 0, len(receivables))
+	for _, receivable := range receivables {
+		if receivable.Currency == credit.Currency && receivable.Outstanding.IsPositive() {
+			inCurrency = append(inCurrency, receivable)
+		}
+	}
+
+	reference := strings.ToLower(credit.Reference)
+	compact := strings.NewReplacer("-", "", " ", "").Replace(reference)
+	var referenced []Receivable
+	for _, receivable := range inCurrency {
+		if strings.Contains(compact, compactID(receivable.BillID)) {
+			referenced = append(referenced, receivable)
+		}
+	}
+	switch {
+	case len(referenced) == 1 && referenced[0].Outstanding.Equal(credit.Amount):
+		billID := referenced[0].BillID
+		return PaymentMatch{
+			Status:     BankTransactionStatusMatched,
+			BillID:     &billID,
+			Candidates: []uuid.UUID{billID},
+			Reason:     MatchReasonExact,
+		}
+	case len(referenced) == 1:
+		return review(credit, referenced, MatchReasonAmountMismatch)
+	case len(referenced) > 1:
+		return review(credit, referenced, MatchReasonAmbiguousReference)
+	}
+
+	tokens := referenceTokens(reference)
+	var partial []Receivable
+	for _, receivable := range inCurrency {
+		if referencesBillPrefix(tokens, receivable.BillID, policy.MinReferencePrefix) ||
+			(receivable.CustomerID != "" && slices.Contains(tokens, strings.ToLower(receivable.CustomerID))) {
+			partial = append(partial, receivable)
+		}
+	}
+	if len(partial) > 0 {
+		return review(credit, partial, MatchReasonPartialReference)
+	}
+
+	var nearby []Receivable
+	for _, receivable := range inCurrency {
+		tolerance := receivable.Outstanding.Mul(policy.AmountTolerancePercent).Div(decimal.NewFromInt(100))
+		if credit.Amount.Sub(receivable.Outstanding).Abs().LessThanOrEqual(tolerance) {
+			nearby = append(nearby, receivable)
+		}
+	}
+	if len(nearby) > 0 {
+		return review(credit, nearby, MatchReasonAmountOnly)
+	}
+	return review(credit, nil, MatchReasonNoCandidates)
+}
+
+// review queues the credit for review with the candidates closest to its amount first
+func review(credit StatementCredit, candidates []Receivable, reason MatchReason) PaymentMatch {
+	slices.SortFunc(candidates, func(a, b Receivable) int {
+		if c := credit.Amount.Sub(a.Outstanding).Abs().Cmp(credit.Amount.Sub(b.Outstanding).Abs()); c != 0 {
+			return c
+		}
+		return strings.Compare(a.BillID.String(), b.BillID.String())
+	})
+	ids := make([]uuid.UUID, 0, len(candidates))
+	for _, candidate := range candidates {
+		if !slices.Contains(ids, candidate.BillID) {
+			ids = append(ids, candidate.BillID)
+		}
+	}
+	return PaymentMatch{Status: BankTransactionStatusReview, Candidates: ids, Reason: reason}
+}
+
+// referenceTokens splits a lowercased reference into its words, keeping dashes and underscores of customer IDs,
+// followed by the dash separated parts of those words
+func referenceTokens(reference string) []string {
+	tokens := strings.FieldsFunc(reference, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_')
+	})
+	for _, token := range tokens {
+		if parts := strings.Split(token, "-"); len(parts) > 1 {
+			tokens = append(tokens, parts...)
+		}
+	}
+	return tokens
+}
+
+// referencesBillPrefix reports whether a hexadecimal token of at least minLength characters starts the bill ID
+func referencesBillPrefix(tokens []string, billID uuid.UUID, minLength int) bool {
+	id := compactID(billID)
+	for _, token := range tokens {
+		if len(token) >= minLength && len(token) <= len(id) && isHex(token) && strings.HasPrefix(id, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// compactID returns the lowercase hexadecimal bill ID without dashes
+func compactID(id uuid.UUID) string {
+	return strings.ReplaceAll(id.String(), "-", "")
+}
+
+func isHex(s string) bool {
+	for _, r := range s {
+		if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// StatementImport summarizes the import of a bank statement
+type StatementImport struct {
+	Format StatementFormat `json:"format"`
+	// Imported counts the credits not imported before
+	Imported int `json:"imported"`
+	// Duplicates counts the credits already imported by an earlier statement, which are not matched again
+	Duplicates int `json:"duplicates"`
+	// Skipped counts the debits, reversals and pending entries of the statement
+	Skipped      int                `json:"skipped"`
+	Matched      int                `json:"matched"`
+	Review       int                `json:"review"`
+	Transactions []*BankTransaction `json:"transactions"`
+}
+
+// BankTransactionFilter selects the bank transactions to list, latest booked first
+type BankTransactionFilter struct {
+	Status BankTransactionStatus
+	Limit  int
+}
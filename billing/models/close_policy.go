@@ -0,0 +1,81 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// ClosePolicy determines when a bill closes relative to the end of its period
+type ClosePolicy string
+
+const (
+	// ClosePolicyExact closes the bill at the end of its period
+	ClosePolicyExact ClosePolicy = "exact"
+	// ClosePolicyEndOfDay closes the bill at the end of the day its period ends on, in the customer timezone
+	ClosePolicyEndOfDay ClosePolicy = "end_of_day"
+	// ClosePolicyGrace closes the bill a grace period after the end of its period, the bill accepts late usage meanwhile
+	ClosePolicyGrace ClosePolicy = "grace"
+)
+
+// CloseSettings are the close policy and timezone of a customer, resolved from the customer profile and configuration
+type CloseSettings struct {
+	Policy      ClosePolicy
+	Location    *time.Location
+	GracePeriod time.Duration
+}
+
+// CloseSettingsFromConfig builds the close settings of customers without a close policy or timezone.
+// The grace policy and the late usage window both delay the close for late usage, a configuration enabling both is refused.
+func CloseSettingsFromConfig(cfg *AppConfig) (CloseSettings, error) {
+	policy := ClosePolicy(cfg.Billing.Close.Policy())
+	if err := policy.Validate(); err != nil {
+		return CloseSettings{}, err
+	}
+	if policy == ClosePolicyGrace && cfg.Billing.Workflow.LateUsageGraceWindow() > 0 {
+		return CloseSettings{}, fmt.Errorf("the grace close policy cannot be combined with a late usage grace window of %ds",
+			cfg.Billing.Workflow.LateUsageGraceWindow())
+	}
+	loc, err := time.LoadLocation(cfg.Billing.Close.Timezone())
+	if err != nil {
+		return CloseSettings{}, fmt.Errorf("invalid close timezone %q: %w", cfg.Billing.Close.Timezone(), err)
+	}
+	return CloseSettings{
+		Policy:      policy,
+		Location:    loc,
+		GracePeriod: time.Duration(cfg.Billing.Close.GracePeriod()) * time.Second,
+	}, nil
+}
+
+// Validate validates the close policy
+func (p ClosePolicy) Validate() error {
+	switch p {
+	case ClosePolicyExact, ClosePolicyEndOfDay, ClosePolicyGrace:
+		return nil
+	default:
+		return fmt.Errorf("invalid close policy %q, supported policies are exact, end_of_day and grace", p)
+	}
+}
+
+// CloseTime returns when a bill whose period ends at periodEnd closes under the settings
+func (s CloseSettings) CloseTime(periodEnd time.Time) time.Time {
+	switch s.Policy {
+	case ClosePolicyEndOfDay:
+		// The period end is exclusive, the bill closes at the end of the day of its last instant
+		last := periodEnd.Add(-time.Nanosecond).In(s.Location)
+		year, month, day := last.Date()
+		return time.Date(year, month, day+1, 0, 0, 0, 0, s.Location)
+	case ClosePolicyGrace:
+		return periodEnd.Add(s.GracePeriod)
+	default:
+		return periodEnd
+	}
+}
+
+// CloseTime returns when the bill closes automatically.
+// Bills created before close policies have no scheduled close time and close at the end of their period.
+func (b *Bill) CloseTime() time.Time {
+	if b.ScheduledCloseAt != nil {
+		return *b.ScheduledCloseAt
+	}
+	return b.PeriodEnd
+}
@@ -22,8 +22,11 @@ type TemporalConfig struct {
 	TaskQueue config.String
 
 	// Workflow settings
-	WorkflowExecutionTimeoutBuffer config.Int // in seconds
-	ActivityStartToCloseTimeout    config.Int // in seconds
+	ActivityStartToCloseTimeout    config.Int // in seconds, per attempt of activities writing to the database
+	ActivityScheduleToCloseTimeout config.Int // in seconds, across all attempts of an activity
+	CloseBillActivityTimeout       config.Int // in seconds, per attempt of CloseBill, which fetches exchange rates
+	ReconcileBatchActivityTimeout  config.Int // in seconds, per attempt of ReconcileBillBatch, which queries a workflow per bill
+	ExportActivityTimeout          config.Int // in seconds, per attempt of GenerateExport, which writes the whole export
 	ActivityRetryPolicy            ActivityRetryPolicy
 }
 
@@ -60,6 +63,30 @@ type BillingConfig struct {
 
 	// Workflow settings
 	Workflow WorkflowConfig
+	// Presentment currency used when neither the request nor the customer profile specifies one
+	DefaultPresentmentCurrency config.String
+	// Rounding rules for money calculations
+	Rounding RoundingConfig
+	// Page sizes for paginated listings
+	Pagination PaginationConfig
+	// Activation of draft bills whose workflow failed to start
+	DraftReconciler DraftReconcilerConfig
+	// Scheduled reconciliation between the bill workflows and the database
+	Reconciliation ReconciliationConfig
+	// When bills close relative to the end of their period, customer profiles can override the policy and timezone
+	Close CloseConfig
+	// General ledger postings of closed bills, payments and credit notes
+	Ledger LedgerConfig
+	// Accounting exports of bills and ledger journals
+	Export ExportConfig
+	// UBL e-invoices of closed bills and credit notes
+	Invoice InvoiceConfig
+	// Bank statement imports matching credits to bill payments
+	BankStatements BankStatementConfig
+	// Revenue analytics rollups and reports
+	Analytics AnalyticsConfig
+	// Recognition schedules of the revenue of closed bills
+	Recognition RecognitionConfig
 }
 
 // ValidationConfig holds validation rule configuration
@@ -74,9 +101,155 @@ type ValidationConfig struct {
 	MaxUnitPrice         config.Float64
 	MaxTotalAmount       config.Float64
 	AllowedCurrencies    config.Values[string]
+
+	// Export constraints
+	MaxExportRangeDays config.Int
+}
+
+// RoundingConfig holds rounding configuration for money calculations
+type RoundingConfig struct {
+	// How amounts are rounded: "half_up", "half_even" or "truncate"
+	Mode config.String
+	// Where amounts are rounded: "line" or "total"
+	Level config.String
+}
+
+// PaginationConfig holds page size limits for paginated listings
+type PaginationConfig struct {
+	DefaultLimit config.Int
+	MaxLimit     config.Int
+}
+
+// DraftReconcilerConfig holds configuration of the reconciler starting workflows of orphaned draft bills
+type DraftReconcilerConfig struct {
+	Interval config.Int // in seconds
+	// Drafts younger than this are left to the workflow started on creation
+	MinAge    config.Int // in seconds
+	BatchSize config.Int
+}
+
+// InvoiceConfig holds the seller, tax and payment terms of e-invoices
+type InvoiceConfig struct {
+	Seller InvoicePartyConfig
+	// UNCL5305 VAT category of invoiced lines: S (standard), Z (zero rated), E (exempt) or O (not subject to VAT)
+	TaxCategory config.String
+	// VAT rate in percent, 0 for categories E and O
+	TaxPercent config.Float64
+	// Required for category E, e.g. "Exempt under article 168"
+	TaxExemptionReason config.String
+	// Days after the issue date the invoice is due
+	PaymentTermsDays config.Int
+	// UNCL4461 payment means, e.g. "30" for credit transfer
+	PaymentMeansCode config.String
+	// Account the buyer pays to, optional
+	PayeeIBAN config.String
+}
+
+// InvoicePartyConfig holds the seller party of e-invoices
+type InvoicePartyConfig struct {
+	Name           config.String
+	RegistrationID config.String
+	TaxID          config.String
+	Street         config.String
+	City           config.String
+	PostalCode     config.String
+	CountryCode    config.String
+	// Peppol electronic address and its EAS scheme, e.g. "0088" for a GLN
+	EndpointID     config.String
+	EndpointScheme config.String
+}
+
+// BankStatementConfig holds the matching tolerances and CSV layout of bank statement imports
+type BankStatementConfig struct {
+	// Credits within this percent of the outstanding balance of a bill are candidates for manual review
+	AmountTolerancePercent config.Float64
+	// Shortest prefix of a bill ID in a payment reference matching the bill for review
+	MinReferencePrefix config.Int
+	// Largest statement accepted, in bytes
+	MaxStatementBytes config.Int
+	CSV               BankStatementCSVConfig
+}
+
+// BankStatementCSVConfig holds the layout of CSV bank statements, columns are named by the header row
+type BankStatementCSVConfig struct {
+	Delimiter config.String
+	// Go layout of booking dates, e.g. "02.01.2006"
+	DateLayout           config.String
+	DecimalSeparator     config.String
+	DateColumn           config.String
+	AmountColumn         config.String // credits are positive
+	CurrencyColumn       config.String
+	ReferenceColumn      config.String
+	CounterpartyColumn   config.String // optional
+	EntryReferenceColumn config.String // optional, entries are identified by their fields without it
+}
+
+// AnalyticsConfig holds the refresh window of the revenue rollups and the range of revenue reports
+type AnalyticsConfig struct {
+	// Trailing months, including the current one, the scheduled refresh rebuilds. Older months are rebuilt on request,
+	// or when bills billed in them change status.
+	RefreshWindowMonths config.Int
+	// Longest range of a revenue report, in months
+	MaxRangeMonths config.Int
+}
+
+// RecognitionConfig holds how the revenue of line items is recognized over their service period
+type RecognitionConfig struct {
+	// Straight-line method: "daily" recognizes each month its days, "monthly" an equal amount per month
+	Method config.String
+}
+
+// ExportConfig holds configuration of accounting exports
+type ExportConfig struct {
+	// Number of bills read per query while writing an export
+	BatchSize config.Int
+}
+
+// ReconciliationConfig holds configuration of the scheduled reconciliation workflow.
+// The schedule is created once, changing the interval requires updating or deleting the existing schedule.
+type ReconciliationConfig struct {
+	Interval config.Int // in seconds
+	// Repair updates the database from the workflow state on scheduled runs, otherwise discrepancies are only reported
+	Repair    config.Bool
+	BatchSize config.Int
+}
+
+// CloseConfig holds the default close policy of bills
+type CloseConfig struct {
+	// "exact", "end_of_day" or "grace"
+	Policy config.String
+	// IANA timezone of customers without one, used by the end_of_day policy
+	Timezone    config.String
+	GracePeriod config.Int // in seconds, used by the grace policy
+}
+
+// LedgerConfig holds the general ledger accounts billing events are posted to
+type LedgerConfig struct {
+	// Currency of the functional amounts balanced in the trial balance, it must be enabled
+	FunctionalCurrency config.String
+	Accounts           LedgerAccountsConfig
+}
+
+// LedgerAccountsConfig holds the general ledger account codes
+type LedgerAccountsConfig struct {
+	AccountsReceivable config.String
+	Revenue            config.String
+	// VAT billed on closed bills at the invoice tax percent, owed to the tax authority
+	TaxPayable config.String
+	Cash       config.String
+	// Realized exchange differences of payments received at another rate than the receivable was booked
+	FXGain config.String
+	FXLoss config.String
 }
 
 // WorkflowConfig holds workflow-specific configuration
 type WorkflowConfig struct {
 	WorkflowIDPrefix config.String
+	// Number of signals handled by a workflow run before it continues as new to bound its history size
+	ContinueAsNewSignalThreshold config.Int
+	// Shortest time between two upserts of the TotalByCurrency search attribute, 0 upserts every change
+	SearchAttributeTotalsInterval config.Int // in seconds
+	// How long bills stay closing after the close time of their policy, accepting late line items that occurred
+	// within their period. Unlike the grace policy, other line items are rejected meanwhile. 0 closes bills directly.
+	LateUsageGraceWindow config.Int // in seconds
 }
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/Rhymond/go-money"
+	"github.com/shopspring/decimal"
+)
+
+// Currency represents an ISO 4217 alphabetic currency code.
+// Currency metadata (minor units, numeric code, symbol) comes from go-money's currency table,
+// so enabling a new currency only requires adding it to the configured allowed currencies.
+type Currency string
+
+const (
+	USD Currency = money.USD
+	GEL Currency = money.GEL
+	JPY Currency = money.JPY
+)
+
+// defaultFraction is the number of minor units assumed for codes missing from the currency table
+const defaultFraction = 2
+
+// CurrencyInfo describes a currency as exposed by the API
+type CurrencyInfo struct {
+	Code        Currency `json:"code"`
+	NumericCode string   `json:"numeric_code"`
+	Fraction    int      `json:"fraction"`
+	Grapheme    string   `json:"grapheme"`
+}
+
+// Info returns the ISO 4217 metadata of the currency, or nil if the code is unknown.
+// Lookups are case-sensitive: "usd" is not a valid currency code.
+func (c Currency) Info() *money.Currency {
+	info := money.GetCurrency(string(c))
+	if info == nil || info.Code != string(c) {
+		return nil
+	}
+	return info
+}
+
+// Fraction returns the number of minor units (decimal places) of the currency
+func (c Currency) Fraction() int32 {
+	if info := c.Info(); info != nil {
+		return int32(info.Fraction)
+	}
+	return defaultFraction
+}
+
+// Validate checks that the currency is a known ISO 4217 code and is enabled in the configuration
+func (c Currency) Validate(cfg *AppConfig) error {
+	return c.ValidateAllowed(cfg.Billing.Validation.AllowedCurrencies())
+}
+
+// ValidateAllowed checks that the currency is a known ISO 4217 code and is one of the allowed codes
+func (c Currency) ValidateAllowed(allowed []string) error {
+	if c.Info() != nil && slices.Contains(allowed, string(c)) {
+		return nil
+	}
+	return ErrInvalidCurrency
+}
+
+// AllowsPrecision reports whether amount can be expressed in the currency's minor units,
+// e.g. 10.5 is not a valid JPY amount and 1.005 is not a valid USD amount
+func (c Currency) AllowsPrecision(amount decimal.Decimal) bool {
+	return amount.Equal(amount.Truncate(c.Fraction()))
+}
+
+// Round rounds amount to the currency's minor units using the given mode.
+// Amounts that already fit are returned unchanged, keeping their original scale.
+func (c Currency) Round(amount decimal.Decimal, mode RoundingMode) decimal.Decimal {
+	fraction := c.Fraction()
+	if amount.Exponent() >= -fraction {
+		return amount
+	}
+
+	switch mode {
+	case RoundingModeHalfEven:
+		return amount.RoundBank(fraction)
+	case RoundingModeTruncate:
+		return amount.Truncate(fraction)
+	default:
+		return amount.Round(fraction)
+	}
+}
+
+// EnabledCurrencies returns metadata for every currency enabled in the configuration.
+// It fails if the configuration enables a code unknown to the currency table.
+func EnabledCurrencies(cfg *AppConfig) ([]CurrencyInfo, error) {
+	codes := cfg.Billing.Validation.AllowedCurrencies()
+	currencies := make([]CurrencyInfo, 0, len(codes))
+	for _, code := range codes {
+		info := Currency(code).Info()
+		if info == nil {
+			return nil, fmt.Errorf("configured currency %q is not a known ISO 4217 code", code)
+		}
+		currencies = append(currencies, CurrencyInfo{
+			Code:        Currency(info.Code),
+			NumericCode: info.NumericCode,
+			Fraction:    info.Fraction,
+			Grapheme:    info.Grapheme,
+		})
+	}
+	return currencies, nil
+}
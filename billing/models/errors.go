@@ -11,10 +11,138 @@ var (
 		Message: "bill not found",
 	}
 
-	// ErrBillClosed is returned when trying to modify a closed bill
+	// ErrCustomerProfileNotFound is returned when a customer has no profile
+	ErrCustomerProfileNotFound = &errs.Error{
+		Code:    errs.NotFound,
+		Message: "customer profile not found",
+	}
+
+	// ErrReconciliationReportNotFound is returned when a reconciliation report is not found
+	ErrReconciliationReportNotFound = &errs.Error{
+		Code:    errs.NotFound,
+		Message: "reconciliation report not found",
+	}
+
+	// ErrFailedOperationNotFound is returned when a failed operation is not found
+	ErrFailedOperationNotFound = &errs.Error{
+		Code:    errs.NotFound,
+		Message: "failed operation not found",
+	}
+
+	// ErrFailedOperationNotPending is returned when retrying or acknowledging a failed operation that is already resolved
+	ErrFailedOperationNotPending = &errs.Error{
+		Code:    errs.FailedPrecondition,
+		Message: "failed operation is not pending",
+	}
+
+	// ErrBillNotSettleable is returned when recording a payment or credit note on a bill that is not closed or finalized
+	ErrBillNotSettleable = &errs.Error{
+		Code:    errs.FailedPrecondition,
+		Message: "payments and credit notes are only recorded on closed or finalized bills",
+	}
+
+	// ErrAmountExceedsBalance is returned when a payment or credit note exceeds the outstanding balance of the bill
+	ErrAmountExceedsBalance = &errs.Error{
+		Code:    errs.FailedPrecondition,
+		Message: "amount exceeds the outstanding balance of the bill in this currency",
+	}
+
+	// ErrExportNotFound is returned when an export job is not found
+	ErrExportNotFound = &errs.Error{
+		Code:    errs.NotFound,
+		Message: "export not found",
+	}
+
+	// ErrExportNotReady is returned when downloading an export that is not completed
+	ErrExportNotReady = &errs.Error{
+		Code:    errs.FailedPrecondition,
+		Message: "export is not completed yet",
+	}
+
+	// ErrBillNotInvoiceable is returned when generating the e-invoice of a bill that is not closed or finalized
+	ErrBillNotInvoiceable = &errs.Error{
+		Code:    errs.FailedPrecondition,
+		Message: "e-invoices are only generated for closed or finalized bills",
+	}
+
+	// ErrCustomerPartyMissing is returned when generating an e-invoice for a customer whose profile has no party
+	ErrCustomerPartyMissing = &errs.Error{
+		Code:    errs.FailedPrecondition,
+		Message: "customer profile has no party, e-invoices require the legal name, country and Peppol endpoint of the customer",
+	}
+
+	// ErrLineItemNotConverted is returned when generating the e-invoice of a bill with a line item
+	// that has no conversion to the presentment currency of the bill
+	ErrLineItemNotConverted = &errs.Error{
+		Code:    errs.FailedPrecondition,
+		Message: "line item has no conversion to the presentment currency of the bill",
+	}
+
+	// ErrCreditNoteNotFound is returned when a credit note is not found on the bill
+	ErrCreditNoteNotFound = &errs.Error{
+		Code:    errs.NotFound,
+		Message: "credit note not found",
+	}
+
+	// ErrBankTransactionNotFound is returned when an imported bank transaction is not found
+	ErrBankTransactionNotFound = &errs.Error{
+		Code:    errs.NotFound,
+		Message: "bank transaction not found",
+	}
+
+	// ErrBankTransactionNotPending is returned when matching or dismissing a bank transaction that is not in review
+	ErrBankTransactionNotPending = &errs.Error{
+		Code:    errs.FailedPrecondition,
+		Message: "bank transaction is not in review",
+	}
+
+	// ErrStatementTooLarge is returned when an uploaded bank statement exceeds Billing.BankStatements.MaxStatementBytes
+	ErrStatementTooLarge = &errs.Error{
+		Code:    errs.InvalidArgument,
+		Message: "bank statement is too large",
+	}
+
+	// ErrJournalAlreadyPosted is returned when a payment or credit note with the same reference is already recorded
+	ErrJournalAlreadyPosted = &errs.Error{
+		Code:    errs.AlreadyExists,
+		Message: "a payment or credit note with this reference is already recorded for the bill",
+	}
+
+	// ErrLineItemNotFound is returned when a line item is not found on the bill
+	ErrLineItemNotFound = &errs.Error{
+		Code:    errs.NotFound,
+		Message: "line item not found",
+	}
+
+	// ErrBillClosed is returned when trying to modify a bill that is not open
 	ErrBillClosed = &errs.Error{
 		Code:    errs.FailedPrecondition,
-		Message: "bill is closed and cannot be modified",
+		Message: "bill is not open and cannot be modified",
+	}
+
+	// ErrLineItemOutsidePeriod is returned when adding a line item to a closing bill, or a bill in its grace period,
+	// whose usage did not occur within the billing period
+	ErrLineItemOutsidePeriod = &errs.Error{
+		Code:    errs.FailedPrecondition,
+		Message: "bill period has ended and the bill only accepts line items that occurred within its period",
+	}
+
+	// ErrInvalidBillTransition is returned when a bill cannot move from its current status to the requested one
+	ErrInvalidBillTransition = &errs.Error{
+		Code:    errs.FailedPrecondition,
+		Message: "bill status does not allow this operation",
+	}
+
+	// ErrTotalsNotPersisted is returned when auditing a bill whose totals were not persisted at close
+	ErrTotalsNotPersisted = &errs.Error{
+		Code:    errs.FailedPrecondition,
+		Message: "bill totals have not been persisted, only closed bills can be audited",
+	}
+
+	// ErrInvalidCursor is returned when a pagination cursor cannot be decoded
+	ErrInvalidCursor = &errs.Error{
+		Code:    errs.InvalidArgument,
+		Message: "invalid pagination cursor",
 	}
 
 	// ErrInvalidCurrency is returned when an invalid currency is provided
@@ -32,7 +160,7 @@ var (
 	// ErrInvalidBillStatus is returned when an invalid bill status is provided
 	ErrInvalidBillStatus = &errs.Error{
 		Code:    errs.InvalidArgument,
-		Message: "invalid bill status, supported statuses are open and closed",
+		Message: "invalid bill status, supported statuses are draft, open, closing, closed, finalized and voided",
 	}
 
 	// ErrInvalidPeriod is returned when period_end is before period_start
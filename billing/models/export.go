@@ -0,0 +1,113 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"encore.dev/types/uuid"
+)
+
+// ExportFormat is the layout of an accounting export
+type ExportFormat string
+
+const (
+	// ExportBillsCSV lists the bills closed in the range, one row per bill
+	ExportBillsCSV ExportFormat = "bills_csv"
+	// ExportLineItemsCSV lists the line items of the bills closed in the range, with their converted amounts
+	ExportLineItemsCSV ExportFormat = "line_items_csv"
+	// ExportTotalsCSV lists the totals of the bills closed in the range per currency, with their converted totals
+	ExportTotalsCSV ExportFormat = "totals_csv"
+	// ExportGeneralLedgerCSV lists the ledger entries of the journals posted in the range, one row per entry
+	ExportGeneralLedgerCSV ExportFormat = "gl_csv"
+	// ExportQuickBooksIIF lists the journals posted in the range as QuickBooks general journal transactions
+	ExportQuickBooksIIF ExportFormat = "quickbooks_iif"
+)
+
+// ExportFormats are the supported export formats
+var ExportFormats = []ExportFormat{
+	ExportBillsCSV, ExportLineItemsCSV, ExportTotalsCSV, ExportGeneralLedgerCSV, ExportQuickBooksIIF,
+}
+
+// Validate checks that the format is supported
+func (f ExportFormat) Validate() error {
+	for _, format := range ExportFormats {
+		if f == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid export format %q, expected one of %v", f, ExportFormats)
+}
+
+// Journals reports whether the format exports ledger journals rather than bills
+func (f ExportFormat) Journals() bool {
+	return f == ExportGeneralLedgerCSV || f == ExportQuickBooksIIF
+}
+
+// ContentType is the media type of the exported file
+func (f ExportFormat) ContentType() string {
+	if f == ExportQuickBooksIIF {
+		return "application/x-iif"
+	}
+	return "text/csv"
+}
+
+// Extension is the file extension of the exported file
+func (f ExportFormat) Extension() string {
+	if f == ExportQuickBooksIIF {
+		return "iif"
+	}
+	return "csv"
+}
+
+// ExportStatus is the status of an export job
+type ExportStatus string
+
+const (
+	ExportStatusPending   ExportStatus = "pending"
+	ExportStatusRunning   ExportStatus = "running"
+	ExportStatusCompleted ExportStatus = "completed"
+	ExportStatusFailed    ExportStatus = "failed"
+)
+
+// ExportJob generates an export of the bills closed, or journals posted, from From until To
+type ExportJob struct {
+	ID     uuid.UUID    `json:"id"`
+	Format ExportFormat `json:"format"`
+	// From is inclusive and To exclusive
+	From       time.Time    `json:"from"`
+	To         time.Time    `json:"to"`
+	Status     ExportStatus `json:"status"`
+	WorkflowID string       `json:"workflow_id"`
+	// ObjectKey is the key of the exported file in the exports bucket
+	ObjectKey string `json:"object_key"`
+	// Rows is the number of rows exported, excluding headers
+	Rows        int        `json:"rows"`
+	Error       string     `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// NewExportJob creates a pending export job whose file is stored under a key unique to the job
+func NewExportJob(format ExportFormat, from, to time.Time, createdAt time.Time) (*ExportJob, error) {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return nil, err
+	}
+	return &ExportJob{
+		ID:     id,
+		Format: format,
+		From:   from,
+		To:     to,
+		Status: ExportStatusPending,
+		ObjectKey: fmt.Sprintf("exports/%s/%s_%s_%s.%s", id, format,
+			from.UTC().Format("20060102T150405Z"), to.UTC().Format("20060102T150405Z"), format.Extension()),
+		CreatedAt: createdAt,
+	}, nil
+}
+
+// FileName is the name of the exported file when downloaded
+func (j *ExportJob) FileName() string {
+	return fmt.Sprintf("%s_%s_%s.%s", j.Format,
+		j.From.UTC().Format("20060102"), j.To.UTC().Format("20060102"), j.Format.Extension())
+}
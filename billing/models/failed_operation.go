@@ -0,0 +1,101 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"encore.dev/types/uuid"
+)
+
+// FailedOperationType is the persistence operation of a bill workflow that failed
+type FailedOperationType string
+
+const (
+	FailedOperationAddLineItem     FailedOperationType = "add_line_item"
+	FailedOperationUpdateLineItem  FailedOperationType = "update_line_item"
+	FailedOperationRemoveLineItem  FailedOperationType = "remove_line_item"
+	FailedOperationMarkBillClosing FailedOperationType = "mark_bill_closing"
+	FailedOperationCloseBill       FailedOperationType = "close_bill"
+	FailedOperationVoidBill        FailedOperationType = "void_bill"
+)
+
+// FailedOperationStatus is the resolution status of a failed operation
+type FailedOperationStatus string
+
+const (
+	// FailedOperationStatusPending keeps the bill workflow running until the operation is resolved
+	FailedOperationStatusPending FailedOperationStatus = "pending"
+	// FailedOperationStatusResolved is set when a retry of the operation succeeded
+	FailedOperationStatusResolved FailedOperationStatus = "resolved"
+	// FailedOperationStatusAcknowledged is set when an operator accepted that the operation is not persisted
+	FailedOperationStatusAcknowledged FailedOperationStatus = "acknowledged"
+)
+
+// Validate validates the failed operation status
+func (s FailedOperationStatus) Validate() error {
+	switch s {
+	case FailedOperationStatusPending, FailedOperationStatusResolved, FailedOperationStatusAcknowledged:
+		return nil
+	}
+	return fmt.Errorf("invalid failed operation status %q, supported values: pending, resolved, acknowledged", s)
+}
+
+// FailedOperationAttempt is an execution of a failed operation that exhausted its activity retries
+type FailedOperationAttempt struct {
+	Attempt   int       `json:"attempt"`
+	Error     string    `json:"error"`
+	ErrorType string    `json:"error_type,omitempty"`
+	FailedAt  time.Time `json:"failed_at"`
+}
+
+// FailedOperation is a persistence operation of a bill workflow whose activity exhausted its retries.
+// The payload is the activity input, so the operation can be retried as it was first executed.
+type FailedOperation struct {
+	ID         uuid.UUID                `json:"id"`
+	BillID     uuid.UUID                `json:"bill_id"`
+	WorkflowID string                   `json:"workflow_id"`
+	Operation  FailedOperationType      `json:"operation"`
+	Payload    json.RawMessage          `json:"payload"`
+	Status     FailedOperationStatus    `json:"status"`
+	Attempts   []FailedOperationAttempt `json:"attempts"`
+	CreatedAt  time.Time                `json:"created_at"`
+	UpdatedAt  time.Time                `json:"updated_at"`
+	ResolvedAt *time.Time               `json:"resolved_at,omitempty"`
+	// ResolutionNote explains why an operation was acknowledged without being persisted
+	ResolutionNote string `json:"resolution_note,omitempty"`
+}
+
+// LastError returns the error of the latest attempt
+func (op *FailedOperation) LastError() string {
+	if len(op.Attempts) == 0 {
+		return ""
+	}
+	return op.Attempts[len(op.Attempts)-1].Error
+}
+
+// AddAttempt records another failed execution of the operation
+func (op *FailedOperation) AddAttempt(message, errorType string, failedAt time.Time) {
+	op.Attempts = append(op.Attempts, FailedOperationAttempt{
+		Attempt:   len(op.Attempts) + 1,
+		Error:     message,
+		ErrorType: errorType,
+		FailedAt:  failedAt,
+	})
+	op.UpdatedAt = failedAt
+}
+
+// Resolve marks the pending operation as resolved or acknowledged
+func (op *FailedOperation) Resolve(status FailedOperationStatus, note string, resolvedAt time.Time) {
+	op.Status = status
+	op.ResolutionNote = note
+	op.ResolvedAt = &resolvedAt
+	op.UpdatedAt = resolvedAt
+}
+
+// FailedOperationFilter selects the failed operations to list, latest first
+type FailedOperationFilter struct {
+	Status FailedOperationStatus
+	BillID *uuid.UUID
+	Limit  int
+}
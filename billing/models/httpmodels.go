@@ -12,6 +12,8 @@ type CreateBillRequest struct {
 	CustomerID  string    `json:"customer_id" validate:"required"`
 	PeriodStart time.Time `json:"period_start" validate:"required"`
 	PeriodEnd   time.Time `json:"period_end" validate:"required"`
+	// PresentmentCurrency is the invoice currency, defaulted from the customer profile when empty
+	PresentmentCurrency Currency `json:"presentment_currency,omitempty"`
 }
 
 // BillResponse represents the response after creating a bill
@@ -25,6 +27,32 @@ type AddLineItemRequest struct {
 	Currency    Currency        `json:"currency" validate:"required"`
 	Quantity    decimal.Decimal `json:"quantity" validate:"required,gt=0"`
 	UnitPrice   decimal.Decimal `json:"unit_price" validate:"required"`
+	// OccurredAt is when the usage occurred, defaults to now. Closing bills only accept usage within their period.
+	OccurredAt *time.Time `json:"occurred_at,omitempty"`
+	// Recurring marks a subscription charge counted in the monthly recurring revenue
+	Recurring bool `json:"recurring,omitempty"`
+	// ServiceStart and ServiceEnd, exclusive, set together, are the period the revenue is recognized over,
+	// the billing period by default
+	ServiceStart *time.Time `json:"service_start,omitempty"`
+	ServiceEnd   *time.Time `json:"service_end,omitempty"`
+}
+
+// UpdateLineItemRequest represents the request to update a line item, omitted fields are left unchanged
+type UpdateLineItemRequest struct {
+	Description *string          `json:"description,omitempty"`
+	Quantity    *decimal.Decimal `json:"quantity,omitempty"`
+	UnitPrice   *decimal.Decimal `json:"unit_price,omitempty"`
+}
+
+// RemoveLineItemParams represents the query parameters when removing a line item
+type RemoveLineItemParams struct {
+	// Reason is kept with the soft-deleted line item for the audit trail
+	Reason string `query:"reason"`
+}
+
+// VoidBillRequest represents the request to void a bill
+type VoidBillRequest struct {
+	Reason string `json:"reason"`
 }
 
 // AddLineItemResponse represents the response after adding a line item
@@ -37,6 +65,12 @@ type GetBillRequest struct {
 	BillID uuid.UUID `json:"bill_id" validate:"required"`
 }
 
+// GetBillParams represents the query parameters when getting a bill
+type GetBillParams struct {
+	// Include lists optional parts of the bill to load, e.g. "line_items"
+	Include string `query:"include"`
+}
+
 // GetBillResponse represents the response when getting a bill
 type GetBillResponse struct {
 	Data *Bill `json:"data"`
@@ -50,3 +84,273 @@ type ListBillsRequest struct {
 	Limit      int    `query:"limit"`
 	Offset     int    `query:"offset"`
 }
+
+// ListLineItemsParams represents the query parameters when listing the line items of a bill
+type ListLineItemsParams struct {
+	Currency      string `query:"currency"`
+	CreatedAfter  string `query:"created_after"`  // RFC 3339, inclusive
+	CreatedBefore string `query:"created_before"` // RFC 3339, exclusive
+	Cursor        string `query:"cursor"`
+	Limit         int    `query:"limit"`
+}
+
+// ListLineItemsResponse represents a page of line items
+type ListLineItemsResponse struct {
+	Data []*LineItem `json:"data"`
+	// NextCursor is empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// ListCurrenciesResponse represents the response when listing enabled currencies
+type ListCurrenciesResponse struct {
+	Data []CurrencyInfo `json:"data"`
+}
+
+// UpsertCustomerProfileRequest represents the request to create or update a customer profile
+type UpsertCustomerProfileRequest struct {
+	PresentmentCurrency Currency `json:"presentment_currency" validate:"required"`
+	// Timezone is an IANA timezone, e.g. "Asia/Tbilisi", used by the end_of_day close policy
+	Timezone string `json:"timezone,omitempty"`
+	// ClosePolicy overrides the configured close policy of the customer's bills
+	ClosePolicy ClosePolicy `json:"close_policy,omitempty"`
+	// Party is the legal identity and Peppol address of the customer, required for e-invoices
+	Party *Party `json:"party,omitempty"`
+}
+
+// CustomerProfileResponse represents the response when getting or updating a customer profile
+type CustomerProfileResponse struct {
+	Data *CustomerProfile `json:"data"`
+}
+
+// AuditBillTotalsResponse represents the response when auditing persisted bill totals
+type AuditBillTotalsResponse struct {
+	Data *TotalsAudit `json:"data"`
+}
+
+// BillHistoryResponse represents the response when getting the audit trail of a bill
+type BillHistoryResponse struct {
+	Data *BillHistory `json:"data"`
+}
+
+// RecordPaymentRequest represents the request to record money received against a closed bill
+type RecordPaymentRequest struct {
+	Amount   decimal.Decimal `json:"amount" validate:"required,gt=0"`
+	Currency Currency        `json:"currency" validate:"required"`
+	// Reference identifies the payment for the bill, a payment is recorded once per reference
+	Reference string `json:"reference" validate:"required"`
+	// ReceivedAt is when the payment was received, defaults to now
+	ReceivedAt *time.Time `json:"received_at,omitempty"`
+}
+
+// IssueCreditNoteRequest represents the request to reduce the amount owed on a closed bill
+type IssueCreditNoteRequest struct {
+	// Amount is net of VAT, like bill totals, the receivable is credited the amount plus its VAT
+	Amount   decimal.Decimal `json:"amount" validate:"required,gt=0"`
+	Currency Currency        `json:"currency" validate:"required"`
+	// Reference identifies the credit note for the bill, a credit note is issued once per reference
+	Reference string `json:"reference" validate:"required"`
+	Reason    string `json:"reason" validate:"required"`
+}
+
+// JournalsResponse represents the ledger journals posted by a request or for a bill
+type JournalsResponse struct {
+	Data []*Journal `json:"data"`
+}
+
+// GetTrialBalanceParams represents the query parameters when getting the trial balance
+type GetTrialBalanceParams struct {
+	AsOf string `query:"as_of"` // RFC 3339, inclusive, defaults to now
+}
+
+// TrialBalanceResponse represents the response when getting the trial balance
+type TrialBalanceResponse struct {
+	Data *TrialBalance `json:"data"`
+}
+
+// GetAgingReportParams represents the query parameters when getting the accounts receivable aging report
+type GetAgingReportParams struct {
+	AsOf       string `query:"as_of"`       // RFC 3339, defaults to now
+	CustomerID string `query:"customer_id"` // optional, every customer when unset
+	// ReportingCurrency defaults to the functional currency of the ledger
+	ReportingCurrency string `query:"reporting_currency"`
+}
+
+// AgingReportResponse represents the response when getting the accounts receivable aging report
+type AgingReportResponse struct {
+	Data *AgingReport `json:"data"`
+}
+
+// GetCustomerStatementParams represents the query parameters when getting the statement of a customer
+type GetCustomerStatementParams struct {
+	From string `query:"from"` // RFC 3339, inclusive
+	To   string `query:"to"`   // RFC 3339, inclusive, defaults to now
+	// ReportingCurrency defaults to the functional currency of the ledger
+	ReportingCurrency string `query:"reporting_currency"`
+}
+
+// CustomerStatementResponse represents the response when getting the statement of a customer
+type CustomerStatementResponse struct {
+	Data *CustomerStatement `json:"data"`
+}
+
+// RefreshRevenueRollupsRequest represents the request to rebuild the revenue rollups
+type RefreshRevenueRollupsRequest struct {
+	// From is in the first month rebuilt, defaults to the trailing months rebuilt on schedule
+	From time.Time `json:"from,omitempty"`
+}
+
+// RollupRefreshResponse represents the response with a refresh of the revenue rollups
+type RollupRefreshResponse struct {
+	Data *RollupRefresh `json:"data"`
+}
+
+// GetRevenueReportParams represents the query parameters when getting the revenue report
+type GetRevenueReportParams struct {
+	From        string `query:"from"`        // YYYY-MM, defaults to 11 months before to
+	To          string `query:"to"`          // YYYY-MM, inclusive, defaults to the current month
+	Granularity string `query:"granularity"` // month, quarter or year, defaults to month
+	// ReportingCurrency defaults to the functional currency of the ledger
+	ReportingCurrency string `query:"reporting_currency"`
+}
+
+// RevenueReportResponse represents the response when getting the revenue report
+type RevenueReportResponse struct {
+	Data *RevenueReport `json:"data"`
+}
+
+// GetMRRReportParams represents the query parameters when getting the recurring revenue report
+type GetMRRReportParams struct {
+	From string `query:"from"` // YYYY-MM, defaults to 11 months before to
+	To   string `query:"to"`   // YYYY-MM, inclusive, defaults to the current month
+	// ReportingCurrency defaults to the functional currency of the ledger
+	ReportingCurrency string `query:"reporting_currency"`
+}
+
+// MRRReportResponse represents the response when getting the recurring revenue report
+type MRRReportResponse struct {
+	Data *MRRReport `json:"data"`
+}
+
+// RecognitionSchedulesResponse represents the response when getting the recognition schedules of a bill
+type RecognitionSchedulesResponse struct {
+	Data []*RecognitionSchedule `json:"data"`
+}
+
+// GetDeferredRevenueReportParams represents the query parameters when getting the deferred revenue waterfall
+type GetDeferredRevenueReportParams struct {
+	From string `query:"from"` // YYYY-MM, defaults to 11 months before to
+	To   string `query:"to"`   // YYYY-MM, inclusive, defaults to the current month
+}
+
+// DeferredRevenueReportResponse represents the response when getting the deferred revenue waterfall
+type DeferredRevenueReportResponse struct {
+	Data *DeferredRevenueWaterfall `json:"data"`
+}
+
+// CreateExportRequest represents the request to export the bills closed, or journals posted, in a date range
+type CreateExportRequest struct {
+	Format ExportFormat `json:"format"`
+	// From is inclusive and To exclusive
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// ExportJobResponse represents the response with an export job
+type ExportJobResponse struct {
+	Data *ExportJob `json:"data"`
+}
+
+// StartReconciliationRequest represents the request to run a reconciliation on demand
+type StartReconciliationRequest struct {
+	// Repair updates the database from the workflow state, otherwise discrepancies are only reported
+	Repair bool `json:"repair"`
+}
+
+// StartReconciliationResponse represents the response after starting a reconciliation
+type StartReconciliationResponse struct {
+	WorkflowID string `json:"workflow_id"`
+}
+
+// ListReconciliationReportsParams represents the query parameters when listing reconciliation reports
+type ListReconciliationReportsParams struct {
+	Limit int `query:"limit"`
+}
+
+// ListReconciliationReportsResponse represents the most recent reconciliation reports
+type ListReconciliationReportsResponse struct {
+	Data []*ReconciliationReport `json:"data"`
+}
+
+// ReconciliationReportResponse represents the response when getting a reconciliation report
+type ReconciliationReportResponse struct {
+	Data *ReconciliationReport `json:"data"`
+}
+
+// ListFailedOperationsParams represents the query parameters when listing failed operations
+type ListFailedOperationsParams struct {
+	Status string `query:"status"` // pending, resolved or acknowledged
+	BillID string `query:"bill_id"`
+	Limit  int    `query:"limit"`
+}
+
+// ListFailedOperationsResponse represents the latest failed operations
+type ListFailedOperationsResponse struct {
+	Data []*FailedOperation `json:"data"`
+}
+
+// FailedOperationResponse represents the response when getting, retrying or acknowledging a failed operation
+type FailedOperationResponse struct {
+	Data *FailedOperation `json:"data"`
+}
+
+// AcknowledgeFailedOperationRequest represents the request to accept that a failed operation is not persisted
+type AcknowledgeFailedOperationRequest struct {
+	Note string `json:"note" validate:"required"`
+}
+
+// StatementImportResponse represents the result of importing a bank statement
+type StatementImportResponse struct {
+	Data *StatementImport `json:"data"`
+}
+
+// ListBankTransactionsParams represents the query parameters when listing imported bank transactions
+type ListBankTransactionsParams struct {
+	Status string `query:"status"` // matched, review or dismissed
+	Limit  int    `query:"limit"`
+}
+
+// ListBankTransactionsResponse represents the latest booked bank transactions
+type ListBankTransactionsResponse struct {
+	Data []*BankTransaction `json:"data"`
+}
+
+// BankTransactionResponse represents the response when getting, matching or dismissing a bank transaction
+type BankTransactionResponse struct {
+	Data *BankTransaction `json:"data"`
+}
+
+// MatchBankTransactionRequest represents the request to record a bank credit in review as a payment of a bill
+type MatchBankTransactionRequest struct {
+	BillID uuid.UUID `json:"bill_id" validate:"required"`
+}
+
+// DismissBankTransactionRequest represents the request to dismiss a bank credit that is not a bill payment
+type DismissBankTransactionRequest struct {
+	Note string `json:"note" validate:"required"`
+}
+
+// ListBillWorkflowsParams represents the query parameters when listing open bills from Temporal visibility
+type ListBillWorkflowsParams struct {
+	CustomerID      string `query:"customer_id"`
+	PeriodEndAfter  string `query:"period_end_after"`  // RFC 3339, inclusive
+	PeriodEndBefore string `query:"period_end_before"` // RFC 3339, exclusive
+	PageToken       string `query:"page_token"`
+	Limit           int    `query:"limit"`
+}
+
+// ListBillWorkflowsResponse represents a page of open bills from Temporal visibility
+type ListBillWorkflowsResponse struct {
+	Data []*BillWorkflowSummary `json:"data"`
+	// NextPageToken is empty on the last page
+	NextPageToken string `json:"next_page_token,omitempty"`
+}
@@ -0,0 +1,150 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	countryCodePattern    = regexp.MustCompile(`^[A-Z]{2}$`)
+	endpointSchemePattern = regexp.MustCompile(`^[0-9]{4}$`)
+)
+
+// Party identifies the seller or the buyer of an e-invoice
+type Party struct {
+	// Name is the registered legal name
+	Name string `json:"name"`
+	// RegistrationID is the legal registration identifier, e.g. the company number
+	RegistrationID string `json:"registration_id,omitempty"`
+	// TaxID is the VAT identifier prefixed with the country code, e.g. GE405000000
+	TaxID      string `json:"tax_id,omitempty"`
+	Street     string `json:"street,omitempty"`
+	City       string `json:"city,omitempty"`
+	PostalCode string `json:"postal_code,omitempty"`
+	// CountryCode is the ISO 3166-1 alpha-2 country code, e.g. GE
+	CountryCode string `json:"country_code"`
+	// EndpointID is the Peppol electronic address of the party in the EAS scheme EndpointScheme, e.g. 0088 for a GLN
+	EndpointID     string `json:"endpoint_id"`
+	EndpointScheme string `json:"endpoint_scheme"`
+}
+
+// PartyViolation is a party field missing or invalid for e-invoices
+type PartyViolation struct {
+	Field   string
+	Message string
+}
+
+// Validate checks the fields every e-invoice requires of a party
+func (p *Party) Validate() []PartyViolation {
+	var violations []PartyViolation
+	if p.Name == "" {
+		violations = append(violations, PartyViolation{"name", "name is required"})
+	}
+	if !countryCodePattern.MatchString(p.CountryCode) {
+		violations = append(violations, PartyViolation{"country_code", "country_code must be an ISO 3166-1 alpha-2 code, e.g. GE"})
+	}
+	if p.EndpointID == "" {
+		violations = append(violations, PartyViolation{"endpoint_id", "endpoint_id is required"})
+	}
+	if !endpointSchemePattern.MatchString(p.EndpointScheme) {
+		violations = append(violations, PartyViolation{"endpoint_scheme", "endpoint_scheme must be a 4 digit EAS code, e.g. 0088"})
+	}
+	return violations
+}
+
+// UNCL5305 VAT categories of invoiced lines
+const (
+	TaxCategoryStandard        = "S"
+	TaxCategoryZeroRated       = "Z"
+	TaxCategoryExempt          = "E"
+	TaxCategoryNotSubjectToVAT = "O"
+)
+
+// TaxCategory is the VAT category and rate of invoiced lines
+type TaxCategory struct {
+	Code string
+	// Percent is the VAT rate, zero for categories E and O
+	Percent         decimal.Decimal
+	ExemptionReason string
+}
+
+// Validate checks the rate and exemption reason against the category
+func (c TaxCategory) Validate() error {
+	switch c.Code {
+	case TaxCategoryStandard:
+		if !c.Percent.IsPositive() {
+			return fmt.Errorf("tax category S requires a positive tax percent")
+		}
+	case TaxCategoryZeroRated:
+		if !c.Percent.IsZero() {
+			return fmt.Errorf("tax category Z requires a zero tax percent")
+		}
+	case TaxCategoryExempt, TaxCategoryNotSubjectToVAT:
+		if !c.Percent.IsZero() {
+			return fmt.Errorf("tax category %s requires a zero tax percent", c.Code)
+		}
+		if c.ExemptionReason == "" {
+			return fmt.Errorf("tax category %s requires an exemption reason", c.Code)
+		}
+	default:
+		return fmt.Errorf("invalid tax category %q, supported categories are S, Z, E and O", c.Code)
+	}
+	return nil
+}
+
+// InvoiceSettings are the seller, tax and payment terms of e-invoices
+type InvoiceSettings struct {
+	Seller           Party
+	TaxCategory      TaxCategory
+	PaymentTermsDays int
+	// PaymentMeansCode is the UNCL4461 payment means, e.g. 30 for credit transfer
+	PaymentMeansCode string
+	PayeeIBAN        string
+	RoundingMode     RoundingMode
+}
+
+// InvoiceSettingsFromConfig builds the e-invoice settings from the configuration
+func InvoiceSettingsFromConfig(cfg *AppConfig) (InvoiceSettings, error) {
+	invoice := cfg.Billing.Invoice
+	settings := InvoiceSettings{
+		Seller: Party{
+			Name:           invoice.Seller.Name(),
+			RegistrationID: invoice.Seller.RegistrationID(),
+			TaxID:          invoice.Seller.TaxID(),
+			Street:         invoice.Seller.Street(),
+			City:           invoice.Seller.City(),
+			PostalCode:     invoice.Seller.PostalCode(),
+			CountryCode:    invoice.Seller.CountryCode(),
+			EndpointID:     invoice.Seller.EndpointID(),
+			EndpointScheme: invoice.Seller.EndpointScheme(),
+		},
+		TaxCategory: TaxCategory{
+			Code:            invoice.TaxCategory(),
+			Percent:         decimal.NewFromFloat(invoice.TaxPercent()),
+			ExemptionReason: invoice.TaxExemptionReason(),
+		},
+		PaymentTermsDays: invoice.PaymentTermsDays(),
+		PaymentMeansCode: invoice.PaymentMeansCode(),
+		PayeeIBAN:        invoice.PayeeIBAN(),
+		RoundingMode:     RoundingPolicyFromConfig(cfg).Mode,
+	}
+
+	if violations := settings.Seller.Validate(); len(violations) > 0 {
+		return InvoiceSettings{}, fmt.Errorf("invalid invoice seller: %s", violations[0].Message)
+	}
+	if err := settings.TaxCategory.Validate(); err != nil {
+		return InvoiceSettings{}, err
+	}
+	if settings.TaxCategory.Code == TaxCategoryStandard && settings.Seller.TaxID == "" {
+		return InvoiceSettings{}, fmt.Errorf("tax category S requires the seller tax ID")
+	}
+	if settings.PaymentTermsDays < 0 {
+		return InvoiceSettings{}, fmt.Errorf("invoice payment terms cannot be negative")
+	}
+	if settings.PaymentMeansCode == "" {
+		return InvoiceSettings{}, fmt.Errorf("invoice payment means code is not configured")
+	}
+	return settings, nil
+}
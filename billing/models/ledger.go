@@ -0,0 +1,440 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"encore.dev/types/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// LedgerSide is the side of the account a ledger entry is posted to
+type LedgerSide string
+
+const (
+	LedgerDebit  LedgerSide = "debit"
+	LedgerCredit LedgerSide = "credit"
+)
+
+// JournalType is the billing event posted by a journal
+type JournalType string
+
+const (
+	// JournalBillClosed debits accounts receivable and credits revenue and tax payable with the bill totals per currency
+	JournalBillClosed JournalType = "bill_closed"
+	// JournalBillReversal reverses the bill_closed journal of a closed bill that is reopened or voided
+	JournalBillReversal JournalType = "bill_reversal"
+	// JournalPayment debits cash and credits accounts receivable at the rate the receivable was booked
+	JournalPayment JournalType = "payment"
+	// JournalCreditNote debits revenue and tax payable and credits accounts receivable
+	JournalCreditNote JournalType = "credit_note"
+	// JournalFXDifference values the cash of a payment at the rate it was received, against FX gain or loss
+	JournalFXDifference JournalType = "fx_difference"
+)
+
+// LedgerAccounts are the general ledger account codes billing events are posted to
+type LedgerAccounts struct {
+	AccountsReceivable string `json:"accounts_receivable"`
+	Revenue            string `json:"revenue"`
+	TaxPayable         string `json:"tax_payable"`
+	Cash               string `json:"cash"`
+	FXGain             string `json:"fx_gain"`
+	FXLoss             string `json:"fx_loss"`
+}
+
+// LedgerSettings are the accounts of ledger entries and the currency their functional amounts are valued in.
+// TaxPercent is the VAT rate of invoiced lines, the tax invoiced on closed bills is posted at that rate.
+type LedgerSettings struct {
+	FunctionalCurrency Currency
+	Accounts           LedgerAccounts
+	TaxPercent         decimal.Decimal
+	RoundingMode       RoundingMode
+}
+
+// LedgerSettingsFromConfig builds the ledger settings, the functional currency must be enabled
+func LedgerSettingsFromConfig(cfg *AppConfig) (LedgerSettings, error) {
+	functional := Currency(cfg.Billing.Ledger.FunctionalCurrency())
+	if err := functional.Validate(cfg); err != nil {
+		return LedgerSettings{}, fmt.Errorf("ledger functional currency %q is not enabled", functional)
+	}
+	accounts := LedgerAccounts{
+		AccountsReceivable: cfg.Billing.Ledger.Accounts.AccountsReceivable(),
+		Revenue:            cfg.Billing.Ledger.Accounts.Revenue(),
+		TaxPayable:         cfg.Billing.Ledger.Accounts.TaxPayable(),
+		Cash:               cfg.Billing.Ledger.Accounts.Cash(),
+		FXGain:             cfg.Billing.Ledger.Accounts.FXGain(),
+		FXLoss:             cfg.Billing.Ledger.Accounts.FXLoss(),
+	}
+	for name, account := range map[string]string{
+		"AccountsReceivable": accounts.AccountsReceivable,
+		"Revenue":            accounts.Revenue,
+		"TaxPayable":         accounts.TaxPayable,
+		"Cash":               accounts.Cash,
+		"FXGain":             accounts.FXGain,
+		"FXLoss":             accounts.FXLoss,
+	} {
+		if account == "" {
+			return LedgerSettings{}, fmt.Errorf("ledger account %s is not configured", name)
+		}
+	}
+	return LedgerSettings{
+		FunctionalCurrency: functional,
+		Accounts:           accounts,
+		TaxPercent:         decimal.NewFromFloat(cfg.Billing.Invoice.TaxPercent()),
+		RoundingMode:       RoundingPolicyFromConfig(cfg).Mode,
+	}, nil
+}
+
+// functional values amount in the functional currency at the given rate
+func (s LedgerSettings) functional(amount, rate decimal.Decimal) decimal.Decimal {
+	return s.FunctionalCurrency.Round(amount.Mul(rate), s.RoundingMode)
+}
+
+// tax returns the VAT on the net amount in the currency, rounded like the tax of invoices
+func (s LedgerSettings) tax(currency Currency, net decimal.Decimal) decimal.Decimal {
+	return currency.Round(net.Mul(s.TaxPercent).Div(decimal.NewFromInt(100)), s.RoundingMode)
+}
+
+// Journal is a balanced set of ledger entries posting one billing event
+type Journal struct {
+	ID uuid.UUID `json:"id"`
+	// Key identifies the billing event, a journal is posted once per key
+	Key    string      `json:"key"`
+	Type   JournalType `json:"type"`
+	BillID uuid.UUID   `json:"bill_id"`
+	// ReversesID is the journal reversed by this one
+	ReversesID *uuid.UUID `json:"reverses_id,omitempty"`
+	// Reference is the payment or credit note reference
+	Reference   string         `json:"reference,omitempty"`
+	Description string         `json:"description"`
+	PostedAt    time.Time      `json:"posted_at"`
+	Entries     []*LedgerEntry `json:"entries"`
+}
+
+// LedgerEntry is a debit or credit of an account, in the transaction currency and in the functional currency
+type LedgerEntry struct {
+	Account            string          `json:"account"`
+	Side               LedgerSide      `json:"side"`
+	Currency           Currency        `json:"currency"`
+	Amount             decimal.Decimal `json:"amount"`
+	FunctionalCurrency Currency        `json:"functional_currency"`
+	FunctionalAmount   decimal.Decimal `json:"functional_amount"`
+}
+
+// Payment is money received against a closed bill
+type Payment struct {
+	// Reference identifies the payment for the bill, e.g. the bank transfer reference
+	Reference  string
+	Currency   Currency
+	Amount     decimal.Decimal
+	ReceivedAt time.Time
+}
+
+// CreditNote reduces the amount owed on a closed bill
+type CreditNote struct {
+	// Reference identifies the credit note for the bill, e.g. its number
+	Reference string
+	Currency  Currency
+	Amount    decimal.Decimal
+	Reason    string
+	IssuedAt  time.Time
+}
+
+func newJournal(key string, journalType JournalType, billID uuid.UUID, description string, postedAt time.Time) (*Journal, error) {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return nil, err
+	}
+	return &Journal{
+		ID:          id,
+		Key:         key,
+		Type:        journalType,
+		BillID:      billID,
+		Description: description,
+		PostedAt:    postedAt,
+		Entries:     []*LedgerEntry{},
+	}, nil
+}
+
+// post debits one account and credits the other with the amount, swapping the accounts of a negative amount
+func (j *Journal) post(
+	debit, credit string, currency Currency, amount decimal.Decimal, functionalCurrency Currency, functional decimal.Decimal,
+) {
+	if amount.IsNegative() {
+		debit, credit = credit, debit
+		amount, functional = amount.Neg(), functional.Neg()
+	}
+	j.Entries = append(j.Entries,
+		&LedgerEntry{
+			Account: debit, Side: LedgerDebit, Currency: currency, Amount: amount,
+			FunctionalCurrency: functionalCurrency, FunctionalAmount: functional,
+		},
+		&LedgerEntry{
+			Account: credit, Side: LedgerCredit, Currency: currency, Amount: amount,
+			FunctionalCurrency: functionalCurrency, FunctionalAmount: functional,
+		},
+	)
+}
+
+// Validate checks that the journal has entries of non-negative amounts in one functional currency,
+// whose functional debits equal its functional credits
+func (j *Journal) Validate() error {
+	if len(j.Entries) < 2 {
+		return fmt.Errorf("journal %s has less than two entries", j.Key)
+	}
+	debits, credits := decimal.Zero, decimal.Zero
+	for _, entry := range j.Entries {
+		if entry.Amount.IsNegative() || entry.FunctionalAmount.IsNegative() {
+			return fmt.Errorf("journal %s has a negative entry on account %s", j.Key, entry.Account)
+		}
+		if entry.FunctionalCurrency != j.Entries[0].FunctionalCurrency {
+			return fmt.Errorf("journal %s has entries in several functional currencies", j.Key)
+		}
+		switch entry.Side {
+		case LedgerDebit:
+			debits = debits.Add(entry.FunctionalAmount)
+		case LedgerCredit:
+			credits = credits.Add(entry.FunctionalAmount)
+		default:
+			return fmt.Errorf("journal %s has an entry of invalid side %q", j.Key, entry.Side)
+		}
+	}
+	if !debits.Equal(credits) {
+		return fmt.Errorf("journal %s is unbalanced: debits %s, credits %s", j.Key, debits, credits)
+	}
+	return nil
+}
+
+// Reverse returns the journal posting the opposite entries
+func (j *Journal) Reverse(postedAt time.Time) (*Journal, error) {
+	reversal, err := newJournal("reversal:"+j.ID.String(), JournalBillReversal, j.BillID,
+		fmt.Sprintf("Reversal of %s", j.Description), postedAt)
+	if err != nil {
+		return nil, err
+	}
+	reversal.ReversesID = &j.ID
+	for _, entry := range j.Entries {
+		reversed := *entry
+		reversed.Side = LedgerDebit
+		if entry.Side == LedgerDebit {
+			reversed.Side = LedgerCredit
+		}
+		reversal.Entries = append(reversal.Entries, &reversed)
+	}
+	return reversal, nil
+}
+
+// LedgerBalance returns the debit balance of the account in the currency over the journals,
+// in the currency and in the functional currency
+func LedgerBalance(journals []*Journal, account string, currency Currency) (amount, functional decimal.Decimal) {
+	amount, functional = decimal.Zero, decimal.Zero
+	for _, journal := range journals {
+		for _, entry := range journal.Entries {
+			if entry.Account != account || entry.Currency != currency {
+				continue
+			}
+			if entry.Side == LedgerDebit {
+				amount, functional = amount.Add(entry.Amount), functional.Add(entry.FunctionalAmount)
+			} else {
+				amount, functional = amount.Sub(entry.Amount), functional.Sub(entry.FunctionalAmount)
+			}
+		}
+	}
+	return amount, functional
+}
+
+// NewBillClosedJournal returns the journal of the closed bill's totals per currency, valued at the given rates.
+// It returns nil for bills closed without totals.
+// Bill totals are net: the receivable is the total plus its VAT, credited to revenue and tax payable.
+func NewBillClosedJournal(bill *Bill, closedAt time.Time, rates *RatesData, settings LedgerSettings) (*Journal, error) {
+	if bill.Total == nil || len(bill.Total.ByCurrency) == 0 {
+		return nil, nil
+	}
+	journal, err := newJournal(
+		fmt.Sprintf("bill_closed:%s:%s", bill.ID, closedAt.UTC().Format(time.RFC3339Nano)),
+		JournalBillClosed, bill.ID, fmt.Sprintf("Bill %s closed", bill.ID), closedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	currencies := make([]Currency, 0, len(bill.Total.ByCurrency))
+	for currency := range bill.Total.ByCurrency {
+		currencies = append(currencies, currency)
+	}
+	slices.Sort(currencies)
+	for _, currency := range currencies {
+		amount := bill.Total.ByCurrency[currency]
+		if amount.IsZero() {
+			continue
+		}
+		rate, err := rates.ConversionRate(currency, settings.FunctionalCurrency)
+		if err != nil {
+			return nil, err
+		}
+		tax := settings.tax(currency, amount)
+		// The tax is valued as the difference, so the receivable is valued as its gross amount
+		gross := settings.functional(amount.Add(tax), rate)
+		net := settings.functional(amount, rate)
+		journal.post(settings.Accounts.AccountsReceivable, settings.Accounts.Revenue,
+			currency, amount, settings.FunctionalCurrency, net)
+		if !tax.IsZero() {
+			journal.post(settings.Accounts.AccountsReceivable, settings.Accounts.TaxPayable,
+				currency, tax, settings.FunctionalCurrency, gross.Sub(net))
+		}
+	}
+	if len(journal.Entries) == 0 {
+		return nil, nil
+	}
+	return journal, nil
+}
+
+// settle returns the functional value of the amount of the bill's receivable in the currency,
+// at the average rate the outstanding receivable was booked.
+// It fails when the amount exceeds the outstanding receivable.
+func settle(ledger []*Journal, settings LedgerSettings, currency Currency, amount decimal.Decimal) (decimal.Decimal, error) {
+	outstanding, outstandingFunctional := LedgerBalance(ledger, settings.Accounts.AccountsReceivable, currency)
+	if !outstanding.IsPositive() || amount.GreaterThan(outstanding) {
+		return decimal.Zero, ErrAmountExceedsBalance
+	}
+	if amount.Equal(outstanding) {
+		// Settles the rounding differences of partial settlements
+		return outstandingFunctional, nil
+	}
+	return settings.FunctionalCurrency.Round(amount.Mul(outstandingFunctional).Div(outstanding), settings.RoundingMode), nil
+}
+
+// NewPaymentJournals returns the journal of the payment of the bill, settling its receivable,
+// followed by the journal of the FX difference when the rate changed since the receivable was booked.
+// The ledger is the journals already posted for the bill.
+func NewPaymentJournals(
+	billID uuid.UUID, ledger []*Journal, payment Payment, rates *RatesData, settings LedgerSettings,
+) ([]*Journal, error) {
+	settled, err := settle(ledger, settings, payment.Currency, payment.Amount)
+	if err != nil {
+		return nil, err
+	}
+	rate, err := rates.ConversionRate(payment.Currency, settings.FunctionalCurrency)
+	if err != nil {
+		return nil, err
+	}
+	received := settings.functional(payment.Amount, rate)
+
+	journal, err := newJournal(
+		fmt.Sprintf("payment:%s:%s", billID, payment.Reference), JournalPayment, billID,
+		fmt.Sprintf("Payment %s of bill %s", payment.Reference, billID), payment.ReceivedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	journal.Reference = payment.Reference
+	journal.post(settings.Accounts.Cash, settings.Accounts.AccountsReceivable,
+		payment.Currency, payment.Amount, settings.FunctionalCurrency, settled)
+	journals := []*Journal{journal}
+
+	difference := received.Sub(settled)
+	if difference.IsZero() {
+		return journals, nil
+	}
+	fx, err := newJournal(
+		fmt.Sprintf("fx_difference:%s:%s", billID, payment.Reference), JournalFXDifference, billID,
+		fmt.Sprintf("FX difference of payment %s of bill %s", payment.Reference, billID), payment.ReceivedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	fx.Reference = payment.Reference
+	// The cash is revalued without changing its amount in the payment currency
+	if difference.IsPositive() {
+		fx.Entries = append(fx.Entries,
+			&LedgerEntry{Account: settings.Accounts.Cash, Side: LedgerDebit, Currency: payment.Currency,
+				Amount: decimal.Zero, FunctionalCurrency: settings.FunctionalCurrency, FunctionalAmount: difference},
+			&LedgerEntry{Account: settings.Accounts.FXGain, Side: LedgerCredit, Currency: settings.FunctionalCurrency,
+				Amount: difference, FunctionalCurrency: settings.FunctionalCurrency, FunctionalAmount: difference},
+		)
+	} else {
+		loss := difference.Neg()
+		fx.Entries = append(fx.Entries,
+			&LedgerEntry{Account: settings.Accounts.FXLoss, Side: LedgerDebit, Currency: settings.FunctionalCurrency,
+				Amount: loss, FunctionalCurrency: settings.FunctionalCurrency, FunctionalAmount: loss},
+			&LedgerEntry{Account: settings.Accounts.Cash, Side: LedgerCredit, Currency: payment.Currency,
+				Amount: decimal.Zero, FunctionalCurrency: settings.FunctionalCurrency, FunctionalAmount: loss},
+		)
+	}
+	return append(journals, fx), nil
+}
+
+// NewCreditNoteJournal returns the journal of the credit note of the bill, reducing its revenue and receivable
+// at the rate the receivable was booked. The amount of the note is net, like bill totals: its VAT is credited too.
+// The ledger is the journals already posted for the bill.
+func NewCreditNoteJournal(billID uuid.UUID, ledger []*Journal, note CreditNote, settings LedgerSettings) (*Journal, error) {
+	tax := settings.tax(note.Currency, note.Amount)
+	settled, err := settle(ledger, settings, note.Currency, note.Amount.Add(tax))
+	if err != nil {
+		return nil, err
+	}
+	journal, err := newJournal(
+		fmt.Sprintf("credit_note:%s:%s", billID, note.Reference), JournalCreditNote, billID,
+		fmt.Sprintf("Credit note %s of bill %s: %s", note.Reference, billID, note.Reason), note.IssuedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	journal.Reference = note.Reference
+	// The net amount is valued in proportion of the gross amount settled
+	net := settled
+	if !tax.IsZero() {
+		net = settings.FunctionalCurrency.Round(settled.Mul(note.Amount).Div(note.Amount.Add(tax)), settings.RoundingMode)
+	}
+	journal.post(settings.Accounts.Revenue, settings.Accounts.AccountsReceivable,
+		note.Currency, note.Amount, settings.FunctionalCurrency, net)
+	if !tax.IsZero() {
+		journal.post(settings.Accounts.TaxPayable, settings.Accounts.AccountsReceivable,
+			note.Currency, tax, settings.FunctionalCurrency, settled.Sub(net))
+	}
+	return journal, nil
+}
+
+// TrialBalanceLine is the debits and credits posted to an account in a currency
+type TrialBalanceLine struct {
+	Account          string          `json:"account"`
+	Currency         Currency        `json:"currency"`
+	Debit            decimal.Decimal `json:"debit"`
+	Credit           decimal.Decimal `json:"credit"`
+	FunctionalDebit  decimal.Decimal `json:"functional_debit"`
+	FunctionalCredit decimal.Decimal `json:"functional_credit"`
+}
+
+// TrialBalance is the debits and credits of every account posted up to a time, in the functional currency
+type TrialBalance struct {
+	AsOf               time.Time          `json:"as_of"`
+	FunctionalCurrency Currency           `json:"functional_currency"`
+	Lines              []TrialBalanceLine `json:"lines"`
+	TotalDebit         decimal.Decimal    `json:"total_debit"`
+	TotalCredit        decimal.Decimal    `json:"total_credit"`
+	// Balanced is set when total debits equal total credits and every journal is balanced
+	Balanced bool `json:"balanced"`
+	// UnbalancedJournals are the journals whose debits differ from their credits
+	UnbalancedJournals []uuid.UUID `json:"unbalanced_journals,omitempty"`
+}
+
+// NewTrialBalance totals the lines in the functional currency and checks that debits equal credits
+func NewTrialBalance(
+	asOf time.Time, functional Currency, lines []TrialBalanceLine, unbalancedJournals []uuid.UUID,
+) *TrialBalance {
+	balance := &TrialBalance{
+		AsOf:               asOf,
+		FunctionalCurrency: functional,
+		Lines:              lines,
+		TotalDebit:         decimal.Zero,
+		TotalCredit:        decimal.Zero,
+		UnbalancedJournals: unbalancedJournals,
+	}
+	for _, line := range lines {
+		balance.TotalDebit = balance.TotalDebit.Add(line.FunctionalDebit)
+		balance.TotalCredit = balance.TotalCredit.Add(line.FunctionalCredit)
+	}
+	balance.Balanced = balance.TotalDebit.Equal(balance.TotalCredit) && len(unbalancedJournals) == 0
+	return balance
+}
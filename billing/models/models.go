@@ -1,55 +1,80 @@
 package models
 
 import (
+	"maps"
 	"slices"
 	"time"
 
 	"encore.dev/types/uuid"
-	"github.com/Rhymond/go-money"
 	"github.com/shopspring/decimal"
 )
 
-// Currency represents supported currencies
-type Currency string
-
-const (
-	USD Currency = money.USD
-	GEL Currency = money.GEL
-)
-
 // BillStatus represents the status of a bill
 type BillStatus string
 
 const (
-	BillStatusOpen   BillStatus = "open"
-	BillStatusClosed BillStatus = "closed"
+	BillStatusDraft     BillStatus = "draft"
+	BillStatusOpen      BillStatus = "open"
+	BillStatusClosing   BillStatus = "closing"
+	BillStatusClosed    BillStatus = "closed"
+	BillStatusFinalized BillStatus = "finalized"
+	BillStatusVoided    BillStatus = "voided"
 )
 
+// billTransitions lists the statuses a bill can move to from each status.
+// Open bills go through closing when a grace window for late usage is configured.
+// Finalized and voided bills are final. The bills table mirrors the resulting invariants with CHECK constraints.
+var billTransitions = map[BillStatus][]BillStatus{
+	BillStatusDraft:   {BillStatusOpen, BillStatusVoided},
+	BillStatusOpen:    {BillStatusClosing, BillStatusClosed, BillStatusVoided},
+	BillStatusClosing: {BillStatusClosed, BillStatusVoided},
+	BillStatusClosed:  {BillStatusFinalized, BillStatusVoided, BillStatusOpen},
+}
+
 // Bill represents a billing period with line items
 type Bill struct {
-	ID          uuid.UUID   `json:"id" db:"id"`
-	CustomerID  string      `json:"customer_id" db:"customer_id"`
-	Status      BillStatus  `json:"status" db:"status"`
-	PeriodStart time.Time   `json:"period_start" db:"period_start"`
-	PeriodEnd   time.Time   `json:"period_end" db:"period_end"`
-	WorkflowID  string      `json:"workflow_id" db:"workflow_id"`
-	CreatedAt   time.Time   `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time   `json:"updated_at" db:"updated_at"`
-	ClosedAt    *time.Time  `json:"closed_at,omitempty" db:"closed_at"`
-	LineItems   []*LineItem `json:"line_items,omitempty"`
-	Total       *Total      `json:"total,omitempty"`
+	ID                  uuid.UUID   `json:"id" db:"id"`
+	CustomerID          string      `json:"customer_id" db:"customer_id"`
+	Status              BillStatus  `json:"status" db:"status"`
+	PeriodStart         time.Time   `json:"period_start" db:"period_start"`
+	PeriodEnd           time.Time   `json:"period_end" db:"period_end"`
+	PresentmentCurrency Currency    `json:"presentment_currency,omitempty" db:"presentment_currency"`
+	WorkflowID          string      `json:"workflow_id" db:"workflow_id"`
+	CreatedAt           time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt           time.Time   `json:"updated_at" db:"updated_at"`
+	ClosedAt            *time.Time  `json:"closed_at,omitempty" db:"closed_at"`
+	FinalizedAt         *time.Time  `json:"finalized_at,omitempty" db:"finalized_at"`
+	VoidedAt            *time.Time  `json:"voided_at,omitempty" db:"voided_at"`
+	VoidReason          string      `json:"void_reason,omitempty" db:"void_reason"`
+	ClosePolicy         ClosePolicy `json:"close_policy,omitempty" db:"close_policy"`
+	ScheduledCloseAt    *time.Time  `json:"scheduled_close_at,omitempty" db:"scheduled_close_at"`
+	ClosingAt           *time.Time  `json:"closing_at,omitempty" db:"closing_at"`
+	LineItems           []*LineItem `json:"line_items,omitempty"`
+	LineItemCount       int64       `json:"line_items_count"`
+	Total               *Total      `json:"total,omitempty"`
 }
 
+// Total holds the native totals per currency and the authoritative grand total in the presentment currency
 type Total struct {
 	ByCurrency map[Currency]decimal.Decimal `json:"by_currency"`
-	Converted  map[Currency]Converted       `json:"converted"`
+	GrandTotal *Converted                   `json:"grand_total,omitempty"`
+	// ComputedAt is set when the totals were persisted at close instead of computed on read
+	ComputedAt *time.Time `json:"computed_at,omitempty"`
 }
 
 type Converted struct {
+	Currency      Currency        `json:"currency"`
 	Amount        decimal.Decimal `json:"amount"`
 	RateUpdatedAt time.Time       `json:"rate_updated_at"`
 }
 
+// LineConversion is a line item total converted to the bill's presentment currency
+type LineConversion struct {
+	Currency Currency        `json:"currency"`
+	Rate     decimal.Decimal `json:"rate"`
+	Amount   decimal.Decimal `json:"amount"`
+}
+
 // LineItem represents an individual charge within a bill
 type LineItem struct {
 	ID          uuid.UUID       `json:"id" db:"id"`
@@ -58,27 +83,53 @@ type LineItem struct {
 	Currency    Currency        `json:"currency" db:"currency"`
 	Quantity    decimal.Decimal `json:"quantity" db:"quantity"`
 	UnitPrice   decimal.Decimal `json:"unit_price" db:"unit_price"`
+	OccurredAt  time.Time       `json:"occurred_at" db:"occurred_at"`
 	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt   *time.Time      `json:"updated_at,omitempty" db:"updated_at"`
 	Total       decimal.Decimal `json:"total"`
+	Converted   *LineConversion `json:"converted,omitempty"`
+	// Recurring charges, e.g. subscription fees, make up the monthly recurring revenue; usage is one-off
+	Recurring bool `json:"recurring,omitempty" db:"recurring"`
+	// ServiceStart and ServiceEnd, exclusive, override the billing period the revenue is recognized over
+	ServiceStart *time.Time `json:"service_start,omitempty" db:"service_start"`
+	ServiceEnd   *time.Time `json:"service_end,omitempty" db:"service_end"`
 }
 
-func (c Currency) Validate(cfg *AppConfig) error {
-	if slices.Contains(cfg.Billing.Validation.AllowedCurrencies(), string(c)) {
-		return nil
-	}
-	return ErrInvalidCurrency
+// LineItemUpdate holds the line item fields to change, nil fields are left unchanged
+type LineItemUpdate struct {
+	Description *string
+	Quantity    *decimal.Decimal
+	UnitPrice   *decimal.Decimal
+}
+
+// CustomerProfile holds per-customer billing preferences.
+// Timezone and ClosePolicy override the configured close defaults when set.
+// Party is the buyer of the customer's e-invoices.
+type CustomerProfile struct {
+	CustomerID          string      `json:"customer_id" db:"customer_id"`
+	PresentmentCurrency Currency    `json:"presentment_currency" db:"presentment_currency"`
+	Timezone            string      `json:"timezone,omitempty" db:"timezone"`
+	ClosePolicy         ClosePolicy `json:"close_policy,omitempty" db:"close_policy"`
+	Party               *Party      `json:"party,omitempty" db:"party"`
+	CreatedAt           time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt           time.Time   `json:"updated_at" db:"updated_at"`
 }
 
 // Validate validates the bill status
 func (s BillStatus) Validate() error {
 	switch s {
-	case BillStatusOpen, BillStatusClosed:
+	case BillStatusDraft, BillStatusOpen, BillStatusClosing, BillStatusClosed, BillStatusFinalized, BillStatusVoided:
 		return nil
 	default:
 		return ErrInvalidBillStatus
 	}
 }
 
+// CanTransitionTo reports whether a bill with this status can move to the next status
+func (s BillStatus) CanTransitionTo(next BillStatus) bool {
+	return slices.Contains(billTransitions[s], next)
+}
+
 func (b *Bill) IsOpen() bool {
 	return b.Status == BillStatusOpen
 }
@@ -87,69 +138,286 @@ func (b *Bill) IsClosed() bool {
 	return b.Status == BillStatusClosed
 }
 
-func (b *Bill) AddLineItem(item LineItem) (success bool) {
-	if b.IsClosed() {
+// IsActive reports whether the bill is held by its workflow, i.e. open or closing
+func (b *Bill) IsActive() bool {
+	return b.Status == BillStatusOpen || b.Status == BillStatusClosing
+}
+
+// AcceptLineItem reports why the line item cannot be added to the bill at now, if it cannot.
+// Open bills accept any line item, closing bills only those whose usage occurred within the period.
+// Open bills under the grace policy are closing in all but name once their period ended:
+// usage of the next period must not land on them during the grace period.
+func (b *Bill) AcceptLineItem(item *LineItem, now time.Time) error {
+	switch b.Status {
+	case BillStatusOpen:
+		if b.ClosePolicy == ClosePolicyGrace && !now.Before(b.PeriodEnd) {
+			return b.acceptPeriodUsage(item)
+		}
+		return nil
+	case BillStatusClosing:
+		return b.acceptPeriodUsage(item)
+	default:
+		return ErrBillClosed
+	}
+}
+
+// acceptPeriodUsage reports whether the usage of the line item occurred within the period of the bill
+func (b *Bill) acceptPeriodUsage(item *LineItem) error {
+	if item.OccurredAt.Before(b.PeriodStart) || !item.OccurredAt.Before(b.PeriodEnd) {
+		return ErrLineItemOutsidePeriod
+	}
+	return nil
+}
+
+func (b *Bill) AddLineItem(item LineItem, now time.Time) (success bool) {
+	if b.AcceptLineItem(&item, now) != nil {
 		return false
 	}
 	b.LineItems = append(b.LineItems, &item)
 	return true
 }
 
+// FindLineItem returns the line item with the given ID, or nil if the bill does not hold it
+func (b *Bill) FindLineItem(id uuid.UUID) *LineItem {
+	i := slices.IndexFunc(b.LineItems, func(item *LineItem) bool { return item.ID == id })
+	if i < 0 {
+		return nil
+	}
+	return b.LineItems[i]
+}
+
+// RemoveLineItem removes a line item from an open bill and returns it
+func (b *Bill) RemoveLineItem(id uuid.UUID) (*LineItem, error) {
+	if !b.IsOpen() {
+		return nil, ErrBillClosed
+	}
+	i := slices.IndexFunc(b.LineItems, func(item *LineItem) bool { return item.ID == id })
+	if i < 0 {
+		return nil, ErrLineItemNotFound
+	}
+	removed := b.LineItems[i]
+	b.LineItems = slices.Delete(b.LineItems, i, i+1)
+	return removed, nil
+}
+
+// UpdateLineItem replaces a line item of an open bill with its updated version
+func (b *Bill) UpdateLineItem(item LineItem) error {
+	if !b.IsOpen() {
+		return ErrBillClosed
+	}
+	i := slices.IndexFunc(b.LineItems, func(existing *LineItem) bool { return existing.ID == item.ID })
+	if i < 0 {
+		return ErrLineItemNotFound
+	}
+	b.LineItems[i] = &item
+	return nil
+}
+
+// Transition moves the bill to the next status, setting the matching timestamp.
+// Reopening a closed bill discards its closing times and persisted totals.
+func (b *Bill) Transition(next BillStatus, at time.Time) error {
+	if !b.Status.CanTransitionTo(next) {
+		return ErrInvalidBillTransition
+	}
+
+	switch next {
+	case BillStatusOpen:
+		b.ClosedAt = nil
+		b.ClosingAt = nil
+		b.Total = nil
+	case BillStatusClosing:
+		b.ClosingAt = &at
+	case BillStatusClosed:
+		b.ClosedAt = &at
+	case BillStatusFinalized:
+		b.FinalizedAt = &at
+	case BillStatusVoided:
+		b.VoidedAt = &at
+	}
+	b.Status = next
+	return nil
+}
+
 func (b *Bill) Close(at time.Time) (success bool) {
-	if b.IsClosed() {
-		return false
+	return b.Transition(BillStatusClosed, at) == nil
+}
+
+// Void voids the bill with the reason. Finalized bills cannot be voided.
+func (b *Bill) Void(reason string, at time.Time) error {
+	if err := b.Transition(BillStatusVoided, at); err != nil {
+		return err
 	}
-	b.Status = BillStatusClosed
-	b.ClosedAt = &at
-	return true
+	b.VoidReason = reason
+	return nil
 }
 
-func (b *Bill) CalculateSum(rates *RatesData) error {
+// CalculateSum calculates line totals, native totals per currency, and the grand total in the presentment currency.
+// Each line is converted at its own rate. Line totals and converted amounts are rounded per line or once at the
+// totals depending on the rounding policy level, using the policy mode. Bills without a presentment currency
+// only get native totals.
+func (b *Bill) CalculateSum(rates *RatesData, policy RoundingPolicy) error {
+	b.LineItemCount = int64(len(b.LineItems))
 	if len(b.LineItems) == 0 {
 		return nil
 	}
 
-	for _, item := range b.LineItems {
-		item.Total = item.UnitPrice.Mul(item.Quantity)
-	}
-
 	b.Total = &Total{}
 	b.Total.ByCurrency = make(map[Currency]decimal.Decimal)
 	for _, item := range b.LineItems {
-		b.Total.ByCurrency[item.Currency] = b.Total.ByCurrency[item.Currency].Add(item.UnitPrice.Mul(item.Quantity))
+		item.Total = policy.RoundLine(item.Currency, item.UnitPrice.Mul(item.Quantity))
+		b.Total.ByCurrency[item.Currency] = b.Total.ByCurrency[item.Currency].Add(item.Total)
 	}
-
-	b.Total.Converted = make(map[Currency]Converted)
 	for currency, amount := range b.Total.ByCurrency {
-		sum := amount
-		for other, amountOther := range b.Total.ByCurrency {
-			if other == currency {
-				continue
-			}
-			fromX, ok := rates.Rates[string(other)]
-			if !ok {
-				return ErrCurrencyNotFound
-			}
-			toX, ok := rates.Rates[string(currency)]
-			if !ok {
-				return ErrCurrencyNotFound
-			}
-
-			converted := amountOther.
-				Mul(decimal.NewFromFloat(toX / fromX)).
-				Round(int32(money.GetCurrency(string(currency)).Fraction))
-
-			sum = sum.Add(converted)
+		b.Total.ByCurrency[currency] = policy.Round(currency, amount)
+	}
+
+	presentment := b.PresentmentCurrency
+	if presentment == "" {
+		return nil
+	}
+
+	sum := decimal.Zero
+	for _, item := range b.LineItems {
+		rate, err := rates.ConversionRate(item.Currency, presentment)
+		if err != nil {
+			return err
 		}
-		b.Total.Converted[currency] = Converted{
-			Amount:        sum,
-			RateUpdatedAt: rates.UpdatedAt,
+
+		converted := policy.RoundLine(presentment, item.Total.Mul(rate))
+		item.Converted = &LineConversion{
+			Currency: presentment,
+			Rate:     rate,
+			Amount:   converted,
 		}
+		sum = sum.Add(converted)
+	}
+
+	b.Total.GrandTotal = &Converted{
+		Currency:      presentment,
+		Amount:        policy.Round(presentment, sum),
+		RateUpdatedAt: rates.UpdatedAt,
 	}
 	return nil
 }
 
+// TotalsDiscrepancy is a difference between a persisted and a recomputed amount
+type TotalsDiscrepancy struct {
+	Field      string          `json:"field"`
+	Stored     decimal.Decimal `json:"stored"`
+	Recomputed decimal.Decimal `json:"recomputed"`
+}
+
+// TotalsAudit compares the totals persisted at close with totals recomputed from the line items
+type TotalsAudit struct {
+	BillID        uuid.UUID           `json:"bill_id"`
+	Stored        *Total              `json:"stored"`
+	Recomputed    *Total              `json:"recomputed"`
+	Matches       bool                `json:"matches"`
+	Discrepancies []TotalsDiscrepancy `json:"discrepancies"`
+}
+
+// AuditTotals recomputes the totals of a bill with persisted totals and reports every difference.
+// The bill itself is left untouched.
+func (b *Bill) AuditTotals(rates *RatesData, policy RoundingPolicy) (*TotalsAudit, error) {
+	recomputed := *b
+	recomputed.Total = nil
+	recomputed.LineItems = make([]*LineItem, len(b.LineItems))
+	for i, item := range b.LineItems {
+		copied := *item
+		copied.Converted = nil
+		recomputed.LineItems[i] = &copied
+	}
+	if err := recomputed.CalculateSum(rates, policy); err != nil {
+		return nil, err
+	}
+
+	audit := &TotalsAudit{
+		BillID:        b.ID,
+		Stored:        b.Total,
+		Recomputed:    recomputed.Total,
+		Discrepancies: []TotalsDiscrepancy{},
+	}
+	compare := func(field string, stored, computed decimal.Decimal) {
+		if !stored.Equal(computed) {
+			audit.Discrepancies = append(audit.Discrepancies, TotalsDiscrepancy{
+				Field:      field,
+				Stored:     stored,
+				Recomputed: computed,
+			})
+		}
+	}
+
+	stored := b.Total
+	if stored == nil {
+		stored = &Total{}
+	}
+	computed := recomputed.Total
+	if computed == nil {
+		computed = &Total{}
+	}
+
+	currencies := make(map[Currency]bool)
+	for currency := range stored.ByCurrency {
+		currencies[currency] = true
+	}
+	for currency := range computed.ByCurrency {
+		currencies[currency] = true
+	}
+	for _, currency := range slices.Sorted(maps.Keys(currencies)) {
+		compare("by_currency."+string(currency), stored.ByCurrency[currency], computed.ByCurrency[currency])
+	}
+
+	var storedGrand, computedGrand decimal.Decimal
+	if stored.GrandTotal != nil {
+		storedGrand = stored.GrandTotal.Amount
+	}
+	if computed.GrandTotal != nil {
+		computedGrand = computed.GrandTotal.Amount
+	}
+	compare("grand_total", storedGrand, computedGrand)
+
+	for i, item := range b.LineItems {
+		compare("line_items."+item.ID.String()+".total", item.Total, recomputed.LineItems[i].Total)
+	}
+
+	audit.Matches = len(audit.Discrepancies) == 0
+	return audit, nil
+}
+
+// Apply returns a copy of the line item with the update applied
+func (li LineItem) Apply(update LineItemUpdate, at time.Time) LineItem {
+	if update.Description != nil {
+		li.Description = *update.Description
+	}
+	if update.Quantity != nil {
+		li.Quantity = *update.Quantity
+	}
+	if update.UnitPrice != nil {
+		li.UnitPrice = *update.UnitPrice
+	}
+	li.UpdatedAt = &at
+	li.Total = decimal.Decimal{}
+	li.Converted = nil
+	return li
+}
+
 type RatesData struct {
 	Rates     map[string]float64
 	UpdatedAt time.Time
 }
+
+// ConversionRate returns the rate converting amounts from one currency to another
+func (r *RatesData) ConversionRate(from, to Currency) (decimal.Decimal, error) {
+	toX, ok := r.Rates[string(to)]
+	if !ok {
+		return decimal.Decimal{}, ErrCurrencyNotFound
+	}
+	if from == to {
+		return decimal.NewFromInt(1), nil
+	}
+	fromX, ok := r.Rates[string(from)]
+	if !ok {
+		return decimal.Decimal{}, ErrCurrencyNotFound
+	}
+	return decimal.NewFromFloat(toX / fromX), nil
+}
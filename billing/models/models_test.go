@@ -1,13 +1,16 @@
 package models
 
 import (
+	"context"
 	"encoding/json"
+	"slices"
 	"testing"
 	"time"
 
 	"encore.dev/types/uuid"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestCurrency_Validate(t *testing.T) {
@@ -18,18 +21,20 @@ func TestCurrency_Validate(t *testing.T) {
 	}{
 		{"valid USD", USD, false},
 		{"valid GEL", GEL, false},
+		{"valid JPY", JPY, false},
 		{"invalid currency", "EUR", true},
 		{"invalid currency", "GBP", true},
 		{"empty currency", "", true},
 		{"case sensitive", "usd", true},
 		{"case sensitive", "gel", true},
+		{"enabled but not ISO 4217", "ABC", true},
 	}
 
 	cfg := &AppConfig{
 		Billing: BillingConfig{
 			Validation: ValidationConfig{
 				AllowedCurrencies: func() []string {
-					return []string{"USD", "GEL"}
+					return []string{"USD", "GEL", "JPY", "ABC"}
 				},
 			},
 		},
@@ -48,14 +53,155 @@ func TestCurrency_Validate(t *testing.T) {
 	}
 }
 
+func TestCurrency_Fraction(t *testing.T) {
+	tests := []struct {
+		name     string
+		currency Currency
+		expected int32
+	}{
+		{"two decimal currency", USD, 2},
+		{"zero decimal currency", JPY, 0},
+		{"three decimal currency", "BHD", 3},
+		{"unknown currency defaults to two", "ABC", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.currency.Fraction())
+		})
+	}
+}
+
+func TestCurrency_AllowsPrecision(t *testing.T) {
+	tests := []struct {
+		name     string
+		currency Currency
+		amount   string
+		expected bool
+	}{
+		{"USD with cents", USD, "10.50", true},
+		{"USD with trailing zeros", USD, "10.5000", true},
+		{"USD with sub-cent amount", USD, "10.505", false},
+		{"JPY whole amount", JPY, "1000", true},
+		{"JPY whole amount with zero decimals", JPY, "1000.00", true},
+		{"JPY fractional amount", JPY, "1000.5", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.currency.AllowsPrecision(decimal.RequireFromString(tt.amount)))
+		})
+	}
+}
+
+func TestCurrency_Round(t *testing.T) {
+	tests := []struct {
+		name     string
+		currency Currency
+		mode     RoundingMode
+		amount   string
+		expected string
+	}{
+		{"half up", USD, RoundingModeHalfUp, "0.125", "0.13"},
+		{"half up negative", USD, RoundingModeHalfUp, "-0.125", "-0.13"},
+		{"half even rounds down to even", USD, RoundingModeHalfEven, "0.125", "0.12"},
+		{"half even rounds up to even", USD, RoundingModeHalfEven, "0.135", "0.14"},
+		{"half even negative", USD, RoundingModeHalfEven, "-0.125", "-0.12"},
+		{"truncate", USD, RoundingModeTruncate, "0.129", "0.12"},
+		{"truncate negative", USD, RoundingModeTruncate, "-0.129", "-0.12"},
+		{"zero decimal half up", JPY, RoundingModeHalfUp, "2.5", "3"},
+		{"zero decimal half even", JPY, RoundingModeHalfEven, "2.5", "2"},
+		{"zero decimal truncate", JPY, RoundingModeTruncate, "2.9", "2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rounded := tt.currency.Round(decimal.RequireFromString(tt.amount), tt.mode)
+			assert.True(t, decimal.RequireFromString(tt.expected).Equal(rounded), "got %s", rounded)
+		})
+	}
+
+	t.Run("keeps amounts that already fit unchanged", func(t *testing.T) {
+		amount := decimal.NewFromFloat(35)
+		assert.Equal(t, amount, USD.Round(amount, RoundingModeHalfUp))
+	})
+}
+
+func TestRoundingPolicy_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  RoundingPolicy
+		wantErr bool
+	}{
+		{"half up at total", RoundingPolicy{Mode: RoundingModeHalfUp, Level: RoundingLevelTotal}, false},
+		{"half even at line", RoundingPolicy{Mode: RoundingModeHalfEven, Level: RoundingLevelLine}, false},
+		{"truncate at line", RoundingPolicy{Mode: RoundingModeTruncate, Level: RoundingLevelLine}, false},
+		{"invalid mode", RoundingPolicy{Mode: "ceiling", Level: RoundingLevelTotal}, true},
+		{"invalid level", RoundingPolicy{Mode: RoundingModeHalfUp, Level: "bill"}, true},
+		{"empty policy", RoundingPolicy{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestEnabledCurrencies(t *testing.T) {
+	t.Run("returns metadata for enabled currencies", func(t *testing.T) {
+		cfg := &AppConfig{
+			Billing: BillingConfig{
+				Validation: ValidationConfig{
+					AllowedCurrencies: func() []string { return []string{"USD", "JPY"} },
+				},
+			},
+		}
+
+		currencies, err := EnabledCurrencies(cfg)
+
+		assert.NoError(t, err)
+		assert.Len(t, currencies, 2)
+		assert.Equal(t, USD, currencies[0].Code)
+		assert.Equal(t, 2, currencies[0].Fraction)
+		assert.Equal(t, "840", currencies[0].NumericCode)
+		assert.Equal(t, JPY, currencies[1].Code)
+		assert.Equal(t, 0, currencies[1].Fraction)
+	})
+
+	t.Run("fails on unknown codes", func(t *testing.T) {
+		cfg := &AppConfig{
+			Billing: BillingConfig{
+				Validation: ValidationConfig{
+					AllowedCurrencies: func() []string { return []string{"USD", "ABC"} },
+				},
+			},
+		}
+
+		currencies, err := EnabledCurrencies(cfg)
+
+		assert.Error(t, err)
+		assert.Nil(t, currencies)
+	})
+}
+
 func TestBillStatus_Validate(t *testing.T) {
 	tests := []struct {
 		name    string
 		status  BillStatus
 		wantErr bool
 	}{
+		{"valid draft", BillStatusDraft, false},
 		{"valid open", BillStatusOpen, false},
+		{"valid closing", BillStatusClosing, false},
 		{"valid closed", BillStatusClosed, false},
+		{"valid finalized", BillStatusFinalized, false},
+		{"valid voided", BillStatusVoided, false},
 		{"invalid status", "invalid", true},
 		{"invalid status", "pending", true},
 		{"empty status", "", true},
@@ -84,6 +230,8 @@ func TestBill_IsOpen(t *testing.T) {
 	}{
 		{"open status", BillStatusOpen, true},
 		{"closed status", BillStatusClosed, false},
+		{"draft status", BillStatusDraft, false},
+		{"voided status", BillStatusVoided, false},
 		{"empty status", "", false},
 		{"invalid status", "invalid", false},
 	}
@@ -142,7 +290,7 @@ func TestBill_AddLineItem(t *testing.T) {
 		}
 
 		initialCount := len(bill.LineItems)
-		success := bill.AddLineItem(newItem)
+		success := bill.AddLineItem(newItem, time.Now())
 
 		assert.True(t, success)
 		assert.Equal(t, initialCount+1, len(bill.LineItems))
@@ -172,7 +320,7 @@ func TestBill_AddLineItem(t *testing.T) {
 		}
 
 		initialCount := len(bill.LineItems)
-		success := bill.AddLineItem(newItem)
+		success := bill.AddLineItem(newItem, time.Now())
 
 		assert.False(t, success)
 		assert.Equal(t, initialCount, len(bill.LineItems))
@@ -192,7 +340,7 @@ func TestBill_AddLineItem(t *testing.T) {
 			UnitPrice:   decimal.NewFromFloat(10.00),
 		}
 
-		success := bill.AddLineItem(newItem)
+		success := bill.AddLineItem(newItem, time.Now())
 
 		assert.True(t, success)
 		assert.Equal(t, 1, len(bill.LineItems))
@@ -230,9 +378,315 @@ func TestBill_Close(t *testing.T) {
 	})
 }
 
+func TestBillStatus_CanTransitionTo(t *testing.T) {
+	allowed := map[BillStatus][]BillStatus{
+		BillStatusDraft:     {BillStatusOpen, BillStatusVoided},
+		BillStatusOpen:      {BillStatusClosing, BillStatusClosed, BillStatusVoided},
+		BillStatusClosing:   {BillStatusClosed, BillStatusVoided},
+		BillStatusClosed:    {BillStatusFinalized, BillStatusVoided, BillStatusOpen},
+		BillStatusFinalized: {},
+		BillStatusVoided:    {},
+	}
+	statuses := []BillStatus{
+		BillStatusDraft, BillStatusOpen, BillStatusClosing, BillStatusClosed, BillStatusFinalized, BillStatusVoided,
+	}
+
+	for _, from := range statuses {
+		for _, to := range statuses {
+			expected := slices.Contains(allowed[from], to)
+			t.Run(string(from)+"_to_"+string(to), func(t *testing.T) {
+				assert.Equal(t, expected, from.CanTransitionTo(to))
+			})
+		}
+	}
+}
+
+func TestBill_Transition(t *testing.T) {
+	t.Run("should_void_open_bill_with_reason", func(t *testing.T) {
+		now := time.Now()
+		bill := &Bill{Status: BillStatusOpen}
+
+		assert.NoError(t, bill.Void("duplicate bill", now))
+		assert.Equal(t, BillStatusVoided, bill.Status)
+		assert.Equal(t, &now, bill.VoidedAt)
+		assert.Equal(t, "duplicate bill", bill.VoidReason)
+	})
+
+	t.Run("should_finalize_closed_bill", func(t *testing.T) {
+		now := time.Now()
+		bill := &Bill{Status: BillStatusClosed, ClosedAt: &now}
+
+		assert.NoError(t, bill.Transition(BillStatusFinalized, now))
+		assert.Equal(t, BillStatusFinalized, bill.Status)
+		assert.Equal(t, &now, bill.FinalizedAt)
+	})
+
+	t.Run("should_reopen_closed_bill_and_discard_totals", func(t *testing.T) {
+		now := time.Now()
+		bill := &Bill{Status: BillStatusClosed, ClosedAt: &now, Total: &Total{ComputedAt: &now}}
+
+		assert.NoError(t, bill.Transition(BillStatusOpen, now))
+		assert.True(t, bill.IsOpen())
+		assert.Nil(t, bill.ClosedAt)
+		assert.Nil(t, bill.Total)
+	})
+
+	t.Run("should_reject_transitions_from_finalized_bill", func(t *testing.T) {
+		now := time.Now()
+		bill := &Bill{Status: BillStatusFinalized, ClosedAt: &now, FinalizedAt: &now}
+
+		assert.Equal(t, ErrInvalidBillTransition, bill.Void("too late", now))
+		assert.Equal(t, ErrInvalidBillTransition, bill.Transition(BillStatusOpen, now))
+		assert.False(t, bill.Close(now))
+		assert.Equal(t, BillStatusFinalized, bill.Status)
+		assert.Empty(t, bill.VoidReason)
+	})
+
+	t.Run("should_reject_line_item_edits_on_voided_bill", func(t *testing.T) {
+		bill := &Bill{Status: BillStatusVoided}
+
+		assert.False(t, bill.AddLineItem(LineItem{ID: uuid.Must(uuid.NewV4())}, time.Now()))
+		assert.Equal(t, ErrBillClosed, bill.UpdateLineItem(LineItem{}))
+	})
+
+	t.Run("should_record_closing_time_and_close_closing_bill", func(t *testing.T) {
+		closingAt := time.Now()
+		closedAt := closingAt.Add(15 * time.Minute)
+		bill := &Bill{Status: BillStatusOpen}
+
+		assert.NoError(t, bill.Transition(BillStatusClosing, closingAt))
+		assert.Equal(t, &closingAt, bill.ClosingAt)
+		assert.True(t, bill.IsActive())
+		assert.True(t, bill.Close(closedAt))
+		assert.Equal(t, &closedAt, bill.ClosedAt)
+		assert.False(t, bill.IsActive())
+	})
+}
+
+func TestBill_AcceptLineItem(t *testing.T) {
+	periodStart := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	periodEnd := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+	afterPeriod := periodEnd.Add(2 * time.Hour)
+	tests := []struct {
+		name       string
+		status     BillStatus
+		policy     ClosePolicy
+		occurredAt time.Time
+		now        time.Time
+		want       error
+	}{
+		{"open bill accepts usage after period", BillStatusOpen, ClosePolicyExact, periodEnd.Add(time.Hour), afterPeriod, nil},
+		{"open bill in period accepts usage before period", BillStatusOpen, ClosePolicyGrace, periodStart.Add(-time.Minute), periodStart, nil},
+		{"grace period bill accepts usage within period", BillStatusOpen, ClosePolicyGrace, periodEnd.Add(-time.Minute), afterPeriod, nil},
+		{"grace period bill rejects usage after period", BillStatusOpen, ClosePolicyGrace, periodEnd.Add(time.Hour), afterPeriod, ErrLineItemOutsidePeriod},
+		{"grace period bill rejects usage at period end", BillStatusOpen, ClosePolicyGrace, periodEnd, periodEnd, ErrLineItemOutsidePeriod},
+		{"closing bill accepts usage within period", BillStatusClosing, ClosePolicyExact, periodEnd.Add(-time.Nanosecond), afterPeriod, nil},
+		{"closing bill accepts usage at period start", BillStatusClosing, ClosePolicyExact, periodStart, afterPeriod, nil},
+		{"closing bill rejects usage at period end", BillStatusClosing, ClosePolicyExact, periodEnd, afterPeriod, ErrLineItemOutsidePeriod},
+		{"closing bill rejects usage before period", BillStatusClosing, ClosePolicyExact, periodStart.Add(-time.Minute), afterPeriod, ErrLineItemOutsidePeriod},
+		{"closed bill rejects usage within period", BillStatusClosed, ClosePolicyExact, periodStart.Add(time.Hour), afterPeriod, ErrBillClosed},
+		{"draft bill rejects usage", BillStatusDraft, ClosePolicyExact, periodStart.Add(time.Hour), periodStart, ErrBillClosed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bill := &Bill{Status: tt.status, ClosePolicy: tt.policy, PeriodStart: periodStart, PeriodEnd: periodEnd}
+			item := LineItem{ID: uuid.Must(uuid.NewV4()), OccurredAt: tt.occurredAt}
+
+			assert.Equal(t, tt.want, bill.AcceptLineItem(&item, tt.now))
+			assert.Equal(t, tt.want == nil, bill.AddLineItem(item, tt.now))
+		})
+	}
+}
+
+func TestClosePolicy_Validate(t *testing.T) {
+	for _, policy := range []ClosePolicy{ClosePolicyExact, ClosePolicyEndOfDay, ClosePolicyGrace} {
+		assert.NoError(t, policy.Validate(), policy)
+	}
+	assert.Error(t, ClosePolicy("weekly").Validate())
+	assert.Error(t, ClosePolicy("").Validate())
+}
+
+func TestValidateActivityRetries(t *testing.T) {
+	newCfg := func(scheduleToClose, maximumInterval int) *AppConfig {
+		return &AppConfig{Temporal: TemporalConfig{
+			ActivityStartToCloseTimeout:    func() int { return 30 },
+			ActivityScheduleToCloseTimeout: func() int { return scheduleToClose },
+			CloseBillActivityTimeout:       func() int { return 120 },
+			ReconcileBatchActivityTimeout:  func() int { return 600 },
+			ExportActivityTimeout:          func() int { return 1800 },
+			ActivityRetryPolicy: ActivityRetryPolicy{
+				InitialInterval:    func() int { return 5 },
+				BackoffCoefficient: func() float64 { return 2.0 },
+				MaximumInterval:    func() int { return maximumInterval },
+				MaximumAttempts:    func() int { return 100 },
+			},
+		}}
+	}
+
+	t.Run("should_accept_retries_fitting_the_schedule_to_close_timeout", func(t *testing.T) {
+		assert.NoError(t, ValidateActivityRetries(newCfg(604800, 3600)))
+	})
+
+	t.Run("should_reject_attempts_longer_than_the_schedule_to_close_timeout", func(t *testing.T) {
+		assert.Error(t, ValidateActivityRetries(newCfg(900, 60)))
+	})
+
+	t.Run("should_reject_maximum_interval_outlasting_the_schedule_to_close_timeout", func(t *testing.T) {
+		assert.Error(t, ValidateActivityRetries(newCfg(3600, 3600)))
+	})
+}
+
+func TestTaxCategory_Validate(t *testing.T) {
+	t.Run("should_accept_rates_matching_the_category", func(t *testing.T) {
+		for _, category := range []TaxCategory{
+			{Code: TaxCategoryStandard, Percent: decimal.NewFromInt(18)},
+			{Code: TaxCategoryZeroRated, Percent: decimal.Zero},
+			{Code: TaxCategoryExempt, Percent: decimal.Zero, ExemptionReason: "Exempt under article 168"},
+			{Code: TaxCategoryNotSubjectToVAT, Percent: decimal.Zero, ExemptionReason: "Not subject to VAT"},
+		} {
+			assert.NoError(t, category.Validate(), category.Code)
+		}
+	})
+
+	t.Run("should_reject_rates_or_reasons_contradicting_the_category", func(t *testing.T) {
+		for _, category := range []TaxCategory{
+			{Code: TaxCategoryStandard, Percent: decimal.Zero},
+			{Code: TaxCategoryZeroRated, Percent: decimal.NewFromInt(5)},
+			{Code: TaxCategoryExempt, Percent: decimal.Zero},
+			{Code: TaxCategoryNotSubjectToVAT, Percent: decimal.NewFromInt(18), ExemptionReason: "Not subject to VAT"},
+			{Code: "AE", Percent: decimal.Zero},
+		} {
+			assert.Error(t, category.Validate(), category.Code)
+		}
+	})
+}
+
+func TestInvoiceSettingsFromConfig(t *testing.T) {
+	newCfg := func(category string, percent float64) *AppConfig {
+		return &AppConfig{Billing: BillingConfig{
+			Rounding: RoundingConfig{
+				Mode:  func() string { return "half_up" },
+				Level: func() string { return "total" },
+			},
+			Invoice: InvoiceConfig{
+				Seller: InvoicePartyConfig{
+					Name:           func() string { return "Pave Billing LLC" },
+					RegistrationID: func() string { return "405000000" },
+					TaxID:          func() string { return "GE405000000" },
+					Street:         func() string { return "1 Rustaveli Avenue" },
+					City:           func() string { return "Tbilisi" },
+					PostalCode:     func() string { return "0108" },
+					CountryCode:    func() string { return "GE" },
+					EndpointID:     func() string { return "4860000000006" },
+					EndpointScheme: func() string { return "0088" },
+				},
+				TaxCategory:        func() string { return category },
+				TaxPercent:         func() float64 { return percent },
+				TaxExemptionReason: func() string { return "Not subject to VAT" },
+				PaymentTermsDays:   func() int { return 30 },
+				PaymentMeansCode:   func() string { return "30" },
+				PayeeIBAN:          func() string { return "" },
+			},
+		}}
+	}
+
+	t.Run("should_accept_categories_without_vat", func(t *testing.T) {
+		settings, err := InvoiceSettingsFromConfig(newCfg(TaxCategoryNotSubjectToVAT, 0))
+		require.NoError(t, err)
+		assert.True(t, settings.TaxCategory.Percent.IsZero())
+	})
+
+	t.Run("should_accept_standard_rated_vat", func(t *testing.T) {
+		settings, err := InvoiceSettingsFromConfig(newCfg(TaxCategoryStandard, 18))
+		require.NoError(t, err)
+		assert.True(t, decimal.NewFromInt(18).Equal(settings.TaxCategory.Percent))
+	})
+
+	t.Run("when_standard_rated_without_seller_tax_id_should_fail", func(t *testing.T) {
+		cfg := newCfg(TaxCategoryStandard, 18)
+		cfg.Billing.Invoice.Seller.TaxID = func() string { return "" }
+
+		_, err := InvoiceSettingsFromConfig(cfg)
+		assert.Error(t, err)
+	})
+}
+
+func TestCloseSettingsFromConfig(t *testing.T) {
+	newCfg := func(policy string, window int) *AppConfig {
+		return &AppConfig{Billing: BillingConfig{
+			Workflow: WorkflowConfig{LateUsageGraceWindow: func() int { return window }},
+			Close: CloseConfig{
+				Policy:      func() string { return policy },
+				Timezone:    func() string { return "UTC" },
+				GracePeriod: func() int { return 3600 },
+			},
+		}}
+	}
+
+	t.Run("should_accept_late_usage_window_with_exact_policy", func(t *testing.T) {
+		settings, err := CloseSettingsFromConfig(newCfg(string(ClosePolicyExact), 900))
+		require.NoError(t, err)
+		assert.Equal(t, ClosePolicyExact, settings.Policy)
+	})
+
+	t.Run("should_accept_grace_policy_without_late_usage_window", func(t *testing.T) {
+		settings, err := CloseSettingsFromConfig(newCfg(string(ClosePolicyGrace), 0))
+		require.NoError(t, err)
+		assert.Equal(t, time.Hour, settings.GracePeriod)
+	})
+
+	t.Run("should_refuse_grace_policy_with_late_usage_window", func(t *testing.T) {
+		_, err := CloseSettingsFromConfig(newCfg(string(ClosePolicyGrace), 900))
+		assert.Error(t, err)
+	})
+}
+
+func TestCloseSettings_CloseTime(t *testing.T) {
+	tbilisi, err := time.LoadLocation("Asia/Tbilisi")
+	require.NoError(t, err)
+	periodEnd := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("exact_should_close_at_period_end", func(t *testing.T) {
+		settings := CloseSettings{Policy: ClosePolicyExact, Location: tbilisi}
+
+		assert.True(t, periodEnd.Equal(settings.CloseTime(periodEnd)))
+	})
+
+	t.Run("end_of_day_should_close_at_midnight_after_last_day_in_customer_timezone", func(t *testing.T) {
+		settings := CloseSettings{Policy: ClosePolicyEndOfDay, Location: tbilisi}
+
+		// The period ends at 04:00 on February 1st in Tbilisi, UTC+4
+		assert.True(t, time.Date(2025, 2, 2, 0, 0, 0, 0, tbilisi).Equal(settings.CloseTime(periodEnd)))
+	})
+
+	t.Run("end_of_day_should_close_at_period_end_when_it_is_midnight", func(t *testing.T) {
+		settings := CloseSettings{Policy: ClosePolicyEndOfDay, Location: time.UTC}
+
+		assert.True(t, periodEnd.Equal(settings.CloseTime(periodEnd)))
+	})
+
+	t.Run("grace_should_close_after_grace_period", func(t *testing.T) {
+		settings := CloseSettings{Policy: ClosePolicyGrace, Location: time.UTC, GracePeriod: time.Hour}
+
+		assert.True(t, periodEnd.Add(time.Hour).Equal(settings.CloseTime(periodEnd)))
+	})
+
+	t.Run("bill_without_scheduled_close_should_close_at_period_end", func(t *testing.T) {
+		closeAt := periodEnd.Add(time.Hour)
+
+		assert.True(t, periodEnd.Equal((&Bill{PeriodEnd: periodEnd}).CloseTime()))
+		assert.True(t, closeAt.Equal((&Bill{PeriodEnd: periodEnd, ScheduledCloseAt: &closeAt}).CloseTime()))
+	})
+}
+
 func TestBill_CalculateSum(t *testing.T) {
+	totalPolicy := RoundingPolicy{Mode: RoundingModeHalfUp, Level: RoundingLevelTotal}
+	linePolicy := RoundingPolicy{Mode: RoundingModeHalfUp, Level: RoundingLevelLine}
+
 	t.Run("with single currency line items", func(t *testing.T) {
 		bill := &Bill{
+			PresentmentCurrency: USD,
 			LineItems: []*LineItem{
 				{
 					ID:          uuid.Must(uuid.NewV4()),
@@ -260,31 +714,37 @@ func TestBill_CalculateSum(t *testing.T) {
 			UpdatedAt: time.Now(),
 		}
 
-		err := bill.CalculateSum(rates)
+		err := bill.CalculateSum(rates, totalPolicy)
 
 		assert.NoError(t, err)
 		assert.Equal(t, decimal.NewFromFloat(35.00), bill.Total.ByCurrency[USD])
-		assert.Equal(t, decimal.NewFromFloat(35.00), bill.Total.Converted[USD].Amount)
+		assert.Equal(t, USD, bill.Total.GrandTotal.Currency)
+		assert.True(t, decimal.NewFromFloat(35.00).Equal(bill.Total.GrandTotal.Amount))
+		assert.True(t, decimal.NewFromInt(1).Equal(bill.LineItems[0].Converted.Rate))
+		assert.True(t, decimal.NewFromFloat(20.00).Equal(bill.LineItems[0].Converted.Amount))
 	})
 
 	t.Run("with multiple currency line items", func(t *testing.T) {
-		bill := &Bill{
-			LineItems: []*LineItem{
-				{
-					ID:          uuid.Must(uuid.NewV4()),
-					Description: "USD Item",
-					Currency:    USD,
-					Quantity:    decimal.NewFromFloat(1.0),
-					UnitPrice:   decimal.NewFromFloat(10.00),
-				},
-				{
-					ID:          uuid.Must(uuid.NewV4()),
-					Description: "GEL Item",
-					Currency:    GEL,
-					Quantity:    decimal.NewFromFloat(2.0),
-					UnitPrice:   decimal.NewFromFloat(5.00),
+		newBill := func(presentment Currency) *Bill {
+			return &Bill{
+				PresentmentCurrency: presentment,
+				LineItems: []*LineItem{
+					{
+						ID:          uuid.Must(uuid.NewV4()),
+						Description: "USD Item",
+						Currency:    USD,
+						Quantity:    decimal.NewFromFloat(1.0),
+						UnitPrice:   decimal.NewFromFloat(10.00),
+					},
+					{
+						ID:          uuid.Must(uuid.NewV4()),
+						Description: "GEL Item",
+						Currency:    GEL,
+						Quantity:    decimal.NewFromFloat(2.0),
+						UnitPrice:   decimal.NewFromFloat(5.00),
+					},
 				},
-			},
+			}
 		}
 
 		// Mock rates data
@@ -296,24 +756,136 @@ func TestBill_CalculateSum(t *testing.T) {
 			UpdatedAt: time.Now(),
 		}
 
-		err := bill.CalculateSum(rates)
+		t.Run("presented in USD", func(t *testing.T) {
+			bill := newBill(USD)
 
-		assert.NoError(t, err)
-		assert.True(t, decimal.NewFromFloat(10.00).Equal(bill.Total.ByCurrency[USD]))
-		assert.True(t, decimal.NewFromFloat(10.00).Equal(bill.Total.ByCurrency[GEL]))
+			err := bill.CalculateSum(rates, totalPolicy)
+
+			assert.NoError(t, err)
+			assert.True(t, decimal.NewFromFloat(10.00).Equal(bill.Total.ByCurrency[USD]))
+			assert.True(t, decimal.NewFromFloat(10.00).Equal(bill.Total.ByCurrency[GEL]))
+
+			// Check per-line conversion
+			gelLine := bill.LineItems[1].Converted
+			assert.Equal(t, USD, gelLine.Currency)
+			assert.True(t, decimal.NewFromFloat(0.4).Equal(gelLine.Rate))
+			assert.True(t, decimal.NewFromFloat(4).Equal(gelLine.Amount))
+
+			// Check grand total
+			assert.Equal(t, rates.UpdatedAt, bill.Total.GrandTotal.RateUpdatedAt)
+			assert.True(t, decimal.NewFromFloat(14).Equal(bill.Total.GrandTotal.Amount))
+		})
+
+		t.Run("presented in GEL", func(t *testing.T) {
+			bill := newBill(GEL)
+
+			err := bill.CalculateSum(rates, totalPolicy)
+
+			assert.NoError(t, err)
+			assert.True(t, decimal.NewFromFloat(2.5).Equal(bill.LineItems[0].Converted.Rate))
+			assert.True(t, decimal.NewFromFloat(25).Equal(bill.LineItems[0].Converted.Amount))
+			assert.Equal(t, GEL, bill.Total.GrandTotal.Currency)
+			assert.True(t, decimal.NewFromFloat(35).Equal(bill.Total.GrandTotal.Amount))
+		})
+	})
+
+	t.Run("with rounding level", func(t *testing.T) {
+		// Three lines of 0.005 USD each, i.e. 0.0125 GEL each at 2.5
+		newBill := func() *Bill {
+			bill := &Bill{PresentmentCurrency: GEL}
+			for i := 0; i < 3; i++ {
+				bill.LineItems = append(bill.LineItems, &LineItem{
+					ID:          uuid.Must(uuid.NewV4()),
+					Description: "Metered usage",
+					Currency:    USD,
+					Quantity:    decimal.RequireFromString("0.5"),
+					UnitPrice:   decimal.RequireFromString("0.01"),
+				})
+			}
+			return bill
+		}
+		rates := &RatesData{
+			Rates: map[string]float64{
+				"USD": 1.0,
+				"GEL": 2.5,
+			},
+			UpdatedAt: time.Now(),
+		}
+
+		t.Run("at total should round the summed amounts once", func(t *testing.T) {
+			bill := newBill()
+
+			err := bill.CalculateSum(rates, totalPolicy)
+
+			assert.NoError(t, err)
+			assert.True(t, decimal.RequireFromString("0.005").Equal(bill.LineItems[0].Total))
+			assert.True(t, decimal.RequireFromString("0.0125").Equal(bill.LineItems[0].Converted.Amount))
+			// 0.015 rounded to 0.02
+			assert.True(t, decimal.RequireFromString("0.02").Equal(bill.Total.ByCurrency[USD]))
+			// 0.0375 rounded to 0.04
+			assert.True(t, decimal.RequireFromString("0.04").Equal(bill.Total.GrandTotal.Amount))
+		})
+
+		t.Run("at line should round each line before summing", func(t *testing.T) {
+			bill := newBill()
+
+			err := bill.CalculateSum(rates, linePolicy)
+
+			assert.NoError(t, err)
+			// 0.005 rounded to 0.01, converted to 0.025 rounded to 0.03
+			assert.True(t, decimal.RequireFromString("0.01").Equal(bill.LineItems[0].Total))
+			assert.True(t, decimal.RequireFromString("0.03").Equal(bill.LineItems[0].Converted.Amount))
+			assert.True(t, decimal.RequireFromString("0.03").Equal(bill.Total.ByCurrency[USD]))
+			assert.True(t, decimal.RequireFromString("0.09").Equal(bill.Total.GrandTotal.Amount))
+		})
+
+		t.Run("at line with banker's rounding", func(t *testing.T) {
+			bill := newBill()
+
+			err := bill.CalculateSum(rates, RoundingPolicy{Mode: RoundingModeHalfEven, Level: RoundingLevelLine})
+
+			assert.NoError(t, err)
+			// 0.005 rounded to 0.00, so nothing is billed
+			assert.True(t, decimal.Zero.Equal(bill.LineItems[0].Total))
+			assert.True(t, decimal.Zero.Equal(bill.Total.GrandTotal.Amount))
+		})
+
+		t.Run("at total with truncation", func(t *testing.T) {
+			bill := newBill()
+
+			err := bill.CalculateSum(rates, RoundingPolicy{Mode: RoundingModeTruncate, Level: RoundingLevelTotal})
+
+			assert.NoError(t, err)
+			// 0.015 truncated to 0.01 and 0.0375 truncated to 0.03
+			assert.True(t, decimal.RequireFromString("0.01").Equal(bill.Total.ByCurrency[USD]))
+			assert.True(t, decimal.RequireFromString("0.03").Equal(bill.Total.GrandTotal.Amount))
+		})
+	})
+
+	t.Run("without presentment currency", func(t *testing.T) {
+		bill := &Bill{
+			LineItems: []*LineItem{
+				{
+					ID:          uuid.Must(uuid.NewV4()),
+					Description: "GEL Item",
+					Currency:    GEL,
+					Quantity:    decimal.NewFromFloat(1.0),
+					UnitPrice:   decimal.NewFromFloat(10.00),
+				},
+			},
+		}
 
-		// Check converted totals
-		usdConverted := bill.Total.Converted[USD]
-		assert.Equal(t, rates.UpdatedAt, usdConverted.RateUpdatedAt)
-		assert.True(t, decimal.NewFromFloat(14).Equal(usdConverted.Amount))
+		err := bill.CalculateSum(&RatesData{}, totalPolicy)
 
-		gelConverted := bill.Total.Converted[GEL]
-		assert.Equal(t, rates.UpdatedAt, gelConverted.RateUpdatedAt)
-		assert.True(t, decimal.NewFromFloat(35).Equal(gelConverted.Amount))
+		assert.NoError(t, err)
+		assert.True(t, decimal.NewFromFloat(10.00).Equal(bill.Total.ByCurrency[GEL]))
+		assert.Nil(t, bill.Total.GrandTotal)
+		assert.Nil(t, bill.LineItems[0].Converted)
 	})
 
 	t.Run("with missing currency rates", func(t *testing.T) {
 		bill := &Bill{
+			PresentmentCurrency: GEL,
 			LineItems: []*LineItem{
 				{
 					ID:          uuid.Must(uuid.NewV4()),
@@ -340,20 +912,74 @@ func TestBill_CalculateSum(t *testing.T) {
 			UpdatedAt: time.Now(),
 		}
 
-		err := bill.CalculateSum(rates)
+		err := bill.CalculateSum(rates, totalPolicy)
 
 		assert.Error(t, err)
 		assert.Equal(t, ErrCurrencyNotFound, err)
 	})
 
-	t.Run("with zero quantity and price", func(t *testing.T) {
-		bill := &Bill{
-			LineItems: []*LineItem{
-				{
-					ID:          uuid.Must(uuid.NewV4()),
-					Description: "Zero Item",
-					Currency:    USD,
-					Quantity:    decimal.Zero,
+	t.Run("with zero decimal currency", func(t *testing.T) {
+		newBill := func(presentment Currency) *Bill {
+			return &Bill{
+				PresentmentCurrency: presentment,
+				LineItems: []*LineItem{
+					{
+						ID:          uuid.Must(uuid.NewV4()),
+						Description: "JPY Item",
+						Currency:    JPY,
+						Quantity:    decimal.NewFromFloat(1.5),
+						UnitPrice:   decimal.NewFromInt(333),
+					},
+					{
+						ID:          uuid.Must(uuid.NewV4()),
+						Description: "USD Item",
+						Currency:    USD,
+						Quantity:    decimal.NewFromFloat(1.0),
+						UnitPrice:   decimal.NewFromFloat(10.00),
+					},
+				},
+			}
+		}
+
+		rates := &RatesData{
+			Rates: map[string]float64{
+				"USD": 1.0,
+				"JPY": 150.0,
+			},
+			UpdatedAt: time.Now(),
+		}
+
+		t.Run("presented in JPY", func(t *testing.T) {
+			bill := newBill(JPY)
+
+			err := bill.CalculateSum(rates, totalPolicy)
+
+			assert.NoError(t, err)
+			// 1.5 * 333 = 499.5 is rounded to whole yen
+			assert.True(t, decimal.NewFromInt(500).Equal(bill.Total.ByCurrency[JPY]))
+			// 499.5 + 10 USD * 150 = 1999.5 rounded to whole yen
+			assert.True(t, decimal.NewFromInt(2000).Equal(bill.Total.GrandTotal.Amount))
+		})
+
+		t.Run("presented in USD", func(t *testing.T) {
+			bill := newBill(USD)
+
+			err := bill.CalculateSum(rates, totalPolicy)
+
+			assert.NoError(t, err)
+			// 10 + 499.5 JPY / 150 = 13.33 USD rounded to cents
+			assert.True(t, decimal.RequireFromString("13.33").Equal(bill.Total.GrandTotal.Amount))
+		})
+	})
+
+	t.Run("with zero quantity and price", func(t *testing.T) {
+		bill := &Bill{
+			LineItems: []*LineItem{
+				{
+					ID:          uuid.Must(uuid.NewV4()),
+					Description: "Zero Item",
+					Currency:    USD,
+					Quantity:    decimal.Zero,
 					UnitPrice:   decimal.Zero,
 				},
 			},
@@ -366,7 +992,7 @@ func TestBill_CalculateSum(t *testing.T) {
 			UpdatedAt: time.Now(),
 		}
 
-		err := bill.CalculateSum(rates)
+		err := bill.CalculateSum(rates, totalPolicy)
 
 		assert.NoError(t, err)
 		assert.True(t, decimal.Zero.Equal(bill.Total.ByCurrency[USD]))
@@ -384,10 +1010,621 @@ func TestBill_CalculateSum(t *testing.T) {
 			UpdatedAt: time.Now(),
 		}
 
-		err := bill.CalculateSum(rates)
+		err := bill.CalculateSum(rates, totalPolicy)
+
+		assert.NoError(t, err)
+		assert.Nil(t, bill.Total)
+	})
+}
+
+func TestBill_AuditTotals(t *testing.T) {
+	policy := RoundingPolicy{Mode: RoundingModeHalfUp, Level: RoundingLevelTotal}
+	rates := &RatesData{
+		Rates: map[string]float64{
+			"USD": 1.0,
+			"GEL": 2.5,
+		},
+		UpdatedAt: time.Now(),
+	}
+	newClosedBill := func() *Bill {
+		bill := &Bill{
+			ID:                  uuid.Must(uuid.NewV4()),
+			Status:              BillStatusOpen,
+			PresentmentCurrency: USD,
+			LineItems: []*LineItem{
+				{
+					ID:        uuid.Must(uuid.NewV4()),
+					Currency:  USD,
+					Quantity:  decimal.NewFromInt(2),
+					UnitPrice: decimal.NewFromFloat(10.25),
+				},
+				{
+					ID:        uuid.Must(uuid.NewV4()),
+					Currency:  GEL,
+					Quantity:  decimal.NewFromInt(1),
+					UnitPrice: decimal.NewFromInt(25),
+				},
+			},
+		}
+		assert.NoError(t, bill.CalculateSum(rates, policy))
+		bill.Close(time.Now())
+		return bill
+	}
+
+	t.Run("should_match_when_totals_are_unchanged", func(t *testing.T) {
+		bill := newClosedBill()
+
+		audit, err := bill.AuditTotals(rates, policy)
+
+		assert.NoError(t, err)
+		assert.True(t, audit.Matches)
+		assert.Empty(t, audit.Discrepancies)
+		assert.Equal(t, bill.ID, audit.BillID)
+	})
+
+	t.Run("should_report_discrepancies_when_rates_changed", func(t *testing.T) {
+		bill := newClosedBill()
+		newRates := &RatesData{
+			Rates: map[string]float64{
+				"USD": 1.0,
+				"GEL": 2.0,
+			},
+			UpdatedAt: time.Now(),
+		}
+
+		audit, err := bill.AuditTotals(newRates, policy)
+
+		assert.NoError(t, err)
+		assert.False(t, audit.Matches)
+		assert.Len(t, audit.Discrepancies, 1)
+		assert.Equal(t, "grand_total", audit.Discrepancies[0].Field)
+		assert.True(t, decimal.NewFromFloat(30.5).Equal(audit.Discrepancies[0].Stored))
+		assert.True(t, decimal.NewFromFloat(33).Equal(audit.Discrepancies[0].Recomputed))
+	})
+
+	t.Run("should_not_modify_the_bill", func(t *testing.T) {
+		bill := newClosedBill()
+		stored := bill.Total
+		bill.LineItems[0].Total = decimal.NewFromInt(1)
+
+		audit, err := bill.AuditTotals(rates, policy)
 
 		assert.NoError(t, err)
+		assert.False(t, audit.Matches)
+		assert.Same(t, stored, bill.Total)
+		assert.True(t, decimal.NewFromInt(1).Equal(bill.LineItems[0].Total))
+		assert.Equal(t, "line_items."+bill.LineItems[0].ID.String()+".total", audit.Discrepancies[0].Field)
+	})
+}
+
+func TestBill_CalculateSummary(t *testing.T) {
+	rates := &RatesData{
+		Rates: map[string]float64{
+			"USD": 1.0,
+			"GEL": 2.7,
+		},
+		UpdatedAt: time.Now(),
+	}
+	newBill := func() *Bill {
+		bill := &Bill{ID: uuid.Must(uuid.NewV4()), Status: BillStatusOpen, PresentmentCurrency: USD}
+		for _, item := range []struct {
+			currency Currency
+			quantity string
+			price    string
+		}{
+			{USD, "3", "0.335"},
+			{USD, "3", "0.335"},
+			{GEL, "1", "10.01"},
+			{GEL, "1", "10.01"},
+			{GEL, "2", "3.33"},
+		} {
+			bill.LineItems = append(bill.LineItems, &LineItem{
+				ID:        uuid.Must(uuid.NewV4()),
+				Currency:  item.currency,
+				Quantity:  decimal.RequireFromString(item.quantity),
+				UnitPrice: decimal.RequireFromString(item.price),
+			})
+		}
+		return bill
+	}
+
+	for _, level := range []RoundingLevel{RoundingLevelLine, RoundingLevelTotal} {
+		t.Run("should_match_calculate_sum_at_"+string(level)+"_level", func(t *testing.T) {
+			policy := RoundingPolicy{Mode: RoundingModeHalfEven, Level: level}
+			full := newBill()
+			assert.NoError(t, full.CalculateSum(rates, policy))
+
+			summary := newBill()
+			groups := GroupLineTotals(summary.LineItems)
+			summary.LineItems = nil
+			assert.NoError(t, summary.CalculateSummary(groups, rates, policy))
+
+			assert.Equal(t, int64(5), summary.LineItemCount)
+			assert.Equal(t, full.LineItemCount, summary.LineItemCount)
+			for currency, amount := range full.Total.ByCurrency {
+				assert.True(t, amount.Equal(summary.Total.ByCurrency[currency]), "currency %s: %s != %s", currency, amount, summary.Total.ByCurrency[currency])
+			}
+			assert.True(t, full.Total.GrandTotal.Amount.Equal(summary.Total.GrandTotal.Amount),
+				"%s != %s", full.Total.GrandTotal.Amount, summary.Total.GrandTotal.Amount)
+		})
+	}
+
+	t.Run("should_group_lines_per_currency_and_amount", func(t *testing.T) {
+		groups := GroupLineTotals(newBill().LineItems)
+
+		assert.Len(t, groups, 3)
+		assert.Equal(t, int64(2), groups[0].Count)
+		assert.True(t, decimal.RequireFromString("2.01").Equal(groups[0].Sum))
+	})
+
+	t.Run("should_leave_totals_empty_without_groups", func(t *testing.T) {
+		bill := &Bill{Status: BillStatusOpen, PresentmentCurrency: USD}
+
+		assert.NoError(t, bill.CalculateSummary([]LineTotalGroup{}, rates, RoundingPolicy{}))
 		assert.Nil(t, bill.Total)
+		assert.Zero(t, bill.LineItemCount)
+	})
+}
+
+func TestBill_EditLineItems(t *testing.T) {
+	newBill := func() (*Bill, *LineItem) {
+		item := &LineItem{
+			ID:          uuid.Must(uuid.NewV4()),
+			Description: "Service A",
+			Currency:    USD,
+			Quantity:    decimal.NewFromInt(2),
+			UnitPrice:   decimal.NewFromInt(10),
+			Total:       decimal.NewFromInt(20),
+		}
+		return &Bill{Status: BillStatusOpen, LineItems: []*LineItem{item}}, item
+	}
+
+	t.Run("should_remove_line_item_from_open_bill", func(t *testing.T) {
+		bill, item := newBill()
+
+		removed, err := bill.RemoveLineItem(item.ID)
+
+		assert.NoError(t, err)
+		assert.Equal(t, item, removed)
+		assert.Empty(t, bill.LineItems)
+	})
+
+	t.Run("should_reject_edits_on_closed_bill", func(t *testing.T) {
+		bill, item := newBill()
+		bill.Close(time.Now())
+
+		_, err := bill.RemoveLineItem(item.ID)
+		assert.Equal(t, ErrBillClosed, err)
+		assert.Equal(t, ErrBillClosed, bill.UpdateLineItem(*item))
+		assert.Len(t, bill.LineItems, 1)
+	})
+
+	t.Run("should_return_not_found_for_unknown_line_item", func(t *testing.T) {
+		bill, item := newBill()
+		unknown := *item
+		unknown.ID = uuid.Must(uuid.NewV4())
+
+		_, err := bill.RemoveLineItem(unknown.ID)
+		assert.Equal(t, ErrLineItemNotFound, err)
+		assert.Equal(t, ErrLineItemNotFound, bill.UpdateLineItem(unknown))
+		assert.Nil(t, bill.FindLineItem(unknown.ID))
+	})
+
+	t.Run("should_apply_update_and_reset_computed_amounts", func(t *testing.T) {
+		bill, item := newBill()
+		at := time.Now()
+		quantity := decimal.NewFromInt(5)
+
+		updated := item.Apply(LineItemUpdate{Quantity: &quantity}, at)
+
+		assert.NoError(t, bill.UpdateLineItem(updated))
+		assert.True(t, quantity.Equal(bill.LineItems[0].Quantity))
+		assert.Equal(t, "Service A", bill.LineItems[0].Description)
+		assert.Equal(t, &at, bill.LineItems[0].UpdatedAt)
+		assert.True(t, bill.LineItems[0].Total.IsZero())
+		assert.True(t, decimal.NewFromInt(2).Equal(item.Quantity), "original line item must be left untouched")
+	})
+
+	t.Run("should_remove_line_from_line_totals", func(t *testing.T) {
+		_, item := newBill()
+		groups := GroupLineTotals([]*LineItem{item, item})
+
+		groups = RemoveLineTotal(groups, item)
+		assert.Len(t, groups, 1)
+		assert.Equal(t, int64(1), groups[0].Count)
+		assert.True(t, decimal.NewFromInt(20).Equal(groups[0].Sum))
+
+		assert.Empty(t, RemoveLineTotal(groups, item))
+	})
+}
+
+func TestSumByCurrency(t *testing.T) {
+	t.Run("should_sum_groups_of_each_currency", func(t *testing.T) {
+		totals := SumByCurrency([]LineTotalGroup{
+			{Currency: USD, LineAmount: decimal.NewFromInt(2), Sum: decimal.NewFromInt(4), Count: 2},
+			{Currency: USD, LineAmount: decimal.RequireFromString("0.5"), Sum: decimal.RequireFromString("0.5"), Count: 1},
+			{Currency: GEL, LineAmount: decimal.NewFromInt(3), Sum: decimal.NewFromInt(3), Count: 1},
+		})
+
+		assert.Len(t, totals, 2)
+		assert.True(t, decimal.RequireFromString("4.5").Equal(totals[USD]))
+		assert.True(t, decimal.NewFromInt(3).Equal(totals[GEL]))
+	})
+}
+
+func TestLineItemCursor(t *testing.T) {
+	t.Run("should_round_trip_through_encoding", func(t *testing.T) {
+		item := &LineItem{ID: uuid.Must(uuid.NewV4()), CreatedAt: time.Now()}
+
+		cursor, err := DecodeLineItemCursor(NewLineItemCursor(item).Encode())
+
+		assert.NoError(t, err)
+		assert.Equal(t, item.ID, cursor.ID)
+		assert.True(t, item.CreatedAt.Equal(cursor.CreatedAt))
+	})
+
+	t.Run("should_reject_malformed_cursor", func(t *testing.T) {
+		for _, s := range []string{"!!", "bm8tc2VwYXJhdG9y", "bm90LWEtdGltZXxub3QtYS11dWlk"} {
+			_, err := DecodeLineItemCursor(s)
+			assert.Equal(t, ErrInvalidCursor, err, s)
+		}
+	})
+}
+
+func TestDiffBill(t *testing.T) {
+	newBills := func() (*Bill, *Bill, *LineItem) {
+		billID := uuid.Must(uuid.NewV4())
+		item := &LineItem{
+			ID:          uuid.Must(uuid.NewV4()),
+			BillID:      billID,
+			Description: "Service A",
+			Currency:    USD,
+			Quantity:    decimal.NewFromInt(2),
+			UnitPrice:   decimal.NewFromInt(10),
+		}
+		stored := *item
+		workflowBill := &Bill{ID: billID, Status: BillStatusOpen, LineItems: []*LineItem{item}, LineItemCount: 3}
+		storedBill := &Bill{ID: billID, Status: BillStatusOpen, LineItems: []*LineItem{&stored}, LineItemCount: 3}
+		return workflowBill, storedBill, item
+	}
+
+	t.Run("should_report_nothing_when_in_sync", func(t *testing.T) {
+		workflowBill, stored, _ := newBills()
+
+		assert.Empty(t, DiffBill(workflowBill, stored))
+	})
+
+	t.Run("should_report_missing_line_item_without_count_mismatch", func(t *testing.T) {
+		workflowBill, stored, _ := newBills()
+		missing := &LineItem{ID: uuid.Must(uuid.NewV4()), BillID: stored.ID, Currency: USD}
+		workflowBill.LineItems = append(workflowBill.LineItems, missing)
+		workflowBill.LineItemCount++
+
+		discrepancies := DiffBill(workflowBill, stored)
+
+		require.Len(t, discrepancies, 1)
+		assert.Equal(t, DiscrepancyMissingLineItem, discrepancies[0].Kind)
+		assert.Equal(t, missing.ID, *discrepancies[0].LineItemID)
+		assert.Same(t, missing, discrepancies[0].LineItem)
+	})
+
+	t.Run("should_report_line_item_mismatch", func(t *testing.T) {
+		workflowBill, stored, item := newBills()
+		item.Quantity = decimal.NewFromInt(5)
+
+		discrepancies := DiffBill(workflowBill, stored)
+
+		require.Len(t, discrepancies, 1)
+		assert.Equal(t, DiscrepancyLineItemMismatch, discrepancies[0].Kind)
+		assert.Equal(t, "Service A: 5 x 10 USD", discrepancies[0].Workflow)
+		assert.Equal(t, "Service A: 2 x 10 USD", discrepancies[0].Database)
+	})
+
+	t.Run("should_report_count_mismatch_for_line_items_of_previous_runs", func(t *testing.T) {
+		workflowBill, stored, _ := newBills()
+		stored.LineItemCount = 2
+
+		discrepancies := DiffBill(workflowBill, stored)
+
+		require.Len(t, discrepancies, 1)
+		assert.Equal(t, DiscrepancyLineItemCount, discrepancies[0].Kind)
+		assert.Equal(t, "3", discrepancies[0].Workflow)
+		assert.Equal(t, "2", discrepancies[0].Database)
+	})
+
+	t.Run("should_report_status_mismatch_after_line_items", func(t *testing.T) {
+		workflowBill, stored, item := newBills()
+		workflowBill.Status = BillStatusClosed
+		item.Description = "Service B"
+
+		discrepancies := DiffBill(workflowBill, stored)
+
+		require.Len(t, discrepancies, 2)
+		assert.Equal(t, DiscrepancyLineItemMismatch, discrepancies[0].Kind)
+		assert.Equal(t, DiscrepancyStatusMismatch, discrepancies[1].Kind)
+		assert.Equal(t, "closed", discrepancies[1].Workflow)
+		assert.Equal(t, "open", discrepancies[1].Database)
+	})
+}
+
+func TestVerifyAuditChain(t *testing.T) {
+	newChain := func(t *testing.T) []*AuditEvent {
+		billID := uuid.Must(uuid.NewV4())
+		lineItemID := uuid.Must(uuid.NewV4())
+		ctx := WithAuditMetadata(context.Background(), AuditMetadata{Actor: "admin", RequestID: "req-1"})
+		start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+		created, err := NewAuditEvent(context.Background(), nil, billID, AuditEntityBill, billID,
+			AuditActionBillCreated, nil, json.RawMessage(`{"status": "draft"}`), start)
+		require.NoError(t, err)
+		added, err := NewAuditEvent(ctx, created, billID, AuditEntityLineItem, lineItemID,
+			AuditActionLineItemAdded, nil, json.RawMessage(`{"quantity": 2}`), start.Add(time.Minute))
+		require.NoError(t, err)
+		updated, err := NewAuditEvent(ctx, added, billID, AuditEntityLineItem, lineItemID,
+			AuditActionLineItemUpdated, json.RawMessage(`{"quantity": 2}`), json.RawMessage(`{"quantity": 3}`), start.Add(2*time.Minute))
+		require.NoError(t, err)
+		return []*AuditEvent{created, added, updated}
+	}
+
+	t.Run("should_chain_events_with_audit_metadata", func(t *testing.T) {
+		events := newChain(t)
+
+		assert.NoError(t, VerifyAuditChain(events))
+		assert.Equal(t, SystemActor, events[0].Actor)
+		assert.Equal(t, "null", string(events[0].Before))
+		assert.Empty(t, events[0].PrevHash)
+		assert.Equal(t, int64(2), events[1].Sequence)
+		assert.Equal(t, events[0].Hash, events[1].PrevHash)
+		assert.Equal(t, "admin", events[1].Actor)
+		assert.Equal(t, "req-1", events[1].RequestID)
+	})
+
+	t.Run("should_verify_events_read_back_with_other_whitespace", func(t *testing.T) {
+		events := newChain(t)
+		events[2].After = json.RawMessage(`{ "quantity" : 3 }`)
+
+		assert.NoError(t, VerifyAuditChain(events))
+	})
+
+	t.Run("should_detect_altered_event", func(t *testing.T) {
+		events := newChain(t)
+		events[1].After = json.RawMessage(`{"quantity": 20}`)
+
+		assert.EqualError(t, VerifyAuditChain(events), "event 2 was altered")
+	})
+
+	t.Run("should_detect_altered_actor", func(t *testing.T) {
+		events := newChain(t)
+		events[2].Actor = "someone"
+
+		assert.EqualError(t, VerifyAuditChain(events), "event 3 was altered")
+	})
+
+	t.Run("should_detect_removed_event", func(t *testing.T) {
+		events := newChain(t)
+
+		assert.EqualError(t, VerifyAuditChain([]*AuditEvent{events[0], events[2]}), "event 2 is missing")
+	})
+
+	t.Run("should_detect_rehashed_event_breaking_the_chain", func(t *testing.T) {
+		events := newChain(t)
+		events[1].After = json.RawMessage(`{"quantity": 20}`)
+		hash, err := events[1].ComputeHash()
+		require.NoError(t, err)
+		events[1].Hash = hash
+
+		assert.EqualError(t, VerifyAuditChain(events), "event 3 does not follow event 2")
+	})
+
+	t.Run("should_report_verification_in_history", func(t *testing.T) {
+		events := newChain(t)
+		events[0].Actor = "someone"
+
+		history := NewBillHistory(events[0].BillID, events)
+
+		assert.False(t, history.Verified)
+		assert.Equal(t, "event 1 was altered", history.VerificationError)
+		assert.True(t, NewBillHistory(events[0].BillID, newChain(t)).Verified)
+	})
+}
+
+func TestLedgerJournals(t *testing.T) {
+	settings := LedgerSettings{
+		FunctionalCurrency: USD,
+		Accounts: LedgerAccounts{
+			AccountsReceivable: "1200", Revenue: "4000", TaxPayable: "2200", Cash: "1000", FXGain: "7100", FXLoss: "7200",
+		},
+		RoundingMode: RoundingModeHalfUp,
+	}
+	taxed := settings
+	taxed.TaxPercent = decimal.NewFromInt(18)
+	rates := func(gel float64) *RatesData {
+		return &RatesData{Rates: map[string]float64{"USD": 1, "GEL": gel}}
+	}
+	closedAt := time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC)
+	// closeBill books 100 GEL and 30 USD, the GEL at 0.4 USD
+	closeBill := func(t *testing.T) []*Journal {
+		bill := &Bill{ID: uuid.Must(uuid.NewV4()), Total: &Total{ByCurrency: map[Currency]decimal.Decimal{
+			GEL: decimal.NewFromInt(100),
+			USD: decimal.NewFromInt(30),
+		}}}
+		journal, err := NewBillClosedJournal(bill, closedAt, rates(2.5), settings)
+		require.NoError(t, err)
+		return []*Journal{journal}
+	}
+
+	t.Run("bill closed journal debits receivable and credits revenue per currency", func(t *testing.T) {
+		ledger := closeBill(t)
+
+		require.NoError(t, ledger[0].Validate())
+		assert.Equal(t, JournalBillClosed, ledger[0].Type)
+		require.Len(t, ledger[0].Entries, 4)
+		gel, gelUSD := LedgerBalance(ledger, "1200", GEL)
+		assert.True(t, decimal.NewFromInt(100).Equal(gel))
+		assert.True(t, decimal.NewFromInt(40).Equal(gelUSD))
+		revenue, revenueUSD := LedgerBalance(ledger, "4000", USD)
+		assert.True(t, decimal.NewFromInt(-30).Equal(revenue))
+		assert.True(t, decimal.NewFromInt(-30).Equal(revenueUSD))
+	})
+
+	t.Run("bill closed journal credits tax payable with the vat of each currency", func(t *testing.T) {
+		bill := &Bill{ID: uuid.Must(uuid.NewV4()), Total: &Total{ByCurrency: map[Currency]decimal.Decimal{
+			GEL: decimal.NewFromInt(100),
+			USD: decimal.RequireFromString("30.05"),
+		}}}
+
+		journal, err := NewBillClosedJournal(bill, closedAt, rates(2.5), taxed)
+
+		require.NoError(t, err)
+		require.NoError(t, journal.Validate())
+		require.Len(t, journal.Entries, 8)
+		ledger := []*Journal{journal}
+		gel, gelUSD := LedgerBalance(ledger, "1200", GEL)
+		assert.True(t, decimal.NewFromInt(118).Equal(gel), gel.String())
+		assert.True(t, decimal.RequireFromString("47.2").Equal(gelUSD), gelUSD.String())
+		revenue, revenueUSD := LedgerBalance(ledger, "4000", GEL)
+		assert.True(t, decimal.NewFromInt(-100).Equal(revenue))
+		assert.True(t, decimal.NewFromInt(-40).Equal(revenueUSD))
+		tax, taxUSD := LedgerBalance(ledger, "2200", GEL)
+		assert.True(t, decimal.NewFromInt(-18).Equal(tax))
+		assert.True(t, decimal.RequireFromString("-7.2").Equal(taxUSD), taxUSD.String())
+		// 18% of 30.05 USD is 5.409, rounded to the cent
+		usdTax, _ := LedgerBalance(ledger, "2200", USD)
+		assert.True(t, decimal.RequireFromString("-5.41").Equal(usdTax), usdTax.String())
+	})
+
+	t.Run("bill closed without totals posts no journal", func(t *testing.T) {
+		journal, err := NewBillClosedJournal(&Bill{ID: uuid.Must(uuid.NewV4())}, closedAt, rates(2.5), settings)
+
+		assert.NoError(t, err)
+		assert.Nil(t, journal)
+	})
+
+	t.Run("payment at booked rate settles receivable without fx difference", func(t *testing.T) {
+		ledger := closeBill(t)
+
+		journals, err := NewPaymentJournals(ledger[0].BillID, ledger, Payment{
+			Reference: "TRF-1", Currency: GEL, Amount: decimal.NewFromInt(100), ReceivedAt: closedAt,
+		}, rates(2.5), settings)
+
+		require.NoError(t, err)
+		require.Len(t, journals, 1)
+		receivable, receivableUSD := LedgerBalance(append(ledger, journals...), "1200", GEL)
+		assert.True(t, receivable.IsZero())
+		assert.True(t, receivableUSD.IsZero())
+	})
+
+	t.Run("payment at lower rate posts fx loss", func(t *testing.T) {
+		ledger := closeBill(t)
+
+		journals, err := NewPaymentJournals(ledger[0].BillID, ledger, Payment{
+			Reference: "TRF-1", Currency: GEL, Amount: decimal.NewFromInt(50), ReceivedAt: closedAt,
+		}, rates(4), settings)
+
+		require.NoError(t, err)
+		require.Len(t, journals, 2)
+		for _, journal := range journals {
+			assert.NoError(t, journal.Validate())
+		}
+		assert.Equal(t, JournalFXDifference, journals[1].Type)
+		ledger = append(ledger, journals...)
+		// Half of the receivable booked at 40 USD, received at 12.50 USD
+		_, receivableUSD := LedgerBalance(ledger, "1200", GEL)
+		assert.True(t, decimal.NewFromInt(20).Equal(receivableUSD))
+		cash, cashUSD := LedgerBalance(ledger, "1000", GEL)
+		assert.True(t, decimal.NewFromInt(50).Equal(cash))
+		assert.True(t, decimal.RequireFromString("12.5").Equal(cashUSD), cashUSD.String())
+		_, loss := LedgerBalance(ledger, "7200", USD)
+		assert.True(t, decimal.RequireFromString("7.5").Equal(loss), loss.String())
+	})
+
+	t.Run("payment exceeding outstanding receivable fails", func(t *testing.T) {
+		ledger := closeBill(t)
+
+		_, err := NewPaymentJournals(ledger[0].BillID, ledger, Payment{
+			Reference: "TRF-1", Currency: GEL, Amount: decimal.NewFromInt(101), ReceivedAt: closedAt,
+		}, rates(2.5), settings)
+
+		assert.Equal(t, ErrAmountExceedsBalance, err)
+	})
+
+	t.Run("credit note reduces revenue and receivable at booked rate", func(t *testing.T) {
+		ledger := closeBill(t)
+
+		journal, err := NewCreditNoteJournal(ledger[0].BillID, ledger, CreditNote{
+			Reference: "CN-1", Currency: GEL, Amount: decimal.NewFromInt(25), Reason: "outage", IssuedAt: closedAt,
+		}, settings)
+
+		require.NoError(t, err)
+		require.NoError(t, journal.Validate())
+		ledger = append(ledger, journal)
+		receivable, receivableUSD := LedgerBalance(ledger, "1200", GEL)
+		assert.True(t, decimal.NewFromInt(75).Equal(receivable))
+		assert.True(t, decimal.NewFromInt(30).Equal(receivableUSD))
+	})
+
+	t.Run("credit note credits receivable with its vat", func(t *testing.T) {
+		bill := &Bill{ID: uuid.Must(uuid.NewV4()), Total: &Total{ByCurrency: map[Currency]decimal.Decimal{
+			GEL: decimal.NewFromInt(100),
+		}}}
+		closed, err := NewBillClosedJournal(bill, closedAt, rates(2.5), taxed)
+		require.NoError(t, err)
+		ledger := []*Journal{closed}
+
+		journal, err := NewCreditNoteJournal(bill.ID, ledger, CreditNote{
+			Reference: "CN-1", Currency: GEL, Amount: decimal.NewFromInt(25), Reason: "outage", IssuedAt: closedAt,
+		}, taxed)
+
+		require.NoError(t, err)
+		require.NoError(t, journal.Validate())
+		assert.True(t, decimal.NewFromInt(25).Equal(journal.Entries[0].Amount))
+		ledger = append(ledger, journal)
+		receivable, receivableUSD := LedgerBalance(ledger, "1200", GEL)
+		assert.True(t, decimal.RequireFromString("88.5").Equal(receivable), receivable.String())
+		assert.True(t, decimal.RequireFromString("35.4").Equal(receivableUSD), receivableUSD.String())
+		tax, _ := LedgerBalance(ledger, "2200", GEL)
+		assert.True(t, decimal.RequireFromString("-13.5").Equal(tax), tax.String())
+
+		// The net amount of a credit note cannot exceed what is left once its vat is added
+		_, err = NewCreditNoteJournal(bill.ID, ledger, CreditNote{
+			Reference: "CN-2", Currency: GEL, Amount: decimal.NewFromInt(76), Reason: "outage", IssuedAt: closedAt,
+		}, taxed)
+		assert.Equal(t, ErrAmountExceedsBalance, err)
+	})
+
+	t.Run("reversal posts opposite entries", func(t *testing.T) {
+		ledger := closeBill(t)
+
+		reversal, err := ledger[0].Reverse(closedAt.Add(time.Hour))
+
+		require.NoError(t, err)
+		require.NoError(t, reversal.Validate())
+		assert.Equal(t, &ledger[0].ID, reversal.ReversesID)
+		for _, account := range []string{"1200", "4000"} {
+			for _, currency := range []Currency{GEL, USD} {
+				amount, functional := LedgerBalance(append(ledger, reversal), account, currency)
+				assert.True(t, amount.IsZero())
+				assert.True(t, functional.IsZero())
+			}
+		}
+	})
+
+	t.Run("unbalanced journal fails validation", func(t *testing.T) {
+		journal := closeBill(t)[0]
+		journal.Entries[0].FunctionalAmount = journal.Entries[0].FunctionalAmount.Add(decimal.NewFromInt(1))
+
+		assert.Error(t, journal.Validate())
+	})
+
+	t.Run("trial balance is balanced when debits equal credits", func(t *testing.T) {
+		lines := []TrialBalanceLine{
+			{Account: "1200", Currency: GEL, Debit: decimal.NewFromInt(100), FunctionalDebit: decimal.NewFromInt(40)},
+			{Account: "4000", Currency: GEL, Credit: decimal.NewFromInt(100), FunctionalCredit: decimal.NewFromInt(40)},
+		}
+
+		assert.True(t, NewTrialBalance(closedAt, USD, lines, nil).Balanced)
+		assert.False(t, NewTrialBalance(closedAt, USD, lines[:1], nil).Balanced)
+		assert.False(t, NewTrialBalance(closedAt, USD, lines, []uuid.UUID{uuid.Must(uuid.NewV4())}).Balanced)
 	})
 }
 
@@ -502,7 +1739,7 @@ func TestBill_EdgeCases(t *testing.T) {
 			UnitPrice:   decimal.NewFromFloat(10.00),
 		}
 
-		success := bill.AddLineItem(item)
+		success := bill.AddLineItem(item, time.Now())
 		assert.True(t, success)
 		assert.Len(t, bill.LineItems, 1)
 	})
@@ -528,7 +1765,7 @@ func TestBill_EdgeCases(t *testing.T) {
 			UpdatedAt: time.Now(),
 		}
 
-		err := bill.CalculateSum(rates)
+		err := bill.CalculateSum(rates, RoundingPolicy{Mode: RoundingModeHalfUp, Level: RoundingLevelTotal})
 		assert.NoError(t, err)
 
 		expectedTotal := decimal.NewFromFloat(1000000000000.0) // 1 trillion
@@ -556,10 +1793,452 @@ func TestBill_EdgeCases(t *testing.T) {
 			UpdatedAt: time.Now(),
 		}
 
-		err := bill.CalculateSum(rates)
+		err := bill.CalculateSum(rates, RoundingPolicy{Mode: RoundingModeHalfUp, Level: RoundingLevelTotal})
 		assert.NoError(t, err)
 
 		expectedTotal := decimal.NewFromFloat(-10.0)
 		assert.True(t, bill.Total.ByCurrency[USD].Equal(expectedTotal))
 	})
 }
+
+func TestMatchPayment(t *testing.T) {
+	billA := uuid.FromStringOrNil("6f1c1d0e-8a6b-4c39-9d1e-2b7a4f0c5e11")
+	billB := uuid.FromStringOrNil("6f1c1d0e-1111-4c39-9d1e-2b7a4f0c5e22")
+	billC := uuid.FromStringOrNil("0b7e4c52-3f0d-4a8e-bf61-7c9d2e5a1f33")
+	receivables := []Receivable{
+		{BillID: billA, CustomerID: "customer-1", Currency: USD, Outstanding: decimal.NewFromInt(100)},
+		{BillID: billB, CustomerID: "customer-1", Currency: USD, Outstanding: decimal.NewFromInt(99)},
+		{BillID: billC, CustomerID: "customer-2", Currency: USD, Outstanding: decimal.NewFromInt(250)},
+		{BillID: billC, CustomerID: "customer-2", Currency: GEL, Outstanding: decimal.NewFromInt(100)},
+	}
+	policy := MatchPolicy{AmountTolerancePercent: decimal.NewFromInt(2), MinReferencePrefix: 8}
+	credit := func(amount int64, currency Currency, reference string) StatementCredit {
+		return StatementCredit{Currency: currency, Amount: decimal.NewFromInt(amount), Reference: reference}
+	}
+
+	tests := []struct {
+		name       string
+		credit     StatementCredit
+		status     BankTransactionStatus
+		reason     MatchReason
+		candidates []uuid.UUID
+	}{
+		{
+			"full_id_and_outstanding_amount_matches",
+			credit(100, USD, "Invoice 6F1C1D0E-8A6B-4C39-9D1E-2B7A4F0C5E11 thanks"),
+			BankTransactionStatusMatched, MatchReasonExact, []uuid.UUID{billA},
+		},
+		{
+			"full_id_without_dashes_matches",
+			credit(250, USD, "0b7e4c523f0d4a8ebf617c9d2e5a1f33"),
+			BankTransactionStatusMatched, MatchReasonExact, []uuid.UUID{billC},
+		},
+		{
+			"full_id_with_other_amount_is_reviewed",
+			credit(60, USD, "bill 0b7e4c52-3f0d-4a8e-bf61-7c9d2e5a1f33 part 1"),
+			BankTransactionStatusReview, MatchReasonAmountMismatch, []uuid.UUID{billC},
+		},
+		{
+			"several_full_ids_are_reviewed",
+			credit(199, USD, "6f1c1d0e-8a6b-4c39-9d1e-2b7a4f0c5e11 6f1c1d0e-1111-4c39-9d1e-2b7a4f0c5e22"),
+			BankTransactionStatusReview, MatchReasonAmbiguousReference, []uuid.UUID{billA, billB},
+		},
+		{
+			"id_prefix_is_reviewed_closest_amount_first",
+			credit(99, USD, "INV 6f1c1d0e"),
+			BankTransactionStatusReview, MatchReasonPartialReference, []uuid.UUID{billB, billA},
+		},
+		{
+			"short_id_prefix_is_ignored",
+			credit(500, USD, "INV 6f1c1d"),
+			BankTransactionStatusReview, MatchReasonNoCandidates, []uuid.UUID{},
+		},
+		{
+			"customer_id_is_reviewed",
+			credit(10, USD, "payment from CUSTOMER-2"),
+			BankTransactionStatusReview, MatchReasonPartialReference, []uuid.UUID{billC},
+		},
+		{
+			"amount_within_tolerance_is_reviewed",
+			credit(98, USD, "monthly payment"),
+			BankTransactionStatusReview, MatchReasonAmountOnly, []uuid.UUID{billB, billA},
+		},
+		{
+			"other_currency_is_not_matched",
+			credit(100, JPY, "6f1c1d0e-8a6b-4c39-9d1e-2b7a4f0c5e11"),
+			BankTransactionStatusReview, MatchReasonNoCandidates, []uuid.UUID{},
+		},
+		{
+			"receivable_in_credit_currency_matches",
+			credit(100, GEL, "0b7e4c52-3f0d-4a8e-bf61-7c9d2e5a1f33"),
+			BankTransactionStatusMatched, MatchReasonExact, []uuid.UUID{billC},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match := MatchPayment(tt.credit, receivables, policy)
+
+			assert.Equal(t, tt.status, match.Status)
+			assert.Equal(t, tt.reason, match.Reason)
+			assert.Equal(t, tt.candidates, match.Candidates)
+			if tt.status == BankTransactionStatusMatched {
+				require.NotNil(t, match.BillID)
+				assert.Equal(t, tt.candidates[0], *match.BillID)
+			} else {
+				assert.Nil(t, match.BillID)
+			}
+		})
+	}
+
+	t.Run("ignores_settled_receivables", func(t *testing.T) {
+		settled := []Receivable{{BillID: billA, Currency: USD, Outstanding: decimal.Zero}}
+
+		match := MatchPayment(credit(100, USD, billA.String()), settled, policy)
+
+		assert.Equal(t, MatchReasonNoCandidates, match.Reason)
+	})
+}
+
+func TestAgingReport(t *testing.T) {
+	policy := RoundingPolicy{Mode: RoundingModeHalfUp, Level: RoundingLevelTotal}
+	rates := &RatesData{Rates: map[string]float64{"USD": 1, "GEL": 2.5}}
+	asOf := time.Date(2025, 6, 30, 12, 0, 0, 0, time.UTC)
+	closedDaysAgo := func(days int) time.Time {
+		return asOf.AddDate(0, 0, -days)
+	}
+
+	t.Run("should_bucket_by_whole_days_past_due", func(t *testing.T) {
+		assert.Equal(t, AgingCurrent, AgingBucketFor(-3))
+		assert.Equal(t, AgingCurrent, AgingBucketFor(0))
+		assert.Equal(t, Aging1To30, AgingBucketFor(1))
+		assert.Equal(t, Aging1To30, AgingBucketFor(30))
+		assert.Equal(t, Aging31To60, AgingBucketFor(31))
+		assert.Equal(t, Aging61To90, AgingBucketFor(90))
+		assert.Equal(t, AgingOver90, AgingBucketFor(91))
+
+		receivable := OpenReceivable{ClosedAt: closedDaysAgo(30).Add(time.Hour)}
+		assert.Equal(t, 0, receivable.DaysPastDue(asOf, 30))
+		receivable.ClosedAt = closedDaysAgo(31)
+		assert.Equal(t, 1, receivable.DaysPastDue(asOf, 30))
+	})
+
+	t.Run("should_group_per_customer_and_currency_and_convert_to_reporting_currency", func(t *testing.T) {
+		receivables := []OpenReceivable{
+			{BillID: uuid.Must(uuid.NewV4()), CustomerID: "cust-b", Currency: USD, ClosedAt: closedDaysAgo(10), Outstanding: decimal.NewFromInt(40)},
+			{BillID: uuid.Must(uuid.NewV4()), CustomerID: "cust-a", Currency: GEL, ClosedAt: closedDaysAgo(45), Outstanding: decimal.NewFromInt(25)},
+			{BillID: uuid.Must(uuid.NewV4()), CustomerID: "cust-a", Currency: GEL, ClosedAt: closedDaysAgo(200), Outstanding: decimal.NewFromInt(50)},
+			{BillID: uuid.Must(uuid.NewV4()), CustomerID: "cust-a", Currency: USD, ClosedAt: closedDaysAgo(70), Outstanding: decimal.RequireFromString("10.5")},
+		}
+
+		report, err := NewAgingReport(receivables, asOf, 30, USD, rates, policy)
+
+		require.NoError(t, err)
+		require.Len(t, report.Rows, 3)
+		gel := report.Rows[0]
+		assert.Equal(t, "cust-a", gel.CustomerID)
+		assert.Equal(t, GEL, gel.Currency)
+		assert.Equal(t, 2, gel.Bills)
+		assert.True(t, decimal.NewFromInt(25).Equal(gel.Amounts.Days1To30), gel.Amounts.Days1To30.String())
+		assert.True(t, decimal.NewFromInt(50).Equal(gel.Amounts.Over90), gel.Amounts.Over90.String())
+		assert.True(t, decimal.NewFromInt(75).Equal(gel.Amounts.Total), gel.Amounts.Total.String())
+		assert.True(t, decimal.NewFromInt(30).Equal(gel.Reporting.Total), gel.Reporting.Total.String())
+		assert.Equal(t, USD, report.Rows[1].Currency)
+		assert.True(t, decimal.RequireFromString("10.5").Equal(report.Rows[1].Amounts.Days31To60))
+		assert.Equal(t, "cust-b", report.Rows[2].CustomerID)
+		assert.True(t, decimal.NewFromInt(40).Equal(report.Rows[2].Amounts.Current))
+		assert.True(t, decimal.RequireFromString("80.5").Equal(report.Totals.Total), report.Totals.Total.String())
+	})
+
+	t.Run("when_rate_of_currency_is_missing_should_return_error", func(t *testing.T) {
+		receivables := []OpenReceivable{{CustomerID: "cust-a", Currency: JPY, ClosedAt: asOf, Outstanding: decimal.NewFromInt(1000)}}
+
+		report, err := NewAgingReport(receivables, asOf, 30, USD, rates, policy)
+
+		assert.Nil(t, report)
+		assert.Equal(t, ErrCurrencyNotFound, err)
+	})
+}
+
+func TestCustomerStatement(t *testing.T) {
+	policy := RoundingPolicy{Mode: RoundingModeHalfUp, Level: RoundingLevelTotal}
+	rates := &RatesData{Rates: map[string]float64{"USD": 1, "GEL": 2.5}}
+	from := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 6, 30, 23, 59, 59, 0, time.UTC)
+	oldBill, newBill := uuid.Must(uuid.NewV4()), uuid.Must(uuid.NewV4())
+
+	t.Run("should_carry_activity_before_period_into_opening_balance_and_run_balance", func(t *testing.T) {
+		activity := []ReceivableActivity{
+			{Type: ReceivableActivityPayment, BillID: newBill, Reference: "TRF-2", OccurredAt: from.AddDate(0, 0, 10), Currency: USD, Amount: decimal.NewFromInt(30)},
+			{Type: ReceivableActivityBill, BillID: oldBill, OccurredAt: from.AddDate(0, 0, -20), Currency: USD, Amount: decimal.NewFromInt(100)},
+			{Type: ReceivableActivityPayment, BillID: oldBill, Reference: "TRF-1", OccurredAt: from.AddDate(0, 0, -5), Currency: USD, Amount: decimal.NewFromInt(60)},
+			{Type: ReceivableActivityBill, BillID: newBill, OccurredAt: from.AddDate(0, 0, 10), Currency: USD, Amount: decimal.NewFromInt(50)},
+			{Type: ReceivableActivityCreditNote, BillID: newBill, Reference: "CN-1", OccurredAt: from.AddDate(0, 0, 12), Currency: GEL, Amount: decimal.NewFromInt(5)},
+			{Type: ReceivableActivityBill, BillID: newBill, OccurredAt: from.AddDate(0, 0, 10), Currency: GEL, Amount: decimal.NewFromInt(25)},
+		}
+
+		statement, err := NewCustomerStatement("cust-a", activity, from, to, USD, rates, policy)
+
+		require.NoError(t, err)
+		require.Len(t, statement.Sections, 2)
+		gel, usd := statement.Sections[0], statement.Sections[1]
+		assert.Equal(t, GEL, gel.Currency)
+		assert.True(t, gel.OpeningBalance.IsZero())
+		assert.True(t, decimal.NewFromInt(20).Equal(gel.ClosingBalance), gel.ClosingBalance.String())
+		assert.True(t, decimal.NewFromInt(8).Equal(gel.ReportingClosingBalance), gel.ReportingClosingBalance.String())
+
+		assert.True(t, decimal.NewFromInt(40).Equal(usd.OpeningBalance), usd.OpeningBalance.String())
+		require.Len(t, usd.Lines, 2)
+		assert.Equal(t, ReceivableActivityBill, usd.Lines[0].Type)
+		assert.True(t, decimal.NewFromInt(50).Equal(usd.Lines[0].Debit))
+		assert.True(t, decimal.NewFromInt(90).Equal(usd.Lines[0].Balance), usd.Lines[0].Balance.String())
+		assert.Equal(t, "TRF-2", usd.Lines[1].Reference)
+		assert.True(t, decimal.NewFromInt(30).Equal(usd.Lines[1].Credit))
+		assert.True(t, decimal.NewFromInt(60).Equal(usd.Lines[1].Balance), usd.Lines[1].Balance.String())
+		assert.True(t, decimal.NewFromInt(68).Equal(statement.ClosingBalance), statement.ClosingBalance.String())
+	})
+
+	t.Run("when_customer_has_no_activity_should_return_empty_statement", func(t *testing.T) {
+		statement, err := NewCustomerStatement("cust-a", nil, from, to, GEL, rates, policy)
+
+		require.NoError(t, err)
+		assert.Empty(t, statement.Sections)
+		assert.True(t, statement.ClosingBalance.IsZero())
+		assert.Equal(t, GEL, statement.ReportingCurrency)
+	})
+}
+
+func TestGranularity(t *testing.T) {
+	t.Run("should_return_start_of_period_containing_month", func(t *testing.T) {
+		at := time.Date(2025, 8, 17, 10, 0, 0, 0, time.UTC)
+
+		assert.Equal(t, time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC), GranularityMonth.PeriodStart(at))
+		assert.Equal(t, time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC), GranularityQuarter.PeriodStart(at))
+		assert.Equal(t, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), GranularityYear.PeriodStart(at))
+		assert.Equal(t, time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC), GranularityQuarter.NextPeriod(GranularityQuarter.PeriodStart(at)))
+	})
+
+	t.Run("when_granularity_is_unknown_should_return_error", func(t *testing.T) {
+		assert.NoError(t, GranularityQuarter.Validate())
+		assert.Error(t, Granularity("week").Validate())
+	})
+}
+
+func TestRevenueReport(t *testing.T) {
+	policy := RoundingPolicy{Mode: RoundingModeHalfUp, Level: RoundingLevelTotal}
+	rates := &RatesData{Rates: map[string]float64{"USD": 1, "GEL": 2.5}}
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("should_list_every_period_with_revenue_per_currency_and_converted", func(t *testing.T) {
+		revenue := []PeriodRevenue{
+			{PeriodStart: from, Currency: USD, Billed: decimal.NewFromInt(100)},
+			{PeriodStart: from, Currency: GEL, Billed: decimal.NewFromInt(50)},
+			{PeriodStart: time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), Currency: GEL, Billed: decimal.RequireFromString("10.01")},
+		}
+
+		report, err := NewRevenueReport(revenue, from, to, GranularityQuarter, USD, rates, policy)
+
+		require.NoError(t, err)
+		require.Len(t, report.Periods, 2)
+		assert.True(t, decimal.NewFromInt(50).Equal(report.Periods[0].ByCurrency[GEL]))
+		assert.True(t, decimal.NewFromInt(120).Equal(report.Periods[0].Reporting), report.Periods[0].Reporting.String())
+		assert.True(t, decimal.RequireFromString("4").Equal(report.Periods[1].Reporting), report.Periods[1].Reporting.String())
+		assert.True(t, decimal.NewFromInt(124).Equal(report.Total), report.Total.String())
+	})
+
+	t.Run("when_no_revenue_should_return_empty_periods", func(t *testing.T) {
+		report, err := NewRevenueReport(nil, from, to, GranularityMonth, USD, rates, policy)
+
+		require.NoError(t, err)
+		require.Len(t, report.Periods, 6)
+		assert.Empty(t, report.Periods[5].ByCurrency)
+		assert.True(t, report.Total.IsZero())
+	})
+}
+
+func TestMRRReport(t *testing.T) {
+	policy := RoundingPolicy{Mode: RoundingModeHalfUp, Level: RoundingLevelTotal}
+	rates := &RatesData{Rates: map[string]float64{"USD": 1, "GEL": 2.5}}
+	jan := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	feb, mar := jan.AddDate(0, 1, 0), jan.AddDate(0, 2, 0)
+	mrr := func(month time.Time, customerID string, currency Currency, amount int64) RecurringRevenue {
+		return RecurringRevenue{Month: month, CustomerID: customerID, Currency: currency, MRR: decimal.NewFromInt(amount)}
+	}
+
+	t.Run("should_split_movement_into_new_expansion_contraction_and_churn", func(t *testing.T) {
+		recurring := []RecurringRevenue{
+			mrr(jan, "expands", USD, 100),
+			mrr(jan, "contracts", USD, 80),
+			mrr(jan, "churns", GEL, 50),
+			mrr(jan, "steady", USD, 10),
+			mrr(feb, "expands", USD, 100),
+			mrr(feb, "expands", GEL, 25),
+			mrr(feb, "contracts", USD, 60),
+			mrr(feb, "steady", USD, 10),
+			mrr(feb, "joins", USD, 30),
+		}
+
+		report, err := NewMRRReport(recurring, feb, mar, USD, rates, policy)
+
+		require.NoError(t, err)
+		require.Len(t, report.Months, 1)
+		month := report.Months[0]
+		assert.Equal(t, feb, month.Month)
+		assert.True(t, decimal.NewFromInt(210).Equal(month.MRR), month.MRR.String())
+		assert.True(t, decimal.NewFromInt(2520).Equal(month.ARR), month.ARR.String())
+		assert.True(t, decimal.NewFromInt(30).Equal(month.NewMRR), month.NewMRR.String())
+		assert.True(t, decimal.NewFromInt(10).Equal(month.ExpansionMRR), month.ExpansionMRR.String())
+		assert.True(t, decimal.NewFromInt(20).Equal(month.ContractionMRR), month.ContractionMRR.String())
+		assert.True(t, decimal.NewFromInt(20).Equal(month.ChurnedMRR), month.ChurnedMRR.String())
+		assert.True(t, decimal.Zero.Equal(month.NetNewMRR), month.NetNewMRR.String())
+		assert.Equal(t, 4, month.Customers)
+		assert.Equal(t, 1, month.NewCustomers)
+		assert.Equal(t, 1, month.ChurnedCustomers)
+		assert.True(t, decimal.RequireFromString("0.25").Equal(month.CustomerChurnRate), month.CustomerChurnRate.String())
+		assert.True(t, decimal.NewFromInt(25).Equal(month.ByCurrency[GEL]))
+	})
+
+	t.Run("when_month_before_has_no_customers_should_report_zero_churn_rate", func(t *testing.T) {
+		report, err := NewMRRReport([]RecurringRevenue{mrr(jan, "joins", USD, 40)}, jan, mar, USD, rates, policy)
+
+		require.NoError(t, err)
+		require.Len(t, report.Months, 2)
+		assert.Equal(t, 1, report.Months[0].NewCustomers)
+		assert.True(t, report.Months[0].CustomerChurnRate.IsZero())
+		assert.Equal(t, 1, report.Months[1].ChurnedCustomers)
+		assert.True(t, decimal.NewFromInt(1).Equal(report.Months[1].CustomerChurnRate))
+		assert.True(t, report.Months[1].MRR.IsZero())
+	})
+}
+
+func TestRecognitionSchedules(t *testing.T) {
+	policy := RoundingPolicy{Mode: RoundingModeHalfUp, Level: RoundingLevelTotal}
+	jan := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	feb, mar := jan.AddDate(0, 1, 0), jan.AddDate(0, 2, 0)
+	newBill := func(items ...*LineItem) *Bill {
+		return &Bill{
+			ID: uuid.Must(uuid.NewV4()), CustomerID: "customer-1", PeriodStart: jan, PeriodEnd: feb, LineItems: items,
+		}
+	}
+	amounts := func(schedule *RecognitionSchedule) (recognized, deferred []string) {
+		for _, entry := range schedule.Entries {
+			recognized = append(recognized, entry.Recognized.StringFixed(2))
+			deferred = append(deferred, entry.Deferred.StringFixed(2))
+		}
+		return recognized, deferred
+	}
+
+	t.Run("should_recognize_days_of_service_period_and_catch_up_months_served_before_close", func(t *testing.T) {
+		serviceStart, serviceEnd := jan.AddDate(0, 0, 14), mar.AddDate(0, 0, 14)
+		item := &LineItem{
+			ID: uuid.Must(uuid.NewV4()), Currency: USD, Total: decimal.NewFromInt(59),
+			ServiceStart: &serviceStart, ServiceEnd: &serviceEnd,
+		}
+
+		schedules, err := NewRecognitionSchedules(newBill(item), feb, RecognitionDaily, policy)
+
+		require.NoError(t, err)
+		require.Len(t, schedules, 1)
+		schedule := schedules[0]
+		assert.Equal(t, item.ID, schedule.LineItemID)
+		assert.Equal(t, serviceStart, schedule.ServiceStart)
+		assert.True(t, decimal.NewFromInt(59).Equal(schedule.Amount))
+		recognized, deferred := amounts(schedule)
+		// 17 days of January, 28 of February and 14 of March
+		assert.Equal(t, []string{"17.00", "28.00", "14.00"}, recognized)
+		assert.Equal(t, []string{"42.00", "14.00", "0.00"}, deferred)
+		assert.Equal(t, []time.Time{jan, feb, mar}, []time.Time{
+			schedule.Entries[0].Month, schedule.Entries[1].Month, schedule.Entries[2].Month,
+		})
+		assert.Equal(t, feb, schedule.Entries[0].RecognizedIn)
+		assert.Equal(t, feb, schedule.Entries[1].RecognizedIn)
+		assert.Equal(t, mar, schedule.Entries[2].RecognizedIn)
+	})
+
+	t.Run("should_recognize_equal_months_over_billing_period_rounded_to_line_total", func(t *testing.T) {
+		bill := newBill(&LineItem{ID: uuid.Must(uuid.NewV4()), Currency: USD, Total: decimal.NewFromInt(100)})
+		bill.PeriodEnd = mar.AddDate(0, 0, 1)
+
+		schedules, err := NewRecognitionSchedules(bill, bill.PeriodEnd, RecognitionMonthly, policy)
+
+		require.NoError(t, err)
+		recognized, deferred := amounts(schedules[0])
+		assert.Equal(t, []string{"33.33", "33.34", "33.33"}, recognized)
+		assert.Equal(t, []string{"66.67", "33.33", "0.00"}, deferred)
+		assert.Equal(t, bill.PeriodStart, schedules[0].ServiceStart)
+	})
+
+	t.Run("should_add_up_to_bill_total_of_each_currency", func(t *testing.T) {
+		// rounded at the total, the lines of half a cent total one cent
+		bill := newBill(
+			&LineItem{ID: uuid.Must(uuid.NewV4()), Currency: USD, Total: decimal.RequireFromString("0.005")},
+			&LineItem{ID: uuid.Must(uuid.NewV4()), Currency: USD, Total: decimal.RequireFromString("0.005")},
+			&LineItem{ID: uuid.Must(uuid.NewV4()), Currency: GEL, Total: decimal.NewFromInt(3)},
+		)
+
+		schedules, err := NewRecognitionSchedules(bill, feb, RecognitionDaily, policy)
+
+		require.NoError(t, err)
+		require.Len(t, schedules, 3)
+		assert.True(t, decimal.RequireFromString("0.01").Equal(schedules[0].Amount.Add(schedules[1].Amount)))
+		assert.True(t, decimal.NewFromInt(3).Equal(schedules[2].Amount))
+		assert.Equal(t, GEL, schedules[2].Currency)
+	})
+
+	t.Run("when_method_is_unknown_should_return_error", func(t *testing.T) {
+		_, err := NewRecognitionSchedules(newBill(), feb, RecognitionMethod("weekly"), policy)
+
+		assert.Error(t, err)
+	})
+}
+
+func TestDeferredRevenueWaterfall(t *testing.T) {
+	jan := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	dec, feb, mar := jan.AddDate(0, -1, 0), jan.AddDate(0, 1, 0), jan.AddDate(0, 2, 0)
+	movement := func(month time.Time, currency Currency, billed, recognized int64) DeferredRevenueMovement {
+		return DeferredRevenueMovement{
+			Month: month, Currency: currency, Billed: decimal.NewFromInt(billed), Recognized: decimal.NewFromInt(recognized),
+		}
+	}
+
+	t.Run("should_roll_deferred_revenue_forward_and_schedule_the_rest", func(t *testing.T) {
+		movements := []DeferredRevenueMovement{
+			movement(dec, USD, 100, 40),
+			movement(jan, USD, 0, 30),
+			movement(jan, GEL, 50, 10),
+			movement(feb, GEL, 0, 10),
+			movement(feb, USD, 0, 30),
+			movement(mar, GEL, 0, 30),
+		}
+
+		waterfall := NewDeferredRevenueWaterfall(movements, jan, mar)
+
+		type row struct {
+			month                                time.Time
+			currency                             Currency
+			opening, billed, recognized, closing int64
+		}
+		rows := make([]row, 0, len(waterfall.Months))
+		for _, m := range waterfall.Months {
+			rows = append(rows, row{
+				m.Month, m.Currency, m.Opening.IntPart(), m.Billed.IntPart(), m.Recognized.IntPart(), m.Closing.IntPart(),
+			})
+		}
+		assert.Equal(t, []row{
+			{jan, GEL, 0, 50, 10, 40},
+			{jan, USD, 60, 0, 30, 30},
+			{feb, GEL, 40, 0, 10, 30},
+			{feb, USD, 30, 0, 30, 0},
+		}, rows)
+		require.Len(t, waterfall.Scheduled, 1)
+		assert.Equal(t, mar, waterfall.Scheduled[0].Month)
+		assert.True(t, decimal.NewFromInt(30).Equal(waterfall.Scheduled[0].Amount))
+	})
+
+	t.Run("when_nothing_is_deferred_should_return_no_months", func(t *testing.T) {
+		waterfall := NewDeferredRevenueWaterfall([]DeferredRevenueMovement{movement(dec, USD, 10, 10)}, jan, mar)
+
+		assert.Empty(t, waterfall.Months)
+		assert.Empty(t, waterfall.Scheduled)
+	})
+}
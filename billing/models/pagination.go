@@ -0,0 +1,56 @@
+package models
+
+import (
+	"encoding/base64"
+	"strings"
+	"time"
+
+	"encore.dev/types/uuid"
+)
+
+// LineItemFilter selects a page of line items, ordered by creation time and ID
+type LineItemFilter struct {
+	Currency      Currency
+	CreatedAfter  *time.Time // inclusive
+	CreatedBefore *time.Time // exclusive
+	After         *LineItemCursor
+	Limit         int
+}
+
+// LineItemCursor is the position of the last line item of a page
+type LineItemCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// NewLineItemCursor returns the cursor pointing after the given line item
+func NewLineItemCursor(item *LineItem) *LineItemCursor {
+	return &LineItemCursor{CreatedAt: item.CreatedAt, ID: item.ID}
+}
+
+// Encode returns the opaque string representation of the cursor
+func (c *LineItemCursor) Encode() string {
+	raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeLineItemCursor parses a cursor returned by Encode
+func DecodeLineItemCursor(s string) (*LineItemCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	createdAt, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+
+	cursor := &LineItemCursor{}
+	if cursor.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if cursor.ID, err = uuid.FromString(id); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return cursor, nil
+}
@@ -0,0 +1,323 @@
+package models
+
+import (
+	"cmp"
+	"slices"
+	"time"
+
+	"encore.dev/types/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// AgingBucket groups outstanding balances by how many whole days they are past due
+type AgingBucket string
+
+const (
+	AgingCurrent AgingBucket = "current"
+	Aging1To30   AgingBucket = "1_30"
+	Aging31To60  AgingBucket = "31_60"
+	Aging61To90  AgingBucket = "61_90"
+	AgingOver90  AgingBucket = "90_plus"
+)
+
+// AgingBucketFor returns the bucket of a balance the given number of whole days past due
+func AgingBucketFor(daysPastDue int) AgingBucket {
+	switch {
+	case daysPastDue <= 0:
+		return AgingCurrent
+	case daysPastDue <= 30:
+		return Aging1To30
+	case daysPastDue <= 60:
+		return Aging31To60
+	case daysPastDue <= 90:
+		return Aging61To90
+	default:
+		return AgingOver90
+	}
+}
+
+// OpenReceivable is the balance of a closed or finalized bill in one currency that is not yet paid or credited
+type OpenReceivable struct {
+	BillID      uuid.UUID       `json:"bill_id"`
+	CustomerID  string          `json:"customer_id"`
+	Currency    Currency        `json:"currency"`
+	ClosedAt    time.Time       `json:"closed_at"`
+	Outstanding decimal.Decimal `json:"outstanding"`
+}
+
+// DueAt returns when the receivable is due, paymentTermsDays after the bill closed
+func (r OpenReceivable) DueAt(paymentTermsDays int) time.Time {
+	return r.ClosedAt.AddDate(0, 0, paymentTermsDays)
+}
+
+// DaysPastDue returns the whole days the receivable is past due at asOf, zero or negative when not yet due
+func (r OpenReceivable) DaysPastDue(asOf time.Time, paymentTermsDays int) int {
+	return int(asOf.Sub(r.DueAt(paymentTermsDays)) / (24 * time.Hour))
+}
+
+// AgingAmounts are outstanding balances split by aging bucket
+type AgingAmounts struct {
+	Current    decimal.Decimal `json:"current"`
+	Days1To30  decimal.Decimal `json:"1_30"`
+	Days31To60 decimal.Decimal `json:"31_60"`
+	Days61To90 decimal.Decimal `json:"61_90"`
+	Over90     decimal.Decimal `json:"90_plus"`
+	Total      decimal.Decimal `json:"total"`
+}
+
+// Add adds amount to the bucket and the total
+func (a *AgingAmounts) Add(bucket AgingBucket, amount decimal.Decimal) {
+	switch bucket {
+	case AgingCurrent:
+		a.Current = a.Current.Add(amount)
+	case Aging1To30:
+		a.Days1To30 = a.Days1To30.Add(amount)
+	case Aging31To60:
+		a.Days31To60 = a.Days31To60.Add(amount)
+	case Aging61To90:
+		a.Days61To90 = a.Days61To90.Add(amount)
+	default:
+		a.Over90 = a.Over90.Add(amount)
+	}
+	a.Total = a.Total.Add(amount)
+}
+
+// addAll adds every bucket of other
+func (a *AgingAmounts) addAll(other AgingAmounts) {
+	a.Current = a.Current.Add(other.Current)
+	a.Days1To30 = a.Days1To30.Add(other.Days1To30)
+	a.Days31To60 = a.Days31To60.Add(other.Days31To60)
+	a.Days61To90 = a.Days61To90.Add(other.Days61To90)
+	a.Over90 = a.Over90.Add(other.Over90)
+	a.Total = a.Total.Add(other.Total)
+}
+
+// convert converts every bucket at rate, rounding each in the target currency.
+// The total is the sum of the rounded buckets so the converted row adds up.
+func (a AgingAmounts) convert(rate decimal.Decimal, to Currency, policy RoundingPolicy) AgingAmounts {
+	round := func(amount decimal.Decimal) decimal.Decimal {
+		return policy.Round(to, amount.Mul(rate))
+	}
+	converted := AgingAmounts{
+		Current:    round(a.Current),
+		Days1To30:  round(a.Days1To30),
+		Days31To60: round(a.Days31To60),
+		Days61To90: round(a.Days61To90),
+		Over90:     round(a.Over90),
+	}
+	converted.Total = converted.Current.Add(converted.Days1To30).Add(converted.Days31To60).
+		Add(converted.Days61To90).Add(converted.Over90)
+	return converted
+}
+
+// AgingRow is the aging of the receivables of a customer in one currency
+type AgingRow struct {
+	CustomerID string       `json:"customer_id"`
+	Currency   Currency     `json:"currency"`
+	Amounts    AgingAmounts `json:"amounts"`
+	// Reporting is Amounts converted to the reporting currency of the report
+	Reporting AgingAmounts `json:"reporting"`
+	Bills     int          `json:"bills"`
+}
+
+// AgingReport is the aging of the open receivables at a time per customer and currency,
+// with totals converted to a reporting currency
+type AgingReport struct {
+	AsOf              time.Time `json:"as_of"`
+	PaymentTermsDays  int       `json:"payment_terms_days"`
+	ReportingCurrency Currency  `json:"reporting_currency"`
+	// RatesUpdatedAt is when the rates converting to the reporting currency were published
+	RatesUpdatedAt time.Time    `json:"rates_updated_at"`
+	Rows           []AgingRow   `json:"rows"`
+	Totals         AgingAmounts `json:"totals"`
+}
+
+// NewAgingReport buckets the receivables by the days they are past due at asOf, due paymentTermsDays after
+// their bill closed, per customer and currency ordered by customer then currency
+func NewAgingReport(
+	receivables []OpenReceivable, asOf time.Time, paymentTermsDays int,
+	reporting Currency, rates *RatesData, policy RoundingPolicy,
+) (*AgingReport, error) {
+	type rowKey struct {
+		customerID string
+		currency   Currency
+	}
+	rows := make(map[rowKey]*AgingRow)
+	for _, receivable := range receivables {
+		key := rowKey{customerID: receivable.CustomerID, currency: receivable.Currency}
+		row, ok := rows[key]
+		if !ok {
+			row = &AgingRow{CustomerID: receivable.CustomerID, Currency: receivable.Currency}
+			rows[key] = row
+		}
+		row.Amounts.Add(AgingBucketFor(receivable.DaysPastDue(asOf, paymentTermsDays)), receivable.Outstanding)
+		row.Bills++
+	}
+
+	report := &AgingReport{
+		AsOf:              asOf,
+		PaymentTermsDays:  paymentTermsDays,
+		ReportingCurrency: reporting,
+		RatesUpdatedAt:    rates.UpdatedAt,
+		Rows:              make([]AgingRow, 0, len(rows)),
+	}
+	for _, row := range rows {
+		rate, err := rates.ConversionRate(row.Currency, reporting)
+		if err != nil {
+			return nil, err
+		}
+		row.Reporting = row.Amounts.convert(rate, reporting, policy)
+		report.Totals.addAll(row.Reporting)
+		report.Rows = append(report.Rows, *row)
+	}
+	slices.SortFunc(report.Rows, func(a, b AgingRow) int {
+		return cmp.Or(cmp.Compare(a.CustomerID, b.CustomerID), cmp.Compare(a.Currency, b.Currency))
+	})
+	return report, nil
+}
+
+// ReceivableActivityType is what changed the receivable of a customer
+type ReceivableActivityType string
+
+const (
+	ReceivableActivityBill       ReceivableActivityType = "bill"
+	ReceivableActivityPayment    ReceivableActivityType = "payment"
+	ReceivableActivityCreditNote ReceivableActivityType = "credit_note"
+)
+
+// ReceivableActivity is a bill billed to a customer, or a payment or credit note settling it, in one currency.
+// Amount is positive; bills increase the balance and payments and credit notes decrease it.
+type ReceivableActivity struct {
+	Type       ReceivableActivityType `json:"type"`
+	BillID     uuid.UUID              `json:"bill_id"`
+	Reference  string                 `json:"reference,omitempty"`
+	OccurredAt time.Time              `json:"occurred_at"`
+	Currency   Currency               `json:"currency"`
+	Amount     decimal.Decimal        `json:"amount"`
+}
+
+// signed returns the change of the balance by the activity
+func (a ReceivableActivity) signed() decimal.Decimal {
+	if a.Type == ReceivableActivityBill {
+		return a.Amount
+	}
+	return a.Amount.Neg()
+}
+
+// CustomerStatementLine is an activity in the statement period and the balance after it
+type CustomerStatementLine struct {
+	ReceivableActivity
+	Debit   decimal.Decimal `json:"debit"`
+	Credit  decimal.Decimal `json:"credit"`
+	Balance decimal.Decimal `json:"balance"`
+}
+
+// CustomerStatementSection is the activity of a customer in one currency with its running balance
+type CustomerStatementSection struct {
+	Currency       Currency                `json:"currency"`
+	OpeningBalance decimal.Decimal         `json:"opening_balance"`
+	Lines          []CustomerStatementLine `json:"lines"`
+	ClosingBalance decimal.Decimal         `json:"closing_balance"`
+	// ReportingClosingBalance is ClosingBalance converted to the reporting currency of the statement
+	ReportingClosingBalance decimal.Decimal `json:"reporting_closing_balance"`
+}
+
+// CustomerStatement lists the bills, payments and credit notes of a customer in a period,
+// with the running balance per currency and the closing balance in a reporting currency
+type CustomerStatement struct {
+	CustomerID        string    `json:"customer_id"`
+	From              time.Time `json:"from"`
+	To                time.Time `json:"to"`
+	ReportingCurrency Currency  `json:"reporting_currency"`
+	// RatesUpdatedAt is when the rates converting to the reporting currency were published
+	RatesUpdatedAt time.Time                  `json:"rates_updated_at"`
+	Sections       []CustomerStatementSection `json:"sections"`
+	ClosingBalance decimal.Decimal            `json:"closing_balance"`
+}
+
+// NewCustomerStatement builds the statement of the customer from its activity up to to.
+// Activity before from makes up the opening balance; the rest is listed in order of occurrence,
+// bills before the payments settling them on the same instant. Sections are ordered by currency.
+func NewCustomerStatement(
+	customerID string, activity []ReceivableActivity, from, to time.Time,
+	reporting Currency, rates *RatesData, policy RoundingPolicy,
+) (*CustomerStatement, error) {
+	ordered := slices.Clone(activity)
+	slices.SortStableFunc(ordered, func(a, b ReceivableActivity) int {
+		return cmp.Or(
+			a.OccurredAt.Compare(b.OccurredAt),
+			cmp.Compare(activityOrder(a.Type), activityOrder(b.Type)),
+			cmp.Compare(a.BillID.String(), b.BillID.String()),
+		)
+	})
+
+	sections := make(map[Currency]*CustomerStatementSection)
+	for _, a := range ordered {
+		if a.OccurredAt.After(to) {
+			continue
+		}
+		section, ok := sections[a.Currency]
+		if !ok {
+			section = &CustomerStatementSection{Currency: a.Currency, Lines: []CustomerStatementLine{}}
+			sections[a.Currency] = section
+		}
+		section.ClosingBalance = section.ClosingBalance.Add(a.signed())
+		if a.OccurredAt.Before(from) {
+			section.OpeningBalance = section.ClosingBalance
+			continue
+		}
+		line := CustomerStatementLine{ReceivableActivity: a, Balance: section.ClosingBalance}
+		if a.Type == ReceivableActivityBill {
+			line.Debit = a.Amount
+		} else {
+			line.Credit = a.Amount
+		}
+		section.Lines = append(section.Lines, line)
+	}
+
+	statement := &CustomerStatement{
+		CustomerID:        customerID,
+		From:              from,
+		To:                to,
+		ReportingCurrency: reporting,
+		RatesUpdatedAt:    rates.UpdatedAt,
+		Sections:          make([]CustomerStatementSection, 0, len(sections)),
+	}
+	for _, section := range sections {
+		rate, err := rates.ConversionRate(section.Currency, reporting)
+		if err != nil {
+			return nil, err
+		}
+		section.ReportingClosingBalance = policy.Round(reporting, section.ClosingBalance.Mul(rate))
+		statement.ClosingBalance = statement.ClosingBalance.Add(section.ReportingClosingBalance)
+		statement.Sections = append(statement.Sections, *section)
+	}
+	slices.SortFunc(statement.Sections, func(a, b CustomerStatementSection) int {
+		return cmp.Compare(a.Currency, b.Currency)
+	})
+	return statement, nil
+}
+
+// activityOrder orders activity on the same instant so bills come before what settles them
+func activityOrder(t ReceivableActivityType) int {
+	if t == ReceivableActivityBill {
+		return 0
+	}
+	return 1
+}
+
+// AgingReportFilter selects the receivables of an aging report
+type AgingReportFilter struct {
+	AsOf time.Time
+	// CustomerID restricts the report to one customer when set
+	CustomerID        string
+	ReportingCurrency Currency
+}
+
+// CustomerStatementFilter selects the period and reporting currency of a customer statement
+type CustomerStatementFilter struct {
+	// From and To are inclusive
+	From              time.Time
+	To                time.Time
+	ReportingCurrency Currency
+}
@@ -0,0 +1,282 @@
+package models
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"time"
+
+	"encore.dev/types/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// RecognitionMethod is how the revenue of a line item is spread straight-line over its service period
+type RecognitionMethod string
+
+const (
+	// RecognitionDaily recognizes an equal amount per UTC day, each month recognizing the days it serves
+	RecognitionDaily RecognitionMethod = "daily"
+	// RecognitionMonthly recognizes an equal amount per UTC calendar month the service period touches
+	RecognitionMonthly RecognitionMethod = "monthly"
+)
+
+// RecognitionMethodFromConfig returns the configured recognition method
+func RecognitionMethodFromConfig(cfg *AppConfig) (RecognitionMethod, error) {
+	method := RecognitionMethod(cfg.Billing.Recognition.Method())
+	if err := method.Validate(); err != nil {
+		return "", err
+	}
+	return method, nil
+}
+
+// Validate validates the recognition method
+func (m RecognitionMethod) Validate() error {
+	switch m {
+	case RecognitionDaily, RecognitionMonthly:
+		return nil
+	default:
+		return fmt.Errorf("invalid recognition method %q, supported methods are daily and monthly", m)
+	}
+}
+
+// monthWeight is the share of a service month in the service period, relative to the other months
+type monthWeight struct {
+	month  time.Time
+	weight int64
+}
+
+// weights spreads the service period from start until the exclusive end over the months it touches.
+// An empty period is served on the day it starts.
+func (m RecognitionMethod) weights(start, end time.Time) []monthWeight {
+	first := startOfDay(start)
+	last := first
+	if end.After(start) {
+		last = startOfDay(end.Add(-time.Nanosecond))
+	}
+
+	weights := make([]monthWeight, 0)
+	for month := StartOfMonth(first); !month.After(last); month = month.AddDate(0, 1, 0) {
+		weight := int64(1)
+		if m == RecognitionDaily {
+			from, to := month, month.AddDate(0, 1, -1)
+			if first.After(from) {
+				from = first
+			}
+			if last.Before(to) {
+				to = last
+			}
+			weight = int64(to.Sub(from)/(24*time.Hour)) + 1
+		}
+		weights = append(weights, monthWeight{month: month, weight: weight})
+	}
+	return weights
+}
+
+// startOfDay returns the first instant of the UTC day of t
+func startOfDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// RecognitionSchedule spreads the revenue of a line item of a closed bill over its service period.
+// The line total is deferred when the bill closes and recognized month by month.
+type RecognitionSchedule struct {
+	LineItemID uuid.UUID         `json:"line_item_id"`
+	BillID     uuid.UUID         `json:"bill_id"`
+	CustomerID string            `json:"customer_id"`
+	Currency   Currency          `json:"currency"`
+	Method     RecognitionMethod `json:"method"`
+	// ServiceStart and ServiceEnd, exclusive, are the service period of the line item or the billing period
+	ServiceStart time.Time       `json:"service_start"`
+	ServiceEnd   time.Time       `json:"service_end"`
+	Amount       decimal.Decimal `json:"amount"`
+	// BilledAt is when the bill closed
+	BilledAt time.Time          `json:"billed_at"`
+	Entries  []RecognitionEntry `json:"entries"`
+}
+
+// RecognitionEntry is the revenue of a line item recognized for a service month
+type RecognitionEntry struct {
+	Month time.Time `json:"month"`
+	// RecognizedIn is the month the revenue is recognized in, the month of close for months served before the bill closed
+	RecognizedIn time.Time       `json:"recognized_in"`
+	Recognized   decimal.Decimal `json:"recognized"`
+	// Deferred is the revenue of the schedule still deferred once the entry is recognized
+	Deferred decimal.Decimal `json:"deferred"`
+}
+
+// NewRecognitionSchedules schedules the recognition of each line item of the bill closed at closedAt, over its
+// service period or the billing period. The line totals must be computed. Entries are rounded in the currency of the
+// line so the schedules of a currency add up to the bill total in it.
+func NewRecognitionSchedules(
+	bill *Bill, closedAt time.Time, method RecognitionMethod, policy RoundingPolicy,
+) ([]*RecognitionSchedule, error) {
+	if err := method.Validate(); err != nil {
+		return nil, err
+	}
+
+	closeMonth := StartOfMonth(closedAt)
+	// unrounded revenue scheduled so far per currency, entries are the increments of its rounded value
+	scheduled := make(map[Currency]decimal.Decimal)
+	schedules := make([]*RecognitionSchedule, 0, len(bill.LineItems))
+	for _, item := range bill.LineItems {
+		start, end := bill.PeriodStart, bill.PeriodEnd
+		if item.ServiceStart != nil && item.ServiceEnd != nil {
+			start, end = *item.ServiceStart, *item.ServiceEnd
+		}
+		schedule := &RecognitionSchedule{
+			LineItemID:   item.ID,
+			BillID:       bill.ID,
+			CustomerID:   bill.CustomerID,
+			Currency:     item.Currency,
+			Method:       method,
+			ServiceStart: start,
+			ServiceEnd:   end,
+			BilledAt:     closedAt,
+			Entries:      []RecognitionEntry{},
+		}
+
+		weights := method.weights(start, end)
+		var total, served int64
+		for _, w := range weights {
+			total += w.weight
+		}
+		base := scheduled[item.Currency]
+		before := policy.Round(item.Currency, base)
+		for _, w := range weights {
+			served += w.weight
+			// the last month reaches the line total exactly
+			after := policy.Round(item.Currency, base.Add(
+				item.Total.Mul(decimal.NewFromInt(served)).Div(decimal.NewFromInt(total)),
+			))
+			recognizedIn := w.month
+			if closeMonth.After(recognizedIn) {
+				recognizedIn = closeMonth
+			}
+			schedule.Amount = schedule.Amount.Add(after.Sub(before))
+			schedule.Entries = append(schedule.Entries, RecognitionEntry{
+				Month:        w.month,
+				RecognizedIn: recognizedIn,
+				Recognized:   after.Sub(before),
+			})
+			before = after
+		}
+		scheduled[item.Currency] = base.Add(item.Total)
+
+		remaining := schedule.Amount
+		for i := range schedule.Entries {
+			remaining = remaining.Sub(schedule.Entries[i].Recognized)
+			schedule.Entries[i].Deferred = remaining
+		}
+		schedules = append(schedules, schedule)
+	}
+	return schedules, nil
+}
+
+// DeferredRevenueMovement is the revenue deferred by bills closed in a month and recognized in it, in a currency
+type DeferredRevenueMovement struct {
+	Month      time.Time       `json:"month"`
+	Currency   Currency        `json:"currency"`
+	Billed     decimal.Decimal `json:"billed"`
+	Recognized decimal.Decimal `json:"recognized"`
+}
+
+// DeferredRevenueMonth is the deferred revenue of a month in a currency, Closing is Opening plus Billed less Recognized
+type DeferredRevenueMonth struct {
+	Month      time.Time       `json:"month"`
+	Currency   Currency        `json:"currency"`
+	Opening    decimal.Decimal `json:"opening"`
+	Billed     decimal.Decimal `json:"billed"`
+	Recognized decimal.Decimal `json:"recognized"`
+	Closing    decimal.Decimal `json:"closing"`
+}
+
+// ScheduledRecognition is deferred revenue at the end of a report to be recognized in a later month
+type ScheduledRecognition struct {
+	Month    time.Time       `json:"month"`
+	Currency Currency        `json:"currency"`
+	Amount   decimal.Decimal `json:"amount"`
+}
+
+// DeferredRevenueWaterfall is the deferred revenue per month and currency of a range, from inclusive to to exclusive
+type DeferredRevenueWaterfall struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	// Months lists the months of each currency with deferred revenue, ordered by month then currency
+	Months []DeferredRevenueMonth `json:"months"`
+	// Scheduled is when the revenue deferred at to is to be recognized, ordered by month then currency
+	Scheduled []ScheduledRecognition `json:"scheduled"`
+}
+
+// NewDeferredRevenueWaterfall rolls the deferred revenue forward month by month from from until to.
+// The movements must include the months before from, their balance is the opening of the first month,
+// and only bills closed before to, whose recognition after to is the scheduled revenue.
+func NewDeferredRevenueWaterfall(movements []DeferredRevenueMovement, from, to time.Time) *DeferredRevenueWaterfall {
+	waterfall := &DeferredRevenueWaterfall{
+		From:      from,
+		To:        to,
+		Months:    []DeferredRevenueMonth{},
+		Scheduled: []ScheduledRecognition{},
+	}
+
+	balances := make(map[Currency]decimal.Decimal)
+	byMonth := make(map[time.Time][]DeferredRevenueMovement)
+	for _, m := range movements {
+		month := StartOfMonth(m.Month)
+		switch {
+		case month.Before(from):
+			balances[m.Currency] = balances[m.Currency].Add(m.Billed).Sub(m.Recognized)
+		case month.Before(to):
+			byMonth[month] = append(byMonth[month], m)
+		case !m.Recognized.IsZero():
+			waterfall.Scheduled = append(waterfall.Scheduled, ScheduledRecognition{
+				Month: month, Currency: m.Currency, Amount: m.Recognized,
+			})
+		}
+	}
+
+	for month := from; month.Before(to); month = month.AddDate(0, 1, 0) {
+		rows := make(map[Currency]*DeferredRevenueMonth)
+		for currency, balance := range balances {
+			if !balance.IsZero() {
+				rows[currency] = &DeferredRevenueMonth{Month: month, Currency: currency, Opening: balance}
+			}
+		}
+		for _, m := range byMonth[month] {
+			row, ok := rows[m.Currency]
+			if !ok {
+				row = &DeferredRevenueMonth{Month: month, Currency: m.Currency, Opening: balances[m.Currency]}
+				rows[m.Currency] = row
+			}
+			row.Billed = row.Billed.Add(m.Billed)
+			row.Recognized = row.Recognized.Add(m.Recognized)
+		}
+
+		currencies := make([]Currency, 0, len(rows))
+		for currency := range rows {
+			currencies = append(currencies, currency)
+		}
+		slices.Sort(currencies)
+		for _, currency := range currencies {
+			row := rows[currency]
+			row.Closing = row.Opening.Add(row.Billed).Sub(row.Recognized)
+			balances[currency] = row.Closing
+			waterfall.Months = append(waterfall.Months, *row)
+		}
+	}
+
+	slices.SortFunc(waterfall.Scheduled, func(a, b ScheduledRecognition) int {
+		if c := a.Month.Compare(b.Month); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Currency, b.Currency)
+	})
+	return waterfall
+}
+
+// DeferredRevenueFilter selects the months of a deferred revenue waterfall
+type DeferredRevenueFilter struct {
+	// From is the first month and To the month after the last
+	From time.Time
+	To   time.Time
+}
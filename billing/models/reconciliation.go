@@ -0,0 +1,127 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"encore.dev/types/uuid"
+)
+
+// DraftReconciliation reports a run of the draft bill reconciler
+type DraftReconciliation struct {
+	// Checked is the number of orphaned draft bills found
+	Checked int `json:"checked"`
+	// Started is the number of drafts whose workflow was started
+	Started int `json:"started"`
+	// Voided is the number of drafts voided because their period ended before activation
+	Voided int `json:"voided"`
+	// Failed is the number of drafts left for the next run
+	Failed int `json:"failed"`
+}
+
+// DiscrepancyKind is the kind of difference between a bill workflow and the database
+type DiscrepancyKind string
+
+const (
+	// DiscrepancyWorkflowUnavailable is reported when the workflow of an open bill cannot be queried
+	DiscrepancyWorkflowUnavailable DiscrepancyKind = "workflow_unavailable"
+	// DiscrepancyStatusMismatch is reported when a status change of the workflow was not persisted
+	DiscrepancyStatusMismatch DiscrepancyKind = "status_mismatch"
+	// DiscrepancyMissingLineItem is reported when a line item of the workflow was not persisted
+	DiscrepancyMissingLineItem DiscrepancyKind = "missing_line_item"
+	// DiscrepancyLineItemMismatch is reported when an update of a line item was not persisted
+	DiscrepancyLineItemMismatch DiscrepancyKind = "line_item_mismatch"
+	// DiscrepancyLineItemCount is reported when the line item counts differ for another reason than missing line items
+	DiscrepancyLineItemCount DiscrepancyKind = "line_item_count_mismatch"
+)
+
+// BillDiscrepancy is a difference between the bill held by its workflow and the database record
+type BillDiscrepancy struct {
+	BillID     uuid.UUID       `json:"bill_id"`
+	Kind       DiscrepancyKind `json:"kind"`
+	LineItemID *uuid.UUID      `json:"line_item_id,omitempty"`
+	Workflow   string          `json:"workflow,omitempty"`
+	Database   string          `json:"database,omitempty"`
+	// Repaired is set when the database was updated from the workflow state
+	Repaired bool   `json:"repaired"`
+	Error    string `json:"error,omitempty"`
+	// LineItem is the workflow version of the line item, used for repairs
+	LineItem *LineItem `json:"-"`
+}
+
+// ReconciliationReport is the result of a reconciliation run between the bill workflows and the database
+type ReconciliationReport struct {
+	ID            uuid.UUID         `json:"id"`
+	WorkflowID    string            `json:"workflow_id"`
+	Repair        bool              `json:"repair"`
+	StartedAt     time.Time         `json:"started_at"`
+	CompletedAt   time.Time         `json:"completed_at"`
+	BillsChecked  int               `json:"bills_checked"`
+	Discrepancies []BillDiscrepancy `json:"discrepancies"`
+}
+
+// DiffBill compares the bill held by its workflow with the database record and its line items.
+// Workflows only hold the line items of their current run, so earlier line items are only compared by count.
+func DiffBill(workflowBill, stored *Bill) []BillDiscrepancy {
+	discrepancies := make([]BillDiscrepancy, 0)
+
+	missing := int64(0)
+	for _, item := range workflowBill.LineItems {
+		id := item.ID
+		storedItem := stored.FindLineItem(id)
+		switch {
+		case storedItem == nil:
+			missing++
+			discrepancies = append(discrepancies, BillDiscrepancy{
+				BillID:     stored.ID,
+				Kind:       DiscrepancyMissingLineItem,
+				LineItemID: &id,
+				LineItem:   item,
+			})
+		case !item.SameAs(storedItem):
+			discrepancies = append(discrepancies, BillDiscrepancy{
+				BillID:     stored.ID,
+				Kind:       DiscrepancyLineItemMismatch,
+				LineItemID: &id,
+				Workflow:   item.Summary(),
+				Database:   storedItem.Summary(),
+				LineItem:   item,
+			})
+		}
+	}
+
+	if stored.LineItemCount+missing != workflowBill.LineItemCount {
+		discrepancies = append(discrepancies, BillDiscrepancy{
+			BillID:   stored.ID,
+			Kind:     DiscrepancyLineItemCount,
+			Workflow: strconv.FormatInt(workflowBill.LineItemCount, 10),
+			Database: strconv.FormatInt(stored.LineItemCount, 10),
+		})
+	}
+
+	// Status comes last, so line items are repaired before the bill is closed with its totals
+	if workflowBill.Status != stored.Status {
+		discrepancies = append(discrepancies, BillDiscrepancy{
+			BillID:   stored.ID,
+			Kind:     DiscrepancyStatusMismatch,
+			Workflow: string(workflowBill.Status),
+			Database: string(stored.Status),
+		})
+	}
+
+	return discrepancies
+}
+
+// SameAs reports whether both line items have the same description, currency, quantity and unit price
+func (li *LineItem) SameAs(other *LineItem) bool {
+	return li.Description == other.Description &&
+		li.Currency == other.Currency &&
+		li.Quantity.Equal(other.Quantity) &&
+		li.UnitPrice.Equal(other.UnitPrice)
+}
+
+// Summary describes the line item fields compared by SameAs
+func (li *LineItem) Summary() string {
+	return fmt.Sprintf("%s: %s x %s %s", li.Description, li.Quantity, li.UnitPrice, li.Currency)
+}
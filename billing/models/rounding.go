@@ -0,0 +1,72 @@
+package models
+
+import (
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+// RoundingMode determines how amounts are rounded to a currency's minor units
+type RoundingMode string
+
+const (
+	// RoundingModeHalfUp rounds halves away from zero, e.g. 0.125 -> 0.13 and -0.125 -> -0.13
+	RoundingModeHalfUp RoundingMode = "half_up"
+	// RoundingModeHalfEven rounds halves to the nearest even digit (banker's rounding), e.g. 0.125 -> 0.12
+	RoundingModeHalfEven RoundingMode = "half_even"
+	// RoundingModeTruncate drops digits beyond the minor units, e.g. 0.129 -> 0.12
+	RoundingModeTruncate RoundingMode = "truncate"
+)
+
+// RoundingLevel determines where amounts are rounded to minor units
+type RoundingLevel string
+
+const (
+	// RoundingLevelLine rounds each line total and converted line before summing
+	RoundingLevelLine RoundingLevel = "line"
+	// RoundingLevelTotal sums unrounded lines and rounds the totals once
+	RoundingLevelTotal RoundingLevel = "total"
+)
+
+// RoundingPolicy describes how money calculations are rounded
+type RoundingPolicy struct {
+	Mode  RoundingMode
+	Level RoundingLevel
+}
+
+// RoundingPolicyFromConfig builds the rounding policy from the billing configuration
+func RoundingPolicyFromConfig(cfg *AppConfig) RoundingPolicy {
+	return RoundingPolicy{
+		Mode:  RoundingMode(cfg.Billing.Rounding.Mode()),
+		Level: RoundingLevel(cfg.Billing.Rounding.Level()),
+	}
+}
+
+// Validate validates the rounding policy
+func (p RoundingPolicy) Validate() error {
+	switch p.Mode {
+	case RoundingModeHalfUp, RoundingModeHalfEven, RoundingModeTruncate:
+	default:
+		return fmt.Errorf("invalid rounding mode %q, supported modes are half_up, half_even and truncate", p.Mode)
+	}
+
+	switch p.Level {
+	case RoundingLevelLine, RoundingLevelTotal:
+		return nil
+	default:
+		return fmt.Errorf("invalid rounding level %q, supported levels are line and total", p.Level)
+	}
+}
+
+// Round rounds amount to the minor units of currency using the policy's mode
+func (p RoundingPolicy) Round(currency Currency, amount decimal.Decimal) decimal.Decimal {
+	return currency.Round(amount, p.Mode)
+}
+
+// RoundLine rounds a line-level amount, which only happens when rounding at line level
+func (p RoundingPolicy) RoundLine(currency Currency, amount decimal.Decimal) decimal.Decimal {
+	if p.Level != RoundingLevelLine {
+		return amount
+	}
+	return p.Round(currency, amount)
+}
@@ -0,0 +1,137 @@
+package models
+
+import (
+	"slices"
+
+	"github.com/shopspring/decimal"
+)
+
+// GetBillOptions controls how much of a bill is loaded on read
+type GetBillOptions struct {
+	// IncludeLineItems loads every line item; otherwise only the summary and totals are returned
+	IncludeLineItems bool
+}
+
+// LineTotalGroup aggregates the line items of a bill sharing a currency, and the line amount when grouped per line.
+// Totals can be calculated from the groups without loading the line items.
+type LineTotalGroup struct {
+	Currency Currency `json:"currency"`
+	// LineAmount is the unrounded amount of each line in the group, set only when grouped per line amount
+	LineAmount decimal.Decimal `json:"line_amount"`
+	// Sum is the unrounded sum of all line amounts in the group
+	Sum   decimal.Decimal `json:"sum"`
+	Count int64           `json:"count"`
+}
+
+// BillSummary is a bill without its line items, along with the aggregated line totals
+type BillSummary struct {
+	Bill       *Bill            `json:"bill"`
+	LineTotals []LineTotalGroup `json:"line_totals"`
+}
+
+// GroupLineTotals aggregates line items per currency and line amount.
+// The groups can be used with either rounding level.
+func GroupLineTotals(items []*LineItem) []LineTotalGroup {
+	groups := make([]LineTotalGroup, 0)
+	for _, item := range items {
+		amount := item.UnitPrice.Mul(item.Quantity)
+		groups = addLineTotal(groups, LineTotalGroup{Currency: item.Currency, LineAmount: amount, Sum: amount, Count: 1})
+	}
+	return groups
+}
+
+// MergeLineTotals combines line totals grouped per currency and line amount into a new slice
+func MergeLineTotals(groups ...[]LineTotalGroup) []LineTotalGroup {
+	merged := make([]LineTotalGroup, 0)
+	for _, gs := range groups {
+		for _, g := range gs {
+			merged = addLineTotal(merged, g)
+		}
+	}
+	return merged
+}
+
+// RemoveLineTotal removes a line item from line totals grouped per currency and line amount
+func RemoveLineTotal(groups []LineTotalGroup, item *LineItem) []LineTotalGroup {
+	amount := item.UnitPrice.Mul(item.Quantity)
+	groups = addLineTotal(MergeLineTotals(groups), LineTotalGroup{
+		Currency:   item.Currency,
+		LineAmount: amount,
+		Sum:        amount.Neg(),
+		Count:      -1,
+	})
+	return slices.DeleteFunc(groups, func(g LineTotalGroup) bool { return g.Count <= 0 })
+}
+
+func addLineTotal(groups []LineTotalGroup, group LineTotalGroup) []LineTotalGroup {
+	i := slices.IndexFunc(groups, func(g LineTotalGroup) bool {
+		return g.Currency == group.Currency && g.LineAmount.Equal(group.LineAmount)
+	})
+	if i < 0 {
+		return append(groups, group)
+	}
+	groups[i].Sum = groups[i].Sum.Add(group.Sum)
+	groups[i].Count += group.Count
+	return groups
+}
+
+// CalculateSummary calculates the bill totals from aggregated line totals, with the same results as CalculateSum.
+// At line rounding level the groups must be grouped per line amount.
+func (b *Bill) CalculateSummary(groups []LineTotalGroup, rates *RatesData, policy RoundingPolicy) error {
+	b.LineItemCount = 0
+	for _, g := range groups {
+		b.LineItemCount += g.Count
+	}
+	if len(groups) == 0 {
+		return nil
+	}
+
+	// groupTotal is the sum of the line totals of a group, rounded per line at line level
+	groupTotal := func(g LineTotalGroup, rate decimal.Decimal, currency Currency) decimal.Decimal {
+		if policy.Level == RoundingLevelLine {
+			line := policy.RoundLine(g.Currency, g.LineAmount)
+			return policy.RoundLine(currency, line.Mul(rate)).Mul(decimal.NewFromInt(g.Count))
+		}
+		return g.Sum.Mul(rate)
+	}
+
+	one := decimal.NewFromInt(1)
+	b.Total = &Total{}
+	b.Total.ByCurrency = make(map[Currency]decimal.Decimal)
+	for _, g := range groups {
+		b.Total.ByCurrency[g.Currency] = b.Total.ByCurrency[g.Currency].Add(groupTotal(g, one, g.Currency))
+	}
+	for currency, amount := range b.Total.ByCurrency {
+		b.Total.ByCurrency[currency] = policy.Round(currency, amount)
+	}
+
+	presentment := b.PresentmentCurrency
+	if presentment == "" {
+		return nil
+	}
+
+	sum := decimal.Zero
+	for _, g := range groups {
+		rate, err := rates.ConversionRate(g.Currency, presentment)
+		if err != nil {
+			return err
+		}
+		sum = sum.Add(groupTotal(g, rate, presentment))
+	}
+
+	b.Total.GrandTotal = &Converted{
+		Currency:      presentment,
+		Amount:        policy.Round(presentment, sum),
+		RateUpdatedAt: rates.UpdatedAt,
+	}
+	return nil
+}
+
+// SumByCurrency returns the unrounded line total of each currency
+func SumByCurrency(groups []LineTotalGroup) map[Currency]decimal.Decimal {
+	totals := make(map[Currency]decimal.Decimal)
+	for _, g := range groups {
+		totals[g.Currency] = totals[g.Currency].Add(g.Sum)
+	}
+	return totals
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"encore.dev/types/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// BillWorkflowFilter filters the open bill workflows listed from Temporal visibility
+type BillWorkflowFilter struct {
+	CustomerID      string
+	PeriodEndAfter  *time.Time // inclusive
+	PeriodEndBefore *time.Time // exclusive
+	Limit           int
+	// PageToken is the token returned with the previous page, nil for the first page
+	PageToken []byte
+}
+
+// BillWorkflowSummary is an open bill as indexed by the search attributes of its workflow.
+// The index is eventually consistent, query the bill for its current state.
+type BillWorkflowSummary struct {
+	BillID     uuid.UUID  `json:"bill_id"`
+	WorkflowID string     `json:"workflow_id"`
+	RunID      string     `json:"run_id"`
+	CustomerID string     `json:"customer_id"`
+	Status     BillStatus `json:"status"`
+	PeriodEnd  *time.Time `json:"period_end,omitempty"`
+	// TotalByCurrency is the unrounded line total of each currency
+	TotalByCurrency map[Currency]decimal.Decimal `json:"total_by_currency"`
+	StartedAt       time.Time                    `json:"started_at"`
+}
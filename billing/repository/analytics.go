@@ -0,0 +1,226 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"encore.app/billing/models"
+	"encore.dev/rlog"
+)
+
+// RefreshRevenueRollups rebuilds the revenue rollups of the months from the month of from on in one transaction.
+// The rebuild starts earlier when bills changed status since the last refresh, e.g. voided or reopened bills,
+// that were billed or spread recurring charges before from.
+// Concurrent refreshes wait for each other, reports keep reading the previous rollups until the commit.
+func (r *SQLRepository) RefreshRevenueRollups(ctx context.Context, from, refreshedAt time.Time) (*models.RollupRefresh, error) {
+	log := rlog.With("module", "billing_repository").With("from", from)
+	log.Info("refreshing revenue rollups in database")
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		log.Error("failed to begin transaction", "error", err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(ctx, `LOCK TABLE revenue_rollups IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+		log.Error("failed to lock revenue rollups", "error", err)
+		return nil, err
+	}
+
+	// Recurring charges are spread from the month the period starts in, and bills close after their period starts,
+	// so the period start also bounds the months of reopened bills whose close time is cleared.
+	// Before the first refresh there are no rollups to correct.
+	var changedFrom sql.NullTime
+	if err = tx.QueryRow(ctx, `
+		SELECT MIN(LEAST(period_start, closed_at))
+		FROM bills
+		WHERE updated_at > (SELECT refreshed_at FROM revenue_rollup_refreshes)
+	`).Scan(&changedFrom); err != nil {
+		log.Error("failed to find bills changed since the last refresh", "error", err)
+		return nil, err
+	}
+	if changedFrom.Valid && changedFrom.Time.Before(from) {
+		log.Info("rebuilding months of bills changed since the last refresh", "changed_from", changedFrom.Time)
+		from = changedFrom.Time
+	}
+	from = models.StartOfMonth(from)
+	fromMonth := from.Format(time.DateOnly)
+	if _, err = tx.Exec(ctx, `DELETE FROM revenue_rollups WHERE month >= $1::date`, fromMonth); err != nil {
+		log.Error("failed to delete revenue rollups", "error", err)
+		return nil, err
+	}
+
+	// Bills are billed their totals persisted at close, or the sum of their line items when closed before totals were
+	// persisted. Recurring charges are spread over the months from the one the period starts in to the one it ends in.
+	result, err := tx.Exec(ctx, `
+		WITH revenue_bills AS (
+			SELECT id, customer_id, closed_at, period_start, period_end
+			FROM bills
+			WHERE status IN ('closed', 'finalized')
+		),
+		billed AS (
+			SELECT b.customer_id, b.closed_at, t.currency, t.amount
+			FROM revenue_bills b
+			JOIN bill_totals t ON t.bill_id = b.id
+			WHERE b.closed_at >= $1
+			UNION ALL
+			SELECT b.customer_id, b.closed_at, li.currency, SUM(li.quantity * li.unit_price)
+			FROM revenue_bills b
+			JOIN line_items li ON li.bill_id = b.id AND li.deleted_at IS NULL
+			WHERE b.closed_at >= $1 AND NOT EXISTS (SELECT 1 FROM bill_totals t WHERE t.bill_id = b.id)
+			GROUP BY b.id, b.customer_id, b.closed_at, li.currency
+		),
+		recurring AS (
+			SELECT b.id AS bill_id, b.customer_id, b.period_start, b.period_end, li.currency,
+			       SUM(li.quantity * li.unit_price) AS amount
+			FROM revenue_bills b
+			JOIN line_items li ON li.bill_id = b.id AND li.deleted_at IS NULL AND li.recurring
+			WHERE b.period_end > $1
+			GROUP BY b.id, b.customer_id, b.period_start, b.period_end, li.currency
+		),
+		spread AS (
+			SELECT m.month::date AS month, r.customer_id, r.currency,
+			       r.amount / COUNT(*) OVER (PARTITION BY r.bill_id, r.currency) AS amount
+			FROM recurring r
+			CROSS JOIN LATERAL generate_series(
+				date_trunc('month', r.period_start AT TIME ZONE 'UTC'),
+				date_trunc('month', (r.period_end - INTERVAL '1 microsecond') AT TIME ZONE 'UTC'),
+				INTERVAL '1 month'
+			) AS m(month)
+		)
+		INSERT INTO revenue_rollups (month, customer_id, currency, billed, recurring)
+		SELECT month, customer_id, currency, SUM(billed), SUM(recurring)
+		FROM (
+			SELECT date_trunc('month', closed_at AT TIME ZONE 'UTC')::date AS month, customer_id, currency,
+			       amount AS billed, 0 AS recurring
+			FROM billed
+			UNION ALL
+			SELECT month, customer_id, currency, 0, amount
+			FROM spread
+			WHERE month >= $2::date
+		) revenue
+		GROUP BY month, customer_id, currency
+	`, from, fromMonth)
+	if err != nil {
+		log.Error("failed to rebuild revenue rollups", "error", err)
+		return nil, err
+	}
+
+	refresh := &models.RollupRefresh{From: from, RefreshedAt: refreshedAt, Rows: result.RowsAffected()}
+	if _, err = tx.Exec(ctx, `
+		INSERT INTO revenue_rollup_refreshes (singleton, from_month, refreshed_at, row_count)
+		VALUES (true, $1, $2, $3)
+		ON CONFLICT (singleton) DO UPDATE
+		SET from_month = EXCLUDED.from_month, refreshed_at = EXCLUDED.refreshed_at, row_count = EXCLUDED.row_count
+	`, fromMonth, refreshedAt, refresh.Rows); err != nil {
+		log.Error("failed to record revenue rollups refresh", "error", err)
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Error("failed to commit revenue rollups refresh", "error", err)
+		return nil, err
+	}
+
+	log.Info("revenue rollups refreshed successfully", "rows", refresh.Rows)
+	return refresh, nil
+}
+
+// GetRevenueRollupsRefresh returns the last refresh of the revenue rollups, sql.ErrNoRows when never refreshed
+func (r *SQLRepository) GetRevenueRollupsRefresh(ctx context.Context) (*models.RollupRefresh, error) {
+	log := rlog.With("module", "billing_repository")
+	log.Debug("retrieving revenue rollups refresh from database")
+
+	var refresh models.RollupRefresh
+	err := r.db.QueryRow(ctx, `
+		SELECT from_month, refreshed_at, row_count
+		FROM revenue_rollup_refreshes
+	`).Scan(&refresh.From, &refresh.RefreshedAt, &refresh.Rows)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Error("failed to retrieve revenue rollups refresh from database", "error", err)
+		}
+		return nil, err
+	}
+	return &refresh, nil
+}
+
+// ListRevenueByPeriod totals the billed revenue of the rollups from the month of from until the month of to
+// per period of the granularity and currency, ordered by period then currency
+func (r *SQLRepository) ListRevenueByPeriod(
+	ctx context.Context, from, to time.Time, granularity models.Granularity,
+) ([]models.PeriodRevenue, error) {
+	log := rlog.With("module", "billing_repository").With("from", from).With("to", to)
+	log.Info("listing revenue by period from database", "granularity", granularity)
+
+	rows, err := r.db.Query(ctx, `
+		SELECT date_trunc($3, month::timestamp)::date AS period_start, currency, SUM(billed)
+		FROM revenue_rollups
+		WHERE month >= $1::date AND month < $2::date AND billed <> 0
+		GROUP BY period_start, currency
+		ORDER BY period_start, currency
+	`, models.StartOfMonth(from).Format(time.DateOnly), models.StartOfMonth(to).Format(time.DateOnly), string(granularity))
+	if err != nil {
+		log.Error("failed to list revenue by period from database", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	revenue := make([]models.PeriodRevenue, 0)
+	for rows.Next() {
+		var period models.PeriodRevenue
+		if err = rows.Scan(&period.PeriodStart, &period.Currency, &period.Billed); err != nil {
+			log.Error("failed to scan period revenue row", "error", err)
+			return nil, err
+		}
+		revenue = append(revenue, period)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Error("error iterating period revenue rows", "error", err)
+		return nil, err
+	}
+
+	log.Info("revenue by period listed successfully", "count", len(revenue))
+	return revenue, nil
+}
+
+// ListRecurringRevenue returns the monthly recurring revenue of the rollups from the month of from until the month
+// of to per customer and currency, ordered by month, customer then currency
+func (r *SQLRepository) ListRecurringRevenue(ctx context.Context, from, to time.Time) ([]models.RecurringRevenue, error) {
+	log := rlog.With("module", "billing_repository").With("from", from).With("to", to)
+	log.Info("listing recurring revenue from database")
+
+	rows, err := r.db.Query(ctx, `
+		SELECT month, customer_id, currency, recurring
+		FROM revenue_rollups
+		WHERE month >= $1::date AND month < $2::date AND recurring <> 0
+		ORDER BY month, customer_id, currency
+	`, models.StartOfMonth(from).Format(time.DateOnly), models.StartOfMonth(to).Format(time.DateOnly))
+	if err != nil {
+		log.Error("failed to list recurring revenue from database", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	recurring := make([]models.RecurringRevenue, 0)
+	for rows.Next() {
+		var revenue models.RecurringRevenue
+		if err = rows.Scan(&revenue.Month, &revenue.CustomerID, &revenue.Currency, &revenue.MRR); err != nil {
+			log.Error("failed to scan recurring revenue row", "error", err)
+			return nil, err
+		}
+		recurring = append(recurring, revenue)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Error("error iterating recurring revenue rows", "error", err)
+		return nil, err
+	}
+
+	log.Info("recurring revenue listed successfully", "count", len(recurring))
+	return recurring, nil
+}
@@ -0,0 +1,208 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"encore.app/billing/models"
+	"encore.dev/rlog"
+	"encore.dev/storage/sqldb"
+	"encore.dev/types/uuid"
+)
+
+// auditChange is a mutation of a bill or one of its line items to record in the audit log
+type auditChange struct {
+	billID     uuid.UUID
+	entityType models.AuditEntityType
+	entityID   uuid.UUID
+	action     models.AuditAction
+	// before and after are the mutated row as JSON, before is nil on creation
+	before []byte
+	after  []byte
+}
+
+func billChange(billID uuid.UUID, action models.AuditAction) auditChange {
+	return auditChange{billID: billID, entityType: models.AuditEntityBill, entityID: billID, action: action}
+}
+
+func lineItemChange(billID uuid.UUID, lineItemID uuid.UUID, action models.AuditAction) auditChange {
+	return auditChange{billID: billID, entityType: models.AuditEntityLineItem, entityID: lineItemID, action: action}
+}
+
+// lockBill locks the bill until the transaction ends, so that the audit events of the bill are appended in order,
+// and returns the bill row as JSON, sql.ErrNoRows when not found
+func lockBill(ctx context.Context, tx *sqldb.Tx, billID uuid.UUID) ([]byte, error) {
+	var state []byte
+	err := tx.QueryRow(ctx, `SELECT to_jsonb(b) FROM bills b WHERE id = $1 FOR NO KEY UPDATE`, billID).Scan(&state)
+	return state, err
+}
+
+// getLineItemState returns the line item row as JSON, nil when not found
+func getLineItemState(ctx context.Context, tx *sqldb.Tx, billID uuid.UUID, lineItemID uuid.UUID) ([]byte, error) {
+	var state []byte
+	err := tx.QueryRow(ctx, `SELECT to_jsonb(li) FROM line_items li WHERE id = $1 AND bill_id = $2`,
+		lineItemID, billID).Scan(&state)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return state, err
+}
+
+// auditedUpdate locks the bill, runs the update returning the updated row as JSON and appends the audit event of the change,
+// returning sql.ErrNoRows when the bill is not found or the update matches no row
+func auditedUpdate(ctx context.Context, tx *sqldb.Tx, change auditChange, query string, args ...interface{}) error {
+	before, err := lockBill(ctx, tx, change.billID)
+	if err != nil {
+		return err
+	}
+	change.before = before
+	if change.entityType == models.AuditEntityLineItem {
+		if change.before, err = getLineItemState(ctx, tx, change.billID, change.entityID); err != nil {
+			return err
+		}
+	}
+	if err = tx.QueryRow(ctx, query, args...).Scan(&change.after); err != nil {
+		return err
+	}
+	return appendAuditEvent(ctx, tx, change)
+}
+
+// appendAuditEvent chains the event of the change after the last event of the bill.
+// Idempotent repeats only touching updated_at are not recorded.
+// The bill must be locked by the transaction.
+func appendAuditEvent(ctx context.Context, tx *sqldb.Tx, change auditChange) error {
+	unchanged, err := sameState(change.before, change.after)
+	if err != nil || unchanged {
+		return err
+	}
+
+	var prev *models.AuditEvent
+	var last models.AuditEvent
+	err = tx.QueryRow(ctx, `
+		SELECT sequence, hash FROM audit_events WHERE bill_id = $1 ORDER BY sequence DESC LIMIT 1
+	`, change.billID).Scan(&last.Sequence, &last.Hash)
+	switch {
+	case err == nil:
+		prev = &last
+	case !errors.Is(err, sql.ErrNoRows):
+		return err
+	}
+
+	event, err := models.NewAuditEvent(ctx, prev, change.billID, change.entityType, change.entityID, change.action,
+		change.before, change.after, time.Now())
+	if err != nil {
+		return err
+	}
+
+	var before *string
+	if change.before != nil {
+		state := string(event.Before)
+		before = &state
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO audit_events (id, bill_id, sequence, entity_type, entity_id, action, actor, request_id, workflow_id, run_id,
+		                          before, after, occurred_at, prev_hash, hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11::json, $12::json, $13, $14, $15)
+	`,
+		event.ID,
+		event.BillID,
+		event.Sequence,
+		event.EntityType,
+		event.EntityID,
+		event.Action,
+		event.Actor,
+		event.RequestID,
+		event.WorkflowID,
+		event.RunID,
+		before,
+		string(event.After),
+		event.OccurredAt,
+		event.PrevHash,
+		event.Hash,
+	)
+	return err
+}
+
+// sameState reports whether the rows only differ by their updated_at
+func sameState(before, after []byte) (bool, error) {
+	if before == nil {
+		return false, nil
+	}
+	normalize := func(state []byte) ([]byte, error) {
+		var row map[string]json.RawMessage
+		if err := json.Unmarshal(state, &row); err != nil {
+			return nil, err
+		}
+		delete(row, "updated_at")
+		return json.Marshal(row)
+	}
+	b, err := normalize(before)
+	if err != nil {
+		return false, err
+	}
+	a, err := normalize(after)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(b, a), nil
+}
+
+// ListAuditEvents returns the audit events of the bill ordered by sequence
+func (r *SQLRepository) ListAuditEvents(ctx context.Context, billID uuid.UUID) ([]*models.AuditEvent, error) {
+	log := rlog.With("module", "billing_repository").With("bill_id", billID.String())
+	log.Info("listing audit events from database")
+
+	rows, err := r.db.Query(ctx, `
+		SELECT id, bill_id, sequence, entity_type, entity_id, action, actor, request_id, workflow_id, run_id,
+		       COALESCE(before::text, 'null'), after::text, occurred_at, prev_hash, hash
+		FROM audit_events
+		WHERE bill_id = $1
+		ORDER BY sequence
+	`, billID)
+	if err != nil {
+		log.Error("failed to list audit events", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*models.AuditEvent{}
+	for rows.Next() {
+		var event models.AuditEvent
+		var before, after string
+		if err = rows.Scan(
+			&event.ID,
+			&event.BillID,
+			&event.Sequence,
+			&event.EntityType,
+			&event.EntityID,
+			&event.Action,
+			&event.Actor,
+			&event.RequestID,
+			&event.WorkflowID,
+			&event.RunID,
+			&before,
+			&after,
+			&event.OccurredAt,
+			&event.PrevHash,
+			&event.Hash,
+		); err != nil {
+			log.Error("failed to scan audit event", "error", err)
+			return nil, err
+		}
+		event.Before = json.RawMessage(before)
+		event.After = json.RawMessage(after)
+		event.OccurredAt = event.OccurredAt.UTC()
+		events = append(events, &event)
+	}
+	if err = rows.Err(); err != nil {
+		log.Error("failed to iterate audit events", "error", err)
+		return nil, err
+	}
+
+	log.Info("audit events listed successfully", "count", len(events))
+	return events, nil
+}
@@ -0,0 +1,232 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"encore.app/billing/models"
+	"encore.dev/rlog"
+	"encore.dev/types/uuid"
+)
+
+func (r *SQLRepository) CreateBankTransaction(ctx context.Context, txn *models.BankTransaction) (bool, error) {
+	log := rlog.With("module", "billing_repository").With("bank_transaction_id", txn.ID.String())
+	log.Info("creating bank transaction in database", "entry_reference", txn.EntryReference)
+
+	candidates, err := json.Marshal(txn.CandidateBillIDs)
+	if err != nil {
+		log.Error("failed to encode candidate bills", "error", err)
+		return false, err
+	}
+
+	var id uuid.UUID
+	err = r.db.QueryRow(ctx, `
+		INSERT INTO bank_transactions (id, entry_reference, booked_at, currency, amount, reference, counterparty,
+		                               status, bill_id, candidate_bill_ids, match_reason, created_at, updated_at,
+		                               resolved_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		ON CONFLICT (entry_reference) DO NOTHING
+		RETURNING id
+	`,
+		txn.ID,
+		txn.EntryReference,
+		txn.BookedAt,
+		txn.Currency,
+		txn.Amount,
+		txn.Reference,
+		txn.Counterparty,
+		txn.Status,
+		txn.BillID,
+		candidates,
+		txn.MatchReason,
+		txn.CreatedAt,
+		txn.UpdatedAt,
+		txn.ResolvedAt,
+	).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Info("bank transaction with the same entry reference already imported")
+		return false, nil
+	}
+	if err != nil {
+		log.Error("failed to create bank transaction in database", "error", err)
+		return false, err
+	}
+
+	log.Info("bank transaction created successfully")
+	return true, nil
+}
+
+func (r *SQLRepository) UpdateBankTransactionMatch(ctx context.Context, txn *models.BankTransaction) error {
+	log := rlog.With("module", "billing_repository").With("bank_transaction_id", txn.ID.String())
+	log.Info("updating bank transaction match in database", "status", txn.Status, "match_reason", txn.MatchReason)
+
+	candidates, err := json.Marshal(txn.CandidateBillIDs)
+	if err != nil {
+		log.Error("failed to encode candidate bills", "error", err)
+		return err
+	}
+
+	result, err := r.db.Exec(ctx, `
+		UPDATE bank_transactions
+		SET status = $1, bill_id = $2, candidate_bill_ids = $3, match_reason = $4, updated_at = $5, resolved_at = $6,
+		    resolution_note = NULLIF($7, '')
+		WHERE id = $8 AND status = 'review'
+	`, txn.Status, txn.BillID, candidates, txn.MatchReason, txn.UpdatedAt, txn.ResolvedAt, txn.ResolutionNote, txn.ID)
+	if err != nil {
+		log.Error("failed to update bank transaction match in database", "error", err)
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		log.Warn("no rows affected when updating bank transaction - transaction may be resolved already or not found")
+		return sql.ErrNoRows
+	}
+
+	log.Info("bank transaction match updated successfully in database")
+	return nil
+}
+
+func (r *SQLRepository) ListBankTransactions(ctx context.Context, filter models.BankTransactionFilter) ([]*models.BankTransaction, error) {
+	log := rlog.With("module", "billing_repository")
+	log.Info("listing bank transactions from database", "status", filter.Status, "limit", filter.Limit)
+
+	rows, err := r.db.Query(ctx, `
+		SELECT `+bankTransactionColumns+`
+		FROM bank_transactions
+		WHERE ($1 = '' OR status = $1)
+		ORDER BY booked_at DESC, id
+		LIMIT $2
+	`, string(filter.Status), filter.Limit)
+	if err != nil {
+		log.Error("failed to list bank transactions from database", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	txns := make([]*models.BankTransaction, 0)
+	for rows.Next() {
+		txn, err := scanBankTransaction(rows)
+		if err != nil {
+			log.Error("failed to scan bank transaction row", "error", err)
+			return nil, err
+		}
+		txns = append(txns, txn)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Error("error iterating bank transaction rows", "error", err)
+		return nil, err
+	}
+
+	log.Info("bank transactions listed successfully", "count", len(txns))
+	return txns, nil
+}
+
+func (r *SQLRepository) GetBankTransaction(ctx context.Context, id uuid.UUID) (*models.BankTransaction, error) {
+	log := rlog.With("module", "billing_repository").With("bank_transaction_id", id.String())
+	log.Info("retrieving bank transaction from database")
+
+	txn, err := scanBankTransaction(r.db.QueryRow(ctx, `
+		SELECT `+bankTransactionColumns+`
+		FROM bank_transactions
+		WHERE id = $1
+	`, id))
+	if err != nil {
+		log.Error("failed to retrieve bank transaction from database", "error", err)
+		return nil, err
+	}
+
+	return txn, nil
+}
+
+// ListReceivables returns the positive balances of the account in the currency per closed or finalized bill,
+// ordered by bill ID
+func (r *SQLRepository) ListReceivables(
+	ctx context.Context, account string, currency models.Currency,
+) ([]models.Receivable, error) {
+	log := rlog.With("module", "billing_repository").With("account", account)
+	log.Info("listing receivables from database", "currency", currency)
+
+	rows, err := r.db.Query(ctx, `
+		SELECT b.id, b.customer_id, e.currency,
+		       SUM(CASE WHEN e.side = 'debit' THEN e.amount ELSE -e.amount END) AS outstanding
+		FROM ledger_entries e
+		JOIN ledger_journals j ON j.id = e.journal_id
+		JOIN bills b ON b.id = j.bill_id
+		WHERE e.account = $1 AND e.currency = $2 AND b.status IN ('closed', 'finalized')
+		GROUP BY b.id, b.customer_id, e.currency
+		HAVING SUM(CASE WHEN e.side = 'debit' THEN e.amount ELSE -e.amount END) > 0
+		ORDER BY b.id
+	`, account, currency)
+	if err != nil {
+		log.Error("failed to list receivables from database", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	receivables := make([]models.Receivable, 0)
+	for rows.Next() {
+		var receivable models.Receivable
+		if err = rows.Scan(
+			&receivable.BillID,
+			&receivable.CustomerID,
+			&receivable.Currency,
+			&receivable.Outstanding,
+		); err != nil {
+			log.Error("failed to scan receivable row", "error", err)
+			return nil, err
+		}
+		receivables = append(receivables, receivable)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Error("error iterating receivable rows", "error", err)
+		return nil, err
+	}
+
+	log.Info("receivables listed successfully", "count", len(receivables))
+	return receivables, nil
+}
+
+const bankTransactionColumns = `id, entry_reference, booked_at, currency, amount, reference, counterparty, status,
+	bill_id, candidate_bill_ids, match_reason, created_at, updated_at, resolved_at, COALESCE(resolution_note, '')`
+
+// scanBankTransaction reads a bank transaction selected with bankTransactionColumns from a row
+func scanBankTransaction(row interface{ Scan(dest ...any) error }) (*models.BankTransaction, error) {
+	var txn models.BankTransaction
+	var billID uuid.NullUUID
+	var candidates []byte
+	var resolvedAt sql.NullTime
+	err := row.Scan(
+		&txn.ID,
+		&txn.EntryReference,
+		&txn.BookedAt,
+		&txn.Currency,
+		&txn.Amount,
+		&txn.Reference,
+		&txn.Counterparty,
+		&txn.Status,
+		&billID,
+		&candidates,
+		&txn.MatchReason,
+		&txn.CreatedAt,
+		&txn.UpdatedAt,
+		&resolvedAt,
+		&txn.ResolutionNote,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if billID.Valid {
+		txn.BillID = &billID.UUID
+	}
+	if err = json.Unmarshal(candidates, &txn.CandidateBillIDs); err != nil {
+		return nil, err
+	}
+	if resolvedAt.Valid {
+		txn.ResolvedAt = &resolvedAt.Time
+	}
+	return &txn, nil
+}
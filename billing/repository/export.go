@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"encore.app/billing/models"
+	"encore.dev/rlog"
+	"encore.dev/types/uuid"
+)
+
+// ListClosedBills returns closed and finalized bills closed from closedFrom until closedTo, ordered by ID,
+// starting after the given ID
+func (r *SQLRepository) ListClosedBills(
+	ctx context.Context, closedFrom, closedTo time.Time, after uuid.UUID, limit int,
+) ([]*models.Bill, error) {
+	log := rlog.With("module", "billing_repository").With("after", after.String())
+	log.Info("listing closed bills from database", "closed_from", closedFrom, "closed_to", closedTo, "limit", limit)
+
+	rows, err := r.db.Query(ctx, `
+		SELECT `+billSummaryColumns+`
+		FROM bills
+		WHERE status IN ('closed', 'finalized') AND closed_at >= $1 AND closed_at < $2 AND id > $3
+		ORDER BY id
+		LIMIT $4
+	`, closedFrom, closedTo, after, limit)
+	if err != nil {
+		log.Error("failed to list closed bills from database", "error", err)
+		return nil, err
+	}
+
+	bills, err := scanBillSummaries(rows)
+	if err != nil {
+		log.Error("failed to scan closed bill rows", "error", err)
+		return nil, err
+	}
+
+	log.Info("closed bills listed successfully", "count", len(bills))
+	return bills, nil
+}
+
+func (r *SQLRepository) CreateExportJob(ctx context.Context, job *models.ExportJob) error {
+	log := rlog.With("module", "billing_repository").With("export_id", job.ID.String())
+	log.Info("creating export job in database", "format", job.Format, "from", job.From, "to", job.To)
+
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO export_jobs (id, format, range_from, range_to, status, workflow_id, object_key, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (id) DO NOTHING
+	`, job.ID, job.Format, job.From, job.To, job.Status, job.WorkflowID, job.ObjectKey, job.CreatedAt)
+	if err != nil {
+		log.Error("failed to create export job in database", "error", err)
+		return err
+	}
+
+	log.Info("export job created successfully")
+	return nil
+}
+
+func (r *SQLRepository) GetExportJob(ctx context.Context, id uuid.UUID) (*models.ExportJob, error) {
+	log := rlog.With("module", "billing_repository").With("export_id", id.String())
+	log.Info("retrieving export job from database")
+
+	var job models.ExportJob
+	var startedAt, completedAt sql.NullTime
+	err := r.db.QueryRow(ctx, `
+		SELECT id, format, range_from, range_to, status, workflow_id, object_key, row_count, COALESCE(error, ''),
+		       created_at, started_at, completed_at
+		FROM export_jobs
+		WHERE id = $1
+	`, id).Scan(
+		&job.ID,
+		&job.Format,
+		&job.From,
+		&job.To,
+		&job.Status,
+		&job.WorkflowID,
+		&job.ObjectKey,
+		&job.Rows,
+		&job.Error,
+		&job.CreatedAt,
+		&startedAt,
+		&completedAt,
+	)
+	if err != nil {
+		log.Error("failed to retrieve export job from database", "error", err)
+		return nil, err
+	}
+
+	if startedAt.Valid {
+		job.StartedAt = &startedAt.Time
+	}
+	if completedAt.Valid {
+		job.CompletedAt = &completedAt.Time
+	}
+	return &job, nil
+}
+
+func (r *SQLRepository) MarkExportJobRunning(ctx context.Context, id uuid.UUID, startedAt time.Time) error {
+	return r.updateExportJob(ctx, id, models.ExportStatusRunning, `
+		UPDATE export_jobs
+		SET status = 'running', started_at = $2
+		WHERE id = $1 AND status IN ('pending', 'running')
+	`, id, startedAt)
+}
+
+func (r *SQLRepository) CompleteExportJob(ctx context.Context, id uuid.UUID, rows int, completedAt time.Time) error {
+	return r.updateExportJob(ctx, id, models.ExportStatusCompleted, `
+		UPDATE export_jobs
+		SET status = 'completed', row_count = $2, completed_at = $3
+		WHERE id = $1 AND (status = 'running' OR (status = 'completed' AND completed_at = $3))
+	`, id, rows, completedAt)
+}
+
+func (r *SQLRepository) FailExportJob(ctx context.Context, id uuid.UUID, message string, failedAt time.Time) error {
+	return r.updateExportJob(ctx, id, models.ExportStatusFailed, `
+		UPDATE export_jobs
+		SET status = 'failed', error = $2, completed_at = $3
+		WHERE id = $1 AND (status IN ('pending', 'running') OR (status = 'failed' AND completed_at = $3))
+	`, id, message, failedAt)
+}
+
+// updateExportJob moves the export job to the status, returning sql.ErrNoRows when the update matches no job
+func (r *SQLRepository) updateExportJob(
+	ctx context.Context, id uuid.UUID, status models.ExportStatus, query string, args ...interface{},
+) error {
+	log := rlog.With("module", "billing_repository").With("export_id", id.String())
+	log.Info("updating export job in database", "status", status)
+
+	result, err := r.db.Exec(ctx, query, args...)
+	if err != nil {
+		log.Error("failed to update export job in database", "error", err)
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		log.Warn("no rows affected when updating export job - job may be finished already or not found")
+		return sql.ErrNoRows
+	}
+
+	log.Info("export job updated successfully in database")
+	return nil
+}
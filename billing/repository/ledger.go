@@ -0,0 +1,318 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"encore.app/billing/models"
+	"encore.dev/rlog"
+	"encore.dev/storage/sqldb"
+	"encore.dev/types/uuid"
+)
+
+// queryer runs queries on the database or in a transaction
+type queryer interface {
+	Query(ctx context.Context, query string, args ...interface{}) (*sqldb.Rows, error)
+}
+
+// insertJournal inserts the balanced journal and its entries,
+// returning false when a journal with the same key is already posted
+func insertJournal(ctx context.Context, tx *sqldb.Tx, journal *models.Journal) (bool, error) {
+	if err := journal.Validate(); err != nil {
+		return false, err
+	}
+
+	var id uuid.UUID
+	err := tx.QueryRow(ctx, `
+		INSERT INTO ledger_journals (id, key, type, bill_id, reverses_journal_id, reference, description, posted_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (key) DO NOTHING
+		RETURNING id
+	`,
+		journal.ID,
+		journal.Key,
+		journal.Type,
+		journal.BillID,
+		journal.ReversesID,
+		journal.Reference,
+		journal.Description,
+		journal.PostedAt,
+	).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	for i, entry := range journal.Entries {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO ledger_entries (journal_id, line_no, account, side, currency, amount, functional_currency, functional_amount)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		`, journal.ID, i+1, entry.Account, entry.Side, entry.Currency, entry.Amount, entry.FunctionalCurrency, entry.FunctionalAmount)
+		if err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// reverseBillClosedJournals posts the reversal of the bill_closed journals of the bill that are not reversed yet.
+// The bill must be locked by the transaction.
+func reverseBillClosedJournals(ctx context.Context, tx *sqldb.Tx, billID uuid.UUID, postedAt time.Time) error {
+	journals, err := listJournals(ctx, tx, `
+		WHERE j.bill_id = $1 AND j.type = $2
+		  AND NOT EXISTS (SELECT 1 FROM ledger_journals r WHERE r.reverses_journal_id = j.id)
+	`, billID, models.JournalBillClosed)
+	if err != nil {
+		return err
+	}
+	for _, journal := range journals {
+		reversal, err := journal.Reverse(postedAt)
+		if err != nil {
+			return err
+		}
+		if _, err = insertJournal(ctx, tx, reversal); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listJournals returns the journals matching the condition on ledger_journals j with their entries,
+// ordered by posting time
+func listJournals(ctx context.Context, q queryer, condition string, args ...interface{}) ([]*models.Journal, error) {
+	rows, err := q.Query(ctx, `
+		SELECT j.id, j.key, j.type, j.bill_id, j.reverses_journal_id, j.reference, j.description, j.posted_at,
+		       e.account, e.side, e.currency, e.amount, e.functional_currency, e.functional_amount
+		FROM ledger_journals j
+		JOIN ledger_entries e ON e.journal_id = j.id
+		`+condition+`
+		ORDER BY j.posted_at, j.created_at, j.id, e.line_no
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	journals := []*models.Journal{}
+	var current *models.Journal
+	for rows.Next() {
+		var journal models.Journal
+		var reversesID uuid.NullUUID
+		var entry models.LedgerEntry
+		if err = rows.Scan(
+			&journal.ID,
+			&journal.Key,
+			&journal.Type,
+			&journal.BillID,
+			&reversesID,
+			&journal.Reference,
+			&journal.Description,
+			&journal.PostedAt,
+			&entry.Account,
+			&entry.Side,
+			&entry.Currency,
+			&entry.Amount,
+			&entry.FunctionalCurrency,
+			&entry.FunctionalAmount,
+		); err != nil {
+			return nil, err
+		}
+		if current == nil || current.ID != journal.ID {
+			if reversesID.Valid {
+				journal.ReversesID = &reversesID.UUID
+			}
+			journal.PostedAt = journal.PostedAt.UTC()
+			journal.Entries = []*models.LedgerEntry{}
+			current = &journal
+			journals = append(journals, current)
+		}
+		current.Entries = append(current.Entries, &entry)
+	}
+	return journals, rows.Err()
+}
+
+// PostBillJournals locks the bill and posts the journals built from its status and posted journals in one transaction,
+// failing with ErrJournalAlreadyPosted when one of them is already posted
+func (r *SQLRepository) PostBillJournals(
+	ctx context.Context,
+	billID uuid.UUID,
+	build func(status models.BillStatus, ledger []*models.Journal) ([]*models.Journal, error),
+) ([]*models.Journal, error) {
+	log := rlog.With("module", "billing_repository").With("bill_id", billID.String())
+	log.Info("posting bill journals to database")
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		log.Error("failed to begin transaction", "error", err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	state, err := lockBill(ctx, tx, billID)
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Warn("bill not found when posting journals")
+		return nil, sql.ErrNoRows
+	}
+	if err != nil {
+		log.Error("failed to lock bill", "error", err)
+		return nil, err
+	}
+	var bill struct {
+		Status models.BillStatus `json:"status"`
+	}
+	if err = json.Unmarshal(state, &bill); err != nil {
+		log.Error("failed to decode bill", "error", err)
+		return nil, err
+	}
+
+	ledger, err := listJournals(ctx, tx, `WHERE j.bill_id = $1`, billID)
+	if err != nil {
+		log.Error("failed to list bill journals", "error", err)
+		return nil, err
+	}
+	journals, err := build(bill.Status, ledger)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, journal := range journals {
+		posted, err := insertJournal(ctx, tx, journal)
+		if err != nil {
+			log.Error("failed to post journal", "key", journal.Key, "error", err)
+			return nil, err
+		}
+		if !posted {
+			log.Warn("journal already posted", "key", journal.Key)
+			return nil, models.ErrJournalAlreadyPosted
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Error("failed to commit bill journals", "error", err)
+		return nil, err
+	}
+
+	log.Info("bill journals posted successfully", "count", len(journals))
+	return journals, nil
+}
+
+// ListBillJournals returns the journals posted for the bill ordered by posting time
+func (r *SQLRepository) ListBillJournals(ctx context.Context, billID uuid.UUID) ([]*models.Journal, error) {
+	log := rlog.With("module", "billing_repository").With("bill_id", billID.String())
+	log.Info("listing bill journals from database")
+
+	journals, err := listJournals(ctx, r.db, `WHERE j.bill_id = $1`, billID)
+	if err != nil {
+		log.Error("failed to list bill journals", "error", err)
+		return nil, err
+	}
+
+	log.Info("bill journals listed successfully", "count", len(journals))
+	return journals, nil
+}
+
+// ListJournals returns the journals posted from postedFrom until postedTo ordered by posting time
+func (r *SQLRepository) ListJournals(ctx context.Context, postedFrom, postedTo time.Time) ([]*models.Journal, error) {
+	log := rlog.With("module", "billing_repository")
+	log.Info("listing journals from database", "posted_from", postedFrom, "posted_to", postedTo)
+
+	journals, err := listJournals(ctx, r.db, `WHERE j.posted_at >= $1 AND j.posted_at < $2`, postedFrom, postedTo)
+	if err != nil {
+		log.Error("failed to list journals", "error", err)
+		return nil, err
+	}
+
+	log.Info("journals listed successfully", "count", len(journals))
+	return journals, nil
+}
+
+// GetTrialBalance totals the entries valued in the functional currency of the journals posted up to asOf
+// per account and currency, and checks every journal is balanced
+func (r *SQLRepository) GetTrialBalance(
+	ctx context.Context, asOf time.Time, functional models.Currency,
+) (*models.TrialBalance, error) {
+	log := rlog.With("module", "billing_repository").With("as_of", asOf)
+	log.Info("computing trial balance from database", "functional_currency", functional)
+
+	rows, err := r.db.Query(ctx, `
+		SELECT e.account, e.currency,
+		       COALESCE(SUM(e.amount) FILTER (WHERE e.side = 'debit'), 0),
+		       COALESCE(SUM(e.amount) FILTER (WHERE e.side = 'credit'), 0),
+		       COALESCE(SUM(e.functional_amount) FILTER (WHERE e.side = 'debit'), 0),
+		       COALESCE(SUM(e.functional_amount) FILTER (WHERE e.side = 'credit'), 0)
+		FROM ledger_entries e
+		JOIN ledger_journals j ON j.id = e.journal_id
+		WHERE j.posted_at <= $1 AND e.functional_currency = $2
+		GROUP BY e.account, e.currency
+		ORDER BY e.account, e.currency
+	`, asOf, functional)
+	if err != nil {
+		log.Error("failed to compute trial balance", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	lines := []models.TrialBalanceLine{}
+	for rows.Next() {
+		var line models.TrialBalanceLine
+		if err = rows.Scan(
+			&line.Account,
+			&line.Currency,
+			&line.Debit,
+			&line.Credit,
+			&line.FunctionalDebit,
+			&line.FunctionalCredit,
+		); err != nil {
+			log.Error("failed to scan trial balance line", "error", err)
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+	if err = rows.Err(); err != nil {
+		log.Error("failed to iterate trial balance lines", "error", err)
+		return nil, err
+	}
+
+	unbalanced, err := r.listUnbalancedJournals(ctx, asOf)
+	if err != nil {
+		log.Error("failed to check journals are balanced", "error", err)
+		return nil, err
+	}
+
+	balance := models.NewTrialBalance(asOf, functional, lines, unbalanced)
+	log.Info("trial balance computed successfully", "lines", len(lines), "balanced", balance.Balanced)
+	return balance, nil
+}
+
+// listUnbalancedJournals returns the journals posted up to asOf whose functional debits differ from their credits
+func (r *SQLRepository) listUnbalancedJournals(ctx context.Context, asOf time.Time) ([]uuid.UUID, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT j.id
+		FROM ledger_journals j
+		JOIN ledger_entries e ON e.journal_id = j.id
+		WHERE j.posted_at <= $1
+		GROUP BY j.id
+		HAVING SUM(CASE WHEN e.side = 'debit' THEN e.functional_amount ELSE -e.functional_amount END) <> 0
+		    OR COUNT(DISTINCT e.functional_currency) > 1
+		ORDER BY j.id
+	`, asOf)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"encore.app/billing/models"
+	"encore.dev/rlog"
+)
+
+// receivableActivityCTE selects the receivable activity of closed and finalized bills up to $2, of the customer $3
+// or every customer when empty. Bills are billed the balance their close journals, net of reversals, debited
+// to the account $1, VAT included. Bills closed before the ledger are billed their totals persisted at close,
+// or the sum of their line items when closed before totals were persisted.
+// Payments and credit notes are the journals crediting the account $1.
+const receivableActivityCTE = `
+	WITH receivable_bills AS (
+		SELECT id, customer_id, closed_at
+		FROM bills
+		WHERE status IN ('closed', 'finalized') AND closed_at <= $2 AND ($3 = '' OR customer_id = $3)
+	),
+	billed AS (
+		SELECT b.id AS bill_id, b.customer_id, b.closed_at, e.currency,
+		       SUM(CASE WHEN e.side = 'debit' THEN e.amount ELSE -e.amount END) AS amount
+		FROM receivable_bills b
+		JOIN ledger_journals j ON j.bill_id = b.id AND j.type IN ('bill_closed', 'bill_reversal')
+		JOIN ledger_entries e ON e.journal_id = j.id AND e.account = $1
+		GROUP BY b.id, b.customer_id, b.closed_at, e.currency
+		UNION ALL
+		SELECT b.id, b.customer_id, b.closed_at, t.currency, t.amount
+		FROM receivable_bills b
+		JOIN bill_totals t ON t.bill_id = b.id
+		WHERE NOT EXISTS (SELECT 1 FROM ledger_journals j WHERE j.bill_id = b.id AND j.type = 'bill_closed')
+		UNION ALL
+		SELECT b.id, b.customer_id, b.closed_at, li.currency, SUM(li.quantity * li.unit_price)
+		FROM receivable_bills b
+		JOIN line_items li ON li.bill_id = b.id AND li.deleted_at IS NULL
+		WHERE NOT EXISTS (SELECT 1 FROM bill_totals t WHERE t.bill_id = b.id)
+		  AND NOT EXISTS (SELECT 1 FROM ledger_journals j WHERE j.bill_id = b.id AND j.type = 'bill_closed')
+		GROUP BY b.id, b.customer_id, b.closed_at, li.currency
+	),
+	settled AS (
+		SELECT j.type, j.bill_id, b.customer_id, j.reference, j.posted_at, e.currency, SUM(e.amount) AS amount
+		FROM receivable_bills b
+		JOIN ledger_journals j ON j.bill_id = b.id
+		JOIN ledger_entries e ON e.journal_id = j.id
+		WHERE j.type IN ('payment', 'credit_note') AND j.posted_at <= $2 AND e.account = $1 AND e.side = 'credit'
+		GROUP BY j.id, j.type, j.bill_id, b.customer_id, j.reference, j.posted_at, e.currency
+	)`
+
+// ListOpenReceivables returns the balances at asOf of the bills closed by then that are not fully paid or credited,
+// per bill and currency, of the customer or every customer when empty
+func (r *SQLRepository) ListOpenReceivables(
+	ctx context.Context, account string, asOf time.Time, customerID string,
+) ([]models.OpenReceivable, error) {
+	log := rlog.With("module", "billing_repository").With("account", account)
+	log.Info("listing open receivables from database", "as_of", asOf, "customer_id", customerID)
+
+	rows, err := r.db.Query(ctx, receivableActivityCTE+`
+		SELECT billed.bill_id, billed.customer_id, billed.currency, billed.closed_at,
+		       billed.amount - COALESCE(SUM(settled.amount), 0) AS outstanding
+		FROM billed
+		LEFT JOIN settled ON settled.bill_id = billed.bill_id AND settled.currency = billed.currency
+		GROUP BY billed.bill_id, billed.customer_id, billed.currency, billed.closed_at, billed.amount
+		HAVING billed.amount - COALESCE(SUM(settled.amount), 0) > 0
+		ORDER BY billed.customer_id, billed.currency, billed.closed_at, billed.bill_id
+	`, account, asOf, customerID)
+	if err != nil {
+		log.Error("failed to list open receivables from database", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	receivables := make([]models.OpenReceivable, 0)
+	for rows.Next() {
+		var receivable models.OpenReceivable
+		if err = rows.Scan(
+			&receivable.BillID,
+			&receivable.CustomerID,
+			&receivable.Currency,
+			&receivable.ClosedAt,
+			&receivable.Outstanding,
+		); err != nil {
+			log.Error("failed to scan open receivable row", "error", err)
+			return nil, err
+		}
+		receivables = append(receivables, receivable)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Error("error iterating open receivable rows", "error", err)
+		return nil, err
+	}
+
+	log.Info("open receivables listed successfully", "count", len(receivables))
+	return receivables, nil
+}
+
+// ListReceivableActivity returns the bills of the customer closed up to the time and the payments and credit notes
+// crediting the account against them posted by then, in order of occurrence
+func (r *SQLRepository) ListReceivableActivity(
+	ctx context.Context, account string, customerID string, to time.Time,
+) ([]models.ReceivableActivity, error) {
+	log := rlog.With("module", "billing_repository").With("customer_id", customerID)
+	log.Info("listing receivable activity from database", "account", account, "to", to)
+
+	rows, err := r.db.Query(ctx, receivableActivityCTE+`
+		SELECT 'bill' AS type, bill_id, '' AS reference, closed_at AS occurred_at, currency, amount
+		FROM billed
+		UNION ALL
+		SELECT type, bill_id, reference, posted_at, currency, amount
+		FROM settled
+		ORDER BY occurred_at, type, bill_id
+	`, account, to, customerID)
+	if err != nil {
+		log.Error("failed to list receivable activity from database", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	activity := make([]models.ReceivableActivity, 0)
+	for rows.Next() {
+		var a models.ReceivableActivity
+		if err = rows.Scan(
+			&a.Type,
+			&a.BillID,
+			&a.Reference,
+			&a.OccurredAt,
+			&a.Currency,
+			&a.Amount,
+		); err != nil {
+			log.Error("failed to scan receivable activity row", "error", err)
+			return nil, err
+		}
+		activity = append(activity, a)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Error("error iterating receivable activity rows", "error", err)
+		return nil, err
+	}
+
+	log.Info("receivable activity listed successfully", "count", len(activity))
+	return activity, nil
+}
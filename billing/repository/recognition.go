@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"encore.app/billing/models"
+	"encore.dev/rlog"
+	"encore.dev/storage/sqldb"
+	"encore.dev/types/uuid"
+)
+
+// insertRecognitionSchedules inserts the schedules and their entries, keeping the schedules of line items
+// already scheduled by a previous close
+func insertRecognitionSchedules(ctx context.Context, tx *sqldb.Tx, schedules []*models.RecognitionSchedule) error {
+	for _, schedule := range schedules {
+		result, err := tx.Exec(ctx, `
+			INSERT INTO revenue_schedules (line_item_id, bill_id, customer_id, currency, method, service_start, service_end,
+			                               amount, billed_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			ON CONFLICT (line_item_id) DO NOTHING
+		`,
+			schedule.LineItemID,
+			schedule.BillID,
+			schedule.CustomerID,
+			schedule.Currency,
+			schedule.Method,
+			schedule.ServiceStart,
+			schedule.ServiceEnd,
+			schedule.Amount,
+			schedule.BilledAt,
+		)
+		if err != nil {
+			return err
+		}
+		if result.RowsAffected() == 0 {
+			continue
+		}
+
+		for _, entry := range schedule.Entries {
+			_, err = tx.Exec(ctx, `
+				INSERT INTO revenue_schedule_entries (line_item_id, month, recognized_in, recognized, deferred)
+				VALUES ($1, $2::date, $3::date, $4, $5)
+			`,
+				schedule.LineItemID,
+				entry.Month.Format(time.DateOnly),
+				entry.RecognizedIn.Format(time.DateOnly),
+				entry.Recognized,
+				entry.Deferred,
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// deleteRecognitionSchedules discards the schedules of the bill with their entries
+func deleteRecognitionSchedules(ctx context.Context, tx *sqldb.Tx, billID uuid.UUID) error {
+	_, err := tx.Exec(ctx, `DELETE FROM revenue_schedules WHERE bill_id = $1`, billID)
+	return err
+}
+
+// ListBillRecognitionSchedules returns the recognition schedules of the line items of the bill with their entries,
+// ordered by service start then line item
+func (r *SQLRepository) ListBillRecognitionSchedules(ctx context.Context, billID uuid.UUID) ([]*models.RecognitionSchedule, error) {
+	log := rlog.With("module", "billing_repository").With("bill_id", billID.String())
+	log.Info("listing bill recognition schedules from database")
+
+	rows, err := r.db.Query(ctx, `
+		SELECT s.line_item_id, s.bill_id, s.customer_id, s.currency, s.method, s.service_start, s.service_end,
+		       s.amount, s.billed_at, e.month, e.recognized_in, e.recognized, e.deferred
+		FROM revenue_schedules s
+		JOIN revenue_schedule_entries e ON e.line_item_id = s.line_item_id
+		WHERE s.bill_id = $1
+		ORDER BY s.service_start, s.line_item_id, e.month
+	`, billID)
+	if err != nil {
+		log.Error("failed to list bill recognition schedules", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	schedules := []*models.RecognitionSchedule{}
+	var current *models.RecognitionSchedule
+	for rows.Next() {
+		var schedule models.RecognitionSchedule
+		var entry models.RecognitionEntry
+		if err = rows.Scan(
+			&schedule.LineItemID,
+			&schedule.BillID,
+			&schedule.CustomerID,
+			&schedule.Currency,
+			&schedule.Method,
+			&schedule.ServiceStart,
+			&schedule.ServiceEnd,
+			&schedule.Amount,
+			&schedule.BilledAt,
+			&entry.Month,
+			&entry.RecognizedIn,
+			&entry.Recognized,
+			&entry.Deferred,
+		); err != nil {
+			log.Error("failed to scan recognition schedule row", "error", err)
+			return nil, err
+		}
+		if current == nil || current.LineItemID != schedule.LineItemID {
+			schedule.Entries = []models.RecognitionEntry{}
+			current = &schedule
+			schedules = append(schedules, current)
+		}
+		current.Entries = append(current.Entries, entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Error("error iterating recognition schedule rows", "error", err)
+		return nil, err
+	}
+
+	log.Info("bill recognition schedules listed successfully", "count", len(schedules))
+	return schedules, nil
+}
+
+// ListDeferredRevenueMovements totals per month and currency the revenue deferred by the bills closed before the
+// time, in the month they closed, and recognized from it, in every month it is scheduled in, ordered by month then currency
+func (r *SQLRepository) ListDeferredRevenueMovements(ctx context.Context, billedBefore time.Time) ([]models.DeferredRevenueMovement, error) {
+	log := rlog.With("module", "billing_repository").With("billed_before", billedBefore)
+	log.Info("listing deferred revenue movements from database")
+
+	rows, err := r.db.Query(ctx, `
+		SELECT month, currency, SUM(billed), SUM(recognized)
+		FROM (
+			SELECT date_trunc('month', billed_at AT TIME ZONE 'UTC')::date AS month, currency,
+			       amount AS billed, 0 AS recognized
+			FROM revenue_schedules
+			WHERE billed_at < $1
+			UNION ALL
+			SELECT e.recognized_in, s.currency, 0, e.recognized
+			FROM revenue_schedules s
+			JOIN revenue_schedule_entries e ON e.line_item_id = s.line_item_id
+			WHERE s.billed_at < $1
+		) movements
+		GROUP BY month, currency
+		ORDER BY month, currency
+	`, billedBefore)
+	if err != nil {
+		log.Error("failed to list deferred revenue movements from database", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	movements := make([]models.DeferredRevenueMovement, 0)
+	for rows.Next() {
+		var movement models.DeferredRevenueMovement
+		if err = rows.Scan(&movement.Month, &movement.Currency, &movement.Billed, &movement.Recognized); err != nil {
+			log.Error("failed to scan deferred revenue movement row", "error", err)
+			return nil, err
+		}
+		movements = append(movements, movement)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Error("error iterating deferred revenue movement rows", "error", err)
+		return nil, err
+	}
+
+	log.Info("deferred revenue movements listed successfully", "count", len(movements))
+	return movements, nil
+}
@@ -0,0 +1,335 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"encore.app/billing/models"
+	"encore.dev/storage/sqldb"
+	"encore.dev/types/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The reporting queries run against the test database of the billing service. Each test bills its own customers
+// in its own year, so the rows of the other tests stay out of its assertions.
+
+var testDB = sqldb.Named("billing")
+
+var (
+	testRates    = &models.RatesData{Rates: map[string]float64{"USD": 1, "GEL": 2.7}}
+	testRounding = models.RoundingPolicy{Mode: models.RoundingModeHalfUp, Level: models.RoundingLevelTotal}
+	testLedger   = models.LedgerSettings{
+		FunctionalCurrency: "USD",
+		Accounts: models.LedgerAccounts{
+			AccountsReceivable: "1200",
+			Revenue:            "4000",
+			TaxPayable:         "2200",
+			Cash:               "1000",
+			FXGain:             "7100",
+			FXLoss:             "7200",
+		},
+		TaxPercent:   decimal.NewFromInt(18),
+		RoundingMode: models.RoundingModeHalfUp,
+	}
+)
+
+type testLine struct {
+	currency     models.Currency
+	amount       string
+	recurring    bool
+	serviceStart *time.Time
+	serviceEnd   *time.Time
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func dec(s string) decimal.Decimal {
+	return decimal.RequireFromString(s)
+}
+
+func newCustomerID() string {
+	return "cust-" + uuid.Must(uuid.NewV4()).String()
+}
+
+// createClosedBill stores a bill of the customer with the lines and closes it at closedAt, posting its close journal
+// and recognition schedules unless legacy, as bills closed before the ledger were
+func createClosedBill(
+	t *testing.T, repo Repository, customerID string, periodStart, periodEnd, closedAt time.Time, legacy bool,
+	lines ...testLine,
+) *models.Bill {
+	t.Helper()
+	ctx := context.Background()
+
+	bill := &models.Bill{
+		ID:          uuid.Must(uuid.NewV4()),
+		CustomerID:  customerID,
+		Status:      models.BillStatusOpen,
+		PeriodStart: periodStart,
+		PeriodEnd:   periodEnd,
+		WorkflowID:  "bill-" + customerID,
+		CreatedAt:   periodStart,
+		UpdatedAt:   periodStart,
+	}
+	require.NoError(t, repo.CreateBill(ctx, bill))
+
+	for _, line := range lines {
+		item := &models.LineItem{
+			ID:           uuid.Must(uuid.NewV4()),
+			BillID:       bill.ID,
+			Description:  "usage",
+			Currency:     line.currency,
+			Quantity:     decimal.NewFromInt(1),
+			UnitPrice:    dec(line.amount),
+			OccurredAt:   periodStart,
+			CreatedAt:    periodStart,
+			Recurring:    line.recurring,
+			ServiceStart: line.serviceStart,
+			ServiceEnd:   line.serviceEnd,
+		}
+		require.NoError(t, repo.AddLineItemToBill(ctx, item))
+		bill.LineItems = append(bill.LineItems, item)
+	}
+	require.NoError(t, bill.CalculateSum(testRates, testRounding))
+
+	var journal *models.Journal
+	var schedules []*models.RecognitionSchedule
+	if !legacy {
+		var err error
+		journal, err = models.NewBillClosedJournal(bill, closedAt, testRates, testLedger)
+		require.NoError(t, err)
+		schedules, err = models.NewRecognitionSchedules(bill, closedAt, models.RecognitionMonthly, testRounding)
+		require.NoError(t, err)
+	}
+	require.NoError(t, repo.CloseBill(ctx, bill, closedAt, journal, schedules))
+	return bill
+}
+
+func postPayment(t *testing.T, repo Repository, billID uuid.UUID, payment models.Payment) {
+	t.Helper()
+	_, err := repo.PostBillJournals(context.Background(), billID,
+		func(_ models.BillStatus, ledger []*models.Journal) ([]*models.Journal, error) {
+			return models.NewPaymentJournals(billID, ledger, payment, testRates, testLedger)
+		})
+	require.NoError(t, err)
+}
+
+func postCreditNote(t *testing.T, repo Repository, billID uuid.UUID, note models.CreditNote) {
+	t.Helper()
+	_, err := repo.PostBillJournals(context.Background(), billID,
+		func(_ models.BillStatus, ledger []*models.Journal) ([]*models.Journal, error) {
+			journal, err := models.NewCreditNoteJournal(billID, ledger, note, testLedger)
+			if err != nil {
+				return nil, err
+			}
+			return []*models.Journal{journal}, nil
+		})
+	require.NoError(t, err)
+}
+
+func outstandingByBill(receivables []models.OpenReceivable) map[uuid.UUID]map[models.Currency]decimal.Decimal {
+	byBill := make(map[uuid.UUID]map[models.Currency]decimal.Decimal)
+	for _, receivable := range receivables {
+		if byBill[receivable.BillID] == nil {
+			byBill[receivable.BillID] = make(map[models.Currency]decimal.Decimal)
+		}
+		byBill[receivable.BillID][receivable.Currency] = receivable.Outstanding
+	}
+	return byBill
+}
+
+func TestSQLRepository_Receivables(t *testing.T) {
+	ctx := context.Background()
+	repo := NewSQLRepository(testDB)
+	account := testLedger.Accounts.AccountsReceivable
+
+	customerID := newCustomerID()
+	usd := createClosedBill(t, repo, customerID, date(2030, 1, 1), date(2030, 2, 1), date(2030, 2, 2), false,
+		testLine{currency: "USD", amount: "100"})
+	gel := createClosedBill(t, repo, customerID, date(2030, 2, 1), date(2030, 3, 1), date(2030, 3, 2), false,
+		testLine{currency: "GEL", amount: "27"})
+	legacy := createClosedBill(t, repo, customerID, date(2030, 3, 1), date(2030, 4, 1), date(2030, 4, 2), true,
+		testLine{currency: "USD", amount: "40"})
+	voided := createClosedBill(t, repo, customerID, date(2030, 4, 1), date(2030, 5, 1), date(2030, 5, 2), false,
+		testLine{currency: "USD", amount: "10"})
+	require.NoError(t, repo.VoidBill(ctx, voided.ID, "duplicate", date(2030, 5, 3)))
+
+	postPayment(t, repo, usd.ID, models.Payment{
+		Reference: "pay-1", Currency: "USD", Amount: dec("50"), ReceivedAt: date(2030, 2, 10),
+	})
+	postCreditNote(t, repo, usd.ID, models.CreditNote{
+		Reference: "cn-1", Currency: "USD", Amount: dec("20"), Reason: "discount", IssuedAt: date(2030, 2, 20),
+	})
+
+	// another customer's bill stays out of the customer's receivables
+	other := createClosedBill(t, repo, newCustomerID(), date(2030, 1, 1), date(2030, 2, 1), date(2030, 2, 2), false,
+		testLine{currency: "USD", amount: "5"})
+
+	t.Run("should_bill_the_close_journal_vat_included_net_of_payments_and_credit_notes", func(t *testing.T) {
+		receivables, err := repo.ListOpenReceivables(ctx, account, date(2030, 6, 1), customerID)
+		require.NoError(t, err)
+
+		byBill := outstandingByBill(receivables)
+		require.Len(t, byBill, 3)
+		assert.True(t, dec("44.40").Equal(byBill[usd.ID]["USD"]), byBill[usd.ID]["USD"].String())
+		assert.True(t, dec("31.86").Equal(byBill[gel.ID]["GEL"]), byBill[gel.ID]["GEL"].String())
+		assert.True(t, dec("40").Equal(byBill[legacy.ID]["USD"]), byBill[legacy.ID]["USD"].String())
+		assert.NotContains(t, byBill, voided.ID)
+		assert.NotContains(t, byBill, other.ID)
+	})
+
+	t.Run("should_only_count_bills_closed_and_settlements_posted_by_the_time", func(t *testing.T) {
+		receivables, err := repo.ListOpenReceivables(ctx, account, date(2030, 2, 15), customerID)
+		require.NoError(t, err)
+
+		byBill := outstandingByBill(receivables)
+		require.Len(t, byBill, 1)
+		assert.True(t, dec("68").Equal(byBill[usd.ID]["USD"]), byBill[usd.ID]["USD"].String())
+	})
+
+	t.Run("should_drop_fully_settled_bills", func(t *testing.T) {
+		postPayment(t, repo, other.ID, models.Payment{
+			Reference: "pay-2", Currency: "USD", Amount: dec("5.90"), ReceivedAt: date(2030, 2, 3),
+		})
+
+		receivables, err := repo.ListOpenReceivables(ctx, account, date(2030, 6, 1), "")
+		require.NoError(t, err)
+		assert.NotContains(t, outstandingByBill(receivables), other.ID)
+		assert.Contains(t, outstandingByBill(receivables), usd.ID)
+	})
+
+	t.Run("should_list_the_activity_in_order_of_occurrence", func(t *testing.T) {
+		activity, err := repo.ListReceivableActivity(ctx, account, customerID, date(2030, 3, 31))
+		require.NoError(t, err)
+
+		require.Len(t, activity, 4)
+		assert.Equal(t, models.ReceivableActivityBill, activity[0].Type)
+		assert.Equal(t, usd.ID, activity[0].BillID)
+		assert.True(t, dec("118").Equal(activity[0].Amount), activity[0].Amount.String())
+		assert.Equal(t, models.ReceivableActivityPayment, activity[1].Type)
+		assert.Equal(t, "pay-1", activity[1].Reference)
+		assert.True(t, dec("50").Equal(activity[1].Amount), activity[1].Amount.String())
+		assert.Equal(t, models.ReceivableActivityCreditNote, activity[2].Type)
+		assert.Equal(t, "cn-1", activity[2].Reference)
+		assert.True(t, dec("23.60").Equal(activity[2].Amount), activity[2].Amount.String())
+		assert.Equal(t, models.ReceivableActivityBill, activity[3].Type)
+		assert.Equal(t, gel.ID, activity[3].BillID)
+		assert.Equal(t, models.Currency("GEL"), activity[3].Currency)
+	})
+}
+
+func TestSQLRepository_RevenueRollups(t *testing.T) {
+	ctx := context.Background()
+	repo := NewSQLRepository(testDB)
+
+	subscriber, oneOff := newCustomerID(), newCustomerID()
+	quarterly := createClosedBill(t, repo, subscriber, date(2031, 1, 1), date(2031, 4, 1), date(2031, 4, 2), false,
+		testLine{currency: "USD", amount: "300", recurring: true},
+		testLine{currency: "USD", amount: "50"})
+	createClosedBill(t, repo, oneOff, date(2031, 4, 1), date(2031, 5, 1), date(2031, 5, 3), false,
+		testLine{currency: "USD", amount: "80"})
+
+	recurringOf := func(t *testing.T) map[time.Time]decimal.Decimal {
+		recurring, err := repo.ListRecurringRevenue(ctx, date(2031, 1, 1), date(2032, 1, 1))
+		require.NoError(t, err)
+		byMonth := make(map[time.Time]decimal.Decimal)
+		for _, revenue := range recurring {
+			if revenue.CustomerID == subscriber {
+				byMonth[revenue.Month.UTC()] = revenue.MRR
+			}
+		}
+		return byMonth
+	}
+
+	t.Run("should_bill_in_the_close_month_and_spread_recurring_charges_over_the_period", func(t *testing.T) {
+		refresh, err := repo.RefreshRevenueRollups(ctx, date(2031, 1, 1), time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, date(2031, 1, 1), refresh.From.UTC())
+
+		revenue, err := repo.ListRevenueByPeriod(ctx, date(2031, 1, 1), date(2032, 1, 1), models.GranularityQuarter)
+		require.NoError(t, err)
+		require.Len(t, revenue, 1)
+		assert.Equal(t, date(2031, 4, 1), revenue[0].PeriodStart.UTC())
+		assert.Equal(t, models.Currency("USD"), revenue[0].Currency)
+		assert.True(t, dec("430").Equal(revenue[0].Billed), revenue[0].Billed.String())
+
+		byMonth := recurringOf(t)
+		require.Len(t, byMonth, 3)
+		for _, month := range []time.Time{date(2031, 1, 1), date(2031, 2, 1), date(2031, 3, 1)} {
+			assert.True(t, dec("100").Equal(byMonth[month]), month.String())
+		}
+	})
+
+	t.Run("should_rebuild_the_months_of_bills_voided_since_the_last_refresh", func(t *testing.T) {
+		require.NoError(t, repo.VoidBill(ctx, quarterly.ID, "cancelled", date(2031, 6, 1)))
+
+		refresh, err := repo.RefreshRevenueRollups(ctx, date(2031, 6, 1), time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, date(2031, 1, 1), refresh.From.UTC())
+
+		revenue, err := repo.ListRevenueByPeriod(ctx, date(2031, 1, 1), date(2032, 1, 1), models.GranularityQuarter)
+		require.NoError(t, err)
+		require.Len(t, revenue, 1)
+		assert.True(t, dec("80").Equal(revenue[0].Billed), revenue[0].Billed.String())
+		assert.Empty(t, recurringOf(t))
+
+		last, err := repo.GetRevenueRollupsRefresh(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, refresh.Rows, last.Rows)
+	})
+}
+
+func TestSQLRepository_Recognition(t *testing.T) {
+	ctx := context.Background()
+	repo := NewSQLRepository(testDB)
+
+	serviceStart, serviceEnd := date(2032, 1, 1), date(2033, 1, 1)
+	bill := createClosedBill(t, repo, newCustomerID(), date(2032, 1, 1), date(2032, 2, 1), date(2032, 1, 15), false,
+		testLine{currency: "USD", amount: "1200", serviceStart: &serviceStart, serviceEnd: &serviceEnd})
+
+	t.Run("should_list_the_schedule_of_the_bill_line_items", func(t *testing.T) {
+		schedules, err := repo.ListBillRecognitionSchedules(ctx, bill.ID)
+		require.NoError(t, err)
+
+		require.Len(t, schedules, 1)
+		schedule := schedules[0]
+		assert.Equal(t, bill.LineItems[0].ID, schedule.LineItemID)
+		assert.True(t, dec("1200").Equal(schedule.Amount), schedule.Amount.String())
+		require.Len(t, schedule.Entries, 12)
+		assert.Equal(t, date(2032, 1, 1), schedule.Entries[0].Month.UTC())
+		assert.True(t, dec("100").Equal(schedule.Entries[0].Recognized), schedule.Entries[0].Recognized.String())
+		assert.True(t, dec("1100").Equal(schedule.Entries[0].Deferred), schedule.Entries[0].Deferred.String())
+		assert.Equal(t, date(2032, 12, 1), schedule.Entries[11].Month.UTC())
+		assert.True(t, schedule.Entries[11].Deferred.IsZero(), schedule.Entries[11].Deferred.String())
+	})
+
+	t.Run("should_defer_in_the_close_month_and_recognize_every_scheduled_month", func(t *testing.T) {
+		movements, err := repo.ListDeferredRevenueMovements(ctx, date(2033, 1, 1))
+		require.NoError(t, err)
+
+		byMonth := make(map[time.Time]models.DeferredRevenueMovement)
+		for _, movement := range movements {
+			if !movement.Month.Before(date(2032, 1, 1)) {
+				byMonth[movement.Month.UTC()] = movement
+			}
+		}
+		require.Len(t, byMonth, 12)
+		assert.True(t, dec("1200").Equal(byMonth[date(2032, 1, 1)].Billed), byMonth[date(2032, 1, 1)].Billed.String())
+		for month := date(2032, 1, 1); month.Before(date(2033, 1, 1)); month = month.AddDate(0, 1, 0) {
+			assert.True(t, dec("100").Equal(byMonth[month].Recognized), month.String())
+		}
+		assert.True(t, byMonth[date(2032, 2, 1)].Billed.IsZero(), byMonth[date(2032, 2, 1)].Billed.String())
+	})
+
+	t.Run("should_drop_the_schedules_of_voided_bills", func(t *testing.T) {
+		require.NoError(t, repo.VoidBill(ctx, bill.ID, "cancelled", date(2032, 2, 1)))
+
+		schedules, err := repo.ListBillRecognitionSchedules(ctx, bill.ID)
+		require.NoError(t, err)
+		assert.Empty(t, schedules)
+	})
+}
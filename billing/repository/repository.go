@@ -3,24 +3,179 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"slices"
 	"time"
 
 	"encore.app/billing/models"
 	"encore.dev/rlog"
 	"encore.dev/storage/sqldb"
 	"encore.dev/types/uuid"
+	"github.com/shopspring/decimal"
 )
 
 // Repository defines the interface for data persistence
 type Repository interface {
 	// Bill operations
+	//
+	// Writes are idempotent so that retried activities succeed: repeating a write with the same arguments
+	// leaves the bill as the first write did, sql.ErrNoRows reports a bill in another state.
+
+	// CreateBill inserts the bill, doing nothing when a bill with the same ID exists
 	CreateBill(ctx context.Context, bill *models.Bill) error
-	GetBillByID(ctx context.Context, billID uuid.UUID) (*models.Bill, error)
-	CloseBill(ctx context.Context, billID uuid.UUID, closedAt time.Time) error
+	GetBillByID(ctx context.Context, billID uuid.UUID, opts models.GetBillOptions) (*models.Bill, error)
+	// ListDraftBills returns the oldest draft bills created before the given time
+	ListDraftBills(ctx context.Context, createdBefore time.Time, limit int) ([]*models.Bill, error)
+	// ActivateBill opens a draft bill once its workflow started
+	ActivateBill(ctx context.Context, billID uuid.UUID) error
+	// ListOpenBills returns open and closing bills ordered by ID, starting after the given ID
+	ListOpenBills(ctx context.Context, after uuid.UUID, limit int) ([]*models.Bill, error)
+	// ListClosedBills returns closed and finalized bills closed from closedFrom until closedTo, ordered by ID,
+	// starting after the given ID
+	ListClosedBills(ctx context.Context, closedFrom, closedTo time.Time, after uuid.UUID, limit int) ([]*models.Bill, error)
+	// MarkBillClosing moves an open bill to closing once its close time is reached, succeeding when already closing since closingAt
+	MarkBillClosing(ctx context.Context, billID uuid.UUID, closingAt time.Time) error
+	// CloseBill closes the open or closing bill and persists its computed totals, posts their journal, when not nil,
+	// and stores the recognition schedules of its line items in the same transaction, succeeding when already closed at closedAt
+	CloseBill(
+		ctx context.Context, bill *models.Bill, closedAt time.Time, journal *models.Journal,
+		schedules []*models.RecognitionSchedule,
+	) error
+	// FinalizeBill finalizes a closed bill
+	FinalizeBill(ctx context.Context, billID uuid.UUID, finalizedAt time.Time) error
+	// VoidBill voids a bill that is not finalized, reverses the journal posted at close and discards its recognition
+	// schedules, succeeding when already voided at voidedAt
+	VoidBill(ctx context.Context, billID uuid.UUID, reason string, voidedAt time.Time) error
+	// ReopenBill reopens a closed bill, discards the totals and recognition schedules persisted at close and reverses
+	// their journal
+	ReopenBill(ctx context.Context, billID uuid.UUID) error
 
 	// Line item operations
+	//
+	// AddLineItemToBill inserts the line item, doing nothing when a line item with the same ID exists
 	AddLineItemToBill(ctx context.Context, lineItem *models.LineItem) error
+	// UpdateLineItem updates the description, quantity and unit price of a line item that is not deleted,
+	// unless it was updated after the update's UpdatedAt
+	UpdateLineItem(ctx context.Context, lineItem *models.LineItem) error
+	// DeleteLineItem soft-deletes a line item, keeping it with the deletion reason for the audit trail
+	DeleteLineItem(ctx context.Context, billID uuid.UUID, lineItemID uuid.UUID, reason string, deletedAt time.Time) error
 	GetLineItemsByBillID(ctx context.Context, billID uuid.UUID) ([]*models.LineItem, error)
+	// GetLineItem retrieves a line item of a bill that is not deleted, sql.ErrNoRows if there is none
+	GetLineItem(ctx context.Context, billID, lineItemID uuid.UUID) (*models.LineItem, error)
+	// ListLineItems returns a page of line items matching the filter
+	ListLineItems(ctx context.Context, billID uuid.UUID, filter models.LineItemFilter) ([]*models.LineItem, error)
+	// GetLineTotalGroups aggregates line totals per currency, and per line amount when byLineAmount is set
+	GetLineTotalGroups(ctx context.Context, billID uuid.UUID, byLineAmount bool) ([]models.LineTotalGroup, error)
+
+	// Reconciliation report operations
+	SaveReconciliationReport(ctx context.Context, report *models.ReconciliationReport) error
+	// ListReconciliationReports returns the latest reconciliation reports first
+	ListReconciliationReports(ctx context.Context, limit int) ([]*models.ReconciliationReport, error)
+	GetReconciliationReport(ctx context.Context, id uuid.UUID) (*models.ReconciliationReport, error)
+
+	// Failed operation operations
+
+	// SaveFailedOperation inserts the failed operation, or updates the attempts of the pending one with the same ID
+	SaveFailedOperation(ctx context.Context, op *models.FailedOperation) error
+	// ResolveFailedOperation resolves or acknowledges a pending failed operation,
+	// succeeding when already resolved with the same status at resolvedAt
+	ResolveFailedOperation(
+		ctx context.Context, id uuid.UUID, status models.FailedOperationStatus, note string, resolvedAt time.Time,
+	) error
+	// ListFailedOperations returns the latest failed operations matching the filter first
+	ListFailedOperations(ctx context.Context, filter models.FailedOperationFilter) ([]*models.FailedOperation, error)
+	GetFailedOperation(ctx context.Context, id uuid.UUID) (*models.FailedOperation, error)
+
+	// Audit operations
+	//
+	// Bill and line item writes append a hash-chained audit event of the bill in their transaction,
+	// with the actor and request of the audit metadata of ctx.
+
+	// ListAuditEvents returns the audit events of the bill ordered by sequence
+	ListAuditEvents(ctx context.Context, billID uuid.UUID) ([]*models.AuditEvent, error)
+
+	// Ledger operations
+	//
+	// Journals are append-only and posted once per key, corrections are posted as reversals.
+
+	// PostBillJournals locks the bill and posts the journals built from its status and posted journals in one transaction,
+	// failing with ErrJournalAlreadyPosted when one of them is already posted
+	PostBillJournals(
+		ctx context.Context,
+		billID uuid.UUID,
+		build func(status models.BillStatus, ledger []*models.Journal) ([]*models.Journal, error),
+	) ([]*models.Journal, error)
+	// ListBillJournals returns the journals posted for the bill ordered by posting time
+	ListBillJournals(ctx context.Context, billID uuid.UUID) ([]*models.Journal, error)
+	// ListJournals returns the journals posted from postedFrom until postedTo ordered by posting time
+	ListJournals(ctx context.Context, postedFrom, postedTo time.Time) ([]*models.Journal, error)
+	// GetTrialBalance totals the entries of the journals posted up to asOf per account and currency
+	GetTrialBalance(ctx context.Context, asOf time.Time, functional models.Currency) (*models.TrialBalance, error)
+
+	// Export job operations
+	//
+	// Status updates are idempotent, sql.ErrNoRows reports a job that is not found or already finished otherwise.
+
+	// CreateExportJob inserts the export job, doing nothing when a job with the same ID exists
+	CreateExportJob(ctx context.Context, job *models.ExportJob) error
+	GetExportJob(ctx context.Context, id uuid.UUID) (*models.ExportJob, error)
+	// MarkExportJobRunning starts a pending or running export job, a retried attempt restarts it at startedAt
+	MarkExportJobRunning(ctx context.Context, id uuid.UUID, startedAt time.Time) error
+	// CompleteExportJob completes a running export job with the number of rows exported
+	CompleteExportJob(ctx context.Context, id uuid.UUID, rows int, completedAt time.Time) error
+	// FailExportJob fails an export job that is not finished with the error message
+	FailExportJob(ctx context.Context, id uuid.UUID, message string, failedAt time.Time) error
+
+	// Bank transaction operations
+	//
+	// Matches are only updated while the transaction is in review, sql.ErrNoRows reports one not found or resolved.
+
+	// CreateBankTransaction inserts the credit, returning false when one with the same entry reference is imported
+	CreateBankTransaction(ctx context.Context, txn *models.BankTransaction) (bool, error)
+	// UpdateBankTransactionMatch saves the status, bill and candidates of a transaction in review
+	UpdateBankTransactionMatch(ctx context.Context, txn *models.BankTransaction) error
+	// ListBankTransactions returns the latest booked transactions matching the filter first
+	ListBankTransactions(ctx context.Context, filter models.BankTransactionFilter) ([]*models.BankTransaction, error)
+	GetBankTransaction(ctx context.Context, id uuid.UUID) (*models.BankTransaction, error)
+	// ListReceivables returns the outstanding balances of the account in the currency of closed and finalized bills
+	ListReceivables(ctx context.Context, account string, currency models.Currency) ([]models.Receivable, error)
+
+	// Receivable report operations
+	//
+	// Bills are receivable from when they close, payments and credit notes are the journals crediting the account.
+
+	// ListOpenReceivables returns the unsettled balances at asOf per bill and currency, of every customer when empty
+	ListOpenReceivables(ctx context.Context, account string, asOf time.Time, customerID string) ([]models.OpenReceivable, error)
+	// ListReceivableActivity returns the bills, payments and credit notes of the customer up to the time
+	ListReceivableActivity(ctx context.Context, account string, customerID string, to time.Time) ([]models.ReceivableActivity, error)
+
+	// Revenue analytics operations
+	//
+	// Reports read the revenue rollups, which are only as fresh as their last refresh.
+
+	// RefreshRevenueRollups rebuilds the rollups of the months from the month of from on
+	RefreshRevenueRollups(ctx context.Context, from, refreshedAt time.Time) (*models.RollupRefresh, error)
+	// GetRevenueRollupsRefresh returns the last refresh, sql.ErrNoRows when never refreshed
+	GetRevenueRollupsRefresh(ctx context.Context) (*models.RollupRefresh, error)
+	// ListRevenueByPeriod totals the billed revenue from the month of from until the month of to per period and currency
+	ListRevenueByPeriod(ctx context.Context, from, to time.Time, granularity models.Granularity) ([]models.PeriodRevenue, error)
+	// ListRecurringRevenue returns the recurring revenue from the month of from until the month of to per customer and currency
+	ListRecurringRevenue(ctx context.Context, from, to time.Time) ([]models.RecurringRevenue, error)
+
+	// Revenue recognition operations
+	//
+	// Schedules are stored when a bill closes, see CloseBill.
+
+	// ListBillRecognitionSchedules returns the recognition schedules of the line items of the bill
+	ListBillRecognitionSchedules(ctx context.Context, billID uuid.UUID) ([]*models.RecognitionSchedule, error)
+	// ListDeferredRevenueMovements totals per month and currency the revenue deferred by the bills closed before
+	// billedBefore and recognized from it, including the months after
+	ListDeferredRevenueMovements(ctx context.Context, billedBefore time.Time) ([]models.DeferredRevenueMovement, error)
+
+	// Customer profile operations
+	GetCustomerProfile(ctx context.Context, customerID string) (*models.CustomerProfile, error)
+	UpsertCustomerProfile(ctx context.Context, profile *models.CustomerProfile) error
 }
 
 // SQLRepository implements Repository using SQL database
@@ -39,42 +194,83 @@ func (r *SQLRepository) CreateBill(ctx context.Context, bill *models.Bill) error
 	log := rlog.With("module", "billing_repository").With("bill_id", bill.ID.String()).With("customer_id", bill.CustomerID)
 	log.Info("creating bill in database", "status", bill.Status, "workflow_id", bill.WorkflowID)
 
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		log.Error("failed to begin transaction", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
 	query := `
-		INSERT INTO bills (id, customer_id, status, period_start, period_end, workflow_id, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		INSERT INTO bills (id, customer_id, status, period_start, period_end, presentment_currency, workflow_id, created_at, updated_at,
+		                   close_policy, scheduled_close_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8, $9, NULLIF($10, ''), $11)
+		ON CONFLICT (id) DO NOTHING
+		RETURNING to_jsonb(bills)
 	`
-	_, err := r.db.Exec(ctx, query,
+	// Bill IDs are generated once per bill, a conflict is the same bill created by a retried attempt
+	var after []byte
+	err = tx.QueryRow(ctx, query,
 		bill.ID,
 		bill.CustomerID,
 		bill.Status,
 		bill.PeriodStart,
 		bill.PeriodEnd,
+		string(bill.PresentmentCurrency),
 		bill.WorkflowID,
 		bill.CreatedAt,
 		bill.UpdatedAt,
-	)
+		string(bill.ClosePolicy),
+		bill.ScheduledCloseAt,
+	).Scan(&after)
 
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Warn("bill already exists in database, skipping creation")
+		return nil
+	}
 	if err != nil {
 		log.Error("failed to create bill in database", "error", err)
 		return err
 	}
 
+	if err = appendAuditEvent(ctx, tx, auditChange{
+		billID:     bill.ID,
+		entityType: models.AuditEntityBill,
+		entityID:   bill.ID,
+		action:     models.AuditActionBillCreated,
+		after:      after,
+	}); err != nil {
+		log.Error("failed to append audit event", "error", err)
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Error("failed to commit bill creation", "error", err)
+		return err
+	}
+
 	log.Info("bill created successfully in database")
 	return nil
 }
 
-func (r *SQLRepository) GetBillByID(ctx context.Context, billID uuid.UUID) (*models.Bill, error) {
+func (r *SQLRepository) GetBillByID(ctx context.Context, billID uuid.UUID, opts models.GetBillOptions) (*models.Bill, error) {
 	log := rlog.With("module", "billing_repository").With("bill_id", billID.String())
-	log.Info("retrieving bill from database")
+	log.Info("retrieving bill from database", "include_line_items", opts.IncludeLineItems)
 
 	query := `
-		SELECT id, customer_id, status, period_start, period_end, workflow_id, created_at, updated_at, closed_at
+		SELECT id, customer_id, status, period_start, period_end, COALESCE(presentment_currency, ''), workflow_id, created_at, updated_at, closed_at,
+		       finalized_at, voided_at, COALESCE(void_reason, ''), COALESCE(close_policy, ''), scheduled_close_at,
+		       closing_at, grand_total, COALESCE(grand_total_currency, ''), totals_rates_updated_at, totals_computed_at,
+		       (SELECT COUNT(*) FROM line_items WHERE line_items.bill_id = bills.id AND line_items.deleted_at IS NULL)
 		FROM bills 
 		WHERE id = $1
 	`
 
 	var bill models.Bill
-	var closedAt sql.NullTime
+	var closedAt, finalizedAt, voidedAt, scheduledCloseAt, closingAt sql.NullTime
+	var grandTotal decimal.NullDecimal
+	var grandTotalCurrency models.Currency
+	var ratesUpdatedAt, totalsComputedAt sql.NullTime
 
 	err := r.db.QueryRow(ctx, query, billID).Scan(
 		&bill.ID,
@@ -82,10 +278,22 @@ func (r *SQLRepository) GetBillByID(ctx context.Context, billID uuid.UUID) (*mod
 		&bill.Status,
 		&bill.PeriodStart,
 		&bill.PeriodEnd,
+		&bill.PresentmentCurrency,
 		&bill.WorkflowID,
 		&bill.CreatedAt,
 		&bill.UpdatedAt,
 		&closedAt,
+		&finalizedAt,
+		&voidedAt,
+		&bill.VoidReason,
+		&bill.ClosePolicy,
+		&scheduledCloseAt,
+		&closingAt,
+		&grandTotal,
+		&grandTotalCurrency,
+		&ratesUpdatedAt,
+		&totalsComputedAt,
+		&bill.LineItemCount,
 	)
 
 	if err != nil {
@@ -97,60 +305,499 @@ func (r *SQLRepository) GetBillByID(ctx context.Context, billID uuid.UUID) (*mod
 		bill.ClosedAt = &closedAt.Time
 		log.Debug("bill has closed timestamp", "closed_at", closedAt.Time)
 	}
+	if finalizedAt.Valid {
+		bill.FinalizedAt = &finalizedAt.Time
+	}
+	if voidedAt.Valid {
+		bill.VoidedAt = &voidedAt.Time
+	}
+	if scheduledCloseAt.Valid {
+		bill.ScheduledCloseAt = &scheduledCloseAt.Time
+	}
+	if closingAt.Valid {
+		bill.ClosingAt = &closingAt.Time
+	}
 
-	// Load line items
-	log.Info("loading line items for bill")
-	lineItems, err := r.GetLineItemsByBillID(ctx, billID)
-	if err != nil {
-		log.Error("failed to load line items for bill", "error", err)
-		return nil, err
+	// Load totals persisted when the bill was closed
+	if totalsComputedAt.Valid {
+		log.Debug("bill has persisted totals", "totals_computed_at", totalsComputedAt.Time)
+		byCurrency, err := r.getBillTotals(ctx, billID)
+		if err != nil {
+			log.Error("failed to load persisted totals for bill", "error", err)
+			return nil, err
+		}
+		bill.Total = &models.Total{
+			ByCurrency: byCurrency,
+			ComputedAt: &totalsComputedAt.Time,
+		}
+		if grandTotal.Valid {
+			bill.Total.GrandTotal = &models.Converted{
+				Currency:      grandTotalCurrency,
+				Amount:        grandTotal.Decimal,
+				RateUpdatedAt: ratesUpdatedAt.Time,
+			}
+		}
+	}
+
+	if opts.IncludeLineItems {
+		log.Info("loading line items for bill")
+		lineItems, err := r.GetLineItemsByBillID(ctx, billID)
+		if err != nil {
+			log.Error("failed to load line items for bill", "error", err)
+			return nil, err
+		}
+		bill.LineItems = lineItems
 	}
-	bill.LineItems = lineItems
 
 	log.Info("bill retrieved successfully from database",
 		"status", bill.Status,
-		"line_items_count", len(lineItems),
+		"line_items_count", bill.LineItemCount,
 		"customer_id", bill.CustomerID)
 
 	return &bill, nil
 }
 
-func (r *SQLRepository) CloseBill(ctx context.Context, billID uuid.UUID, closedAt time.Time) error {
-	log := rlog.With("module", "billing_repository").With("bill_id", billID.String()).With("closed_at", closedAt)
-	log.Info("closing bill in database")
+func (r *SQLRepository) ListDraftBills(ctx context.Context, createdBefore time.Time, limit int) ([]*models.Bill, error) {
+	log := rlog.With("module", "billing_repository").With("created_before", createdBefore)
+	log.Info("listing draft bills from database", "limit", limit)
+
+	rows, err := r.db.Query(ctx, `
+		SELECT `+billSummaryColumns+`
+		FROM bills
+		WHERE status = 'draft' AND created_at < $1
+		ORDER BY created_at
+		LIMIT $2
+	`, createdBefore, limit)
+	if err != nil {
+		log.Error("failed to list draft bills from database", "error", err)
+		return nil, err
+	}
+
+	bills, err := scanBillSummaries(rows)
+	if err != nil {
+		log.Error("failed to scan draft bill rows", "error", err)
+		return nil, err
+	}
+
+	log.Info("draft bills listed successfully", "count", len(bills))
+	return bills, nil
+}
+
+func (r *SQLRepository) ListOpenBills(ctx context.Context, after uuid.UUID, limit int) ([]*models.Bill, error) {
+	log := rlog.With("module", "billing_repository").With("after", after.String())
+	log.Info("listing open bills from database", "limit", limit)
+
+	rows, err := r.db.Query(ctx, `
+		SELECT `+billSummaryColumns+`
+		FROM bills
+		WHERE status IN ('open', 'closing') AND id > $1
+		ORDER BY id
+		LIMIT $2
+	`, after, limit)
+	if err != nil {
+		log.Error("failed to list open bills from database", "error", err)
+		return nil, err
+	}
+
+	bills, err := scanBillSummaries(rows)
+	if err != nil {
+		log.Error("failed to scan open bill rows", "error", err)
+		return nil, err
+	}
+
+	log.Info("open bills listed successfully", "count", len(bills))
+	return bills, nil
+}
+
+// billSummaryColumns are the bill columns read by scanBillSummaries, without closing details and totals
+const billSummaryColumns = `id, customer_id, status, period_start, period_end, COALESCE(presentment_currency, ''), workflow_id, created_at, updated_at,
+	COALESCE(close_policy, ''), scheduled_close_at, closing_at`
+
+// scanBillSummaries reads bills selected with billSummaryColumns and closes the rows
+func scanBillSummaries(rows *sqldb.Rows) ([]*models.Bill, error) {
+	defer rows.Close()
+
+	bills := make([]*models.Bill, 0)
+	for rows.Next() {
+		var bill models.Bill
+		var scheduledCloseAt, closingAt sql.NullTime
+		err := rows.Scan(
+			&bill.ID,
+			&bill.CustomerID,
+			&bill.Status,
+			&bill.PeriodStart,
+			&bill.PeriodEnd,
+			&bill.PresentmentCurrency,
+			&bill.WorkflowID,
+			&bill.CreatedAt,
+			&bill.UpdatedAt,
+			&bill.ClosePolicy,
+			&scheduledCloseAt,
+			&closingAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if scheduledCloseAt.Valid {
+			bill.ScheduledCloseAt = &scheduledCloseAt.Time
+		}
+		if closingAt.Valid {
+			bill.ClosingAt = &closingAt.Time
+		}
+		bills = append(bills, &bill)
+	}
+	return bills, rows.Err()
+}
+
+func (r *SQLRepository) ActivateBill(ctx context.Context, billID uuid.UUID) error {
+	log := rlog.With("module", "billing_repository").With("bill_id", billID.String())
+	log.Info("activating draft bill in database")
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		log.Error("failed to begin transaction", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	err = auditedUpdate(ctx, tx, billChange(billID, models.AuditActionBillActivated), `
+		UPDATE bills
+		SET status = 'open', updated_at = NOW()
+		WHERE id = $1 AND status = 'draft'
+		RETURNING to_jsonb(bills)
+	`, billID)
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Warn("no rows affected when activating bill - bill may not be a draft or not found")
+		return sql.ErrNoRows
+	}
+	if err != nil {
+		log.Error("failed to activate bill in database", "error", err)
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Error("failed to commit bill activation", "error", err)
+		return err
+	}
+
+	log.Info("bill activated successfully in database")
+	return nil
+}
+
+func (r *SQLRepository) MarkBillClosing(ctx context.Context, billID uuid.UUID, closingAt time.Time) error {
+	log := rlog.With("module", "billing_repository").With("bill_id", billID.String()).With("closing_at", closingAt)
+	log.Info("marking bill as closing in database")
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		log.Error("failed to begin transaction", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	err = auditedUpdate(ctx, tx, billChange(billID, models.AuditActionBillClosing), `
+		UPDATE bills
+		SET status = 'closing', closing_at = $1, updated_at = NOW()
+		WHERE id = $2 AND (status = 'open' OR (status = 'closing' AND closing_at = $1))
+		RETURNING to_jsonb(bills)
+	`, closingAt, billID)
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Warn("no rows affected when marking bill as closing - bill may not be open, closing since another time or not found")
+		return sql.ErrNoRows
+	}
+	if err != nil {
+		log.Error("failed to mark bill as closing in database", "error", err)
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Error("failed to commit bill closing mark", "error", err)
+		return err
+	}
+
+	log.Info("bill marked as closing successfully in database")
+	return nil
+}
+
+func (r *SQLRepository) CloseBill(
+	ctx context.Context, bill *models.Bill, closedAt time.Time, journal *models.Journal,
+	schedules []*models.RecognitionSchedule,
+) error {
+	log := rlog.With("module", "billing_repository").With("bill_id", bill.ID.String()).With("closed_at", closedAt)
+	log.Info("closing bill in database", "line_items_count", len(bill.LineItems))
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		log.Error("failed to begin transaction", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	var grandTotal decimal.NullDecimal
+	var grandTotalCurrency string
+	var ratesUpdatedAt sql.NullTime
+	var computedAt sql.NullTime
+	if bill.Total != nil {
+		computedAt = sql.NullTime{Time: time.Now(), Valid: true}
+		if bill.Total.ComputedAt != nil {
+			computedAt.Time = *bill.Total.ComputedAt
+		}
+		if bill.Total.GrandTotal != nil {
+			grandTotal = decimal.NewNullDecimal(bill.Total.GrandTotal.Amount)
+			grandTotalCurrency = string(bill.Total.GrandTotal.Currency)
+			ratesUpdatedAt = sql.NullTime{Time: bill.Total.GrandTotal.RateUpdatedAt, Valid: true}
+		}
+	}
 
 	query := `
 		UPDATE bills 
-		SET status = 'closed', closed_at = $1, updated_at = NOW()
-		WHERE id = $2 AND status = 'open'
+		SET status = 'closed', closed_at = $1, updated_at = NOW(),
+		    grand_total = $2, grand_total_currency = NULLIF($3, ''), totals_rates_updated_at = $4, totals_computed_at = $5
+		WHERE id = $6 AND (status IN ('open', 'closing') OR (status = 'closed' AND closed_at = $1))
+		RETURNING to_jsonb(bills)
 	`
 
-	result, err := r.db.Exec(ctx, query, closedAt, billID)
+	err = auditedUpdate(ctx, tx, billChange(bill.ID, models.AuditActionBillClosed), query,
+		closedAt, grandTotal, grandTotalCurrency, ratesUpdatedAt, computedAt, bill.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Warn("no rows affected when closing bill - bill may be closed at another time, finalized, voided or not found")
+		return sql.ErrNoRows
+	}
 	if err != nil {
 		log.Error("failed to close bill in database", "error", err)
 		return err
 	}
 
-	rowsAffected := result.RowsAffected()
-	if rowsAffected == 0 {
-		log.Warn("no rows affected when closing bill - bill may already be closed or not found")
+	if bill.Total != nil {
+		for currency, amount := range bill.Total.ByCurrency {
+			_, err = tx.Exec(ctx, `
+				INSERT INTO bill_totals (bill_id, currency, amount)
+				VALUES ($1, $2, $3)
+				ON CONFLICT (bill_id, currency) DO UPDATE SET amount = EXCLUDED.amount
+			`, bill.ID, currency, amount)
+			if err != nil {
+				log.Error("failed to persist bill total", "currency", currency, "error", err)
+				return err
+			}
+		}
+
+		for _, item := range bill.LineItems {
+			var convertedCurrency string
+			var convertedRate, convertedAmount decimal.NullDecimal
+			if item.Converted != nil {
+				convertedCurrency = string(item.Converted.Currency)
+				convertedRate = decimal.NewNullDecimal(item.Converted.Rate)
+				convertedAmount = decimal.NewNullDecimal(item.Converted.Amount)
+			}
+			_, err = tx.Exec(ctx, `
+				UPDATE line_items
+				SET total = $1, converted_currency = NULLIF($2, ''), converted_rate = $3, converted_amount = $4
+				WHERE id = $5 AND bill_id = $6
+			`, item.Total, convertedCurrency, convertedRate, convertedAmount, item.ID, bill.ID)
+			if err != nil {
+				log.Error("failed to persist line item total", "line_item_id", item.ID.String(), "error", err)
+				return err
+			}
+		}
+	}
+
+	// A repeated close finds the journal posted by the first one
+	if journal != nil {
+		if _, err = insertJournal(ctx, tx, journal); err != nil {
+			log.Error("failed to post bill closed journal", "error", err)
+			return err
+		}
+	}
+	if err = insertRecognitionSchedules(ctx, tx, schedules); err != nil {
+		log.Error("failed to store recognition schedules", "error", err)
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Error("failed to commit bill closing", "error", err)
+		return err
+	}
+
+	log.Info("bill closed successfully in database", "totals_persisted", bill.Total != nil)
+	return nil
+}
+
+func (r *SQLRepository) FinalizeBill(ctx context.Context, billID uuid.UUID, finalizedAt time.Time) error {
+	log := rlog.With("module", "billing_repository").With("bill_id", billID.String()).With("finalized_at", finalizedAt)
+	log.Info("finalizing bill in database")
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		log.Error("failed to begin transaction", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	err = auditedUpdate(ctx, tx, billChange(billID, models.AuditActionBillFinalized), `
+		UPDATE bills
+		SET status = 'finalized', finalized_at = $1, updated_at = NOW()
+		WHERE id = $2 AND status = 'closed'
+		RETURNING to_jsonb(bills)
+	`, finalizedAt, billID)
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Warn("no rows affected when finalizing bill - bill may not be closed or not found")
+		return sql.ErrNoRows
+	}
+	if err != nil {
+		log.Error("failed to finalize bill in database", "error", err)
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Error("failed to commit bill finalization", "error", err)
+		return err
+	}
+
+	log.Info("bill finalized successfully in database")
+	return nil
+}
+
+func (r *SQLRepository) VoidBill(ctx context.Context, billID uuid.UUID, reason string, voidedAt time.Time) error {
+	log := rlog.With("module", "billing_repository").With("bill_id", billID.String()).With("voided_at", voidedAt)
+	log.Info("voiding bill in database", "reason", reason)
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		log.Error("failed to begin transaction", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	err = auditedUpdate(ctx, tx, billChange(billID, models.AuditActionBillVoided), `
+		UPDATE bills
+		SET status = 'voided', voided_at = $1, void_reason = $2, updated_at = NOW()
+		WHERE id = $3 AND (status IN ('draft', 'open', 'closing', 'closed') OR (status = 'voided' AND voided_at = $1))
+		RETURNING to_jsonb(bills)
+	`, voidedAt, reason, billID)
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Warn("no rows affected when voiding bill - bill may be finalized, voided at another time or not found")
+		return sql.ErrNoRows
+	}
+	if err != nil {
+		log.Error("failed to void bill in database", "error", err)
+		return err
+	}
+
+	if err = reverseBillClosedJournals(ctx, tx, billID, voidedAt); err != nil {
+		log.Error("failed to reverse bill closed journal", "error", err)
+		return err
+	}
+	if err = deleteRecognitionSchedules(ctx, tx, billID); err != nil {
+		log.Error("failed to discard recognition schedules", "error", err)
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Error("failed to commit bill voiding", "error", err)
+		return err
+	}
+
+	log.Info("bill voided successfully in database")
+	return nil
+}
+
+func (r *SQLRepository) ReopenBill(ctx context.Context, billID uuid.UUID) error {
+	log := rlog.With("module", "billing_repository").With("bill_id", billID.String())
+	log.Info("reopening bill in database")
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		log.Error("failed to begin transaction", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	err = auditedUpdate(ctx, tx, billChange(billID, models.AuditActionBillReopened), `
+		UPDATE bills
+		SET status = 'open', closed_at = NULL, closing_at = NULL, updated_at = NOW(),
+		    grand_total = NULL, grand_total_currency = NULL, totals_rates_updated_at = NULL, totals_computed_at = NULL
+		WHERE id = $1 AND status = 'closed'
+		RETURNING to_jsonb(bills)
+	`, billID)
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Warn("no rows affected when reopening bill - bill may not be closed or not found")
 		return sql.ErrNoRows
 	}
+	if err != nil {
+		log.Error("failed to reopen bill in database", "error", err)
+		return err
+	}
 
-	log.Info("bill closed successfully in database", "rows_affected", rowsAffected)
+	// Totals are computed on read again until the bill is closed
+	if _, err = tx.Exec(ctx, `DELETE FROM bill_totals WHERE bill_id = $1`, billID); err != nil {
+		log.Error("failed to discard persisted bill totals", "error", err)
+		return err
+	}
+	if _, err = tx.Exec(ctx, `
+		UPDATE line_items
+		SET total = NULL, converted_currency = NULL, converted_rate = NULL, converted_amount = NULL
+		WHERE bill_id = $1
+	`, billID); err != nil {
+		log.Error("failed to discard persisted line item totals", "error", err)
+		return err
+	}
+	if err = reverseBillClosedJournals(ctx, tx, billID, time.Now()); err != nil {
+		log.Error("failed to reverse bill closed journal", "error", err)
+		return err
+	}
+	if err = deleteRecognitionSchedules(ctx, tx, billID); err != nil {
+		log.Error("failed to discard recognition schedules", "error", err)
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Error("failed to commit bill reopening", "error", err)
+		return err
+	}
+
+	log.Info("bill reopened successfully in database")
 	return nil
 }
 
+// getBillTotals retrieves the native totals per currency persisted when the bill was closed
+func (r *SQLRepository) getBillTotals(ctx context.Context, billID uuid.UUID) (map[models.Currency]decimal.Decimal, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT currency, amount
+		FROM bill_totals
+		WHERE bill_id = $1
+	`, billID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	totals := make(map[models.Currency]decimal.Decimal)
+	for rows.Next() {
+		var currency models.Currency
+		var amount decimal.Decimal
+		if err := rows.Scan(&currency, &amount); err != nil {
+			return nil, err
+		}
+		totals[currency] = amount
+	}
+	return totals, rows.Err()
+}
+
+// lineItemColumns are the line item columns read by scanLineItems
+const lineItemColumns = `id, bill_id, description, currency, quantity, unit_price, occurred_at, created_at, updated_at,
+		       total, COALESCE(converted_currency, ''), converted_rate, converted_amount, recurring,
+		       service_start, service_end`
+
 // GetLineItemsByBillID retrieves all line items for a bill
 func (r *SQLRepository) GetLineItemsByBillID(ctx context.Context, billID uuid.UUID) ([]*models.LineItem, error) {
 	log := rlog.With("module", "billing_repository").With("bill_id", billID.String())
 	log.Debug("retrieving line items for bill")
 
 	query := `
-		SELECT id, bill_id, description, currency, quantity, unit_price, created_at
+		SELECT ` + lineItemColumns + `
 		FROM line_items 
-		WHERE bill_id = $1
-		ORDER BY created_at ASC
+		WHERE bill_id = $1 AND deleted_at IS NULL
+		ORDER BY created_at ASC, id ASC
 	`
 
 	rows, err := r.db.Query(ctx, query, billID)
@@ -160,9 +807,156 @@ func (r *SQLRepository) GetLineItemsByBillID(ctx context.Context, billID uuid.UU
 	}
 	defer rows.Close()
 
+	lineItems, err := scanLineItems(rows)
+	if err != nil {
+		log.Error("failed to scan line item row", "error", err)
+		return nil, err
+	}
+
+	log.Debug("line items retrieved successfully", "count", len(lineItems))
+	return lineItems, nil
+}
+
+// GetLineItem retrieves a single line item of a bill
+func (r *SQLRepository) GetLineItem(ctx context.Context, billID, lineItemID uuid.UUID) (*models.LineItem, error) {
+	log := rlog.With("module", "billing_repository").With("bill_id", billID.String()).With("line_item_id", lineItemID.String())
+	log.Debug("retrieving line item")
+
+	query := `
+		SELECT ` + lineItemColumns + `
+		FROM line_items
+		WHERE bill_id = $1 AND id = $2 AND deleted_at IS NULL
+	`
+
+	rows, err := r.db.Query(ctx, query, billID, lineItemID)
+	if err != nil {
+		log.Error("failed to query line item", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	lineItems, err := scanLineItems(rows)
+	if err != nil {
+		log.Error("failed to scan line item row", "error", err)
+		return nil, err
+	}
+	if len(lineItems) == 0 {
+		log.Debug("line item not found")
+		return nil, sql.ErrNoRows
+	}
+
+	log.Debug("line item retrieved successfully")
+	return lineItems[0], nil
+}
+
+// ListLineItems retrieves a page of line items using keyset pagination on (created_at, id)
+func (r *SQLRepository) ListLineItems(ctx context.Context, billID uuid.UUID, filter models.LineItemFilter) ([]*models.LineItem, error) {
+	log := rlog.With("module", "billing_repository").With("bill_id", billID.String())
+	log.Debug("listing line items for bill", "currency", filter.Currency, "limit", filter.Limit)
+
+	var createdAfter, createdBefore, cursorCreatedAt sql.NullTime
+	cursorID := uuid.Nil
+	if filter.CreatedAfter != nil {
+		createdAfter = sql.NullTime{Time: *filter.CreatedAfter, Valid: true}
+	}
+	if filter.CreatedBefore != nil {
+		createdBefore = sql.NullTime{Time: *filter.CreatedBefore, Valid: true}
+	}
+	if filter.After != nil {
+		cursorCreatedAt = sql.NullTime{Time: filter.After.CreatedAt, Valid: true}
+		cursorID = filter.After.ID
+	}
+
+	query := `
+		SELECT ` + lineItemColumns + `
+		FROM line_items
+		WHERE bill_id = $1 AND deleted_at IS NULL
+		  AND ($2 = '' OR currency = $2)
+		  AND ($3::timestamptz IS NULL OR created_at >= $3)
+		  AND ($4::timestamptz IS NULL OR created_at < $4)
+		  AND ($5::timestamptz IS NULL OR (created_at, id) > ($5, $6::uuid))
+		ORDER BY created_at ASC, id ASC
+		LIMIT $7
+	`
+
+	rows, err := r.db.Query(ctx, query,
+		billID,
+		string(filter.Currency),
+		createdAfter,
+		createdBefore,
+		cursorCreatedAt,
+		cursorID,
+		filter.Limit,
+	)
+	if err != nil {
+		log.Error("failed to query line items", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	lineItems, err := scanLineItems(rows)
+	if err != nil {
+		log.Error("failed to scan line item row", "error", err)
+		return nil, err
+	}
+
+	log.Debug("line items listed successfully", "count", len(lineItems))
+	return lineItems, nil
+}
+
+// GetLineTotalGroups aggregates the unrounded line amounts of a bill in the database
+func (r *SQLRepository) GetLineTotalGroups(ctx context.Context, billID uuid.UUID, byLineAmount bool) ([]models.LineTotalGroup, error) {
+	log := rlog.With("module", "billing_repository").With("bill_id", billID.String())
+	log.Debug("aggregating line totals for bill", "by_line_amount", byLineAmount)
+
+	query := `
+		SELECT currency, 0::numeric, SUM(quantity * unit_price), COUNT(*)
+		FROM line_items
+		WHERE bill_id = $1 AND deleted_at IS NULL
+		GROUP BY currency
+	`
+	if byLineAmount {
+		query = `
+			SELECT currency, quantity * unit_price AS line_amount, SUM(quantity * unit_price), COUNT(*)
+			FROM line_items
+			WHERE bill_id = $1 AND deleted_at IS NULL
+			GROUP BY currency, line_amount
+		`
+	}
+
+	rows, err := r.db.Query(ctx, query, billID)
+	if err != nil {
+		log.Error("failed to aggregate line totals", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := make([]models.LineTotalGroup, 0)
+	for rows.Next() {
+		var group models.LineTotalGroup
+		if err := rows.Scan(&group.Currency, &group.LineAmount, &group.Sum, &group.Count); err != nil {
+			log.Error("failed to scan line total row", "error", err)
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+	if err := rows.Err(); err != nil {
+		log.Error("failed to iterate line total rows", "error", err)
+		return nil, err
+	}
+
+	log.Debug("line totals aggregated successfully", "groups", len(groups))
+	return groups, nil
+}
+
+// scanLineItems reads line items selected with lineItemColumns
+func scanLineItems(rows *sqldb.Rows) ([]*models.LineItem, error) {
 	lineItems := make([]*models.LineItem, 0)
 	for rows.Next() {
 		lineItem := &models.LineItem{}
+		var total, convertedRate, convertedAmount decimal.NullDecimal
+		var convertedCurrency models.Currency
+		var updatedAt, serviceStart, serviceEnd sql.NullTime
 
 		err := rows.Scan(
 			&lineItem.ID,
@@ -171,20 +965,47 @@ func (r *SQLRepository) GetLineItemsByBillID(ctx context.Context, billID uuid.UU
 			&lineItem.Currency,
 			&lineItem.Quantity,
 			&lineItem.UnitPrice,
+			&lineItem.OccurredAt,
 			&lineItem.CreatedAt,
+			&updatedAt,
+			&total,
+			&convertedCurrency,
+			&convertedRate,
+			&convertedAmount,
+			&lineItem.Recurring,
+			&serviceStart,
+			&serviceEnd,
 		)
 		if err != nil {
-			log.Error("failed to scan line item row", "error", err)
 			return nil, err
 		}
 
+		if updatedAt.Valid {
+			lineItem.UpdatedAt = &updatedAt.Time
+		}
+		if serviceStart.Valid && serviceEnd.Valid {
+			lineItem.ServiceStart = &serviceStart.Time
+			lineItem.ServiceEnd = &serviceEnd.Time
+		}
+		// Totals persisted when the bill was closed
+		if total.Valid {
+			lineItem.Total = total.Decimal
+		}
+		if convertedAmount.Valid {
+			lineItem.Converted = &models.LineConversion{
+				Currency: convertedCurrency,
+				Rate:     convertedRate.Decimal,
+				Amount:   convertedAmount.Decimal,
+			}
+		}
+
 		lineItems = append(lineItems, lineItem)
 	}
-
-	log.Debug("line items retrieved successfully", "count", len(lineItems))
-	return lineItems, nil
+	return lineItems, rows.Err()
 }
 
+// AddLineItemToBill inserts a line item of an open or closing bill, returning models.ErrBillClosed when the bill
+// moved to another status, e.g. closed while a retried addition was pending. A line item already inserted is skipped.
 func (r *SQLRepository) AddLineItemToBill(ctx context.Context, lineItem *models.LineItem) error {
 	log := rlog.With("module", "billing_repository").With("bill_id", lineItem.BillID.String()).With("line_item_id", lineItem.ID.String())
 	log.Info("adding line item to bill in database",
@@ -193,25 +1014,526 @@ func (r *SQLRepository) AddLineItemToBill(ctx context.Context, lineItem *models.
 		"quantity", lineItem.Quantity,
 		"unit_price", lineItem.UnitPrice)
 
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		log.Error("failed to begin transaction", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	err = lockBillInStatus(ctx, tx, lineItem.BillID, models.BillStatusOpen, models.BillStatusClosing)
+	if errors.Is(err, models.ErrBillClosed) {
+		// The line item inserted by an attempt whose completion was lost is part of the closed bill
+		var exists bool
+		if existsErr := tx.QueryRow(ctx, `
+			SELECT EXISTS (SELECT 1 FROM line_items WHERE id = $1 AND bill_id = $2)
+		`, lineItem.ID, lineItem.BillID).Scan(&exists); existsErr == nil && exists {
+			log.Warn("line item already exists in database, skipping insertion")
+			return nil
+		}
+	}
+	if err != nil {
+		log.Warn("failed to lock open or closing bill of line item", "error", err)
+		return err
+	}
+
 	lineItemQuery := `
-		INSERT INTO line_items (id, bill_id, description, currency, quantity, unit_price, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO line_items (id, bill_id, description, currency, quantity, unit_price, occurred_at, created_at,
+		                        recurring, service_start, service_end)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (id) DO NOTHING
+		RETURNING to_jsonb(line_items)
 	`
-	_, err := r.db.Exec(ctx, lineItemQuery,
+	// Line items signaled before occurred_at was recorded occurred when they were created
+	occurredAt := lineItem.OccurredAt
+	if occurredAt.IsZero() {
+		occurredAt = lineItem.CreatedAt
+	}
+	var after []byte
+	err = tx.QueryRow(ctx, lineItemQuery,
 		lineItem.ID,
 		lineItem.BillID,
 		lineItem.Description,
 		lineItem.Currency,
 		lineItem.Quantity,
 		lineItem.UnitPrice,
+		occurredAt,
 		lineItem.CreatedAt,
-	)
+		lineItem.Recurring,
+		lineItem.ServiceStart,
+		lineItem.ServiceEnd,
+	).Scan(&after)
 
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Warn("line item already exists in database, skipping insertion")
+		return nil
+	}
 	if err != nil {
 		log.Error("failed to add line item to bill in database", "error", err)
 		return err
 	}
 
+	if err = appendAuditEvent(ctx, tx, auditChange{
+		billID:     lineItem.BillID,
+		entityType: models.AuditEntityLineItem,
+		entityID:   lineItem.ID,
+		action:     models.AuditActionLineItemAdded,
+		after:      after,
+	}); err != nil {
+		log.Error("failed to append audit event", "error", err)
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Error("failed to commit line item addition", "error", err)
+		return err
+	}
+
 	log.Info("line item added successfully to bill in database")
 	return nil
 }
+
+// lockBillInStatus locks the bill of a line item change until the transaction ends, so that the bill cannot close meanwhile,
+// returning models.ErrBillClosed when the bill is in none of the statuses and sql.ErrNoRows when not found
+func lockBillInStatus(ctx context.Context, tx *sqldb.Tx, billID uuid.UUID, statuses ...models.BillStatus) error {
+	var status models.BillStatus
+	err := tx.QueryRow(ctx, `SELECT status FROM bills WHERE id = $1 FOR UPDATE`, billID).Scan(&status)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(statuses, status) {
+		return models.ErrBillClosed
+	}
+	return nil
+}
+
+// UpdateLineItem updates a line item of an open bill, returning models.ErrBillClosed when the bill is no longer open
+// and sql.ErrNoRows when the line item is deleted, updated later or not found
+func (r *SQLRepository) UpdateLineItem(ctx context.Context, lineItem *models.LineItem) error {
+	log := rlog.With("module", "billing_repository").With("bill_id", lineItem.BillID.String()).With("line_item_id", lineItem.ID.String())
+	log.Info("updating line item in database",
+		"description", lineItem.Description,
+		"quantity", lineItem.Quantity,
+		"unit_price", lineItem.UnitPrice)
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		log.Error("failed to begin transaction", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = lockBillInStatus(ctx, tx, lineItem.BillID, models.BillStatusOpen); err != nil {
+		log.Warn("failed to lock open bill of line item", "error", err)
+		return err
+	}
+
+	query := `
+		UPDATE line_items
+		SET description = $1, quantity = $2, unit_price = $3, updated_at = $4
+		WHERE id = $5 AND bill_id = $6 AND deleted_at IS NULL AND (updated_at IS NULL OR updated_at <= $4)
+		RETURNING to_jsonb(line_items)
+	`
+	updatedAt := time.Now()
+	if lineItem.UpdatedAt != nil {
+		updatedAt = *lineItem.UpdatedAt
+	}
+	err = auditedUpdate(ctx, tx, lineItemChange(lineItem.BillID, lineItem.ID, models.AuditActionLineItemUpdated), query,
+		lineItem.Description,
+		lineItem.Quantity,
+		lineItem.UnitPrice,
+		updatedAt,
+		lineItem.ID,
+		lineItem.BillID,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Warn("no rows affected when updating line item - line item may be deleted, updated later or not found")
+		return sql.ErrNoRows
+	}
+	if err != nil {
+		log.Error("failed to update line item in database", "error", err)
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Error("failed to commit line item update", "error", err)
+		return err
+	}
+
+	log.Info("line item updated successfully in database")
+	return nil
+}
+
+// DeleteLineItem soft-deletes a line item of an open bill, returning models.ErrBillClosed when the bill is no longer open
+// and sql.ErrNoRows when the line item is not found or was deleted by another removal
+func (r *SQLRepository) DeleteLineItem(
+	ctx context.Context, billID uuid.UUID, lineItemID uuid.UUID, reason string, deletedAt time.Time,
+) error {
+	log := rlog.With("module", "billing_repository").With("bill_id", billID.String()).With("line_item_id", lineItemID.String())
+	log.Info("soft-deleting line item in database", "reason", reason)
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		log.Error("failed to begin transaction", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = lockBillInStatus(ctx, tx, billID, models.BillStatusOpen); err != nil {
+		log.Warn("failed to lock open bill of line item", "error", err)
+		return err
+	}
+
+	query := `
+		UPDATE line_items
+		SET deleted_at = $1, deletion_reason = $2
+		WHERE id = $3 AND bill_id = $4 AND deleted_at IS NULL
+		RETURNING to_jsonb(line_items)
+	`
+	err = auditedUpdate(ctx, tx, lineItemChange(billID, lineItemID, models.AuditActionLineItemRemoved), query,
+		deletedAt, reason, lineItemID, billID)
+	if errors.Is(err, sql.ErrNoRows) {
+		// A retried removal finds the line item deleted by its previous attempt
+		var deleted bool
+		err = tx.QueryRow(ctx, `
+			SELECT EXISTS (SELECT 1 FROM line_items WHERE id = $1 AND bill_id = $2 AND deleted_at = $3)
+		`, lineItemID, billID, deletedAt).Scan(&deleted)
+		if err == nil && deleted {
+			log.Info("line item already soft-deleted by this removal")
+			return nil
+		}
+		log.Warn("no rows affected when deleting line item - line item may be deleted by another removal or not found")
+		return sql.ErrNoRows
+	}
+	if err != nil {
+		log.Error("failed to delete line item in database", "error", err)
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Error("failed to commit line item deletion", "error", err)
+		return err
+	}
+
+	log.Info("line item soft-deleted successfully in database")
+	return nil
+}
+
+// SaveReconciliationReport stores the report of a reconciliation run, once per report ID
+func (r *SQLRepository) SaveReconciliationReport(ctx context.Context, report *models.ReconciliationReport) error {
+	log := rlog.With("module", "billing_repository").With("report_id", report.ID.String())
+	log.Info("saving reconciliation report in database",
+		"bills_checked", report.BillsChecked,
+		"discrepancies", len(report.Discrepancies))
+
+	discrepancies, err := json.Marshal(report.Discrepancies)
+	if err != nil {
+		log.Error("failed to encode discrepancies", "error", err)
+		return err
+	}
+
+	_, err = r.db.Exec(ctx, `
+		INSERT INTO reconciliation_reports (id, workflow_id, repair, started_at, completed_at, bills_checked, discrepancies)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (id) DO NOTHING
+	`, report.ID, report.WorkflowID, report.Repair, report.StartedAt, report.CompletedAt, report.BillsChecked, discrepancies)
+	if err != nil {
+		log.Error("failed to save reconciliation report in database", "error", err)
+		return err
+	}
+
+	log.Info("reconciliation report saved successfully")
+	return nil
+}
+
+// ListReconciliationReports lists the latest reconciliation reports, newest first
+func (r *SQLRepository) ListReconciliationReports(ctx context.Context, limit int) ([]*models.ReconciliationReport, error) {
+	log := rlog.With("module", "billing_repository")
+	log.Info("listing reconciliation reports from database", "limit", limit)
+
+	rows, err := r.db.Query(ctx, `
+		SELECT `+reconciliationReportColumns+`
+		FROM reconciliation_reports
+		ORDER BY started_at DESC
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		log.Error("failed to list reconciliation reports from database", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := make([]*models.ReconciliationReport, 0)
+	for rows.Next() {
+		report, err := scanReconciliationReport(rows)
+		if err != nil {
+			log.Error("failed to scan reconciliation report row", "error", err)
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Error("error iterating reconciliation report rows", "error", err)
+		return nil, err
+	}
+
+	log.Info("reconciliation reports listed successfully", "count", len(reports))
+	return reports, nil
+}
+
+func (r *SQLRepository) GetReconciliationReport(ctx context.Context, id uuid.UUID) (*models.ReconciliationReport, error) {
+	log := rlog.With("module", "billing_repository").With("report_id", id.String())
+	log.Info("retrieving reconciliation report from database")
+
+	report, err := scanReconciliationReport(r.db.QueryRow(ctx, `
+		SELECT `+reconciliationReportColumns+`
+		FROM reconciliation_reports
+		WHERE id = $1
+	`, id))
+	if err != nil {
+		log.Error("failed to retrieve reconciliation report from database", "error", err)
+		return nil, err
+	}
+
+	return report, nil
+}
+
+const reconciliationReportColumns = `id, workflow_id, repair, started_at, completed_at, bills_checked, discrepancies`
+
+// scanReconciliationReport reads a report selected with reconciliationReportColumns from a row
+func scanReconciliationReport(row interface{ Scan(dest ...any) error }) (*models.ReconciliationReport, error) {
+	var report models.ReconciliationReport
+	var discrepancies []byte
+	err := row.Scan(
+		&report.ID,
+		&report.WorkflowID,
+		&report.Repair,
+		&report.StartedAt,
+		&report.CompletedAt,
+		&report.BillsChecked,
+		&discrepancies,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(discrepancies, &report.Discrepancies); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+func (r *SQLRepository) SaveFailedOperation(ctx context.Context, op *models.FailedOperation) error {
+	log := rlog.With("module", "billing_repository").With("failed_operation_id", op.ID.String()).With("bill_id", op.BillID.String())
+	log.Info("saving failed operation in database", "operation", op.Operation, "attempts", len(op.Attempts))
+
+	attempts, err := json.Marshal(op.Attempts)
+	if err != nil {
+		log.Error("failed to encode attempts", "error", err)
+		return err
+	}
+
+	_, err = r.db.Exec(ctx, `
+		INSERT INTO failed_operations (id, bill_id, workflow_id, operation, payload, status, attempts, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, 'pending', $6, $7, $8)
+		ON CONFLICT (id) DO UPDATE
+		SET attempts = EXCLUDED.attempts, updated_at = EXCLUDED.updated_at
+		WHERE failed_operations.status = 'pending'
+	`, op.ID, op.BillID, op.WorkflowID, op.Operation, []byte(op.Payload), attempts, op.CreatedAt, op.UpdatedAt)
+	if err != nil {
+		log.Error("failed to save failed operation in database", "error", err)
+		return err
+	}
+
+	log.Info("failed operation saved successfully")
+	return nil
+}
+
+func (r *SQLRepository) ResolveFailedOperation(
+	ctx context.Context, id uuid.UUID, status models.FailedOperationStatus, note string, resolvedAt time.Time,
+) error {
+	log := rlog.With("module", "billing_repository").With("failed_operation_id", id.String())
+	log.Info("resolving failed operation in database", "status", status)
+
+	result, err := r.db.Exec(ctx, `
+		UPDATE failed_operations
+		SET status = $1, resolution_note = NULLIF($2, ''), resolved_at = $3, updated_at = $3
+		WHERE id = $4 AND (status = 'pending' OR (status = $1 AND resolved_at = $3))
+	`, status, note, resolvedAt, id)
+	if err != nil {
+		log.Error("failed to resolve failed operation in database", "error", err)
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		log.Warn("no rows affected when resolving failed operation - operation may be resolved already or not found")
+		return sql.ErrNoRows
+	}
+
+	log.Info("failed operation resolved successfully in database")
+	return nil
+}
+
+func (r *SQLRepository) ListFailedOperations(ctx context.Context, filter models.FailedOperationFilter) ([]*models.FailedOperation, error) {
+	log := rlog.With("module", "billing_repository")
+	log.Info("listing failed operations from database", "status", filter.Status, "bill_id", filter.BillID, "limit", filter.Limit)
+
+	rows, err := r.db.Query(ctx, `
+		SELECT `+failedOperationColumns+`
+		FROM failed_operations
+		WHERE ($1 = '' OR status = $1) AND ($2::uuid IS NULL OR bill_id = $2)
+		ORDER BY created_at DESC
+		LIMIT $3
+	`, string(filter.Status), filter.BillID, filter.Limit)
+	if err != nil {
+		log.Error("failed to list failed operations from database", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	ops := make([]*models.FailedOperation, 0)
+	for rows.Next() {
+		op, err := scanFailedOperation(rows)
+		if err != nil {
+			log.Error("failed to scan failed operation row", "error", err)
+			return nil, err
+		}
+		ops = append(ops, op)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Error("error iterating failed operation rows", "error", err)
+		return nil, err
+	}
+
+	log.Info("failed operations listed successfully", "count", len(ops))
+	return ops, nil
+}
+
+func (r *SQLRepository) GetFailedOperation(ctx context.Context, id uuid.UUID) (*models.FailedOperation, error) {
+	log := rlog.With("module", "billing_repository").With("failed_operation_id", id.String())
+	log.Info("retrieving failed operation from database")
+
+	op, err := scanFailedOperation(r.db.QueryRow(ctx, `
+		SELECT `+failedOperationColumns+`
+		FROM failed_operations
+		WHERE id = $1
+	`, id))
+	if err != nil {
+		log.Error("failed to retrieve failed operation from database", "error", err)
+		return nil, err
+	}
+
+	return op, nil
+}
+
+const failedOperationColumns = `id, bill_id, workflow_id, operation, payload, status, attempts, created_at, updated_at,
+	resolved_at, COALESCE(resolution_note, '')`
+
+// scanFailedOperation reads a failed operation selected with failedOperationColumns from a row
+func scanFailedOperation(row interface{ Scan(dest ...any) error }) (*models.FailedOperation, error) {
+	var op models.FailedOperation
+	var payload, attempts []byte
+	var resolvedAt sql.NullTime
+	err := row.Scan(
+		&op.ID,
+		&op.BillID,
+		&op.WorkflowID,
+		&op.Operation,
+		&payload,
+		&op.Status,
+		&attempts,
+		&op.CreatedAt,
+		&op.UpdatedAt,
+		&resolvedAt,
+		&op.ResolutionNote,
+	)
+	if err != nil {
+		return nil, err
+	}
+	op.Payload = payload
+	if err = json.Unmarshal(attempts, &op.Attempts); err != nil {
+		return nil, err
+	}
+	if resolvedAt.Valid {
+		op.ResolvedAt = &resolvedAt.Time
+	}
+	return &op, nil
+}
+
+// GetCustomerProfile retrieves the billing profile of a customer
+func (r *SQLRepository) GetCustomerProfile(ctx context.Context, customerID string) (*models.CustomerProfile, error) {
+	log := rlog.With("module", "billing_repository").With("customer_id", customerID)
+	log.Debug("retrieving customer profile from database")
+
+	query := `
+		SELECT customer_id, presentment_currency, COALESCE(timezone, ''), COALESCE(close_policy, ''), party, created_at, updated_at
+		FROM customer_profiles
+		WHERE customer_id = $1
+	`
+
+	var profile models.CustomerProfile
+	var party []byte
+	err := r.db.QueryRow(ctx, query, customerID).Scan(
+		&profile.CustomerID,
+		&profile.PresentmentCurrency,
+		&profile.Timezone,
+		&profile.ClosePolicy,
+		&party,
+		&profile.CreatedAt,
+		&profile.UpdatedAt,
+	)
+	if err != nil {
+		log.Debug("failed to retrieve customer profile from database", "error", err)
+		return nil, err
+	}
+	if party != nil {
+		if err = json.Unmarshal(party, &profile.Party); err != nil {
+			log.Error("failed to decode customer party", "error", err)
+			return nil, err
+		}
+	}
+
+	log.Debug("customer profile retrieved successfully", "presentment_currency", profile.PresentmentCurrency)
+	return &profile, nil
+}
+
+// UpsertCustomerProfile creates a customer profile or updates the existing one
+func (r *SQLRepository) UpsertCustomerProfile(ctx context.Context, profile *models.CustomerProfile) error {
+	log := rlog.With("module", "billing_repository").With("customer_id", profile.CustomerID)
+	log.Info("upserting customer profile in database", "presentment_currency", profile.PresentmentCurrency)
+
+	var party []byte
+	if profile.Party != nil {
+		var err error
+		if party, err = json.Marshal(profile.Party); err != nil {
+			log.Error("failed to encode customer party", "error", err)
+			return err
+		}
+	}
+
+	query := `
+		INSERT INTO customer_profiles (customer_id, presentment_currency, timezone, close_policy, party, created_at, updated_at)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), $5, $6, $7)
+		ON CONFLICT (customer_id) DO UPDATE
+		SET presentment_currency = EXCLUDED.presentment_currency, timezone = EXCLUDED.timezone,
+		    close_policy = EXCLUDED.close_policy, party = EXCLUDED.party, updated_at = EXCLUDED.updated_at
+		RETURNING created_at
+	`
+	err := r.db.QueryRow(ctx, query,
+		profile.CustomerID,
+		profile.PresentmentCurrency,
+		profile.Timezone,
+		string(profile.ClosePolicy),
+		party,
+		profile.CreatedAt,
+		profile.UpdatedAt,
+	).Scan(&profile.CreatedAt)
+	if err != nil {
+		log.Error("failed to upsert customer profile in database", "error", err)
+		return err
+	}
+
+	log.Info("customer profile upserted successfully in database")
+	return nil
+}
@@ -0,0 +1,1034 @@
+// Package repositorytest provides an in-memory repository for the tests of the packages using the repository.
+// The SQL of the repository itself is tested against the database in the repository package.
+package repositorytest
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+
+	"encore.app/billing/models"
+	"encore.app/billing/repository"
+	"encore.dev/types/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var _ repository.Repository = (*FakeRepo)(nil)
+
+// FakeRepo is an in-memory repo used for testing
+type FakeRepo struct {
+	bills     map[uuid.UUID]*models.Bill
+	lineItems map[uuid.UUID][]*models.LineItem
+	profiles  map[string]*models.CustomerProfile
+	reports   []*models.ReconciliationReport
+	failedOps []*models.FailedOperation
+	audit     map[uuid.UUID][]*models.AuditEvent
+	journals  []*models.Journal
+	exports   map[uuid.UUID]*models.ExportJob
+	bankTxns  []*models.BankTransaction
+	rollups   []revenueRollup
+	refresh   *models.RollupRefresh
+	schedules []*models.RecognitionSchedule
+}
+
+// revenueRollup is a row of the revenue rollups
+type revenueRollup struct {
+	month      time.Time
+	customerID string
+	currency   models.Currency
+	billed     decimal.Decimal
+	recurring  decimal.Decimal
+}
+
+// recordAuditEvent chains the event of the mutation after the last event of the bill, as SQLRepository does
+func (m *FakeRepo) recordAuditEvent(
+	ctx context.Context, billID uuid.UUID, entityType models.AuditEntityType, entityID uuid.UUID,
+	action models.AuditAction, before, after interface{},
+) error {
+	var beforeState json.RawMessage
+	if before != nil {
+		state, err := json.Marshal(before)
+		if err != nil {
+			return err
+		}
+		beforeState = state
+	}
+	afterState, err := json.Marshal(after)
+	if err != nil {
+		return err
+	}
+
+	if m.audit == nil {
+		m.audit = make(map[uuid.UUID][]*models.AuditEvent)
+	}
+	var prev *models.AuditEvent
+	if events := m.audit[billID]; len(events) > 0 {
+		prev = events[len(events)-1]
+	}
+	event, err := models.NewAuditEvent(ctx, prev, billID, entityType, entityID, action, beforeState, afterState, time.Now())
+	if err != nil {
+		return err
+	}
+	m.audit[billID] = append(m.audit[billID], event)
+	return nil
+}
+
+func (m *FakeRepo) ListAuditEvents(ctx context.Context, billID uuid.UUID) ([]*models.AuditEvent, error) {
+	return append([]*models.AuditEvent{}, m.audit[billID]...), nil
+}
+
+func (m *FakeRepo) CreateBill(ctx context.Context, bill *models.Bill) error {
+	if m.bills == nil {
+		m.bills = make(map[uuid.UUID]*models.Bill)
+	}
+	if _, exists := m.bills[bill.ID]; exists {
+		return nil
+	}
+	m.bills[bill.ID] = bill
+	return m.recordAuditEvent(ctx, bill.ID, models.AuditEntityBill, bill.ID, models.AuditActionBillCreated, nil, bill)
+}
+
+func (m *FakeRepo) GetBillByID(ctx context.Context, billID uuid.UUID, opts models.GetBillOptions) (*models.Bill, error) {
+	if bill, exists := m.bills[billID]; exists {
+		bill.LineItemCount = int64(len(m.lineItems[billID]))
+		if !opts.IncludeLineItems {
+			summary := *bill
+			summary.LineItems = nil
+			return &summary, nil
+		}
+		// Load line items
+		if lineItems, exists := m.lineItems[billID]; exists {
+			bill.LineItems = lineItems
+		}
+		return bill, nil
+	}
+	return nil, models.ErrBillNotFound
+}
+
+func (m *FakeRepo) ListDraftBills(ctx context.Context, createdBefore time.Time, limit int) ([]*models.Bill, error) {
+	drafts := make([]*models.Bill, 0)
+	for _, bill := range m.bills {
+		if bill.Status == models.BillStatusDraft && bill.CreatedAt.Before(createdBefore) {
+			drafts = append(drafts, bill)
+		}
+	}
+	slices.SortFunc(drafts, func(a, b *models.Bill) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	if len(drafts) > limit {
+		drafts = drafts[:limit]
+	}
+	return drafts, nil
+}
+
+func (m *FakeRepo) ListOpenBills(ctx context.Context, after uuid.UUID, limit int) ([]*models.Bill, error) {
+	open := make([]*models.Bill, 0)
+	for _, bill := range m.bills {
+		if bill.IsActive() && strings.Compare(bill.ID.String(), after.String()) > 0 {
+			open = append(open, bill)
+		}
+	}
+	slices.SortFunc(open, func(a, b *models.Bill) int { return strings.Compare(a.ID.String(), b.ID.String()) })
+	if len(open) > limit {
+		open = open[:limit]
+	}
+	return open, nil
+}
+
+func (m *FakeRepo) ListClosedBills(
+	ctx context.Context, closedFrom, closedTo time.Time, after uuid.UUID, limit int,
+) ([]*models.Bill, error) {
+	closed := make([]*models.Bill, 0)
+	for _, bill := range m.bills {
+		if bill.Status != models.BillStatusClosed && bill.Status != models.BillStatusFinalized {
+			continue
+		}
+		if bill.ClosedAt.Before(closedFrom) || !bill.ClosedAt.Before(closedTo) {
+			continue
+		}
+		if strings.Compare(bill.ID.String(), after.String()) > 0 {
+			closed = append(closed, bill)
+		}
+	}
+	slices.SortFunc(closed, func(a, b *models.Bill) int { return strings.Compare(a.ID.String(), b.ID.String()) })
+	if len(closed) > limit {
+		closed = closed[:limit]
+	}
+	return closed, nil
+}
+
+func (m *FakeRepo) SaveReconciliationReport(ctx context.Context, report *models.ReconciliationReport) error {
+	m.reports = append(m.reports, report)
+	return nil
+}
+
+func (m *FakeRepo) ListReconciliationReports(ctx context.Context, limit int) ([]*models.ReconciliationReport, error) {
+	reports := slices.Clone(m.reports)
+	slices.Reverse(reports)
+	if len(reports) > limit {
+		reports = reports[:limit]
+	}
+	return reports, nil
+}
+
+func (m *FakeRepo) GetReconciliationReport(ctx context.Context, id uuid.UUID) (*models.ReconciliationReport, error) {
+	for _, report := range m.reports {
+		if report.ID == id {
+			return report, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *FakeRepo) SaveFailedOperation(ctx context.Context, op *models.FailedOperation) error {
+	for i, existing := range m.failedOps {
+		if existing.ID == op.ID {
+			if existing.Status == models.FailedOperationStatusPending {
+				saved := *existing
+				saved.Attempts = op.Attempts
+				saved.UpdatedAt = op.UpdatedAt
+				m.failedOps[i] = &saved
+			}
+			return nil
+		}
+	}
+	saved := *op
+	saved.Status = models.FailedOperationStatusPending
+	m.failedOps = append(m.failedOps, &saved)
+	return nil
+}
+
+func (m *FakeRepo) ResolveFailedOperation(
+	ctx context.Context, id uuid.UUID, status models.FailedOperationStatus, note string, resolvedAt time.Time,
+) error {
+	for _, op := range m.failedOps {
+		if op.ID != id {
+			continue
+		}
+		if op.Status == models.FailedOperationStatusPending {
+			op.Resolve(status, note, resolvedAt)
+			return nil
+		}
+		if op.Status == status && op.ResolvedAt != nil && op.ResolvedAt.Equal(resolvedAt) {
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (m *FakeRepo) ListFailedOperations(ctx context.Context, filter models.FailedOperationFilter) ([]*models.FailedOperation, error) {
+	ops := make([]*models.FailedOperation, 0)
+	for i := len(m.failedOps) - 1; i >= 0; i-- {
+		op := m.failedOps[i]
+		if filter.Status != "" && op.Status != filter.Status {
+			continue
+		}
+		if filter.BillID != nil && op.BillID != *filter.BillID {
+			continue
+		}
+		ops = append(ops, op)
+	}
+	if len(ops) > filter.Limit {
+		ops = ops[:filter.Limit]
+	}
+	return ops, nil
+}
+
+func (m *FakeRepo) GetFailedOperation(ctx context.Context, id uuid.UUID) (*models.FailedOperation, error) {
+	for _, op := range m.failedOps {
+		if op.ID == id {
+			return op, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *FakeRepo) ActivateBill(ctx context.Context, billID uuid.UUID) error {
+	bill, exists := m.bills[billID]
+	if !exists || bill.Status != models.BillStatusDraft {
+		return sql.ErrNoRows
+	}
+	before := *bill
+	bill.Status = models.BillStatusOpen
+	return m.recordAuditEvent(ctx, billID, models.AuditEntityBill, billID, models.AuditActionBillActivated, before, bill)
+}
+
+func (m *FakeRepo) MarkBillClosing(ctx context.Context, billID uuid.UUID, closingAt time.Time) error {
+	bill, exists := m.bills[billID]
+	if exists && bill.Status == models.BillStatusClosing && bill.ClosingAt != nil && bill.ClosingAt.Equal(closingAt) {
+		return nil
+	}
+	if !exists || bill.Status != models.BillStatusOpen {
+		return sql.ErrNoRows
+	}
+	before := *bill
+	bill.Status = models.BillStatusClosing
+	bill.ClosingAt = &closingAt
+	return m.recordAuditEvent(ctx, billID, models.AuditEntityBill, billID, models.AuditActionBillClosing, before, bill)
+}
+
+func (m *FakeRepo) CloseBill(
+	ctx context.Context, closing *models.Bill, closedAt time.Time, journal *models.Journal,
+	schedules []*models.RecognitionSchedule,
+) error {
+	if bill, exists := m.bills[closing.ID]; exists {
+		alreadyClosed := bill.Status == models.BillStatusClosed && bill.ClosedAt != nil && bill.ClosedAt.Equal(closedAt)
+		if !bill.IsActive() && !alreadyClosed {
+			return sql.ErrNoRows
+		}
+		if alreadyClosed {
+			return nil
+		}
+		before := *bill
+		bill.Status = models.BillStatusClosed
+		bill.ClosedAt = &closedAt
+		bill.UpdatedAt = time.Now()
+		bill.Total = closing.Total
+		if bill.Total != nil && bill.Total.ComputedAt == nil {
+			computedAt := time.Now()
+			bill.Total.ComputedAt = &computedAt
+		}
+		if journal != nil {
+			if _, err := m.postJournal(journal); err != nil {
+				return err
+			}
+		}
+		m.schedules = append(m.schedules, schedules...)
+		return m.recordAuditEvent(ctx, bill.ID, models.AuditEntityBill, bill.ID, models.AuditActionBillClosed, before, bill)
+	}
+	return models.ErrBillNotFound
+}
+
+func (m *FakeRepo) FinalizeBill(ctx context.Context, billID uuid.UUID, finalizedAt time.Time) error {
+	bill, exists := m.bills[billID]
+	if !exists || bill.Status != models.BillStatusClosed {
+		return sql.ErrNoRows
+	}
+	before := *bill
+	bill.Status = models.BillStatusFinalized
+	bill.FinalizedAt = &finalizedAt
+	bill.UpdatedAt = time.Now()
+	return m.recordAuditEvent(ctx, billID, models.AuditEntityBill, billID, models.AuditActionBillFinalized, before, bill)
+}
+
+func (m *FakeRepo) VoidBill(ctx context.Context, billID uuid.UUID, reason string, voidedAt time.Time) error {
+	bill, exists := m.bills[billID]
+	if exists && bill.Status == models.BillStatusVoided && bill.VoidedAt != nil && bill.VoidedAt.Equal(voidedAt) {
+		return nil
+	}
+	if !exists || !bill.Status.CanTransitionTo(models.BillStatusVoided) {
+		return sql.ErrNoRows
+	}
+	before := *bill
+	bill.Status = models.BillStatusVoided
+	bill.VoidedAt = &voidedAt
+	bill.VoidReason = reason
+	bill.UpdatedAt = time.Now()
+	if err := m.reverseBillClosedJournals(billID, voidedAt); err != nil {
+		return err
+	}
+	m.deleteRecognitionSchedules(billID)
+	return m.recordAuditEvent(ctx, billID, models.AuditEntityBill, billID, models.AuditActionBillVoided, before, bill)
+}
+
+func (m *FakeRepo) ReopenBill(ctx context.Context, billID uuid.UUID) error {
+	bill, exists := m.bills[billID]
+	if !exists || bill.Status != models.BillStatusClosed {
+		return sql.ErrNoRows
+	}
+	before := *bill
+	bill.Status = models.BillStatusOpen
+	bill.ClosedAt = nil
+	bill.ClosingAt = nil
+	bill.Total = nil
+	bill.UpdatedAt = time.Now()
+	if err := m.reverseBillClosedJournals(billID, time.Now()); err != nil {
+		return err
+	}
+	m.deleteRecognitionSchedules(billID)
+	return m.recordAuditEvent(ctx, billID, models.AuditEntityBill, billID, models.AuditActionBillReopened, before, bill)
+}
+
+// postJournal appends the balanced journal, returning false when a journal with the same key is already posted
+func (m *FakeRepo) postJournal(journal *models.Journal) (bool, error) {
+	if err := journal.Validate(); err != nil {
+		return false, err
+	}
+	for _, posted := range m.journals {
+		if posted.Key == journal.Key {
+			return false, nil
+		}
+	}
+	m.journals = append(m.journals, journal)
+	return true, nil
+}
+
+func (m *FakeRepo) reverseBillClosedJournals(billID uuid.UUID, postedAt time.Time) error {
+	for _, journal := range m.billJournals(billID) {
+		if journal.Type != models.JournalBillClosed {
+			continue
+		}
+		reversal, err := journal.Reverse(postedAt)
+		if err != nil {
+			return err
+		}
+		if _, err = m.postJournal(reversal); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *FakeRepo) billJournals(billID uuid.UUID) []*models.Journal {
+	journals := []*models.Journal{}
+	for _, journal := range m.journals {
+		if journal.BillID == billID {
+			journals = append(journals, journal)
+		}
+	}
+	return journals
+}
+
+func (m *FakeRepo) PostBillJournals(
+	ctx context.Context,
+	billID uuid.UUID,
+	build func(status models.BillStatus, ledger []*models.Journal) ([]*models.Journal, error),
+) ([]*models.Journal, error) {
+	bill, exists := m.bills[billID]
+	if !exists {
+		return nil, sql.ErrNoRows
+	}
+	journals, err := build(bill.Status, m.billJournals(billID))
+	if err != nil {
+		return nil, err
+	}
+	for _, journal := range journals {
+		for _, posted := range m.journals {
+			if posted.Key == journal.Key {
+				return nil, models.ErrJournalAlreadyPosted
+			}
+		}
+	}
+	for _, journal := range journals {
+		if _, err = m.postJournal(journal); err != nil {
+			return nil, err
+		}
+	}
+	return journals, nil
+}
+
+func (m *FakeRepo) ListBillJournals(ctx context.Context, billID uuid.UUID) ([]*models.Journal, error) {
+	return m.billJournals(billID), nil
+}
+
+func (m *FakeRepo) ListJournals(ctx context.Context, postedFrom, postedTo time.Time) ([]*models.Journal, error) {
+	journals := []*models.Journal{}
+	for _, journal := range m.journals {
+		if !journal.PostedAt.Before(postedFrom) && journal.PostedAt.Before(postedTo) {
+			journals = append(journals, journal)
+		}
+	}
+	slices.SortStableFunc(journals, func(a, b *models.Journal) int { return a.PostedAt.Compare(b.PostedAt) })
+	return journals, nil
+}
+
+func (m *FakeRepo) GetTrialBalance(ctx context.Context, asOf time.Time, functional models.Currency) (*models.TrialBalance, error) {
+	type key struct {
+		account  string
+		currency models.Currency
+	}
+	totals := make(map[key]*models.TrialBalanceLine)
+	keys := []key{}
+	for _, journal := range m.journals {
+		if journal.PostedAt.After(asOf) {
+			continue
+		}
+		for _, entry := range journal.Entries {
+			if entry.FunctionalCurrency != functional {
+				continue
+			}
+			k := key{account: entry.Account, currency: entry.Currency}
+			line, exists := totals[k]
+			if !exists {
+				line = &models.TrialBalanceLine{Account: entry.Account, Currency: entry.Currency}
+				totals[k] = line
+				keys = append(keys, k)
+			}
+			if entry.Side == models.LedgerDebit {
+				line.Debit = line.Debit.Add(entry.Amount)
+				line.FunctionalDebit = line.FunctionalDebit.Add(entry.FunctionalAmount)
+			} else {
+				line.Credit = line.Credit.Add(entry.Amount)
+				line.FunctionalCredit = line.FunctionalCredit.Add(entry.FunctionalAmount)
+			}
+		}
+	}
+	slices.SortFunc(keys, func(a, b key) int {
+		if c := strings.Compare(a.account, b.account); c != 0 {
+			return c
+		}
+		return strings.Compare(string(a.currency), string(b.currency))
+	})
+	lines := []models.TrialBalanceLine{}
+	for _, k := range keys {
+		lines = append(lines, *totals[k])
+	}
+	return models.NewTrialBalance(asOf, functional, lines, nil), nil
+}
+
+func (m *FakeRepo) CreateExportJob(ctx context.Context, job *models.ExportJob) error {
+	if m.exports == nil {
+		m.exports = make(map[uuid.UUID]*models.ExportJob)
+	}
+	if _, exists := m.exports[job.ID]; exists {
+		return nil
+	}
+	saved := *job
+	m.exports[job.ID] = &saved
+	return nil
+}
+
+func (m *FakeRepo) GetExportJob(ctx context.Context, id uuid.UUID) (*models.ExportJob, error) {
+	if job, exists := m.exports[id]; exists {
+		copied := *job
+		return &copied, nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *FakeRepo) MarkExportJobRunning(ctx context.Context, id uuid.UUID, startedAt time.Time) error {
+	job, exists := m.exports[id]
+	if !exists || (job.Status != models.ExportStatusPending && job.Status != models.ExportStatusRunning) {
+		return sql.ErrNoRows
+	}
+	job.Status = models.ExportStatusRunning
+	job.StartedAt = &startedAt
+	return nil
+}
+
+func (m *FakeRepo) CompleteExportJob(ctx context.Context, id uuid.UUID, rows int, completedAt time.Time) error {
+	job, exists := m.exports[id]
+	if !exists {
+		return sql.ErrNoRows
+	}
+	if job.Status == models.ExportStatusCompleted && job.CompletedAt.Equal(completedAt) {
+		return nil
+	}
+	if job.Status != models.ExportStatusRunning {
+		return sql.ErrNoRows
+	}
+	job.Status = models.ExportStatusCompleted
+	job.Rows = rows
+	job.CompletedAt = &completedAt
+	return nil
+}
+
+func (m *FakeRepo) FailExportJob(ctx context.Context, id uuid.UUID, message string, failedAt time.Time) error {
+	job, exists := m.exports[id]
+	if !exists {
+		return sql.ErrNoRows
+	}
+	if job.Status == models.ExportStatusFailed && job.CompletedAt.Equal(failedAt) {
+		return nil
+	}
+	if job.Status != models.ExportStatusPending && job.Status != models.ExportStatusRunning {
+		return sql.ErrNoRows
+	}
+	job.Status = models.ExportStatusFailed
+	job.Error = message
+	job.CompletedAt = &failedAt
+	return nil
+}
+
+func (m *FakeRepo) CreateBankTransaction(ctx context.Context, txn *models.BankTransaction) (bool, error) {
+	for _, existing := range m.bankTxns {
+		if existing.EntryReference == txn.EntryReference {
+			return false, nil
+		}
+	}
+	saved := *txn
+	m.bankTxns = append(m.bankTxns, &saved)
+	return true, nil
+}
+
+func (m *FakeRepo) UpdateBankTransactionMatch(ctx context.Context, txn *models.BankTransaction) error {
+	for i, existing := range m.bankTxns {
+		if existing.ID == txn.ID && existing.Status == models.BankTransactionStatusReview {
+			saved := *txn
+			m.bankTxns[i] = &saved
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (m *FakeRepo) ListBankTransactions(ctx context.Context, filter models.BankTransactionFilter) ([]*models.BankTransaction, error) {
+	txns := make([]*models.BankTransaction, 0)
+	for _, txn := range m.bankTxns {
+		if filter.Status == "" || txn.Status == filter.Status {
+			copied := *txn
+			txns = append(txns, &copied)
+		}
+	}
+	slices.SortStableFunc(txns, func(a, b *models.BankTransaction) int { return b.BookedAt.Compare(a.BookedAt) })
+	if filter.Limit > 0 && len(txns) > filter.Limit {
+		txns = txns[:filter.Limit]
+	}
+	return txns, nil
+}
+
+func (m *FakeRepo) GetBankTransaction(ctx context.Context, id uuid.UUID) (*models.BankTransaction, error) {
+	for _, txn := range m.bankTxns {
+		if txn.ID == id {
+			copied := *txn
+			return &copied, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *FakeRepo) ListReceivables(ctx context.Context, account string, currency models.Currency) ([]models.Receivable, error) {
+	receivables := make([]models.Receivable, 0)
+	for id, bill := range m.bills {
+		if bill.Status != models.BillStatusClosed && bill.Status != models.BillStatusFinalized {
+			continue
+		}
+		outstanding, _ := models.LedgerBalance(m.billJournals(id), account, currency)
+		if outstanding.IsPositive() {
+			receivables = append(receivables, models.Receivable{
+				BillID: id, CustomerID: bill.CustomerID, Currency: currency, Outstanding: outstanding,
+			})
+		}
+	}
+	slices.SortFunc(receivables, func(a, b models.Receivable) int {
+		return strings.Compare(a.BillID.String(), b.BillID.String())
+	})
+	return receivables, nil
+}
+
+// receivableActivity returns the activity up to the time of closed and finalized bills, of every customer when empty
+func (m *FakeRepo) receivableActivity(account string, to time.Time, customerID string) []models.ReceivableActivity {
+	activity := make([]models.ReceivableActivity, 0)
+	for id, bill := range m.bills {
+		if bill.Status != models.BillStatusClosed && bill.Status != models.BillStatusFinalized {
+			continue
+		}
+		if bill.ClosedAt == nil || bill.ClosedAt.After(to) || (customerID != "" && bill.CustomerID != customerID) {
+			continue
+		}
+		billed := make(map[models.Currency]decimal.Decimal)
+		journals := m.billJournals(id)
+		closeJournaled := slices.ContainsFunc(journals, func(j *models.Journal) bool { return j.Type == models.JournalBillClosed })
+		if closeJournaled {
+			for _, journal := range journals {
+				if journal.Type != models.JournalBillClosed && journal.Type != models.JournalBillReversal {
+					continue
+				}
+				for _, entry := range journal.Entries {
+					if entry.Account != account {
+						continue
+					}
+					if entry.Side == models.LedgerDebit {
+						billed[entry.Currency] = billed[entry.Currency].Add(entry.Amount)
+					} else {
+						billed[entry.Currency] = billed[entry.Currency].Sub(entry.Amount)
+					}
+				}
+			}
+		} else if bill.Total != nil && bill.Total.ComputedAt != nil {
+			billed = bill.Total.ByCurrency
+		} else {
+			for _, group := range models.GroupLineTotals(m.lineItems[id]) {
+				billed[group.Currency] = billed[group.Currency].Add(group.Sum)
+			}
+		}
+		for currency, amount := range billed {
+			activity = append(activity, models.ReceivableActivity{
+				Type: models.ReceivableActivityBill, BillID: id, OccurredAt: *bill.ClosedAt, Currency: currency, Amount: amount,
+			})
+		}
+		for _, journal := range journals {
+			if journal.Type != models.JournalPayment && journal.Type != models.JournalCreditNote {
+				continue
+			}
+			if journal.PostedAt.After(to) {
+				continue
+			}
+			for _, entry := range journal.Entries {
+				if entry.Account == account && entry.Side == models.LedgerCredit {
+					activity = append(activity, models.ReceivableActivity{
+						Type:       models.ReceivableActivityType(journal.Type),
+						BillID:     id,
+						Reference:  journal.Reference,
+						OccurredAt: journal.PostedAt,
+						Currency:   entry.Currency,
+						Amount:     entry.Amount,
+					})
+				}
+			}
+		}
+	}
+	return activity
+}
+
+func (m *FakeRepo) ListOpenReceivables(
+	ctx context.Context, account string, asOf time.Time, customerID string,
+) ([]models.OpenReceivable, error) {
+	type key struct {
+		billID   uuid.UUID
+		currency models.Currency
+	}
+	outstanding := make(map[key]decimal.Decimal)
+	for _, a := range m.receivableActivity(account, asOf, customerID) {
+		k := key{billID: a.BillID, currency: a.Currency}
+		if a.Type == models.ReceivableActivityBill {
+			outstanding[k] = outstanding[k].Add(a.Amount)
+		} else {
+			outstanding[k] = outstanding[k].Sub(a.Amount)
+		}
+	}
+
+	receivables := make([]models.OpenReceivable, 0)
+	for k, amount := range outstanding {
+		if !amount.IsPositive() {
+			continue
+		}
+		bill := m.bills[k.billID]
+		receivables = append(receivables, models.OpenReceivable{
+			BillID: k.billID, CustomerID: bill.CustomerID, Currency: k.currency, ClosedAt: *bill.ClosedAt, Outstanding: amount,
+		})
+	}
+	slices.SortFunc(receivables, func(a, b models.OpenReceivable) int {
+		if c := strings.Compare(a.CustomerID, b.CustomerID); c != 0 {
+			return c
+		}
+		if c := strings.Compare(string(a.Currency), string(b.Currency)); c != 0 {
+			return c
+		}
+		if c := a.ClosedAt.Compare(b.ClosedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.BillID.String(), b.BillID.String())
+	})
+	return receivables, nil
+}
+
+func (m *FakeRepo) ListReceivableActivity(
+	ctx context.Context, account string, customerID string, to time.Time,
+) ([]models.ReceivableActivity, error) {
+	activity := m.receivableActivity(account, to, customerID)
+	slices.SortFunc(activity, func(a, b models.ReceivableActivity) int {
+		if c := a.OccurredAt.Compare(b.OccurredAt); c != 0 {
+			return c
+		}
+		if c := strings.Compare(string(a.Type), string(b.Type)); c != 0 {
+			return c
+		}
+		return strings.Compare(a.BillID.String(), b.BillID.String())
+	})
+	return activity, nil
+}
+
+func (m *FakeRepo) RefreshRevenueRollups(ctx context.Context, from, refreshedAt time.Time) (*models.RollupRefresh, error) {
+	// Bills changed since the last refresh are rebuilt from the month their period starts in
+	if m.refresh != nil {
+		for _, bill := range m.bills {
+			if bill.UpdatedAt.After(m.refresh.RefreshedAt) && bill.PeriodStart.Before(from) {
+				from = bill.PeriodStart
+			}
+		}
+	}
+	from = models.StartOfMonth(from)
+	type key struct {
+		month      time.Time
+		customerID string
+		currency   models.Currency
+	}
+	rebuilt := make(map[key]*revenueRollup)
+	rollup := func(month time.Time, customerID string, currency models.Currency) *revenueRollup {
+		k := key{month: month, customerID: customerID, currency: currency}
+		if rebuilt[k] == nil {
+			rebuilt[k] = &revenueRollup{month: month, customerID: customerID, currency: currency}
+		}
+		return rebuilt[k]
+	}
+
+	for id, bill := range m.bills {
+		if bill.Status != models.BillStatusClosed && bill.Status != models.BillStatusFinalized {
+			continue
+		}
+		if !bill.ClosedAt.Before(from) {
+			billed := make(map[models.Currency]decimal.Decimal)
+			if bill.Total != nil && bill.Total.ComputedAt != nil {
+				billed = bill.Total.ByCurrency
+			} else {
+				for _, group := range models.GroupLineTotals(m.lineItems[id]) {
+					billed[group.Currency] = billed[group.Currency].Add(group.Sum)
+				}
+			}
+			for currency, amount := range billed {
+				r := rollup(models.StartOfMonth(*bill.ClosedAt), bill.CustomerID, currency)
+				r.billed = r.billed.Add(amount)
+			}
+		}
+
+		recurring := make(map[models.Currency]decimal.Decimal)
+		for _, item := range m.lineItems[id] {
+			if item.Recurring {
+				recurring[item.Currency] = recurring[item.Currency].Add(item.Quantity.Mul(item.UnitPrice))
+			}
+		}
+		months := []time.Time{}
+		for month := models.StartOfMonth(bill.PeriodStart); month.Before(bill.PeriodEnd); month = month.AddDate(0, 1, 0) {
+			months = append(months, month)
+		}
+		for currency, amount := range recurring {
+			share := amount.Div(decimal.NewFromInt(int64(len(months))))
+			for _, month := range months {
+				if !month.Before(from) {
+					r := rollup(month, bill.CustomerID, currency)
+					r.recurring = r.recurring.Add(share)
+				}
+			}
+		}
+	}
+
+	rollups := make([]revenueRollup, 0, len(m.rollups)+len(rebuilt))
+	for _, r := range m.rollups {
+		if r.month.Before(from) {
+			rollups = append(rollups, r)
+		}
+	}
+	for _, r := range rebuilt {
+		rollups = append(rollups, *r)
+	}
+	m.rollups = rollups
+	m.refresh = &models.RollupRefresh{From: from, RefreshedAt: refreshedAt, Rows: int64(len(rebuilt))}
+	return m.refresh, nil
+}
+
+func (m *FakeRepo) GetRevenueRollupsRefresh(ctx context.Context) (*models.RollupRefresh, error) {
+	if m.refresh == nil {
+		return nil, sql.ErrNoRows
+	}
+	return m.refresh, nil
+}
+
+func (m *FakeRepo) ListRevenueByPeriod(
+	ctx context.Context, from, to time.Time, granularity models.Granularity,
+) ([]models.PeriodRevenue, error) {
+	revenue := make([]models.PeriodRevenue, 0)
+	for _, r := range m.rollups {
+		if r.month.Before(models.StartOfMonth(from)) || !r.month.Before(models.StartOfMonth(to)) || r.billed.IsZero() {
+			continue
+		}
+		revenue = append(revenue, models.PeriodRevenue{
+			PeriodStart: granularity.PeriodStart(r.month), Currency: r.currency, Billed: r.billed,
+		})
+	}
+	return revenue, nil
+}
+
+func (m *FakeRepo) ListRecurringRevenue(ctx context.Context, from, to time.Time) ([]models.RecurringRevenue, error) {
+	recurring := make([]models.RecurringRevenue, 0)
+	for _, r := range m.rollups {
+		if r.month.Before(models.StartOfMonth(from)) || !r.month.Before(models.StartOfMonth(to)) || r.recurring.IsZero() {
+			continue
+		}
+		recurring = append(recurring, models.RecurringRevenue{
+			Month: r.month, CustomerID: r.customerID, Currency: r.currency, MRR: r.recurring,
+		})
+	}
+	return recurring, nil
+}
+
+func (m *FakeRepo) AddLineItemToBill(ctx context.Context, lineItem *models.LineItem) error {
+	if m.lineItems == nil {
+		m.lineItems = make(map[uuid.UUID][]*models.LineItem)
+	}
+	for _, item := range m.lineItems[lineItem.BillID] {
+		if item.ID == lineItem.ID {
+			return nil
+		}
+	}
+	if bill, exists := m.bills[lineItem.BillID]; exists && !bill.IsActive() {
+		return models.ErrBillClosed
+	}
+	m.lineItems[lineItem.BillID] = append(m.lineItems[lineItem.BillID], lineItem)
+	return m.recordAuditEvent(ctx, lineItem.BillID, models.AuditEntityLineItem, lineItem.ID,
+		models.AuditActionLineItemAdded, nil, lineItem)
+}
+
+func (m *FakeRepo) UpdateLineItem(ctx context.Context, lineItem *models.LineItem) error {
+	if bill, exists := m.bills[lineItem.BillID]; exists && !bill.IsOpen() {
+		return models.ErrBillClosed
+	}
+	for i, item := range m.lineItems[lineItem.BillID] {
+		if item.ID == lineItem.ID {
+			if item.UpdatedAt != nil && lineItem.UpdatedAt != nil && item.UpdatedAt.After(*lineItem.UpdatedAt) {
+				return sql.ErrNoRows
+			}
+			m.lineItems[lineItem.BillID][i] = lineItem
+			return m.recordAuditEvent(ctx, lineItem.BillID, models.AuditEntityLineItem, lineItem.ID,
+				models.AuditActionLineItemUpdated, item, lineItem)
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (m *FakeRepo) DeleteLineItem(ctx context.Context, billID uuid.UUID, lineItemID uuid.UUID, reason string, deletedAt time.Time) error {
+	if bill, exists := m.bills[billID]; exists && !bill.IsOpen() {
+		return models.ErrBillClosed
+	}
+	for i, item := range m.lineItems[billID] {
+		if item.ID == lineItemID {
+			m.lineItems[billID] = slices.Delete(m.lineItems[billID], i, i+1)
+			return m.recordAuditEvent(ctx, billID, models.AuditEntityLineItem, lineItemID,
+				models.AuditActionLineItemRemoved, item, map[string]interface{}{
+					"id": lineItemID, "deleted_at": deletedAt, "deletion_reason": reason,
+				})
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (m *FakeRepo) GetLineItemsByBillID(ctx context.Context, billID uuid.UUID) ([]*models.LineItem, error) {
+	if lineItems, exists := m.lineItems[billID]; exists {
+		return lineItems, nil
+	}
+	return []*models.LineItem{}, nil
+}
+
+func (m *FakeRepo) GetLineItem(ctx context.Context, billID, lineItemID uuid.UUID) (*models.LineItem, error) {
+	for _, item := range m.lineItems[billID] {
+		if item.ID == lineItemID {
+			return item, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *FakeRepo) ListLineItems(ctx context.Context, billID uuid.UUID, filter models.LineItemFilter) ([]*models.LineItem, error) {
+	lineItems := make([]*models.LineItem, 0)
+	for _, item := range m.lineItems[billID] {
+		if filter.Currency != "" && item.Currency != filter.Currency {
+			continue
+		}
+		if filter.CreatedAfter != nil && item.CreatedAt.Before(*filter.CreatedAfter) {
+			continue
+		}
+		if filter.CreatedBefore != nil && !item.CreatedAt.Before(*filter.CreatedBefore) {
+			continue
+		}
+		lineItems = append(lineItems, item)
+	}
+	slices.SortFunc(lineItems, func(a, b *models.LineItem) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID.String(), b.ID.String())
+	})
+	if filter.After != nil {
+		lineItems = slices.DeleteFunc(lineItems, func(item *models.LineItem) bool {
+			if c := item.CreatedAt.Compare(filter.After.CreatedAt); c != 0 {
+				return c < 0
+			}
+			return item.ID.String() <= filter.After.ID.String()
+		})
+	}
+	if filter.Limit > 0 && len(lineItems) > filter.Limit {
+		lineItems = lineItems[:filter.Limit]
+	}
+	return lineItems, nil
+}
+
+func (m *FakeRepo) GetLineTotalGroups(ctx context.Context, billID uuid.UUID, byLineAmount bool) ([]models.LineTotalGroup, error) {
+	groups := models.GroupLineTotals(m.lineItems[billID])
+	if byLineAmount {
+		return groups, nil
+	}
+	// Merge the per line amount groups into one group per currency
+	merged := make([]models.LineTotalGroup, 0)
+	for _, group := range groups {
+		i := slices.IndexFunc(merged, func(g models.LineTotalGroup) bool { return g.Currency == group.Currency })
+		if i < 0 {
+			merged = append(merged, models.LineTotalGroup{Currency: group.Currency, Sum: group.Sum, Count: group.Count})
+			continue
+		}
+		merged[i].Sum = merged[i].Sum.Add(group.Sum)
+		merged[i].Count += group.Count
+	}
+	return merged, nil
+}
+
+func (m *FakeRepo) GetCustomerProfile(ctx context.Context, customerID string) (*models.CustomerProfile, error) {
+	if profile, exists := m.profiles[customerID]; exists {
+		return profile, nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *FakeRepo) UpsertCustomerProfile(ctx context.Context, profile *models.CustomerProfile) error {
+	if m.profiles == nil {
+		m.profiles = make(map[string]*models.CustomerProfile)
+	}
+	if existing, exists := m.profiles[profile.CustomerID]; exists {
+		profile.CreatedAt = existing.CreatedAt
+	}
+	m.profiles[profile.CustomerID] = profile
+	return nil
+}
+
+// deleteRecognitionSchedules discards the recognition schedules of the bill
+func (m *FakeRepo) deleteRecognitionSchedules(billID uuid.UUID) {
+	m.schedules = slices.DeleteFunc(m.schedules, func(s *models.RecognitionSchedule) bool { return s.BillID == billID })
+}
+
+func (m *FakeRepo) ListBillRecognitionSchedules(ctx context.Context, billID uuid.UUID) ([]*models.RecognitionSchedule, error) {
+	schedules := []*models.RecognitionSchedule{}
+	for _, schedule := range m.schedules {
+		if schedule.BillID == billID {
+			schedules = append(schedules, schedule)
+		}
+	}
+	return schedules, nil
+}
+
+func (m *FakeRepo) ListDeferredRevenueMovements(ctx context.Context, billedBefore time.Time) ([]models.DeferredRevenueMovement, error) {
+	type key struct {
+		month    time.Time
+		currency models.Currency
+	}
+	totals := make(map[key]*models.DeferredRevenueMovement)
+	movement := func(month time.Time, currency models.Currency) *models.DeferredRevenueMovement {
+		k := key{month: month, currency: currency}
+		if totals[k] == nil {
+			totals[k] = &models.DeferredRevenueMovement{Month: month, Currency: currency}
+		}
+		return totals[k]
+	}
+	for _, schedule := range m.schedules {
+		if !schedule.BilledAt.Before(billedBefore) {
+			continue
+		}
+		billed := movement(models.StartOfMonth(schedule.BilledAt), schedule.Currency)
+		billed.Billed = billed.Billed.Add(schedule.Amount)
+		for _, entry := range schedule.Entries {
+			recognized := movement(entry.RecognizedIn, schedule.Currency)
+			recognized.Recognized = recognized.Recognized.Add(entry.Recognized)
+		}
+	}
+
+	movements := make([]models.DeferredRevenueMovement, 0, len(totals))
+	for _, total := range totals {
+		movements = append(movements, *total)
+	}
+	slices.SortFunc(movements, func(a, b models.DeferredRevenueMovement) int {
+		if c := a.Month.Compare(b.Month); c != 0 {
+			return c
+		}
+		return strings.Compare(string(a.Currency), string(b.Currency))
+	})
+	return movements, nil
+}
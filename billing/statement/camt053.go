@@ -0,0 +1,279 @@
+// Package statement parses the credits of bank statements imported to match bill payments
+package statement
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"encore.app/billing/models"
+	"encore.dev/beta/errs"
+	"github.com/shopspring/decimal"
+)
+
+// camtDocument is the part of an ISO 20022 camt.053 statement the import reads. Element names are matched
+// in any namespace, so every version of the message from camt.053.001.02 on is accepted.
+type camtDocument struct {
+	Statements []camtStatement `xml:"BkToCstmrStmt>Stmt"`
+}
+
+type camtStatement struct {
+	ID      string      `xml:"Id"`
+	IBAN    string      `xml:"Acct>Id>IBAN"`
+	Other   string      `xml:"Acct>Id>Othr>Id"`
+	Entries []camtEntry `xml:"Ntry"`
+}
+
+type camtEntry struct {
+	NtryRef     string          `xml:"NtryRef"`
+	Amount      camtAmount      `xml:"Amt"`
+	Indicator   string          `xml:"CdtDbtInd"`
+	Reversal    bool            `xml:"RvslInd"`
+	Status      camtStatus      `xml:"Sts"`
+	BookingDate camtDate        `xml:"BookgDt"`
+	AcctSvcrRef string          `xml:"AcctSvcrRef"`
+	Details     []camtTxDetails `xml:"NtryDtls>TxDtls"`
+}
+
+type camtAmount struct {
+	Currency string `xml:"Ccy,attr"`
+	Value    string `xml:",chardata"`
+}
+
+// camtStatus is the entry status, a code since camt.053.001.08 and text before
+type camtStatus struct {
+	Text string `xml:",chardata"`
+	Code string `xml:"Cd"`
+}
+
+func (s camtStatus) value() string {
+	if s.Code != "" {
+		return strings.TrimSpace(s.Code)
+	}
+	return strings.TrimSpace(s.Text)
+}
+
+type camtDate struct {
+	Date     string `xml:"Dt"`
+	DateTime string `xml:"DtTm"`
+}
+
+type camtTxDetails struct {
+	AcctSvcrRef string     `xml:"Refs>AcctSvcrRef"`
+	EndToEndID  string     `xml:"Refs>EndToEndId"`
+	Amount      camtAmount `xml:"Amt"`
+	TxAmount    camtAmount `xml:"AmtDtls>TxAmt>Amt"`
+	Indicator   string     `xml:"CdtDbtInd"`
+	// The debtor name is nested in a party since camt.053.001.08
+	Debtor       string   `xml:"RltdPties>Dbtr>Nm"`
+	DebtorParty  string   `xml:"RltdPties>Dbtr>Pty>Nm"`
+	Unstructured []string `xml:"RmtInf>Ustrd"`
+	Structured   []string `xml:"RmtInf>Strd>CdtrRefInf>Ref"`
+}
+
+func (d camtTxDetails) amount() camtAmount {
+	if d.Amount.Value != "" {
+		return d.Amount
+	}
+	return d.TxAmount
+}
+
+func (d camtTxDetails) reference() string {
+	return strings.Join(append(append([]string{}, d.Structured...), d.Unstructured...), " ")
+}
+
+func (d camtTxDetails) counterparty() string {
+	if d.Debtor != "" {
+		return d.Debtor
+	}
+	return d.DebtorParty
+}
+
+// ParseCamt053 reads the booked credits of a camt.053 statement. An entry batching several transactions
+// with their own amounts is split into a credit per transaction.
+func ParseCamt053(r io.Reader) (*models.Statement, error) {
+	var doc camtDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, invalid("camt.053", err)
+	}
+	if len(doc.Statements) == 0 {
+		return nil, invalid("camt.053", fmt.Errorf("no BkToCstmrStmt/Stmt element"))
+	}
+
+	parsed := &models.Statement{Credits: []models.StatementCredit{}}
+	ids := newEntryIDs()
+	for _, stmt := range doc.Statements {
+		account := stmt.IBAN
+		if account == "" {
+			account = stmt.Other
+		}
+		for i, entry := range stmt.Entries {
+			if entry.Indicator != "CRDT" || entry.Reversal || entry.Status.value() != "BOOK" {
+				parsed.Skipped++
+				continue
+			}
+			bookedAt, err := entry.BookingDate.parse()
+			if err != nil {
+				return nil, invalid("camt.053", fmt.Errorf("statement %s entry %d: %w", stmt.ID, i+1, err))
+			}
+			credits, err := entry.credits(account, bookedAt, ids)
+			if err != nil {
+				return nil, invalid("camt.053", fmt.Errorf("statement %s entry %d: %w", stmt.ID, i+1, err))
+			}
+			parsed.Credits = append(parsed.Credits, credits...)
+		}
+	}
+	return parsed, nil
+}
+
+// credits returns the credit of the entry, or of each of its transactions when they have their own amounts
+func (e camtEntry) credits(account string, bookedAt time.Time, ids *entryIDs) ([]models.StatementCredit, error) {
+	split := len(e.Details) > 1
+	for _, details := range e.Details {
+		split = split && details.amount().Value != ""
+	}
+
+	if !split {
+		currency, amount, err := parseAmount(e.Amount)
+		if err != nil {
+			return nil, err
+		}
+		credit := models.StatementCredit{BookedAt: bookedAt, Currency: currency, Amount: amount}
+		var references, counterparties []string
+		for _, details := range e.Details {
+			references = appendNonEmpty(references, details.reference())
+			counterparties = appendNonEmpty(counterparties, details.counterparty())
+		}
+		credit.Reference = strings.Join(references, " ")
+		credit.Counterparty = strings.Join(counterparties, ", ")
+		credit.EntryReference = ids.next(account, firstNonEmpty(e.AcctSvcrRef, e.NtryRef), credit)
+		return []models.StatementCredit{credit}, nil
+	}
+
+	credits := make([]models.StatementCredit, 0, len(e.Details))
+	for i, details := range e.Details {
+		if details.Indicator == "DBIT" {
+			continue
+		}
+		currency, amount, err := parseAmount(details.amount())
+		if err != nil {
+			return nil, fmt.Errorf("transaction %d: %w", i+1, err)
+		}
+		credit := models.StatementCredit{
+			BookedAt:     bookedAt,
+			Currency:     currency,
+			Amount:       amount,
+			Reference:    details.reference(),
+			Counterparty: details.counterparty(),
+		}
+		entryRef := firstNonEmpty(details.AcctSvcrRef, details.EndToEndID)
+		if entryRef == "" && firstNonEmpty(e.AcctSvcrRef, e.NtryRef) != "" {
+			entryRef = firstNonEmpty(e.AcctSvcrRef, e.NtryRef) + "/" + strconv.Itoa(i+1)
+		}
+		credit.EntryReference = ids.next(account, entryRef, credit)
+		credits = append(credits, credit)
+	}
+	return credits, nil
+}
+
+func (d camtDate) parse() (time.Time, error) {
+	if d.DateTime != "" {
+		return time.Parse(time.RFC3339, strings.TrimSpace(d.DateTime))
+	}
+	if d.Date != "" {
+		return time.Parse(time.DateOnly, strings.TrimSpace(d.Date))
+	}
+	return time.Time{}, fmt.Errorf("missing booking date")
+}
+
+func parseAmount(amount camtAmount) (models.Currency, decimal.Decimal, error) {
+	value, err := decimal.NewFromString(strings.TrimSpace(amount.Value))
+	if err != nil {
+		return "", decimal.Zero, fmt.Errorf("invalid amount %q", amount.Value)
+	}
+	currency, err := parseCurrency(amount.Currency)
+	if err != nil {
+		return "", decimal.Zero, err
+	}
+	return currency, value, nil
+}
+
+// parseCurrency reads an ISO 4217 alphabetic code, whether it is enabled is checked when matching
+func parseCurrency(code string) (models.Currency, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != 3 || strings.Trim(code, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+		return "", fmt.Errorf("invalid currency %q", code)
+	}
+	return models.Currency(code), nil
+}
+
+// maxEntryReference is the longest entry reference stored, longer ones are hashed so the payment reference
+// of a matched credit fits the ledger
+const maxEntryReference = 200
+
+// entryIDs identifies the credits of a statement. Credits without a bank reference are identified by a hash
+// of the account and their fields, numbered when the statement books several identical credits, so importing
+// the same statement again finds the same entries.
+type entryIDs struct {
+	seen map[string]int
+}
+
+func newEntryIDs() *entryIDs {
+	return &entryIDs{seen: make(map[string]int)}
+}
+
+func (ids *entryIDs) next(account, reference string, credit models.StatementCredit) string {
+	if reference != "" {
+		if account != "" {
+			reference = account + "/" + reference
+		}
+		if len(reference) <= maxEntryReference {
+			return reference
+		}
+		return hashReference(reference)
+	}
+	fields := strings.Join([]string{
+		account,
+		credit.BookedAt.Format(time.RFC3339),
+		string(credit.Currency),
+		credit.Amount.String(),
+		credit.Reference,
+		credit.Counterparty,
+	}, "\x1f")
+	ids.seen[fields]++
+	return hashReference(fields + "\x1f" + strconv.Itoa(ids.seen[fields]))
+}
+
+func hashReference(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return "sha256:" + hex.EncodeToString(sum[:16])
+}
+
+// invalid reports a statement that cannot be read as an invalid argument
+func invalid(format string, err error) error {
+	return &errs.Error{
+		Code:    errs.InvalidArgument,
+		Message: fmt.Sprintf("invalid %s bank statement: %v", format, err),
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func appendNonEmpty(values []string, value string) []string {
+	if value = strings.TrimSpace(value); value != "" {
+		return append(values, value)
+	}
+	return values
+}
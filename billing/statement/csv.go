@@ -0,0 +1,175 @@
+package statement
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"encore.app/billing/models"
+	"github.com/shopspring/decimal"
+)
+
+// CSVLayout is the layout of a CSV bank statement, columns are named by its header row
+type CSVLayout struct {
+	Delimiter        rune
+	DateLayout       string
+	DecimalSeparator string
+	DateColumn       string
+	// AmountColumn holds signed amounts, credits are positive
+	AmountColumn       string
+	CurrencyColumn     string
+	ReferenceColumn    string
+	CounterpartyColumn string
+	// EntryReferenceColumn identifies the entries, optional
+	EntryReferenceColumn string
+}
+
+// CSVLayoutFromConfig builds the CSV layout of bank statements
+func CSVLayoutFromConfig(cfg *models.AppConfig) (CSVLayout, error) {
+	csvCfg := cfg.Billing.BankStatements.CSV
+	delimiter, size := utf8.DecodeRuneInString(csvCfg.Delimiter())
+	if delimiter == utf8.RuneError || size != len(csvCfg.Delimiter()) {
+		return CSVLayout{}, fmt.Errorf("bank statement CSV delimiter %q must be a single character", csvCfg.Delimiter())
+	}
+	if sep := csvCfg.DecimalSeparator(); sep != "." && sep != "," {
+		return CSVLayout{}, fmt.Errorf("bank statement CSV decimal separator %q must be . or ,", sep)
+	}
+	layout := CSVLayout{
+		Delimiter:            delimiter,
+		DateLayout:           csvCfg.DateLayout(),
+		DecimalSeparator:     csvCfg.DecimalSeparator(),
+		DateColumn:           csvCfg.DateColumn(),
+		AmountColumn:         csvCfg.AmountColumn(),
+		CurrencyColumn:       csvCfg.CurrencyColumn(),
+		ReferenceColumn:      csvCfg.ReferenceColumn(),
+		CounterpartyColumn:   csvCfg.CounterpartyColumn(),
+		EntryReferenceColumn: csvCfg.EntryReferenceColumn(),
+	}
+	for name, column := range map[string]string{
+		"DateLayout":      layout.DateLayout,
+		"DateColumn":      layout.DateColumn,
+		"AmountColumn":    layout.AmountColumn,
+		"CurrencyColumn":  layout.CurrencyColumn,
+		"ReferenceColumn": layout.ReferenceColumn,
+	} {
+		if column == "" {
+			return CSVLayout{}, fmt.Errorf("bank statement CSV %s is not configured", name)
+		}
+	}
+	return layout, nil
+}
+
+// ParseCSV reads the credits of a CSV bank statement, rows with a negative or zero amount are skipped
+func ParseCSV(r io.Reader, layout CSVLayout) (*models.Statement, error) {
+	reader := csv.NewReader(r)
+	reader.Comma = layout.Delimiter
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, invalid("CSV", fmt.Errorf("missing header row"))
+	}
+	if err != nil {
+		return nil, invalid("CSV", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))] = i
+	}
+	index := func(name string, required bool) (int, error) {
+		if name == "" {
+			return -1, nil
+		}
+		i, ok := columns[name]
+		switch {
+		case ok:
+			return i, nil
+		case required:
+			return -1, fmt.Errorf("missing column %q", name)
+		}
+		return -1, nil
+	}
+	var dateCol, amountCol, currencyCol, referenceCol, counterpartyCol, entryCol int
+	for _, column := range []struct {
+		index    *int
+		name     string
+		required bool
+	}{
+		{&dateCol, layout.DateColumn, true},
+		{&amountCol, layout.AmountColumn, true},
+		{&currencyCol, layout.CurrencyColumn, true},
+		{&referenceCol, layout.ReferenceColumn, true},
+		{&counterpartyCol, layout.CounterpartyColumn, false},
+		{&entryCol, layout.EntryReferenceColumn, false},
+	} {
+		if *column.index, err = index(column.name, column.required); err != nil {
+			return nil, invalid("CSV", err)
+		}
+	}
+
+	parsed := &models.Statement{Credits: []models.StatementCredit{}}
+	ids := newEntryIDs()
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, invalid("CSV", err)
+		}
+		line, _ := reader.FieldPos(0)
+		field := func(i int) string {
+			if i < 0 || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		amount, err := parseCSVAmount(field(amountCol), layout.DecimalSeparator)
+		if err != nil {
+			return nil, invalid("CSV", fmt.Errorf("line %d: %w", line, err))
+		}
+		if !amount.IsPositive() {
+			parsed.Skipped++
+			continue
+		}
+		bookedAt, err := time.Parse(layout.DateLayout, field(dateCol))
+		if err != nil {
+			return nil, invalid("CSV", fmt.Errorf("line %d: invalid date %q", line, field(dateCol)))
+		}
+		currency, err := parseCurrency(field(currencyCol))
+		if err != nil {
+			return nil, invalid("CSV", fmt.Errorf("line %d: %w", line, err))
+		}
+
+		credit := models.StatementCredit{
+			BookedAt:     bookedAt,
+			Currency:     currency,
+			Amount:       amount,
+			Reference:    field(referenceCol),
+			Counterparty: field(counterpartyCol),
+		}
+		credit.EntryReference = ids.next("", field(entryCol), credit)
+		parsed.Credits = append(parsed.Credits, credit)
+	}
+	return parsed, nil
+}
+
+// parseCSVAmount reads a signed amount, dropping the grouping separators of its locale
+func parseCSVAmount(value, decimalSeparator string) (decimal.Decimal, error) {
+	normalized := strings.ReplaceAll(value, " ", "")
+	if decimalSeparator == "," {
+		normalized = strings.ReplaceAll(strings.ReplaceAll(normalized, ".", ""), ",", ".")
+	} else {
+		normalized = strings.ReplaceAll(normalized, ",", "")
+	}
+	amount, err := decimal.NewFromString(strings.TrimPrefix(normalized, "+"))
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("invalid amount %q", value)
+	}
+	return amount, nil
+}
@@ -0,0 +1,178 @@
+package statement
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"encore.app/billing/models"
+	"encore.dev/beta/errs"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const camt053 = `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.08">
+  <BkToCstmrStmt>
+    <Stmt>
+      <Id>STMT-2025-04-01</Id>
+      <Acct><Id><IBAN>DE89370400440532013000</IBAN></Id></Acct>
+      <Ntry>
+        <Amt Ccy="USD">115.00</Amt>
+        <CdtDbtInd>CRDT</CdtDbtInd>
+        <Sts><Cd>BOOK</Cd></Sts>
+        <BookgDt><Dt>2025-04-01</Dt></BookgDt>
+        <AcctSvcrRef>REF-1</AcctSvcrRef>
+        <NtryDtls><TxDtls>
+          <RltdPties><Dbtr><Pty><Nm>Acme Corp</Nm></Pty></Dbtr></RltdPties>
+          <RmtInf><Ustrd>Invoice 6f1c1d0e-8a6b-4c39-9d1e-2b7a4f0c5e11</Ustrd></RmtInf>
+        </TxDtls></NtryDtls>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="USD">40.00</Amt>
+        <CdtDbtInd>DBIT</CdtDbtInd>
+        <Sts><Cd>BOOK</Cd></Sts>
+        <BookgDt><Dt>2025-04-01</Dt></BookgDt>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="USD">10.00</Amt>
+        <CdtDbtInd>CRDT</CdtDbtInd>
+        <Sts><Cd>PDNG</Cd></Sts>
+        <BookgDt><Dt>2025-04-01</Dt></BookgDt>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="EUR">30.00</Amt>
+        <CdtDbtInd>CRDT</CdtDbtInd>
+        <Sts><Cd>BOOK</Cd></Sts>
+        <BookgDt><DtTm>2025-04-02T10:30:00+02:00</DtTm></BookgDt>
+        <AcctSvcrRef>BATCH-7</AcctSvcrRef>
+        <NtryDtls>
+          <TxDtls>
+            <Refs><EndToEndId>E2E-1</EndToEndId></Refs>
+            <Amt Ccy="EUR">12.50</Amt>
+            <RmtInf><Strd><CdtrRefInf><Ref>RF18 5390 0754 7034</Ref></CdtrRefInf></Strd></RmtInf>
+          </TxDtls>
+          <TxDtls>
+            <Amt Ccy="EUR">17.50</Amt>
+            <RltdPties><Dbtr><Nm>Globex</Nm></Dbtr></RltdPties>
+            <RmtInf><Ustrd>customer-2</Ustrd></RmtInf>
+          </TxDtls>
+        </NtryDtls>
+      </Ntry>
+    </Stmt>
+  </BkToCstmrStmt>
+</Document>`
+
+func TestParseCamt053(t *testing.T) {
+	t.Run("reads_booked_credits", func(t *testing.T) {
+		parsed, err := ParseCamt053(strings.NewReader(camt053))
+		require.NoError(t, err)
+
+		assert.Equal(t, 2, parsed.Skipped, "debits and pending entries are skipped")
+		require.Len(t, parsed.Credits, 3)
+
+		first := parsed.Credits[0]
+		assert.Equal(t, "DE89370400440532013000/REF-1", first.EntryReference)
+		assert.Equal(t, time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), first.BookedAt)
+		assert.Equal(t, models.USD, first.Currency)
+		assert.True(t, first.Amount.Equal(decimal.NewFromInt(115)))
+		assert.Equal(t, "Invoice 6f1c1d0e-8a6b-4c39-9d1e-2b7a4f0c5e11", first.Reference)
+		assert.Equal(t, "Acme Corp", first.Counterparty)
+	})
+
+	t.Run("splits_batched_transactions", func(t *testing.T) {
+		parsed, err := ParseCamt053(strings.NewReader(camt053))
+		require.NoError(t, err)
+
+		second, third := parsed.Credits[1], parsed.Credits[2]
+		assert.Equal(t, "DE89370400440532013000/E2E-1", second.EntryReference)
+		assert.True(t, second.Amount.Equal(decimal.RequireFromString("12.5")))
+		assert.Equal(t, "RF18 5390 0754 7034", second.Reference)
+		assert.Equal(t, "DE89370400440532013000/BATCH-7/2", third.EntryReference)
+		assert.True(t, third.Amount.Equal(decimal.RequireFromString("17.5")))
+		assert.Equal(t, "Globex", third.Counterparty)
+		assert.True(t, third.BookedAt.Equal(time.Date(2025, 4, 2, 8, 30, 0, 0, time.UTC)))
+	})
+
+	t.Run("identifies_entries_without_reference_by_their_fields", func(t *testing.T) {
+		doc := strings.NewReplacer("<AcctSvcrRef>REF-1</AcctSvcrRef>", "").Replace(camt053)
+
+		first, err := ParseCamt053(strings.NewReader(doc))
+		require.NoError(t, err)
+		again, err := ParseCamt053(strings.NewReader(doc))
+		require.NoError(t, err)
+
+		assert.True(t, strings.HasPrefix(first.Credits[0].EntryReference, "sha256:"))
+		assert.Equal(t, first.Credits[0].EntryReference, again.Credits[0].EntryReference)
+	})
+
+	t.Run("rejects_invalid_documents", func(t *testing.T) {
+		for name, doc := range map[string]string{
+			"not_xml":      "not xml",
+			"no_statement": `<Document><BkToCstmrStmt/></Document>`,
+			"bad_amount":   strings.Replace(camt053, "115.00", "abc", 1),
+			"no_date":      strings.Replace(camt053, "<BookgDt><Dt>2025-04-01</Dt></BookgDt>", "", 1),
+		} {
+			_, err := ParseCamt053(strings.NewReader(doc))
+			var e *errs.Error
+			require.True(t, errors.As(err, &e), name)
+			assert.Equal(t, errs.InvalidArgument, e.Code, name)
+		}
+	})
+}
+
+func testLayout() CSVLayout {
+	return CSVLayout{
+		Delimiter:            ';',
+		DateLayout:           "02.01.2006",
+		DecimalSeparator:     ",",
+		DateColumn:           "Date",
+		AmountColumn:         "Amount",
+		CurrencyColumn:       "Currency",
+		ReferenceColumn:      "Purpose",
+		CounterpartyColumn:   "Payer",
+		EntryReferenceColumn: "ID",
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	t.Run("reads_credits", func(t *testing.T) {
+		file := "\ufeffDate;Amount;Currency;Purpose;Payer;ID\n" +
+			"01.04.2025;1.234,56;eur;Bill 6f1c1d0e;Acme Corp;TX-1\n" +
+			"01.04.2025;-20,00;EUR;Fees;;TX-2\n" +
+			"02.04.2025;+15,00;USD;customer-2;Globex;\n"
+
+		parsed, err := ParseCSV(strings.NewReader(file), testLayout())
+		require.NoError(t, err)
+
+		assert.Equal(t, 1, parsed.Skipped)
+		require.Len(t, parsed.Credits, 2)
+		assert.Equal(t, models.StatementCredit{
+			EntryReference: "TX-1",
+			BookedAt:       time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC),
+			Currency:       models.Currency("EUR"),
+			Amount:         decimal.RequireFromString("1234.56"),
+			Reference:      "Bill 6f1c1d0e",
+			Counterparty:   "Acme Corp",
+		}, parsed.Credits[0])
+		assert.True(t, strings.HasPrefix(parsed.Credits[1].EntryReference, "sha256:"))
+		assert.True(t, parsed.Credits[1].Amount.Equal(decimal.NewFromInt(15)))
+	})
+
+	t.Run("rejects_invalid_files", func(t *testing.T) {
+		for name, file := range map[string]string{
+			"empty":          "",
+			"missing_column": "Date;Amount;Currency\n01.04.2025;1,00;EUR\n",
+			"bad_amount":     "Date;Amount;Currency;Purpose\n01.04.2025;abc;EUR;x\n",
+			"bad_date":       "Date;Amount;Currency;Purpose\n2025-04-01;1,00;EUR;x\n",
+			"bad_currency":   "Date;Amount;Currency;Purpose\n01.04.2025;1,00;euro;x\n",
+		} {
+			_, err := ParseCSV(strings.NewReader(file), testLayout())
+			var e *errs.Error
+			require.True(t, errors.As(err, &e), name)
+			assert.Equal(t, errs.InvalidArgument, e.Code, name)
+		}
+	})
+}
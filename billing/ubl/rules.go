@@ -0,0 +1,262 @@
+package ubl
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"encore.app/billing/models"
+	"encore.dev/beta/errs"
+	"github.com/shopspring/decimal"
+)
+
+// lineAmountTolerance is the slack of PEPPOL-EN16931-R120 between a line amount and its quantity times price
+var lineAmountTolerance = decimal.RequireFromString("0.02")
+
+// taxAmountTolerance is the slack of BR-CO-17 between a breakdown VAT amount and its taxable amount times its rate
+var taxAmountTolerance = decimal.RequireFromString("0.01")
+
+// RuleViolation is an EN 16931 or Peppol business rule the document breaks
+type RuleViolation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Details lists every business rule the document breaks, returned as the details of the validation error
+type Details struct {
+	Violations []RuleViolation `json:"violations"`
+}
+
+func (Details) ErrDetails() {}
+
+// violations collects the business rules a document breaks
+type violations []RuleViolation
+
+// check adds a violation of the rule unless ok
+func (v *violations) check(ok bool, rule, message string, args ...any) {
+	if !ok {
+		*v = append(*v, RuleViolation{Rule: rule, Message: fmt.Sprintf(message, args...)})
+	}
+}
+
+// err returns nil without violations, otherwise a failed precondition error listing all of them
+func (v violations) err() error {
+	if len(v) == 0 {
+		return nil
+	}
+	messages := make([]string, len(v))
+	for i, violation := range v {
+		messages[i] = violation.Rule + ": " + violation.Message
+	}
+	return &errs.Error{
+		Code:    errs.FailedPrecondition,
+		Message: "e-invoice breaks business rules: " + strings.Join(messages, "; "),
+		Details: Details{Violations: v},
+	}
+}
+
+// Validate checks the document against the EN 16931 and Peppol BIS Billing 3.0 business rules
+// that apply to the documents generated from bills, without schematron or network access
+func (d *Document) Validate() error {
+	var vs violations
+
+	vs.check(d.CustomizationID != "", "BR-01", "the document must have a specification identifier")
+	vs.check(d.ID != "", "BR-02", "the document must have a number")
+	vs.check(d.IssueDate != "", "BR-03", "the document must have an issue date")
+	vs.check(d.InvoiceTypeCode != "" || d.CreditNoteTypeCode != "", "BR-04", "the document must have a type code")
+	vs.check(d.DocumentCurrencyCode != "", "BR-05", "the document must have a currency code")
+	vs.check(d.BuyerReference != "", "PEPPOL-EN16931-R003", "the document must have a buyer reference or an order reference")
+
+	seller, buyer := d.AccountingSupplierParty.Party, d.AccountingCustomerParty.Party
+	vs.check(seller.PartyLegalEntity.RegistrationName != "", "BR-06", "the seller must have a name")
+	vs.check(buyer.PartyLegalEntity.RegistrationName != "", "BR-07", "the buyer must have a name")
+	vs.check(seller.PostalAddress.Country.IdentificationCode != "", "BR-09", "the seller address must have a country code")
+	vs.check(buyer.PostalAddress.Country.IdentificationCode != "", "BR-11", "the buyer address must have a country code")
+	vs.check(buyer.EndpointID.Value != "", "PEPPOL-EN16931-R010", "the buyer must have an electronic address")
+	vs.check(seller.EndpointID.Value != "", "PEPPOL-EN16931-R020", "the seller must have an electronic address")
+
+	lines := d.lines()
+	vs.check(len(lines) > 0, "BR-16", "the document must have at least one line")
+	lineTotal := decimal.Zero
+	categoryTotals := make(map[string]decimal.Decimal, 1)
+	ids := make(map[string]bool, len(lines))
+	for i, line := range lines {
+		vs.check(line.ID != "", "BR-21", "line %d must have an identifier", i+1)
+		vs.check(!ids[line.ID], "BR-21", "line identifier %s is not unique", line.ID)
+		ids[line.ID] = true
+
+		quantity := line.InvoicedQuantity
+		if quantity == nil {
+			quantity = line.CreditedQuantity
+		}
+		qty, hasQuantity := parseDecimal(quantityValue(quantity))
+		vs.check(hasQuantity, "BR-22", "line %s must have a quantity", line.ID)
+		vs.check(quantity != nil && quantity.UnitCode != "", "BR-23", "line %s must have a unit of measure", line.ID)
+		amount, hasAmount := parseDecimal(line.LineExtensionAmount.Value)
+		vs.check(hasAmount, "BR-24", "line %s must have a net amount", line.ID)
+		vs.check(line.Item.Name != "", "BR-25", "line %s must have an item name", line.ID)
+		price, hasPrice := parseDecimal(line.Price.PriceAmount.Value)
+		vs.check(hasPrice, "BR-26", "line %s must have an item net price", line.ID)
+		vs.check(!hasPrice || !price.IsNegative(), "BR-27", "line %s item net price cannot be negative", line.ID)
+		vs.check(decimals(line.LineExtensionAmount.Value) <= 2, "BR-DEC-23",
+			"line %s net amount must have at most 2 decimals", line.ID)
+		if hasQuantity && hasAmount && hasPrice {
+			vs.check(amount.Sub(qty.Mul(price)).Abs().LessThanOrEqual(lineAmountTolerance), "PEPPOL-EN16931-R120",
+				"line %s net amount %s must equal its quantity %s times its price %s", line.ID, amount, qty, price)
+		}
+		lineTotal = lineTotal.Add(amount)
+		key := categoryKey(line.Item.ClassifiedTaxCategory)
+		categoryTotals[key] = categoryTotals[key].Add(amount)
+
+		d.checkLineCategory(&vs, line)
+		d.checkCurrency(&vs, line.LineExtensionAmount, line.Price.PriceAmount)
+	}
+
+	d.checkTaxBreakdown(&vs, seller, buyer, categoryTotals)
+	d.checkTotals(&vs, lineTotal)
+
+	return vs.err()
+}
+
+// checkLineCategory checks the rate of the VAT category of the line
+func (d *Document) checkLineCategory(vs *violations, line Line) {
+	category := line.Item.ClassifiedTaxCategory
+	rate, hasRate := parseDecimal(category.Percent)
+	switch category.ID {
+	case models.TaxCategoryStandard:
+		vs.check(hasRate && rate.IsPositive(), "BR-S-05", "line %s of category S must have a VAT rate greater than zero", line.ID)
+	case models.TaxCategoryExempt:
+		vs.check(hasRate && rate.IsZero(), "BR-E-05", "line %s of category E must have a VAT rate of zero", line.ID)
+	case models.TaxCategoryNotSubjectToVAT:
+		vs.check(!hasRate, "BR-O-05", "line %s of category O must not have a VAT rate", line.ID)
+	case "":
+		vs.check(false, "BR-CO-04", "line %s must have a VAT category", line.ID)
+	}
+}
+
+// checkTaxBreakdown checks the VAT breakdown against the line net amounts of each category and rate,
+// and the VAT identifiers it requires or excludes
+func (d *Document) checkTaxBreakdown(vs *violations, seller, buyer Party, categoryTotals map[string]decimal.Decimal) {
+	d.checkCurrency(vs, d.TaxTotal.TaxAmount)
+	vs.check(decimals(d.TaxTotal.TaxAmount.Value) <= 2, "BR-DEC-13", "the VAT total must have at most 2 decimals")
+
+	taxTotal := decimal.Zero
+	for _, subtotal := range d.TaxTotal.TaxSubtotals {
+		category := subtotal.TaxCategory
+		taxAmount, _ := parseDecimal(subtotal.TaxAmount.Value)
+		taxTotal = taxTotal.Add(taxAmount)
+		d.checkCurrency(vs, subtotal.TaxableAmount, subtotal.TaxAmount)
+		vs.check(decimals(subtotal.TaxableAmount.Value) <= 2 && decimals(subtotal.TaxAmount.Value) <= 2, "BR-DEC-19",
+			"the VAT breakdown of category %s must have amounts of at most 2 decimals", category.ID)
+
+		key := categoryKey(category)
+		taxable, _ := parseDecimal(subtotal.TaxableAmount.Value)
+		vs.check(taxable.Equal(categoryTotals[key]), "BR-"+category.ID+"-08",
+			"the taxable amount %s of category %s must equal the sum of its line net amounts %s",
+			taxable, category.ID, categoryTotals[key])
+		delete(categoryTotals, key)
+		rate, _ := parseDecimal(category.Percent)
+		expected := taxable.Mul(rate).Div(decimal.NewFromInt(100))
+		vs.check(taxAmount.Sub(expected).Abs().LessThanOrEqual(taxAmountTolerance), "BR-CO-17",
+			"the VAT amount %s of category %s must equal its taxable amount %s times its rate %s",
+			taxAmount, category.ID, taxable, rate)
+
+		switch category.ID {
+		case models.TaxCategoryStandard:
+			vs.check(seller.PartyTaxScheme != nil, "BR-S-02", "documents of category S must have the seller VAT identifier")
+		case models.TaxCategoryExempt:
+			vs.check(category.TaxExemptionReason != "", "BR-E-10", "the VAT breakdown of category E must have an exemption reason")
+			vs.check(seller.PartyTaxScheme != nil, "BR-E-02", "documents of category E must have the seller VAT identifier")
+		case models.TaxCategoryNotSubjectToVAT:
+			vs.check(category.TaxExemptionReason != "", "BR-O-10", "the VAT breakdown of category O must have an exemption reason")
+			vs.check(seller.PartyTaxScheme == nil && buyer.PartyTaxScheme == nil, "BR-O-02",
+				"documents of category O must not have seller or buyer VAT identifiers")
+		}
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(categoryTotals)) {
+		// Lines without a category already break BR-CO-04
+		if code, _, _ := strings.Cut(key, "/"); code != "" {
+			vs.check(false, "BR-"+code+"-01", "lines of category %s must have a VAT breakdown of their category and rate", key)
+		}
+	}
+
+	total, _ := parseDecimal(d.TaxTotal.TaxAmount.Value)
+	vs.check(total.Equal(taxTotal), "BR-CO-14",
+		"the VAT total %s must equal the sum of the VAT breakdown amounts %s", total, taxTotal)
+}
+
+// checkTotals checks the monetary totals against each other and the sum of the lines
+func (d *Document) checkTotals(vs *violations, lineTotal decimal.Decimal) {
+	totals := d.LegalMonetaryTotal
+	rounding := Amount{CurrencyID: d.DocumentCurrencyCode, Value: "0"}
+	if totals.PayableRoundingAmount != nil {
+		rounding = *totals.PayableRoundingAmount
+	}
+	amounts := []Amount{
+		totals.LineExtensionAmount, totals.TaxExclusiveAmount, totals.TaxInclusiveAmount, rounding, totals.PayableAmount,
+	}
+	d.checkCurrency(vs, amounts...)
+	for _, amount := range amounts {
+		vs.check(decimals(amount.Value) <= 2, "BR-DEC", "document total %s must have at most 2 decimals", amount.Value)
+	}
+
+	lineExtension, _ := parseDecimal(totals.LineExtensionAmount.Value)
+	taxExclusive, _ := parseDecimal(totals.TaxExclusiveAmount.Value)
+	taxInclusive, _ := parseDecimal(totals.TaxInclusiveAmount.Value)
+	roundingAmount, _ := parseDecimal(rounding.Value)
+	payable, _ := parseDecimal(totals.PayableAmount.Value)
+	tax, _ := parseDecimal(d.TaxTotal.TaxAmount.Value)
+
+	vs.check(lineExtension.Equal(lineTotal), "BR-CO-10",
+		"the sum of line net amounts %s must equal the sum of the lines %s", lineExtension, lineTotal)
+	// Bills have no document level allowances or charges
+	vs.check(taxExclusive.Equal(lineExtension), "BR-CO-13",
+		"the total without VAT %s must equal the sum of line net amounts %s", taxExclusive, lineExtension)
+	vs.check(taxInclusive.Equal(taxExclusive.Add(tax)), "BR-CO-15",
+		"the total with VAT %s must equal the total without VAT %s plus the VAT total %s", taxInclusive, taxExclusive, tax)
+	vs.check(payable.Equal(taxInclusive.Add(roundingAmount)), "BR-CO-16",
+		"the amount due %s must equal the total with VAT %s plus the rounding amount %s", payable, taxInclusive, roundingAmount)
+}
+
+// categoryKey identifies a VAT breakdown: category S has one per rate, whatever its decimals,
+// the other categories of bills have a single one (BR-S-08, BR-Z-08, BR-E-08, BR-O-08)
+func categoryKey(category TaxCategory) string {
+	rate, hasRate := parseDecimal(category.Percent)
+	if category.ID != models.TaxCategoryStandard || !hasRate {
+		return category.ID
+	}
+	return category.ID + "/" + rate.String()
+}
+
+// checkCurrency checks that the amounts are in the document currency
+func (d *Document) checkCurrency(vs *violations, amounts ...Amount) {
+	for _, amount := range amounts {
+		vs.check(amount.CurrencyID == d.DocumentCurrencyCode, "PEPPOL-R051",
+			"amount %s in %s must be in the document currency %s", amount.Value, amount.CurrencyID, d.DocumentCurrencyCode)
+	}
+}
+
+func quantityValue(quantity *Quantity) string {
+	if quantity == nil {
+		return ""
+	}
+	return quantity.Value
+}
+
+func parseDecimal(value string) (decimal.Decimal, bool) {
+	if value == "" {
+		return decimal.Zero, false
+	}
+	d, err := decimal.NewFromString(value)
+	return d, err == nil
+}
+
+// decimals returns the number of decimals of a formatted amount
+func decimals(value string) int {
+	if i := strings.IndexByte(value, '.'); i >= 0 {
+		return len(value) - i - 1
+	}
+	return 0
+}
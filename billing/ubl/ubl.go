@@ -0,0 +1,459 @@
+// Package ubl generates UBL 2.1 invoices and credit notes following the Peppol BIS Billing 3.0 specification
+// of the EN 16931 e-invoicing standard
+package ubl
+
+import (
+	"encoding/xml"
+	"fmt"
+	"slices"
+	"time"
+
+	"encore.app/billing/models"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	invoiceNamespace    = "urn:oasis:names:specification:ubl:schema:xsd:Invoice-2"
+	creditNoteNamespace = "urn:oasis:names:specification:ubl:schema:xsd:CreditNote-2"
+	cacNamespace        = "urn:oasis:names:specification:ubl:schema:xsd:CommonAggregateComponents-2"
+	cbcNamespace        = "urn:oasis:names:specification:ubl:schema:xsd:CommonBasicComponents-2"
+
+	// CustomizationID identifies Peppol BIS Billing 3.0 documents
+	CustomizationID = "urn:cen.eu:en16931:2017#compliant#urn:fdc:peppol.eu:2017:poacc:billing:3.0"
+	// ProfileID identifies the Peppol billing business process
+	ProfileID = "urn:fdc:peppol.eu:2017:poacc:billing:01:1.0"
+
+	// InvoiceTypeCode is the UNCL1001 code of commercial invoices
+	InvoiceTypeCode = "380"
+	// CreditNoteTypeCode is the UNCL1001 code of credit notes
+	CreditNoteTypeCode = "381"
+
+	// unitCode is the UN/ECE Recommendation 20 unit of line quantities, "one"
+	unitCode   = "C62"
+	vatScheme  = "VAT"
+	dateLayout = "2006-01-02"
+	// priceDecimals is the precision of item prices converted to the document currency
+	priceDecimals = 6
+)
+
+// Document is a UBL invoice or credit note. Element names carry their UBL namespace prefix
+// and fields are declared in the element order of the UBL schema.
+type Document struct {
+	XMLName  xml.Name
+	Xmlns    string `xml:"xmlns,attr"`
+	XmlnsCac string `xml:"xmlns:cac,attr"`
+	XmlnsCbc string `xml:"xmlns:cbc,attr"`
+
+	CustomizationID         string            `xml:"cbc:CustomizationID"`
+	ProfileID               string            `xml:"cbc:ProfileID"`
+	ID                      string            `xml:"cbc:ID"`
+	IssueDate               string            `xml:"cbc:IssueDate"`
+	DueDate                 string            `xml:"cbc:DueDate,omitempty"`
+	InvoiceTypeCode         string            `xml:"cbc:InvoiceTypeCode,omitempty"`
+	CreditNoteTypeCode      string            `xml:"cbc:CreditNoteTypeCode,omitempty"`
+	Note                    string            `xml:"cbc:Note,omitempty"`
+	DocumentCurrencyCode    string            `xml:"cbc:DocumentCurrencyCode"`
+	BuyerReference          string            `xml:"cbc:BuyerReference,omitempty"`
+	InvoicePeriod           *Period           `xml:"cac:InvoicePeriod"`
+	BillingReference        *BillingReference `xml:"cac:BillingReference"`
+	AccountingSupplierParty PartyRole         `xml:"cac:AccountingSupplierParty"`
+	AccountingCustomerParty PartyRole         `xml:"cac:AccountingCustomerParty"`
+	PaymentMeans            *PaymentMeans     `xml:"cac:PaymentMeans"`
+	PaymentTerms            *PaymentTerms     `xml:"cac:PaymentTerms"`
+	TaxTotal                TaxTotal          `xml:"cac:TaxTotal"`
+	LegalMonetaryTotal      MonetaryTotal     `xml:"cac:LegalMonetaryTotal"`
+	InvoiceLines            []Line            `xml:"cac:InvoiceLine"`
+	CreditNoteLines         []Line            `xml:"cac:CreditNoteLine"`
+}
+
+type Amount struct {
+	CurrencyID string `xml:"currencyID,attr"`
+	Value      string `xml:",chardata"`
+}
+
+type Quantity struct {
+	UnitCode string `xml:"unitCode,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type Period struct {
+	StartDate string `xml:"cbc:StartDate"`
+	EndDate   string `xml:"cbc:EndDate"`
+}
+
+// BillingReference refers a credit note to the invoice it credits
+type BillingReference struct {
+	InvoiceDocumentReference DocumentReference `xml:"cac:InvoiceDocumentReference"`
+}
+
+type DocumentReference struct {
+	ID        string `xml:"cbc:ID"`
+	IssueDate string `xml:"cbc:IssueDate,omitempty"`
+}
+
+// PartyRole wraps the party of the seller or the buyer
+type PartyRole struct {
+	Party Party `xml:"cac:Party"`
+}
+
+type Party struct {
+	EndpointID       EndpointID      `xml:"cbc:EndpointID"`
+	PostalAddress    Address         `xml:"cac:PostalAddress"`
+	PartyTaxScheme   *PartyTaxScheme `xml:"cac:PartyTaxScheme"`
+	PartyLegalEntity LegalEntity     `xml:"cac:PartyLegalEntity"`
+}
+
+// EndpointID is the Peppol electronic address of a party
+type EndpointID struct {
+	SchemeID string `xml:"schemeID,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type Address struct {
+	StreetName string  `xml:"cbc:StreetName,omitempty"`
+	CityName   string  `xml:"cbc:CityName,omitempty"`
+	PostalZone string  `xml:"cbc:PostalZone,omitempty"`
+	Country    Country `xml:"cac:Country"`
+}
+
+type Country struct {
+	IdentificationCode string `xml:"cbc:IdentificationCode"`
+}
+
+// PartyTaxScheme holds the VAT identifier of a party
+type PartyTaxScheme struct {
+	CompanyID string    `xml:"cbc:CompanyID"`
+	TaxScheme TaxScheme `xml:"cac:TaxScheme"`
+}
+
+type LegalEntity struct {
+	RegistrationName string `xml:"cbc:RegistrationName"`
+	CompanyID        string `xml:"cbc:CompanyID,omitempty"`
+}
+
+type TaxScheme struct {
+	ID string `xml:"cbc:ID"`
+}
+
+type PaymentMeans struct {
+	PaymentMeansCode      string            `xml:"cbc:PaymentMeansCode"`
+	PaymentID             string            `xml:"cbc:PaymentID,omitempty"`
+	PayeeFinancialAccount *FinancialAccount `xml:"cac:PayeeFinancialAccount"`
+}
+
+type FinancialAccount struct {
+	ID string `xml:"cbc:ID"`
+}
+
+type PaymentTerms struct {
+	Note string `xml:"cbc:Note"`
+}
+
+type TaxTotal struct {
+	TaxAmount    Amount        `xml:"cbc:TaxAmount"`
+	TaxSubtotals []TaxSubtotal `xml:"cac:TaxSubtotal"`
+}
+
+type TaxSubtotal struct {
+	TaxableAmount Amount      `xml:"cbc:TaxableAmount"`
+	TaxAmount     Amount      `xml:"cbc:TaxAmount"`
+	TaxCategory   TaxCategory `xml:"cac:TaxCategory"`
+}
+
+// TaxCategory is the VAT category of the tax breakdown or of a line, the percent is omitted
+// for category O which has no rate
+type TaxCategory struct {
+	ID                 string    `xml:"cbc:ID"`
+	Percent            string    `xml:"cbc:Percent,omitempty"`
+	TaxExemptionReason string    `xml:"cbc:TaxExemptionReason,omitempty"`
+	TaxScheme          TaxScheme `xml:"cac:TaxScheme"`
+}
+
+type MonetaryTotal struct {
+	LineExtensionAmount   Amount  `xml:"cbc:LineExtensionAmount"`
+	TaxExclusiveAmount    Amount  `xml:"cbc:TaxExclusiveAmount"`
+	TaxInclusiveAmount    Amount  `xml:"cbc:TaxInclusiveAmount"`
+	PayableRoundingAmount *Amount `xml:"cbc:PayableRoundingAmount"`
+	PayableAmount         Amount  `xml:"cbc:PayableAmount"`
+}
+
+// Line is an invoice line, with an invoiced quantity, or a credit note line, with a credited quantity
+type Line struct {
+	ID                  string    `xml:"cbc:ID"`
+	Note                string    `xml:"cbc:Note,omitempty"`
+	InvoicedQuantity    *Quantity `xml:"cbc:InvoicedQuantity"`
+	CreditedQuantity    *Quantity `xml:"cbc:CreditedQuantity"`
+	LineExtensionAmount Amount    `xml:"cbc:LineExtensionAmount"`
+	Item                Item      `xml:"cac:Item"`
+	Price               Price     `xml:"cac:Price"`
+}
+
+type Item struct {
+	Name                  string      `xml:"cbc:Name"`
+	ClassifiedTaxCategory TaxCategory `xml:"cac:ClassifiedTaxCategory"`
+}
+
+type Price struct {
+	PriceAmount Amount `xml:"cbc:PriceAmount"`
+}
+
+// NewInvoice builds the invoice of a closed or finalized bill with its line items, issued on the day the bill
+// closed in loc. Lines are invoiced in the presentment currency of the bill at the rates of their conversions,
+// and the payable amount is rounded to the grand total of the bill.
+func NewInvoice(bill *models.Bill, buyer models.Party, settings models.InvoiceSettings, loc *time.Location) (*Document, error) {
+	if bill.ClosedAt == nil {
+		return nil, fmt.Errorf("bill %s is not closed", bill.ID)
+	}
+	currency := bill.PresentmentCurrency
+	if bill.Total != nil && bill.Total.GrandTotal != nil {
+		currency = bill.Total.GrandTotal.Currency
+	}
+	if currency == "" {
+		return nil, fmt.Errorf("bill %s has no presentment currency", bill.ID)
+	}
+
+	priced := make([]pricedLine, 0, len(bill.LineItems))
+	lines := make([]Line, 0, len(bill.LineItems))
+	net := decimal.Zero
+	for i, item := range bill.LineItems {
+		line, err := invoiceLine(i+1, item, currency, settings)
+		if err != nil {
+			return nil, err
+		}
+		net = net.Add(line.amount)
+		priced = append(priced, line)
+		lines = append(lines, line.Line)
+	}
+
+	issuedAt := bill.ClosedAt.In(loc)
+	doc := newDocument(invoiceNamespace, "Invoice", bill.ID.String(), issuedAt, currency, buyer, settings)
+	doc.DueDate = issuedAt.AddDate(0, 0, settings.PaymentTermsDays).Format(dateLayout)
+	doc.InvoiceTypeCode = InvoiceTypeCode
+	doc.BuyerReference = bill.CustomerID
+	doc.InvoicePeriod = &Period{
+		StartDate: bill.PeriodStart.In(loc).Format(dateLayout),
+		// The period end is exclusive, the last day invoiced is the day of its last instant
+		EndDate: bill.PeriodEnd.Add(-time.Nanosecond).In(loc).Format(dateLayout),
+	}
+	doc.PaymentMeans = &PaymentMeans{PaymentMeansCode: settings.PaymentMeansCode, PaymentID: bill.ID.String()}
+	if settings.PayeeIBAN != "" {
+		doc.PaymentMeans.PayeeFinancialAccount = &FinancialAccount{ID: settings.PayeeIBAN}
+	}
+	doc.PaymentTerms = &PaymentTerms{Note: fmt.Sprintf("Payment within %d days", settings.PaymentTermsDays)}
+
+	// Lines converted and rounded one by one can differ from the grand total converted at close by a few minor units
+	rounding := decimal.Zero
+	if bill.Total != nil && bill.Total.GrandTotal != nil {
+		rounding = bill.Total.GrandTotal.Amount.Sub(net)
+	}
+	doc.setTotals(priced, rounding, settings)
+	doc.InvoiceLines = lines
+	return doc, nil
+}
+
+// pricedLine is an invoice line with its unformatted amount and its VAT category
+type pricedLine struct {
+	Line
+	amount   decimal.Decimal
+	category models.TaxCategory
+}
+
+// invoiceLine builds the line of the line item priced in the document currency
+func invoiceLine(number int, item *models.LineItem, currency models.Currency, settings models.InvoiceSettings) (pricedLine, error) {
+	rate := decimal.NewFromInt(1)
+	var note string
+	if item.Currency != currency {
+		if item.Converted == nil || item.Converted.Currency != currency {
+			return pricedLine{}, models.ErrLineItemNotConverted
+		}
+		rate = item.Converted.Rate
+		note = fmt.Sprintf("Converted from %s %s at rate %s", item.Total, item.Currency, rate)
+	}
+
+	// The line amount is the quantity times the converted price, as the PEPPOL-EN16931-R120 rule requires
+	price := item.UnitPrice.Mul(rate).Round(priceDecimals)
+	amount := currency.Round(item.Quantity.Mul(price), settings.RoundingMode)
+	category := taxCategory(settings.TaxCategory)
+	category.TaxExemptionReason = ""
+	return pricedLine{
+		Line: Line{
+			ID:                  fmt.Sprint(number),
+			Note:                note,
+			InvoicedQuantity:    &Quantity{UnitCode: unitCode, Value: item.Quantity.String()},
+			LineExtensionAmount: newAmount(currency, amount),
+			Item:                Item{Name: item.Description, ClassifiedTaxCategory: category},
+			Price:               Price{PriceAmount: Amount{CurrencyID: string(currency), Value: price.String()}},
+		},
+		amount:   amount,
+		category: settings.TaxCategory,
+	}, nil
+}
+
+// NewCreditNote builds the credit note of a credit_note journal posted for the bill, crediting its amount
+// in one line and referring to the invoice of the bill
+func NewCreditNote(
+	bill *models.Bill, journal *models.Journal, buyer models.Party, settings models.InvoiceSettings, loc *time.Location,
+) (*Document, error) {
+	if journal.Type != models.JournalCreditNote || len(journal.Entries) == 0 {
+		return nil, fmt.Errorf("journal %s is not a credit note", journal.Key)
+	}
+	if bill.ClosedAt == nil {
+		return nil, fmt.Errorf("bill %s is not closed", bill.ID)
+	}
+	// The first entries of a credit note post its net amount, in the currency it was issued in, then its VAT
+	currency, amount := journal.Entries[0].Currency, journal.Entries[0].Amount
+
+	doc := newDocument(creditNoteNamespace, "CreditNote", journal.Reference, journal.PostedAt.In(loc), currency, buyer, settings)
+	doc.CreditNoteTypeCode = CreditNoteTypeCode
+	doc.Note = journal.Description
+	doc.BuyerReference = bill.CustomerID
+	doc.BillingReference = &BillingReference{InvoiceDocumentReference: DocumentReference{
+		ID:        bill.ID.String(),
+		IssueDate: bill.ClosedAt.In(loc).Format(dateLayout),
+	}}
+	category := taxCategory(settings.TaxCategory)
+	category.TaxExemptionReason = ""
+	line := pricedLine{
+		Line: Line{
+			ID:                  "1",
+			CreditedQuantity:    &Quantity{UnitCode: unitCode, Value: "1"},
+			LineExtensionAmount: newAmount(currency, amount),
+			Item:                Item{Name: fmt.Sprintf("Credit of bill %s", bill.ID), ClassifiedTaxCategory: category},
+			Price:               Price{PriceAmount: newAmount(currency, amount)},
+		},
+		amount:   amount,
+		category: settings.TaxCategory,
+	}
+	doc.setTotals([]pricedLine{line}, decimal.Zero, settings)
+	doc.CreditNoteLines = []Line{line.Line}
+	return doc, nil
+}
+
+func newDocument(
+	namespace, name, id string, issuedAt time.Time, currency models.Currency, buyer models.Party, settings models.InvoiceSettings,
+) *Document {
+	return &Document{
+		XMLName:                 xml.Name{Local: name},
+		Xmlns:                   namespace,
+		XmlnsCac:                cacNamespace,
+		XmlnsCbc:                cbcNamespace,
+		CustomizationID:         CustomizationID,
+		ProfileID:               ProfileID,
+		ID:                      id,
+		IssueDate:               issuedAt.Format(dateLayout),
+		DocumentCurrencyCode:    string(currency),
+		AccountingSupplierParty: PartyRole{Party: newParty(settings.Seller, settings.TaxCategory)},
+		AccountingCustomerParty: PartyRole{Party: newParty(buyer, settings.TaxCategory)},
+	}
+}
+
+// setTotals sets the VAT breakdown and the monetary totals of the document from its lines
+func (d *Document) setTotals(lines []pricedLine, rounding decimal.Decimal, settings models.InvoiceSettings) {
+	currency := models.Currency(d.DocumentCurrencyCode)
+	net := decimal.Zero
+	for _, line := range lines {
+		net = net.Add(line.amount)
+	}
+	subtotals, tax := taxBreakdown(currency, lines, settings)
+	d.TaxTotal = TaxTotal{TaxAmount: newAmount(currency, tax), TaxSubtotals: subtotals}
+	d.LegalMonetaryTotal = MonetaryTotal{
+		LineExtensionAmount: newAmount(currency, net),
+		TaxExclusiveAmount:  newAmount(currency, net),
+		TaxInclusiveAmount:  newAmount(currency, net.Add(tax)),
+		PayableAmount:       newAmount(currency, net.Add(tax).Add(rounding)),
+	}
+	if !rounding.IsZero() {
+		roundingAmount := newAmount(currency, rounding)
+		d.LegalMonetaryTotal.PayableRoundingAmount = &roundingAmount
+	}
+}
+
+// taxBreakdown returns the VAT breakdown of the lines per category and rate, in order of their first line,
+// and the VAT total. The VAT of each category is its taxable amount times its rate, rounded once (BR-CO-17),
+// like the VAT the ledger posts on bill totals. Documents without lines have an empty breakdown of the configured category.
+func taxBreakdown(
+	currency models.Currency, lines []pricedLine, settings models.InvoiceSettings,
+) ([]TaxSubtotal, decimal.Decimal) {
+	type group struct {
+		category models.TaxCategory
+		taxable  decimal.Decimal
+	}
+	groups := make([]*group, 0, 1)
+	for _, line := range lines {
+		i := slices.IndexFunc(groups, func(g *group) bool {
+			return g.category.Code == line.category.Code && g.category.Percent.Equal(line.category.Percent)
+		})
+		if i < 0 {
+			groups = append(groups, &group{category: line.category, taxable: decimal.Zero})
+			i = len(groups) - 1
+		}
+		groups[i].taxable = groups[i].taxable.Add(line.amount)
+	}
+	if len(groups) == 0 {
+		groups = append(groups, &group{category: settings.TaxCategory, taxable: decimal.Zero})
+	}
+
+	subtotals := make([]TaxSubtotal, 0, len(groups))
+	total := decimal.Zero
+	for _, g := range groups {
+		tax := currency.Round(g.taxable.Mul(g.category.Percent).Div(decimal.NewFromInt(100)), settings.RoundingMode)
+		total = total.Add(tax)
+		subtotals = append(subtotals, TaxSubtotal{
+			TaxableAmount: newAmount(currency, g.taxable),
+			TaxAmount:     newAmount(currency, tax),
+			TaxCategory:   taxCategory(g.category),
+		})
+	}
+	return subtotals, total
+}
+
+// Marshal returns the XML of the document
+func (d *Document) Marshal() ([]byte, error) {
+	out, err := xml.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
+
+// lines returns the invoice or credit note lines of the document
+func (d *Document) lines() []Line {
+	if d.CreditNoteTypeCode != "" {
+		return d.CreditNoteLines
+	}
+	return d.InvoiceLines
+}
+
+func newParty(party models.Party, category models.TaxCategory) Party {
+	p := Party{
+		EndpointID: EndpointID{SchemeID: party.EndpointScheme, Value: party.EndpointID},
+		PostalAddress: Address{
+			StreetName: party.Street,
+			CityName:   party.City,
+			PostalZone: party.PostalCode,
+			Country:    Country{IdentificationCode: party.CountryCode},
+		},
+		PartyLegalEntity: LegalEntity{RegistrationName: party.Name, CompanyID: party.RegistrationID},
+	}
+	// Documents not subject to VAT carry no VAT identifiers (BR-O-02)
+	if party.TaxID != "" && category.Code != models.TaxCategoryNotSubjectToVAT {
+		p.PartyTaxScheme = &PartyTaxScheme{CompanyID: party.TaxID, TaxScheme: TaxScheme{ID: vatScheme}}
+	}
+	return p
+}
+
+func taxCategory(category models.TaxCategory) TaxCategory {
+	c := TaxCategory{ID: category.Code, TaxScheme: TaxScheme{ID: vatScheme}}
+	if category.Code != models.TaxCategoryNotSubjectToVAT {
+		c.Percent = category.Percent.String()
+	}
+	if category.Code == models.TaxCategoryExempt || category.Code == models.TaxCategoryNotSubjectToVAT {
+		c.TaxExemptionReason = category.ExemptionReason
+	}
+	return c
+}
+
+// newAmount formats the amount in the minor units of the currency
+func newAmount(currency models.Currency, amount decimal.Decimal) Amount {
+	return Amount{CurrencyID: string(currency), Value: amount.StringFixed(currency.Fraction())}
+}
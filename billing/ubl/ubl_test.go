@@ -0,0 +1,351 @@
+package ubl
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"encore.app/billing/models"
+	"encore.dev/beta/errs"
+	"encore.dev/types/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	billID   = uuid.FromStringOrNil("6f1c1d0e-8a6b-4c39-9d1e-2b7a4f0c5e11")
+	closedAt = time.Date(2025, 3, 31, 20, 0, 0, 0, time.UTC)
+	tbilisi  = time.FixedZone("Asia/Tbilisi", 4*60*60)
+)
+
+func settings() models.InvoiceSettings {
+	return models.InvoiceSettings{
+		Seller: models.Party{
+			Name: "Pave Billing LLC", RegistrationID: "405000000", TaxID: "GE405000000",
+			Street: "1 Rustaveli Ave", City: "Tbilisi", PostalCode: "0108", CountryCode: "GE",
+			EndpointID: "4860000000006", EndpointScheme: "0088",
+		},
+		TaxCategory: models.TaxCategory{
+			Code: models.TaxCategoryNotSubjectToVAT, Percent: decimal.Zero, ExemptionReason: "Not subject to VAT",
+		},
+		PaymentTermsDays: 30,
+		PaymentMeansCode: "30",
+		PayeeIBAN:        "GE29NB0000000101904917",
+		RoundingMode:     models.RoundingModeHalfUp,
+	}
+}
+
+func buyer() models.Party {
+	return models.Party{
+		Name: "Acme GmbH", TaxID: "DE123456789", City: "Berlin", CountryCode: "DE",
+		EndpointID: "DE123456789", EndpointScheme: "9930",
+	}
+}
+
+func closedBill() *models.Bill {
+	return &models.Bill{
+		ID:                  billID,
+		CustomerID:          "customer-1",
+		Status:              models.BillStatusClosed,
+		PeriodStart:         time.Date(2025, 2, 28, 20, 0, 0, 0, time.UTC),
+		PeriodEnd:           closedAt,
+		PresentmentCurrency: models.USD,
+		ClosedAt:            &closedAt,
+		LineItems: []*models.LineItem{
+			{
+				Description: "API calls",
+				Currency:    models.USD,
+				Quantity:    decimal.NewFromInt(10),
+				UnitPrice:   decimal.RequireFromString("1.5"),
+				Total:       decimal.NewFromInt(15),
+				Converted: &models.LineConversion{
+					Currency: models.USD, Rate: decimal.NewFromInt(1), Amount: decimal.NewFromInt(15),
+				},
+			},
+			{
+				Description: "Storage",
+				Currency:    models.GEL,
+				Quantity:    decimal.NewFromInt(3),
+				UnitPrice:   decimal.NewFromInt(9),
+				Total:       decimal.NewFromInt(27),
+				Converted: &models.LineConversion{
+					Currency: models.USD, Rate: decimal.RequireFromString("0.3701"), Amount: decimal.RequireFromString("9.99"),
+				},
+			},
+		},
+		Total: &models.Total{
+			ByCurrency: map[models.Currency]decimal.Decimal{models.USD: decimal.NewFromInt(15), models.GEL: decimal.NewFromInt(27)},
+			GrandTotal: &models.Converted{Currency: models.USD, Amount: decimal.RequireFromString("24.99")},
+		},
+	}
+}
+
+func creditNoteJournal() *models.Journal {
+	return &models.Journal{
+		Key:         "credit_note:" + billID.String() + ":CN-1",
+		Type:        models.JournalCreditNote,
+		BillID:      billID,
+		Reference:   "CN-1",
+		Description: "Credit note CN-1 of bill " + billID.String() + ": service outage",
+		PostedAt:    time.Date(2025, 4, 10, 12, 0, 0, 0, time.UTC),
+		Entries: []*models.LedgerEntry{
+			{Account: "4000", Side: models.LedgerDebit, Currency: models.USD, Amount: decimal.NewFromInt(5)},
+			{Account: "1200", Side: models.LedgerCredit, Currency: models.USD, Amount: decimal.NewFromInt(5)},
+		},
+	}
+}
+
+// ruleViolations returns the rules broken by the document
+func ruleViolations(t *testing.T, err error) []string {
+	var e *errs.Error
+	require.True(t, errors.As(err, &e))
+	assert.Equal(t, errs.FailedPrecondition, e.Code)
+	var rules []string
+	for _, violation := range e.Details.(Details).Violations {
+		rules = append(rules, violation.Rule)
+	}
+	return rules
+}
+
+func TestNewInvoice(t *testing.T) {
+	t.Run("should_invoice_lines_in_the_presentment_currency", func(t *testing.T) {
+		doc, err := NewInvoice(closedBill(), buyer(), settings(), tbilisi)
+		require.NoError(t, err)
+
+		assert.Equal(t, "Invoice", doc.XMLName.Local)
+		assert.Equal(t, billID.String(), doc.ID)
+		assert.Equal(t, "380", doc.InvoiceTypeCode)
+		assert.Equal(t, "2025-04-01", doc.IssueDate)
+		assert.Equal(t, "2025-05-01", doc.DueDate)
+		assert.Equal(t, &Period{StartDate: "2025-03-01", EndDate: "2025-03-31"}, doc.InvoicePeriod)
+		assert.Equal(t, "USD", doc.DocumentCurrencyCode)
+		assert.Equal(t, "customer-1", doc.BuyerReference)
+		require.Len(t, doc.InvoiceLines, 2)
+		assert.Equal(t, Amount{CurrencyID: "USD", Value: "15.00"}, doc.InvoiceLines[0].LineExtensionAmount)
+		assert.Empty(t, doc.InvoiceLines[0].Note)
+		assert.Equal(t, Amount{CurrencyID: "USD", Value: "3.3309"}, doc.InvoiceLines[1].Price.PriceAmount)
+		assert.Equal(t, Amount{CurrencyID: "USD", Value: "9.99"}, doc.InvoiceLines[1].LineExtensionAmount)
+		assert.Equal(t, "Converted from 27 GEL at rate 0.3701", doc.InvoiceLines[1].Note)
+		assert.Equal(t, "24.99", doc.LegalMonetaryTotal.PayableAmount.Value)
+		assert.Nil(t, doc.LegalMonetaryTotal.PayableRoundingAmount)
+		assert.NoError(t, doc.Validate())
+	})
+
+	t.Run("should_round_the_payable_amount_to_the_grand_total", func(t *testing.T) {
+		bill := closedBill()
+		bill.Total.GrandTotal.Amount = decimal.RequireFromString("25")
+
+		doc, err := NewInvoice(bill, buyer(), settings(), tbilisi)
+		require.NoError(t, err)
+
+		assert.Equal(t, "24.99", doc.LegalMonetaryTotal.LineExtensionAmount.Value)
+		assert.Equal(t, &Amount{CurrencyID: "USD", Value: "0.01"}, doc.LegalMonetaryTotal.PayableRoundingAmount)
+		assert.Equal(t, "25.00", doc.LegalMonetaryTotal.PayableAmount.Value)
+		assert.NoError(t, doc.Validate())
+	})
+
+	t.Run("when_not_subject_to_vat_should_omit_rates_and_vat_identifiers", func(t *testing.T) {
+		doc, err := NewInvoice(closedBill(), buyer(), settings(), tbilisi)
+		require.NoError(t, err)
+
+		assert.Nil(t, doc.AccountingSupplierParty.Party.PartyTaxScheme)
+		assert.Nil(t, doc.AccountingCustomerParty.Party.PartyTaxScheme)
+		assert.Equal(t, TaxCategory{
+			ID: "O", TaxExemptionReason: "Not subject to VAT", TaxScheme: TaxScheme{ID: "VAT"},
+		}, doc.TaxTotal.TaxSubtotals[0].TaxCategory)
+		assert.Equal(t, TaxCategory{ID: "O", TaxScheme: TaxScheme{ID: "VAT"}}, doc.InvoiceLines[0].Item.ClassifiedTaxCategory)
+	})
+
+	t.Run("when_standard_rated_should_add_vat_to_the_payable_amount", func(t *testing.T) {
+		s := settings()
+		s.TaxCategory = models.TaxCategory{Code: models.TaxCategoryStandard, Percent: decimal.NewFromInt(18)}
+
+		doc, err := NewInvoice(closedBill(), buyer(), s, tbilisi)
+		require.NoError(t, err)
+
+		assert.Equal(t, "GE405000000", doc.AccountingSupplierParty.Party.PartyTaxScheme.CompanyID)
+		assert.Equal(t, "4.50", doc.TaxTotal.TaxAmount.Value)
+		assert.Equal(t, "29.49", doc.LegalMonetaryTotal.TaxInclusiveAmount.Value)
+		assert.Equal(t, "29.49", doc.LegalMonetaryTotal.PayableAmount.Value)
+		assert.Equal(t, "18", doc.InvoiceLines[0].Item.ClassifiedTaxCategory.Percent)
+		assert.NoError(t, doc.Validate())
+	})
+
+	t.Run("when_georgian_vat_applies_should_break_down_the_vat_of_the_gel_lines", func(t *testing.T) {
+		s := settings()
+		s.TaxCategory = models.TaxCategory{Code: models.TaxCategoryStandard, Percent: decimal.NewFromInt(18)}
+		bill := closedBill()
+		bill.PresentmentCurrency = models.GEL
+		bill.LineItems = bill.LineItems[1:]
+		bill.LineItems[0].Converted = &models.LineConversion{
+			Currency: models.GEL, Rate: decimal.NewFromInt(1), Amount: decimal.NewFromInt(27),
+		}
+		bill.Total = &models.Total{
+			ByCurrency: map[models.Currency]decimal.Decimal{models.GEL: decimal.NewFromInt(27)},
+			GrandTotal: &models.Converted{Currency: models.GEL, Amount: decimal.NewFromInt(27)},
+		}
+
+		doc, err := NewInvoice(bill, buyer(), s, tbilisi)
+		require.NoError(t, err)
+
+		assert.Equal(t, Amount{CurrencyID: "GEL", Value: "4.86"}, doc.TaxTotal.TaxAmount)
+		assert.Equal(t, []TaxSubtotal{{
+			TaxableAmount: Amount{CurrencyID: "GEL", Value: "27.00"},
+			TaxAmount:     Amount{CurrencyID: "GEL", Value: "4.86"},
+			TaxCategory:   TaxCategory{ID: "S", Percent: "18", TaxScheme: TaxScheme{ID: "VAT"}},
+		}}, doc.TaxTotal.TaxSubtotals)
+		assert.Equal(t, "27.00", doc.LegalMonetaryTotal.TaxExclusiveAmount.Value)
+		assert.Equal(t, "31.86", doc.LegalMonetaryTotal.PayableAmount.Value)
+		assert.NoError(t, doc.Validate())
+	})
+
+	t.Run("when_line_item_is_not_converted_should_fail", func(t *testing.T) {
+		bill := closedBill()
+		bill.LineItems[1].Converted = nil
+
+		_, err := NewInvoice(bill, buyer(), settings(), tbilisi)
+
+		assert.ErrorIs(t, err, models.ErrLineItemNotConverted)
+	})
+
+	t.Run("should_marshal_ubl_invoice_xml", func(t *testing.T) {
+		doc, err := NewInvoice(closedBill(), buyer(), settings(), tbilisi)
+		require.NoError(t, err)
+
+		out, err := doc.Marshal()
+		require.NoError(t, err)
+
+		xml := string(out)
+		assert.Contains(t, xml, `<?xml version="1.0" encoding="UTF-8"?>`)
+		assert.Contains(t, xml, `<Invoice xmlns="urn:oasis:names:specification:ubl:schema:xsd:Invoice-2" `+
+			`xmlns:cac="urn:oasis:names:specification:ubl:schema:xsd:CommonAggregateComponents-2" `+
+			`xmlns:cbc="urn:oasis:names:specification:ubl:schema:xsd:CommonBasicComponents-2">`)
+		assert.Contains(t, xml, `<cbc:CustomizationID>`+CustomizationID+`</cbc:CustomizationID>`)
+		assert.Contains(t, xml, `<cbc:EndpointID schemeID="9930">DE123456789</cbc:EndpointID>`)
+		assert.Contains(t, xml, `<cbc:InvoicedQuantity unitCode="C62">10</cbc:InvoicedQuantity>`)
+		assert.Contains(t, xml, `<cbc:PayableAmount currencyID="USD">24.99</cbc:PayableAmount>`)
+		assert.NotContains(t, xml, "CreditedQuantity")
+		assert.NotContains(t, xml, "PayableRoundingAmount")
+	})
+}
+
+func TestNewCreditNote(t *testing.T) {
+	t.Run("should_credit_the_amount_of_the_credit_note_referring_to_the_bill", func(t *testing.T) {
+		doc, err := NewCreditNote(closedBill(), creditNoteJournal(), buyer(), settings(), tbilisi)
+		require.NoError(t, err)
+
+		assert.Equal(t, "CreditNote", doc.XMLName.Local)
+		assert.Equal(t, creditNoteNamespace, doc.Xmlns)
+		assert.Equal(t, "CN-1", doc.ID)
+		assert.Equal(t, "381", doc.CreditNoteTypeCode)
+		assert.Equal(t, "2025-04-10", doc.IssueDate)
+		assert.Empty(t, doc.DueDate)
+		assert.Equal(t, DocumentReference{ID: billID.String(), IssueDate: "2025-04-01"},
+			doc.BillingReference.InvoiceDocumentReference)
+		require.Len(t, doc.CreditNoteLines, 1)
+		assert.Equal(t, &Quantity{UnitCode: "C62", Value: "1"}, doc.CreditNoteLines[0].CreditedQuantity)
+		assert.Equal(t, Amount{CurrencyID: "USD", Value: "5.00"}, doc.CreditNoteLines[0].LineExtensionAmount)
+		assert.Equal(t, "5.00", doc.LegalMonetaryTotal.PayableAmount.Value)
+		assert.NoError(t, doc.Validate())
+
+		out, err := doc.Marshal()
+		require.NoError(t, err)
+		assert.Contains(t, string(out), `<cac:CreditNoteLine>`)
+		assert.NotContains(t, string(out), "InvoiceLine")
+	})
+
+	t.Run("when_standard_rated_should_add_vat_to_the_credited_amount", func(t *testing.T) {
+		s := settings()
+		s.TaxCategory = models.TaxCategory{Code: models.TaxCategoryStandard, Percent: decimal.NewFromInt(18)}
+		journal := creditNoteJournal()
+		journal.Entries = append(journal.Entries,
+			&models.LedgerEntry{Account: "2200", Side: models.LedgerDebit, Currency: models.USD, Amount: decimal.RequireFromString("0.9")},
+			&models.LedgerEntry{Account: "1200", Side: models.LedgerCredit, Currency: models.USD, Amount: decimal.RequireFromString("0.9")},
+		)
+
+		doc, err := NewCreditNote(closedBill(), journal, buyer(), s, tbilisi)
+		require.NoError(t, err)
+
+		assert.Equal(t, "5.00", doc.TaxTotal.TaxSubtotals[0].TaxableAmount.Value)
+		assert.Equal(t, "0.90", doc.TaxTotal.TaxAmount.Value)
+		assert.Equal(t, "5.90", doc.LegalMonetaryTotal.PayableAmount.Value)
+		assert.NoError(t, doc.Validate())
+	})
+
+	t.Run("when_journal_is_not_a_credit_note_should_fail", func(t *testing.T) {
+		journal := creditNoteJournal()
+		journal.Type = models.JournalPayment
+
+		_, err := NewCreditNote(closedBill(), journal, buyer(), settings(), tbilisi)
+
+		assert.Error(t, err)
+	})
+}
+
+func TestDocument_Validate(t *testing.T) {
+	newInvoice := func(t *testing.T) *Document {
+		doc, err := NewInvoice(closedBill(), buyer(), settings(), tbilisi)
+		require.NoError(t, err)
+		return doc
+	}
+
+	t.Run("when_buyer_has_no_electronic_address_should_break_peppol_rule", func(t *testing.T) {
+		doc := newInvoice(t)
+		doc.AccountingCustomerParty.Party.EndpointID.Value = ""
+
+		assert.Equal(t, []string{"PEPPOL-EN16931-R010"}, ruleViolations(t, doc.Validate()))
+	})
+
+	t.Run("when_totals_do_not_match_the_lines_should_break_calculation_rules", func(t *testing.T) {
+		doc := newInvoice(t)
+		doc.InvoiceLines[0].LineExtensionAmount.Value = "14.00"
+
+		assert.Equal(t, []string{"PEPPOL-EN16931-R120", "BR-O-08", "BR-CO-10"}, ruleViolations(t, doc.Validate()))
+	})
+
+	t.Run("when_category_o_line_has_a_rate_should_break_rule", func(t *testing.T) {
+		doc := newInvoice(t)
+		doc.InvoiceLines[1].Item.ClassifiedTaxCategory.Percent = "0"
+
+		assert.Equal(t, []string{"BR-O-05"}, ruleViolations(t, doc.Validate()))
+	})
+
+	t.Run("when_vat_amount_does_not_match_the_rate_should_break_calculation_rule", func(t *testing.T) {
+		s := settings()
+		s.TaxCategory = models.TaxCategory{Code: models.TaxCategoryStandard, Percent: decimal.NewFromInt(18)}
+		doc, err := NewInvoice(closedBill(), buyer(), s, tbilisi)
+		require.NoError(t, err)
+		doc.TaxTotal.TaxAmount.Value = "4.60"
+		doc.TaxTotal.TaxSubtotals[0].TaxAmount.Value = "4.60"
+		doc.LegalMonetaryTotal.TaxInclusiveAmount.Value = "29.59"
+		doc.LegalMonetaryTotal.PayableAmount.Value = "29.59"
+
+		assert.Equal(t, []string{"BR-CO-17"}, ruleViolations(t, doc.Validate()))
+	})
+
+	t.Run("when_line_rate_has_no_vat_breakdown_should_break_category_rules", func(t *testing.T) {
+		s := settings()
+		s.TaxCategory = models.TaxCategory{Code: models.TaxCategoryStandard, Percent: decimal.NewFromInt(18)}
+		doc, err := NewInvoice(closedBill(), buyer(), s, tbilisi)
+		require.NoError(t, err)
+		doc.InvoiceLines[1].Item.ClassifiedTaxCategory.Percent = "5"
+
+		assert.Equal(t, []string{"BR-S-08", "BR-S-01"}, ruleViolations(t, doc.Validate()))
+	})
+
+	t.Run("when_amounts_have_more_than_two_decimals_should_break_decimal_rules", func(t *testing.T) {
+		doc := newInvoice(t)
+		doc.LegalMonetaryTotal.PayableAmount.Value = "24.990"
+
+		assert.Equal(t, []string{"BR-DEC"}, ruleViolations(t, doc.Validate()))
+	})
+
+	t.Run("when_document_has_no_lines_should_break_rules", func(t *testing.T) {
+		doc := newInvoice(t)
+		doc.InvoiceLines = nil
+		doc.BuyerReference = ""
+
+		assert.ElementsMatch(t, []string{"PEPPOL-EN16931-R003", "BR-16", "BR-O-08", "BR-CO-10"}, ruleViolations(t, doc.Validate()))
+	})
+}
@@ -0,0 +1,312 @@
+package validation
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"encore.app/billing/models"
+	"encore.dev/types/uuid"
+)
+
+// monthLayout is the format of the months of revenue reports
+const monthLayout = "2006-01"
+
+// ParseGetBillParams validates the get bill query parameters and converts them to read options
+func (v *Validator) ParseGetBillParams(params *models.GetBillParams) (models.GetBillOptions, error) {
+	var vs violations
+	var opts models.GetBillOptions
+	if params.Include != "" {
+		for _, part := range strings.Split(params.Include, ",") {
+			switch strings.TrimSpace(part) {
+			case "line_items":
+				opts.IncludeLineItems = true
+			default:
+				vs.add("include", fmt.Sprintf("unsupported include %q, supported values: line_items", part))
+			}
+		}
+	}
+	return opts, vs.err()
+}
+
+// ParseListLineItemsParams validates the list line items query parameters and converts them to a filter
+func (v *Validator) ParseListLineItemsParams(params *models.ListLineItemsParams) (models.LineItemFilter, error) {
+	var vs violations
+	filter := models.LineItemFilter{Limit: v.parseLimit(&vs, params.Limit)}
+
+	if params.Currency != "" {
+		filter.Currency = models.Currency(params.Currency)
+		v.validateCurrency(&vs, "currency", filter.Currency)
+	}
+	filter.CreatedAfter = v.parseOptionalTimestamp(&vs, "created_after", params.CreatedAfter)
+	filter.CreatedBefore = v.parseOptionalTimestamp(&vs, "created_before", params.CreatedBefore)
+
+	if params.Cursor != "" {
+		cursor, err := models.DecodeLineItemCursor(params.Cursor)
+		if err != nil {
+			vs.add("cursor", models.ErrInvalidCursor.Message)
+		}
+		filter.After = cursor
+	}
+
+	return filter, vs.err()
+}
+
+// ParseListBillWorkflowsParams validates the list bill workflows query parameters and converts them to a filter
+func (v *Validator) ParseListBillWorkflowsParams(params *models.ListBillWorkflowsParams) (models.BillWorkflowFilter, error) {
+	var vs violations
+	filter := models.BillWorkflowFilter{
+		CustomerID:      strings.TrimSpace(params.CustomerID),
+		Limit:           v.parseLimit(&vs, params.Limit),
+		PeriodEndAfter:  v.parseOptionalTimestamp(&vs, "period_end_after", params.PeriodEndAfter),
+		PeriodEndBefore: v.parseOptionalTimestamp(&vs, "period_end_before", params.PeriodEndBefore),
+	}
+
+	if params.PageToken != "" {
+		token, err := base64.RawURLEncoding.DecodeString(params.PageToken)
+		if err != nil {
+			vs.add("page_token", "invalid page token")
+		}
+		filter.PageToken = token
+	}
+
+	return filter, vs.err()
+}
+
+// ParseListReconciliationReportsParams validates the list reconciliation reports query parameters and returns the limit
+func (v *Validator) ParseListReconciliationReportsParams(params *models.ListReconciliationReportsParams) (int, error) {
+	var vs violations
+	limit := v.parseLimit(&vs, params.Limit)
+	return limit, vs.err()
+}
+
+// ParseListFailedOperationsParams validates the list failed operations query parameters and converts them to a filter
+func (v *Validator) ParseListFailedOperationsParams(params *models.ListFailedOperationsParams) (models.FailedOperationFilter, error) {
+	var vs violations
+	filter := models.FailedOperationFilter{Limit: v.parseLimit(&vs, params.Limit)}
+
+	if params.Status != "" {
+		filter.Status = models.FailedOperationStatus(params.Status)
+		if err := filter.Status.Validate(); err != nil {
+			vs.add("status", err.Error())
+		}
+	}
+
+	if params.BillID != "" {
+		billID, err := uuid.FromString(params.BillID)
+		if err != nil {
+			vs.add("bill_id", "bill_id must be a UUID")
+		} else {
+			filter.BillID = &billID
+		}
+	}
+
+	return filter, vs.err()
+}
+
+// ParseStatementFormat validates the format query parameter of a bank statement import
+func (v *Validator) ParseStatementFormat(format string) (models.StatementFormat, error) {
+	var vs violations
+	statementFormat := models.StatementFormat(format)
+	if err := statementFormat.Validate(); err != nil {
+		vs.add("format", err.Error())
+	}
+	return statementFormat, vs.err()
+}
+
+// ParseListBankTransactionsParams validates the list bank transactions query parameters and converts them to a filter
+func (v *Validator) ParseListBankTransactionsParams(params *models.ListBankTransactionsParams) (models.BankTransactionFilter, error) {
+	var vs violations
+	filter := models.BankTransactionFilter{Limit: v.parseLimit(&vs, params.Limit)}
+
+	if params.Status != "" {
+		filter.Status = models.BankTransactionStatus(params.Status)
+		if err := filter.Status.Validate(); err != nil {
+			vs.add("status", err.Error())
+		}
+	}
+
+	return filter, vs.err()
+}
+
+// ParseGetTrialBalanceParams validates the get trial balance query parameters and returns the time the balance is taken at,
+// now when unset
+func (v *Validator) ParseGetTrialBalanceParams(params *models.GetTrialBalanceParams) (time.Time, error) {
+	var vs violations
+	asOf := v.parseTimestamp(&vs, "as_of", params.AsOf, v.now())
+	return asOf, vs.err()
+}
+
+// ParseGetAgingReportParams validates the aging report query parameters and converts them to a filter,
+// taken at now when as_of is unset
+func (v *Validator) ParseGetAgingReportParams(params *models.GetAgingReportParams) (models.AgingReportFilter, error) {
+	var vs violations
+	filter := models.AgingReportFilter{
+		CustomerID:        params.CustomerID,
+		AsOf:              v.parseTimestamp(&vs, "as_of", params.AsOf, v.now()),
+		ReportingCurrency: v.parseReportingCurrency(&vs, params.ReportingCurrency),
+	}
+	return filter, vs.err()
+}
+
+// ParseGetCustomerStatementParams validates the customer statement query parameters and converts them to a filter,
+// until now when to is unset
+func (v *Validator) ParseGetCustomerStatementParams(params *models.GetCustomerStatementParams) (models.CustomerStatementFilter, error) {
+	var vs violations
+	filter := models.CustomerStatementFilter{
+		ReportingCurrency: v.parseReportingCurrency(&vs, params.ReportingCurrency),
+	}
+
+	if params.From == "" {
+		vs.add("from", "from is required")
+	} else {
+		filter.From = v.parseTimestamp(&vs, "from", params.From, time.Time{})
+	}
+	filter.To = v.parseTimestamp(&vs, "to", params.To, v.now())
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.To.After(filter.From) {
+		vs.add("to", "to must be after from")
+	}
+
+	return filter, vs.err()
+}
+
+// ParseGetRevenueReportParams validates the revenue report query parameters and converts them to a filter
+// covering whole periods of the granularity
+func (v *Validator) ParseGetRevenueReportParams(params *models.GetRevenueReportParams) (models.RevenueReportFilter, error) {
+	var vs violations
+	filter := models.RevenueReportFilter{
+		Granularity:       models.GranularityMonth,
+		ReportingCurrency: v.parseReportingCurrency(&vs, params.ReportingCurrency),
+	}
+
+	validGranularity := true
+	if params.Granularity != "" {
+		filter.Granularity = models.Granularity(params.Granularity)
+		if err := filter.Granularity.Validate(); err != nil {
+			vs.add("granularity", err.Error())
+			validGranularity = false
+		}
+	}
+
+	from, to, ok := v.parseMonthRange(&vs, params.From, params.To)
+	if ok && validGranularity {
+		filter.From = filter.Granularity.PeriodStart(from)
+		filter.To = filter.Granularity.NextPeriod(filter.Granularity.PeriodStart(to.AddDate(0, -1, 0)))
+	}
+
+	return filter, vs.err()
+}
+
+// ParseGetMRRReportParams validates the recurring revenue report query parameters and converts them to a filter
+func (v *Validator) ParseGetMRRReportParams(params *models.GetMRRReportParams) (models.MRRReportFilter, error) {
+	var vs violations
+	from, to, _ := v.parseMonthRange(&vs, params.From, params.To)
+	filter := models.MRRReportFilter{
+		From:              from,
+		To:                to,
+		ReportingCurrency: v.parseReportingCurrency(&vs, params.ReportingCurrency),
+	}
+	return filter, vs.err()
+}
+
+// ParseGetDeferredRevenueReportParams parses the months of the deferred revenue waterfall
+func (v *Validator) ParseGetDeferredRevenueReportParams(
+	params *models.GetDeferredRevenueReportParams,
+) (models.DeferredRevenueFilter, error) {
+	var vs violations
+	from, to, _ := v.parseMonthRange(&vs, params.From, params.To)
+	return models.DeferredRevenueFilter{From: from, To: to}, vs.err()
+}
+
+// parseMonthRange parses the YYYY-MM months of a report, returning the start of the first month and of the month after
+// the last, and whether both months are valid. The last month defaults to the current one and the first to 11 months before it.
+func (v *Validator) parseMonthRange(vs *violations, fromValue, toValue string) (time.Time, time.Time, bool) {
+	last, validTo := v.parseMonth(vs, "to", toValue, models.StartOfMonth(v.now()))
+	if !validTo {
+		// The default first month depends on the last one
+		v.parseMonth(vs, "from", fromValue, time.Time{})
+		return time.Time{}, time.Time{}, false
+	}
+	from, validFrom := v.parseMonth(vs, "from", fromValue, last.AddDate(0, -11, 0))
+	if !validFrom {
+		return time.Time{}, time.Time{}, false
+	}
+
+	if last.Before(from) {
+		vs.add("to", "to cannot be before from")
+		return time.Time{}, time.Time{}, false
+	}
+	maxRangeMonths := v.analytics.MaxRangeMonths()
+	to := last.AddDate(0, 1, 0)
+	if to.After(from.AddDate(0, maxRangeMonths, 0)) {
+		vs.add("to", fmt.Sprintf("report range cannot exceed %d months", maxRangeMonths))
+		return time.Time{}, time.Time{}, false
+	}
+	return from, to, true
+}
+
+// parseMonth parses the YYYY-MM query parameter to the start of the month in UTC, returning def when unset.
+// It reports whether the month is valid.
+func (v *Validator) parseMonth(vs *violations, field, value string, def time.Time) (time.Time, bool) {
+	if value == "" {
+		return def, true
+	}
+	month, err := time.Parse(monthLayout, value)
+	if err != nil {
+		vs.add(field, fmt.Sprintf("%s must be a month formatted YYYY-MM", field))
+		return time.Time{}, false
+	}
+	return month, true
+}
+
+// parseTimestamp parses the RFC 3339 query parameter, returning def when unset
+func (v *Validator) parseTimestamp(vs *violations, field, value string, def time.Time) time.Time {
+	if value == "" {
+		return def
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		vs.add(field, fmt.Sprintf("%s must be an RFC 3339 timestamp", field))
+		return time.Time{}
+	}
+	return t
+}
+
+// parseOptionalTimestamp parses the RFC 3339 query parameter, returning nil when unset or invalid
+func (v *Validator) parseOptionalTimestamp(vs *violations, field, value string) *time.Time {
+	if value == "" {
+		return nil
+	}
+	t := v.parseTimestamp(vs, field, value, time.Time{})
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// parseReportingCurrency validates the reporting currency of a report, left unset for the ledger functional currency
+func (v *Validator) parseReportingCurrency(vs *violations, value string) models.Currency {
+	if value == "" {
+		return ""
+	}
+	currency := models.Currency(value)
+	if !v.validateCurrency(vs, "reporting_currency", currency) {
+		return ""
+	}
+	return currency
+}
+
+// parseLimit applies the configured pagination default to an unset limit and rejects limits out of range
+func (v *Validator) parseLimit(vs *violations, limit int) int {
+	if limit == 0 {
+		return v.pagination.DefaultLimit()
+	}
+
+	maxLimit := v.pagination.MaxLimit()
+	if limit < 0 || limit > maxLimit {
+		vs.add("limit", fmt.Sprintf("limit must be between 1 and %d", maxLimit))
+		return 0
+	}
+	return limit
+}
@@ -0,0 +1,122 @@
+package validation
+
+import (
+	"testing"
+	"time"
+
+	"encore.app/billing/models"
+	"encore.dev/beta/errs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidator_ParseListLineItemsParams(t *testing.T) {
+	t.Run("when_params_are_unset_should_apply_default_limit", func(t *testing.T) {
+		filter, err := testValidator(365).ParseListLineItemsParams(&models.ListLineItemsParams{})
+
+		require.NoError(t, err)
+		assert.Equal(t, models.LineItemFilter{Limit: 50}, filter)
+	})
+
+	t.Run("when_params_are_invalid_should_return_all_violations", func(t *testing.T) {
+		_, err := testValidator(365).ParseListLineItemsParams(&models.ListLineItemsParams{
+			Currency:     "EUR",
+			CreatedAfter: "yesterday",
+			Cursor:       "not-a-cursor",
+			Limit:        501,
+		})
+
+		requireViolations(t, err,
+			FieldViolation{Field: "limit", Message: "limit must be between 1 and 500"},
+			FieldViolation{Field: "currency", Message: models.ErrInvalidCurrency.Message},
+			FieldViolation{Field: "created_after", Message: "created_after must be an RFC 3339 timestamp"},
+			FieldViolation{Field: "cursor", Message: models.ErrInvalidCursor.Message},
+		)
+	})
+}
+
+func TestValidator_ParseListFailedOperationsParams(t *testing.T) {
+	_, err := testValidator(365).ParseListFailedOperationsParams(&models.ListFailedOperationsParams{
+		Status: "lost",
+		BillID: "42",
+		Limit:  -1,
+	})
+
+	assert.Equal(t, []string{"limit", "status", "bill_id"}, violationFields(t, err))
+}
+
+func TestValidator_ParseGetCustomerStatementParams(t *testing.T) {
+	t.Run("when_to_is_unset_should_end_statement_now", func(t *testing.T) {
+		filter, err := testValidator(365).ParseGetCustomerStatementParams(&models.GetCustomerStatementParams{
+			From: "2025-01-01T00:00:00Z",
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), filter.From)
+		assert.Equal(t, now, filter.To)
+	})
+
+	t.Run("when_params_are_invalid_should_return_all_violations", func(t *testing.T) {
+		_, err := testValidator(365).ParseGetCustomerStatementParams(&models.GetCustomerStatementParams{
+			To:                "2025-01-01",
+			ReportingCurrency: "XYZ",
+		})
+
+		requireViolations(t, err,
+			FieldViolation{Field: "reporting_currency", Message: models.ErrInvalidCurrency.Message},
+			FieldViolation{Field: "from", Message: "from is required"},
+			FieldViolation{Field: "to", Message: "to must be an RFC 3339 timestamp"},
+		)
+	})
+}
+
+func TestValidator_ParseGetRevenueReportParams(t *testing.T) {
+	t.Run("when_months_are_unset_should_cover_the_last_12_months", func(t *testing.T) {
+		filter, err := testValidator(365).ParseGetRevenueReportParams(&models.GetRevenueReportParams{})
+
+		require.NoError(t, err)
+		assert.Equal(t, time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), filter.From)
+		assert.Equal(t, time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), filter.To)
+		assert.Equal(t, models.GranularityMonth, filter.Granularity)
+	})
+
+	t.Run("should_extend_the_range_to_whole_quarters", func(t *testing.T) {
+		filter, err := testValidator(365).ParseGetRevenueReportParams(&models.GetRevenueReportParams{
+			From: "2025-02", To: "2025-05", Granularity: "quarter",
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), filter.From)
+		assert.Equal(t, time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC), filter.To)
+	})
+
+	t.Run("when_params_are_invalid_should_return_all_violations", func(t *testing.T) {
+		_, err := testValidator(365).ParseGetRevenueReportParams(&models.GetRevenueReportParams{
+			From: "2025-13", Granularity: "week", ReportingCurrency: "XYZ",
+		})
+
+		assert.Equal(t, []string{"reporting_currency", "granularity", "from"}, violationFields(t, err))
+	})
+
+	t.Run("when_range_exceeds_max_range_months_should_return_error", func(t *testing.T) {
+		_, err := testValidator(365).ParseGetRevenueReportParams(&models.GetRevenueReportParams{
+			From: "2020-01", To: "2025-01",
+		})
+
+		requireViolations(t, err, FieldViolation{Field: "to", Message: "report range cannot exceed 24 months"})
+	})
+}
+
+// violationFields asserts that err is a validation error and returns the fields of its violations
+func violationFields(t *testing.T, err error) []string {
+	t.Helper()
+	var validationErr *errs.Error
+	require.ErrorAs(t, err, &validationErr)
+	details, ok := validationErr.Details.(Details)
+	require.True(t, ok)
+	fields := make([]string, len(details.Violations))
+	for i, violation := range details.Violations {
+		fields[i] = violation.Field
+	}
+	return fields
+}
@@ -0,0 +1,391 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"encore.app/billing/models"
+	"encore.dev/beta/errs"
+	"encore.dev/types/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// maxReferenceLength is the length of the reference column of ledger journals
+const maxReferenceLength = 255
+
+// Clock returns the current time, e.g. time.Now
+type Clock func() time.Time
+
+// FieldViolation is a request field failing validation
+type FieldViolation struct {
+	// Field is the path of the field in the request, empty for violations of the whole request
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// Details lists every field violation of a request, returned as the details of the validation error
+type Details struct {
+	Violations []FieldViolation `json:"violations"`
+}
+
+func (Details) ErrDetails() {}
+
+// violations collects the field violations of a request
+type violations []FieldViolation
+
+func (v *violations) add(field, message string) {
+	*v = append(*v, FieldViolation{Field: field, Message: message})
+}
+
+// err returns nil without violations, otherwise an invalid argument error listing all of them
+func (v violations) err() error {
+	if len(v) == 0 {
+		return nil
+	}
+	messages := make([]string, len(v))
+	for i, violation := range v {
+		messages[i] = violation.Message
+	}
+	return &errs.Error{
+		Code:    errs.InvalidArgument,
+		Message: strings.Join(messages, "; "),
+		Details: Details{Violations: v},
+	}
+}
+
+// Validator validates billing requests and query parameters against a validation configuration.
+// Validators with different configurations can serve different tenants or customers.
+type Validator struct {
+	cfg        models.ValidationConfig
+	pagination models.PaginationConfig
+	analytics  models.AnalyticsConfig
+	now        Clock
+}
+
+// NewValidator creates a validator checking the rules of cfg, the page sizes of pagination and the report ranges
+// of analytics, with now as the current time
+func NewValidator(
+	cfg models.ValidationConfig, pagination models.PaginationConfig, analytics models.AnalyticsConfig, now Clock,
+) *Validator {
+	return &Validator{cfg: cfg, pagination: pagination, analytics: analytics, now: now}
+}
+
+// ValidateCreateBillRequest validates a create bill request
+func (v *Validator) ValidateCreateBillRequest(req *models.CreateBillRequest) error {
+	var vs violations
+
+	if req.CustomerID == "" {
+		vs.add("customer_id", "customer_id is required")
+	}
+	if req.PeriodStart.IsZero() {
+		vs.add("period_start", "period_start is required")
+	}
+	if req.PeriodEnd.IsZero() {
+		vs.add("period_end", "period_end is required")
+	}
+
+	if !req.PeriodStart.IsZero() && !req.PeriodEnd.IsZero() {
+		maxBillingPeriodDays := v.cfg.MaxBillingPeriodDays()
+		if req.PeriodEnd.Before(req.PeriodStart) {
+			vs.add("period_end", models.ErrInvalidPeriod.Message)
+		} else if req.PeriodEnd.Sub(req.PeriodStart) > days(maxBillingPeriodDays) {
+			vs.add("period_end", fmt.Sprintf("billing period cannot exceed %d days", maxBillingPeriodDays))
+		}
+	}
+
+	if !req.PeriodStart.IsZero() {
+		maxPastStartDays := v.cfg.MaxPastStartDays()
+		if req.PeriodStart.Before(v.now().Add(-days(maxPastStartDays))) {
+			vs.add("period_start", fmt.Sprintf("period_start cannot be more than %d days in the past", maxPastStartDays))
+		}
+	}
+
+	if req.PresentmentCurrency != "" {
+		v.validateCurrency(&vs, "presentment_currency", req.PresentmentCurrency)
+	}
+
+	return vs.err()
+}
+
+// ValidateUpsertCustomerProfileRequest validates an upsert customer profile request
+func (v *Validator) ValidateUpsertCustomerProfileRequest(customerID string, req *models.UpsertCustomerProfileRequest) error {
+	var vs violations
+
+	if customerID == "" {
+		vs.add("customer_id", "customer_id is required")
+	}
+
+	v.validateCurrency(&vs, "presentment_currency", req.PresentmentCurrency)
+
+	if req.Timezone != "" {
+		if _, err := time.LoadLocation(req.Timezone); err != nil {
+			vs.add("timezone", fmt.Sprintf("invalid timezone %q, expected an IANA timezone such as Europe/Berlin", req.Timezone))
+		}
+	}
+
+	if req.ClosePolicy != "" {
+		if err := req.ClosePolicy.Validate(); err != nil {
+			vs.add("close_policy", err.Error())
+		}
+	}
+
+	if req.Party != nil {
+		for _, violation := range req.Party.Validate() {
+			vs.add("party."+violation.Field, violation.Message)
+		}
+	}
+
+	return vs.err()
+}
+
+// ValidateAddLineItemRequest validates an add line item request
+func (v *Validator) ValidateAddLineItemRequest(req *models.AddLineItemRequest) error {
+	var vs violations
+
+	if req.Description == "" {
+		vs.add("description", "description is required")
+	} else if maxDescriptionLength := v.cfg.MaxDescriptionLength(); len(req.Description) > maxDescriptionLength {
+		vs.add("description", fmt.Sprintf("description cannot exceed %d characters", maxDescriptionLength))
+	}
+
+	validCurrency := v.validateCurrency(&vs, "currency", req.Currency)
+	validQuantity := v.validateQuantity(&vs, req.Quantity)
+	validUnitPrice := v.validateUnitPrice(&vs, req.UnitPrice)
+
+	// Amounts are only meaningful for a valid currency, quantity and unit price
+	if validCurrency && validQuantity && validUnitPrice {
+		v.validateLineAmount(&vs, req.Currency, req.Quantity, req.UnitPrice)
+	}
+
+	if req.OccurredAt != nil && req.OccurredAt.After(v.now()) {
+		vs.add("occurred_at", "occurred_at cannot be in the future")
+	}
+
+	switch {
+	case req.ServiceStart == nil && req.ServiceEnd == nil:
+	case req.ServiceStart == nil:
+		vs.add("service_start", "service_start is required with service_end")
+	case req.ServiceEnd == nil:
+		vs.add("service_end", "service_end is required with service_start")
+	case req.ServiceEnd.Before(*req.ServiceStart):
+		vs.add("service_end", "service_end cannot be before service_start")
+	default:
+		if maxBillingPeriodDays := v.cfg.MaxBillingPeriodDays(); req.ServiceEnd.Sub(*req.ServiceStart) > days(maxBillingPeriodDays) {
+			vs.add("service_end", fmt.Sprintf("service period cannot exceed %d days", maxBillingPeriodDays))
+		}
+	}
+
+	return vs.err()
+}
+
+// ValidateUpdateLineItemRequest validates an update line item request
+func (v *Validator) ValidateUpdateLineItemRequest(req *models.UpdateLineItemRequest) error {
+	var vs violations
+
+	if req.Description == nil && req.Quantity == nil && req.UnitPrice == nil {
+		vs.add("", "at least one of description, quantity or unit_price is required")
+		return vs.err()
+	}
+
+	if req.Description != nil {
+		maxDescriptionLength := v.cfg.MaxDescriptionLength()
+		if *req.Description == "" || len(*req.Description) > maxDescriptionLength {
+			vs.add("description", fmt.Sprintf("description must be between 1 and %d characters", maxDescriptionLength))
+		}
+	}
+	if req.Quantity != nil {
+		v.validateQuantity(&vs, *req.Quantity)
+	}
+	if req.UnitPrice != nil {
+		v.validateUnitPrice(&vs, *req.UnitPrice)
+	}
+
+	return vs.err()
+}
+
+// ValidateLineItemAmounts validates the amounts of a line item updated with a request,
+// the currency of the line item being needed to check them
+func (v *Validator) ValidateLineItemAmounts(item *models.LineItem) error {
+	var vs violations
+	v.validateLineAmount(&vs, item.Currency, item.Quantity, item.UnitPrice)
+	return vs.err()
+}
+
+// ValidateRemoveLineItemParams validates the remove line item parameters
+func (v *Validator) ValidateRemoveLineItemParams(params *models.RemoveLineItemParams) error {
+	var vs violations
+	v.validateReason(&vs, "reason", params.Reason)
+	return vs.err()
+}
+
+// ValidateVoidBillRequest validates a void bill request
+func (v *Validator) ValidateVoidBillRequest(req *models.VoidBillRequest) error {
+	var vs violations
+	v.validateReason(&vs, "reason", req.Reason)
+	return vs.err()
+}
+
+// ValidateAcknowledgeFailedOperationRequest validates the note explaining why a failed operation is not persisted
+func (v *Validator) ValidateAcknowledgeFailedOperationRequest(req *models.AcknowledgeFailedOperationRequest) error {
+	var vs violations
+	v.validateReason(&vs, "note", req.Note)
+	return vs.err()
+}
+
+// ValidateMatchBankTransactionRequest validates the bill a bank transaction is matched to
+func (v *Validator) ValidateMatchBankTransactionRequest(req *models.MatchBankTransactionRequest) error {
+	var vs violations
+	if req.BillID == uuid.Nil {
+		vs.add("bill_id", "bill_id is required")
+	}
+	return vs.err()
+}
+
+// ValidateDismissBankTransactionRequest validates the note explaining why a bank transaction is not a bill payment
+func (v *Validator) ValidateDismissBankTransactionRequest(req *models.DismissBankTransactionRequest) error {
+	var vs violations
+	v.validateReason(&vs, "note", req.Note)
+	return vs.err()
+}
+
+// ValidateRecordPaymentRequest validates a record payment request
+func (v *Validator) ValidateRecordPaymentRequest(req *models.RecordPaymentRequest) error {
+	var vs violations
+	v.validateAmount(&vs, req.Currency, req.Amount)
+	v.validateReference(&vs, req.Reference)
+	if req.ReceivedAt != nil && req.ReceivedAt.After(v.now()) {
+		vs.add("received_at", "received_at cannot be in the future")
+	}
+	return vs.err()
+}
+
+// ValidateIssueCreditNoteRequest validates an issue credit note request
+func (v *Validator) ValidateIssueCreditNoteRequest(req *models.IssueCreditNoteRequest) error {
+	var vs violations
+	v.validateAmount(&vs, req.Currency, req.Amount)
+	v.validateReference(&vs, req.Reference)
+	v.validateReason(&vs, "reason", req.Reason)
+	return vs.err()
+}
+
+// ValidateCreateExportRequest validates a create export request
+func (v *Validator) ValidateCreateExportRequest(req *models.CreateExportRequest) error {
+	var vs violations
+
+	if err := req.Format.Validate(); err != nil {
+		vs.add("format", err.Error())
+	}
+	if req.From.IsZero() {
+		vs.add("from", "from is required")
+	}
+	if req.To.IsZero() {
+		vs.add("to", "to is required")
+	}
+
+	if !req.From.IsZero() && !req.To.IsZero() {
+		maxExportRangeDays := v.cfg.MaxExportRangeDays()
+		if !req.To.After(req.From) {
+			vs.add("to", "to must be after from")
+		} else if req.To.Sub(req.From) > days(maxExportRangeDays) {
+			vs.add("to", fmt.Sprintf("export range cannot exceed %d days", maxExportRangeDays))
+		}
+	}
+
+	return vs.err()
+}
+
+// ValidateRefreshRevenueRollupsRequest validates the first month of a revenue rollups refresh
+func (v *Validator) ValidateRefreshRevenueRollupsRequest(req *models.RefreshRevenueRollupsRequest) error {
+	var vs violations
+	if req.From.After(v.now()) {
+		vs.add("from", "from cannot be in the future")
+	}
+	return vs.err()
+}
+
+// validateAmount checks that a payment or credit note amount is positive,
+// in an enabled currency and expressible in its minor units
+func (v *Validator) validateAmount(vs *violations, currency models.Currency, amount decimal.Decimal) {
+	validCurrency := v.validateCurrency(vs, "currency", currency)
+	if !amount.IsPositive() {
+		vs.add("amount", "amount must be positive")
+		return
+	}
+	if validCurrency && !currency.AllowsPrecision(amount) {
+		vs.add("amount", fmt.Sprintf("amount cannot have more than %d decimal places for %s", currency.Fraction(), currency))
+	}
+	maxTotalAmount := decimal.NewFromFloat(v.cfg.MaxTotalAmount())
+	if amount.GreaterThan(maxTotalAmount) {
+		vs.add("amount", fmt.Sprintf("amount cannot exceed %s", maxTotalAmount))
+	}
+}
+
+// validateReference checks that a payment or credit note reference is present and within the maximum reference length
+func (v *Validator) validateReference(vs *violations, reference string) {
+	if strings.TrimSpace(reference) == "" || len(reference) > maxReferenceLength {
+		vs.add("reference", fmt.Sprintf("reference is required and cannot exceed %d characters", maxReferenceLength))
+	}
+}
+
+// validateCurrency reports whether the currency is enabled, adding a violation otherwise
+func (v *Validator) validateCurrency(vs *violations, field string, currency models.Currency) bool {
+	if err := currency.ValidateAllowed(v.cfg.AllowedCurrencies()); err != nil {
+		vs.add(field, models.ErrInvalidCurrency.Message)
+		return false
+	}
+	return true
+}
+
+// validateQuantity reports whether the quantity is positive and within the configured maximum
+func (v *Validator) validateQuantity(vs *violations, quantity decimal.Decimal) bool {
+	if quantity.LessThanOrEqual(decimal.Zero) {
+		vs.add("quantity", models.ErrInvalidQuantity.Message)
+		return false
+	}
+	maxQuantity := decimal.NewFromFloat(v.cfg.MaxQuantity())
+	if quantity.GreaterThan(maxQuantity) {
+		vs.add("quantity", fmt.Sprintf("quantity cannot exceed %s", maxQuantity))
+		return false
+	}
+	return true
+}
+
+// validateUnitPrice reports whether the unit price is not negative and within the configured maximum
+func (v *Validator) validateUnitPrice(vs *violations, unitPrice decimal.Decimal) bool {
+	if unitPrice.LessThan(decimal.Zero) {
+		vs.add("unit_price", "unit_price cannot be negative")
+		return false
+	}
+	maxUnitPrice := decimal.NewFromFloat(v.cfg.MaxUnitPrice())
+	if unitPrice.GreaterThan(maxUnitPrice) {
+		vs.add("unit_price", fmt.Sprintf("unit_price cannot exceed %s", maxUnitPrice))
+		return false
+	}
+	return true
+}
+
+// validateLineAmount checks that the unit price fits the minor units of the currency, e.g. no fractional JPY,
+// and that the line amount does not exceed the configured maximum
+func (v *Validator) validateLineAmount(vs *violations, currency models.Currency, quantity, unitPrice decimal.Decimal) {
+	if !currency.AllowsPrecision(unitPrice) {
+		vs.add("unit_price", fmt.Sprintf("unit_price cannot have more than %d decimal places for %s", currency.Fraction(), currency))
+	}
+	maxTotalAmount := decimal.NewFromFloat(v.cfg.MaxTotalAmount())
+	if quantity.Mul(unitPrice).GreaterThan(maxTotalAmount) {
+		vs.add("unit_price", fmt.Sprintf("total line item amount cannot exceed %s", maxTotalAmount))
+	}
+}
+
+// validateReason checks that a removal or void reason, or an acknowledgement note, is present
+// and within the description length
+func (v *Validator) validateReason(vs *violations, field, reason string) {
+	maxReasonLength := v.cfg.MaxDescriptionLength()
+	if strings.TrimSpace(reason) == "" || len(reason) > maxReasonLength {
+		vs.add(field, fmt.Sprintf("%s is required and cannot exceed %d characters", field, maxReasonLength))
+	}
+}
+
+func days(n int) time.Duration {
+	return time.Duration(n) * 24 * time.Hour
+}
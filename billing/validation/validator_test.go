@@ -0,0 +1,445 @@
+package validation
+
+import (
+	"testing"
+	"time"
+
+	"encore.app/billing/models"
+	"encore.dev/beta/errs"
+	"encore.dev/types/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var now = time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+
+func testValidator(maxBillingPeriodDays int) *Validator {
+	return NewValidator(models.ValidationConfig{
+		MaxBillingPeriodDays: func() int { return maxBillingPeriodDays },
+		MaxPastStartDays:     func() int { return 1 },
+		MaxDescriptionLength: func() int { return 20 },
+		MaxQuantity:          func() float64 { return 1000 },
+		MaxUnitPrice:         func() float64 { return 1000 },
+		MaxTotalAmount:       func() float64 { return 10000 },
+		AllowedCurrencies:    func() []string { return []string{"USD", "JPY"} },
+		MaxExportRangeDays:   func() int { return 31 },
+	}, models.PaginationConfig{
+		DefaultLimit: func() int { return 50 },
+		MaxLimit:     func() int { return 500 },
+	}, models.AnalyticsConfig{
+		MaxRangeMonths: func() int { return 24 },
+	}, func() time.Time { return now })
+}
+
+// requireViolations asserts that err is an invalid argument error listing the violations
+func requireViolations(t *testing.T, err error, expected ...FieldViolation) {
+	t.Helper()
+	var validationErr *errs.Error
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, errs.InvalidArgument, validationErr.Code)
+	details, ok := validationErr.Details.(Details)
+	require.True(t, ok)
+	assert.Equal(t, expected, details.Violations)
+	for _, violation := range expected {
+		assert.Contains(t, validationErr.Message, violation.Message)
+	}
+}
+
+func TestValidator_ValidateCreateBillRequest(t *testing.T) {
+	validReq := func() *models.CreateBillRequest {
+		return &models.CreateBillRequest{
+			CustomerID:  "customer-123",
+			PeriodStart: now,
+			PeriodEnd:   now.AddDate(0, 1, 0),
+		}
+	}
+
+	t.Run("when_request_is_valid_should_return_nil", func(t *testing.T) {
+		assert.NoError(t, testValidator(365).ValidateCreateBillRequest(validReq()))
+	})
+
+	t.Run("when_fields_are_missing_should_return_all_violations", func(t *testing.T) {
+		err := testValidator(365).ValidateCreateBillRequest(&models.CreateBillRequest{PresentmentCurrency: "EUR"})
+
+		requireViolations(t, err,
+			FieldViolation{Field: "customer_id", Message: "customer_id is required"},
+			FieldViolation{Field: "period_start", Message: "period_start is required"},
+			FieldViolation{Field: "period_end", Message: "period_end is required"},
+			FieldViolation{Field: "presentment_currency", Message: "unsupported currency"},
+		)
+	})
+
+	t.Run("when_period_starts_too_far_in_the_past_should_use_clock", func(t *testing.T) {
+		req := validReq()
+		req.PeriodStart = now.Add(-25 * time.Hour)
+
+		err := testValidator(365).ValidateCreateBillRequest(req)
+
+		requireViolations(t, err, FieldViolation{Field: "period_start", Message: "period_start cannot be more than 1 days in the past"})
+	})
+
+	t.Run("when_period_exceeds_configured_maximum_should_return_error", func(t *testing.T) {
+		req := validReq()
+
+		assert.NoError(t, testValidator(31).ValidateCreateBillRequest(req))
+		requireViolations(t, testValidator(7).ValidateCreateBillRequest(req),
+			FieldViolation{Field: "period_end", Message: "billing period cannot exceed 7 days"})
+	})
+
+	t.Run("when_period_ends_before_it_starts_should_return_error", func(t *testing.T) {
+		req := validReq()
+		req.PeriodEnd = now.Add(-time.Hour)
+
+		err := testValidator(365).ValidateCreateBillRequest(req)
+
+		requireViolations(t, err, FieldViolation{Field: "period_end", Message: "period_end must be after period_start"})
+	})
+}
+
+func TestValidator_ValidateAddLineItemRequest(t *testing.T) {
+	validReq := func() *models.AddLineItemRequest {
+		return &models.AddLineItemRequest{
+			Description: "API calls",
+			Currency:    models.USD,
+			Quantity:    decimal.NewFromInt(10),
+			UnitPrice:   decimal.RequireFromString("1.25"),
+		}
+	}
+
+	t.Run("when_request_is_valid_should_return_nil", func(t *testing.T) {
+		assert.NoError(t, testValidator(365).ValidateAddLineItemRequest(validReq()))
+	})
+
+	t.Run("when_fields_are_invalid_should_return_all_violations", func(t *testing.T) {
+		occurredAt := now.Add(time.Second)
+		req := &models.AddLineItemRequest{
+			Description: "a description that is too long",
+			Currency:    "EUR",
+			Quantity:    decimal.NewFromInt(-1),
+			UnitPrice:   decimal.NewFromInt(-1),
+			OccurredAt:  &occurredAt,
+		}
+
+		err := testValidator(365).ValidateAddLineItemRequest(req)
+
+		requireViolations(t, err,
+			FieldViolation{Field: "description", Message: "description cannot exceed 20 characters"},
+			FieldViolation{Field: "currency", Message: "unsupported currency"},
+			FieldViolation{Field: "quantity", Message: "quantity must be greater than zero"},
+			FieldViolation{Field: "unit_price", Message: "unit_price cannot be negative"},
+			FieldViolation{Field: "occurred_at", Message: "occurred_at cannot be in the future"},
+		)
+	})
+
+	t.Run("when_occurred_at_is_not_after_clock_should_return_nil", func(t *testing.T) {
+		req := validReq()
+		req.OccurredAt = &now
+
+		assert.NoError(t, testValidator(365).ValidateAddLineItemRequest(req))
+	})
+
+	t.Run("when_service_period_is_invalid_should_return_error", func(t *testing.T) {
+		start := now.AddDate(0, -1, 0)
+		tests := []struct {
+			name     string
+			start    *time.Time
+			end      *time.Time
+			expected FieldViolation
+		}{
+			{"missing start", nil, &now, FieldViolation{Field: "service_start", Message: "service_start is required with service_end"}},
+			{"missing end", &start, nil, FieldViolation{Field: "service_end", Message: "service_end is required with service_start"}},
+			{"end before start", &now, &start, FieldViolation{Field: "service_end", Message: "service_end cannot be before service_start"}},
+			{"too long", &start, &now, FieldViolation{Field: "service_end", Message: "service period cannot exceed 7 days"}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				req := validReq()
+				req.ServiceStart = tt.start
+				req.ServiceEnd = tt.end
+
+				requireViolations(t, testValidator(7).ValidateAddLineItemRequest(req), tt.expected)
+			})
+		}
+	})
+
+	t.Run("when_service_period_is_valid_should_return_nil", func(t *testing.T) {
+		req := validReq()
+		start, end := now.AddDate(0, 0, -30), now.AddDate(0, 10, 0)
+		req.ServiceStart, req.ServiceEnd = &start, &end
+
+		assert.NoError(t, testValidator(365).ValidateAddLineItemRequest(req))
+	})
+
+	t.Run("when_amounts_are_invalid_should_return_error", func(t *testing.T) {
+		tests := []struct {
+			name      string
+			currency  models.Currency
+			quantity  string
+			unitPrice string
+			expected  string
+		}{
+			{"fractional JPY", models.Currency("JPY"), "1", "10.5", "unit_price cannot have more than 0 decimal places for JPY"},
+			{"USD beyond cents", models.USD, "1", "10.005", "unit_price cannot have more than 2 decimal places for USD"},
+			{"total above maximum", models.USD, "1000", "10.01", "total line item amount cannot exceed 10000"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				req := validReq()
+				req.Currency = tt.currency
+				req.Quantity = decimal.RequireFromString(tt.quantity)
+				req.UnitPrice = decimal.RequireFromString(tt.unitPrice)
+
+				err := testValidator(365).ValidateAddLineItemRequest(req)
+
+				requireViolations(t, err, FieldViolation{Field: "unit_price", Message: tt.expected})
+			})
+		}
+	})
+}
+
+func TestValidator_ValidateUpdateLineItemRequest(t *testing.T) {
+	t.Run("when_nothing_is_updated_should_return_request_violation", func(t *testing.T) {
+		err := testValidator(365).ValidateUpdateLineItemRequest(&models.UpdateLineItemRequest{})
+
+		requireViolations(t, err, FieldViolation{Message: "at least one of description, quantity or unit_price is required"})
+	})
+
+	t.Run("when_fields_are_invalid_should_return_all_violations", func(t *testing.T) {
+		description := ""
+		quantity := decimal.NewFromInt(1001)
+		unitPrice := decimal.NewFromInt(1001)
+
+		err := testValidator(365).ValidateUpdateLineItemRequest(&models.UpdateLineItemRequest{
+			Description: &description,
+			Quantity:    &quantity,
+			UnitPrice:   &unitPrice,
+		})
+
+		requireViolations(t, err,
+			FieldViolation{Field: "description", Message: "description must be between 1 and 20 characters"},
+			FieldViolation{Field: "quantity", Message: "quantity cannot exceed 1000"},
+			FieldViolation{Field: "unit_price", Message: "unit_price cannot exceed 1000"},
+		)
+	})
+}
+
+func TestValidator_ValidateLineItemAmounts(t *testing.T) {
+	t.Run("when_amounts_are_valid_should_return_nil", func(t *testing.T) {
+		item := &models.LineItem{Currency: models.USD, Quantity: decimal.NewFromInt(3), UnitPrice: decimal.RequireFromString("9.99")}
+
+		assert.NoError(t, testValidator(365).ValidateLineItemAmounts(item))
+	})
+
+	t.Run("when_updated_amounts_are_invalid_should_return_error", func(t *testing.T) {
+		tests := []struct {
+			name      string
+			currency  models.Currency
+			quantity  string
+			unitPrice string
+			expected  string
+		}{
+			{"fractional JPY", models.Currency("JPY"), "1", "10.5", "unit_price cannot have more than 0 decimal places for JPY"},
+			{"total above maximum", models.USD, "1000", "10.01", "total line item amount cannot exceed 10000"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				item := &models.LineItem{
+					Currency:  tt.currency,
+					Quantity:  decimal.RequireFromString(tt.quantity),
+					UnitPrice: decimal.RequireFromString(tt.unitPrice),
+				}
+
+				err := testValidator(365).ValidateLineItemAmounts(item)
+
+				requireViolations(t, err, FieldViolation{Field: "unit_price", Message: tt.expected})
+			})
+		}
+	})
+}
+
+func TestValidator_ValidateUpsertCustomerProfileRequest(t *testing.T) {
+	t.Run("when_fields_are_invalid_should_return_all_violations", func(t *testing.T) {
+		err := testValidator(365).ValidateUpsertCustomerProfileRequest("", &models.UpsertCustomerProfileRequest{
+			PresentmentCurrency: "EUR",
+			Timezone:            "Mars/Olympus",
+			ClosePolicy:         "weekly",
+		})
+
+		var validationErr *errs.Error
+		require.ErrorAs(t, err, &validationErr)
+		details, ok := validationErr.Details.(Details)
+		require.True(t, ok)
+		fields := make([]string, 0, len(details.Violations))
+		for _, violation := range details.Violations {
+			fields = append(fields, violation.Field)
+		}
+		assert.Equal(t, []string{"customer_id", "presentment_currency", "timezone", "close_policy"}, fields)
+	})
+
+	t.Run("when_party_is_incomplete_should_return_party_violations", func(t *testing.T) {
+		err := testValidator(365).ValidateUpsertCustomerProfileRequest("customer-123", &models.UpsertCustomerProfileRequest{
+			PresentmentCurrency: models.USD,
+			Party:               &models.Party{Name: "Acme GmbH", CountryCode: "de", EndpointScheme: "GLN"},
+		})
+
+		requireViolations(t, err,
+			FieldViolation{Field: "party.country_code", Message: "country_code must be an ISO 3166-1 alpha-2 code, e.g. GE"},
+			FieldViolation{Field: "party.endpoint_id", Message: "endpoint_id is required"},
+			FieldViolation{Field: "party.endpoint_scheme", Message: "endpoint_scheme must be a 4 digit EAS code, e.g. 0088"},
+		)
+	})
+
+	t.Run("when_request_is_valid_should_return_nil", func(t *testing.T) {
+		err := testValidator(365).ValidateUpsertCustomerProfileRequest("customer-123", &models.UpsertCustomerProfileRequest{
+			PresentmentCurrency: models.USD,
+			Timezone:            "Europe/Berlin",
+			Party:               &models.Party{Name: "Acme GmbH", CountryCode: "DE", EndpointID: "DE123456789", EndpointScheme: "9930"},
+		})
+
+		assert.NoError(t, err)
+	})
+}
+
+func TestValidator_ValidateAcknowledgeFailedOperationRequest(t *testing.T) {
+	t.Run("when_note_is_blank_should_return_note_violation", func(t *testing.T) {
+		err := testValidator(365).ValidateAcknowledgeFailedOperationRequest(&models.AcknowledgeFailedOperationRequest{Note: " "})
+
+		requireViolations(t, err, FieldViolation{Field: "note", Message: "note is required and cannot exceed 20 characters"})
+	})
+
+	t.Run("when_note_is_set_should_return_nil", func(t *testing.T) {
+		err := testValidator(365).ValidateAcknowledgeFailedOperationRequest(&models.AcknowledgeFailedOperationRequest{Note: "closed manually"})
+
+		assert.NoError(t, err)
+	})
+}
+
+func TestValidator_ValidateBankTransactionRequests(t *testing.T) {
+	t.Run("when_bill_id_is_missing_should_return_bill_id_violation", func(t *testing.T) {
+		err := testValidator(365).ValidateMatchBankTransactionRequest(&models.MatchBankTransactionRequest{})
+
+		requireViolations(t, err, FieldViolation{Field: "bill_id", Message: "bill_id is required"})
+	})
+
+	t.Run("when_bill_id_is_set_should_return_nil", func(t *testing.T) {
+		err := testValidator(365).ValidateMatchBankTransactionRequest(&models.MatchBankTransactionRequest{
+			BillID: uuid.Must(uuid.NewV4()),
+		})
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("when_dismiss_note_is_blank_should_return_note_violation", func(t *testing.T) {
+		err := testValidator(365).ValidateDismissBankTransactionRequest(&models.DismissBankTransactionRequest{Note: ""})
+
+		requireViolations(t, err, FieldViolation{Field: "note", Message: "note is required and cannot exceed 20 characters"})
+	})
+}
+
+func TestValidator_ValidateRecordPaymentRequest(t *testing.T) {
+	t.Run("when_request_is_valid_should_return_nil", func(t *testing.T) {
+		receivedAt := now.Add(-time.Hour)
+		err := testValidator(365).ValidateRecordPaymentRequest(&models.RecordPaymentRequest{
+			Amount: decimal.RequireFromString("10.50"), Currency: models.USD, Reference: "TRF-1", ReceivedAt: &receivedAt,
+		})
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("when_fields_are_invalid_should_return_all_violations", func(t *testing.T) {
+		receivedAt := now.Add(time.Hour)
+		err := testValidator(365).ValidateRecordPaymentRequest(&models.RecordPaymentRequest{
+			Amount: decimal.RequireFromString("10.5"), Currency: "JPY", ReceivedAt: &receivedAt,
+		})
+
+		requireViolations(t, err,
+			FieldViolation{Field: "amount", Message: "amount cannot have more than 0 decimal places for JPY"},
+			FieldViolation{Field: "reference", Message: "reference is required and cannot exceed 255 characters"},
+			FieldViolation{Field: "received_at", Message: "received_at cannot be in the future"},
+		)
+	})
+
+	t.Run("when_amount_is_not_positive_should_return_amount_violation", func(t *testing.T) {
+		err := testValidator(365).ValidateRecordPaymentRequest(&models.RecordPaymentRequest{
+			Amount: decimal.Zero, Currency: models.USD, Reference: "TRF-1",
+		})
+
+		requireViolations(t, err, FieldViolation{Field: "amount", Message: "amount must be positive"})
+	})
+}
+
+func TestValidator_ValidateIssueCreditNoteRequest(t *testing.T) {
+	t.Run("when_request_is_valid_should_return_nil", func(t *testing.T) {
+		err := testValidator(365).ValidateIssueCreditNoteRequest(&models.IssueCreditNoteRequest{
+			Amount: decimal.NewFromInt(5), Currency: models.USD, Reference: "CN-1", Reason: "service outage",
+		})
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("when_fields_are_invalid_should_return_all_violations", func(t *testing.T) {
+		err := testValidator(365).ValidateIssueCreditNoteRequest(&models.IssueCreditNoteRequest{
+			Amount: decimal.NewFromInt(20000), Currency: "EUR", Reference: "CN-1",
+		})
+
+		requireViolations(t, err,
+			FieldViolation{Field: "currency", Message: models.ErrInvalidCurrency.Message},
+			FieldViolation{Field: "amount", Message: "amount cannot exceed 10000"},
+			FieldViolation{Field: "reason", Message: "reason is required and cannot exceed 20 characters"},
+		)
+	})
+}
+
+func TestValidator_ValidateCreateExportRequest(t *testing.T) {
+	t.Run("when_request_is_valid_should_return_nil", func(t *testing.T) {
+		err := testValidator(365).ValidateCreateExportRequest(&models.CreateExportRequest{
+			Format: models.ExportQuickBooksIIF, From: now.AddDate(0, 0, -31), To: now,
+		})
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("when_fields_are_missing_should_return_all_violations", func(t *testing.T) {
+		err := testValidator(365).ValidateCreateExportRequest(&models.CreateExportRequest{Format: "xlsx"})
+
+		requireViolations(t, err,
+			FieldViolation{Field: "format", Message: models.ExportFormat("xlsx").Validate().Error()},
+			FieldViolation{Field: "from", Message: "from is required"},
+			FieldViolation{Field: "to", Message: "to is required"},
+		)
+	})
+
+	t.Run("when_to_is_not_after_from_should_return_to_violation", func(t *testing.T) {
+		err := testValidator(365).ValidateCreateExportRequest(&models.CreateExportRequest{
+			Format: models.ExportBillsCSV, From: now, To: now,
+		})
+
+		requireViolations(t, err, FieldViolation{Field: "to", Message: "to must be after from"})
+	})
+
+	t.Run("when_range_is_too_long_should_return_to_violation", func(t *testing.T) {
+		err := testValidator(365).ValidateCreateExportRequest(&models.CreateExportRequest{
+			Format: models.ExportBillsCSV, From: now.AddDate(0, 0, -32), To: now,
+		})
+
+		requireViolations(t, err, FieldViolation{Field: "to", Message: "export range cannot exceed 31 days"})
+	})
+}
+
+func TestValidator_ValidateRefreshRevenueRollupsRequest(t *testing.T) {
+	t.Run("when_from_is_unset_or_past_should_return_nil", func(t *testing.T) {
+		assert.NoError(t, testValidator(365).ValidateRefreshRevenueRollupsRequest(&models.RefreshRevenueRollupsRequest{}))
+		assert.NoError(t, testValidator(365).ValidateRefreshRevenueRollupsRequest(&models.RefreshRevenueRollupsRequest{
+			From: now.AddDate(-1, 0, 0),
+		}))
+	})
+
+	t.Run("when_from_is_in_future_should_return_from_violation", func(t *testing.T) {
+		err := testValidator(365).ValidateRefreshRevenueRollupsRequest(&models.RefreshRevenueRollupsRequest{
+			From: now.Add(time.Hour),
+		})
+
+		requireViolations(t, err, FieldViolation{Field: "from", Message: "from cannot be in the future"})
+	})
+}